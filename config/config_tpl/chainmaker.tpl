@@ -8,11 +8,17 @@
 # [*] the represented items could not be modified after startup
 
 # "auth_type" should be consistent among the whole chain configuration files(e.g., bc1.yml and chainmaker.yml)
-# The auth type can be permissionedWithCert, permissionedWithKey, public.
+# The auth type can be permissionedWithCert, permissionedWithKey, public, did.
 # By default it is permissionedWithCert.
 # permissionedWithCert: permissioned blockchain, using x.509 certificate to identify members.
 # permissionedWithKey: permissioned blockchain, using public key to identify members.
 # public: public blockchain, using public key to identify members.
+# did: permissioned blockchain, using DIDs and verifiable credentials to identify members,
+#   the trust roots of the orgs are the DID documents of their issuers, the other members carry the credentials
+#   issued to their DIDs, whose DID documents are kept in the state of contract DID_REGISTRY, the nodes are
+#   identified by public keys as permissionedWithKey. Only the methods ANCHOR_DID and REVOKE_CREDENTIAL of
+#   DID_REGISTRY are allowed: a DID document is rotated only by a key of the document anchored, and a credential
+#   is revoked only by an admin of its org. The credentials are valid or not by the time of the last block.
 auth_type: "permissionedWithCert" # [*]
 
 # Logger settings
@@ -71,9 +77,12 @@ node:
 
 # Network Settings
 net:
-  # Network provider, can be libp2p or liquid.
+  # Network provider, can be libp2p, liquid or memory.
   # libp2p: using libp2p components to build the p2p module.
   # liquid: a new p2p network module. We build it from 0 to 1.
+  # memory: an in-process network for running several nodes in one process, such as the end-to-end tests.
+  #         The listen_addr is like /memory/<network name>, the nodes on the same network connect to each other.
+  #         The node config is global to the process, so only the nodes assembled by the tests share a network.
   # This item must be consistent across the blockchain network.
   provider: LibP2P
 
@@ -225,6 +234,183 @@ scheduler:
   # whether log the txRWSet map in debug mode
   rwset_log: false
 
+# Sync related settings
+# sync:
+  # Whether to request the blocks compressed, a peer not answering the first compressed request
+  # (the versions before it drop the request) is requested uncompressed since then
+  # compress_blocks: false
+  # Max bytes of the blocks served in one msg
+  # max_batch_bytes: 4194304
+  # A peer serving invalid or no blocks is ignored when its penalty reaches it
+  # max_peer_penalty: 5
+  # Seconds to ignore a peer evicted by its penalty
+  # peer_ban_time: 60
+
+# Score the peers of all the chains by their misbehaviors, the peers banned are dropped for a while.
+# The consensus nodes are scored but never banned, their misbehaviors are handled by the consensus.
+# peer_score:
+  # Disabled by default. Once enabled, the signatures of the txs received from the peers are verified
+  # before the tx pool, so that the peers sending the forged txs are reported
+  # enabled: false
+  # Ban a peer when its score drops to -ban_threshold, 0 disables the bans
+  # ban_threshold: 100
+  # ban_seconds: 1800
+  # The time the score of a peer takes to recover by half
+  # half_life_seconds: 600
+  # The penalty of each misbehavior, the ones not configured use the default penalties
+  # penalties:
+    # invalid_block: 50
+    # invalid_signature: 50
+    # invalid_msg: 20
+    # invalid_tx: 5
+    # timeout: 5
+
+# The shutdown mode of the node on SIGTERM/SIGINT, stops at once if not configured
+# shutdown:
+  # Report not ready on the /ready of the monitor, wait readiness_delay_seconds for the load balancers,
+  # then stop the rpc service gracefully and hand off the raft leadership or skip the tbft proposer turns
+  # graceful: true
+  # readiness_delay_seconds: 5
+  # Timeout of draining the rpc requests and of handing off the consensus duties each
+  # drain_timeout_seconds: 30
+
+# The config file is reloaded on SIGHUP, the log levels, debug, rpc rate limits and blacklist, monitor port
+# and sync tickers are applied at runtime, also to the chains joined or resumed later. The other changes are
+# rejected and logged, they require a restart. Not applied at runtime are the tx pool sizes and the storage
+# cache sizes, which are read once by the tx pool and the store, and the net blacklist, which the net providers
+# take before they start.
+# config_reload:
+  # Reload the config file when it changes, checked every watch_interval_seconds, 0 means on SIGHUP only
+  # watch_interval_seconds: 10
+
+# Relay the cross-chain messages between the chains of the node. A contract sends a message by the event of
+# topic CROSS_CHAIN_MESSAGE with data [dst_chain_id, dst_contract, dst_method, payload, callback_method], the
+# callback is optional. The message is delivered by a CROSS_CHAIN DELIVER tx with the proof of the source block,
+# and acknowledged back by an ACK tx with the proof of the destination block, queried by GET_RECEIPT.
+# The proofs carry the quorum certs of TBFT, the blocks of the other consensus types could not be proved.
+# A chain accepts the messages of another chain trusted in its consensus.ext_config by the keys:
+#   cross_chain.{chain_id}.consensus_orgs: the orgs running the consensus of the chain, separated by commas
+#   cross_chain.{chain_id}.consensus_node.{node_id}: the PEM of the sign cert of a consensus node of the chain,
+#     a block is trusted if more than 2/3 of the nodes set precommit it
+#   cross_chain.{chain_id}.trust_root.{org_id}: the PEM of the CA certs of the org
+#   cross_chain.{chain_id}.hash_type: the hash algorithm of the chain, SHA256 by default
+# The txs are sent by the consensus nodes of the chain, or the relayers of its consensus orgs set by the key:
+#   cross_chain.relayers: the member ids of the relayers, separated by commas
+# cross_chain:
+  # relay: true
+  # Interval of scanning the new blocks and submitting the txs
+  # scan_interval_seconds: 1
+  # Blocks rescanned at startup to relay the messages missed while stopped
+  # rescan_blocks: 100
+  # Interval of resubmitting the txs not committed
+  # retry_interval_seconds: 30
+  # Cert and key signing the txs, a consensus node or a relayer of the chains, the node's by default
+  # sign_cert_file: ../config/{org_path}/certs/user/relayer1/relayer1.sign.crt
+  # sign_key_file: ../config/{org_path}/certs/user/relayer1/relayer1.sign.key
+
+# Check the certificate status against the OCSP responders and the CRL distribution points
+# embedded in the certificates, besides the CRLs submitted on chain, disabled if not set.
+# The senders and endorsers of the txs are checked when the rpc server admits the txs, the blocks are
+# verified against the CRLs on chain only, so that all the nodes verify them the same.
+# cert_status:
+  # enabled: true
+  # Reject the certificates whose status is unknown, e.g. the responders are unreachable
+  # hard_fail: false
+  # Seconds to cache a status, capped by the next update of the CRL or OCSP response
+  # cache_ttl: 300
+  # Seconds to wait for a responder
+  # timeout: 5
+  # Read the CRLs and OCSP responses from the directory instead of the responders, for tests only
+  # responder_dir: ""
+
+# Track the expiry of the trust roots, trust members and the node certificates, enabled by default
+# cert_expiry:
+  # enabled: true
+  # Days before the expiry to log a warning
+  # warn_days: [30, 7, 1]
+  # Seconds between two scans
+  # check_interval: 3600
+  # Other certificates to track, such as the admin and user certificates
+  # cert_files:
+  #   - ../config/{org_path}/certs/user/admin1/admin1.sign.crt
+
+# Where the signing key of the node is kept, which signs the blocks, the consensus messages and
+# the transactions of the node. By default it is pkcs11 if node.pkcs11 is enabled, or file.
+# With pkcs11 or kms, node.priv_key_file holds the id of the key instead of the PEM key.
+# The net tls key is always read from the PEM file of net.tls.priv_key_file, as the net providers could not
+# sign the TLS handshakes by a key in the HSM or KMS.
+# With file, node.priv_key_file, net.tls.priv_key_file and rpc.tls.priv_key_file could be
+# encrypted by `cmc key encrypt`. The passphrase unlocking them at startup is read from the env
+# CHAINMAKER_KEY_PASSPHRASE, the file in the env CHAINMAKER_KEY_PASSPHRASE_FILE, the passphrase_file
+# below, or prompted by `chainmaker start` in the terminal.
+# For local tests with SoftHSM, set node.pkcs11.library to /usr/lib/softhsm/libsofthsm2.so
+# and node.pkcs11.label to the token initialized by `softhsm2-util --init-token`, the key is generated
+# by `pkcs11-tool --keypairgen --label <key id>`, see TestParsePrivateKey_SoftHSM in accesscontrol.
+# key_source:
+  # Key source provider, can be file, pkcs11 or kms
+  # provider: kms
+  # File holding the passphrase of the encrypted key files, which should be readable by the node only
+  # passphrase_file: /run/secrets/chainmaker_key_passphrase
+  # The KMS serving GET {endpoint}/v1/keys/{key_id}/public_key and POST {endpoint}/v1/keys/{key_id}/sign
+  # kms:
+    # endpoint: https://127.0.0.1:8443
+    # Sent as the bearer token
+    # access_token: ""
+    # CA certificate of the KMS, the system roots are used if not set
+    # ca_cert_file: ""
+    # Seconds to wait for the KMS
+    # timeout: 10
+
+# Resource budgets of the chains sharing the node, so that a busy chain does not degrade the others.
+# The default entry applies to the chains not listed, 0 or absent means unlimited.
+# The consumption is exported in the chainmaker_chain_resource_* metrics labeled by chainId.
+# chain_resources:
+  # default:
+    # Max goroutines executing the txs of a block
+    # vm_concurrency: 8
+    # Max MB of the txs kept in the tx pool, submitted to the node or received from the peers,
+    # the txs beyond are rejected or dropped
+    # txpool_memory_mb: 512
+    # Max subscription streams
+    # max_subscriptions: 100
+    # Max KB per second of the blocks served to the syncing peers,
+    # the msgs served are queued and dropped once the queue is full, the peers request them again
+    # sync_bandwidth_kb: 10240
+    # Max requests per second
+    # rpc_qps: 5000
+  # chain1:
+    # vm_concurrency: 16
+
+# QoS of the p2p msgs sent by each chain, the "default" entry applies to the chains not listed.
+# The msgs are grouped in the classes consensus, tx and sync. The msgs of a higher class are sent first,
+# and consensus msgs do not wait for the bandwidth used by the sync msgs during a catch-up.
+# The p2p net prioritizes the msgs of all the chains alike, by the priorities of the "default" entry.
+# net_qos:
+  # default:
+    # Max KB per second of the msgs sent by the chain, 0 means unlimited
+    # bandwidth_kb: 10240
+    # Max msgs of the chain being sent, the msgs waiting are sent by the priority of their classes
+    # max_inflight: 64
+    # classes:
+      # consensus:
+        # Priority 0~9
+        # priority: 9
+        # Max percent of bandwidth_kb the class could use
+        # bandwidth_share: 100
+        # Max msgs waiting for sending, the msgs beyond are dropped
+        # queue_size: 1024
+      # tx:
+        # priority: 7
+        # bandwidth_share: 50
+        # queue_size: 4096
+      # sync:
+        # priority: 5
+        # bandwidth_share: 30
+        # queue_size: 256
+    # Move a net msg type to another class
+    # msg_types:
+      # TXS: sync
+
 # Storage config settings
 # Contains blockDb, stateDb, historyDb, resultDb, contractEventDb
 #
@@ -317,6 +503,23 @@ storage:
       # Mysql connection info, such as:  root:admin@tcp(127.0.0.1:3306)/
       dsn: root:password@tcp(127.0.0.1:3306)/
 
+  # Archive source to sync the blocks archived by all peers, disabled if not set
+  # archive_source:
+    # Archive source type, can be mysql, file or s3
+    # type: mysql
+    # The database written by `cmc archive dump`, such as: user:password:localhost:port
+    # dest: root:password:127.0.0.1:3306
+    # The directory of the file archive, blocks are read from {path}/{prefix}/blocks/{height}
+    # path: ../data/{org_id}/archive
+    # The s3 compatible bucket, blocks are read from {endpoint}/{bucket}/{prefix}/blocks/{height}
+    # endpoint: http://127.0.0.1:9000
+    # bucket: chainmaker-archive
+    # region: us-east-1
+    # access_key: ""
+    # secret_key: ""
+    # Prefix of the archive in the directory or bucket, default is the chain id
+    # prefix: chain1
+
 # Docker go virtual machine configuration
 vm:
   # Enable docker go virtual machine
@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package cmd
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"net/http"
@@ -51,6 +52,12 @@ func mainStart() {
 		traceMemoryUsage()
 	}
 
+	// unlock the encrypted key files before loading them
+	if err := unlockKeyStore(); err != nil {
+		log.Errorf("unlock key store failed, %s", err.Error())
+		return
+	}
+
 	// init chainmaker server
 	chainMakerServer := blockchain.NewChainMakerServer()
 	if err := chainMakerServer.Init(); err != nil {
@@ -65,8 +72,15 @@ func mainStart() {
 		return
 	}
 
+	shutdownConfig, err := blockchain.LoadShutdownConfig()
+	if err != nil {
+		log.Errorf("load shutdown config failed, %s", err.Error())
+		return
+	}
+
 	// init monitor server
 	monitorServer := monitor.NewMonitorServer()
+	monitorServer.SetReadiness(chainMakerServer.IsReady)
 
 	//// p2p callback to validate
 	//txpool.RegisterCallback(rpcServer.Gateway().Invoke)
@@ -97,6 +111,12 @@ func mainStart() {
 		startPProf()
 	}
 
+	// reload the safe settings of the config file at runtime
+	stopReloadC := make(chan struct{})
+	if err := startConfigReloader(stopReloadC, chainMakerServer, rpcServer, monitorServer); err != nil {
+		errorC <- err
+	}
+
 	printLogo()
 
 	// listen error signal in main function
@@ -104,12 +124,41 @@ func mainStart() {
 	if errC != nil {
 		log.Error("chainmaker encounters error ", errC)
 	}
-	rpcServer.Stop()
+	close(stopReloadC)
+	if shutdownConfig.Graceful {
+		drain(chainMakerServer, rpcServer, shutdownConfig, errorC)
+	} else {
+		rpcServer.Stop()
+	}
 	chainMakerServer.Stop()
 	log.Info("All is stopped!")
 
 }
 
+// drain reports the node as not ready and waits for the load balancers to notice, then stops the rpc server
+// gracefully and hands off the consensus duties, each within the drain timeout. The readiness delay is skipped
+// if another exit signal is received.
+func drain(chainMakerServer *blockchain.ChainMakerServer, rpcServer *rpcserver.RPCServer,
+	config *blockchain.ShutdownConfig, exitC <-chan error) {
+	log.Infof("chainmaker is draining, readiness delay: %ds, drain timeout: %ds",
+		config.ReadinessDelaySeconds, config.DrainTimeoutSeconds)
+	chainMakerServer.StartDraining()
+	select {
+	case <-time.After(time.Duration(config.ReadinessDelaySeconds) * time.Second):
+	case <-exitC:
+		log.Info("received exit signal again, skip the readiness delay")
+	}
+
+	drainTimeout := time.Duration(config.DrainTimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	rpcServer.StopGracefully(ctx)
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), drainTimeout)
+	chainMakerServer.Drain(ctx)
+	cancel()
+}
+
 func handleExitSignal(exitC chan<- error) {
 
 	signalChan := make(chan os.Signal, 1)
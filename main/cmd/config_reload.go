@@ -0,0 +1,71 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"chainmaker.org/chainmaker-go/blockchain"
+	"chainmaker.org/chainmaker/localconf/v2"
+	"github.com/spf13/viper"
+)
+
+// startConfigReloader reloads the config file on SIGHUP, and when it changes if config_reload is set,
+// until the stopC is closed. The chains joined or resumed later apply the config reloaded as well.
+func startConfigReloader(stopC <-chan struct{}, chainMakerServer *blockchain.ChainMakerServer,
+	watchers ...blockchain.LocalConfigWatcher) error {
+	reloadConfig, err := blockchain.LoadConfigReloadConfig()
+	if err != nil {
+		return err
+	}
+	reloader, err := blockchain.NewLocalConfigReloader(loadLocalConfig)
+	if err != nil {
+		return err
+	}
+	reloader.RegisterWatcher(append(watchers, chainMakerServer)...)
+	chainMakerServer.SetLocalConfigReloader(reloader)
+
+	go handleReloadSignal(reloader, stopC)
+	if reloadConfig.WatchIntervalSeconds > 0 {
+		go reloader.Watch(localconf.ConfigFilepath, time.Duration(reloadConfig.WatchIntervalSeconds)*time.Second,
+			stopC)
+	}
+	return nil
+}
+
+// loadLocalConfig reads the config file into a new config, the running one is not changed
+func loadLocalConfig() (*localconf.CMConfig, error) {
+	cmViper := viper.New()
+	cmViper.SetConfigFile(localconf.ConfigFilepath)
+	if err := cmViper.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	config := &localconf.CMConfig{}
+	if err := cmViper.Unmarshal(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func handleReloadSignal(reloader *blockchain.LocalConfigReloader, stopC <-chan struct{}) {
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGHUP)
+	defer signal.Stop(signalChan)
+
+	for {
+		select {
+		case sig := <-signalChan:
+			log.Infof("received signal: %d (%s), reload the config file", sig, sig)
+			reloader.ReloadAndReport()
+		case <-stopC:
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"chainmaker.org/chainmaker-go/accesscontrol"
+	"chainmaker.org/chainmaker/localconf/v2"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// unlockKeyStore unlocks the encrypted key files of the node, i.e. the signing key, the net tls key and the rpc
+// tls key, by the passphrase in the env variable or the passphrase file, or prompted in the terminal
+func unlockKeyStore() error {
+	var encryptedFiles []string
+	for _, keyFile := range []string{
+		localconf.ChainMakerConfig.NodeConfig.PrivKeyFile,
+		localconf.ChainMakerConfig.NetConfig.TLSConfig.PrivKeyFile,
+		localconf.ChainMakerConfig.RpcConfig.TLSConfig.PrivKeyFile,
+	} {
+		if keyFile == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			// the missing key files are reported by the modules loading them
+			continue
+		}
+		if accesscontrol.IsKeyStore(content) {
+			encryptedFiles = append(encryptedFiles, keyFile)
+		}
+	}
+	if len(encryptedFiles) == 0 {
+		return nil
+	}
+
+	passphrase, ok, err := accesscontrol.LoadKeyStorePassphrase()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if passphrase, err = promptKeyStorePassphrase(); err != nil {
+			return err
+		}
+	}
+
+	// check the passphrase before starting any module
+	for _, keyFile := range encryptedFiles {
+		content, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return err
+		}
+		if _, err = accesscontrol.DecryptKeyStore(content, passphrase); err != nil {
+			return fmt.Errorf("unlock key file %s failed, %v", keyFile, err)
+		}
+	}
+	accesscontrol.UnlockKeyStore(passphrase)
+	log.Infof("unlock the encrypted key files %v success", encryptedFiles)
+	return nil
+}
+
+func promptKeyStorePassphrase() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", fmt.Errorf("the key files are encrypted, set the passphrase by the env %s or %s, "+
+			"or the passphrase_file of storage.key_source", accesscontrol.KeyStorePassphraseEnv,
+			accesscontrol.KeyStorePassphraseFileEnv)
+	}
+	fmt.Print("Enter the passphrase of the key files: ")
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("empty passphrase")
+	}
+	return string(passphrase), nil
+}
@@ -44,7 +44,7 @@ func (af *AcFactory) NewACProvider(chainConf protocol.ChainConf, localOrgId stri
 			return nil,
 				fmt.Errorf("new ac provider failed, the consensus type does not match the authentication type")
 		}
-	case protocol.PermissionedWithKey:
+	case protocol.PermissionedWithKey, AuthTypeDID:
 		if chainConf.ChainConfig().Consensus.Type == consensus.ConsensusType_DPOS {
 			return nil,
 				fmt.Errorf("new ac provider failed, the consensus type does not match the authentication type")
@@ -18,6 +18,7 @@ func init() {
 	RegisterACProvider(protocol.Identity, NilCertACProvider)
 	RegisterACProvider(protocol.PermissionedWithKey, NilPermissionedPkACProvider)
 	RegisterACProvider(protocol.Public, NilPkACProvider)
+	RegisterACProvider(AuthTypeDID, NilDIDACProvider)
 }
 
 var acProviderRegistry = map[string]reflect.Type{}
@@ -200,6 +200,12 @@ type accessControlService struct {
 
 	exceptionalPolicyMap *sync.Map // map[string]*policy , resourceName -> *policy
 
+	// number of the policies configured for the user contracts and their methods
+	contractPolicyNum int32
+
+	// the admin powers delegated and the signers endorsing by them, *delegations
+	delegations atomic.Value
+
 	//local cache for member
 	memberCache *concurrentlru.Cache
 
@@ -211,6 +217,9 @@ type accessControlService struct {
 	hashType string
 
 	authType string
+
+	// whether the chain verifies the timestamps of the txs, the time conditions require it, accessed atomically
+	txTimestampVerify int32
 }
 
 type memberCached struct {
@@ -532,20 +541,38 @@ func (acs *accessControlService) createDefaultResourcePolicyForPK(localOrgId str
 		syscontract.PubkeyManageFunction_PUBKEY_DELETE.String(), policySelfConfig)
 }
 
+// setTxTimestampVerify records whether the chain verifies the timestamps of the txs
+func (acs *accessControlService) setTxTimestampVerify(enabled bool) {
+	var verify int32
+	if enabled {
+		verify = 1
+	}
+	atomic.StoreInt32(&acs.txTimestampVerify, verify)
+}
+
 func (acs *accessControlService) initResourcePolicy(resourcePolicies []*config.ResourcePolicy,
 	localOrgId string) {
 	switch acs.authType {
 	case protocol.PermissionedWithCert, protocol.Identity:
 		acs.createDefaultResourcePolicy(localOrgId)
-	case protocol.PermissionedWithKey:
+	case protocol.PermissionedWithKey, AuthTypeDID:
 		acs.createDefaultResourcePolicyForPK(localOrgId)
 	}
+	var contractPolicyNum int32
 	for _, resourcePolicy := range resourcePolicies {
+		if isDelegationResource(resourcePolicy.ResourceName) {
+			continue
+		}
 		if acs.validateResourcePolicy(resourcePolicy) {
 			policy := newPolicyFromPb(resourcePolicy.Policy)
 			acs.resourceNamePolicyMap.Store(resourcePolicy.ResourceName, policy)
+			if isContractResource(resourcePolicy.ResourceName) {
+				contractPolicyNum++
+			}
 		}
 	}
+	atomic.StoreInt32(&acs.contractPolicyNum, contractPolicyNum)
+	acs.initDelegations(resourcePolicies)
 }
 
 func (acs *accessControlService) checkResourcePolicyOrgList(policy *pbac.Policy) bool {
@@ -703,6 +730,31 @@ func (acs *accessControlService) validateResourcePolicy(resourcePolicy *config.R
 		return false
 	}
 
+	if isDelegationResource(resourcePolicy.ResourceName) {
+		return acs.validateDelegationPolicy(resourcePolicy)
+	}
+
+	// the rule is checked without the conditions of the policy expression
+	rule, conditions, err := parsePolicyRule(resourcePolicy.Policy.Rule)
+	if err != nil {
+		acs.log.Errorf("bad configuration: %v", err)
+		return false
+	}
+	if len(conditions) > 0 {
+		if rule == protocol.RuleDelete {
+			acs.log.Errorf("bad configuration: conditions of [%s] should not be with rule [%s]",
+				resourcePolicy.ResourceName, rule)
+			return false
+		}
+		resourcePolicy = &config.ResourcePolicy{
+			ResourceName: resourcePolicy.ResourceName,
+			Policy: &pbac.Policy{
+				Rule:     string(rule),
+				OrgList:  resourcePolicy.Policy.OrgList,
+				RoleList: resourcePolicy.Policy.RoleList,
+			},
+		}
+	}
 	return acs.checkResourcePolicyRule(resourcePolicy)
 }
 
@@ -812,6 +864,17 @@ func (acs *accessControlService) getMemberFromCache(member *pbac.Member) protoco
 
 func (acs *accessControlService) verifyPrincipalPolicy(principal, refinedPrincipal protocol.Principal, p *policy) (
 	bool, error) {
+	if ok, err := acs.verifyPrincipalPolicyWithConditions(principal, refinedPrincipal, p); !ok {
+		return false, err
+	}
+	if ok, err := acs.verifyDelegationGrants(principal, refinedPrincipal); !ok {
+		return false, err
+	}
+	return acs.verifyContractPolicy(principal, refinedPrincipal)
+}
+
+func (acs *accessControlService) verifyPrincipalPolicyRule(principal, refinedPrincipal protocol.Principal,
+	p *policy) (bool, error) {
 	endorsements := refinedPrincipal.GetEndorsement()
 	rule := p.GetRule()
 
@@ -864,7 +927,7 @@ func (acs *accessControlService) verifyPrincipalPolicyRuleSelfCase(targetOrg str
 			continue
 		}
 
-		member := acs.getMemberFromCache(entry.Signer)
+		member := acs.getSignerMember(entry.Signer)
 		if member == nil {
 			acs.log.Debugf(
 				"authentication warning: the member is not in member cache, memberInfo[%s]",
@@ -895,7 +958,7 @@ func (acs *accessControlService) verifyPrincipalPolicyRuleAnyCase(p *policy, end
 			return true, nil
 		}
 
-		member := acs.getMemberFromCache(endorsement.Signer)
+		member := acs.getSignerMember(endorsement.Signer)
 		if member == nil {
 			acs.log.Debugf(
 				"authentication warning: the member is not in member cache, memberInfo[%s]",
@@ -1001,7 +1064,7 @@ func (acs *accessControlService) getValidEndorsements(orgList map[string]bool, r
 			continue
 		}
 
-		member := acs.getMemberFromCache(endorsement.Signer)
+		member := acs.getSignerMember(endorsement.Signer)
 		if member == nil {
 			acs.log.Debugf(
 				"authentication warning: the member is not in member cache, memberInfo[%s]",
@@ -18,6 +18,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"chainmaker.org/chainmaker/common/v2/concurrentlru"
 	bcx509 "chainmaker.org/chainmaker/common/v2/crypto/x509"
@@ -50,6 +51,12 @@ type certACProvider struct {
 
 	//third-party trusted members
 	trustMembers *sync.Map
+
+	// online certificate status checking, nil if it is not enabled
+	statusChecker *certStatusChecker
+
+	// expiry tracking of the trusted and local certificates, nil if it is disabled
+	expiryTracker *certExpiryTracker
 }
 
 type trustMemberCached struct {
@@ -94,12 +101,18 @@ func newCertACProvider(chainConfig *config.ChainConfig, localOrgId string,
 	certACProvider.acService = initAccessControlService(chainConfig.GetCrypto().Hash,
 		chainConfig.AuthType, store, log)
 
+	certACProvider.statusChecker, err = newCertStatusChecker(log)
+	if err != nil {
+		return nil, err
+	}
+
 	err = certACProvider.initTrustRoots(chainConfig.TrustRoots, localOrgId)
 	if err != nil {
 		return nil, err
 	}
 
 	certACProvider.acService.initResourcePolicy(chainConfig.ResourcePolicies, localOrgId)
+	certACProvider.acService.setTxTimestampVerify(chainConfig.GetBlock().GetTxTimestampVerify())
 
 	certACProvider.opts.KeyUsages = make([]x509.ExtKeyUsage, 1)
 	certACProvider.opts.KeyUsages[0] = x509.ExtKeyUsageAny
@@ -111,6 +124,12 @@ func newCertACProvider(chainConfig *config.ChainConfig, localOrgId string,
 	if err := certACProvider.loadCertFrozenList(); err != nil {
 		return nil, err
 	}
+
+	certACProvider.expiryTracker, err = newCertExpiryTracker(chainConfig.ChainId, certACProvider, log)
+	if err != nil {
+		return nil, err
+	}
+	certACProvider.expiryTracker.start()
 	return certACProvider, nil
 }
 
@@ -606,6 +625,34 @@ func (cp *certACProvider) GetMemberStatus(pbMember *pbac.Member) (pbac.MemberSta
 	return pbac.MemberStatus_NORMAL, nil
 }
 
+// Stop stops tracking the expiry of the certificates, the provider could still verify the members
+func (cp *certACProvider) Stop() {
+	cp.expiryTracker.stop()
+}
+
+// CheckOnlineStatus checks the certificate chain of the member against the responders embedded in the certificates
+func (cp *certACProvider) CheckOnlineStatus(pbMember *pbac.Member) error {
+	if cp.statusChecker == nil {
+		return nil
+	}
+	member, err := cp.NewMember(pbMember)
+	if err != nil {
+		return err
+	}
+	certMember, ok := member.(*certificateMember)
+	if !ok {
+		return fmt.Errorf("invalid member: member type err")
+	}
+	certChains, err := certMember.cert.Verify(cp.opts)
+	if err != nil {
+		return fmt.Errorf("not ac valid certificate from trusted CAs: %v", err)
+	}
+	if len(certChains) == 0 {
+		return fmt.Errorf("no certificate chain of the member")
+	}
+	return cp.statusChecker.check(certChains[0])
+}
+
 func (cp *certACProvider) VerifyRelatedMaterial(verifyType pbac.VerifyType, data []byte) (bool, error) {
 
 	if verifyType != pbac.VerifyType_CRL {
@@ -927,6 +974,7 @@ func (cp *certACProvider) Watch(chainConfig *config.ChainConfig) error {
 	}
 
 	cp.acService.initResourcePolicy(chainConfig.ResourcePolicies, cp.localOrg.id)
+	cp.acService.setTxTimestampVerify(chainConfig.GetBlock().GetTxTimestampVerify())
 
 	cp.opts.KeyUsages = make([]x509.ExtKeyUsage, 1)
 	cp.opts.KeyUsages[0] = x509.ExtKeyUsageAny
@@ -937,6 +985,8 @@ func (cp *certACProvider) Watch(chainConfig *config.ChainConfig) error {
 	if err != nil {
 		return err
 	}
+	// track the trust roots and members updated at once
+	cp.expiryTracker.scan(time.Now())
 	return nil
 }
 
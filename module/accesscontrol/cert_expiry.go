@@ -0,0 +1,328 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bcx509 "chainmaker.org/chainmaker/common/v2/crypto/x509"
+	"chainmaker.org/chainmaker/localconf/v2"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// certExpiryConfigKey is the top-level section of CertExpiryConfig in chainmaker.yml
+	certExpiryConfigKey = "cert_expiry"
+
+	defaultCertExpiryCheckInterval = 3600
+)
+
+// The kinds of the certificates tracked
+const (
+	certKindTrustRoot         = "trust_root"
+	certKindTrustIntermediate = "trust_intermediate"
+	certKindTrustMember       = "trust_member"
+	certKindNodeSign          = "node_sign"
+	certKindNodeNetTLS        = "node_net_tls"
+	certKindNodeRpcTLS        = "node_rpc_tls"
+	certKindFile              = "file"
+)
+
+var defaultCertExpiryWarnDays = []int{30, 7, 1}
+
+var (
+	certExpiryMetricsOnce sync.Once
+
+	metricCertDaysToExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "chainmaker", Subsystem: "accesscontrol", Name: "cert_days_to_expiry",
+		Help: "The days before the certificate expires, negative if it has expired",
+	}, []string{"chainId", "kind", "org", "subject", "serial"})
+)
+
+// Stopper is implemented by the access control providers running background tasks,
+// they are stopped when the chain stops
+type Stopper interface {
+	Stop()
+}
+
+// CertExpiryConfig configures the tracking of the expiry of the certificates, it is enabled by default
+type CertExpiryConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// WarnDays are the days before the expiry to log a warning, 30, 7 and 1 by default
+	WarnDays []int `mapstructure:"warn_days"`
+	// CheckInterval is the seconds between two scans
+	CheckInterval int `mapstructure:"check_interval"`
+	// CertFiles are the other certificates to track, e.g. the admin and user certificates of the node
+	CertFiles []string `mapstructure:"cert_files"`
+}
+
+// certExpiry is the expiry of a certificate tracked
+type certExpiry struct {
+	kind     string
+	org      string
+	cert     *bcx509.Certificate
+	daysLeft float64
+}
+
+func (e *certExpiry) key() string {
+	return e.kind + "/" + e.org + "/" + e.cert.SerialNumber.Text(16)
+}
+
+func (e *certExpiry) labels(chainId string) []string {
+	return []string{chainId, e.kind, e.org, e.cert.Subject.CommonName, e.cert.SerialNumber.Text(16)}
+}
+
+// certExpiryTracker scans the certificates of the provider and the node, all methods are no-op on nil
+type certExpiryTracker struct {
+	chainId   string
+	provider  *certACProvider
+	warnDays  []int
+	interval  time.Duration
+	certFiles map[string]string
+	metrics   bool
+	log       protocol.Logger
+
+	stopC    chan struct{}
+	stopOnce sync.Once
+
+	lock    sync.Mutex
+	stopped bool
+	// the least warn days logged of the certificates
+	warned map[string]int
+	// the labels of the gauges reported, to delete the ones of the certificates removed
+	reported map[string][]string
+}
+
+// newCertExpiryTracker creates the tracker by the local config, returns nil if it is disabled
+func newCertExpiryTracker(chainId string, provider *certACProvider, log protocol.Logger) (*certExpiryTracker, error) {
+	conf := &CertExpiryConfig{Enabled: true}
+	if _, err := LoadNodeConfigSection(certExpiryConfigKey, conf); err != nil {
+		return nil, err
+	}
+	return newCertExpiryTrackerWithConfig(chainId, provider, conf, log), nil
+}
+
+func newCertExpiryTrackerWithConfig(chainId string, provider *certACProvider, conf *CertExpiryConfig,
+	log protocol.Logger) *certExpiryTracker {
+	if !conf.Enabled {
+		return nil
+	}
+	tracker := &certExpiryTracker{
+		chainId:  chainId,
+		provider: provider,
+		warnDays: append([]int{}, conf.WarnDays...),
+		interval: time.Duration(conf.CheckInterval) * time.Second,
+		certFiles: map[string]string{
+			localconf.ChainMakerConfig.NodeConfig.CertFile:          certKindNodeSign,
+			localconf.ChainMakerConfig.NetConfig.TLSConfig.CertFile: certKindNodeNetTLS,
+			localconf.ChainMakerConfig.RpcConfig.TLSConfig.CertFile: certKindNodeRpcTLS,
+		},
+		metrics:  localconf.ChainMakerConfig.MonitorConfig.Enabled,
+		log:      log,
+		stopC:    make(chan struct{}),
+		warned:   make(map[string]int),
+		reported: make(map[string][]string),
+	}
+	if len(tracker.warnDays) == 0 {
+		tracker.warnDays = append(tracker.warnDays, defaultCertExpiryWarnDays...)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(tracker.warnDays)))
+	if tracker.interval <= 0 {
+		tracker.interval = defaultCertExpiryCheckInterval * time.Second
+	}
+	for _, file := range conf.CertFiles {
+		tracker.certFiles[file] = certKindFile
+	}
+	delete(tracker.certFiles, "")
+	if tracker.metrics {
+		certExpiryMetricsOnce.Do(func() {
+			prometheus.MustRegister(metricCertDaysToExpiry)
+		})
+	}
+	return tracker
+}
+
+// start scans the certificates periodically
+func (t *certExpiryTracker) start() {
+	if t == nil {
+		return
+	}
+	go func() {
+		t.scan(time.Now())
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				t.scan(now)
+			case <-t.stopC:
+				return
+			}
+		}
+	}()
+}
+
+// stop ends the scans and deletes the gauges reported
+func (t *certExpiryTracker) stop() {
+	if t == nil {
+		return
+	}
+	t.stopOnce.Do(func() {
+		close(t.stopC)
+	})
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.stopped = true
+	for key, labels := range t.reported {
+		metricCertDaysToExpiry.DeleteLabelValues(labels...)
+		delete(t.reported, key)
+	}
+}
+
+// scan checks the expiry of the certificates, reports the metrics and logs the warnings
+func (t *certExpiryTracker) scan(now time.Time) []*certExpiry {
+	if t == nil {
+		return nil
+	}
+	expiries := t.collect(now)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.stopped {
+		return expiries
+	}
+	seen := make(map[string]bool, len(expiries))
+	for _, expiry := range expiries {
+		key := expiry.key()
+		seen[key] = true
+		if t.metrics {
+			labels := expiry.labels(t.chainId)
+			metricCertDaysToExpiry.WithLabelValues(labels...).Set(expiry.daysLeft)
+			t.reported[key] = labels
+		}
+		t.warn(key, expiry)
+	}
+	for key, labels := range t.reported {
+		if !seen[key] {
+			metricCertDaysToExpiry.DeleteLabelValues(labels...)
+			delete(t.reported, key)
+		}
+	}
+	for key := range t.warned {
+		if !seen[key] {
+			delete(t.warned, key)
+		}
+	}
+	return expiries
+}
+
+// warn logs an error for an expired certificate every scan, and a warning once when it crosses a warn days
+func (t *certExpiryTracker) warn(key string, expiry *certExpiry) {
+	if expiry.daysLeft <= 0 {
+		t.log.Errorf("[chain %s] %s certificate [org: %s, subject: %s, serial: %s] has expired at %s",
+			t.chainId, expiry.kind, expiry.org, expiry.cert.Subject.CommonName,
+			expiry.cert.SerialNumber.Text(16), expiry.cert.NotAfter)
+		return
+	}
+	threshold := -1
+	for _, days := range t.warnDays {
+		if expiry.daysLeft <= float64(days) {
+			threshold = days
+		}
+	}
+	if threshold < 0 {
+		delete(t.warned, key)
+		return
+	}
+	if warned, ok := t.warned[key]; ok && warned <= threshold {
+		return
+	}
+	t.warned[key] = threshold
+	t.log.Warnf("[chain %s] %s certificate [org: %s, subject: %s, serial: %s] expires in %.1f days at %s, "+
+		"please renew it", t.chainId, expiry.kind, expiry.org, expiry.cert.Subject.CommonName,
+		expiry.cert.SerialNumber.Text(16), expiry.daysLeft, expiry.cert.NotAfter)
+}
+
+func (t *certExpiryTracker) collect(now time.Time) []*certExpiry {
+	var expiries []*certExpiry
+	add := func(kind, org string, cert *bcx509.Certificate) {
+		expiries = append(expiries, &certExpiry{
+			kind:     kind,
+			org:      org,
+			cert:     cert,
+			daysLeft: cert.NotAfter.Sub(now).Hours() / 24,
+		})
+	}
+
+	if t.provider != nil {
+		for _, info := range t.provider.acService.getAllOrgInfos() {
+			org := info.(*organization)
+			for _, cert := range org.trustedRootCerts {
+				add(certKindTrustRoot, org.id, cert)
+			}
+			for _, cert := range org.trustedIntermediateCerts {
+				add(certKindTrustIntermediate, org.id, cert)
+			}
+		}
+		t.provider.trustMembers.Range(func(_, value interface{}) bool {
+			member := value.(*trustMemberCached)
+			add(certKindTrustMember, member.trustMember.OrgId, member.cert)
+			return true
+		})
+	}
+
+	for file, kind := range t.certFiles {
+		certs, err := loadCertsFromFile(file)
+		if err != nil {
+			t.log.Warnf("track the expiry of certificate file %s failed, %v", file, err)
+			continue
+		}
+		for _, cert := range certs {
+			var org string
+			if len(cert.Subject.Organization) > 0 {
+				org = cert.Subject.Organization[0]
+			}
+			add(kind, org, cert)
+		}
+	}
+
+	sort.Slice(expiries, func(i, j int) bool {
+		return expiries[i].key() < expiries[j].key()
+	})
+	return expiries
+}
+
+func loadCertsFromFile(file string) ([]*bcx509.Certificate, error) {
+	raw, err := ioutil.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	var certs []*bcx509.Certificate
+	for block, rest := pem.Decode(raw); block != nil; block, rest = pem.Decode(rest) {
+		if !strings.Contains(block.Type, "CERTIFICATE") {
+			continue
+		}
+		cert, err := bcx509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return certs, nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logger2 "chainmaker.org/chainmaker/logger/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertExpiryTracker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cert_expiry")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	log := logger2.GetLogger(logger2.MODULE_ACCESS)
+
+	ca := newTestStatusCA(t)
+	userCert := ca.issue(t, 20, nil, nil)
+	userCertFile := filepath.Join(dir, "user.crt")
+	require.Nil(t, ioutil.WriteFile(userCertFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: userCert.Raw}), 0600))
+
+	provider, err := newCertACProvider(testChainConfig, testOrg1, nil, log)
+	require.Nil(t, err)
+
+	// 1. disabled
+	require.Nil(t, newCertExpiryTrackerWithConfig("chain1", provider, &CertExpiryConfig{}, log))
+	var tracker *certExpiryTracker
+	require.Nil(t, tracker.scan(time.Now()))
+
+	// 2. the trust roots, trust members and the files are tracked
+	tracker = newCertExpiryTrackerWithConfig("chain1", provider, &CertExpiryConfig{
+		Enabled: true, CertFiles: []string{userCertFile},
+	}, log)
+	kinds := make(map[string]int)
+	for _, expiry := range tracker.scan(time.Now()) {
+		kinds[expiry.kind]++
+	}
+	require.Equal(t, len(testChainConfig.TrustRoots), kinds[certKindTrustRoot])
+	require.Equal(t, len(testChainConfig.TrustMembers), kinds[certKindTrustMember])
+	require.Equal(t, 1, kinds[certKindFile])
+
+	// 3. warn once when a warn days is crossed
+	tracker = newCertExpiryTrackerWithConfig("chain1", nil, &CertExpiryConfig{
+		Enabled: true, WarnDays: []int{7, 30}, CertFiles: []string{userCertFile},
+	}, log)
+	key := (&certExpiry{kind: certKindFile, org: "org-status", cert: userCert}).key()
+	expiries := tracker.scan(userCert.NotAfter.Add(-40 * 24 * time.Hour))
+	require.Len(t, expiries, 1)
+	require.InDelta(t, 40, expiries[0].daysLeft, 0.01)
+	require.NotContains(t, tracker.warned, key)
+
+	tracker.scan(userCert.NotAfter.Add(-20 * 24 * time.Hour))
+	require.Equal(t, 30, tracker.warned[key])
+	tracker.scan(userCert.NotAfter.Add(-5 * 24 * time.Hour))
+	require.Equal(t, 7, tracker.warned[key])
+	tracker.scan(userCert.NotAfter.Add(-4 * 24 * time.Hour))
+	require.Equal(t, 7, tracker.warned[key])
+
+	expiries = tracker.scan(userCert.NotAfter.Add(time.Hour))
+	require.True(t, expiries[0].daysLeft < 0)
+
+	// 4. the certificates removed are not tracked any more
+	require.Nil(t, os.Remove(userCertFile))
+	require.Len(t, tracker.scan(time.Now()), 0)
+	require.NotContains(t, tracker.warned, key)
+
+	// 5. the gauges are deleted when stopped and not reported any more
+	tracker = newCertExpiryTrackerWithConfig("chain1", provider, &CertExpiryConfig{Enabled: true}, log)
+	tracker.metrics = true
+	tracker.start()
+	tracker.scan(time.Now())
+	require.NotEmpty(t, tracker.reported)
+	tracker.stop()
+	require.Empty(t, tracker.reported)
+	tracker.scan(time.Now())
+	require.Empty(t, tracker.reported)
+	tracker.stop()
+	provider.Stop()
+}
+
+func TestNewCertExpiryTracker(t *testing.T) {
+	log := logger2.GetLogger(logger2.MODULE_ACCESS)
+	provider, err := newCertACProvider(testChainConfig, testOrg1, nil, log)
+	require.Nil(t, err)
+
+	// enabled by default, the section nested in storage is ignored
+	useNodeConfig(t, "storage:\n  cert_expiry:\n    enabled: false\n")
+	tracker, err := newCertExpiryTracker("chain1", provider, log)
+	require.Nil(t, err)
+	require.NotNil(t, tracker)
+
+	useNodeConfig(t, "cert_expiry:\n  enabled: false\n")
+	tracker, err = newCertExpiryTracker("chain1", provider, log)
+	require.Nil(t, err)
+	require.Nil(t, tracker)
+}
@@ -14,12 +14,8 @@ import (
 
 	"strings"
 
-	"chainmaker.org/chainmaker/common/v2/cert"
 	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
-	"chainmaker.org/chainmaker/common/v2/crypto/asym"
-	"chainmaker.org/chainmaker/common/v2/crypto/pkcs11"
 	bcx509 "chainmaker.org/chainmaker/common/v2/crypto/x509"
-	"chainmaker.org/chainmaker/localconf/v2"
 	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
 	"chainmaker.org/chainmaker/protocol/v2"
 	"chainmaker.org/chainmaker/utils/v2"
@@ -235,24 +231,9 @@ func NewCertSigningMember(hashType string, member *pbac.Member, privateKeyPem,
 		return nil, err
 	}
 
-	var sk bccrypto.PrivateKey
-	nodeConfig := localconf.ChainMakerConfig.NodeConfig
-	if nodeConfig.P11Config.Enabled {
-		var p11Handle *pkcs11.P11Handle
-		p11Handle, err = getP11Handle()
-		if err != nil {
-			return nil, fmt.Errorf("fail to initialize identity management service: [%v]", err)
-		}
-
-		sk, err = cert.ParseP11PrivKey(p11Handle, []byte(privateKeyPem))
-		if err != nil {
-			return nil, fmt.Errorf("fail to initialize identity management service: [%v]", err)
-		}
-	} else {
-		sk, err = asym.PrivateKeyFromPEM([]byte(privateKeyPem), []byte(password))
-		if err != nil {
-			return nil, err
-		}
+	sk, err := parsePrivateKey([]byte(privateKeyPem), password)
+	if err != nil {
+		return nil, err
 	}
 
 	return &signingCertMember{
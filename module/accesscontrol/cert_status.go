@@ -0,0 +1,313 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bcx509 "chainmaker.org/chainmaker/common/v2/crypto/x509"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// certStatusConfigKey is the top-level section of CertStatusConfig in chainmaker.yml
+	certStatusConfigKey = "cert_status"
+
+	defaultCertStatusCacheTTL = 300
+	defaultCertStatusTimeout  = 5
+
+	maxCertStatusResponseSize = 32 * 1024 * 1024
+	ocspResponseFileSuffix    = ".ocsp"
+)
+
+// CertStatusConfig configures the checking of the certificate status against the CRL distribution
+// points and the OCSP responders embedded in the certificates, besides the CRLs submitted on chain
+type CertStatusConfig struct {
+	// Enabled enables the checking
+	Enabled bool `mapstructure:"enabled"`
+	// HardFail rejects the certificates whose status is unknown, e.g. all responders are unreachable,
+	// they are accepted with a warning by default
+	HardFail bool `mapstructure:"hard_fail"`
+	// CacheTTL is the seconds to cache a status, capped by the next update of the CRL or the OCSP response
+	CacheTTL int `mapstructure:"cache_ttl"`
+	// Timeout is the seconds to wait for a responder
+	Timeout int `mapstructure:"timeout"`
+	// ResponderDir replaces the responders with local files, for tests: the CRLs are read from
+	// {dir}/{last path element of the distribution point} and the OCSP responses from {dir}/{serial hex}.ocsp
+	ResponderDir string `mapstructure:"responder_dir"`
+}
+
+// OnlineStatusChecker is implemented by the access control providers checking the status of the members
+// online. The status depends on the responders and the time of the check, so it is checked when a tx is
+// admitted by the rpc server, and never when a block is verified or a tx is executed.
+type OnlineStatusChecker interface {
+	// CheckOnlineStatus returns an error if the member is revoked, or its status is unknown with hard fail
+	CheckOnlineStatus(member *pbac.Member) error
+}
+
+type certStatus int
+
+const (
+	certStatusUnknown certStatus = iota
+	certStatusGood
+	certStatusRevoked
+)
+
+type certStatusCached struct {
+	status certStatus
+	reason string
+	expire time.Time
+}
+
+type crlCached struct {
+	crl    *pkix.CertificateList
+	expire time.Time
+}
+
+// certStatusChecker checks the status of the certificates online, all methods are no-op on nil
+type certStatusChecker struct {
+	hardFail     bool
+	ttl          time.Duration
+	responderDir string
+	client       *http.Client
+	log          protocol.Logger
+
+	// status of the certificates, the key is the issuer key id and the serial number
+	statusCache sync.Map
+	// CRLs fetched from the distribution points, the key is the distribution point
+	crlCache sync.Map
+}
+
+// newCertStatusChecker creates the checker by the local config, returns nil if it is not enabled
+func newCertStatusChecker(log protocol.Logger) (*certStatusChecker, error) {
+	conf := &CertStatusConfig{}
+	if ok, err := LoadNodeConfigSection(certStatusConfigKey, conf); !ok || err != nil {
+		return nil, err
+	}
+	return newCertStatusCheckerWithConfig(conf, log), nil
+}
+
+func newCertStatusCheckerWithConfig(conf *CertStatusConfig, log protocol.Logger) *certStatusChecker {
+	if conf == nil || !conf.Enabled {
+		return nil
+	}
+	ttl, timeout := conf.CacheTTL, conf.Timeout
+	if ttl <= 0 {
+		ttl = defaultCertStatusCacheTTL
+	}
+	if timeout <= 0 {
+		timeout = defaultCertStatusTimeout
+	}
+	return &certStatusChecker{
+		hardFail:     conf.HardFail,
+		ttl:          time.Duration(ttl) * time.Second,
+		responderDir: conf.ResponderDir,
+		client:       &http.Client{Timeout: time.Duration(timeout) * time.Second},
+		log:          log,
+	}
+}
+
+// check checks the certificates of the chain but the last one against the responders of them,
+// the error of a revoked certificate is the same as the one of checkCRL
+func (c *certStatusChecker) check(certChain []*bcx509.Certificate) error {
+	if c == nil {
+		return nil
+	}
+	for i := 0; i+1 < len(certChain); i++ {
+		status, reason := c.status(certChain[i], certChain[i+1])
+		switch status {
+		case certStatusRevoked:
+			return errors.New("certificate is revoked")
+		case certStatusUnknown:
+			if c.hardFail {
+				return fmt.Errorf("status of certificate [serial: %s] is unknown: %s",
+					certChain[i].SerialNumber.Text(16), reason)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *certStatusChecker) status(cert, issuer *bcx509.Certificate) (certStatus, string) {
+	if len(cert.OCSPServer) == 0 && len(cert.CRLDistributionPoints) == 0 {
+		return certStatusGood, ""
+	}
+	key := hex.EncodeToString(issuer.SubjectKeyId) + ":" + cert.SerialNumber.Text(16)
+	if cached, ok := c.statusCache.Load(key); ok && time.Now().Before(cached.(*certStatusCached).expire) {
+		return cached.(*certStatusCached).status, cached.(*certStatusCached).reason
+	}
+
+	// the OCSP responders are preferred, they are fresher than the CRLs
+	var reasons []string
+	status, nextUpdate, err := c.queryOCSP(cert, issuer)
+	if err != nil {
+		reasons = append(reasons, err.Error())
+		if status, nextUpdate, err = c.queryCRL(cert, issuer); err != nil {
+			reasons = append(reasons, err.Error())
+		}
+	}
+	reason := strings.Join(reasons, "; ")
+	if status == certStatusUnknown {
+		c.log.Warnf("status of certificate [serial: %s] is unknown, hard fail: %v, %s",
+			cert.SerialNumber.Text(16), c.hardFail, reason)
+	}
+
+	expire := time.Now().Add(c.ttl)
+	if !nextUpdate.IsZero() && nextUpdate.Before(expire) {
+		expire = nextUpdate
+	}
+	c.statusCache.Store(key, &certStatusCached{status: status, reason: reason, expire: expire})
+	return status, reason
+}
+
+func (c *certStatusChecker) queryOCSP(cert, issuer *bcx509.Certificate) (certStatus, time.Time, error) {
+	if len(cert.OCSPServer) == 0 {
+		return certStatusUnknown, time.Time{}, errors.New("no OCSP responder")
+	}
+	// the OCSP request and response are handled by the standard library, which parses RSA and ECDSA only
+	stdCert, err := x509.ParseCertificate(cert.Raw)
+	if err != nil {
+		return certStatusUnknown, time.Time{}, fmt.Errorf("OCSP is not supported by the certificate: %v", err)
+	}
+	stdIssuer, err := x509.ParseCertificate(issuer.Raw)
+	if err != nil {
+		return certStatusUnknown, time.Time{}, fmt.Errorf("OCSP is not supported by the issuer: %v", err)
+	}
+
+	var errs []string
+	for _, server := range cert.OCSPServer {
+		respBytes, err := c.fetchOCSP(server, stdCert, stdIssuer)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("OCSP responder %s: %v", server, err))
+			continue
+		}
+		resp, err := ocsp.ParseResponseForCert(respBytes, stdCert, stdIssuer)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("OCSP responder %s: invalid response: %v", server, err))
+			continue
+		}
+		switch resp.Status {
+		case ocsp.Good:
+			return certStatusGood, resp.NextUpdate, nil
+		case ocsp.Revoked:
+			return certStatusRevoked, resp.NextUpdate, nil
+		}
+		errs = append(errs, fmt.Sprintf("OCSP responder %s: unknown status", server))
+	}
+	return certStatusUnknown, time.Time{}, errors.New(strings.Join(errs, "; "))
+}
+
+func (c *certStatusChecker) fetchOCSP(server string, cert, issuer *x509.Certificate) ([]byte, error) {
+	if c.responderDir != "" {
+		return readResponderFile(filepath.Join(c.responderDir, cert.SerialNumber.Text(16)+ocspResponseFileSuffix))
+	}
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Post(server, "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	return readResponse(resp)
+}
+
+func (c *certStatusChecker) queryCRL(cert, issuer *bcx509.Certificate) (certStatus, time.Time, error) {
+	if len(cert.CRLDistributionPoints) == 0 {
+		return certStatusUnknown, time.Time{}, errors.New("no CRL distribution point")
+	}
+	var errs []string
+	for _, dp := range cert.CRLDistributionPoints {
+		crl, err := c.loadCRL(dp, issuer)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("CRL distribution point %s: %v", dp, err))
+			continue
+		}
+		for _, rc := range crl.TBSCertList.RevokedCertificates {
+			if rc.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return certStatusRevoked, crl.TBSCertList.NextUpdate, nil
+			}
+		}
+		return certStatusGood, crl.TBSCertList.NextUpdate, nil
+	}
+	return certStatusUnknown, time.Time{}, errors.New(strings.Join(errs, "; "))
+}
+
+// loadCRL returns the CRL of the distribution point, it is fetched again when it expires
+func (c *certStatusChecker) loadCRL(dp string, issuer *bcx509.Certificate) (*pkix.CertificateList, error) {
+	now := time.Now()
+	if cached, ok := c.crlCache.Load(dp); ok && now.Before(cached.(*crlCached).expire) {
+		return cached.(*crlCached).crl, nil
+	}
+
+	var (
+		crlBytes []byte
+		err      error
+	)
+	if c.responderDir != "" {
+		var dpUrl *url.URL
+		if dpUrl, err = url.Parse(dp); err != nil {
+			return nil, err
+		}
+		crlBytes, err = readResponderFile(filepath.Join(c.responderDir, path.Base(dpUrl.Path)))
+	} else {
+		var resp *http.Response
+		if resp, err = c.client.Get(dp); err == nil {
+			crlBytes, err = readResponse(resp)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// both PEM and DER are accepted
+	crl, err := x509.ParseCRL(crlBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRL: %v", err)
+	}
+	if err = issuer.CheckCRLSignature(crl); err != nil {
+		return nil, fmt.Errorf("CRL is not signed by the issuer: %v", err)
+	}
+	if crl.HasExpired(now) {
+		return nil, fmt.Errorf("CRL has expired at %s", crl.TBSCertList.NextUpdate)
+	}
+
+	expire := now.Add(c.ttl)
+	if !crl.TBSCertList.NextUpdate.IsZero() && crl.TBSCertList.NextUpdate.Before(expire) {
+		expire = crl.TBSCertList.NextUpdate
+	}
+	c.crlCache.Store(dp, &crlCached{crl: crl, expire: expire})
+	return crl, nil
+}
+
+func readResponse(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected http status %s", resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxCertStatusResponseSize))
+}
+
+func readResponderFile(file string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Clean(file))
+}
@@ -0,0 +1,165 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bcx509 "chainmaker.org/chainmaker/common/v2/crypto/x509"
+	logger2 "chainmaker.org/chainmaker/logger/v2"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+type testStatusCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestStatusCA(t *testing.T) *testStatusCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca.status.test", Organization: []string{"org-status"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	return &testStatusCA{cert: cert, key: key}
+}
+
+func (ca *testStatusCA) issue(t *testing.T, serial int64, ocspServer, crlDP []string) *bcx509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "client.status.test", Organization: []string{"org-status"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		OCSPServer:            ocspServer,
+		CRLDistributionPoints: crlDP,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.Nil(t, err)
+	cert, err := bcx509.ParseCertificate(der)
+	require.Nil(t, err)
+	return cert
+}
+
+func (ca *testStatusCA) bcCert(t *testing.T) *bcx509.Certificate {
+	cert, err := bcx509.ParseCertificate(ca.cert.Raw)
+	require.Nil(t, err)
+	return cert
+}
+
+func (ca *testStatusCA) writeOCSP(t *testing.T, dir string, cert *bcx509.Certificate, status int) {
+	resp, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+		Status:       status,
+		SerialNumber: cert.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+		RevokedAt:    time.Now().Add(-time.Minute),
+	}, ca.key)
+	require.Nil(t, err)
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, cert.SerialNumber.Text(16)+ocspResponseFileSuffix), resp, 0600))
+}
+
+func (ca *testStatusCA) writeCRL(t *testing.T, dir, name string, revoked ...int64) {
+	var revokedCerts []pkix.RevokedCertificate
+	for _, serial := range revoked {
+		revokedCerts = append(revokedCerts, pkix.RevokedCertificate{
+			SerialNumber: big.NewInt(serial), RevocationTime: time.Now().Add(-time.Minute),
+		})
+	}
+	crl, err := ca.cert.CreateCRL(rand.Reader, crypto.Signer(ca.key), revokedCerts,
+		time.Now().Add(-time.Minute), time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), crl, 0600))
+}
+
+func TestCertStatusChecker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cert_status")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	log := logger2.GetLogger(logger2.MODULE_ACCESS)
+
+	ca := newTestStatusCA(t)
+	root := ca.bcCert(t)
+	ocspCert := ca.issue(t, 10, []string{"http://ocsp.status.test"}, nil)
+	crlCert := ca.issue(t, 11, nil, []string{"http://crl.status.test/ca.crl"})
+	revokedCert := ca.issue(t, 12, nil, []string{"http://crl.status.test/ca.crl"})
+	unknownCert := ca.issue(t, 13, []string{"http://ocsp.status.test"}, nil)
+	plainCert := ca.issue(t, 14, nil, nil)
+	ca.writeOCSP(t, dir, ocspCert, ocsp.Revoked)
+	ca.writeCRL(t, dir, "ca.crl", 12)
+
+	// 1. disabled
+	require.Nil(t, newCertStatusCheckerWithConfig(&CertStatusConfig{}, log))
+	var checker *certStatusChecker
+	require.Nil(t, checker.check([]*bcx509.Certificate{ocspCert, root}))
+
+	// 2. soft fail
+	checker = newCertStatusCheckerWithConfig(&CertStatusConfig{Enabled: true, ResponderDir: dir}, log)
+	require.EqualError(t, checker.check([]*bcx509.Certificate{ocspCert, root}), "certificate is revoked")
+	require.Nil(t, checker.check([]*bcx509.Certificate{crlCert, root}))
+	require.EqualError(t, checker.check([]*bcx509.Certificate{revokedCert, root}), "certificate is revoked")
+	require.Nil(t, checker.check([]*bcx509.Certificate{unknownCert, root}))
+	require.Nil(t, checker.check([]*bcx509.Certificate{plainCert, root}))
+
+	// 3. hard fail
+	checker = newCertStatusCheckerWithConfig(&CertStatusConfig{Enabled: true, HardFail: true, ResponderDir: dir}, log)
+	require.Error(t, checker.check([]*bcx509.Certificate{unknownCert, root}))
+	require.Nil(t, checker.check([]*bcx509.Certificate{crlCert, root}))
+
+	// 4. the status is cached until the ttl
+	ca.writeCRL(t, dir, "ca.crl", 11, 12)
+	require.Nil(t, checker.check([]*bcx509.Certificate{crlCert, root}))
+	checker.statusCache.Range(func(key, _ interface{}) bool {
+		checker.statusCache.Delete(key)
+		return true
+	})
+	checker.crlCache.Range(func(key, _ interface{}) bool {
+		checker.crlCache.Delete(key)
+		return true
+	})
+	require.EqualError(t, checker.check([]*bcx509.Certificate{crlCert, root}), "certificate is revoked")
+}
+
+func TestNewCertStatusChecker(t *testing.T) {
+	log := logger2.GetLogger(logger2.MODULE_ACCESS)
+	// the section nested in storage is ignored
+	useNodeConfig(t, "storage:\n  cert_status:\n    enabled: true\n")
+	checker, err := newCertStatusChecker(log)
+	require.Nil(t, err)
+	require.Nil(t, checker)
+
+	useNodeConfig(t, "cert_status:\n  enabled: true\n  hard_fail: true\n")
+	checker, err = newCertStatusChecker(log)
+	require.Nil(t, err)
+	require.NotNil(t, checker)
+	require.True(t, checker.hardFail)
+}
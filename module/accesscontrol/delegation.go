@@ -0,0 +1,390 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/pb-go/v2/config"
+	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+// A delegation lets a member of an organization act as an admin of the organization on some resources,
+// it is a resource policy of the chain config, granted and revoked by the chain config updates:
+//
+//	resource_name: DELEGATION:<name>
+//	policy:
+//	  rule: DELEGATE <uid> [ON (<resource>[, <resource>]...)] [CONTRACTS (<contract>[, <contract>]...)] [UNTIL <time>]
+//	  org_list: [<org>]
+//
+// The uid is the hex subject key id of the certificate or the public key of the delegate, who should belong to
+// the organization. The delegate endorses as an admin the resources listed, the policies of the contracts listed
+// and their methods, and the contract management of the contracts listed, until the RFC3339 time if set.
+// The time is the timestamp of the transaction, set by the client, so a delegation with UNTIL is taken as
+// expired if the chain does not verify the timestamps of the transactions by block.tx_timestamp_verify.
+//
+// A delegation is granted or updated by the chain config updates endorsed by an admin of its organization,
+// besides the policy of the update, and an update also by an admin of the organization granting it before.
+//
+// The delegations of an organization are limited by the quota, 5 by default:
+//
+//	resource_name: DELEGATION_QUOTA or DELEGATION_QUOTA:<org>
+//	policy:
+//	  rule: <number>
+const (
+	delegationResourcePrefix = "DELEGATION:"
+	delegationQuotaResource  = "DELEGATION_QUOTA"
+
+	defaultDelegationQuota = 5
+)
+
+var delegationRuleRegexp = regexp.MustCompile(`(?i)^DELEGATE\s+([0-9a-f]+)(?:\s+ON\s*\(([^)]*)\))?` +
+	`(?:\s+CONTRACTS\s*\(([^)]*)\))?(?:\s+UNTIL\s+(\S+))?$`)
+
+// delegation is an admin power of an organization delegated to a member
+type delegation struct {
+	name      string
+	orgId     string
+	delegate  string
+	resources map[string]bool
+	contracts map[string]bool
+	// unix seconds, 0 if it never expires
+	expireAt int64
+}
+
+// delegations are the delegations on the chain
+type delegations struct {
+	// delegate uid -> delegations
+	byDelegate map[string][]*delegation
+	// name -> delegation
+	byName       map[string]*delegation
+	countByOrg   map[string]int
+	quotaByOrg   map[string]int
+	defaultQuota int
+	// the endorsements added by the delegations, delegated memberInfo -> *delegatedMember,
+	// they are dropped with the delegations updated
+	signers *sync.Map
+}
+
+// delegatedMember is the member acting as an admin of the organization by a delegation
+type delegatedMember struct {
+	protocol.Member
+	delegation *delegation
+}
+
+func (m *delegatedMember) GetRole() protocol.Role {
+	return protocol.RoleAdmin
+}
+
+func isDelegationResource(resourceName string) bool {
+	return strings.HasPrefix(resourceName, delegationResourcePrefix) ||
+		resourceName == delegationQuotaResource || strings.HasPrefix(resourceName, delegationQuotaResource+":")
+}
+
+// parseDelegation parses the delegation from the resource policy
+func parseDelegation(resourcePolicy *config.ResourcePolicy) (*delegation, error) {
+	name := strings.TrimPrefix(resourcePolicy.ResourceName, delegationResourcePrefix)
+	if name == "" {
+		return nil, fmt.Errorf("the name of delegation is empty")
+	}
+	if len(resourcePolicy.Policy.OrgList) != 1 {
+		return nil, fmt.Errorf("delegation [%s] should be granted by one organization", name)
+	}
+	matches := delegationRuleRegexp.FindStringSubmatch(strings.TrimSpace(resourcePolicy.Policy.Rule))
+	if matches == nil {
+		return nil, fmt.Errorf("bad delegation [%s]: %s", name, resourcePolicy.Policy.Rule)
+	}
+	d := &delegation{
+		name:      name,
+		orgId:     resourcePolicy.Policy.OrgList[0],
+		delegate:  strings.ToLower(matches[1]),
+		resources: splitDelegationList(matches[2]),
+		contracts: splitDelegationList(matches[3]),
+	}
+	if len(d.resources) == 0 && len(d.contracts) == 0 {
+		return nil, fmt.Errorf("delegation [%s] delegates neither resources nor contracts", name)
+	}
+	for resource := range d.resources {
+		if restrainedResourceList[resource] || isDelegationResource(resource) {
+			return nil, fmt.Errorf("resource [%s] should not be delegated", resource)
+		}
+	}
+	if matches[4] != "" {
+		expireAt, err := time.Parse(time.RFC3339, matches[4])
+		if err != nil {
+			return nil, fmt.Errorf("bad expiration of delegation [%s]: %v", name, err)
+		}
+		d.expireAt = expireAt.Unix()
+	}
+	return d, nil
+}
+
+func splitDelegationList(list string) map[string]bool {
+	items := make(map[string]bool)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items[item] = true
+		}
+	}
+	return items
+}
+
+func parseDelegationQuota(resourcePolicy *config.ResourcePolicy) (int, error) {
+	quota, err := strconv.Atoi(strings.TrimSpace(resourcePolicy.Policy.Rule))
+	if err != nil || quota < 0 {
+		return 0, fmt.Errorf("bad delegation quota [%s]", resourcePolicy.Policy.Rule)
+	}
+	return quota, nil
+}
+
+// covers checks whether the delegation covers the resource of the request at the time of it
+func (d *delegation) covers(resourceName string, payload *common.Payload) bool {
+	if d.expireAt > 0 && payload.Timestamp >= d.expireAt {
+		return false
+	}
+	if d.resources[resourceName] {
+		return true
+	}
+	if len(d.contracts) == 0 {
+		return false
+	}
+	// the policies of the contracts and their methods
+	contractName := strings.SplitN(resourceName, "-", 2)[0]
+	if d.contracts[contractName] && isContractResource(resourceName) {
+		return true
+	}
+	// the contract management of the contracts
+	if payload.ContractName == syscontract.SystemContract_CONTRACT_MANAGE.String() &&
+		resourceName == payload.ContractName+"-"+payload.Method {
+		for _, param := range payload.Parameters {
+			if param.Key == syscontract.FreezeContract_CONTRACT_NAME.String() {
+				return d.contracts[string(param.Value)]
+			}
+		}
+	}
+	return false
+}
+
+func (ds *delegations) quota(orgId string) int {
+	if quota, ok := ds.quotaByOrg[orgId]; ok {
+		return quota
+	}
+	return ds.defaultQuota
+}
+
+// initDelegations loads the delegations and the quotas from the resource policies,
+// the delegations over the quota of the organization are ignored
+func (acs *accessControlService) initDelegations(resourcePolicies []*config.ResourcePolicy) {
+	ds := &delegations{
+		byDelegate:   make(map[string][]*delegation),
+		byName:       make(map[string]*delegation),
+		countByOrg:   make(map[string]int),
+		quotaByOrg:   make(map[string]int),
+		defaultQuota: defaultDelegationQuota,
+		signers:      &sync.Map{},
+	}
+	for _, resourcePolicy := range resourcePolicies {
+		if resourcePolicy.Policy == nil || resourcePolicy.Policy.Rule == string(protocol.RuleDelete) ||
+			!isDelegationResource(resourcePolicy.ResourceName) ||
+			strings.HasPrefix(resourcePolicy.ResourceName, delegationResourcePrefix) {
+			continue
+		}
+		quota, err := parseDelegationQuota(resourcePolicy)
+		if err != nil {
+			acs.log.Warnf("ignore %s: %v", resourcePolicy.ResourceName, err)
+			continue
+		}
+		if resourcePolicy.ResourceName == delegationQuotaResource {
+			ds.defaultQuota = quota
+		} else {
+			ds.quotaByOrg[strings.TrimPrefix(resourcePolicy.ResourceName, delegationQuotaResource+":")] = quota
+		}
+	}
+	for _, resourcePolicy := range resourcePolicies {
+		if resourcePolicy.Policy == nil || resourcePolicy.Policy.Rule == string(protocol.RuleDelete) ||
+			!strings.HasPrefix(resourcePolicy.ResourceName, delegationResourcePrefix) {
+			continue
+		}
+		d, err := parseDelegation(resourcePolicy)
+		if err != nil {
+			acs.log.Warnf("ignore %s: %v", resourcePolicy.ResourceName, err)
+			continue
+		}
+		if ds.countByOrg[d.orgId] >= ds.quota(d.orgId) {
+			acs.log.Warnf("ignore delegation [%s], the quota %d of organization [%s] is used up",
+				d.name, ds.quota(d.orgId), d.orgId)
+			continue
+		}
+		ds.countByOrg[d.orgId]++
+		ds.byName[d.name] = d
+		ds.byDelegate[d.delegate] = append(ds.byDelegate[d.delegate], d)
+	}
+	acs.delegations.Store(ds)
+}
+
+// getSignerMember returns the member of the signer of an endorsement being verified, or the delegated member
+// of the endorsement added by the delegation, which is never resolved from the endorsements received
+func (acs *accessControlService) getSignerMember(signer *pbac.Member) protocol.Member {
+	if ds := acs.getDelegations(); ds != nil {
+		if delegated, ok := ds.signers.Load(string(signer.MemberInfo)); ok {
+			return delegated.(*delegatedMember)
+		}
+	}
+	return acs.getMemberFromCache(signer)
+}
+
+func (acs *accessControlService) getDelegations() *delegations {
+	ds, _ := acs.delegations.Load().(*delegations)
+	return ds
+}
+
+// validateDelegationPolicy checks the delegation or the quota updated, and the quota of the organization
+func (acs *accessControlService) validateDelegationPolicy(resourcePolicy *config.ResourcePolicy) bool {
+	if resourcePolicy.Policy.Rule == string(protocol.RuleDelete) {
+		return true
+	}
+	if !strings.HasPrefix(resourcePolicy.ResourceName, delegationResourcePrefix) {
+		if _, err := parseDelegationQuota(resourcePolicy); err != nil {
+			acs.log.Errorf("bad configuration: %v", err)
+			return false
+		}
+		orgId := strings.TrimPrefix(resourcePolicy.ResourceName, delegationQuotaResource+":")
+		if orgId != delegationQuotaResource && acs.getOrgInfoByOrgId(orgId) == nil {
+			acs.log.Errorf("bad configuration: unknown organization [%s] of %s", orgId, resourcePolicy.ResourceName)
+			return false
+		}
+		return true
+	}
+	d, err := parseDelegation(resourcePolicy)
+	if err != nil {
+		acs.log.Errorf("bad configuration: %v", err)
+		return false
+	}
+	if ds := acs.getDelegations(); ds != nil {
+		if _, ok := ds.byName[d.name]; !ok && ds.countByOrg[d.orgId] >= ds.quota(d.orgId) {
+			acs.log.Errorf("bad configuration: the delegation quota %d of organization [%s] is used up",
+				ds.quota(d.orgId), d.orgId)
+			return false
+		}
+	}
+	return true
+}
+
+// applyDelegations adds the endorsements of the delegates as the admins of their organizations,
+// if the delegations cover the resource at the time of the transaction
+func (acs *accessControlService) applyDelegations(principal,
+	refinedPrincipal protocol.Principal) (protocol.Principal, error) {
+	ds := acs.getDelegations()
+	if ds == nil || len(ds.byDelegate) == 0 {
+		return refinedPrincipal, nil
+	}
+	var payload *common.Payload
+	endorsements := refinedPrincipal.GetEndorsement()
+	var delegated []*common.EndorsementEntry
+	for _, endorsement := range endorsements {
+		member := acs.getMemberFromCache(endorsement.Signer)
+		if member == nil {
+			continue
+		}
+		for _, d := range ds.byDelegate[strings.ToLower(member.GetUid())] {
+			if d.orgId != member.GetOrgId() {
+				continue
+			}
+			if payload == nil {
+				payload = &common.Payload{}
+				if err := payload.Unmarshal(principal.GetMessage()); err != nil || payload.Timestamp <= 0 {
+					// delegations apply to the transactions only
+					return refinedPrincipal, nil
+				}
+			}
+			if d.expireAt > 0 && atomic.LoadInt32(&acs.txTimestampVerify) == 0 {
+				acs.log.Debugf("delegation [%s] with an expiration requires tx_timestamp_verify", d.name)
+				continue
+			}
+			if !d.covers(principal.GetResourceName(), payload) {
+				continue
+			}
+			memberInfo := delegationResourcePrefix + d.name + ":" + string(endorsement.Signer.MemberInfo)
+			ds.signers.LoadOrStore(memberInfo, &delegatedMember{Member: member, delegation: d})
+			delegated = append(delegated, &common.EndorsementEntry{
+				Signer: &pbac.Member{
+					OrgId:      d.orgId,
+					MemberInfo: []byte(memberInfo),
+					MemberType: endorsement.Signer.MemberType,
+				},
+				Signature: endorsement.Signature,
+			})
+			acs.log.Debugf("signer [%s] endorses [%s] as an admin of [%s] by delegation [%s]",
+				member.GetMemberId(), principal.GetResourceName(), d.orgId, d.name)
+			break
+		}
+	}
+	if len(delegated) == 0 {
+		return refinedPrincipal, nil
+	}
+	return acs.createPrincipalForTargetOrg(refinedPrincipal.GetResourceName(),
+		append(append([]*common.EndorsementEntry{}, endorsements...), delegated...),
+		refinedPrincipal.GetMessage(), refinedPrincipal.GetTargetOrgId())
+}
+
+// verifyDelegationGrants checks the delegations granted or updated by the permission updates of the chain config
+// are endorsed by the admins of the organizations granting them
+func (acs *accessControlService) verifyDelegationGrants(principal, refinedPrincipal protocol.Principal) (bool, error) {
+	resourceName := principal.GetResourceName()
+	chainConfigName := syscontract.SystemContract_CHAIN_CONFIG.String()
+	if resourceName != chainConfigName+"-"+syscontract.ChainConfigFunction_PERMISSION_ADD.String() &&
+		resourceName != chainConfigName+"-"+syscontract.ChainConfigFunction_PERMISSION_UPDATE.String() {
+		return true, nil
+	}
+	payload := &common.Payload{}
+	if err := payload.Unmarshal(principal.GetMessage()); err != nil {
+		return false, fmt.Errorf("authentication fail: [%s] requires a transaction", resourceName)
+	}
+	for _, param := range payload.Parameters {
+		if !strings.HasPrefix(param.Key, delegationResourcePrefix) {
+			continue
+		}
+		grantPolicy := &pbac.Policy{}
+		if err := grantPolicy.Unmarshal(param.Value); err != nil || len(grantPolicy.OrgList) != 1 {
+			return false, fmt.Errorf("authentication fail: bad delegation [%s]", param.Key)
+		}
+		orgIds := []string{grantPolicy.OrgList[0]}
+		if ds := acs.getDelegations(); ds != nil {
+			if d, ok := ds.byName[strings.TrimPrefix(param.Key, delegationResourcePrefix)]; ok &&
+				d.orgId != orgIds[0] {
+				orgIds = append(orgIds, d.orgId)
+			}
+		}
+		for _, orgId := range orgIds {
+			if !acs.endorsedByAdminOf(orgId, refinedPrincipal.GetEndorsement()) {
+				return false, fmt.Errorf("authentication fail: delegation [%s] requires an admin of [%s]",
+					param.Key, orgId)
+			}
+		}
+	}
+	return true, nil
+}
+
+// endorsedByAdminOf returns whether an admin of the organization endorses, the delegated admins are not counted
+func (acs *accessControlService) endorsedByAdminOf(orgId string, endorsements []*common.EndorsementEntry) bool {
+	for _, endorsement := range endorsements {
+		member := acs.getMemberFromCache(endorsement.Signer)
+		if member != nil && member.GetOrgId() == orgId && member.GetRole() == protocol.RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	logger2 "chainmaker.org/chainmaker/logger/v2"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/pb-go/v2/config"
+	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelegation(t *testing.T) {
+	_, cleanFunc, err := createTempDirWithCleanFunc()
+	require.Nil(t, err)
+	defer cleanFunc()
+
+	member1 := initOrgMember(t, orgMemberInfoMap[testOrg1])
+	member2 := initOrgMember(t, orgMemberInfoMap[testOrg2])
+	freeze := syscontract.SystemContract_CERT_MANAGE.String() + "-" + syscontract.CertManageFunction_CERTS_FREEZE.String()
+	unfreeze := syscontract.SystemContract_CERT_MANAGE.String() + "-" +
+		syscontract.CertManageFunction_CERTS_UNFREEZE.String()
+
+	chainConfig := proto.Clone(testChainConfig).(*config.ChainConfig)
+	chainConfig.Block = &config.BlockConfig{TxTimestampVerify: true, TxTimestampExpireDuration: 600}
+	chainConfig.ResourcePolicies = append(chainConfig.ResourcePolicies,
+		&config.ResourcePolicy{
+			ResourceName: "erc20-mint",
+			Policy:       &pbac.Policy{Rule: string(protocol.RuleAny), RoleList: []string{string(protocol.RoleAdmin)}},
+		},
+		&config.ResourcePolicy{
+			ResourceName: "DELEGATION:operator1",
+			Policy: &pbac.Policy{
+				Rule: fmt.Sprintf("DELEGATE %s ON (%s) CONTRACTS (erc20) UNTIL 2021-10-02T00:00:00Z",
+					member1.client.GetUid(), freeze),
+				OrgList: []string{testOrg1},
+			},
+		},
+		&config.ResourcePolicy{
+			ResourceName: "DELEGATION_QUOTA:" + testOrg2,
+			Policy:       &pbac.Policy{Rule: "0"},
+		},
+		&config.ResourcePolicy{
+			ResourceName: "DELEGATION:operator2",
+			Policy: &pbac.Policy{
+				Rule:    fmt.Sprintf("DELEGATE %s ON (%s)", member2.client.GetUid(), freeze),
+				OrgList: []string{testOrg2},
+			},
+		},
+	)
+	provider, err := newCertACProvider(chainConfig, testOrg1, nil, logger2.GetLogger(logger2.MODULE_ACCESS))
+	require.Nil(t, err)
+	require.EqualValues(t, 1, provider.acService.contractPolicyNum)
+
+	noon := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC).Unix()
+	later := time.Date(2021, 10, 3, 12, 0, 0, 0, time.UTC).Unix()
+	verify := func(member *orgMember, resourceName, contract, method string, timestamp int64) bool {
+		payload, err := (&common.Payload{
+			ChainId: chainConfig.ChainId, TxType: common.TxType_INVOKE_CONTRACT,
+			ContractName: contract, Method: method, Timestamp: timestamp,
+		}).Marshal()
+		require.Nil(t, err)
+		endorsement, err := testCreateEndorsementEntry(member, protocol.RoleClient, testHashType, string(payload))
+		require.Nil(t, err)
+		principal, err := provider.CreatePrincipal(resourceName, []*common.EndorsementEntry{endorsement}, payload)
+		require.Nil(t, err)
+		ok, _ := provider.VerifyPrincipal(principal)
+		return ok
+	}
+	certManage := syscontract.SystemContract_CERT_MANAGE.String()
+	certsFreeze := syscontract.CertManageFunction_CERTS_FREEZE.String()
+	certsUnfreeze := syscontract.CertManageFunction_CERTS_UNFREEZE.String()
+
+	// 1. the delegate endorses the resources and contracts delegated as an admin before the expiration
+	require.True(t, verify(member1, freeze, certManage, certsFreeze, noon))
+	require.True(t, verify(member1, common.TxType_INVOKE_CONTRACT.String(), "erc20", "mint", noon))
+	require.False(t, verify(member1, unfreeze, certManage, certsUnfreeze, noon))
+	require.False(t, verify(member1, freeze, certManage, certsFreeze, later))
+
+	// the delegation with an expiration is taken as expired if the timestamps of the txs are set by the clients
+	chainConfig.Block.TxTimestampVerify = false
+	require.Nil(t, provider.Watch(chainConfig))
+	require.False(t, verify(member1, freeze, certManage, certsFreeze, noon))
+	chainConfig.Block.TxTimestampVerify = true
+	require.Nil(t, provider.Watch(chainConfig))
+	require.True(t, verify(member1, freeze, certManage, certsFreeze, noon))
+
+	// 2. the delegations over the quota are ignored and rejected
+	require.False(t, verify(member2, freeze, certManage, certsFreeze, noon))
+	require.False(t, provider.ValidateResourcePolicy(&config.ResourcePolicy{
+		ResourceName: "DELEGATION:operator3",
+		Policy: &pbac.Policy{
+			Rule:    fmt.Sprintf("DELEGATE %s ON (%s)", member2.client.GetUid(), freeze),
+			OrgList: []string{testOrg2},
+		},
+	}))
+	require.True(t, provider.ValidateResourcePolicy(&config.ResourcePolicy{
+		ResourceName: "DELEGATION:operator3",
+		Policy: &pbac.Policy{
+			Rule:    fmt.Sprintf("DELEGATE %s ON (%s)", member1.admin.GetUid(), unfreeze),
+			OrgList: []string{testOrg1},
+		},
+	}))
+	for _, rule := range []string{
+		"DELEGATE 0a1b",
+		"DELEGATE 0a1b ON (P2P)",
+		"DELEGATE 0a1b ON (" + freeze + ") UNTIL tomorrow",
+	} {
+		require.False(t, provider.ValidateResourcePolicy(&config.ResourcePolicy{
+			ResourceName: "DELEGATION:operator3",
+			Policy:       &pbac.Policy{Rule: rule, OrgList: []string{testOrg1}},
+		}), rule)
+	}
+
+	// 3. a delegation is granted by an admin of its organization, and updated also by the one granting it before
+	grant := func(name, orgId string, admins ...*orgMember) bool {
+		grantPolicy, err := (&pbac.Policy{
+			Rule:    fmt.Sprintf("DELEGATE %s ON (%s)", member2.client.GetUid(), freeze),
+			OrgList: []string{orgId},
+		}).Marshal()
+		require.Nil(t, err)
+		payload, err := (&common.Payload{
+			ChainId: chainConfig.ChainId, TxType: common.TxType_INVOKE_CONTRACT,
+			ContractName: syscontract.SystemContract_CHAIN_CONFIG.String(),
+			Method:       syscontract.ChainConfigFunction_PERMISSION_ADD.String(),
+			Parameters:   []*common.KeyValuePair{{Key: delegationResourcePrefix + name, Value: grantPolicy}},
+			Timestamp:    noon,
+		}).Marshal()
+		require.Nil(t, err)
+		var endorsements []*common.EndorsementEntry
+		for _, admin := range admins {
+			endorsement, err := testCreateEndorsementEntry(admin, protocol.RoleAdmin, testHashType, string(payload))
+			require.Nil(t, err)
+			endorsements = append(endorsements, endorsement)
+		}
+		principal, err := provider.CreatePrincipal(syscontract.SystemContract_CHAIN_CONFIG.String()+"-"+
+			syscontract.ChainConfigFunction_PERMISSION_ADD.String(), endorsements, payload)
+		require.Nil(t, err)
+		refinedPrincipal, err := provider.refinePrincipal(principal)
+		require.Nil(t, err)
+		ok, _ := provider.acService.verifyDelegationGrants(principal, refinedPrincipal)
+		return ok
+	}
+	require.True(t, grant("operator4", testOrg2, member2))
+	require.False(t, grant("operator4", testOrg2, member1))
+	require.False(t, grant("operator1", testOrg2, member2))
+	require.True(t, grant("operator1", testOrg2, member1, member2))
+
+	// 4. the delegation revoked
+	chainConfig.ResourcePolicies = chainConfig.ResourcePolicies[:len(chainConfig.ResourcePolicies)-3]
+	require.Nil(t, provider.Watch(chainConfig))
+	require.False(t, verify(member1, freeze, certManage, certsFreeze, noon))
+}
@@ -0,0 +1,570 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chainmaker.org/chainmaker/localconf/v2"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/pb-go/v2/config"
+	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+// AuthTypeDID is the auth type of the permissioned blockchains identifying the members by the DIDs:
+//   - the trust roots of an org are the DID documents of its issuers, who are the admins of the org
+//   - the other members carry the verifiable credentials issued by the issuers, which grant the roles of the org
+//     to the DIDs, their DID documents are anchored in the state of the contract DIDRegistryContractName
+//   - the nodes are identified by the public keys as the permissionedWithKey auth type does
+const AuthTypeDID = "did"
+
+const (
+	// DIDRegistryContractName is the contract whose state keeps the DID documents by the DIDs, and the ids of
+	// the revoked credentials prefixed by didRevocationKeyPrefix. It is served by the node, see
+	// ExecuteDIDRegistry, and only the methods below are accepted by the access control:
+	//   - DIDRegistryMethodAnchor anchors the DOCUMENT of the DID, a new DID is anchored by an admin, and
+	//     the document anchored is rotated only by the DID signing by a key of the document anchored
+	//   - DIDRegistryMethodRevoke revokes the CREDENTIAL, by an admin of the org it grants the role of
+	DIDRegistryContractName = "DID_REGISTRY"
+	DIDRegistryMethodAnchor = "ANCHOR_DID"
+	DIDRegistryMethodRevoke = "REVOKE_CREDENTIAL"
+
+	didRegistryParamDID        = "DID"
+	didRegistryParamDocument   = "DOCUMENT"
+	didRegistryParamCredential = "CREDENTIAL"
+
+	didRevocationKeyPrefix = "revoked:"
+)
+
+var _ protocol.AccessControlProvider = (*didACProvider)(nil)
+
+var NilDIDACProvider ACProvider = (*didACProvider)(nil)
+
+type didACProvider struct {
+	acService *accessControlService
+
+	// local org id
+	localOrg string
+
+	// issuers of the orgs in the trust roots, DID -> *didIssuer
+	issuers *sync.Map
+
+	// consensus list identified by the node ids
+	consensusMember *sync.Map
+}
+
+type didIssuer struct {
+	orgId string
+	keys  []*didKey
+}
+
+func (dp *didACProvider) NewACProvider(chainConf protocol.ChainConf, localOrgId string,
+	store protocol.BlockchainStore, log protocol.Logger) (protocol.AccessControlProvider, error) {
+	didProvider, err := newDIDACProvider(chainConf.ChainConfig(), localOrgId, store, log)
+	if err != nil {
+		return nil, err
+	}
+	chainConf.AddWatch(didProvider)
+	chainConf.AddVmWatch(didProvider)
+	return didProvider, nil
+}
+
+func newDIDACProvider(chainConfig *config.ChainConfig, localOrgId string,
+	store protocol.BlockchainStore, log protocol.Logger) (*didACProvider, error) {
+	didProvider := &didACProvider{
+		issuers:         &sync.Map{},
+		consensusMember: &sync.Map{},
+		localOrg:        localOrgId,
+	}
+	chainConfig.AuthType = strings.ToLower(chainConfig.AuthType)
+	didProvider.acService = initAccessControlService(chainConfig.GetCrypto().Hash,
+		chainConfig.AuthType, store, log)
+
+	if err := didProvider.initIssuers(chainConfig.TrustRoots); err != nil {
+		return nil, err
+	}
+	didProvider.initConsensusMember(chainConfig.Consensus.Nodes)
+	didProvider.acService.initResourcePolicy(chainConfig.ResourcePolicies, localOrgId)
+	didProvider.acService.setTxTimestampVerify(chainConfig.GetBlock().GetTxTimestampVerify())
+
+	return didProvider, nil
+}
+
+func (dp *didACProvider) initIssuers(trustRootList []*config.TrustRootConfig) error {
+	var (
+		tempSyncMap, orgList sync.Map
+		orgNum               int32
+	)
+	for _, trustRoot := range trustRootList {
+		for _, root := range trustRoot.Root {
+			doc, keys, err := parseDIDDocument([]byte(root))
+			if err != nil {
+				return fmt.Errorf("init issuers failed: %v", err)
+			}
+			if issuer, ok := tempSyncMap.Load(doc.Id); ok && issuer.(*didIssuer).orgId != trustRoot.OrgId {
+				return fmt.Errorf("init issuers failed: issuer [%s] belongs to more than one org", doc.Id)
+			}
+			tempSyncMap.Store(doc.Id, &didIssuer{orgId: trustRoot.OrgId, keys: keys})
+		}
+
+		if _, ok := orgList.Load(trustRoot.OrgId); !ok {
+			orgList.Store(trustRoot.OrgId, struct{}{})
+			orgNum++
+		}
+	}
+	atomic.StoreInt32(&dp.acService.orgNum, orgNum)
+	dp.acService.orgList = &orgList
+	dp.issuers = &tempSyncMap
+	return nil
+}
+
+func (dp *didACProvider) initConsensusMember(consensusConf []*config.OrgConfig) {
+	var tempSyncMap sync.Map
+	for _, conf := range consensusConf {
+		for _, node := range conf.NodeId {
+			tempSyncMap.Store(node, &consensusMemberModel{
+				nodeId: node,
+				orgId:  conf.OrgId,
+			})
+		}
+	}
+	dp.consensusMember = &tempSyncMap
+}
+
+func (dp *didACProvider) Module() string {
+	return ModuleNameAccessControl
+}
+
+func (dp *didACProvider) Watch(chainConfig *config.ChainConfig) error {
+	dp.acService.hashType = chainConfig.GetCrypto().GetHash()
+
+	if err := dp.initIssuers(chainConfig.TrustRoots); err != nil {
+		return fmt.Errorf("update chainconfig error: %s", err.Error())
+	}
+	dp.initConsensusMember(chainConfig.Consensus.Nodes)
+	dp.acService.initResourcePolicy(chainConfig.ResourcePolicies, dp.localOrg)
+	dp.acService.setTxTimestampVerify(chainConfig.GetBlock().GetTxTimestampVerify())
+
+	dp.acService.memberCache.Clear()
+
+	return nil
+}
+
+func (dp *didACProvider) ContractNames() []string {
+	return []string{DIDRegistryContractName, syscontract.SystemContract_PUBKEY_MANAGE.String()}
+}
+
+// Callback drops the members cached once the DID documents, the revoked credentials or the public keys
+// of the nodes change, so the rotated keys take effect in the next block
+func (dp *didACProvider) Callback(contractName string, _ []byte) error {
+	switch contractName {
+	case DIDRegistryContractName, syscontract.SystemContract_PUBKEY_MANAGE.String():
+		dp.acService.memberCache.Clear()
+		return nil
+	default:
+		dp.acService.log.Debugf("unwatched smart contract [%s]", contractName)
+		return nil
+	}
+}
+
+// resolveDID returns the keys of the DID, from the trust roots for the issuers, or from the DID documents
+// anchored on chain for the others
+func (dp *didACProvider) resolveDID(did string) ([]*didKey, error) {
+	if issuer, ok := dp.issuers.Load(did); ok {
+		return issuer.(*didIssuer).keys, nil
+	}
+	if dp.acService.dataStore == nil {
+		return nil, fmt.Errorf("resolve DID [%s] failed: no store", did)
+	}
+	docBytes, err := dp.acService.dataStore.ReadObject(DIDRegistryContractName, []byte(did))
+	if err != nil {
+		return nil, fmt.Errorf("resolve DID [%s] failed: %v", did, err)
+	}
+	if docBytes == nil {
+		return nil, fmt.Errorf("resolve DID [%s] failed: the DID document is not anchored on chain", did)
+	}
+	doc, keys, err := parseDIDDocument(docBytes)
+	if err != nil {
+		return nil, fmt.Errorf("resolve DID [%s] failed: %v", did, err)
+	}
+	if doc.Id != did {
+		return nil, fmt.Errorf("resolve DID [%s] failed: the DID document is of [%s]", did, doc.Id)
+	}
+	return keys, nil
+}
+
+func (dp *didACProvider) isCredentialRevoked(credentialId string) (bool, error) {
+	if dp.acService.dataStore == nil || credentialId == "" {
+		return false, nil
+	}
+	revoked, err := dp.acService.dataStore.ReadObject(DIDRegistryContractName,
+		[]byte(didRevocationKeyPrefix+credentialId))
+	if err != nil {
+		return false, err
+	}
+	return revoked != nil, nil
+}
+
+// blockTime returns the timestamp of the last block committed, the credentials are valid or not by it,
+// so that all the nodes agree on the members whenever they verify them
+func (dp *didACProvider) blockTime() (time.Time, error) {
+	if dp.acService.dataStore == nil {
+		return time.Now(), nil
+	}
+	lastBlock, err := dp.acService.dataStore.GetLastBlock()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get last block failed: %v", err)
+	}
+	return time.Unix(lastBlock.GetHeader().GetBlockTimestamp(), 0), nil
+}
+
+// newDIDMember creates the member of an issuer by its DID, or the member granted by a verifiable credential
+func (dp *didACProvider) newDIDMember(member *pbac.Member) (protocol.Member, error) {
+	now, err := dp.blockTime()
+	if err != nil {
+		return nil, fmt.Errorf("new DID member failed: %s", err.Error())
+	}
+	memberInfo := string(member.MemberInfo)
+	if cached, ok := dp.acService.lookUpMemberInCache(memberInfo); ok {
+		if dm, ok := cached.member.(*didMember); ok && !dm.expired(now) && dm.orgId == member.OrgId {
+			return dm, nil
+		}
+	}
+
+	dm, err := dp.newDIDMemberFromInfo(member.MemberInfo, now)
+	if err != nil {
+		return nil, fmt.Errorf("new DID member failed: %s", err.Error())
+	}
+	if dm.orgId != member.OrgId && member.OrgId != "" {
+		return nil, fmt.Errorf("new DID member failed: member orgId does not match on chain")
+	}
+	dp.acService.addMemberToCache(memberInfo, &memberCached{member: dm})
+	return dm, nil
+}
+
+func (dp *didACProvider) newDIDMemberFromInfo(memberInfo []byte, now time.Time) (*didMember, error) {
+	did := strings.TrimSpace(string(memberInfo))
+	if strings.HasPrefix(did, didPrefix) {
+		issuer, ok := dp.issuers.Load(did)
+		if !ok {
+			return nil, fmt.Errorf("[%s] is not an issuer in the trust roots, a credential is required", did)
+		}
+		return &didMember{
+			did:        did,
+			orgId:      issuer.(*didIssuer).orgId,
+			role:       protocol.RoleAdmin,
+			keys:       issuer.(*didIssuer).keys,
+			memberInfo: memberInfo,
+		}, nil
+	}
+
+	vc, err := parseVerifiableCredential(memberInfo)
+	if err != nil {
+		return nil, err
+	}
+	issuer, ok := dp.issuers.Load(vc.Issuer)
+	if !ok {
+		return nil, fmt.Errorf("the issuer [%s] of credential [%s] is not in the trust roots", vc.Issuer, vc.Id)
+	}
+	if issuer.(*didIssuer).orgId != vc.CredentialSubject.OrgId {
+		return nil, fmt.Errorf("the issuer [%s] of credential [%s] does not belong to org [%s]",
+			vc.Issuer, vc.Id, vc.CredentialSubject.OrgId)
+	}
+	if err = vc.verifyProof(issuer.(*didIssuer).keys, dp.acService.hashType); err != nil {
+		return nil, err
+	}
+	notBefore, notAfter, err := vc.validPeriod()
+	if err != nil {
+		return nil, err
+	}
+	if now.Before(notBefore) || (!notAfter.IsZero() && now.After(notAfter)) {
+		return nil, fmt.Errorf("credential [%s] is not valid now", vc.Id)
+	}
+	revoked, err := dp.isCredentialRevoked(vc.Id)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, fmt.Errorf("credential [%s] has been revoked", vc.Id)
+	}
+
+	keys, err := dp.resolveDID(vc.CredentialSubject.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &didMember{
+		did:        vc.CredentialSubject.Id,
+		orgId:      vc.CredentialSubject.OrgId,
+		role:       protocol.Role(strings.ToUpper(vc.CredentialSubject.Role)),
+		keys:       keys,
+		memberInfo: memberInfo,
+		expireAt:   notAfter,
+	}, nil
+}
+
+// all-in-one validation for signing members: DID documents/credentials, signature, policies
+func (dp *didACProvider) refinePrincipal(principal protocol.Principal) (protocol.Principal, error) {
+	endorsements := principal.GetEndorsement()
+	msg := principal.GetMessage()
+	refinedEndorsement := dp.refineEndorsements(endorsements, msg)
+	if len(refinedEndorsement) <= 0 {
+		return nil, fmt.Errorf("refine endorsements failed, all endorsers have failed verification")
+	}
+
+	refinedPrincipal, err := dp.CreatePrincipal(principal.GetResourceName(), refinedEndorsement, msg)
+	if err != nil {
+		return nil, fmt.Errorf("create principal failed: [%s]", err.Error())
+	}
+
+	return refinedPrincipal, nil
+}
+
+func (dp *didACProvider) refineEndorsements(endorsements []*common.EndorsementEntry,
+	msg []byte) []*common.EndorsementEntry {
+
+	refinedSigners := map[string]bool{}
+	var refinedEndorsement []*common.EndorsementEntry
+
+	for _, endorsementEntry := range endorsements {
+		endorsement := &common.EndorsementEntry{
+			Signer: &pbac.Member{
+				OrgId:      endorsementEntry.Signer.OrgId,
+				MemberInfo: endorsementEntry.Signer.MemberInfo,
+				MemberType: endorsementEntry.Signer.MemberType,
+			},
+			Signature: endorsementEntry.Signature,
+		}
+
+		remoteMember, err := dp.NewMember(endorsement.Signer)
+		if err != nil {
+			dp.acService.log.Infof("new member failed: [%s]", err.Error())
+			continue
+		}
+
+		if err := remoteMember.Verify(dp.GetHashAlg(), msg, endorsement.Signature); err != nil {
+			dp.acService.log.Infof("signer member verify signature failed: [%s]", err.Error())
+			dp.acService.log.Debugf("information for invalid signature:\norganization: %s\nmember: %s\nmessage: %s\n"+
+				"signature: %s", endorsement.Signer.OrgId, remoteMember.GetMemberId(), hex.Dump(msg),
+				hex.Dump(endorsement.Signature))
+			continue
+		}
+
+		// the members of a DID endorse once whatever credentials they carry
+		if uid := remoteMember.GetUid(); !refinedSigners[uid] {
+			refinedSigners[uid] = true
+			refinedEndorsement = append(refinedEndorsement, endorsement)
+		}
+	}
+	return refinedEndorsement
+}
+
+func (dp *didACProvider) NewMember(member *pbac.Member) (protocol.Member, error) {
+	switch member.MemberType {
+	case pbac.MemberType_DID:
+		return dp.newDIDMember(member)
+	case pbac.MemberType_PUBLIC_KEY:
+		// the admins are the issuers, so no admin is identified by the public key
+		return dp.acService.newPkMember(member, &sync.Map{}, dp.consensusMember)
+	default:
+		return nil, fmt.Errorf("new member failed: the member type [%s] is not supported by auth type %s",
+			member.MemberType, AuthTypeDID)
+	}
+}
+
+// GetHashAlg return hash algorithm the access control provider uses
+func (dp *didACProvider) GetHashAlg() string {
+	return dp.acService.hashType
+}
+
+// ValidateResourcePolicy checks whether the given resource principal is valid
+func (dp *didACProvider) ValidateResourcePolicy(resourcePolicy *config.ResourcePolicy) bool {
+	return dp.acService.validateResourcePolicy(resourcePolicy)
+}
+
+// CreatePrincipalForTargetOrg creates a principal for "SELF" type principal,
+// which needs to convert SELF to a sepecific organization id in one authentication
+func (dp *didACProvider) CreatePrincipalForTargetOrg(resourceName string,
+	endorsements []*common.EndorsementEntry, message []byte,
+	targetOrgId string) (protocol.Principal, error) {
+	return dp.acService.createPrincipalForTargetOrg(resourceName, endorsements, message, targetOrgId)
+}
+
+// CreatePrincipal creates a principal for one time authentication
+func (dp *didACProvider) CreatePrincipal(resourceName string, endorsements []*common.EndorsementEntry,
+	message []byte) (
+	protocol.Principal, error) {
+	return dp.acService.createPrincipal(resourceName, endorsements, message)
+}
+
+func (dp *didACProvider) LookUpPolicy(resourceName string) (*pbac.Policy, error) {
+	return dp.acService.lookUpPolicy(resourceName)
+}
+
+func (dp *didACProvider) LookUpExceptionalPolicy(resourceName string) (*pbac.Policy, error) {
+	return dp.acService.lookUpExceptionalPolicy(resourceName)
+}
+
+func (dp *didACProvider) GetMemberStatus(member *pbac.Member) (pbac.MemberStatus, error) {
+	var err error
+	if member.MemberType == pbac.MemberType_DID {
+		_, err = dp.newDIDMember(member)
+	} else {
+		_, err = dp.acService.newNodePkMember(member, dp.consensusMember)
+	}
+	if err != nil {
+		dp.acService.log.Infof("get member status: %s", err.Error())
+		return pbac.MemberStatus_INVALID, err
+	}
+	return pbac.MemberStatus_NORMAL, nil
+}
+
+func (dp *didACProvider) VerifyRelatedMaterial(verifyType pbac.VerifyType, data []byte) (bool, error) {
+	return false, fmt.Errorf("verify related material failed: the credentials are revoked by contract %s "+
+		"in %s mode", DIDRegistryContractName, AuthTypeDID)
+}
+
+// VerifyPrincipal verifies if the principal for the resource is met
+func (dp *didACProvider) VerifyPrincipal(principal protocol.Principal) (bool, error) {
+
+	if atomic.LoadInt32(&dp.acService.orgNum) <= 0 {
+		return false, fmt.Errorf("authentication failed: empty organization list or trusted node list on this chain")
+	}
+
+	refinedPrincipal, err := dp.refinePrincipal(principal)
+	if err != nil {
+		return false, fmt.Errorf("authentication failed, [%s]", err.Error())
+	}
+
+	if localconf.ChainMakerConfig.DebugConfig.IsSkipAccessControl {
+		return true, nil
+	}
+
+	p, err := dp.acService.lookUpPolicyByResourceName(principal.GetResourceName())
+	if err != nil {
+		return false, fmt.Errorf("authentication failed, [%s]", err.Error())
+	}
+
+	if ok, err := dp.acService.verifyPrincipalPolicy(principal, refinedPrincipal, p); !ok {
+		return false, err
+	}
+	return dp.verifyRegistryTx(principal, refinedPrincipal)
+}
+
+// verifyRegistryTx checks the txs invoking DIDRegistryContractName, see the methods accepted there
+func (dp *didACProvider) verifyRegistryTx(principal, refinedPrincipal protocol.Principal) (bool, error) {
+	resourceName := principal.GetResourceName()
+	if resourceName != common.TxType_INVOKE_CONTRACT.String() &&
+		!strings.HasPrefix(resourceName, DIDRegistryContractName+"-") {
+		return true, nil
+	}
+	payload := &common.Payload{}
+	if err := payload.Unmarshal(principal.GetMessage()); err != nil ||
+		payload.ContractName != DIDRegistryContractName {
+		return true, nil
+	}
+	params := map[string][]byte{}
+	for _, param := range payload.Parameters {
+		params[param.Key] = param.Value
+	}
+	endorsements := refinedPrincipal.GetEndorsement()
+	switch payload.Method {
+	case DIDRegistryMethodAnchor:
+		return dp.verifyAnchor(string(params[didRegistryParamDID]), params[didRegistryParamDocument], endorsements)
+	case DIDRegistryMethodRevoke:
+		vc, err := parseVerifiableCredential(params[didRegistryParamCredential])
+		if err != nil {
+			return false, fmt.Errorf("authentication fail: bad credential to revoke: %v", err)
+		}
+		issuer, ok := dp.issuers.Load(vc.Issuer)
+		if !ok || issuer.(*didIssuer).orgId != vc.CredentialSubject.OrgId {
+			return false, fmt.Errorf("authentication fail: credential [%s] is not issued by org [%s]",
+				vc.Id, vc.CredentialSubject.OrgId)
+		}
+		if !dp.acService.endorsedByAdminOf(vc.CredentialSubject.OrgId, endorsements) {
+			return false, fmt.Errorf("authentication fail: revoking credential [%s] requires an admin of [%s]",
+				vc.Id, vc.CredentialSubject.OrgId)
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("authentication fail: method [%s] of contract %s is not allowed",
+			payload.Method, DIDRegistryContractName)
+	}
+}
+
+// verifyAnchor checks the DID document anchored is of the DID, and it is rotated by the DID with a key of
+// the document anchored before, or anchored for the first time by an admin
+func (dp *didACProvider) verifyAnchor(did string, docBytes []byte,
+	endorsements []*common.EndorsementEntry) (bool, error) {
+	doc, _, err := parseDIDDocument(docBytes)
+	if err != nil {
+		return false, fmt.Errorf("authentication fail: bad DID document: %v", err)
+	}
+	if doc.Id != did {
+		return false, fmt.Errorf("authentication fail: the DID document is of [%s], not [%s]", doc.Id, did)
+	}
+	if _, ok := dp.issuers.Load(did); ok {
+		return false, fmt.Errorf("authentication fail: issuer [%s] is updated in the trust roots", did)
+	}
+	if dp.acService.dataStore == nil {
+		return false, fmt.Errorf("authentication fail: no store to resolve DID [%s]", did)
+	}
+	anchored, err := dp.acService.dataStore.ReadObject(DIDRegistryContractName, []byte(did))
+	if err != nil {
+		return false, fmt.Errorf("authentication fail: resolve DID [%s] failed: %v", did, err)
+	}
+	for _, endorsement := range endorsements {
+		member := dp.acService.getMemberFromCache(endorsement.Signer)
+		if member == nil {
+			continue
+		}
+		// the endorsements are verified by the keys of the document anchored, so the old key signs
+		if anchored != nil && member.GetUid() == did {
+			return true, nil
+		}
+		if anchored == nil && member.GetRole() == protocol.RoleAdmin {
+			return true, nil
+		}
+	}
+	if anchored != nil {
+		return false, fmt.Errorf("authentication fail: rotating DID [%s] requires its key anchored", did)
+	}
+	return false, fmt.Errorf("authentication fail: anchoring DID [%s] requires an admin", did)
+}
+
+// GetValidEndorsements filters all endorsement entries and returns all valid ones
+func (dp *didACProvider) GetValidEndorsements(principal protocol.Principal) (
+	[]*common.EndorsementEntry, error) {
+	if atomic.LoadInt32(&dp.acService.orgNum) <= 0 {
+		return nil, fmt.Errorf("authentication fail: empty organization list or trusted node list on this chain")
+	}
+	refinedPolicy, err := dp.refinePrincipal(principal)
+	if err != nil {
+		return nil, fmt.Errorf("authentication fail, not a member on this chain: [%v]", err)
+	}
+	endorsements := refinedPolicy.GetEndorsement()
+
+	p, err := dp.acService.lookUpPolicyByResourceName(principal.GetResourceName())
+	if err != nil {
+		return nil, fmt.Errorf("authentication fail: [%v]", err)
+	}
+	orgList := map[string]bool{}
+	roleList := map[protocol.Role]bool{}
+	for _, orgRaw := range p.GetOrgList() {
+		orgList[orgRaw] = true
+	}
+	for _, roleRaw := range p.GetRoleList() {
+		roleList[roleRaw] = true
+	}
+	return dp.acService.getValidEndorsements(orgList, roleList, endorsements), nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	logger2 "chainmaker.org/chainmaker/logger/v2"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/pb-go/v2/config"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func testDIDDocument(t *testing.T, did string, sks ...bccrypto.PrivateKey) []byte {
+	doc := &didDocument{Id: did}
+	for i, sk := range sks {
+		pkPEM, err := sk.PublicKey().String()
+		require.Nil(t, err)
+		doc.VerificationMethod = append(doc.VerificationMethod, &didVerificationMethod{
+			Id:           fmt.Sprintf("%s#key-%d", did, i+1),
+			Type:         "PublicKeyPem",
+			Controller:   did,
+			PublicKeyPem: pkPEM,
+		})
+	}
+	docBytes, err := json.Marshal(doc)
+	require.Nil(t, err)
+	return docBytes
+}
+
+func testIssueCredential(t *testing.T, vc *verifiableCredential, issuerKey bccrypto.PrivateKey) []byte {
+	msg, err := vc.signingBytes()
+	require.Nil(t, err)
+	sig, err := issuerKey.SignWithOpts(msg, &bccrypto.SignOpts{
+		Hash: bccrypto.HashAlgoMap[testPKHashType],
+		UID:  bccrypto.CRYPTO_DEFAULT_UID,
+	})
+	require.Nil(t, err)
+	vc.Proof = &verifiableCredentialProof{
+		VerificationMethod: vc.Issuer + "#key-1",
+		ProofValue:         base64.StdEncoding.EncodeToString(sig),
+	}
+	vcBytes, err := json.Marshal(vc)
+	require.Nil(t, err)
+	return vcBytes
+}
+
+func TestDIDACProvider(t *testing.T) {
+	issuerKey, err := asym.PrivateKeyFromPEM([]byte(TestSK5), nil)
+	require.Nil(t, err)
+	aliceKey1, err := asym.PrivateKeyFromPEM([]byte(TestSK9), nil)
+	require.Nil(t, err)
+	aliceKey2, err := asym.PrivateKeyFromPEM([]byte(TestSK10), nil)
+	require.Nil(t, err)
+	issuerDID, aliceDID := "did:cm:org1-issuer", "did:cm:alice"
+
+	// the registry contract keeping the DID documents and the revoked credentials
+	registry := map[string][]byte{aliceDID: testDIDDocument(t, aliceDID, aliceKey1)}
+	ctl := gomock.NewController(t)
+	store := mock.NewMockBlockchainStore(ctl)
+	store.EXPECT().ReadObject(DIDRegistryContractName, gomock.Any()).DoAndReturn(
+		func(_ string, key []byte) ([]byte, error) {
+			return registry[string(key)], nil
+		}).AnyTimes()
+	// the credentials are valid or not by the time of the last block
+	store.EXPECT().GetLastBlock().Return(&common.Block{
+		Header: &common.BlockHeader{BlockTimestamp: time.Now().Unix()}}, nil).AnyTimes()
+
+	chainConfig := proto.Clone(testPermissionedPKChainConfig).(*config.ChainConfig)
+	chainConfig.AuthType = AuthTypeDID
+	chainConfig.TrustRoots = []*config.TrustRootConfig{
+		{OrgId: testOrg1, Root: []string{string(testDIDDocument(t, issuerDID, issuerKey))}},
+	}
+	provider, err := newDIDACProvider(chainConfig, testOrg1, store, logger2.GetLogger(logger2.MODULE_ACCESS))
+	require.Nil(t, err)
+
+	credential := &verifiableCredential{
+		Id:           "urn:credential:1",
+		Type:         []string{"VerifiableCredential"},
+		Issuer:       issuerDID,
+		IssuanceDate: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		CredentialSubject: &didCredentialSubject{
+			Id:    aliceDID,
+			OrgId: testOrg1,
+			Role:  "client",
+		},
+	}
+	alice := &pbac.Member{
+		OrgId:      testOrg1,
+		MemberType: pbac.MemberType_DID,
+		MemberInfo: testIssueCredential(t, credential, issuerKey),
+	}
+	sign := func(signer *pbac.Member, sk bccrypto.PrivateKey, msg []byte) *common.EndorsementEntry {
+		sig, err := sk.SignWithOpts(msg, &bccrypto.SignOpts{
+			Hash: bccrypto.HashAlgoMap[testPKHashType],
+			UID:  bccrypto.CRYPTO_DEFAULT_UID,
+		})
+		require.Nil(t, err)
+		return &common.EndorsementEntry{Signer: signer, Signature: sig}
+	}
+	verify := func(signer *pbac.Member, sk bccrypto.PrivateKey, resourceName string) bool {
+		ok, _ := testVerifyPrincipal(provider, resourceName,
+			[]*common.EndorsementEntry{sign(signer, sk, []byte(testMsg))})
+		return ok
+	}
+	// verifyRegistryTx verifies the tx invoking the registry signed by the key
+	verifyRegistryTx := func(signer *pbac.Member, sk bccrypto.PrivateKey, method string,
+		params ...*common.KeyValuePair) bool {
+		payload, err := (&common.Payload{ContractName: DIDRegistryContractName, Method: method,
+			Parameters: params, TxType: common.TxType_INVOKE_CONTRACT}).Marshal()
+		require.Nil(t, err)
+		principal, err := provider.CreatePrincipal(common.TxType_INVOKE_CONTRACT.String(),
+			[]*common.EndorsementEntry{sign(signer, sk, payload)}, payload)
+		require.Nil(t, err)
+		ok, _ := provider.VerifyPrincipal(principal)
+		return ok
+	}
+
+	// 1. the credential grants the role of the org to the DID, the issuer is an admin of the org
+	member, err := provider.NewMember(alice)
+	require.Nil(t, err)
+	require.Equal(t, aliceDID, member.GetUid())
+	require.Equal(t, protocol.RoleClient, member.GetRole())
+	require.True(t, verify(alice, aliceKey1, common.TxType_INVOKE_CONTRACT.String()))
+	issuer := &pbac.Member{OrgId: testOrg1, MemberType: pbac.MemberType_DID, MemberInfo: []byte(issuerDID)}
+	member, err = provider.NewMember(issuer)
+	require.Nil(t, err)
+	require.Equal(t, protocol.RoleAdmin, member.GetRole())
+	require.True(t, verify(issuer, issuerKey, common.TxType_INVOKE_CONTRACT.String()))
+
+	// 2. the key rotated keeps the identity
+	registry[aliceDID] = testDIDDocument(t, aliceDID, aliceKey2)
+	require.Nil(t, provider.Callback(DIDRegistryContractName, nil))
+	require.True(t, verify(alice, aliceKey2, common.TxType_INVOKE_CONTRACT.String()))
+	require.False(t, verify(alice, aliceKey1, common.TxType_INVOKE_CONTRACT.String()))
+	member, err = provider.NewMember(alice)
+	require.Nil(t, err)
+	require.Equal(t, aliceDID, member.GetUid())
+
+	// 3. the DID document is rotated by the key anchored, and anchored for the first time by an admin
+	aliceKey3, err := asym.PrivateKeyFromPEM([]byte(TestSK4), nil)
+	require.Nil(t, err)
+	anchorParams := []*common.KeyValuePair{
+		{Key: didRegistryParamDID, Value: []byte(aliceDID)},
+		{Key: didRegistryParamDocument, Value: testDIDDocument(t, aliceDID, aliceKey3)},
+	}
+	require.True(t, verifyRegistryTx(alice, aliceKey2, DIDRegistryMethodAnchor, anchorParams...))
+	require.False(t, verifyRegistryTx(alice, aliceKey1, DIDRegistryMethodAnchor, anchorParams...))
+	require.False(t, verifyRegistryTx(issuer, issuerKey, DIDRegistryMethodAnchor, anchorParams...))
+	bobDID := "did:cm:bob"
+	bobParams := []*common.KeyValuePair{
+		{Key: didRegistryParamDID, Value: []byte(bobDID)},
+		{Key: didRegistryParamDocument, Value: testDIDDocument(t, bobDID, aliceKey3)},
+	}
+	require.True(t, verifyRegistryTx(issuer, issuerKey, DIDRegistryMethodAnchor, bobParams...))
+	require.False(t, verifyRegistryTx(alice, aliceKey2, DIDRegistryMethodAnchor, bobParams...))
+	require.False(t, verifyRegistryTx(issuer, issuerKey, DIDRegistryMethodAnchor, bobParams[1], anchorParams[0]))
+	revokeParam := &common.KeyValuePair{Key: didRegistryParamCredential, Value: alice.MemberInfo}
+	require.True(t, verifyRegistryTx(issuer, issuerKey, DIDRegistryMethodRevoke, revokeParam))
+	require.False(t, verifyRegistryTx(alice, aliceKey2, DIDRegistryMethodRevoke, revokeParam))
+	require.False(t, verifyRegistryTx(issuer, issuerKey, "PUT_STATE", anchorParams...))
+	_, err = provider.VerifyRelatedMaterial(pbac.VerifyType_CRL, nil)
+	require.NotNil(t, err)
+
+	// 4. the credentials tampered, of other orgs, expired or revoked are rejected
+	tampered := *credential
+	tampered.CredentialSubject = &didCredentialSubject{Id: aliceDID, OrgId: testOrg1, Role: "admin"}
+	tamperedInfo, err := json.Marshal(&tampered)
+	require.Nil(t, err)
+	_, err = provider.NewMember(&pbac.Member{OrgId: testOrg1, MemberType: pbac.MemberType_DID,
+		MemberInfo: tamperedInfo})
+	require.NotNil(t, err)
+	_, err = provider.NewMember(&pbac.Member{OrgId: testOrg2, MemberType: pbac.MemberType_DID,
+		MemberInfo: alice.MemberInfo})
+	require.NotNil(t, err)
+	expired := *credential
+	expired.ExpirationDate = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	_, err = provider.NewMember(&pbac.Member{OrgId: testOrg1, MemberType: pbac.MemberType_DID,
+		MemberInfo: testIssueCredential(t, &expired, issuerKey)})
+	require.NotNil(t, err)
+	registry[didRevocationKeyPrefix+credential.Id] = []byte("revoked")
+	require.Nil(t, provider.Callback(DIDRegistryContractName, nil))
+	require.False(t, verify(alice, aliceKey2, common.TxType_INVOKE_CONTRACT.String()))
+}
@@ -0,0 +1,238 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+const didPrefix = "did:"
+
+// roles a verifiable credential could grant, the consensus nodes are identified by the public keys
+var didCredentialRoles = map[protocol.Role]bool{
+	protocol.RoleAdmin:      true,
+	protocol.RoleClient:     true,
+	protocol.RoleLight:      true,
+	protocol.RoleCommonNode: true,
+}
+
+// didDocument is the subset of the W3C DID document the keys of a DID are resolved by, e.g.
+//
+//	{
+//	  "id": "did:cm:alice",
+//	  "verificationMethod": [
+//	    {"id": "did:cm:alice#key-1", "type": "PublicKeyPem", "controller": "did:cm:alice", "publicKeyPem": "..."}
+//	  ]
+//	}
+type didDocument struct {
+	Id                 string                   `json:"id"`
+	VerificationMethod []*didVerificationMethod `json:"verificationMethod"`
+}
+
+type didVerificationMethod struct {
+	Id           string `json:"id"`
+	Type         string `json:"type,omitempty"`
+	Controller   string `json:"controller,omitempty"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// verifiableCredential is the W3C verifiable credential which grants the role of an org to its subject,
+// the proof is the signature of the JSON of the credential without the proof, signed by a key of the issuer
+type verifiableCredential struct {
+	Context           []string                   `json:"@context,omitempty"`
+	Id                string                     `json:"id"`
+	Type              []string                   `json:"type,omitempty"`
+	Issuer            string                     `json:"issuer"`
+	IssuanceDate      string                     `json:"issuanceDate,omitempty"`
+	ExpirationDate    string                     `json:"expirationDate,omitempty"`
+	CredentialSubject *didCredentialSubject      `json:"credentialSubject"`
+	Proof             *verifiableCredentialProof `json:"proof,omitempty"`
+}
+
+type didCredentialSubject struct {
+	Id    string `json:"id"`
+	OrgId string `json:"orgId"`
+	Role  string `json:"role"`
+}
+
+type verifiableCredentialProof struct {
+	Type               string `json:"type,omitempty"`
+	Created            string `json:"created,omitempty"`
+	VerificationMethod string `json:"verificationMethod"`
+	// ProofValue is the signature encoded in base64
+	ProofValue string `json:"proofValue"`
+}
+
+// didKey is a public key resolved from a DID document
+type didKey struct {
+	id string
+	pk bccrypto.PublicKey
+}
+
+func parseDIDDocument(docBytes []byte) (*didDocument, []*didKey, error) {
+	doc := &didDocument{}
+	if err := json.Unmarshal(docBytes, doc); err != nil {
+		return nil, nil, fmt.Errorf("invalid DID document: %v", err)
+	}
+	if !strings.HasPrefix(doc.Id, didPrefix) {
+		return nil, nil, fmt.Errorf("invalid DID document: bad DID [%s]", doc.Id)
+	}
+	keys := make([]*didKey, 0, len(doc.VerificationMethod))
+	for _, method := range doc.VerificationMethod {
+		pk, err := asym.PublicKeyFromPEM([]byte(method.PublicKeyPem))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid DID document of [%s]: parse the key [%s] failed: %v",
+				doc.Id, method.Id, err)
+		}
+		keys = append(keys, &didKey{id: method.Id, pk: pk})
+	}
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("invalid DID document of [%s]: no verification method", doc.Id)
+	}
+	return doc, keys, nil
+}
+
+func parseVerifiableCredential(vcBytes []byte) (*verifiableCredential, error) {
+	vc := &verifiableCredential{}
+	if err := json.Unmarshal(vcBytes, vc); err != nil {
+		return nil, fmt.Errorf("invalid verifiable credential: %v", err)
+	}
+	if vc.CredentialSubject == nil || !strings.HasPrefix(vc.CredentialSubject.Id, didPrefix) {
+		return nil, fmt.Errorf("invalid verifiable credential [%s]: bad subject", vc.Id)
+	}
+	if !didCredentialRoles[protocol.Role(strings.ToUpper(vc.CredentialSubject.Role))] {
+		return nil, fmt.Errorf("invalid verifiable credential [%s]: unsupported role [%s]",
+			vc.Id, vc.CredentialSubject.Role)
+	}
+	if vc.Proof == nil || vc.Proof.ProofValue == "" {
+		return nil, fmt.Errorf("invalid verifiable credential [%s]: no proof", vc.Id)
+	}
+	return vc, nil
+}
+
+// signingBytes returns the bytes the proof of the credential signs
+func (vc *verifiableCredential) signingBytes() ([]byte, error) {
+	unsigned := *vc
+	unsigned.Proof = nil
+	return json.Marshal(&unsigned)
+}
+
+// validPeriod returns the period in which the credential is valid, the zero time means no limit
+func (vc *verifiableCredential) validPeriod() (notBefore, notAfter time.Time, err error) {
+	if vc.IssuanceDate != "" {
+		if notBefore, err = time.Parse(time.RFC3339, vc.IssuanceDate); err != nil {
+			return notBefore, notAfter, fmt.Errorf("invalid issuance date of credential [%s]: %v", vc.Id, err)
+		}
+	}
+	if vc.ExpirationDate != "" {
+		if notAfter, err = time.Parse(time.RFC3339, vc.ExpirationDate); err != nil {
+			return notBefore, notAfter, fmt.Errorf("invalid expiration date of credential [%s]: %v", vc.Id, err)
+		}
+	}
+	return notBefore, notAfter, nil
+}
+
+// verifyProof verifies the proof of the credential by the keys of the issuer
+func (vc *verifiableCredential) verifyProof(issuerKeys []*didKey, hashType string) error {
+	msg, err := vc.signingBytes()
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(vc.Proof.ProofValue)
+	if err != nil {
+		return fmt.Errorf("invalid proof of credential [%s]: %v", vc.Id, err)
+	}
+	for _, key := range issuerKeys {
+		if key.id != vc.Proof.VerificationMethod {
+			continue
+		}
+		return verifyWithPublicKey(key.pk, hashType, msg, sig)
+	}
+	return fmt.Errorf("the key [%s] of the proof of credential [%s] is not a key of the issuer [%s]",
+		vc.Proof.VerificationMethod, vc.Id, vc.Issuer)
+}
+
+func verifyWithPublicKey(pk bccrypto.PublicKey, hashType string, msg, sig []byte) error {
+	hash, ok := bccrypto.HashAlgoMap[hashType]
+	if !ok {
+		return fmt.Errorf("verify signature failed: unsupport hash type")
+	}
+	ok, err := pk.VerifyWithOpts(msg, sig, &bccrypto.SignOpts{
+		Hash: hash,
+		UID:  bccrypto.CRYPTO_DEFAULT_UID,
+	})
+	if err != nil {
+		return fmt.Errorf("verify signature failed: [%s]", err.Error())
+	}
+	if !ok {
+		return fmt.Errorf("verify signature failed: invalid signature")
+	}
+	return nil
+}
+
+var _ protocol.Member = (*didMember)(nil)
+
+// didMember is a member identified by a DID, its keys are resolved through the DID document,
+// so the identity is kept across the key rotations and the chains the DID is anchored on
+type didMember struct {
+	did        string
+	orgId      string
+	role       protocol.Role
+	keys       []*didKey
+	memberInfo []byte
+
+	// the expiration of the credential the role is granted by, zero if no limit
+	expireAt time.Time
+}
+
+func (dm *didMember) GetMemberId() string {
+	return dm.did
+}
+
+func (dm *didMember) GetOrgId() string {
+	return dm.orgId
+}
+
+func (dm *didMember) GetRole() protocol.Role {
+	return dm.role
+}
+
+func (dm *didMember) GetUid() string {
+	return dm.did
+}
+
+// Verify verifies the signature by any key of the DID document
+func (dm *didMember) Verify(hashType string, msg []byte, sig []byte) error {
+	var err error
+	for _, key := range dm.keys {
+		if err = verifyWithPublicKey(key.pk, hashType, msg, sig); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("DID member [%s] verify signature failed: %v", dm.did, err)
+}
+
+func (dm *didMember) GetMember() (*pbac.Member, error) {
+	return &pbac.Member{
+		OrgId:      dm.orgId,
+		MemberInfo: dm.memberInfo,
+		MemberType: pbac.MemberType_DID,
+	}, nil
+}
+
+func (dm *didMember) expired(now time.Time) bool {
+	return !dm.expireAt.IsZero() && now.After(dm.expireAt)
+}
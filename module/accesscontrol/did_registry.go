@@ -0,0 +1,69 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"fmt"
+
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+// ExecuteDIDRegistry executes the txs of DIDRegistryContractName, which is served by the node on the chains of
+// AuthTypeDID. The senders are authorized by the access control when the txs are verified, see verifyRegistryTx,
+// the contract keeps the DID documents and the ids of the revoked credentials in its state:
+//   - DIDRegistryMethodAnchor puts the DOCUMENT of the DID by the key DID
+//   - DIDRegistryMethodRevoke puts the id of the tx revoking the CREDENTIAL by the key revoked:<credential id>
+func ExecuteDIDRegistry(txSimContext protocol.TxSimContext, method string, params map[string][]byte) (
+	*common.ContractResult, protocol.ExecOrderTxType, common.TxStatusCode) {
+	switch method {
+	case DIDRegistryMethodAnchor:
+		did := string(params[didRegistryParamDID])
+		doc, _, err := parseDIDDocument(params[didRegistryParamDocument])
+		if err != nil {
+			return didRegistryFailed(err)
+		}
+		if doc.Id != did {
+			return didRegistryFailed(fmt.Errorf("the DID document is of [%s], not [%s]", doc.Id, did))
+		}
+		if err = txSimContext.Put(DIDRegistryContractName, []byte(did),
+			params[didRegistryParamDocument]); err != nil {
+			return didRegistryFailed(err)
+		}
+		return &common.ContractResult{Result: []byte(did)}, protocol.ExecOrderTxTypeNormal,
+			common.TxStatusCode_SUCCESS
+	case DIDRegistryMethodRevoke:
+		vc, err := parseVerifiableCredential(params[didRegistryParamCredential])
+		if err != nil {
+			return didRegistryFailed(err)
+		}
+		if vc.Id == "" {
+			return didRegistryFailed(fmt.Errorf("the credential to revoke has no id"))
+		}
+		key := []byte(didRevocationKeyPrefix + vc.Id)
+		revoked, err := txSimContext.Get(DIDRegistryContractName, key)
+		if err != nil {
+			return didRegistryFailed(err)
+		}
+		if revoked != nil {
+			return didRegistryFailed(fmt.Errorf("credential [%s] is revoked already", vc.Id))
+		}
+		if err = txSimContext.Put(DIDRegistryContractName, key,
+			[]byte(txSimContext.GetTx().Payload.TxId)); err != nil {
+			return didRegistryFailed(err)
+		}
+		return &common.ContractResult{Result: []byte(vc.Id)}, protocol.ExecOrderTxTypeNormal,
+			common.TxStatusCode_SUCCESS
+	default:
+		return didRegistryFailed(fmt.Errorf("unknown method %s of %s", method, DIDRegistryContractName))
+	}
+}
+
+func didRegistryFailed(err error) (*common.ContractResult, protocol.ExecOrderTxType, common.TxStatusCode) {
+	return &common.ContractResult{Code: 1, Message: err.Error()}, protocol.ExecOrderTxTypeNormal,
+		common.TxStatusCode_CONTRACT_FAIL
+}
@@ -0,0 +1,158 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	logger2 "chainmaker.org/chainmaker/logger/v2"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/pb-go/v2/config"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDIDRegistry anchors, rotates and revokes through the registry served by the node, the txs are verified by
+// the access control, executed by ExecuteDIDRegistry and committed to the state the access control resolves
+func TestDIDRegistry(t *testing.T) {
+	issuerKey, err := asym.PrivateKeyFromPEM([]byte(TestSK5), nil)
+	require.Nil(t, err)
+	bobKey1, err := asym.PrivateKeyFromPEM([]byte(TestSK9), nil)
+	require.Nil(t, err)
+	bobKey2, err := asym.PrivateKeyFromPEM([]byte(TestSK10), nil)
+	require.Nil(t, err)
+	issuerDID, bobDID := "did:cm:org1-issuer", "did:cm:bob"
+
+	// the committed state of the registry
+	registry := map[string][]byte{}
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+	store := mock.NewMockBlockchainStore(ctl)
+	store.EXPECT().ReadObject(DIDRegistryContractName, gomock.Any()).DoAndReturn(
+		func(_ string, key []byte) ([]byte, error) {
+			return registry[string(key)], nil
+		}).AnyTimes()
+	store.EXPECT().GetLastBlock().Return(&common.Block{
+		Header: &common.BlockHeader{BlockTimestamp: time.Now().Unix()}}, nil).AnyTimes()
+
+	chainConfig := proto.Clone(testPermissionedPKChainConfig).(*config.ChainConfig)
+	chainConfig.AuthType = AuthTypeDID
+	chainConfig.TrustRoots = []*config.TrustRootConfig{
+		{OrgId: testOrg1, Root: []string{string(testDIDDocument(t, issuerDID, issuerKey))}},
+	}
+	provider, err := newDIDACProvider(chainConfig, testOrg1, store, logger2.GetLogger(logger2.MODULE_ACCESS))
+	require.Nil(t, err)
+
+	issuer := &pbac.Member{OrgId: testOrg1, MemberType: pbac.MemberType_DID, MemberInfo: []byte(issuerDID)}
+	credential := testIssueCredential(t, &verifiableCredential{
+		Id:           "urn:credential:bob",
+		Issuer:       issuerDID,
+		IssuanceDate: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		CredentialSubject: &didCredentialSubject{
+			Id:    bobDID,
+			OrgId: testOrg1,
+			Role:  "client",
+		},
+	}, issuerKey)
+	bob := &pbac.Member{OrgId: testOrg1, MemberType: pbac.MemberType_DID, MemberInfo: credential}
+
+	txNum := 0
+	// invoke verifies the tx invoking the registry signed by the key, then executes and commits it
+	invoke := func(signer *pbac.Member, sk bccrypto.PrivateKey, method string, params map[string][]byte) error {
+		txNum++
+		tx := &common.Transaction{Payload: &common.Payload{ChainId: chainConfig.ChainId,
+			TxId: fmt.Sprintf("tx%d", txNum), TxType: common.TxType_INVOKE_CONTRACT,
+			ContractName: DIDRegistryContractName, Method: method}}
+		for key, value := range params {
+			tx.Payload.Parameters = append(tx.Payload.Parameters, &common.KeyValuePair{Key: key, Value: value})
+		}
+		payload, err := tx.Payload.Marshal()
+		require.Nil(t, err)
+		sig, err := sk.SignWithOpts(payload, &bccrypto.SignOpts{
+			Hash: bccrypto.HashAlgoMap[testPKHashType],
+			UID:  bccrypto.CRYPTO_DEFAULT_UID,
+		})
+		require.Nil(t, err)
+		principal, err := provider.CreatePrincipal(common.TxType_INVOKE_CONTRACT.String(),
+			[]*common.EndorsementEntry{{Signer: signer, Signature: sig}}, payload)
+		require.Nil(t, err)
+		if ok, err := provider.VerifyPrincipal(principal); !ok {
+			return fmt.Errorf("verify tx failed, %v", err)
+		}
+
+		writes := map[string][]byte{}
+		txSimContext := mock.NewMockTxSimContext(ctl)
+		txSimContext.EXPECT().GetTx().Return(tx).AnyTimes()
+		txSimContext.EXPECT().Get(DIDRegistryContractName, gomock.Any()).DoAndReturn(
+			func(_ string, key []byte) ([]byte, error) {
+				return registry[string(key)], nil
+			}).AnyTimes()
+		txSimContext.EXPECT().Put(DIDRegistryContractName, gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ string, key, value []byte) error {
+				writes[string(key)] = value
+				return nil
+			}).AnyTimes()
+		result, _, code := ExecuteDIDRegistry(txSimContext, method, params)
+		if code != common.TxStatusCode_SUCCESS {
+			return fmt.Errorf("execute tx failed, %s", result.Message)
+		}
+		for key, value := range writes {
+			registry[key] = value
+		}
+		// the access control drops the members cached once the block is committed
+		return provider.Callback(DIDRegistryContractName, nil)
+	}
+	verify := func(sk bccrypto.PrivateKey) bool {
+		sig, err := sk.SignWithOpts([]byte(testMsg), &bccrypto.SignOpts{
+			Hash: bccrypto.HashAlgoMap[testPKHashType],
+			UID:  bccrypto.CRYPTO_DEFAULT_UID,
+		})
+		require.Nil(t, err)
+		ok, _ := testVerifyPrincipal(provider, common.TxType_INVOKE_CONTRACT.String(),
+			[]*common.EndorsementEntry{{Signer: bob, Signature: sig}})
+		return ok
+	}
+	anchor := func(sk bccrypto.PrivateKey) map[string][]byte {
+		return map[string][]byte{didRegistryParamDID: []byte(bobDID),
+			didRegistryParamDocument: testDIDDocument(t, bobDID, sk)}
+	}
+
+	// 1. the DID of bob is anchored by an admin, then the credential of bob is valid
+	_, err = provider.NewMember(bob)
+	require.NotNil(t, err)
+	require.NotNil(t, invoke(bob, bobKey1, DIDRegistryMethodAnchor, anchor(bobKey1)))
+	require.Nil(t, invoke(issuer, issuerKey, DIDRegistryMethodAnchor, anchor(bobKey1)))
+	require.True(t, verify(bobKey1))
+
+	// 2. bob rotates the key by the key anchored
+	require.NotNil(t, invoke(bob, bobKey2, DIDRegistryMethodAnchor, anchor(bobKey2)))
+	require.Nil(t, invoke(bob, bobKey1, DIDRegistryMethodAnchor, anchor(bobKey2)))
+	require.False(t, verify(bobKey1))
+	require.True(t, verify(bobKey2))
+	require.NotNil(t, invoke(bob, bobKey1, DIDRegistryMethodAnchor, anchor(bobKey1)))
+
+	// 3. the credential of bob is revoked by an admin, and only once
+	revoke := map[string][]byte{didRegistryParamCredential: credential}
+	require.NotNil(t, invoke(bob, bobKey2, DIDRegistryMethodRevoke, revoke))
+	require.Nil(t, invoke(issuer, issuerKey, DIDRegistryMethodRevoke, revoke))
+	require.Equal(t, "tx7", string(registry[didRevocationKeyPrefix+"urn:credential:bob"]))
+	require.False(t, verify(bobKey2))
+	require.NotNil(t, invoke(issuer, issuerKey, DIDRegistryMethodRevoke, revoke))
+
+	// 4. the document of another DID is not anchored
+	mismatched := anchor(bobKey2)
+	mismatched[didRegistryParamDID] = []byte("did:cm:carol")
+	require.NotNil(t, invoke(issuer, issuerKey, DIDRegistryMethodAnchor, mismatched))
+	require.Nil(t, registry["did:cm:carol"])
+}
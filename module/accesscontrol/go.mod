@@ -11,6 +11,10 @@ require (
 	chainmaker.org/chainmaker/utils/v2 v2.1.0
 	github.com/gogo/protobuf v1.3.2
 	github.com/golang/mock v1.6.0
+	github.com/mitchellh/mapstructure v1.4.2
 	github.com/mr-tron/base58 v1.2.0
+	github.com/prometheus/client_golang v1.11.0
+	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
 )
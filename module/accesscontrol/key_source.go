@@ -0,0 +1,123 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"fmt"
+	"strings"
+
+	"chainmaker.org/chainmaker/common/v2/cert"
+	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	"chainmaker.org/chainmaker/localconf/v2"
+)
+
+const (
+	// keySourceConfigKey is the top-level section of KeySourceConfig in chainmaker.yml
+	keySourceConfigKey = "key_source"
+
+	defaultKMSTimeout = 10
+)
+
+// The providers of the private keys of the node
+const (
+	// KeySourceFile reads the private keys from the PEM files, or the keystore JSONs encrypting them
+	KeySourceFile = "file"
+	// KeySourcePKCS11 keeps the private keys in the HSM configured by node.pkcs11,
+	// the key files hold the key ids
+	KeySourcePKCS11 = "pkcs11"
+	// KeySourceKMS keeps the private keys in a KMS, the key files hold the key ids
+	KeySourceKMS = "kms"
+)
+
+// KeySourceConfig selects where the private keys of the node are kept
+type KeySourceConfig struct {
+	// Provider is file, pkcs11 or kms, pkcs11 if node.pkcs11 is enabled and file otherwise by default
+	Provider string     `mapstructure:"provider"`
+	KMS      *KMSConfig `mapstructure:"kms"`
+	// PassphraseFile holds the passphrase of the encrypted key files, readable by the node only
+	PassphraseFile string `mapstructure:"passphrase_file"`
+}
+
+// KMSConfig is the config of the KMS keeping the private keys
+type KMSConfig struct {
+	// Endpoint is the base url of the KMS, such as https://kms.example.com
+	Endpoint string `mapstructure:"endpoint"`
+	// AccessToken is sent as the bearer token of the requests
+	AccessToken string `mapstructure:"access_token"`
+	// CACertFile verifies the TLS certificate of the KMS, the system roots are used if not set
+	CACertFile string `mapstructure:"ca_cert_file"`
+	// Timeout is the seconds to wait for the KMS
+	Timeout int `mapstructure:"timeout"`
+}
+
+// LoadNetTLSPrivateKey loads the net tls key of the node from net.tls.priv_key_file, which is a PEM file, or a
+// keystore JSON decrypted by the passphrase unlocked at startup. The net providers sign the TLS handshakes by a
+// PEM key only, so the net tls key is always read from the file, whatever source keeps the signing keys.
+func LoadNetTLSPrivateKey() (bccrypto.PrivateKey, error) {
+	keyFile := localconf.ChainMakerConfig.NetConfig.TLSConfig.PrivKeyFile
+	keyBytes, err := ReadPrivateKeyFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := asym.PrivateKeyFromPEM(keyBytes, nil)
+	if err != nil {
+		return nil, fmt.Errorf("load net tls key file[%s] failed, %v", keyFile, err)
+	}
+	return key, nil
+}
+
+func loadKeySourceConfig() (*KeySourceConfig, error) {
+	conf := &KeySourceConfig{}
+	if _, err := LoadNodeConfigSection(keySourceConfigKey, conf); err != nil {
+		return nil, err
+	}
+	conf.Provider = strings.ToLower(conf.Provider)
+	if conf.Provider == "" {
+		conf.Provider = KeySourceFile
+		if localconf.ChainMakerConfig.NodeConfig.P11Config.Enabled {
+			conf.Provider = KeySourcePKCS11
+		}
+	}
+	return conf, nil
+}
+
+// parsePrivateKey loads the private key from the key source configured, keyBytes is the PEM of the key
+// for the file source, or the id of the key for the pkcs11 and kms sources
+func parsePrivateKey(keyBytes []byte, password string) (bccrypto.PrivateKey, error) {
+	conf, err := loadKeySourceConfig()
+	if err != nil {
+		return nil, err
+	}
+	return parsePrivateKeyWithConfig(conf, keyBytes, password)
+}
+
+func parsePrivateKeyWithConfig(conf *KeySourceConfig, keyBytes []byte,
+	password string) (bccrypto.PrivateKey, error) {
+	switch conf.Provider {
+	case KeySourceFile:
+		return asym.PrivateKeyFromPEM(keyBytes, []byte(password))
+	case KeySourcePKCS11:
+		p11Handle, err := getP11Handle()
+		if err != nil {
+			return nil, err
+		}
+		return cert.ParseP11PrivKey(p11Handle, keyBytes)
+	case KeySourceKMS:
+		if conf.KMS == nil || conf.KMS.Endpoint == "" {
+			return nil, fmt.Errorf("the endpoint of %s.kms is not set", keySourceConfigKey)
+		}
+		sk, err := newKMSPrivateKey(conf.KMS, strings.TrimSpace(string(keyBytes)))
+		if err != nil {
+			return nil, err
+		}
+		return sk, nil
+	default:
+		return nil, fmt.Errorf("unsupported key source [%s], should be one of %s, %s or %s",
+			conf.Provider, KeySourceFile, KeySourcePKCS11, KeySourceKMS)
+	}
+}
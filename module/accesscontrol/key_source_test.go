@@ -0,0 +1,139 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
+	"chainmaker.org/chainmaker/localconf/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// defaultSoftHSMLibrary is the SoftHSM library of the debian packages, SOFTHSM2_LIB overrides it
+const defaultSoftHSMLibrary = "/usr/lib/softhsm/libsofthsm2.so"
+
+func TestLoadKeySourceConfig(t *testing.T) {
+	preP11 := localconf.ChainMakerConfig.NodeConfig.P11Config.Enabled
+	defer func() {
+		localconf.ChainMakerConfig.NodeConfig.P11Config.Enabled = preP11
+	}()
+	localconf.ChainMakerConfig.NodeConfig.P11Config.Enabled = false
+
+	// the section nested in storage is ignored
+	useNodeConfig(t, "storage:\n  key_source:\n    provider: kms\n")
+	conf, err := loadKeySourceConfig()
+	require.Nil(t, err)
+	require.Equal(t, KeySourceFile, conf.Provider)
+
+	useNodeConfig(t, "key_source:\n  provider: KMS\n  kms:\n    endpoint: https://127.0.0.1:8443\n")
+	conf, err = loadKeySourceConfig()
+	require.Nil(t, err)
+	require.Equal(t, KeySourceKMS, conf.Provider)
+	require.Equal(t, "https://127.0.0.1:8443", conf.KMS.Endpoint)
+}
+
+func TestLoadNetTLSPrivateKey(t *testing.T) {
+	preKeyFile := localconf.ChainMakerConfig.NetConfig.TLSConfig.PrivKeyFile
+	defer func() {
+		localconf.ChainMakerConfig.NetConfig.TLSConfig.PrivKeyFile = preKeyFile
+	}()
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	der, err := x509.MarshalECPrivateKey(sk)
+	require.Nil(t, err)
+	keyFile := filepath.Join(t.TempDir(), "node.key")
+	require.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}),
+		0600))
+
+	// the net tls key is read from the PEM file whatever source keeps the signing keys
+	useNodeConfig(t, "key_source:\n  provider: kms\n")
+	localconf.ChainMakerConfig.NetConfig.TLSConfig.PrivKeyFile = keyFile
+	key, err := LoadNetTLSPrivateKey()
+	require.Nil(t, err)
+	require.Equal(t, sk.D, key.ToStandardKey().(*ecdsa.PrivateKey).D)
+
+	localconf.ChainMakerConfig.NetConfig.TLSConfig.PrivKeyFile = filepath.Join(t.TempDir(), "missing.key")
+	_, err = LoadNetTLSPrivateKey()
+	require.NotNil(t, err)
+}
+
+// TestParsePrivateKey_SoftHSM signs by a key generated in a SoftHSM token, it is skipped if
+// SoftHSM and pkcs11-tool of OpenSC are not installed
+func TestParsePrivateKey_SoftHSM(t *testing.T) {
+	library := os.Getenv("SOFTHSM2_LIB")
+	if library == "" {
+		library = defaultSoftHSMLibrary
+	}
+	if _, err := os.Stat(library); err != nil {
+		t.Skipf("SoftHSM library %s not found", library)
+	}
+	for _, tool := range []string{"softhsm2-util", "pkcs11-tool"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found", tool)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "softhsm")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	tokenDir := filepath.Join(dir, "tokens")
+	require.Nil(t, os.Mkdir(tokenDir, 0700))
+	confFile := filepath.Join(dir, "softhsm2.conf")
+	require.Nil(t, ioutil.WriteFile(confFile, []byte(fmt.Sprintf("directories.tokendir = %s\n", tokenDir)), 0600))
+	preConf, hasConf := os.LookupEnv("SOFTHSM2_CONF")
+	require.Nil(t, os.Setenv("SOFTHSM2_CONF", confFile))
+	defer func() {
+		if hasConf {
+			_ = os.Setenv("SOFTHSM2_CONF", preConf)
+		} else {
+			_ = os.Unsetenv("SOFTHSM2_CONF")
+		}
+	}()
+
+	const label, pin, keyId = "chainmaker-test", "1234", "node1"
+	run := func(name string, args ...string) {
+		out, err := exec.Command(name, args...).CombinedOutput()
+		require.Nil(t, err, "%s: %s", name, out)
+	}
+	run("softhsm2-util", "--init-token", "--free", "--label", label, "--pin", pin, "--so-pin", pin)
+	// the key is found by its id or label, both are the key id
+	run("pkcs11-tool", "--module", library, "--token-label", label, "--login", "--pin", pin,
+		"--keypairgen", "--key-type", "EC:prime256v1", "--label", keyId, "--id", hex.EncodeToString([]byte(keyId)))
+
+	preP11Config := localconf.ChainMakerConfig.NodeConfig.P11Config
+	defer func() {
+		localconf.ChainMakerConfig.NodeConfig.P11Config = preP11Config
+	}()
+	localconf.ChainMakerConfig.NodeConfig.P11Config.Enabled = true
+	localconf.ChainMakerConfig.NodeConfig.P11Config.Library = library
+	localconf.ChainMakerConfig.NodeConfig.P11Config.Label = label
+	localconf.ChainMakerConfig.NodeConfig.P11Config.Password = pin
+	localconf.ChainMakerConfig.NodeConfig.P11Config.SessionCacheSize = 10
+	localconf.ChainMakerConfig.NodeConfig.P11Config.Hash = "SHA256"
+
+	sk, err := parsePrivateKeyWithConfig(&KeySourceConfig{Provider: KeySourcePKCS11}, []byte(keyId), "")
+	require.Nil(t, err)
+	msg := []byte("block hash")
+	opts := &bccrypto.SignOpts{Hash: bccrypto.HASH_TYPE_SHA256, UID: bccrypto.CRYPTO_DEFAULT_UID}
+	sig, err := sk.SignWithOpts(msg, opts)
+	require.Nil(t, err)
+	ok, err := sk.PublicKey().VerifyWithOpts(msg, sig, opts)
+	require.Nil(t, err)
+	require.True(t, ok)
+}
@@ -0,0 +1,212 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// The encrypted key files of the node are keystore JSONs, which encrypt the PEM of the private keys by a
+// passphrase with scrypt and AES-256-GCM. The passphrase unlocking them at startup is read from the env
+// variable, the passphrase file, or the prompt of `chainmaker start`.
+const (
+	// KeyStorePassphraseEnv is the env variable of the passphrase of the encrypted key files
+	KeyStorePassphraseEnv = "CHAINMAKER_KEY_PASSPHRASE"
+	// KeyStorePassphraseFileEnv is the env variable of the file holding the passphrase of the encrypted key files
+	KeyStorePassphraseFileEnv = "CHAINMAKER_KEY_PASSPHRASE_FILE"
+
+	keyStoreVersion = 1
+	keyStoreKDF     = "scrypt"
+	keyStoreCipher  = "aes-256-gcm"
+	keyStoreKeyLen  = 32
+	keyStoreSaltLen = 32
+)
+
+// the scrypt params of encrypting the keys, about 64 MB and 0.2 second on a server
+var (
+	keyStoreScryptN = 1 << 16
+	keyStoreScryptR = 8
+	keyStoreScryptP = 1
+)
+
+var (
+	// ErrKeyStoreLocked is returned when an encrypted key file is read before the passphrase is unlocked
+	ErrKeyStoreLocked = errors.New("the key file is encrypted but the key store is not unlocked")
+
+	keyStoreLock       sync.RWMutex
+	keyStorePassphrase *string
+)
+
+type keyStoreJSON struct {
+	Version    int                `json:"version"`
+	KDF        string             `json:"kdf"`
+	KDFParams  keyStoreScryptJSON `json:"kdfparams"`
+	Cipher     string             `json:"cipher"`
+	Nonce      string             `json:"nonce"`
+	CipherText string             `json:"ciphertext"`
+}
+
+type keyStoreScryptJSON struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt string `json:"salt"`
+}
+
+// EncryptKeyStore encrypts the PEM of a private key into a keystore JSON by the passphrase
+func EncryptKeyStore(keyPEM []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty passphrase")
+	}
+	salt := make([]byte, keyStoreSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	params := keyStoreScryptJSON{N: keyStoreScryptN, R: keyStoreScryptR, P: keyStoreScryptP,
+		Salt: hex.EncodeToString(salt)}
+	aead, err := keyStoreAEAD(passphrase, params, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&keyStoreJSON{
+		Version:    keyStoreVersion,
+		KDF:        keyStoreKDF,
+		KDFParams:  params,
+		Cipher:     keyStoreCipher,
+		Nonce:      hex.EncodeToString(nonce),
+		CipherText: hex.EncodeToString(aead.Seal(nil, nonce, keyPEM, nil)),
+	}, "", "  ")
+}
+
+// DecryptKeyStore decrypts a keystore JSON into the PEM of the private key by the passphrase
+func DecryptKeyStore(keyStore []byte, passphrase string) ([]byte, error) {
+	ks := &keyStoreJSON{}
+	if err := json.Unmarshal(keyStore, ks); err != nil {
+		return nil, fmt.Errorf("invalid key store, %v", err)
+	}
+	if ks.Version != keyStoreVersion || ks.KDF != keyStoreKDF || ks.Cipher != keyStoreCipher {
+		return nil, fmt.Errorf("unsupported key store of version %d, kdf %s and cipher %s",
+			ks.Version, ks.KDF, ks.Cipher)
+	}
+	salt, err := hex.DecodeString(ks.KDFParams.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt of key store, %v", err)
+	}
+	nonce, err := hex.DecodeString(ks.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce of key store, %v", err)
+	}
+	cipherText, err := hex.DecodeString(ks.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cipher text of key store, %v", err)
+	}
+	aead, err := keyStoreAEAD(passphrase, ks.KDFParams, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce size %d of key store", len(nonce))
+	}
+	keyPEM, err := aead.Open(nil, nonce, cipherText, nil)
+	if err != nil {
+		return nil, errors.New("decrypt key store failed, wrong passphrase or corrupted key store")
+	}
+	return keyPEM, nil
+}
+
+// IsKeyStore returns true if the content of a key file is a keystore JSON rather than a PEM
+func IsKeyStore(content []byte) bool {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 || content[0] != '{' {
+		return false
+	}
+	ks := &keyStoreJSON{}
+	return json.Unmarshal(content, ks) == nil && ks.KDF != "" && ks.CipherText != ""
+}
+
+// UnlockKeyStore sets the passphrase decrypting the encrypted key files read by ReadPrivateKeyFile
+func UnlockKeyStore(passphrase string) {
+	keyStoreLock.Lock()
+	defer keyStoreLock.Unlock()
+	keyStorePassphrase = &passphrase
+}
+
+// ReadPrivateKeyFile reads the PEM of the private key in the key file, the keystore JSON is decrypted by the
+// passphrase of UnlockKeyStore. The content of the key files of the pkcs11 and kms key sources is returned as is.
+func ReadPrivateKeyFile(keyFile string) ([]byte, error) {
+	content, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if !IsKeyStore(content) {
+		return content, nil
+	}
+	keyStoreLock.RLock()
+	passphrase := keyStorePassphrase
+	keyStoreLock.RUnlock()
+	if passphrase == nil {
+		return nil, fmt.Errorf("read key file %s failed, %v", keyFile, ErrKeyStoreLocked)
+	}
+	keyPEM, err := DecryptKeyStore(content, *passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("read key file %s failed, %v", keyFile, err)
+	}
+	return keyPEM, nil
+}
+
+// LoadKeyStorePassphrase loads the passphrase of the encrypted key files from the env variable, the file in
+// the env variable, or the passphrase file of the key source config in order, false if none of them is set
+func LoadKeyStorePassphrase() (string, bool, error) {
+	if passphrase, ok := os.LookupEnv(KeyStorePassphraseEnv); ok {
+		return passphrase, true, nil
+	}
+	passphraseFile := os.Getenv(KeyStorePassphraseFileEnv)
+	if passphraseFile == "" {
+		conf, err := loadKeySourceConfig()
+		if err != nil {
+			return "", false, err
+		}
+		passphraseFile = conf.PassphraseFile
+	}
+	if passphraseFile == "" {
+		return "", false, nil
+	}
+	content, err := ioutil.ReadFile(passphraseFile)
+	if err != nil {
+		return "", false, fmt.Errorf("read passphrase file failed, %v", err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+func keyStoreAEAD(passphrase string, params keyStoreScryptJSON, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, keyStoreKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("derive key of key store failed, %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,234 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	"chainmaker.org/chainmaker/common/v2/crypto/hash"
+)
+
+// kmsPrivateKey is a private key kept in a KMS, which is never exported. The KMS serves:
+//
+//	GET  {endpoint}/v1/keys/{key_id}/public_key  -> {"public_key": "<PEM>"}
+//	POST {endpoint}/v1/keys/{key_id}/sign         <- {"digest": "<base64>", "hash": "SHA256"}
+//	                                                 {"message": "<base64>", "hash": "SM3", "uid": "<uid>"}
+//	                                              -> {"signature": "<base64>"}
+//
+// A digest is signed as it is, and a message is hashed by the KMS, which is the case of the SM2 keys
+// whose digest is computed with the uid. The signatures are encoded the way the keys in files sign.
+type kmsPrivateKey struct {
+	client *kmsClient
+	keyId  string
+	pub    bccrypto.PublicKey
+}
+
+type kmsClient struct {
+	endpoint    string
+	accessToken string
+	httpClient  *http.Client
+}
+
+type kmsSignRequest struct {
+	Digest  []byte `json:"digest,omitempty"`
+	Message []byte `json:"message,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+	UID     string `json:"uid,omitempty"`
+}
+
+type kmsSignResponse struct {
+	Signature []byte `json:"signature"`
+}
+
+type kmsPublicKeyResponse struct {
+	PublicKey string `json:"public_key"`
+}
+
+// newKMSPrivateKey loads the public key of the key from the KMS
+func newKMSPrivateKey(conf *KMSConfig, keyId string) (*kmsPrivateKey, error) {
+	if keyId == "" {
+		return nil, errors.New("the kms key id is empty")
+	}
+	client, err := newKMSClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	resp := &kmsPublicKeyResponse{}
+	if err = client.call(http.MethodGet, keyId, "public_key", nil, resp); err != nil {
+		return nil, err
+	}
+	pub, err := asym.PublicKeyFromPEM([]byte(resp.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of kms key [%s]: %v", keyId, err)
+	}
+	return &kmsPrivateKey{client: client, keyId: keyId, pub: pub}, nil
+}
+
+func newKMSClient(conf *KMSConfig) (*kmsClient, error) {
+	timeout := conf.Timeout
+	if timeout <= 0 {
+		timeout = defaultKMSTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if conf.CACertFile != "" {
+		caPEM, err := ioutil.ReadFile(filepath.Clean(conf.CACertFile))
+		if err != nil {
+			return nil, fmt.Errorf("read kms ca cert failed: %v", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificate found in kms ca cert file %s", conf.CACertFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+	return &kmsClient{
+		endpoint:    strings.TrimRight(conf.Endpoint, "/"),
+		accessToken: conf.AccessToken,
+		httpClient:  &http.Client{Transport: transport, Timeout: time.Duration(timeout) * time.Second},
+	}, nil
+}
+
+func (c *kmsClient) call(method, keyId, action string, req, resp interface{}) error {
+	var body io.Reader
+	if req != nil {
+		reqBytes, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(reqBytes)
+	}
+	httpReq, err := http.NewRequest(method,
+		fmt.Sprintf("%s/v1/keys/%s/%s", c.endpoint, url.PathEscape(keyId), action), body)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.accessToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.accessToken)
+	}
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("kms %s of key [%s] failed: %v", action, keyId, err)
+	}
+	defer httpResp.Body.Close()
+	respBytes, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("kms %s of key [%s] failed: %v", action, keyId, err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kms %s of key [%s] failed: %s %s", action, keyId, httpResp.Status,
+			strings.TrimSpace(string(respBytes)))
+	}
+	if err = json.Unmarshal(respBytes, resp); err != nil {
+		return fmt.Errorf("invalid kms %s response of key [%s]: %v", action, keyId, err)
+	}
+	return nil
+}
+
+func (k *kmsPrivateKey) sign(req *kmsSignRequest) ([]byte, error) {
+	resp := &kmsSignResponse{}
+	if err := k.client.call(http.MethodPost, k.keyId, "sign", req, resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Signature) == 0 {
+		return nil, fmt.Errorf("kms returns an empty signature of key [%s]", k.keyId)
+	}
+	return resp.Signature, nil
+}
+
+// Bytes is not supported, the private key never leaves the KMS
+func (k *kmsPrivateKey) Bytes() ([]byte, error) {
+	return nil, fmt.Errorf("the private key [%s] is kept in the kms", k.keyId)
+}
+
+// Type returns the type of the key
+func (k *kmsPrivateKey) Type() bccrypto.KeyType {
+	return k.pub.Type()
+}
+
+// String is not supported, the private key never leaves the KMS
+func (k *kmsPrivateKey) String() (string, error) {
+	return "", fmt.Errorf("the private key [%s] is kept in the kms", k.keyId)
+}
+
+// Sign signs the digest
+func (k *kmsPrivateKey) Sign(digest []byte) ([]byte, error) {
+	return k.sign(&kmsSignRequest{Digest: digest})
+}
+
+// SignWithOpts hashes the message with the hash of the options and signs the digest,
+// the SM2 keys sign the message by the KMS with the uid
+func (k *kmsPrivateKey) SignWithOpts(msg []byte, opts *bccrypto.SignOpts) ([]byte, error) {
+	if opts == nil {
+		return k.Sign(msg)
+	}
+	hashName := kmsHashName(opts.Hash)
+	if k.Type() == bccrypto.SM2 {
+		return k.sign(&kmsSignRequest{Message: msg, Hash: hashName, UID: opts.UID})
+	}
+	digest, err := hash.Get(opts.Hash, msg)
+	if err != nil {
+		return nil, err
+	}
+	return k.sign(&kmsSignRequest{Digest: digest, Hash: hashName})
+}
+
+// PublicKey returns the public key
+func (k *kmsPrivateKey) PublicKey() bccrypto.PublicKey {
+	return k.pub
+}
+
+// ToStandardKey returns a crypto.Signer signing by the KMS, e.g. for the TLS handshakes
+func (k *kmsPrivateKey) ToStandardKey() crypto.PrivateKey {
+	return &kmsSigner{key: k}
+}
+
+// kmsSigner is the crypto.Signer of a KMS key
+type kmsSigner struct {
+	key *kmsPrivateKey
+}
+
+func (s *kmsSigner) Public() crypto.PublicKey {
+	return s.key.pub.ToStandardKey()
+}
+
+func (s *kmsSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := &kmsSignRequest{Digest: digest}
+	if opts != nil && opts.HashFunc() != 0 {
+		req.Hash = strings.ReplaceAll(opts.HashFunc().String(), "-", "")
+	}
+	return s.key.sign(req)
+}
+
+// kmsHashName returns the name of the hash type, such as SHA256 and SM3, empty if the KMS does not know it
+func kmsHashName(hashType bccrypto.HashType) string {
+	switch hashType {
+	case bccrypto.HASH_TYPE_SM3:
+		return "SM3"
+	case bccrypto.HASH_TYPE_SHA256:
+		return "SHA256"
+	case bccrypto.HASH_TYPE_SHA3_256:
+		return "SHA3_256"
+	default:
+		return ""
+	}
+}
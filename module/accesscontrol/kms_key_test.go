@@ -0,0 +1,102 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKMSPrivateKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.Nil(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/keys/node1/public_key":
+			_ = json.NewEncoder(w).Encode(&kmsPublicKeyResponse{
+				PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})),
+			})
+		case "/v1/keys/node1/sign":
+			req := &kmsSignRequest{}
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil || len(req.Digest) == 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			sig, err := ecdsa.SignASN1(rand.Reader, key, req.Digest)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(&kmsSignResponse{Signature: sig})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	conf := &KeySourceConfig{
+		Provider: KeySourceKMS,
+		KMS:      &KMSConfig{Endpoint: server.URL + "/", AccessToken: "token1"},
+	}
+	sk, err := parsePrivateKeyWithConfig(conf, []byte("node1\n"), "")
+	require.Nil(t, err)
+	_, err = sk.Bytes()
+	require.NotNil(t, err)
+
+	// 1. sign the messages the way the keys in files sign
+	msg := []byte("block hash")
+	opts := &bccrypto.SignOpts{Hash: bccrypto.HASH_TYPE_SHA256, UID: bccrypto.CRYPTO_DEFAULT_UID}
+	sig, err := sk.SignWithOpts(msg, opts)
+	require.Nil(t, err)
+	ok, err := sk.PublicKey().VerifyWithOpts(msg, sig, opts)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// 2. sign the digests as a crypto.Signer, e.g. in the TLS handshakes
+	signer, ok := sk.ToStandardKey().(crypto.Signer)
+	require.True(t, ok)
+	require.Equal(t, &key.PublicKey, signer.Public())
+	digest := sha256.Sum256(msg)
+	sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.Nil(t, err)
+	require.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig))
+
+	// 3. the errors of the kms
+	_, err = parsePrivateKeyWithConfig(conf, []byte("node2"), "")
+	require.NotNil(t, err)
+	conf.KMS.AccessToken = ""
+	_, err = parsePrivateKeyWithConfig(conf, []byte("node1"), "")
+	require.NotNil(t, err)
+	_, err = parsePrivateKeyWithConfig(&KeySourceConfig{Provider: KeySourceKMS}, []byte("node1"), "")
+	require.NotNil(t, err)
+	_, err = parsePrivateKeyWithConfig(&KeySourceConfig{Provider: "vault"}, []byte("node1"), "")
+	require.NotNil(t, err)
+
+	// 4. the names of the hash types
+	require.Equal(t, "SHA256", kmsHashName(bccrypto.HASH_TYPE_SHA256))
+	require.Equal(t, "SM3", kmsHashName(bccrypto.HASH_TYPE_SM3))
+	require.Equal(t, "SHA3_256", kmsHashName(bccrypto.HASH_TYPE_SHA3_256))
+}
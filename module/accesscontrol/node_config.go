@@ -0,0 +1,39 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"fmt"
+	"os"
+
+	"chainmaker.org/chainmaker/localconf/v2"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// LoadNodeConfigSection decodes the top-level section of the config file into config. The sections
+// are not known by localconf.CMConfig, so they are read from the file, false means the section is absent.
+func LoadNodeConfigSection(section string, config interface{}) (bool, error) {
+	if localconf.ConfigFilepath == "" {
+		return false, nil
+	}
+	if _, err := os.Stat(localconf.ConfigFilepath); os.IsNotExist(err) {
+		return false, nil
+	}
+	cmViper := viper.New()
+	cmViper.SetConfigFile(localconf.ConfigFilepath)
+	if err := cmViper.ReadInConfig(); err != nil {
+		return false, err
+	}
+	if !cmViper.IsSet(section) {
+		return false, nil
+	}
+	if err := mapstructure.Decode(cmViper.Get(section), config); err != nil {
+		return false, fmt.Errorf("invalid %s config: %v", section, err)
+	}
+	return true, nil
+}
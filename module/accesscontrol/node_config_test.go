@@ -0,0 +1,25 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"chainmaker.org/chainmaker/localconf/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// useNodeConfig sets the config file of the node to the content during the test
+func useNodeConfig(t *testing.T, content string) {
+	configFilepath := localconf.ConfigFilepath
+	t.Cleanup(func() { localconf.ConfigFilepath = configFilepath })
+
+	localconf.ConfigFilepath = filepath.Join(t.TempDir(), "chainmaker.yml")
+	require.Nil(t, ioutil.WriteFile(localconf.ConfigFilepath, []byte(content), 0600))
+}
@@ -88,6 +88,7 @@ func newPermissionedPkACProvider(chainConfig *config.ChainConfig, localOrgId str
 	}
 
 	ppacProvider.acService.initResourcePolicy(chainConfig.ResourcePolicies, localOrgId)
+	ppacProvider.acService.setTxTimestampVerify(chainConfig.GetBlock().GetTxTimestampVerify())
 
 	return ppacProvider, nil
 }
@@ -164,6 +165,7 @@ func (pp *permissionedPkACProvider) Watch(chainConfig *config.ChainConfig) error
 	}
 
 	pp.acService.initResourcePolicy(chainConfig.ResourcePolicies, pp.localOrg)
+	pp.acService.setTxTimestampVerify(chainConfig.GetBlock().GetTxTimestampVerify())
 
 	pp.acService.memberCache.Clear()
 
@@ -18,6 +18,10 @@ type policy struct {
 	rule     protocol.Rule
 	orgList  []string
 	roleList []protocol.Role
+
+	// the attribute conditions of the policy expression and the raw expression
+	conditions []*policyCondition
+	expr       string
 }
 
 func (p *policy) GetRule() protocol.Rule {
@@ -30,8 +34,12 @@ func (p *policy) GetPbPolicy() *pbac.Policy {
 		var roleStr = string(role)
 		pbRoleList = append(pbRoleList, roleStr)
 	}
+	rule := string(p.rule)
+	if p.expr != "" {
+		rule = p.expr
+	}
 	return &pbac.Policy{
-		Rule:     string(p.rule),
+		Rule:     rule,
 		OrgList:  p.orgList,
 		RoleList: pbRoleList,
 	}
@@ -61,6 +69,13 @@ func newPolicyFromPb(input *pbac.Policy) *policy {
 		roleList: nil,
 	}
 
+	if rule, conditions, err := parsePolicyRule(input.Rule); err != nil {
+		// the expressions are validated before, forbid the access in case
+		p.rule = protocol.RuleForbidden
+	} else if len(conditions) > 0 {
+		p.rule, p.conditions, p.expr = rule, conditions, input.Rule
+	}
+
 	for _, role := range input.RoleList {
 		role = strings.ToUpper(role)
 		p.roleList = append(p.roleList, protocol.Role(role))
@@ -0,0 +1,354 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+// A policy expression extends the rule of a resource policy with attribute conditions:
+//
+//	<rule> WHERE <condition> [AND <condition>]...
+//	<condition> = <attribute> [NOT] IN (<value>[, <value>]...)
+//
+// The attributes of the signer filter the endorsements before the rule is verified:
+//
+//	ou           the organizational units of the signer certificate
+//	cn           the common name of the signer certificate
+//	ext <oid>    the value of the custom X.509 extension of the signer certificate
+//
+// The attributes of the request are verified against the payload of the transaction:
+//
+//	method       the method invoked
+//	time         the UTC time windows of the transaction timestamp, e.g. 09:00-18:00
+//
+// The time is the timestamp set by the client in the payload, the access control has no block
+// time, and every node must verify a tx the same in the blocks. The timestamp is only trusted as
+// far as the nodes admit it, within the tx_timestamp_expire_duration of their clocks, so the time
+// conditions require tx_timestamp_verify of the chain and a window is enforced to that precision.
+//
+// e.g. `ANY WHERE ou IN (finance) AND method NOT IN (burn) AND time IN (09:00-18:00)`
+//
+// Besides the resources of the system contracts, the policy of a user contract `<contract>` or
+// its method `<contract>-<method>` is verified when the contract is invoked or queried.
+const (
+	policyAttrOU     = "ou"
+	policyAttrCN     = "cn"
+	policyAttrExt    = "ext"
+	policyAttrMethod = "method"
+	policyAttrTime   = "time"
+)
+
+var (
+	policyWhereRegexp     = regexp.MustCompile(`(?i)\s+WHERE\s+`)
+	policyAndRegexp       = regexp.MustCompile(`(?i)\s+AND\s+`)
+	policyConditionRegexp = regexp.MustCompile(
+		`(?i)^(ou|cn|method|time|ext\s+([0-9]+(?:\.[0-9]+)+))\s+(NOT\s+)?IN\s*\((.*)\)$`)
+)
+
+// policyCondition is a condition of a policy expression
+type policyCondition struct {
+	attr    string
+	oid     asn1.ObjectIdentifier
+	negate  bool
+	values  map[string]bool
+	windows []timeWindow
+}
+
+// timeWindow is a window of the day in minutes, the end is before the start if it crosses midnight
+type timeWindow struct {
+	start int
+	end   int
+}
+
+func (w timeWindow) contains(minute int) bool {
+	if w.start <= w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+func (c *policyCondition) isSignerCondition() bool {
+	return c.attr == policyAttrOU || c.attr == policyAttrCN || c.attr == policyAttrExt
+}
+
+// parsePolicyRule splits the rule of a policy expression into the rule and the conditions
+func parsePolicyRule(expr string) (protocol.Rule, []*policyCondition, error) {
+	parts := policyWhereRegexp.Split(strings.TrimSpace(expr), 2)
+	if len(parts) == 1 {
+		return protocol.Rule(parts[0]), nil, nil
+	}
+	var conditions []*policyCondition
+	for _, condExpr := range policyAndRegexp.Split(strings.TrimSpace(parts[1]), -1) {
+		condition, err := parsePolicyCondition(strings.TrimSpace(condExpr))
+		if err != nil {
+			return "", nil, fmt.Errorf("bad policy expression [%s]: %v", expr, err)
+		}
+		conditions = append(conditions, condition)
+	}
+	return protocol.Rule(strings.TrimSpace(parts[0])), conditions, nil
+}
+
+func parsePolicyCondition(expr string) (*policyCondition, error) {
+	matches := policyConditionRegexp.FindStringSubmatch(expr)
+	if matches == nil {
+		return nil, fmt.Errorf("unsupported condition [%s]", expr)
+	}
+	condition := &policyCondition{
+		attr:   strings.ToLower(strings.Fields(matches[1])[0]),
+		negate: matches[3] != "",
+		values: make(map[string]bool),
+	}
+	if condition.attr == policyAttrExt {
+		for _, arc := range strings.Split(matches[2], ".") {
+			n, err := strconv.Atoi(arc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid oid [%s]", matches[2])
+			}
+			condition.oid = append(condition.oid, n)
+		}
+	}
+	for _, value := range strings.Split(matches[4], ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		if condition.attr == policyAttrTime {
+			window, err := parseTimeWindow(value)
+			if err != nil {
+				return nil, err
+			}
+			condition.windows = append(condition.windows, window)
+			continue
+		}
+		condition.values[value] = true
+	}
+	if len(condition.values) == 0 && len(condition.windows) == 0 {
+		return nil, fmt.Errorf("empty value list of condition [%s]", expr)
+	}
+	return condition, nil
+}
+
+func parseTimeWindow(value string) (timeWindow, error) {
+	bounds := strings.Split(value, "-")
+	if len(bounds) != 2 {
+		return timeWindow{}, fmt.Errorf("invalid time window [%s], should be like 09:00-18:00", value)
+	}
+	var window timeWindow
+	for i, bound := range bounds {
+		t, err := time.Parse("15:04", strings.TrimSpace(bound))
+		if err != nil {
+			return timeWindow{}, fmt.Errorf("invalid time window [%s]: %v", value, err)
+		}
+		minute := t.Hour()*60 + t.Minute()
+		if i == 0 {
+			window.start = minute
+		} else {
+			window.end = minute
+		}
+	}
+	return window, nil
+}
+
+// matchSigner checks the signer conditions against the certificate of the member,
+// the members without a certificate meet none of them
+func (c *policyCondition) matchSigner(member protocol.Member) bool {
+	if delegated, ok := member.(*delegatedMember); ok {
+		member = delegated.Member
+	}
+	certMember, ok := member.(*certificateMember)
+	if !ok || certMember.cert == nil {
+		return false
+	}
+	var attrValues []string
+	switch c.attr {
+	case policyAttrOU:
+		attrValues = certMember.cert.Subject.OrganizationalUnit
+	case policyAttrCN:
+		attrValues = []string{certMember.cert.Subject.CommonName}
+	case policyAttrExt:
+		for _, ext := range certMember.cert.Extensions {
+			if ext.Id.Equal(c.oid) {
+				attrValues = append(attrValues, extensionValue(ext.Value))
+			}
+		}
+	}
+	return c.matchValues(attrValues)
+}
+
+// matchRequest checks the request conditions against the payload
+func (c *policyCondition) matchRequest(payload *common.Payload) bool {
+	switch c.attr {
+	case policyAttrMethod:
+		return c.matchValues([]string{payload.Method})
+	case policyAttrTime:
+		t := time.Unix(payload.Timestamp, 0).UTC()
+		minute := t.Hour()*60 + t.Minute()
+		in := false
+		for _, window := range c.windows {
+			if window.contains(minute) {
+				in = true
+				break
+			}
+		}
+		return in != c.negate
+	}
+	return false
+}
+
+func (c *policyCondition) matchValues(attrValues []string) bool {
+	in := false
+	for _, value := range attrValues {
+		if c.values[value] {
+			in = true
+			break
+		}
+	}
+	return in != c.negate
+}
+
+// extensionValue returns the string of an extension value encoded as an ASN.1 string, or the hex of it
+func extensionValue(value []byte) string {
+	var s string
+	if rest, err := asn1.Unmarshal(value, &s); err == nil && len(rest) == 0 {
+		return s
+	}
+	return hex.EncodeToString(value)
+}
+
+// verifyPrincipalPolicyWithConditions verifies the conditions of the policy, then the rule of the policy
+// against the endorsements of the signers meeting the conditions
+func (acs *accessControlService) verifyPrincipalPolicyWithConditions(principal, refinedPrincipal protocol.Principal,
+	p *policy) (bool, error) {
+	refinedPrincipal, err := acs.applyDelegations(principal, refinedPrincipal)
+	if err != nil {
+		return false, err
+	}
+	if len(p.conditions) == 0 {
+		return acs.verifyPrincipalPolicyRule(principal, refinedPrincipal, p)
+	}
+
+	var (
+		payload       *common.Payload
+		signerFilters []*policyCondition
+	)
+	for _, condition := range p.conditions {
+		if condition.isSignerCondition() {
+			signerFilters = append(signerFilters, condition)
+			continue
+		}
+		if condition.attr == policyAttrTime && atomic.LoadInt32(&acs.txTimestampVerify) == 0 {
+			return false, fmt.Errorf("authentication fail: the time condition of [%s] requires tx_timestamp_verify",
+				principal.GetResourceName())
+		}
+		if payload == nil {
+			payload = &common.Payload{}
+			if err := payload.Unmarshal(principal.GetMessage()); err != nil || payload.Timestamp <= 0 {
+				return false, fmt.Errorf("authentication fail: the conditions of [%s] require a transaction",
+					principal.GetResourceName())
+			}
+		}
+		if !condition.matchRequest(payload) {
+			return false, fmt.Errorf("authentication fail: the request does not meet the %s condition of [%s]",
+				condition.attr, principal.GetResourceName())
+		}
+	}
+
+	if len(signerFilters) > 0 {
+		var endorsements []*common.EndorsementEntry
+		for _, endorsement := range refinedPrincipal.GetEndorsement() {
+			member := acs.getSignerMember(endorsement.Signer)
+			if member == nil {
+				continue
+			}
+			matched := true
+			for _, condition := range signerFilters {
+				if !condition.matchSigner(member) {
+					matched = false
+					acs.log.Debugf("authentication warning: signer [%s] does not meet the %s condition",
+						member.GetMemberId(), condition.attr)
+					break
+				}
+			}
+			if matched {
+				endorsements = append(endorsements, endorsement)
+			}
+		}
+		if len(endorsements) == 0 {
+			return false, fmt.Errorf("authentication fail: no signer meets the conditions of [%s]",
+				principal.GetResourceName())
+		}
+		refinedPrincipal, err = acs.createPrincipalForTargetOrg(refinedPrincipal.GetResourceName(), endorsements,
+			refinedPrincipal.GetMessage(), refinedPrincipal.GetTargetOrgId())
+		if err != nil {
+			return false, err
+		}
+	}
+	return acs.verifyPrincipalPolicyRule(principal, refinedPrincipal, p)
+}
+
+// verifyContractPolicy verifies the policies of the user contract and the method invoked or queried
+func (acs *accessControlService) verifyContractPolicy(principal, refinedPrincipal protocol.Principal) (bool, error) {
+	resourceName := principal.GetResourceName()
+	if resourceName != common.TxType_INVOKE_CONTRACT.String() &&
+		resourceName != common.TxType_QUERY_CONTRACT.String() {
+		return true, nil
+	}
+	if !acs.hasContractPolicy() {
+		return true, nil
+	}
+	payload := &common.Payload{}
+	if err := payload.Unmarshal(principal.GetMessage()); err != nil {
+		return false, fmt.Errorf("authentication fail: invalid payload of [%s]: %v", resourceName, err)
+	}
+	if _, ok := syscontract.SystemContract_value[payload.ContractName]; ok {
+		return true, nil
+	}
+	for _, name := range []string{payload.ContractName, payload.ContractName + "-" + payload.Method} {
+		p, ok := acs.resourceNamePolicyMap.Load(name)
+		if !ok {
+			continue
+		}
+		contractPrincipal, err := acs.createPrincipalForTargetOrg(name, refinedPrincipal.GetEndorsement(),
+			refinedPrincipal.GetMessage(), refinedPrincipal.GetTargetOrgId())
+		if err != nil {
+			return false, err
+		}
+		if ok, err := acs.verifyPrincipalPolicyWithConditions(contractPrincipal, contractPrincipal,
+			p.(*policy)); !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (acs *accessControlService) hasContractPolicy() bool {
+	return atomic.LoadInt32(&acs.contractPolicyNum) > 0
+}
+
+// isContractResource checks whether the resource may be the policy of a user contract or its method
+func isContractResource(resourceName string) bool {
+	if isDelegationResource(resourceName) {
+		return false
+	}
+	contractName := strings.SplitN(resourceName, "-", 2)[0]
+	if _, ok := syscontract.SystemContract_value[contractName]; ok {
+		return false
+	}
+	_, ok := common.TxType_value[resourceName]
+	return !ok
+}
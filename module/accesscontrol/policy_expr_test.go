@@ -0,0 +1,136 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package accesscontrol
+
+import (
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	bcx509 "chainmaker.org/chainmaker/common/v2/crypto/x509"
+	logger2 "chainmaker.org/chainmaker/logger/v2"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/pb-go/v2/config"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePolicyRule(t *testing.T) {
+	rule, conditions, err := parsePolicyRule("ANY")
+	require.Nil(t, err)
+	require.Equal(t, protocol.RuleAny, rule)
+	require.Nil(t, conditions)
+
+	rule, conditions, err = parsePolicyRule(
+		"2/3 where ou in (finance, ops) and ext 1.2.3.4 NOT IN (gold) AND time IN (22:00-02:00)")
+	require.Nil(t, err)
+	require.Equal(t, protocol.Rule("2/3"), rule)
+	require.Len(t, conditions, 3)
+	require.Equal(t, map[string]bool{"finance": true, "ops": true}, conditions[0].values)
+	require.True(t, conditions[1].negate)
+	require.Equal(t, "1.2.3.4", conditions[1].oid.String())
+	require.True(t, conditions[2].windows[0].contains(23*60))
+	require.True(t, conditions[2].windows[0].contains(60))
+	require.False(t, conditions[2].windows[0].contains(12*60))
+
+	for _, expr := range []string{
+		"ANY WHERE role IN (admin)",
+		"ANY WHERE ou IN ()",
+		"ANY WHERE time IN (9-18)",
+		"ANY WHERE ou (admin)",
+	} {
+		_, _, err = parsePolicyRule(expr)
+		require.NotNil(t, err, expr)
+	}
+}
+
+func TestVerifyContractPolicy(t *testing.T) {
+	_, cleanFunc, err := createTempDirWithCleanFunc()
+	require.Nil(t, err)
+	defer cleanFunc()
+
+	certBlock, _ := pem.Decode([]byte(testAdminSignOrg1.cert))
+	adminCert, err := bcx509.ParseCertificate(certBlock.Bytes)
+	require.Nil(t, err)
+	require.NotEmpty(t, adminCert.Subject.OrganizationalUnit)
+
+	chainConfig := proto.Clone(testChainConfig).(*config.ChainConfig)
+	chainConfig.Block = &config.BlockConfig{TxTimestampVerify: true, TxTimestampExpireDuration: 600}
+	chainConfig.ResourcePolicies = append(chainConfig.ResourcePolicies,
+		&config.ResourcePolicy{
+			ResourceName: "erc20",
+			Policy:       &pbac.Policy{Rule: "ANY WHERE method NOT IN (burn) AND time IN (09:00-18:00)"},
+		},
+		&config.ResourcePolicy{
+			ResourceName: "erc20-mint",
+			Policy: &pbac.Policy{
+				Rule: fmt.Sprintf("ANY WHERE ou IN (%s)", adminCert.Subject.OrganizationalUnit[0]),
+			},
+		},
+		&config.ResourcePolicy{
+			ResourceName: "erc20-approve",
+			Policy:       &pbac.Policy{Rule: "ANY WHERE ou IN (finance"},
+		},
+	)
+	provider, err := newCertACProvider(chainConfig, testOrg1, nil, logger2.GetLogger(logger2.MODULE_ACCESS))
+	require.Nil(t, err)
+	require.EqualValues(t, 2, provider.acService.contractPolicyNum)
+	pbPolicy, err := provider.LookUpPolicy("erc20")
+	require.Nil(t, err)
+	require.Equal(t, "ANY WHERE method NOT IN (burn) AND time IN (09:00-18:00)", pbPolicy.Rule)
+
+	member := initOrgMember(t, orgMemberInfoMap[testOrg1])
+	noon := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC).Unix()
+	night := time.Date(2021, 10, 1, 20, 0, 0, 0, time.UTC).Unix()
+	cases := []struct {
+		role      protocol.Role
+		method    string
+		timestamp int64
+		ok        bool
+	}{
+		{protocol.RoleAdmin, "mint", noon, true},
+		{protocol.RoleClient, "mint", noon, false},
+		{protocol.RoleClient, "transfer", noon, true},
+		{protocol.RoleClient, "approve", noon, true},
+		{protocol.RoleAdmin, "burn", noon, false},
+		{protocol.RoleClient, "transfer", night, false},
+	}
+	for _, c := range cases {
+		payload, err := (&common.Payload{
+			ChainId: chainConfig.ChainId, TxType: common.TxType_INVOKE_CONTRACT,
+			ContractName: "erc20", Method: c.method, Timestamp: c.timestamp,
+		}).Marshal()
+		require.Nil(t, err)
+		endorsement, err := testCreateEndorsementEntry(member, c.role, testHashType, string(payload))
+		require.Nil(t, err)
+		principal, err := provider.CreatePrincipal(common.TxType_INVOKE_CONTRACT.String(),
+			[]*common.EndorsementEntry{endorsement}, payload)
+		require.Nil(t, err)
+		ok, err := provider.VerifyPrincipal(principal)
+		require.Equal(t, c.ok, ok, "%s %s %d: %v", c.role, c.method, c.timestamp, err)
+	}
+
+	// the timestamps of the txs are set by the clients if the chain does not verify them
+	chainConfig.Block.TxTimestampVerify = false
+	require.Nil(t, provider.Watch(chainConfig))
+	payload, err := (&common.Payload{
+		ChainId: chainConfig.ChainId, TxType: common.TxType_INVOKE_CONTRACT,
+		ContractName: "erc20", Method: "transfer", Timestamp: noon,
+	}).Marshal()
+	require.Nil(t, err)
+	endorsement, err := testCreateEndorsementEntry(member, protocol.RoleClient, testHashType, string(payload))
+	require.Nil(t, err)
+	principal, err := provider.CreatePrincipal(common.TxType_INVOKE_CONTRACT.String(),
+		[]*common.EndorsementEntry{endorsement}, payload)
+	require.Nil(t, err)
+	ok, err := provider.VerifyPrincipal(principal)
+	require.False(t, ok)
+	require.Error(t, err)
+}
@@ -13,9 +13,6 @@ import (
 	"fmt"
 	"io/ioutil"
 
-	"chainmaker.org/chainmaker/common/v2/cert"
-	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
-	"chainmaker.org/chainmaker/common/v2/crypto/asym"
 	"chainmaker.org/chainmaker/common/v2/crypto/pkcs11"
 	"chainmaker.org/chainmaker/localconf/v2"
 	"chainmaker.org/chainmaker/pb-go/v2/config"
@@ -86,29 +83,14 @@ func InitCertSigningMember(chainConfig *config.ChainConfig, localOrgId,
 			}
 		}
 
-		skPEM, err := ioutil.ReadFile(localPrivKeyFile)
+		skPEM, err := ReadPrivateKeyFile(localPrivKeyFile)
 		if err != nil {
 			return nil, fmt.Errorf("fail to initialize identity management service: [%s]", err.Error())
 		}
 
-		var sk bccrypto.PrivateKey
-		p11Config := localconf.ChainMakerConfig.NodeConfig.P11Config
-		if p11Config.Enabled {
-			var p11Handle *pkcs11.P11Handle
-			p11Handle, err = getP11Handle()
-			if err != nil {
-				return nil, fmt.Errorf("fail to initialize identity management service: [%s]", err.Error())
-			}
-
-			sk, err = cert.ParseP11PrivKey(p11Handle, skPEM)
-			if err != nil {
-				return nil, fmt.Errorf("fail to initialize identity management service: [%s]", err.Error())
-			}
-		} else {
-			sk, err = asym.PrivateKeyFromPEM(skPEM, []byte(localPrivKeyPwd))
-			if err != nil {
-				return nil, err
-			}
+		sk, err := parsePrivateKey(skPEM, localPrivKeyPwd)
+		if err != nil {
+			return nil, fmt.Errorf("fail to initialize identity management service: [%s]", err.Error())
 		}
 
 		return &signingCertMember{
@@ -123,29 +105,14 @@ func InitPKSigningMember(ac protocol.AccessControlProvider,
 	localOrgId, localPrivKeyFile, localPrivKeyPwd string) (protocol.SigningMember, error) {
 
 	if localPrivKeyFile != "" {
-		skPEM, err := ioutil.ReadFile(localPrivKeyFile)
+		skPEM, err := ReadPrivateKeyFile(localPrivKeyFile)
 		if err != nil {
 			return nil, fmt.Errorf("fail to initialize identity management service: [%s]", err.Error())
 		}
 
-		var sk bccrypto.PrivateKey
-		p11Config := localconf.ChainMakerConfig.NodeConfig.P11Config
-		if p11Config.Enabled {
-			var p11Handle *pkcs11.P11Handle
-			p11Handle, err = getP11Handle()
-			if err != nil {
-				return nil, fmt.Errorf("fail to initialize identity management service: [%v]", err)
-			}
-
-			sk, err = cert.ParseP11PrivKey(p11Handle, []byte(skPEM))
-			if err != nil {
-				return nil, fmt.Errorf("fail to initialize identity management service: [%v]", err)
-			}
-		} else {
-			sk, err = asym.PrivateKeyFromPEM(skPEM, []byte(localPrivKeyPwd))
-			if err != nil {
-				return nil, fmt.Errorf("fail to initialize identity management service: [%s]", err.Error())
-			}
+		sk, err := parsePrivateKey(skPEM, localPrivKeyPwd)
+		if err != nil {
+			return nil, fmt.Errorf("fail to initialize identity management service: [%s]", err.Error())
 		}
 
 		publicKeyBytes, err := sk.PublicKey().Bytes()
@@ -29,6 +29,7 @@ const (
 	moduleNameCore          = "Core"
 	moduleNameConsensus     = "Consensus"
 	moduleNameSync          = "Sync"
+	moduleNameResource      = "ResourceBudget"
 )
 
 // Blockchain is a block chain service. It manage all the modules of the chain.
@@ -87,6 +88,9 @@ type Blockchain struct {
 
 	eventSubscriber *subscriber.EventSubscriber
 
+	// resources the chain could consume on the node
+	resourceBudget *ChainResourceBudget
+
 	initModules  map[string]struct{}
 	startModules map[string]struct{}
 }
@@ -115,3 +119,18 @@ func (bc *Blockchain) getConsensusType() consensus.ConsensusType {
 func (bc *Blockchain) GetAccessControl() protocol.AccessControlProvider {
 	return bc.ac
 }
+
+// GetResourceBudget get the resources the chain could consume on the node.
+func (bc *Blockchain) GetResourceBudget() *ChainResourceBudget {
+	return bc.resourceBudget
+}
+
+// GetSyncService get the protocol.SyncService of instance.
+func (bc *Blockchain) GetSyncService() protocol.SyncService {
+	return bc.syncServer
+}
+
+// GetNetService get the protocol.NetService of instance.
+func (bc *Blockchain) GetNetService() protocol.NetService {
+	return bc.netService
+}
@@ -38,6 +38,7 @@ import (
 	"chainmaker.org/chainmaker/store/v2/conf"
 	"chainmaker.org/chainmaker/utils/v2"
 	"chainmaker.org/chainmaker/vm/v2"
+	"github.com/gogo/protobuf/proto"
 	"github.com/mitchellh/mapstructure"
 )
 
@@ -49,6 +50,8 @@ const (
 // Init all the modules.
 func (bc *Blockchain) Init() (err error) {
 	baseModules := []map[string]func() error{
+		// init resource budget
+		{moduleNameResource: bc.initResourceBudget},
 		// init Subscriber
 		{moduleNameSubscriber: bc.initSubscriber},
 		// init store module
@@ -140,9 +143,35 @@ func (bc *Blockchain) initNetService() (err error) {
 		bc.log.Infof("net service module existed, ignore.")
 		return
 	}
+	qosConfig, err := LoadNetQoSConfig(bc.chainId)
+	if err != nil {
+		bc.log.Errorf("load net qos config failed, %s", err)
+		return
+	}
+	peerScoreConfig, err := LoadPeerScoreConfig()
+	if err != nil {
+		bc.log.Errorf("load peer score config failed, %s", err)
+		return
+	}
+	metrics := localconf.ChainMakerConfig.MonitorConfig.Enabled
+	opts := []net.NetServiceOption{net.WithMsgBus(bc.msgBus), net.WithQoS(qosConfig, metrics),
+		net.WithPeerScore(peerScoreConfig, metrics)}
+	// the tx msgs of the default tx pool are the marshaled txs
+	if txPoolType, _ := localconf.ChainMakerConfig.TxPoolConfig["pool_type"].(string); txPoolType == "" ||
+		strings.ToUpper(txPoolType) == txpool.TypeDefault {
+		// the signatures are verified once more to score the peers sending the forged txs
+		verifySignature := peerScoreConfig != nil && peerScoreConfig.Enabled
+		opts = append(opts, net.WithTxMsgValidator(func(payload []byte) error {
+			return bc.validateTxMsg(payload, verifySignature)
+		}))
+		// the txs received from the peers are counted in the memory budget of the tx pool
+		if bc.resourceBudget.TxPoolMemoryMB > 0 {
+			opts = append(opts, net.WithTxMsgFilter(bc.admitTxMsg))
+		}
+	}
 	var netServiceFactory net.NetServiceFactory
 	if bc.netService, err = netServiceFactory.NewNetService(
-		bc.net, bc.chainId, bc.ac, bc.chainConf, net.WithMsgBus(bc.msgBus)); err != nil {
+		bc.net, bc.chainId, bc.ac, bc.chainConf, opts...); err != nil {
 		bc.log.Errorf("new net service failed, %s", err)
 		return
 	}
@@ -150,6 +179,36 @@ func (bc *Blockchain) initNetService() (err error) {
 	return
 }
 
+// validateTxMsg checks the tx msg received by the net is a tx of the chain, the signatures are verified by
+// the tx pool, and here too if verifySignature, so that the peer sending a forged tx is reported
+func (bc *Blockchain) validateTxMsg(payload []byte, verifySignature bool) error {
+	tx := &common.Transaction{}
+	if err := proto.Unmarshal(payload, tx); err != nil {
+		return err
+	}
+	if tx.Payload == nil || tx.Payload.TxId == "" {
+		return errors.New("tx payload or tx id is empty")
+	}
+	if tx.Payload.ChainId != bc.chainId {
+		return fmt.Errorf("tx of chain %s received by chain %s", tx.Payload.ChainId, bc.chainId)
+	}
+	if !verifySignature {
+		return nil
+	}
+	if err := utils.VerifyTxWithoutPayload(tx, bc.chainId, bc.ac); err != nil {
+		return fmt.Errorf("%w, %s", net.ErrorTxMsgSignature, err.Error())
+	}
+	return nil
+}
+
+// admitTxMsg counts the tx msg received by the net in the memory budget of the tx pool
+func (bc *Blockchain) admitTxMsg(payload []byte) bool {
+	if budgetPool, ok := bc.txPool.(*budgetTxPool); ok {
+		return budgetPool.admitTxMsg(payload)
+	}
+	return true
+}
+
 func (bc *Blockchain) initStore() (err error) {
 	_, ok := bc.initModules[moduleNameStore]
 	if ok {
@@ -370,7 +429,7 @@ func (bc *Blockchain) initAC() (err error) {
 			bc.log.Errorf("initialize identity failed, %s", err.Error())
 			return
 		}
-	case protocol.PermissionedWithKey, protocol.Public:
+	case protocol.PermissionedWithKey, protocol.Public, accesscontrol.AuthTypeDID:
 		bc.identity, err = accesscontrol.InitPKSigningMember(bc.ac, nodeConfig.OrgId,
 			nodeConfig.PrivKeyFile, nodeConfig.PrivKeyPassword)
 		if err != nil {
@@ -424,6 +483,10 @@ func (bc *Blockchain) initTxPool() (err error) {
 		return err
 	}
 
+	// the txs submitted to the node are limited by the memory budget of the chain
+	if bc.resourceBudget.TxPoolMemoryMB > 0 {
+		currentTxPool = newBudgetTxPool(currentTxPool, bc.chainId, bc.resourceBudget.TxPoolMemoryMB)
+	}
 	bc.txPool = currentTxPool
 	bc.initModules[moduleNameTxPool] = struct{}{}
 	return nil
@@ -580,6 +643,7 @@ func (bc *Blockchain) initCore() (err error) {
 		VmMgr:           bc.vmMgr,
 		ProposalCache:   bc.proposalCache,
 		Subscriber:      bc.eventSubscriber,
+		VMConcurrency:   bc.resourceBudget.VMConcurrency,
 	}
 
 	coreEngineFactory := core.Factory()
@@ -651,6 +715,26 @@ func (bc *Blockchain) initSync() (err error) {
 		return
 	}
 	// init sync service module
+	extConfig := &blockSync.ExtConfig{}
+	if _, err = loadNodeConfigSection("sync", extConfig); err != nil {
+		return err
+	}
+	opts := []blockSync.Option{blockSync.WithExtConfig(extConfig)}
+	// the blocks archived by all peers are synced from the archive source in storage config
+	if archiveConfig, ok := localconf.ChainMakerConfig.StorageConfig["archive_source"]; ok {
+		config := &blockSync.ArchiveSourceConfig{}
+		if err = mapstructure.Decode(archiveConfig, config); err != nil {
+			return err
+		}
+		var archiveSource blockSync.ArchiveSource
+		if archiveSource, err = blockSync.NewArchiveSource(bc.chainId, config); err != nil {
+			return err
+		}
+		opts = append(opts, blockSync.WithArchiveSource(archiveSource))
+	}
+	if bc.resourceBudget.SyncBandwidthKB > 0 {
+		opts = append(opts, blockSync.WithBandwidthLimit(bc.resourceBudget.SyncBandwidthKB*1024))
+	}
 	bc.syncServer = blockSync.NewBlockChainSyncServer(
 		bc.chainId,
 		bc.netService,
@@ -659,11 +743,26 @@ func (bc *Blockchain) initSync() (err error) {
 		bc.ledgerCache,
 		bc.coreEngine.GetBlockVerifier(),
 		bc.coreEngine.GetBlockCommitter(),
+		opts...,
 	)
 	bc.initModules[moduleNameSync] = struct{}{}
 	return
 }
 
+func (bc *Blockchain) initResourceBudget() (err error) {
+	_, ok := bc.initModules[moduleNameResource]
+	if ok {
+		bc.log.Infof("resource budget module existed, ignore.")
+		return
+	}
+	if bc.resourceBudget, err = LoadChainResourceBudget(bc.chainId); err != nil {
+		return err
+	}
+	bc.log.Infof("resource budget of chain: %+v", *bc.resourceBudget)
+	bc.initModules[moduleNameResource] = struct{}{}
+	return
+}
+
 func (bc *Blockchain) initSubscriber() error {
 	_, ok := bc.initModules[moduleNameSubscriber]
 	if ok {
@@ -7,6 +7,8 @@ SPDX-License-Identifier: Apache-2.0
 
 package blockchain
 
+import "chainmaker.org/chainmaker-go/accesscontrol"
+
 // Stop all the modules.
 func (bc *Blockchain) Stop() {
 	// stop all module
@@ -52,10 +54,29 @@ func (bc *Blockchain) Stop() {
 			bc.log.Infof("STOP STEP (%d/%d) => stop module[%s] success :)", total-idx, total, name)
 		}
 	}
+
+	// the background tasks of the access control, e.g. tracking the expiry of the certificates
+	if stopper, ok := bc.ac.(accesscontrol.Stopper); ok {
+		stopper.Stop()
+	}
 }
 
 // StopOnRequirements close the module instance which is required to shut down when chain configuration updating.
 func (bc *Blockchain) StopOnRequirements() {
+	bc.stopInSequence(func(moduleName string) bool {
+		_, ok := bc.initModules[moduleName]
+		return !ok
+	})
+}
+
+// Pause stops all the started modules of the chain in the same sequence as StopOnRequirements,
+// the store, chain config and access control are kept, so that the chain could be queried while paused.
+func (bc *Blockchain) Pause() {
+	bc.stopInSequence(func(string) bool { return true })
+}
+
+// stopInSequence stops the started modules which shouldStop returns true for.
+func (bc *Blockchain) stopInSequence(shouldStop func(moduleName string) bool) {
 	stopMethodMap := map[string]func() error{
 		moduleNameNetService: bc.stopNetService,
 		moduleNameSync:       bc.stopSyncService,
@@ -82,8 +103,7 @@ func (bc *Blockchain) StopOnRequirements() {
 	}
 	closeFlagArray := [6]string{}
 	for moduleName := range bc.startModules {
-		_, ok := bc.initModules[moduleName]
-		if ok {
+		if !shouldStop(moduleName) {
 			continue
 		}
 		seq, canStop := sequence[moduleName]
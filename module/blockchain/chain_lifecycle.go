@@ -0,0 +1,290 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"chainmaker.org/chainmaker/common/v2/msgbus"
+	"chainmaker.org/chainmaker/localconf/v2"
+)
+
+// the admin queries to manage the lifecycle of the chains on the node, sent to the system chain
+const (
+	// LifecycleContractName the contract name of the lifecycle admin queries
+	LifecycleContractName = "CHAIN_LIFECYCLE"
+
+	LifecycleMethodJoin   = "JOIN"
+	LifecycleMethodPause  = "PAUSE"
+	LifecycleMethodResume = "RESUME"
+	LifecycleMethodLeave  = "LEAVE"
+	LifecycleMethodStatus = "GET_STATUS"
+
+	// LifecycleParamChainId the chain managed
+	LifecycleParamChainId = "chain_id"
+	// LifecycleParamGenesis the path of the genesis file on the node to join the chain with
+	LifecycleParamGenesis = "genesis"
+	// LifecycleParamDeleteData whether to delete the data of the chain left
+	LifecycleParamDeleteData = "delete_data"
+)
+
+// the states of the chains on the node
+const (
+	ChainStateRunning = "RUNNING"
+	ChainStatePaused  = "PAUSED"
+)
+
+const chainPausedErrorTemplate = "chain %s is paused"
+
+// ChainStatus is the lifecycle status of a chain on the node
+type ChainStatus struct {
+	ChainId string `json:"chain_id"`
+	Genesis string `json:"genesis"`
+	State   string `json:"state"`
+	Height  uint64 `json:"height"`
+}
+
+// ParseLifecycleDeleteData parses the delete_data param of the leave query, false if absent.
+func ParseLifecycleDeleteData(params map[string][]byte) (bool, error) {
+	v, ok := params[LifecycleParamDeleteData]
+	if !ok || len(v) == 0 {
+		return false, nil
+	}
+	deleteData, err := strconv.ParseBool(string(v))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %s", LifecycleParamDeleteData, string(v))
+	}
+	return deleteData, nil
+}
+
+// JoinBlockchain init and start the chain from the genesis file without restarting the node.
+// The chain joined is not written to chainmaker.yml, add it to the config to keep it after restarting.
+func (server *ChainMakerServer) JoinBlockchain(chainId, genesis string) error {
+	if err := checkLifecycleChainId(chainId); err != nil {
+		return err
+	}
+	server.lifecycleLock.Lock()
+	defer server.lifecycleLock.Unlock()
+
+	if _, ok := server.blockchains.Load(chainId); ok {
+		return fmt.Errorf("chain %s has been joined", chainId)
+	}
+	blockchain, err := server.newBlockchain(chainId, genesis)
+	if err != nil {
+		return err
+	}
+	if err = blockchain.Start(); err != nil {
+		blockchain.Stop()
+		blockchain.release(true)
+		return fmt.Errorf("start blockchain[%s] failed, %s", chainId, err.Error())
+	}
+	server.blockchains.Store(chainId, blockchain)
+	server.applyReloadedLocalConfig(blockchain)
+	log.Infof("[Lifecycle] join blockchain[%s] success", chainId)
+	return nil
+}
+
+// PauseBlockchain stops the modules of the chain, the chain could still be queried while paused.
+func (server *ChainMakerServer) PauseBlockchain(chainId string) error {
+	server.lifecycleLock.Lock()
+	defer server.lifecycleLock.Unlock()
+
+	blockchain, err := server.GetBlockchain(chainId)
+	if err != nil {
+		return err
+	}
+	if server.isPaused(chainId) {
+		return fmt.Errorf(chainPausedErrorTemplate, chainId)
+	}
+	server.pausedChains.Store(chainId, struct{}{})
+	blockchain.Pause()
+	log.Infof("[Lifecycle] pause blockchain[%s] success", chainId)
+	return nil
+}
+
+// ResumeBlockchain rebuilds the modules of the paused chain on its store, then starts them.
+func (server *ChainMakerServer) ResumeBlockchain(chainId string) error {
+	server.lifecycleLock.Lock()
+	defer server.lifecycleLock.Unlock()
+
+	paused, err := server.GetBlockchain(chainId)
+	if err != nil {
+		return err
+	}
+	if !server.isPaused(chainId) {
+		return fmt.Errorf("chain %s is not paused", chainId)
+	}
+
+	// the modules stopped could not be started again, so a new instance is built on the store kept
+	blockchain := NewBlockchain(paused.genesis, chainId, msgbus.NewMessageBus(), server.net)
+	blockchain.store = paused.store
+	blockchain.initModules[moduleNameStore] = struct{}{}
+	if err = blockchain.Init(); err != nil {
+		blockchain.release(false)
+		return fmt.Errorf("init blockchain[%s] failed, %s", chainId, err.Error())
+	}
+	if err = blockchain.Start(); err != nil {
+		blockchain.Stop()
+		blockchain.release(false)
+		return fmt.Errorf("start blockchain[%s] failed, %s", chainId, err.Error())
+	}
+	paused.release(false)
+	server.blockchains.Store(chainId, blockchain)
+	server.applyReloadedLocalConfig(blockchain)
+	server.pausedChains.Delete(chainId)
+	log.Infof("[Lifecycle] resume blockchain[%s] success", chainId)
+	return nil
+}
+
+// LeaveBlockchain stops the chain and closes its store, the data of the chain is deleted if deleteData.
+// The chain configured in chainmaker.yml is joined again after restarting.
+func (server *ChainMakerServer) LeaveBlockchain(chainId string, deleteData bool) error {
+	if err := checkLifecycleChainId(chainId); err != nil {
+		return err
+	}
+	server.lifecycleLock.Lock()
+	defer server.lifecycleLock.Unlock()
+
+	blockchain, err := server.GetBlockchain(chainId)
+	if err != nil {
+		return err
+	}
+	if !server.isPaused(chainId) {
+		server.pausedChains.Store(chainId, struct{}{})
+		blockchain.Pause()
+	}
+	server.blockchains.Delete(chainId)
+	server.pausedChains.Delete(chainId)
+	blockchain.release(true)
+	log.Infof("[Lifecycle] leave blockchain[%s] success", chainId)
+
+	if deleteData {
+		dataPaths, sqlDBs := chainDataPaths(localconf.ChainMakerConfig.StorageConfig, chainId)
+		for _, dataPath := range dataPaths {
+			if err = os.RemoveAll(dataPath); err != nil {
+				return fmt.Errorf("delete the data of chain %s failed, %s", chainId, err.Error())
+			}
+			log.Infof("[Lifecycle] delete the data of blockchain[%s] at %s", chainId, dataPath)
+		}
+		for _, db := range sqlDBs {
+			log.Warnf("[Lifecycle] the %s of blockchain[%s] is kept in sql, drop its database manually", db, chainId)
+		}
+	}
+	return nil
+}
+
+// chainDataPaths returns the dirs of the data of the chain: the dir under the default store path, and those
+// under the store paths of the leveldb and badgerdb dbs in the storage config. The dbs in sql are returned
+// by their config keys, they are not deleted with the dirs.
+func chainDataPaths(storageConfig map[string]interface{}, chainId string) (dataPaths []string, sqlDBs []string) {
+	seen := make(map[string]bool)
+	addPath := func(storePath interface{}) {
+		if path, ok := storePath.(string); ok && path != "" {
+			dataPath := filepath.Join(path, chainId)
+			if !seen[dataPath] {
+				seen[dataPath] = true
+				dataPaths = append(dataPaths, dataPath)
+			}
+		}
+	}
+	addPath(storageConfig["store_path"])
+
+	var dbKeys []string
+	for key := range storageConfig {
+		if strings.HasSuffix(key, "db_config") {
+			dbKeys = append(dbKeys, key)
+		}
+	}
+	sort.Strings(dbKeys)
+	for _, key := range dbKeys {
+		dbConfig, ok := toStringMap(storageConfig[key])
+		if !ok {
+			continue
+		}
+		provider, _ := dbConfig["provider"].(string)
+		switch strings.ToLower(provider) {
+		case "leveldb", "badgerdb":
+			if providerConfig, ok := toStringMap(dbConfig[strings.ToLower(provider)+"_config"]); ok {
+				addPath(providerConfig["store_path"])
+			}
+		case "sql":
+			if key == "contract_eventdb_config" && storageConfig["disable_contract_eventdb"] == true {
+				continue
+			}
+			sqlDBs = append(sqlDBs, key)
+		}
+	}
+	return dataPaths, sqlDBs
+}
+
+// toStringMap converts the sections of the config decoded by viper, which may be keyed by interface{}
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(m))
+		for k, value := range m {
+			converted[fmt.Sprint(k)] = value
+		}
+		return converted, true
+	default:
+		return nil, false
+	}
+}
+
+// BlockchainStatuses returns the lifecycle status of all the chains on the node.
+func (server *ChainMakerServer) BlockchainStatuses() []*ChainStatus {
+	var statuses []*ChainStatus
+	server.blockchains.Range(func(key, value interface{}) bool {
+		chainId, _ := key.(string)
+		blockchain, _ := value.(*Blockchain)
+		status := &ChainStatus{
+			ChainId: chainId,
+			Genesis: blockchain.genesis,
+			State:   ChainStateRunning,
+		}
+		if server.isPaused(chainId) {
+			status.State = ChainStatePaused
+		}
+		if lastBlock, err := blockchain.store.GetLastBlock(); err == nil && lastBlock != nil {
+			status.Height = lastBlock.Header.BlockHeight
+		}
+		statuses = append(statuses, status)
+		return true
+	})
+	return statuses
+}
+
+func (server *ChainMakerServer) isPaused(chainId string) bool {
+	_, ok := server.pausedChains.Load(chainId)
+	return ok
+}
+
+// checkLifecycleChainId rejects the chain ids which could not be used as the name of the data dir
+func checkLifecycleChainId(chainId string) error {
+	if chainId == "" || chainId == "." || chainId == ".." || filepath.Base(chainId) != chainId {
+		return fmt.Errorf("invalid chain id: %s", chainId)
+	}
+	return nil
+}
+
+// release closes the msg bus of the chain, and the store if closeStore.
+func (bc *Blockchain) release(closeStore bool) {
+	bc.msgBus.Close()
+	if !closeStore || bc.store == nil {
+		return
+	}
+	if err := bc.store.Close(); err != nil {
+		bc.log.Errorf("close store failed, %s", err)
+	}
+}
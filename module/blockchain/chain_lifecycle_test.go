@@ -0,0 +1,86 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckLifecycleChainId(t *testing.T) {
+	for _, chainId := range []string{"chain1", "chain_2", "chain-3"} {
+		if err := checkLifecycleChainId(chainId); err != nil {
+			t.Errorf("chain id %s should be valid, %s", chainId, err)
+		}
+	}
+	for _, chainId := range []string{"", ".", "..", "../chain1", "chain1/ledger", "/chain1"} {
+		if err := checkLifecycleChainId(chainId); err == nil {
+			t.Errorf("chain id %s should be invalid", chainId)
+		}
+	}
+}
+
+func TestParseLifecycleDeleteData(t *testing.T) {
+	cases := []struct {
+		params     map[string][]byte
+		deleteData bool
+		invalid    bool
+	}{
+		{params: map[string][]byte{}},
+		{params: map[string][]byte{LifecycleParamDeleteData: []byte("")}},
+		{params: map[string][]byte{LifecycleParamDeleteData: []byte("true")}, deleteData: true},
+		{params: map[string][]byte{LifecycleParamDeleteData: []byte("false")}},
+		{params: map[string][]byte{LifecycleParamDeleteData: []byte("yes")}, invalid: true},
+	}
+	for i, c := range cases {
+		deleteData, err := ParseLifecycleDeleteData(c.params)
+		if (err != nil) != c.invalid {
+			t.Errorf("case %d: unexpected error %v", i, err)
+			continue
+		}
+		if deleteData != c.deleteData {
+			t.Errorf("case %d: delete data %v, expected %v", i, deleteData, c.deleteData)
+		}
+	}
+}
+
+func TestChainDataPaths(t *testing.T) {
+	storageConfig := map[string]interface{}{
+		"store_path": "../data/org1/ledgerData1",
+		"blockdb_config": map[string]interface{}{
+			"provider":       "leveldb",
+			"leveldb_config": map[string]interface{}{"store_path": "../data/org1/block"},
+		},
+		"statedb_config": map[interface{}]interface{}{
+			"provider":        "badgerdb",
+			"badgerdb_config": map[interface{}]interface{}{"store_path": "../data/org1/state"},
+		},
+		"historydb_config": map[string]interface{}{
+			"provider":       "leveldb",
+			"leveldb_config": map[string]interface{}{"store_path": "../data/org1/ledgerData1"},
+		},
+		"resultdb_config": map[string]interface{}{
+			"provider":     "sql",
+			"sqldb_config": map[string]interface{}{"sqldb_type": "mysql"},
+		},
+		"disable_contract_eventdb": true,
+		"contract_eventdb_config":  map[string]interface{}{"provider": "sql"},
+	}
+	dataPaths, sqlDBs := chainDataPaths(storageConfig, "chain1")
+	expected := []string{
+		filepath.Join("../data/org1/ledgerData1", "chain1"),
+		filepath.Join("../data/org1/block", "chain1"),
+		filepath.Join("../data/org1/state", "chain1"),
+	}
+	if !reflect.DeepEqual(dataPaths, expected) {
+		t.Errorf("data paths %v, expected %v", dataPaths, expected)
+	}
+	if !reflect.DeepEqual(sqlDBs, []string{"resultdb_config"}) {
+		t.Errorf("sql dbs %v, expected [resultdb_config]", sqlDBs)
+	}
+}
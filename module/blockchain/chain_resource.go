@@ -0,0 +1,286 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"chainmaker.org/chainmaker-go/net"
+	"chainmaker.org/chainmaker/localconf/v2"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// chainResourcesConfigKey is the top-level section of the per-chain resource budgets
+	chainResourcesConfigKey = "chain_resources"
+	// defaultChainResourcesKey is the budget of the chains not configured
+	defaultChainResourcesKey = "default"
+	// netQoSConfigKey is the top-level section of the per-chain qos of the net msgs
+	netQoSConfigKey = "net_qos"
+	// peerScoreConfigKey is the top-level section of scoring the peers of all the chains
+	peerScoreConfigKey = "peer_score"
+)
+
+// ChainResourceBudget is the resources a chain could consume on the node, shared by all the chains,
+// so that a busy chain does not degrade the others. Zero means unlimited.
+type ChainResourceBudget struct {
+	// VMConcurrency caps the goroutines executing the txs of a block
+	VMConcurrency int `mapstructure:"vm_concurrency"`
+	// TxPoolMemoryMB caps the size of the txs submitted to the node and kept in the tx pool
+	TxPoolMemoryMB int `mapstructure:"txpool_memory_mb"`
+	// MaxSubscriptions caps the subscription streams of the chain
+	MaxSubscriptions int `mapstructure:"max_subscriptions"`
+	// SyncBandwidthKB caps the KB per second of the blocks served to the syncing peers
+	SyncBandwidthKB int `mapstructure:"sync_bandwidth_kb"`
+	// RpcQPS caps the requests per second of the chain
+	RpcQPS int `mapstructure:"rpc_qps"`
+}
+
+// LoadChainResourceBudget loads the budget of the chain in the top-level chain_resources section,
+// the budget of the default entry is used if the chain is not configured.
+func LoadChainResourceBudget(chainId string) (*ChainResourceBudget, error) {
+	budget := &ChainResourceBudget{}
+	budgets := make(map[string]*ChainResourceBudget)
+	ok, err := loadNodeConfigSection(chainResourcesConfigKey, &budgets)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return budget, nil
+	}
+	// the keys of the config file are lower cased by viper
+	if chainBudget, ok := budgets[strings.ToLower(chainId)]; ok && chainBudget != nil {
+		budget = chainBudget
+	} else if defaultBudget, ok := budgets[defaultChainResourcesKey]; ok && defaultBudget != nil {
+		budget = defaultBudget
+	}
+	if budget.VMConcurrency < 0 || budget.TxPoolMemoryMB < 0 || budget.MaxSubscriptions < 0 ||
+		budget.SyncBandwidthKB < 0 || budget.RpcQPS < 0 {
+		return nil, fmt.Errorf("invalid %s config of chain %s: negative budget", chainResourcesConfigKey, chainId)
+	}
+	return budget, nil
+}
+
+// LoadNetQoSConfig loads the qos of the net msgs of the chain in the top-level net_qos section,
+// the config of the default entry is used if the chain is not configured, nil if neither.
+func LoadNetQoSConfig(chainId string) (*net.QoSConfig, error) {
+	configs := make(map[string]*net.QoSConfig)
+	ok, err := loadNodeConfigSection(netQoSConfigKey, &configs)
+	if err != nil || !ok {
+		return nil, err
+	}
+	// the keys of the config file are lower cased by viper
+	config, ok := configs[strings.ToLower(chainId)]
+	if !ok || config == nil {
+		config = configs[defaultChainResourcesKey]
+	}
+	if config == nil {
+		return nil, nil
+	}
+	// the names of the net msg types are upper cased back
+	msgTypes := make(map[string]string, len(config.MsgTypes))
+	for msgType, class := range config.MsgTypes {
+		msgTypes[strings.ToUpper(msgType)] = class
+	}
+	config.MsgTypes = msgTypes
+	if err = config.Verify(); err != nil {
+		return nil, fmt.Errorf("invalid %s config of chain %s: %v", netQoSConfigKey, chainId, err)
+	}
+	return config, nil
+}
+
+// LoadPeerScoreConfig loads the config of scoring the peers in the top-level peer_score section,
+// nil means the peers are not scored.
+func LoadPeerScoreConfig() (*net.PeerScoreConfig, error) {
+	config := &net.PeerScoreConfig{}
+	ok, err := loadNodeConfigSection(peerScoreConfigKey, config)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return config, nil
+}
+
+var (
+	resourceMetricsOnce sync.Once
+
+	metricTxPoolMemory = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "chainmaker", Subsystem: "chain_resource", Name: "txpool_memory_bytes",
+		Help: "The size of the txs submitted to the node and kept in the tx pool",
+	}, []string{"chainId"})
+	metricTxPoolRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chainmaker", Subsystem: "chain_resource", Name: "txpool_rejected_total",
+		Help: "The number of the txs rejected for exceeding the tx pool memory budget",
+	}, []string{"chainId"})
+)
+
+func registerResourceMetrics() bool {
+	if !localconf.ChainMakerConfig.MonitorConfig.Enabled {
+		return false
+	}
+	resourceMetricsOnce.Do(func() {
+		prometheus.MustRegister(metricTxPoolMemory, metricTxPoolRejected)
+	})
+	return true
+}
+
+// reconcileInterval is the minimum interval between the scans of the txs counted in the tx pool,
+// the txs counted in the interval are not dropped by the scan since they may not be added yet
+const reconcileInterval = time.Second
+
+// budgetTxPool rejects the txs added when the txs of the chain in the pool exceed the memory budget.
+// The txs received from the peers are counted by admitTxMsg, which the net service filters the tx msgs by.
+type budgetTxPool struct {
+	protocol.TxPool
+
+	chainId  string
+	maxBytes int64
+	metrics  bool
+
+	mtx           sync.Mutex
+	usedBytes     int64
+	txSizes       map[string]*countedTx // tx id -> the txs counted
+	lastReconcile time.Time
+}
+
+type countedTx struct {
+	size      int64
+	countedAt time.Time
+}
+
+func newBudgetTxPool(txPool protocol.TxPool, chainId string, memoryMB int) *budgetTxPool {
+	return &budgetTxPool{
+		TxPool:   txPool,
+		chainId:  chainId,
+		maxBytes: int64(memoryMB) << 20,
+		metrics:  registerResourceMetrics(),
+		txSizes:  make(map[string]*countedTx),
+	}
+}
+
+// admitTxMsg counts the tx received from the peers, false if the memory budget does not allow it.
+// The tx is released once removed by the core engine or dropped by the scan if the pool refuses it.
+func (p *budgetTxPool) admitTxMsg(payload []byte) bool {
+	tx := &common.Transaction{}
+	if err := proto.Unmarshal(payload, tx); err != nil || tx.Payload == nil {
+		return true
+	}
+	if err := p.reserve(tx.Payload.TxId, int64(len(payload))); err != nil {
+		if p.metrics {
+			metricTxPoolRejected.WithLabelValues(p.chainId).Inc()
+		}
+		return false
+	}
+	return true
+}
+
+// AddTx adds the tx to the pool if the memory budget allows.
+func (p *budgetTxPool) AddTx(tx *common.Transaction, source protocol.TxSource) error {
+	txId := tx.Payload.TxId
+	size := int64(proto.Size(tx))
+	if err := p.reserve(txId, size); err != nil {
+		if p.metrics {
+			metricTxPoolRejected.WithLabelValues(p.chainId).Inc()
+		}
+		return err
+	}
+	if err := p.TxPool.AddTx(tx, source); err != nil {
+		p.release(txId)
+		return err
+	}
+	return nil
+}
+
+// RetryAndRemoveTxs releases the memory of the txs removed.
+func (p *budgetTxPool) RetryAndRemoveTxs(retryTxs []*common.Transaction, removeTxs []*common.Transaction) {
+	p.TxPool.RetryAndRemoveTxs(retryTxs, removeTxs)
+	for _, tx := range removeTxs {
+		p.release(tx.Payload.TxId)
+	}
+}
+
+func (p *budgetTxPool) reserve(txId string, size int64) error {
+	if p.tryReserve(txId, size, time.Now()) {
+		return nil
+	}
+	// the txs may leave the pool without being removed by the core engine, such as the expired ones
+	p.reconcile(time.Now())
+	if p.tryReserve(txId, size, time.Now()) {
+		return nil
+	}
+	return fmt.Errorf("the txs of chain %s in the tx pool exceed the memory budget of %d bytes",
+		p.chainId, p.maxBytes)
+}
+
+func (p *budgetTxPool) tryReserve(txId string, size int64, now time.Time) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if _, ok := p.txSizes[txId]; ok {
+		return true
+	}
+	if p.usedBytes+size > p.maxBytes {
+		return false
+	}
+	p.txSizes[txId] = &countedTx{size: size, countedAt: now}
+	p.usedBytes += size
+	p.setMetric()
+	return true
+}
+
+func (p *budgetTxPool) release(txId string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if counted, ok := p.txSizes[txId]; ok {
+		delete(p.txSizes, txId)
+		p.usedBytes -= counted.size
+		p.setMetric()
+	}
+}
+
+// reconcile drops the txs counted but not in the pool any more, at most once in reconcileInterval.
+// The pool is queried without the lock, so the txs are still added and released meanwhile.
+func (p *budgetTxPool) reconcile(now time.Time) {
+	p.mtx.Lock()
+	if now.Sub(p.lastReconcile) < reconcileInterval {
+		p.mtx.Unlock()
+		return
+	}
+	p.lastReconcile = now
+	txIds := make([]string, 0, len(p.txSizes))
+	for txId, counted := range p.txSizes {
+		if now.Sub(counted.countedAt) >= reconcileInterval {
+			txIds = append(txIds, txId)
+		}
+	}
+	p.mtx.Unlock()
+
+	txsInPool, _ := p.TxPool.GetTxsByTxIds(txIds)
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for _, txId := range txIds {
+		if _, ok := txsInPool[txId]; ok {
+			continue
+		}
+		if counted, ok := p.txSizes[txId]; ok {
+			p.usedBytes -= counted.size
+			delete(p.txSizes, txId)
+		}
+	}
+	p.setMetric()
+}
+
+func (p *budgetTxPool) setMetric() {
+	if p.metrics {
+		metricTxPoolMemory.WithLabelValues(p.chainId).Set(float64(p.usedBytes))
+	}
+}
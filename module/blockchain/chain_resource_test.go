@@ -0,0 +1,160 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker-go/net"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"github.com/gogo/protobuf/proto"
+)
+
+func TestLoadChainResourceBudget(t *testing.T) {
+	// unlimited if not configured, the budgets nested in storage are not read
+	useNodeConfig(t, "storage:\n  chain_resources:\n    default:\n      vm_concurrency: 4\n")
+	budget, err := LoadChainResourceBudget("chain1")
+	if err != nil || *budget != (ChainResourceBudget{}) {
+		t.Fatalf("unexpected budget %+v, %v", budget, err)
+	}
+
+	useNodeConfig(t, `chain_resources:
+  default:
+    vm_concurrency: 4
+    rpc_qps: 100
+  Chain1:
+    vm_concurrency: 16
+    txpool_memory_mb: 512
+    max_subscriptions: 10
+    sync_bandwidth_kb: 1024
+  chain3:
+    rpc_qps: -1
+`)
+	budget, err = LoadChainResourceBudget("Chain1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ChainResourceBudget{VMConcurrency: 16, TxPoolMemoryMB: 512, MaxSubscriptions: 10, SyncBandwidthKB: 1024}
+	if *budget != expected {
+		t.Errorf("budget of chain1 %+v, expected %+v", *budget, expected)
+	}
+	// the default entry applies to the chains not listed
+	budget, err = LoadChainResourceBudget("chain2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected = (ChainResourceBudget{VMConcurrency: 4, RpcQPS: 100}); *budget != expected {
+		t.Errorf("budget of chain2 %+v, expected %+v", *budget, expected)
+	}
+	if _, err = LoadChainResourceBudget("chain3"); err == nil {
+		t.Error("negative budget should be rejected")
+	}
+}
+
+func TestLoadNetQoSConfig(t *testing.T) {
+	useNodeConfig(t, "storage:\n  net_qos:\n    default:\n      bandwidth_kb: 1024\n")
+	if config, err := LoadNetQoSConfig("chain1"); config != nil || err != nil {
+		t.Fatalf("the qos nested in storage is not read, %+v, %v", config, err)
+	}
+
+	useNodeConfig(t, `net_qos:
+  default:
+    bandwidth_kb: 1024
+  Chain1:
+    max_inflight: 8
+    msg_types:
+      TXS: sync
+  chain3:
+    bandwidth_kb: -1
+`)
+	config, err := LoadNetQoSConfig("Chain1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxInflight != 8 || config.MsgTypes["TXS"] != net.QoSClassSync {
+		t.Errorf("unexpected qos of chain1 %+v", config)
+	}
+	// the default entry applies to the chains not listed
+	if config, err = LoadNetQoSConfig("chain2"); err != nil || config.BandwidthKB != 1024 {
+		t.Errorf("unexpected qos of chain2 %+v, %v", config, err)
+	}
+	if _, err = LoadNetQoSConfig("chain3"); err == nil {
+		t.Error("negative bandwidth should be rejected")
+	}
+}
+
+// fakeTxPool keeps the txs added in the map
+type fakeTxPool struct {
+	protocol.TxPool
+	txs map[string]*common.Transaction
+}
+
+func (p *fakeTxPool) AddTx(tx *common.Transaction, source protocol.TxSource) error {
+	p.txs[tx.Payload.TxId] = tx
+	return nil
+}
+
+func (p *fakeTxPool) RetryAndRemoveTxs(retryTxs []*common.Transaction, removeTxs []*common.Transaction) {
+	for _, tx := range removeTxs {
+		delete(p.txs, tx.Payload.TxId)
+	}
+}
+
+func (p *fakeTxPool) GetTxsByTxIds(txIds []string) (map[string]*common.Transaction, map[string]uint64) {
+	txs := make(map[string]*common.Transaction)
+	for _, txId := range txIds {
+		if tx, ok := p.txs[txId]; ok {
+			txs[txId] = tx
+		}
+	}
+	return txs, nil
+}
+
+func TestBudgetTxPool(t *testing.T) {
+	newTx := func(txId string) *common.Transaction {
+		return &common.Transaction{Payload: &common.Payload{TxId: txId}}
+	}
+	txPool := &fakeTxPool{txs: make(map[string]*common.Transaction)}
+	pool := newBudgetTxPool(txPool, "chain1", 1)
+	size := int64(proto.Size(newTx("tx1")))
+	pool.maxBytes = 2 * size
+
+	// the txs submitted and received from the peers share the budget
+	if err := pool.AddTx(newTx("tx1"), protocol.RPC); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := proto.Marshal(newTx("tx2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pool.admitTxMsg(payload) {
+		t.Fatal("tx2 received should be admitted")
+	}
+	if err = pool.AddTx(newTx("tx3"), protocol.RPC); err == nil {
+		t.Fatal("tx3 should exceed the budget")
+	}
+	if payload, _ = proto.Marshal(newTx("tx4")); pool.admitTxMsg(payload) {
+		t.Fatal("tx4 received should exceed the budget")
+	}
+
+	// tx2 is not added to the pool, it is dropped by the scan after the interval
+	pool.reconcile(time.Now())
+	if len(pool.txSizes) != 2 {
+		t.Fatalf("the txs counted in the interval should be kept, %d counted", len(pool.txSizes))
+	}
+	pool.reconcile(time.Now().Add(2 * reconcileInterval))
+	if _, ok := pool.txSizes["tx2"]; ok || pool.usedBytes != size {
+		t.Fatalf("tx2 should be dropped, %d bytes used", pool.usedBytes)
+	}
+	// the txs removed by the core engine are released
+	pool.RetryAndRemoveTxs(nil, []*common.Transaction{newTx("tx1")})
+	if pool.usedBytes != 0 {
+		t.Fatalf("tx1 should be released, %d bytes used", pool.usedBytes)
+	}
+}
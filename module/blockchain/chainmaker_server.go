@@ -15,7 +15,9 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 
+	"chainmaker.org/chainmaker-go/accesscontrol"
 	"chainmaker.org/chainmaker-go/net"
 	"chainmaker.org/chainmaker-go/subscriber"
 	"chainmaker.org/chainmaker/common/v2/crypto/asym"
@@ -39,7 +41,21 @@ type ChainMakerServer struct {
 	// blockchains known by this node
 	blockchains sync.Map // map[string]*Blockchain
 
+	// the chains paused by the lifecycle admin, map[string]struct{}
+	pausedChains sync.Map
+	// serializes the lifecycle operations of the chains
+	lifecycleLock sync.Mutex
+
 	readyC chan struct{}
+	// 1 if the node is draining for the graceful shutdown, accessed atomically
+	draining int32
+
+	// closed to stop relaying the cross-chain messages, nil if not relaying
+	crossChainStopC chan struct{}
+	// closed to stop proving the VRF of the DPoS candidates of the node, nil if not proving
+	dposVRFStopC chan struct{}
+	// the reloader of the local config, *LocalConfigReloader, nil if the config is not reloaded
+	localConfigReloader atomic.Value
 }
 
 // NewChainMakerServer create a new ChainMakerServer instance.
@@ -75,6 +91,9 @@ func (server *ChainMakerServer) initNet() error {
 		netType = protocol.Libp2p
 	case "liquid":
 		netType = protocol.Liquid
+	case "memory":
+		// the in-process net for running several nodes in one process, such as the end-to-end tests
+		netType = net.MemoryNetType
 	default:
 		return errors.New("unsupported net provider")
 	}
@@ -90,12 +109,14 @@ func (server *ChainMakerServer) initNet() error {
 			return err
 		}
 	}
+	// the net providers sign the TLS handshakes by the PEM key only, the signing keys of the node may be kept
+	// in the HSM or KMS, but not the net TLS key
 	log.Infof("load net tls key file path: %s", keyPath)
 
 	var certPath string
 	var pubKeyMode bool
 	switch strings.ToLower(authType) {
-	case protocol.PermissionedWithKey, protocol.Public:
+	case protocol.PermissionedWithKey, protocol.Public, accesscontrol.AuthTypeDID:
 		pubKeyMode = true
 	case protocol.PermissionedWithCert, protocol.Identity, emptyAuthType:
 		pubKeyMode = false
@@ -110,13 +131,24 @@ func (server *ChainMakerServer) initNet() error {
 	default:
 		return errors.New("wrong auth type")
 	}
+	// the net tls key may be encrypted, it is decrypted by the passphrase unlocked at startup
+	keyBytes, err := accesscontrol.ReadPrivateKeyFile(keyPath)
+	if err != nil {
+		return err
+	}
+	var certBytes []byte
+	if !pubKeyMode {
+		if certBytes, err = ioutil.ReadFile(certPath); err != nil {
+			return err
+		}
+	}
 	// new net
 	var netFactory net.NetFactory
 	server.net, err = netFactory.NewNet(
 		netType,
 		net.WithReadySignalC(server.readyC),
 		net.WithListenAddr(localconf.ChainMakerConfig.NetConfig.ListenAddr),
-		net.WithCrypto(pubKeyMode, keyPath, certPath),
+		net.WithCryptoPEM(pubKeyMode, keyBytes, certBytes),
 		net.WithPeerStreamPoolSize(localconf.ChainMakerConfig.NetConfig.PeerStreamPoolSize),
 		net.WithMaxPeerCountAllowed(localconf.ChainMakerConfig.NetConfig.MaxPeerCountAllow),
 		net.WithPeerEliminationStrategy(localconf.ChainMakerConfig.NetConfig.PeerEliminationStrategy),
@@ -132,14 +164,17 @@ func (server *ChainMakerServer) initNet() error {
 		return errors.New(errMsg)
 	}
 
-	// read key file, then set the NodeId of local config
-	file, err := ioutil.ReadFile(keyPath)
+	// the priorities of the msg flags are shared by the chains, they are set by the default entry of net_qos
+	qosConfig, err := LoadNetQoSConfig(defaultChainResourcesKey)
 	if err != nil {
 		return err
 	}
-	privateKey, err := asym.PrivateKeyFromPEM(file, nil)
+	net.SetMsgPriorities(server.net, qosConfig)
+
+	// set the NodeId of local config by the key
+	privateKey, err := asym.PrivateKeyFromPEM(keyBytes, nil)
 	if err != nil {
-		return err
+		return fmt.Errorf("load net tls key failed, the net tls key should be a PEM key file: %v", err)
 	}
 	nodeId, err := helper.CreateLibp2pPeerIdWithPrivateKey(privateKey)
 	if err != nil {
@@ -195,6 +230,7 @@ func (server *ChainMakerServer) newBlockchainTaskListener() {
 				continue
 			}
 			newBlockchain, _ := server.blockchains.Load(newChainId)
+			server.applyReloadedLocalConfig(newBlockchain.(*Blockchain))
 			go startBlockchain(newBlockchain.(*Blockchain))
 
 		}
@@ -202,23 +238,33 @@ func (server *ChainMakerServer) newBlockchainTaskListener() {
 }
 
 func (server *ChainMakerServer) initBlockchain(chainId, genesis string) error {
+	blockchain, err := server.newBlockchain(chainId, genesis)
+	if err != nil {
+		return err
+	}
+	server.blockchains.Store(chainId, blockchain)
+	log.Infof("init blockchain[%s] success!", chainId)
+	return nil
+}
+
+// newBlockchain create and init the blockchain, the modules init already are released if failed.
+func (server *ChainMakerServer) newBlockchain(chainId, genesis string) (*Blockchain, error) {
 	if !filepath.IsAbs(genesis) {
 		var err error
 		genesis, err = filepath.Abs(genesis)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 	log.Infof("load genesis file path of chain[%s]: %s", chainId, genesis)
 	blockchain := NewBlockchain(genesis, chainId, msgbus.NewMessageBus(), server.net)
 
 	if err := blockchain.Init(); err != nil {
+		blockchain.release(true)
 		errMsg := fmt.Sprintf("init blockchain[%s] failed, %s", chainId, err.Error())
-		return errors.New(errMsg)
+		return nil, errors.New(errMsg)
 	}
-	server.blockchains.Store(chainId, blockchain)
-	log.Infof("init blockchain[%s] success!", chainId)
-	return nil
+	return blockchain, nil
 }
 
 func startBlockchain(chain *Blockchain) {
@@ -245,13 +291,40 @@ func (server *ChainMakerServer) Start() error {
 		return true
 	})
 
-	// 3) ready
+	// 3) start relaying the cross-chain messages
+	crossChainConfig, err := LoadCrossChainConfig()
+	if err != nil {
+		return err
+	}
+	if crossChainConfig.Relay {
+		server.crossChainStopC = make(chan struct{})
+		go newCrossChainRelay(server, crossChainConfig).run(server.crossChainStopC)
+		log.Info("[CrossChain] start relaying cross-chain messages")
+	}
+
+	// 4) prove the VRF of the DPoS candidates of the node
+	if prover, err := newDPoSVRFProver(server); err != nil {
+		log.Warnf("[DPoS] the candidates of the node could not prove the VRF, they are elected for the seats "+
+			"left only, %s", err)
+	} else {
+		server.dposVRFStopC = make(chan struct{})
+		go prover.run(server.dposVRFStopC)
+	}
+
+	// 5) ready
 	close(server.readyC)
 	return nil
 }
 
 // Stop ChainMakerServer.
 func (server *ChainMakerServer) Stop() {
+	if server.crossChainStopC != nil {
+		close(server.crossChainStopC)
+	}
+	if server.dposVRFStopC != nil {
+		close(server.dposVRFStopC)
+	}
+
 	// stop all blockchains
 	var wg sync.WaitGroup
 	server.blockchains.Range(func(_, value interface{}) bool {
@@ -276,6 +349,9 @@ func (server *ChainMakerServer) Stop() {
 
 // AddTx add a transaction.
 func (server *ChainMakerServer) AddTx(chainId string, tx *common.Transaction, source protocol.TxSource) error {
+	if server.isPaused(chainId) {
+		return fmt.Errorf(chainPausedErrorTemplate, chainId)
+	}
 	if blockchain, ok := server.blockchains.Load(chainId); ok {
 		return blockchain.(*Blockchain).txPool.AddTx(tx, source)
 	}
@@ -352,6 +428,15 @@ func (server *ChainMakerServer) GetBlockchain(chainId string) (*Blockchain, erro
 	return nil, fmt.Errorf(chainIdNotFoundErrorTemplate, chainId)
 }
 
+// GetResourceBudget get the resources the chain which id is the given could consume on the node.
+func (server *ChainMakerServer) GetResourceBudget(chainId string) (*ChainResourceBudget, error) {
+	if blockchain, ok := server.blockchains.Load(chainId); ok {
+		return blockchain.(*Blockchain).resourceBudget, nil
+	}
+
+	return nil, fmt.Errorf(chainIdNotFoundErrorTemplate, chainId)
+}
+
 // GetAllAC get all protocol.AccessControlProvider of all the chains.
 func (server *ChainMakerServer) GetAllAC() ([]protocol.AccessControlProvider, error) {
 	var accessControls []protocol.AccessControlProvider
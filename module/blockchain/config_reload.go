@@ -0,0 +1,559 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chainmaker.org/chainmaker/localconf/v2"
+	configPb "chainmaker.org/chainmaker/pb-go/v2/config"
+)
+
+// configReloadConfigKey is the top-level section of reloading the local config at runtime
+const configReloadConfigKey = "config_reload"
+
+// ConfigReloadConfig is the config of reloading the local config at runtime.
+type ConfigReloadConfig struct {
+	// WatchIntervalSeconds is the interval of checking the config file changed, 0 means the config is
+	// reloaded on SIGHUP only
+	WatchIntervalSeconds int `mapstructure:"watch_interval_seconds"`
+}
+
+// LoadConfigReloadConfig loads the config of reloading the local config in the top-level config_reload section.
+func LoadConfigReloadConfig() (*ConfigReloadConfig, error) {
+	config := &ConfigReloadConfig{}
+	if _, err := loadNodeConfigSection(configReloadConfigKey, config); err != nil {
+		return nil, err
+	}
+	if config.WatchIntervalSeconds < 0 {
+		return nil, fmt.Errorf("invalid %s config: negative watch_interval_seconds", configReloadConfigKey)
+	}
+	return config, nil
+}
+
+// LocalConfigWatcher is implemented by the modules which apply the changes of the local config at runtime.
+// The changes of the fields watched by no module are rejected, they require restarting the node.
+//
+// localconf.ChainMakerConfig is read by the modules without locks, so it is not updated: the watchers keep the
+// values they apply by themselves, and the modules started later take them from LocalConfigReloader.Applied.
+// The tx pool sizes and the storage cache sizes are read once by the tx pool and the store, which could not
+// resize at runtime, and the net providers take the net blacklists before they start, so no module watches
+// them and their changes are rejected.
+type LocalConfigWatcher interface {
+	// Module returns the name of the module
+	Module() string
+	// WatchedLocalConfigs returns the paths of the fields of localconf.CMConfig applied at runtime, such as
+	// "RpcConfig.BlackList", a path covers the fields under it, the keys of the maps are elements of the paths
+	WatchedLocalConfigs() []string
+	// WatchLocalConfig applies the local config reloaded, which is the running one with the changes applied.
+	// The config is never modified, later changes are given in new configs.
+	WatchLocalConfig(config *localconf.CMConfig) error
+}
+
+// LocalConfigLoader loads the local config from the config file.
+type LocalConfigLoader func() (*localconf.CMConfig, error)
+
+// LocalConfigChange is a field of the local config changed in the config file.
+type LocalConfigChange struct {
+	// Key is the key of the field in the config file, such as rpc.blacklist.addresses
+	Key string
+	Old interface{}
+	New interface{}
+	// Module is the module applying the change, empty if the change is rejected
+	Module string
+
+	path []string // the path of the field in localconf.CMConfig
+}
+
+// String returns the key and the values of the change, the values of the secrets are masked.
+func (c *LocalConfigChange) String() string {
+	lowerKey := strings.ToLower(c.Key)
+	for _, secret := range []string{"password", "passwd", "secret", "dsn", "pin"} {
+		if strings.Contains(lowerKey, secret) {
+			return fmt.Sprintf("%s: *** -> ***", c.Key)
+		}
+	}
+	return fmt.Sprintf("%s: %v -> %v", c.Key, c.Old, c.New)
+}
+
+// LocalConfigReloadReport is the result of reloading the local config.
+type LocalConfigReloadReport struct {
+	// Applied are the changes applied at runtime
+	Applied []*LocalConfigChange
+	// Rejected are the changes not applied, which require restarting the node
+	Rejected []*LocalConfigChange
+	// Failed are the errors of the modules failed to apply the changes, by the module names
+	Failed map[string]string
+}
+
+// String returns the report of all the changes.
+func (r *LocalConfigReloadReport) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "local config reloaded, %d changes applied, %d rejected, %d modules failed",
+		len(r.Applied), len(r.Rejected), len(r.Failed))
+	for _, change := range r.Applied {
+		fmt.Fprintf(&buf, "\n  applied %s, by %s", change, change.Module)
+	}
+	for _, change := range r.Rejected {
+		fmt.Fprintf(&buf, "\n  rejected %s, not applicable at runtime, restart the node to apply it", change)
+	}
+	modules := make([]string, 0, len(r.Failed))
+	for module := range r.Failed {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		fmt.Fprintf(&buf, "\n  failed %s: %s", module, r.Failed[module])
+	}
+	return buf.String()
+}
+
+// LocalConfigReloader reloads the local config at runtime, it applies the changes watched by the modules
+// and rejects the others.
+type LocalConfigReloader struct {
+	lock             sync.Mutex
+	load             LocalConfigLoader
+	refreshLogLevels func() error
+	// loaded is the config running, the one loaded last time with the changes rejected reverted,
+	// so they are reported again
+	loaded   *localconf.CMConfig
+	watchers []LocalConfigWatcher
+}
+
+// NewLocalConfigReloader creates a reloader of the local config, the config loaded now is the base of the
+// changes reloaded later.
+func NewLocalConfigReloader(load LocalConfigLoader) (*LocalConfigReloader, error) {
+	loaded, err := load()
+	if err != nil {
+		return nil, fmt.Errorf("load local config failed, %v", err)
+	}
+	return &LocalConfigReloader{
+		load:             load,
+		refreshLogLevels: localconf.RefreshLogLevelsConfig,
+		loaded:           loaded,
+		watchers:         []LocalConfigWatcher{debugConfigWatcher{update: localconf.UpdateDebugConfig}},
+	}, nil
+}
+
+// Applied returns the local config running, the one loaded at start with the changes applied since,
+// which the modules started after the reloads apply. It is never modified.
+func (r *LocalConfigReloader) Applied() *localconf.CMConfig {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.loaded
+}
+
+// RegisterWatcher registers the modules applying the changes of the local config.
+func (r *LocalConfigReloader) RegisterWatcher(watchers ...LocalConfigWatcher) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.watchers = append(r.watchers, watchers...)
+}
+
+// Reload loads the local config and applies the changes watched by the modules, the log levels are refreshed
+// as well since the log config file may change alone.
+func (r *LocalConfigReloader) Reload() (*LocalConfigReloadReport, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	config, err := r.load()
+	if err != nil {
+		return nil, fmt.Errorf("load local config failed, %v", err)
+	}
+	report := &LocalConfigReloadReport{Failed: make(map[string]string)}
+	notified := make([]bool, len(r.watchers))
+	// the config given to the watchers last time is not modified
+	applied := *r.loaded
+	for _, change := range diffLocalConfig(r.loaded, config) {
+		i := r.watcherOf(change.path)
+		if i < 0 {
+			report.Rejected = append(report.Rejected, change)
+			continue
+		}
+		if err = setLocalConfig(&applied, change.path, change.New); err != nil {
+			report.Rejected = append(report.Rejected, change)
+			report.Failed[r.watchers[i].Module()] = fmt.Sprintf("set %s failed, %v", change.Key, err)
+			continue
+		}
+		change.Module = r.watchers[i].Module()
+		report.Applied = append(report.Applied, change)
+		notified[i] = true
+	}
+
+	r.loaded = &applied
+
+	if err = r.refreshLogLevels(); err != nil {
+		report.Failed["log"] = fmt.Sprintf("refresh log levels failed, %v", err)
+	}
+	for i, watcher := range r.watchers {
+		if !notified[i] {
+			continue
+		}
+		if err = watcher.WatchLocalConfig(r.loaded); err != nil {
+			report.Failed[watcher.Module()] = err.Error()
+		}
+	}
+	return report, nil
+}
+
+// Watch reloads the local config when the content of the config file changes, until the stopC is closed.
+func (r *LocalConfigReloader) Watch(configFile string, interval time.Duration, stopC <-chan struct{}) {
+	lastHash, _ := fileHash(configFile)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			hash, err := fileHash(configFile)
+			if err != nil {
+				log.Warnf("check config file %s failed, %s", configFile, err)
+				continue
+			}
+			if bytes.Equal(hash, lastHash) {
+				continue
+			}
+			lastHash = hash
+			log.Infof("config file %s changed, reload it", configFile)
+			r.ReloadAndReport()
+		case <-stopC:
+			return
+		}
+	}
+}
+
+// ReloadAndReport reloads the local config and logs the report.
+func (r *LocalConfigReloader) ReloadAndReport() {
+	report, err := r.Reload()
+	if err != nil {
+		log.Errorf("reload local config failed, %s", err)
+		return
+	}
+	if len(report.Rejected) > 0 || len(report.Failed) > 0 {
+		log.Warn(report.String())
+		return
+	}
+	log.Info(report.String())
+}
+
+// watcherOf returns the index of the watcher applying the field at the path, -1 if none
+func (r *LocalConfigReloader) watcherOf(path []string) int {
+	for i, watcher := range r.watchers {
+		for _, watched := range watcher.WatchedLocalConfigs() {
+			watchedPath := strings.Split(watched, ".")
+			if len(watchedPath) <= len(path) && reflect.DeepEqual(watchedPath, path[:len(watchedPath)]) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func fileHash(file string) ([]byte, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(content)
+	return hash[:], nil
+}
+
+// syncTickerResetter is implemented by the sync services which could reset their tickers at runtime
+type syncTickerResetter interface {
+	ResetTickers(config *localconf.CMConfig)
+}
+
+// Module returns the name of the module
+func (server *ChainMakerServer) Module() string {
+	return "ChainMakerServer"
+}
+
+// WatchedLocalConfigs returns the tickers of the sync services
+func (server *ChainMakerServer) WatchedLocalConfigs() []string {
+	return []string{"SyncConfig.ProcessBlockTick", "SyncConfig.SchedulerTick", "SyncConfig.NodeStatusTick",
+		"SyncConfig.LivenessTick", "SyncConfig.DataDetectionTick"}
+}
+
+// SetLocalConfigReloader sets the reloader of the local config, the chains joined or resumed later apply
+// the config it applied, since their sync services read the tickers of localconf.ChainMakerConfig at start.
+func (server *ChainMakerServer) SetLocalConfigReloader(reloader *LocalConfigReloader) {
+	server.localConfigReloader.Store(reloader)
+}
+
+// applyReloadedLocalConfig resets the tickers of the sync service of the chain started after the reloads
+// to the ones applied, the chain is stored before, so that a reload meanwhile resets it as well
+func (server *ChainMakerServer) applyReloadedLocalConfig(chain *Blockchain) {
+	reloader, _ := server.localConfigReloader.Load().(*LocalConfigReloader)
+	if reloader == nil {
+		return
+	}
+	if resetter, ok := chain.syncServer.(syncTickerResetter); ok {
+		resetter.ResetTickers(reloader.Applied())
+	}
+}
+
+// WatchLocalConfig resets the tickers of the sync services of all the chains
+func (server *ChainMakerServer) WatchLocalConfig(config *localconf.CMConfig) error {
+	server.blockchains.Range(func(_, value interface{}) bool {
+		chain, _ := value.(*Blockchain)
+		if resetter, ok := chain.syncServer.(syncTickerResetter); ok {
+			resetter.ResetTickers(config)
+		}
+		return true
+	})
+	return nil
+}
+
+// debugConfigWatcher applies the debug switches by localconf.UpdateDebugConfig, as the UpdateDebugConfig rpc
+// does, the modules read them from localconf.ChainMakerConfig every time. The log levels need no watcher,
+// they are refreshed from the log config file on every reload.
+type debugConfigWatcher struct {
+	update func(pairs []*configPb.ConfigKeyValue) error
+}
+
+// Module returns the name of the module
+func (debugConfigWatcher) Module() string {
+	return "DebugConfig"
+}
+
+// WatchedLocalConfigs returns the debug switches
+func (debugConfigWatcher) WatchedLocalConfigs() []string {
+	var watched []string
+	for _, name := range debugSwitches() {
+		watched = append(watched, "DebugConfig."+name)
+	}
+	return watched
+}
+
+// WatchLocalConfig updates the debug switches to the ones reloaded
+func (w debugConfigWatcher) WatchLocalConfig(config *localconf.CMConfig) error {
+	debugConfig := reflect.Indirect(reflect.ValueOf(config.DebugConfig))
+	if !debugConfig.IsValid() {
+		return nil
+	}
+	var pairs []*configPb.ConfigKeyValue
+	for _, name := range debugSwitches() {
+		pairs = append(pairs, &configPb.ConfigKeyValue{
+			Key:   name,
+			Value: strconv.FormatBool(debugConfig.FieldByName(name).Bool()),
+		})
+	}
+	return w.update(pairs)
+}
+
+// debugSwitches returns the names of the bool fields of the debug config
+func debugSwitches() []string {
+	var switches []string
+	debugConfigType := reflect.TypeOf(localconf.CMConfig{}.DebugConfig)
+	if debugConfigType.Kind() == reflect.Ptr {
+		debugConfigType = debugConfigType.Elem()
+	}
+	for i := 0; i < debugConfigType.NumField(); i++ {
+		if field := debugConfigType.Field(i); field.Type.Kind() == reflect.Bool && field.PkgPath == "" {
+			switches = append(switches, field.Name)
+		}
+	}
+	return switches
+}
+
+// diffLocalConfig returns the changed fields of the local config, the structs and the maps are compared
+// by their fields and keys, the other values are compared as a whole
+func diffLocalConfig(old, new *localconf.CMConfig) []*LocalConfigChange {
+	var changes []*LocalConfigChange
+	diffConfigValue(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), nil, nil, &changes)
+	return changes
+}
+
+func diffConfigValue(old, new reflect.Value, path, keys []string, changes *[]*LocalConfigChange) {
+	old, new = indirectConfigValue(old), indirectConfigValue(new)
+	switch {
+	case old.Kind() == reflect.Struct && new.Kind() == reflect.Struct && old.Type() == new.Type():
+		for i := 0; i < old.NumField(); i++ {
+			field := old.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			tag := strings.Split(field.Tag.Get("mapstructure"), ",")
+			if tag[0] == "-" {
+				continue
+			}
+			fieldKeys := keys
+			if !strings.Contains(field.Tag.Get("mapstructure"), "squash") {
+				key := tag[0]
+				if key == "" {
+					key = strings.ToLower(field.Name)
+				}
+				fieldKeys = append(keys[:len(keys):len(keys)], key)
+			}
+			diffConfigValue(old.Field(i), new.Field(i), append(path[:len(path):len(path)], field.Name),
+				fieldKeys, changes)
+		}
+		return
+	case isStringMap(old) && isStringMap(new):
+		mapKeys := make(map[string]struct{})
+		for _, key := range old.MapKeys() {
+			mapKeys[key.String()] = struct{}{}
+		}
+		for _, key := range new.MapKeys() {
+			mapKeys[key.String()] = struct{}{}
+		}
+		sortedKeys := make([]string, 0, len(mapKeys))
+		for key := range mapKeys {
+			sortedKeys = append(sortedKeys, key)
+		}
+		sort.Strings(sortedKeys)
+		for _, key := range sortedKeys {
+			diffConfigValue(old.MapIndex(reflect.ValueOf(key).Convert(old.Type().Key())),
+				new.MapIndex(reflect.ValueOf(key).Convert(new.Type().Key())),
+				append(path[:len(path):len(path)], key), append(keys[:len(keys):len(keys)], key), changes)
+		}
+		return
+	}
+	oldValue, newValue := configValueOf(old), configValueOf(new)
+	if reflect.DeepEqual(oldValue, newValue) || (isEmptyConfigValue(old) && isEmptyConfigValue(new)) {
+		return
+	}
+	*changes = append(*changes, &LocalConfigChange{
+		Key:  strings.Join(keys, "."),
+		Old:  oldValue,
+		New:  newValue,
+		path: path,
+	})
+}
+
+// setLocalConfig sets the field at the path of the config, the maps and the pointers on the path are copied
+// instead of being modified, since they are shared with the configs given to the watchers before.
+// The field is deleted from the map if the value is nil.
+func setLocalConfig(config *localconf.CMConfig, path []string, value interface{}) error {
+	if len(path) == 0 {
+		return fmt.Errorf("empty path")
+	}
+	field := reflect.ValueOf(config).Elem().FieldByName(path[0])
+	if !field.IsValid() {
+		return fmt.Errorf("unknown field %s", path[0])
+	}
+	newField, err := setConfigValue(field, path[1:], value)
+	if err != nil {
+		return err
+	}
+	field.Set(newField)
+	return nil
+}
+
+// setConfigValue returns a copy of v with the field at the path set to the value
+func setConfigValue(v reflect.Value, path []string, value interface{}) (reflect.Value, error) {
+	if len(path) == 0 {
+		if value == nil {
+			return reflect.Zero(v.Type()), nil
+		}
+		newValue := reflect.ValueOf(value)
+		if !newValue.Type().AssignableTo(v.Type()) {
+			return reflect.Value{}, fmt.Errorf("%v is not a %s", value, v.Type())
+		}
+		return newValue, nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return reflect.Value{}, fmt.Errorf("nil value at %s", path[0])
+		}
+		return setConfigValue(v.Elem(), path, value)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Value{}, fmt.Errorf("nil value at %s", path[0])
+		}
+		elem, err := setConfigValue(v.Elem(), path, value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		newPtr := reflect.New(v.Type().Elem())
+		newPtr.Elem().Set(elem)
+		return newPtr, nil
+	case reflect.Struct:
+		newStruct := reflect.New(v.Type()).Elem()
+		newStruct.Set(v)
+		field := newStruct.FieldByName(path[0])
+		if !field.IsValid() {
+			return reflect.Value{}, fmt.Errorf("unknown field %s", path[0])
+		}
+		newField, err := setConfigValue(field, path[1:], value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		field.Set(newField)
+		return newStruct, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, fmt.Errorf("unsupported map at %s", path[0])
+		}
+		newMap := reflect.MakeMapWithSize(v.Type(), v.Len()+1)
+		iter := v.MapRange()
+		for iter.Next() {
+			newMap.SetMapIndex(iter.Key(), iter.Value())
+		}
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		if len(path) == 1 && value == nil {
+			newMap.SetMapIndex(key, reflect.Value{})
+			return newMap, nil
+		}
+		elem := v.MapIndex(key)
+		if !elem.IsValid() {
+			if len(path) > 1 {
+				return reflect.Value{}, fmt.Errorf("no key %s", path[0])
+			}
+			elem = reflect.Zero(v.Type().Elem())
+		}
+		newElem, err := setConfigValue(elem, path[1:], value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		newMap.SetMapIndex(key, newElem)
+		return newMap, nil
+	}
+	return reflect.Value{}, fmt.Errorf("unsupported %s at %s", v.Kind(), path[0])
+}
+
+func indirectConfigValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func isStringMap(v reflect.Value) bool {
+	return v.IsValid() && v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String
+}
+
+func configValueOf(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// isEmptyConfigValue returns true for the values absent, nil and the empty slices, which are the same in the
+// config file
+func isEmptyConfigValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"chainmaker.org/chainmaker/localconf/v2"
+	configPb "chainmaker.org/chainmaker/pb-go/v2/config"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+type testConfigWatcher struct {
+	watched int
+	config  *localconf.CMConfig
+}
+
+func (w *testConfigWatcher) Module() string {
+	return "test"
+}
+
+func (w *testConfigWatcher) WatchedLocalConfigs() []string {
+	return []string{"StorageConfig.cache"}
+}
+
+func (w *testConfigWatcher) WatchLocalConfig(config *localconf.CMConfig) error {
+	w.watched++
+	w.config = config
+	return nil
+}
+
+func TestLocalConfigReloader(t *testing.T) {
+	newStorageConfig := func(cacheSize int, storePath string) map[string]interface{} {
+		return map[string]interface{}{
+			"store_path": storePath,
+			"cache":      map[string]interface{}{"size": cacheSize, "shards": 16},
+		}
+	}
+	fileStorageConfig, fileAuthType := newStorageConfig(60, "a"), "permissionedWithCert"
+	load := func() (*localconf.CMConfig, error) {
+		return &localconf.CMConfig{StorageConfig: fileStorageConfig, AuthType: fileAuthType}, nil
+	}
+
+	reloader, err := NewLocalConfigReloader(load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader.refreshLogLevels = func() error { return nil }
+	watcher := &testConfigWatcher{}
+	reloader.RegisterWatcher(watcher)
+
+	// nothing changed
+	report, err := reloader.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Applied) != 0 || len(report.Rejected) != 0 || watcher.watched != 0 {
+		t.Fatalf("unexpected report %s", report)
+	}
+
+	// the changes watched are applied, the others are rejected
+	fileStorageConfig, fileAuthType = newStorageConfig(120, "b"), "public"
+	if report, err = reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Applied) != 1 || !strings.HasSuffix(report.Applied[0].Key, "cache.size") ||
+		report.Applied[0].Module != watcher.Module() || len(report.Rejected) != 2 || len(report.Failed) != 0 {
+		t.Fatalf("unexpected report %s", report)
+	}
+	if watcher.watched != 1 {
+		t.Errorf("watcher notified %d times, expected 1", watcher.watched)
+	}
+	applied := watcher.config
+	cache, _ := applied.StorageConfig["cache"].(map[string]interface{})
+	if cache["size"] != 120 || cache["shards"] != 16 || applied.StorageConfig["store_path"] != "a" {
+		t.Errorf("unexpected storage config %v", applied.StorageConfig)
+	}
+	if applied.AuthType != "permissionedWithCert" {
+		t.Errorf("the rejected auth type is applied")
+	}
+	// the global config read without locks is left to the watchers
+	if _, ok := localconf.ChainMakerConfig.StorageConfig["cache"]; ok {
+		t.Error("the global storage config is modified")
+	}
+
+	// the rejected changes are reported again
+	if report, err = reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Applied) != 0 || len(report.Rejected) != 2 || watcher.watched != 1 {
+		t.Fatalf("unexpected report %s", report)
+	}
+
+	// the config given to the watchers is not modified by the later changes
+	fileStorageConfig = newStorageConfig(180, "b")
+	if report, err = reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Applied) != 1 || watcher.watched != 2 || watcher.config == applied {
+		t.Fatalf("unexpected report %s", report)
+	}
+	if cache["size"] != 120 || applied.StorageConfig["cache"].(map[string]interface{})["size"] != 120 {
+		t.Error("the config given to the watcher before is modified")
+	}
+}
+
+func TestDebugConfigWatcher(t *testing.T) {
+	var updated []*configPb.ConfigKeyValue
+	watcher := debugConfigWatcher{update: func(pairs []*configPb.ConfigKeyValue) error {
+		updated = pairs
+		return nil
+	}}
+	if len(watcher.WatchedLocalConfigs()) == 0 {
+		t.Fatal("no debug switch watched")
+	}
+
+	config := &localconf.CMConfig{}
+	config.DebugConfig.IsProposeDelay = true
+	if err := watcher.WatchLocalConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != len(watcher.WatchedLocalConfigs()) {
+		t.Fatalf("%d debug switches updated, expected all of them", len(updated))
+	}
+	for _, pair := range updated {
+		if expected := strconv.FormatBool(pair.Key == "IsProposeDelay"); pair.Value != expected {
+			t.Errorf("debug switch %s updated to %s, expected %s", pair.Key, pair.Value, expected)
+		}
+	}
+}
+
+func TestLoadConfigReloadConfig(t *testing.T) {
+	useNodeConfig(t, "config_reload:\n  watch_interval_seconds: 10\n")
+	config, err := LoadConfigReloadConfig()
+	if err != nil || config.WatchIntervalSeconds != 10 {
+		t.Fatalf("unexpected config reload config %+v, %v", config, err)
+	}
+
+	useNodeConfig(t, "config_reload:\n  watch_interval_seconds: -1\n")
+	if _, err = LoadConfigReloadConfig(); err == nil {
+		t.Error("negative watch interval should be rejected")
+	}
+}
+
+// testTickerResetter records the tickers reset
+type testTickerResetter struct {
+	protocol.SyncService
+	config *localconf.CMConfig
+}
+
+func (r *testTickerResetter) ResetTickers(config *localconf.CMConfig) {
+	r.config = config
+}
+
+func TestApplyReloadedLocalConfig(t *testing.T) {
+	nodeStatusTick := 2.0
+	load := func() (*localconf.CMConfig, error) {
+		config := &localconf.CMConfig{}
+		config.SyncConfig.NodeStatusTick = nodeStatusTick
+		return config, nil
+	}
+	reloader, err := NewLocalConfigReloader(load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader.refreshLogLevels = func() error { return nil }
+	server := &ChainMakerServer{}
+	reloader.RegisterWatcher(server)
+
+	// the chains started before the reloader is set keep the tickers of the config file
+	chain := &Blockchain{syncServer: &testTickerResetter{}}
+	server.applyReloadedLocalConfig(chain)
+	if chain.syncServer.(*testTickerResetter).config != nil {
+		t.Fatal("the tickers are reset without a reloader")
+	}
+
+	// a chain joined after a reload takes the tickers applied
+	server.SetLocalConfigReloader(reloader)
+	nodeStatusTick = 5
+	if _, err = reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	server.applyReloadedLocalConfig(chain)
+	if config := chain.syncServer.(*testTickerResetter).config; config == nil || config.SyncConfig.NodeStatusTick != 5 {
+		t.Errorf("the tickers applied are not reset, %+v", config)
+	}
+}
@@ -0,0 +1,342 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"chainmaker.org/chainmaker-go/accesscontrol"
+	"chainmaker.org/chainmaker-go/core/crosschain"
+	"chainmaker.org/chainmaker/localconf/v2"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"github.com/gogo/protobuf/proto"
+)
+
+// crossChainConfigKey is the top-level section of relaying the cross-chain messages in the config file
+const crossChainConfigKey = "cross_chain"
+
+const (
+	// the max blocks of a chain scanned in a pass, the rest are scanned in the next passes
+	crossChainMaxScanBlocks = 100
+	// the scanning stops when the pending jobs reach the limit, until they are committed
+	crossChainMaxPendingJobs = 10000
+)
+
+// CrossChainConfig is the config of relaying the cross-chain messages between the chains of the node.
+type CrossChainConfig struct {
+	// Relay relays the messages of the chains of the node, the node should be a consensus node of the chains
+	Relay bool `mapstructure:"relay"`
+	// ScanIntervalSeconds is the interval of scanning the new blocks and submitting the txs
+	ScanIntervalSeconds int `mapstructure:"scan_interval_seconds"`
+	// RescanBlocks is the number of the blocks rescanned at startup, to relay the messages missed while stopped
+	RescanBlocks uint64 `mapstructure:"rescan_blocks"`
+	// RetryIntervalSeconds is the interval of resubmitting a tx not committed
+	RetryIntervalSeconds int `mapstructure:"retry_interval_seconds"`
+	// SignCertFile and SignKeyFile are the cert and key signing the txs, the identity of the node by default.
+	// The txs are accepted if the member is a consensus node or a relayer in cross_chain.relayers of the chain
+	// config, and its org runs the consensus of the chain.
+	SignCertFile string `mapstructure:"sign_cert_file"`
+	SignKeyFile  string `mapstructure:"sign_key_file"`
+}
+
+// LoadCrossChainConfig loads the config of relaying the cross-chain messages in the top-level cross_chain section,
+// the messages are not relayed if not configured.
+func LoadCrossChainConfig() (*CrossChainConfig, error) {
+	config := &CrossChainConfig{ScanIntervalSeconds: 1, RescanBlocks: 100, RetryIntervalSeconds: 30}
+	ok, err := loadNodeConfigSection(crossChainConfigKey, config)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return config, nil
+	}
+	if config.ScanIntervalSeconds <= 0 || config.RetryIntervalSeconds <= 0 {
+		return nil, fmt.Errorf("invalid %s config: non-positive scan_interval_seconds or retry_interval_seconds",
+			crossChainConfigKey)
+	}
+	if (config.SignCertFile == "") != (config.SignKeyFile == "") {
+		return nil, fmt.Errorf("invalid %s config: sign_cert_file and sign_key_file should be set both",
+			crossChainConfigKey)
+	}
+	return config, nil
+}
+
+// crossChainJob is a DELIVER or ACK tx to submit to a chain of the node
+type crossChainJob struct {
+	chainId string
+	txId    string
+	method  string
+	params  []*common.KeyValuePair
+	// trustedChainId is the chain proved by the tx, which should be trusted by the chain of the tx
+	trustedChainId string
+	// submitTime is the time of the last submission, zero if not submitted
+	submitTime time.Time
+}
+
+// crossChainRelay scans the committed blocks of the chains of the node for the cross-chain messages and the
+// deliveries, and submits the DELIVER txs to the destination chains and the ACK txs to the source chains.
+// The messages between the chains not hosted by the node both are not relayed.
+type crossChainRelay struct {
+	server *ChainMakerServer
+	config *CrossChainConfig
+	// the last height scanned of the chains
+	scanned map[string]uint64
+	// the pending jobs by the tx id
+	jobs map[string]*crossChainJob
+	// the members signing the txs of the chains, when the sign cert is configured
+	signers map[string]protocol.SigningMember
+}
+
+func newCrossChainRelay(server *ChainMakerServer, config *CrossChainConfig) *crossChainRelay {
+	return &crossChainRelay{
+		server:  server,
+		config:  config,
+		scanned: make(map[string]uint64),
+		jobs:    make(map[string]*crossChainJob),
+		signers: make(map[string]protocol.SigningMember),
+	}
+}
+
+// run relays the messages until the stopC is closed
+func (r *crossChainRelay) run(stopC <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(r.config.ScanIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.pass()
+		case <-stopC:
+			return
+		}
+	}
+}
+
+// pass scans the new blocks of the chains and submits the pending txs, the chains paused are skipped
+func (r *crossChainRelay) pass() {
+	r.server.lifecycleLock.Lock()
+	defer r.server.lifecycleLock.Unlock()
+	r.server.blockchains.Range(func(key, value interface{}) bool {
+		chainId, _ := key.(string)
+		if !r.server.isPaused(chainId) {
+			r.scan(value.(*Blockchain))
+		}
+		return true
+	})
+	for txId, job := range r.jobs {
+		if done := r.submit(job); done {
+			delete(r.jobs, txId)
+		}
+	}
+}
+
+// scan scans the blocks committed since the last pass
+func (r *crossChainRelay) scan(bc *Blockchain) {
+	lastBlock := bc.ledgerCache.GetLastCommittedBlock()
+	if lastBlock == nil {
+		return
+	}
+	last := lastBlock.Header.BlockHeight
+	scanned, ok := r.scanned[bc.chainId]
+	if !ok {
+		if last > r.config.RescanBlocks {
+			scanned = last - r.config.RescanBlocks
+		}
+		r.scanned[bc.chainId] = scanned
+	}
+	for height := scanned + 1; height <= last && height <= scanned+crossChainMaxScanBlocks; height++ {
+		if len(r.jobs) >= crossChainMaxPendingJobs {
+			return
+		}
+		block, err := bc.store.GetBlock(height)
+		if err != nil || block == nil {
+			bc.log.Warnf("cross chain relay get block %d failed, %v", height, err)
+			return
+		}
+		r.scanBlock(bc, block)
+		r.scanned[bc.chainId] = height
+	}
+}
+
+// scanBlock adds the jobs of the messages sent and delivered by the txs of the block
+func (r *crossChainRelay) scanBlock(bc *Blockchain, block *common.Block) {
+	for i, tx := range block.Txs {
+		var jobs []*crossChainJob
+		for _, msg := range crosschain.MessagesOf(bc.chainId, tx) {
+			if _, ok := r.server.blockchains.Load(msg.DstChainId); ok {
+				jobs = append(jobs, &crossChainJob{chainId: msg.DstChainId, txId: crosschain.DeliverTxId(msg.Id),
+					method: crosschain.MethodDeliver, trustedChainId: bc.chainId})
+			}
+		}
+		if msg, ok := deliveredMessage(tx); ok && msg.DstChainId == bc.chainId {
+			if _, ok = r.server.blockchains.Load(msg.SrcChainId); ok {
+				jobs = append(jobs, &crossChainJob{chainId: msg.SrcChainId, txId: crosschain.AckTxId(msg.Id),
+					method: crosschain.MethodAck, trustedChainId: bc.chainId})
+			}
+		}
+		if len(jobs) == 0 {
+			continue
+		}
+
+		proof, err := r.buildProof(bc, block, i)
+		if err != nil {
+			bc.log.Errorf("cross chain relay build the proof of tx %s failed, %s", tx.Payload.TxId, err)
+			continue
+		}
+		for _, job := range jobs {
+			msg, err := r.messageOf(job, bc.chainId, tx)
+			if err != nil {
+				bc.log.Errorf("cross chain relay tx %s failed, %s", tx.Payload.TxId, err)
+				continue
+			}
+			job.params = []*common.KeyValuePair{
+				{Key: crosschain.ParamMessage, Value: msg},
+				{Key: crosschain.ParamProof, Value: proof},
+			}
+			r.jobs[job.txId] = job
+		}
+	}
+}
+
+// messageOf returns the message param of the job by the tx proved
+func (r *crossChainRelay) messageOf(job *crossChainJob, chainId string, tx *common.Transaction) ([]byte, error) {
+	if job.method == crosschain.MethodAck {
+		msg, _ := deliveredMessage(tx)
+		return json.Marshal(msg)
+	}
+	for _, msg := range crosschain.MessagesOf(chainId, tx) {
+		if crosschain.DeliverTxId(msg.Id) == job.txId {
+			return json.Marshal(msg)
+		}
+	}
+	return nil, fmt.Errorf("message of tx %s not found", job.txId)
+}
+
+// buildProof builds the proof of the tx of the index in the block, with the quorum cert of the block
+func (r *crossChainRelay) buildProof(bc *Blockchain, block *common.Block, txIndex int) ([]byte, error) {
+	proof, err := crosschain.BuildProof(block, txIndex, bc.chainConf.ChainConfig().Crypto.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(proof)
+}
+
+// submit submits the tx of the job if it is not committed, it returns true if the job is done
+func (r *crossChainRelay) submit(job *crossChainJob) bool {
+	value, ok := r.server.blockchains.Load(job.chainId)
+	if !ok {
+		return true
+	}
+	if r.server.isPaused(job.chainId) {
+		return false
+	}
+	bc, _ := value.(*Blockchain)
+	exist, err := bc.store.TxExists(job.txId)
+	if err != nil {
+		bc.log.Warnf("cross chain relay check tx %s failed, %s", job.txId, err)
+		return false
+	}
+	if exist {
+		return true
+	}
+	if _, ok = crosschain.LoadRemoteChain(bc.chainConf.ChainConfig(), job.trustedChainId); !ok {
+		// waits for the chain config trusting the chain proved
+		return false
+	}
+	if !job.submitTime.IsZero() &&
+		time.Since(job.submitTime) < time.Duration(r.config.RetryIntervalSeconds)*time.Second {
+		return false
+	}
+	job.submitTime = time.Now()
+
+	tx, err := r.newTx(bc, job)
+	if err != nil {
+		bc.log.Errorf("cross chain relay new tx %s failed, %s", job.txId, err)
+		return false
+	}
+	if err = r.server.AddTx(job.chainId, tx, protocol.RPC); err != nil {
+		bc.log.Warnf("cross chain relay add tx %s failed, %s", job.txId, err)
+		return false
+	}
+	bc.log.Infof("cross chain relay submitted %s tx %s", job.method, job.txId)
+	return false
+}
+
+// newTx builds the tx of the job signed by the signer of the chain
+func (r *crossChainRelay) newTx(bc *Blockchain, job *crossChainJob) (*common.Transaction, error) {
+	signer, err := r.signer(bc)
+	if err != nil {
+		return nil, err
+	}
+	return signTx(signer, bc.chainConf.ChainConfig().Crypto.Hash, &common.Payload{
+		ChainId:      job.chainId,
+		TxType:       common.TxType_INVOKE_CONTRACT,
+		TxId:         job.txId,
+		Timestamp:    time.Now().Unix(),
+		ContractName: crosschain.ContractName,
+		Method:       job.method,
+		Parameters:   job.params,
+	})
+}
+
+// signTx builds the tx of the payload signed by the signer
+func signTx(signer protocol.SigningMember, hashType string, payload *common.Payload) (*common.Transaction, error) {
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signer.Sign(hashType, payloadBytes)
+	if err != nil {
+		return nil, err
+	}
+	member, err := signer.GetMember()
+	if err != nil {
+		return nil, err
+	}
+	return &common.Transaction{
+		Payload: payload,
+		Sender:  &common.EndorsementEntry{Signer: member, Signature: signature},
+	}, nil
+}
+
+// signer returns the member signing the txs of the chain
+func (r *crossChainRelay) signer(bc *Blockchain) (protocol.SigningMember, error) {
+	if r.config.SignCertFile == "" {
+		return bc.identity, nil
+	}
+	if signer, ok := r.signers[bc.chainId]; ok {
+		return signer, nil
+	}
+	signer, err := accesscontrol.InitCertSigningMember(bc.chainConf.ChainConfig(),
+		localconf.ChainMakerConfig.NodeConfig.OrgId, r.config.SignKeyFile, "", r.config.SignCertFile)
+	if err != nil {
+		return nil, err
+	}
+	r.signers[bc.chainId] = signer
+	return signer, nil
+}
+
+// deliveredMessage returns the message of the DELIVER tx, false if the tx is not a delivery
+func deliveredMessage(tx *common.Transaction) (*crosschain.Message, bool) {
+	if tx.Payload == nil || tx.Payload.ContractName != crosschain.ContractName ||
+		tx.Payload.Method != crosschain.MethodDeliver {
+		return nil, false
+	}
+	for _, param := range tx.Payload.Parameters {
+		if param.Key != crosschain.ParamMessage {
+			continue
+		}
+		msg, err := crosschain.ParseMessage(param.Value)
+		if err != nil || tx.Payload.TxId != crosschain.DeliverTxId(msg.Id) {
+			return nil, false
+		}
+		return msg, true
+	}
+	return nil, false
+}
@@ -0,0 +1,201 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"chainmaker.org/chainmaker-go/core/crosschain"
+	"chainmaker.org/chainmaker/logger/v2"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	configpb "chainmaker.org/chainmaker/pb-go/v2/config"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestLoadCrossChainConfig(t *testing.T) {
+	// not relayed if not configured
+	useNodeConfig(t, "storage:\n  cross_chain:\n    relay: true\n")
+	config, err := LoadCrossChainConfig()
+	if err != nil || *config != (CrossChainConfig{ScanIntervalSeconds: 1, RescanBlocks: 100,
+		RetryIntervalSeconds: 30}) {
+		t.Fatalf("unexpected cross chain config %+v, %v", config, err)
+	}
+
+	useNodeConfig(t, "cross_chain:\n  relay: true\n  rescan_blocks: 10\n")
+	config, err = LoadCrossChainConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := CrossChainConfig{Relay: true, ScanIntervalSeconds: 1, RescanBlocks: 10, RetryIntervalSeconds: 30}
+	if *config != expected {
+		t.Errorf("cross chain config %+v, expected %+v", *config, expected)
+	}
+
+	useNodeConfig(t, "cross_chain:\n  relay: true\n  scan_interval_seconds: 0\n")
+	if _, err = LoadCrossChainConfig(); err == nil {
+		t.Error("zero scan interval should be rejected")
+	}
+	useNodeConfig(t, "cross_chain:\n  relay: true\n  sign_cert_file: relayer.crt\n")
+	if _, err = LoadCrossChainConfig(); err == nil {
+		t.Error("sign cert without the key should be rejected")
+	}
+}
+
+// newRelayTestChain returns a chain of the node hashing by SHA256, whose store holds the txs of txIds
+func newRelayTestChain(ctrl *gomock.Controller, chainId string, txIds ...string) *Blockchain {
+	chainConf := mock.NewMockChainConf(ctrl)
+	chainConf.EXPECT().ChainConfig().AnyTimes().Return(&configpb.ChainConfig{ChainId: chainId,
+		Crypto: &configpb.CryptoConfig{Hash: "SHA256"}, Consensus: &configpb.ConsensusConfig{}})
+	store := mock.NewMockBlockchainStore(ctrl)
+	store.EXPECT().TxExists(gomock.Any()).AnyTimes().DoAndReturn(func(txId string) (bool, error) {
+		for _, id := range txIds {
+			if id == txId {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	return &Blockchain{chainId: chainId, chainConf: chainConf, store: store,
+		log: logger.GetLoggerByChain(logger.MODULE_BLOCKCHAIN, chainId)}
+}
+
+func newRelayTestBlock(chainId string, txs ...*common.Transaction) *common.Block {
+	return &common.Block{
+		Header: &common.BlockHeader{ChainId: chainId, BlockHeight: 1, TxCount: uint32(len(txs))},
+		Txs:    txs,
+		AdditionalData: &common.AdditionalData{
+			ExtraData: map[string][]byte{protocol.TBFTAddtionalDataKey: []byte("quorum cert")}},
+	}
+}
+
+func newRelayTestMessageTx(chainId, txId string, dstChainIds ...string) *common.Transaction {
+	var events []*common.ContractEvent
+	for _, dstChainId := range dstChainIds {
+		events = append(events, &common.ContractEvent{Topic: crosschain.EventTopicMessage, ContractName: "src",
+			EventData: []string{dstChainId, "dst", "receive", "hello"}})
+	}
+	return &common.Transaction{
+		Payload: &common.Payload{ChainId: chainId, TxId: txId, ContractName: "src"},
+		Result: &common.Result{Code: common.TxStatusCode_SUCCESS,
+			ContractResult: &common.ContractResult{ContractEvent: events}},
+	}
+}
+
+func TestCrossChainRelayScanBlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	server := &ChainMakerServer{}
+	chain1 := newRelayTestChain(ctrl, "chain1")
+	chain2 := newRelayTestChain(ctrl, "chain2")
+	server.blockchains.Store("chain1", chain1)
+	server.blockchains.Store("chain2", chain2)
+	relay := newCrossChainRelay(server, &CrossChainConfig{Relay: true, RetryIntervalSeconds: 30})
+
+	// the messages to the chains of the node are delivered, the others are not relayed
+	srcTx := newRelayTestMessageTx("chain1", "tx1", "chain3", "chain2")
+	relay.scanBlock(chain1, newRelayTestBlock("chain1", newRelayTestMessageTx("chain1", "tx0"), srcTx))
+	msg := crosschain.MessagesOf("chain1", srcTx)[1]
+	if len(relay.jobs) != 1 {
+		t.Fatalf("%d jobs, expected the delivery of message %s", len(relay.jobs), msg.Id)
+	}
+	deliverJob := relay.jobs[crosschain.DeliverTxId(msg.Id)]
+	if deliverJob == nil || deliverJob.chainId != "chain2" || deliverJob.method != crosschain.MethodDeliver ||
+		deliverJob.trustedChainId != "chain1" {
+		t.Fatalf("unexpected delivery job %+v", deliverJob)
+	}
+	params := make(map[string][]byte)
+	for _, param := range deliverJob.params {
+		params[param.Key] = param.Value
+	}
+	if delivered, err := crosschain.ParseMessage(params[crosschain.ParamMessage]); err != nil ||
+		*delivered != *msg {
+		t.Errorf("delivered message %+v, expected %+v, %v", delivered, msg, err)
+	}
+	proof, err := crosschain.ParseProof(params[crosschain.ParamProof])
+	if err != nil || proof.TxIndex != 1 || string(proof.QuorumCert) != "quorum cert" {
+		t.Errorf("unexpected proof %+v, %v", proof, err)
+	}
+
+	// the delivery is acknowledged to the source chain
+	deliverTx := &common.Transaction{
+		Payload: &common.Payload{ChainId: "chain2", TxId: deliverJob.txId, ContractName: crosschain.ContractName,
+			Method: crosschain.MethodDeliver, Parameters: deliverJob.params},
+		Result: &common.Result{Code: common.TxStatusCode_CONTRACT_FAIL, ContractResult: &common.ContractResult{}},
+	}
+	relay.scanBlock(chain2, newRelayTestBlock("chain2", deliverTx))
+	ackJob := relay.jobs[crosschain.AckTxId(msg.Id)]
+	if ackJob == nil || ackJob.chainId != "chain1" || ackJob.method != crosschain.MethodAck ||
+		ackJob.trustedChainId != "chain2" {
+		t.Fatalf("unexpected ack job %+v", ackJob)
+	}
+
+	// the blocks without the quorum cert could not be proved
+	relay.jobs = make(map[string]*crossChainJob)
+	block := newRelayTestBlock("chain1", newRelayTestMessageTx("chain1", "tx2", "chain2"))
+	block.AdditionalData = nil
+	relay.scanBlock(chain1, block)
+	if len(relay.jobs) != 0 {
+		t.Errorf("%d jobs of the block without the quorum cert", len(relay.jobs))
+	}
+}
+
+func TestCrossChainRelaySubmit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	server := &ChainMakerServer{}
+	server.blockchains.Store("chain1", newRelayTestChain(ctrl, "chain1", "committed"))
+	relay := newCrossChainRelay(server, &CrossChainConfig{Relay: true, RetryIntervalSeconds: 30})
+
+	for _, c := range []struct {
+		job  *crossChainJob
+		done bool
+	}{
+		{job: &crossChainJob{chainId: "chain3", txId: "tx1"}, done: true},
+		{job: &crossChainJob{chainId: "chain1", txId: "committed"}, done: true},
+		// waits for the chain config trusting the chain proved
+		{job: &crossChainJob{chainId: "chain1", txId: "tx1", trustedChainId: "chain2"}, done: false},
+	} {
+		if done := relay.submit(c.job); done != c.done {
+			t.Errorf("job %+v done: %v, expected %v", c.job, done, c.done)
+		}
+		if !c.job.submitTime.IsZero() {
+			t.Errorf("job %+v should not be submitted", c.job)
+		}
+	}
+
+	server.pausedChains.Store("chain1", struct{}{})
+	if relay.submit(&crossChainJob{chainId: "chain1", txId: "committed"}) {
+		t.Error("the jobs of the paused chains are kept")
+	}
+}
+
+func TestDeliveredMessage(t *testing.T) {
+	msg := &crosschain.Message{SrcChainId: "chain1", SrcTxId: "tx1", DstChainId: "chain2"}
+	msg.Id = crosschain.MessageId(msg.SrcChainId, msg.SrcTxId, msg.EventIndex)
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range []struct {
+		txId, method string
+		ok           bool
+	}{
+		{txId: crosschain.DeliverTxId(msg.Id), method: crosschain.MethodDeliver, ok: true},
+		{txId: crosschain.AckTxId(msg.Id), method: crosschain.MethodAck},
+		{txId: fmt.Sprintf("%s-forged", crosschain.DeliverTxId(msg.Id)), method: crosschain.MethodDeliver},
+	} {
+		tx := &common.Transaction{Payload: &common.Payload{TxId: c.txId, ContractName: crosschain.ContractName,
+			Method: c.method, Parameters: []*common.KeyValuePair{{Key: crosschain.ParamMessage, Value: msgBytes}}}}
+		if delivered, ok := deliveredMessage(tx); ok != c.ok || (ok && delivered.Id != msg.Id) {
+			t.Errorf("case %d: delivered message %+v, ok: %v", i, delivered, ok)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"time"
+
+	"chainmaker.org/chainmaker-go/accesscontrol"
+	"chainmaker.org/chainmaker-go/consensus/dpos"
+	"chainmaker.org/chainmaker/common/v2/helper"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	consensuspb "chainmaker.org/chainmaker/pb-go/v2/consensus"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/utils/v2"
+)
+
+const (
+	// the interval of checking the DPoS chains for the VRF proofs to submit
+	dposVRFProveInterval = 5 * time.Second
+	// the interval of resubmitting a VRF proof not recorded
+	dposVRFRetryInterval = 30 * time.Second
+)
+
+// dposVRFProver proves the VRF of the DPoS candidates run by the node for the next epochs of the chains. The proofs
+// are made over the seed of the next epoch with the net tls key of the node, whose id is pinned as the node of the
+// candidates, and are submitted to the DPOS_VRF contract signed by the identity of the node. The validators are
+// elected by the VRF outputs recorded, the candidates not proving only take the seats left.
+type dposVRFProver struct {
+	server *ChainMakerServer
+	key    *ecdsa.PrivateKey
+	pkPEM  []byte
+	nodeId string
+	// the last submission of the proofs by the chain, the epoch and the candidate
+	submitted map[string]time.Time
+}
+
+// newDPoSVRFProver creates the prover by the net tls key of the node, which should be a P-256 key
+func newDPoSVRFProver(server *ChainMakerServer) (*dposVRFProver, error) {
+	privateKey, err := accesscontrol.LoadNetTLSPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := privateKey.ToStandardKey().(*ecdsa.PrivateKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("the VRF of DPoS is proved by a P-256 net tls key")
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	nodeId, err := helper.CreateLibp2pPeerIdWithPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &dposVRFProver{
+		server:    server,
+		key:       key,
+		pkPEM:     pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		nodeId:    nodeId,
+		submitted: make(map[string]time.Time),
+	}, nil
+}
+
+// run proves the VRF until the stopC is closed
+func (p *dposVRFProver) run(stopC <-chan struct{}) {
+	ticker := time.NewTicker(dposVRFProveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.pass()
+		case <-stopC:
+			return
+		}
+	}
+}
+
+// pass submits the proofs pending of the DPoS chains, the chains paused are skipped
+func (p *dposVRFProver) pass() {
+	for job, submitTime := range p.submitted {
+		if time.Since(submitTime) >= dposVRFRetryInterval {
+			delete(p.submitted, job)
+		}
+	}
+	p.server.lifecycleLock.Lock()
+	defer p.server.lifecycleLock.Unlock()
+	p.server.blockchains.Range(func(key, value interface{}) bool {
+		chainId, _ := key.(string)
+		if !p.server.isPaused(chainId) {
+			p.prove(value.(*Blockchain))
+		}
+		return true
+	})
+}
+
+// prove submits the proofs of the candidates of the node not recorded for the next epoch of the chain
+func (p *dposVRFProver) prove(bc *Blockchain) {
+	if bc.chainConf.ChainConfig().Consensus.Type != consensuspb.ConsensusType_DPOS {
+		return
+	}
+	epochId, seed, addrs, err := dpos.NewDPoSImpl(bc.chainConf, bc.store).PendingVRFProofs(p.nodeId)
+	if err != nil {
+		bc.log.Warnf("dpos vrf prover load the pending proofs failed, %s", err)
+		return
+	}
+	if len(addrs) == 0 {
+		return
+	}
+	_, proof, err := dpos.VRFProve(p.key, seed)
+	if err != nil {
+		bc.log.Errorf("dpos vrf prover prove epoch %d failed, %s", epochId, err)
+		return
+	}
+	for _, addr := range addrs {
+		job := fmt.Sprintf("%s/%d/%s", bc.chainId, epochId, addr)
+		if _, ok := p.submitted[job]; ok {
+			continue
+		}
+		p.submitted[job] = time.Now()
+		tx, err := signTx(bc.identity, bc.chainConf.ChainConfig().Crypto.Hash, &common.Payload{
+			ChainId:      bc.chainId,
+			TxType:       common.TxType_INVOKE_CONTRACT,
+			TxId:         utils.GetRandTxId(),
+			Timestamp:    time.Now().Unix(),
+			ContractName: dpos.VRFContractName,
+			Method:       dpos.MethodProveEpoch,
+			Parameters: []*common.KeyValuePair{
+				{Key: dpos.ParamEpochId, Value: []byte(strconv.FormatUint(epochId, 10))},
+				{Key: dpos.ParamAddress, Value: []byte(addr)},
+				{Key: dpos.ParamPublicKey, Value: p.pkPEM},
+				{Key: dpos.ParamProof, Value: proof},
+			},
+		})
+		if err != nil {
+			bc.log.Errorf("dpos vrf prover new tx of candidate %s failed, %s", addr, err)
+			continue
+		}
+		if err = p.server.AddTx(bc.chainId, tx, protocol.RPC); err != nil {
+			bc.log.Warnf("dpos vrf prover add tx %s failed, %s", tx.Payload.TxId, err)
+			continue
+		}
+		bc.log.Infof("dpos vrf prover submitted the proof of candidate %s for epoch %d", addr, epochId)
+	}
+}
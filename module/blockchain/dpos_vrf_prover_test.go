@@ -0,0 +1,132 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker-go/consensus/dpos"
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	"chainmaker.org/chainmaker/common/v2/helper"
+	"chainmaker.org/chainmaker/logger/v2"
+	acpb "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	configpb "chainmaker.org/chainmaker/pb-go/v2/config"
+	consensuspb "chainmaker.org/chainmaker/pb-go/v2/consensus"
+	storePb "chainmaker.org/chainmaker/pb-go/v2/store"
+	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"chainmaker.org/chainmaker/vm-native/v2/dposmgr"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/mock/gomock"
+)
+
+func TestDPoSVRFProver(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&sk.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	pk, err := asym.PublicKeyFromPEM(pkPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeId, err := helper.CreateLibp2pPeerIdWithPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the node runs the candidate addr1 of epoch 3, addr2 is run by another node and addr3 has proved
+	epochBz, err := proto.Marshal(&syscontract.Epoch{EpochId: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := map[string][]byte{
+		dposmgr.KeyCurrentEpoch:               epochBz,
+		dpos.KeyEpochRandomness:               []byte("randomness"),
+		string(dpos.VRFOutputKey(3, "addr3")): []byte("output"),
+	}
+	pinned := []*storePb.KV{
+		{Key: dpos.VRFNodeIdKey(3, "addr1"), Value: []byte(nodeId)},
+		{Key: dpos.VRFNodeIdKey(3, "addr2"), Value: []byte("other")},
+		{Key: dpos.VRFNodeIdKey(3, "addr3"), Value: []byte(nodeId)},
+	}
+	store := mock.NewMockBlockchainStore(ctrl)
+	store.EXPECT().ReadObject(syscontract.SystemContract_DPOS_STAKE.String(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ string, key []byte) ([]byte, error) {
+			return state[string(key)], nil
+		})
+	store.EXPECT().SelectObject(syscontract.SystemContract_DPOS_STAKE.String(), gomock.Any(),
+		gomock.Any()).AnyTimes().DoAndReturn(func(_ string, _, _ []byte) (protocol.StateIterator, error) {
+		i := -1
+		iter := mock.NewMockStateIterator(ctrl)
+		iter.EXPECT().Next().AnyTimes().DoAndReturn(func() bool {
+			i++
+			return i < len(pinned)
+		})
+		iter.EXPECT().Value().AnyTimes().DoAndReturn(func() (*storePb.KV, error) {
+			return pinned[i], nil
+		})
+		iter.EXPECT().Release().AnyTimes()
+		return iter, nil
+	})
+	chainConf := mock.NewMockChainConf(ctrl)
+	chainConf.EXPECT().ChainConfig().AnyTimes().Return(&configpb.ChainConfig{ChainId: "chain1",
+		Crypto:    &configpb.CryptoConfig{Hash: "SHA256"},
+		Consensus: &configpb.ConsensusConfig{Type: consensuspb.ConsensusType_DPOS}})
+	identity := mock.NewMockSigningMember(ctrl)
+	identity.EXPECT().Sign(gomock.Any(), gomock.Any()).AnyTimes().Return([]byte("signature"), nil)
+	identity.EXPECT().GetMember().AnyTimes().Return(&acpb.Member{OrgId: "org1"}, nil)
+	var txs []*common.Transaction
+	txPool := mock.NewMockTxPool(ctrl)
+	txPool.EXPECT().AddTx(gomock.Any(), protocol.RPC).AnyTimes().DoAndReturn(
+		func(tx *common.Transaction, _ protocol.TxSource) error {
+			txs = append(txs, tx)
+			return nil
+		})
+	server := &ChainMakerServer{}
+	server.blockchains.Store("chain1", &Blockchain{chainId: "chain1", chainConf: chainConf, store: store,
+		txPool: txPool, identity: identity, log: logger.GetLoggerByChain(logger.MODULE_BLOCKCHAIN, "chain1")})
+	prover := &dposVRFProver{server: server, key: sk, pkPEM: pkPEM, nodeId: nodeId,
+		submitted: make(map[string]time.Time)}
+
+	prover.pass()
+	if len(txs) != 1 {
+		t.Fatalf("%d txs submitted, expected the proof of addr1", len(txs))
+	}
+	params := make(map[string][]byte)
+	for _, param := range txs[0].Payload.Parameters {
+		params[param.Key] = param.Value
+	}
+	if txs[0].Payload.ContractName != dpos.VRFContractName || txs[0].Payload.Method != dpos.MethodProveEpoch ||
+		string(params[dpos.ParamEpochId]) != "3" || string(params[dpos.ParamAddress]) != "addr1" {
+		t.Fatalf("unexpected proof tx %+v", txs[0].Payload)
+	}
+	if _, err = dpos.VRFVerify(&sk.PublicKey, dpos.EpochSeed(3, []byte("randomness")),
+		params[dpos.ParamProof]); err != nil {
+		t.Errorf("invalid proof, %s", err)
+	}
+
+	// the proof is not resubmitted until the retry interval passes
+	prover.pass()
+	if len(txs) != 1 {
+		t.Errorf("the proof is resubmitted in %d txs", len(txs))
+	}
+}
@@ -20,11 +20,16 @@ require (
 	chainmaker.org/chainmaker/protocol/v2 v2.1.1
 	chainmaker.org/chainmaker/store/v2 v2.1.1
 	chainmaker.org/chainmaker/utils/v2 v2.1.0
+	chainmaker.org/chainmaker/vm-native/v2 v2.1.1
 	chainmaker.org/chainmaker/vm/v2 v2.1.1
 	github.com/fatih/color v1.13.0 // indirect
+	github.com/gogo/protobuf v1.3.2
+	github.com/golang/mock v1.6.0
 	github.com/hokaccha/go-prettyjson v0.0.0-20210113012101-fb4e108d2519 // indirect
 	github.com/mattn/go-colorable v0.1.11 // indirect
 	github.com/mitchellh/mapstructure v1.4.2
+	github.com/prometheus/client_golang v1.11.0
+	github.com/spf13/viper v1.9.0
 )
 
 replace (
@@ -0,0 +1,108 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shutdownConfigKey is the top-level section of the shutdown mode of the node
+const shutdownConfigKey = "shutdown"
+
+// ShutdownConfig is the shutdown mode of the node.
+type ShutdownConfig struct {
+	// Graceful drains the node before stopping it: the readiness is reported as false first, then the rpc
+	// requests and streams are drained and the consensus duties are handed off, the node stops at once otherwise.
+	Graceful bool `mapstructure:"graceful"`
+	// ReadinessDelaySeconds is the time to wait after reporting not ready, for the load balancers to notice.
+	ReadinessDelaySeconds int `mapstructure:"readiness_delay_seconds"`
+	// DrainTimeoutSeconds bounds the time to drain the rpc requests and to hand off the consensus duties each.
+	DrainTimeoutSeconds int `mapstructure:"drain_timeout_seconds"`
+}
+
+// LoadShutdownConfig loads the shutdown mode in the top-level shutdown section,
+// the node stops at once if not configured.
+func LoadShutdownConfig() (*ShutdownConfig, error) {
+	config := &ShutdownConfig{ReadinessDelaySeconds: 5, DrainTimeoutSeconds: 30}
+	ok, err := loadNodeConfigSection(shutdownConfigKey, config)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return config, nil
+	}
+	if config.ReadinessDelaySeconds < 0 || config.DrainTimeoutSeconds <= 0 {
+		return nil, fmt.Errorf("invalid %s config: negative readiness_delay_seconds or drain_timeout_seconds",
+			shutdownConfigKey)
+	}
+	return config, nil
+}
+
+// consensusDrainer is implemented by the consensus engines which could hand off the duties of the node,
+// such as the leadership or the proposer turn, before it stops.
+type consensusDrainer interface {
+	Drain(ctx context.Context) error
+}
+
+// IsReady returns true if the chains are started and the node is not draining.
+func (server *ChainMakerServer) IsReady() bool {
+	if atomic.LoadInt32(&server.draining) == 1 {
+		return false
+	}
+	select {
+	case <-server.readyC:
+		return true
+	default:
+		return false
+	}
+}
+
+// StartDraining reports the node as not ready, which is the first step of the graceful shutdown.
+func (server *ChainMakerServer) StartDraining() {
+	if atomic.CompareAndSwapInt32(&server.draining, 0, 1) {
+		log.Info("ChainMaker server is draining, report not ready")
+	}
+}
+
+// Drain hands off the consensus duties of the node on all the chains, until the ctx is done.
+// The chains are still running, stop them by Stop after draining.
+func (server *ChainMakerServer) Drain(ctx context.Context) {
+	server.StartDraining()
+	var wg sync.WaitGroup
+	server.blockchains.Range(func(_, value interface{}) bool {
+		chain, _ := value.(*Blockchain)
+		wg.Add(1)
+		go func(chain *Blockchain) {
+			defer wg.Done()
+			chain.Drain(ctx)
+		}(chain)
+		return true
+	})
+	wg.Wait()
+	log.Info("ChainMaker server is drained!")
+}
+
+// Drain hands off the duties of the node in the consensus of the chain, until the ctx is done.
+func (bc *Blockchain) Drain(ctx context.Context) {
+	if !bc.isModuleStartUp(moduleNameConsensus) {
+		return
+	}
+	drainer, ok := bc.consensus.(consensusDrainer)
+	if !ok {
+		return
+	}
+	start := time.Now()
+	if err := drainer.Drain(ctx); err != nil {
+		bc.log.Warnf("drain consensus failed, stop it anyway, %s", err)
+		return
+	}
+	bc.log.Infof("drain consensus success in %v", time.Since(start))
+}
@@ -0,0 +1,49 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"testing"
+)
+
+func TestLoadShutdownConfig(t *testing.T) {
+	// stop at once if not configured
+	useNodeConfig(t, "log:\n  config_file: ../config/log.yml\n")
+	config, err := LoadShutdownConfig()
+	if err != nil || *config != (ShutdownConfig{ReadinessDelaySeconds: 5, DrainTimeoutSeconds: 30}) {
+		t.Fatalf("unexpected shutdown config %+v, %v", config, err)
+	}
+
+	useNodeConfig(t, "shutdown:\n  graceful: true\n  readiness_delay_seconds: 0\n")
+	config, err = LoadShutdownConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (ShutdownConfig{Graceful: true, DrainTimeoutSeconds: 30}); *config != expected {
+		t.Errorf("shutdown config %+v, expected %+v", *config, expected)
+	}
+
+	useNodeConfig(t, "shutdown:\n  graceful: true\n  drain_timeout_seconds: 0\n")
+	if _, err = LoadShutdownConfig(); err == nil {
+		t.Error("zero drain timeout should be rejected")
+	}
+}
+
+func TestChainMakerServerIsReady(t *testing.T) {
+	server := &ChainMakerServer{readyC: make(chan struct{})}
+	if server.IsReady() {
+		t.Error("not ready before the chains are started")
+	}
+	close(server.readyC)
+	if !server.IsReady() {
+		t.Error("ready after the chains are started")
+	}
+	server.StartDraining()
+	if server.IsReady() {
+		t.Error("not ready when draining")
+	}
+}
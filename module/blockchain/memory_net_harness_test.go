@@ -0,0 +1,220 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker-go/consensus/harness"
+	"chainmaker.org/chainmaker-go/net"
+	blockSync "chainmaker.org/chainmaker-go/sync"
+	"chainmaker.org/chainmaker/common/v2/msgbus"
+	"chainmaker.org/chainmaker/localconf/v2"
+	commonpb "chainmaker.org/chainmaker/pb-go/v2/common"
+	netpb "chainmaker.org/chainmaker/pb-go/v2/net"
+	storepb "chainmaker.org/chainmaker/pb-go/v2/store"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"github.com/golang/mock/gomock"
+)
+
+const e2eChainId = "chain1"
+
+// e2eNode is a node of the memory net harness test: the modules of a ChainMakerServer read the global
+// localconf, so the nodes in one process are assembled from the modules talking over the net instead,
+// a raft engine or none, the net service on a memory net and the sync service.
+type e2eNode struct {
+	*harness.Node
+	memNet     *net.MemoryNet
+	netService protocol.NetService
+	syncServer protocol.SyncService
+	txs        int32
+}
+
+// OnMessage counts the txs gossiped to the node
+func (n *e2eNode) OnMessage(message *msgbus.Message) {
+	if msg, ok := message.Payload.(*netpb.NetMsg); ok && msg.Type == netpb.NetMsg_TX {
+		atomic.AddInt32(&n.txs, 1)
+	}
+}
+
+// OnQuit does nothing
+func (n *e2eNode) OnQuit() {}
+
+func startE2ENode(t *testing.T, ctrl *gomock.Controller, network string, node *harness.Node,
+	consensusIds []string) *e2eNode {
+	var netFactory net.NetFactory
+	localNet, err := netFactory.NewNet(net.MemoryNetType, net.WithListenAddr("/memory/"+network))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e2e := &e2eNode{Node: node, memNet: localNet.(*net.MemoryNet)}
+	e2e.memNet.SetNodeUid(node.Id)
+	if err = e2e.memNet.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var netServiceFactory net.NetServiceFactory
+	if e2e.netService, err = netServiceFactory.NewNetService(localNet, e2eChainId, nil, nil,
+		net.WithMsgBus(node.MsgBus), net.WithConsensusNodeUid(consensusIds...)); err != nil {
+		t.Fatal(err)
+	}
+	if err = e2e.netService.Start(); err != nil {
+		t.Fatal(err)
+	}
+	node.MsgBus.Register(msgbus.RecvTxPoolMsg, e2e)
+
+	ledgerCache, verifier, committer := node.LedgerServices(ctrl)
+	e2e.syncServer = blockSync.NewBlockChainSyncServer(e2eChainId, e2e.netService, node.MsgBus,
+		newE2EBlockStore(ctrl, node), ledgerCache, verifier, committer)
+	if err = e2e.syncServer.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return e2e
+}
+
+func (n *e2eNode) stop() {
+	n.syncServer.Stop()
+	_ = n.netService.Stop()
+	_ = n.memNet.Stop()
+}
+
+// newE2EBlockStore serves the blocks committed by the node to the sync service
+func newE2EBlockStore(ctrl *gomock.Controller, node *harness.Node) protocol.BlockchainStore {
+	getBlock := func(height uint64) (*commonpb.Block, error) {
+		blocks := node.CommittedBlocks()
+		if height >= uint64(len(blocks)) {
+			return nil, fmt.Errorf("block %d not found", height)
+		}
+		return blocks[height], nil
+	}
+	store := mock.NewMockBlockchainStore(ctrl)
+	store.EXPECT().GetArchivedPivot().AnyTimes().Return(uint64(0))
+	store.EXPECT().GetBlock(gomock.Any()).AnyTimes().DoAndReturn(getBlock)
+	store.EXPECT().GetBlockWithRWSets(gomock.Any()).AnyTimes().DoAndReturn(
+		func(height uint64) (*storepb.BlockWithRWSet, error) {
+			block, err := getBlock(height)
+			if err != nil {
+				return nil, err
+			}
+			return &storepb.BlockWithRWSet{Block: block}, nil
+		})
+	return store
+}
+
+// runE2E runs size raft nodes on a memory net with latency and a partitioned node, the txs are gossiped
+// to the consensus nodes and a sync node started late catches up by the sync service.
+func runE2E(t *testing.T, size int) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the sync service reads its tickers from the global localconf
+	preSyncConfig := localconf.ChainMakerConfig.SyncConfig
+	defer func() { localconf.ChainMakerConfig.SyncConfig = preSyncConfig }()
+	localconf.ChainMakerConfig.SyncConfig.NodeStatusTick = 0.2
+	localconf.ChainMakerConfig.SyncConfig.LivenessTick = 0.2
+
+	network := fmt.Sprintf("TestMemoryNetHarness%d", size)
+	defer net.RemoveMemoryNetwork(network)
+	// raft instances are registered globally by node id, the ids differ by the size of the cluster
+	ids := make([]string, size)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("e2e%d-node%d", size, i)
+	}
+	genesis := &commonpb.Block{Header: &commonpb.BlockHeader{ChainId: e2eChainId, BlockHash: []byte("genesis")}}
+	cluster, err := harness.NewCluster(e2eChainId, ids, genesis, 1,
+		harness.RaftFactory(ctrl, t.TempDir()), harness.WithExternalNet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodes []*e2eNode
+	for _, node := range cluster.Nodes {
+		nodes = append(nodes, startE2ENode(t, ctrl, network, node, ids))
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.stop()
+		}
+	}()
+	memNetwork := nodes[0].memNet.Network()
+	memNetwork.SetFaults(net.MemoryNetFaults{MaxDelay: 5 * time.Millisecond})
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Stop()
+
+	// consensus
+	if err = cluster.WaitForHeight(2, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// tx gossip reaches the other consensus nodes
+	nodes[0].MsgBus.Publish(msgbus.SendTxPoolMsg, &netpb.NetMsg{Payload: []byte("tx"), Type: netpb.NetMsg_TX})
+	deadline := time.Now().Add(10 * time.Second)
+	for _, node := range nodes[1:] {
+		for atomic.LoadInt32(&node.txs) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("tx not gossiped to %s", node.Id)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// the majority keeps committing while a node is partitioned, the node catches up after healing
+	isolated := ids[size-1]
+	memNetwork.Partition(ids[:size-1], []string{isolated})
+	height, _ := cluster.Node(ids[0]).CurrentHeight()
+	if err = cluster.WaitForHeight(height+2, time.Minute, ids[:size-1]...); err != nil {
+		t.Fatal(err)
+	}
+	memNetwork.Heal()
+	if err = cluster.WaitForHeight(height+2, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// a sync node joining late catches up by the sync service
+	syncNode := harness.NewObserver(e2eChainId, fmt.Sprintf("e2e%d-sync", size), genesis)
+	observer := startE2ENode(t, ctrl, network, syncNode, ids)
+	defer observer.stop()
+	height, _ = cluster.Node(ids[0]).CurrentHeight()
+	deadline = time.Now().Add(time.Minute)
+	for {
+		if current, _ := observer.CurrentHeight(); current >= height {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sync node did not reach height %d", height)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err = cluster.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+	committed := cluster.Node(ids[0]).CommittedBlocks()
+	for _, block := range observer.CommittedBlocks()[:height+1] {
+		if !bytes.Equal(block.Header.BlockHash, committed[block.Header.BlockHeight].Header.BlockHash) {
+			t.Fatalf("sync node committed a different block at height %d", block.Header.BlockHeight)
+		}
+	}
+}
+
+// TestMemoryNetHarness runs the consensus, the tx gossip and the sync of 4 and 7 nodes over the memory net.
+// It is a harness test rather than a test of ChainMakerServer: the nodes are assembled by e2eNode, because
+// a ChainMakerServer reads the node config from the global localconf and only one runs in a process.
+func TestMemoryNetHarness(t *testing.T) {
+	harness.SkipRaftIfShort(t)
+	for _, size := range []int{4, 7} {
+		size := size
+		t.Run(fmt.Sprintf("%d nodes", size), func(t *testing.T) {
+			runE2E(t, size)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+
+	"chainmaker.org/chainmaker-go/net"
+	"chainmaker.org/chainmaker/localconf/v2"
+)
+
+// NetPeers returns the peers connected to the chain, with the chains on the node each peer also serves.
+func (server *ChainMakerServer) NetPeers(chainId string) (*net.ChainPeers, error) {
+	netService, err := server.getNetService(chainId)
+	if err != nil {
+		return nil, err
+	}
+	peers, err := netService.Peers()
+	if err != nil {
+		return nil, err
+	}
+	chainsOfPeers := server.chainsOfPeers()
+	for _, peer := range peers.Peers {
+		peer.Chains = chainsOfPeers[peer.NodeId]
+	}
+	return peers, nil
+}
+
+// NetSeeds returns the seeds configured and the ones dialed by the chains, with whether they are connected
+// to any chain on the node.
+func (server *ChainMakerServer) NetSeeds() []*net.SeedInfo {
+	chainsOfPeers := server.chainsOfPeers()
+	added := make(map[string]bool)
+	var seeds []*net.SeedInfo
+	addSeed := func(address string, dialed bool) {
+		if added[address] {
+			return
+		}
+		added[address] = true
+		nodeId := net.SeedNodeId(address)
+		seeds = append(seeds, &net.SeedInfo{
+			Address:   address,
+			NodeId:    nodeId,
+			Connected: len(chainsOfPeers[nodeId]) > 0,
+			Dialed:    dialed,
+		})
+	}
+	for _, address := range localconf.ChainMakerConfig.NetConfig.Seeds {
+		addSeed(address, false)
+	}
+	server.blockchains.Range(func(_, value interface{}) bool {
+		if netService, ok := value.(*Blockchain).netService.(*net.NetService); ok {
+			for _, address := range netService.DialedSeeds() {
+				addSeed(address, true)
+			}
+		}
+		return true
+	})
+	return seeds
+}
+
+// chainsOfPeers returns the chains on the node each peer serves, sorted by the chain id.
+func (server *ChainMakerServer) chainsOfPeers() map[string][]string {
+	chainsOfPeers := make(map[string][]string)
+	server.blockchains.Range(func(key, value interface{}) bool {
+		chainId, _ := key.(string)
+		blockchain, _ := value.(*Blockchain)
+		if blockchain.netService == nil {
+			return true
+		}
+		infos, err := blockchain.netService.GetChainNodesInfoProvider().GetChainNodesInfo()
+		if err != nil {
+			log.Warnf("get the nodes of chain %s failed, %s", chainId, err)
+			return true
+		}
+		for _, info := range infos {
+			chainsOfPeers[info.NodeUid] = append(chainsOfPeers[info.NodeUid], chainId)
+		}
+		return true
+	})
+	for _, chains := range chainsOfPeers {
+		sort.Strings(chains)
+	}
+	return chainsOfPeers
+}
+
+func (server *ChainMakerServer) getNetService(chainId string) (*net.NetService, error) {
+	blockchain, err := server.GetBlockchain(chainId)
+	if err != nil {
+		return nil, err
+	}
+	netService, ok := blockchain.netService.(*net.NetService)
+	if !ok {
+		return nil, fmt.Errorf("net service of chain %s does not support the net admin", chainId)
+	}
+	return netService, nil
+}
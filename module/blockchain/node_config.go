@@ -0,0 +1,15 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import "chainmaker.org/chainmaker-go/accesscontrol"
+
+// loadNodeConfigSection decodes the top-level section of the config file into config, false means the
+// section is absent. The sections of the access control are loaded the same, see LoadNodeConfigSection.
+func loadNodeConfigSection(section string, config interface{}) (bool, error) {
+	return accesscontrol.LoadNodeConfigSection(section, config)
+}
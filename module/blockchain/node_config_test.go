@@ -0,0 +1,46 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockchain
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"chainmaker.org/chainmaker/localconf/v2"
+)
+
+// useNodeConfig makes the config file of the node hold content until the test ends
+func useNodeConfig(t *testing.T, content string) {
+	configFilepath := localconf.ConfigFilepath
+	t.Cleanup(func() { localconf.ConfigFilepath = configFilepath })
+
+	localconf.ConfigFilepath = filepath.Join(t.TempDir(), "chainmaker.yml")
+	if err := ioutil.WriteFile(localconf.ConfigFilepath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadNodeConfigSection(t *testing.T) {
+	var config struct {
+		Enabled bool `mapstructure:"enabled"`
+	}
+	useNodeConfig(t, "storage:\n  section:\n    enabled: true\n")
+	if ok, err := loadNodeConfigSection("section", &config); ok || err != nil {
+		t.Errorf("a section nested in storage is not top-level, ok: %v, err: %v", ok, err)
+	}
+
+	useNodeConfig(t, "section:\n  enabled: true\n")
+	if ok, err := loadNodeConfigSection("section", &config); !ok || err != nil || !config.Enabled {
+		t.Errorf("section %+v not loaded, ok: %v, err: %v", config, ok, err)
+	}
+
+	useNodeConfig(t, "section:\n  enabled: [true]\n")
+	if _, err := loadNodeConfigSection("section", &config); err == nil {
+		t.Error("invalid section should be rejected")
+	}
+}
@@ -35,7 +35,7 @@ func NewDPoSImpl(chainConf protocol.ChainConf, blockChainStore protocol.Blockcha
 }
 
 func (impl *DPoSImpl) CreateDPoSRWSet(preBlkHash []byte, proposedBlock *consensus.ProposalBlock) error {
-	consensusRwSets, err := impl.createDPoSRWSet(preBlkHash, proposedBlock)
+	consensusRwSets, err := impl.createDPoSRWSet(proposedBlock)
 	if err != nil {
 		return err
 	}
@@ -45,8 +45,7 @@ func (impl *DPoSImpl) CreateDPoSRWSet(preBlkHash []byte, proposedBlock *consensu
 	return err
 }
 
-func (impl *DPoSImpl) createDPoSRWSet(
-	preBlkHash []byte, proposedBlock *consensus.ProposalBlock) (*common.TxRWSet, error) {
+func (impl *DPoSImpl) createDPoSRWSet(proposedBlock *consensus.ProposalBlock) (*common.TxRWSet, error) {
 	impl.log.Debugf("begin createDPoS rwSet, blockInfo: %d:%x ",
 		proposedBlock.Block.Header.BlockHeight, proposedBlock.Block.Header.BlockHash)
 	// 1. judge consensus: DPoS
@@ -59,13 +58,15 @@ func (impl *DPoSImpl) createDPoSRWSet(
 		blockTxRwSet = proposedBlock.TxsRwSet
 	)
 	blockHeight := uint64(block.Header.BlockHeight)
+	rewards := newRewardLedger(impl.stateDB)
 	// 2. get epoch info from stateDB
 	epoch, err := impl.getEpochInfo()
 	if err != nil {
 		return nil, err
 	}
 	if epoch.NextEpochCreateHeight != blockHeight {
-		return nil, nil
+		// only the reward withdrawals of the block are executed out of the epoch switch
+		return impl.createWithdrawRwSet(rewards, block, blockTxRwSet, &common.TxRWSet{TxId: ModuleName})
 	}
 	// 3. create unbounding rwset
 	unboundingRwSet, err := impl.completeUnbounding(epoch, block, blockTxRwSet)
@@ -73,8 +74,13 @@ func (impl *DPoSImpl) createDPoSRWSet(
 		impl.log.Errorf("create complete unbonding error, reason: %s", err)
 		return nil, err
 	}
-	// 4. create newEpoch
-	newEpoch, err := impl.createNewEpoch(blockHeight, epoch, preBlkHash)
+	// 4. issue the reward of the finished epoch to its validators and delegators
+	if err = impl.distributeEpochReward(rewards, epoch); err != nil {
+		impl.log.Errorf("distribute epoch reward error, reason: %s", err)
+		return nil, err
+	}
+	// 5. create newEpoch, the validators are elected by the VRF outputs the candidates proved for it
+	newEpoch, electionWrites, err := impl.createNewEpoch(blockHeight, epoch, block, blockTxRwSet)
 	if err != nil {
 		impl.log.Errorf("create new epoch error, reason: %s", err)
 		return nil, err
@@ -89,26 +95,44 @@ func (impl *DPoSImpl) createDPoSRWSet(
 		impl.log.Errorf("create validators rwSet error, reason: %s", err)
 		return nil, err
 	}
-	// 5. Aggregate read-write set
+	// 6. Aggregate read-write set
 	unboundingRwSet.TxWrites = append(unboundingRwSet.TxWrites, epochRwSet.TxWrites...)
 	unboundingRwSet.TxWrites = append(unboundingRwSet.TxWrites, validatorsRwSet.TxWrites...)
-	impl.log.Debugf("end createDPoS rwSet: %v ", unboundingRwSet)
-	return unboundingRwSet, nil
+	unboundingRwSet.TxWrites = append(unboundingRwSet.TxWrites, electionWrites...)
+	return impl.createWithdrawRwSet(rewards, block, blockTxRwSet, unboundingRwSet)
+}
+
+// createWithdrawRwSet appends the reward writes and the balance writes of the
+// reward withdrawals in block to rwSet, it returns nil when rwSet is empty.
+func (impl *DPoSImpl) createWithdrawRwSet(rewards *rewardLedger, block *common.Block,
+	blockTxRwSet map[string]*common.TxRWSet, rwSet *common.TxRWSet) (*common.TxRWSet, error) {
+	withdrawWrites, err := impl.withdrawRewards(rewards, block, blockTxRwSet, rwSet.TxWrites)
+	if err != nil {
+		impl.log.Errorf("withdraw rewards error, reason: %s", err)
+		return nil, err
+	}
+	rwSet.TxWrites = append(rwSet.TxWrites, withdrawWrites...)
+	rwSet.TxWrites = append(rwSet.TxWrites, rewards.writes()...)
+	if len(rwSet.TxWrites) == 0 {
+		return nil, nil
+	}
+	impl.log.Debugf("end createDPoS rwSet: %v ", rwSet)
+	return rwSet, nil
 }
 
 func (impl *DPoSImpl) isDPoSConsensus() bool {
 	return impl.chainConf.ChainConfig().Consensus.Type == consensus.ConsensusType_DPOS
 }
 
-func (impl *DPoSImpl) createNewEpoch(
-	proposalHeight uint64, oldEpoch *syscontract.Epoch, seed []byte) (*syscontract.Epoch, error) {
+func (impl *DPoSImpl) createNewEpoch(proposalHeight uint64, oldEpoch *syscontract.Epoch, block *common.Block,
+	blockTxRwSet map[string]*common.TxRWSet) (*syscontract.Epoch, []*common.TxWrite, error) {
 	impl.log.Debugf("begin create new epoch in blockHeight: %d", proposalHeight)
 	// 1. get property: epochBlockNum
 	epochBlockNumBz, err := impl.stateDB.ReadObject(
 		syscontract.SystemContract_DPOS_STAKE.String(), []byte(dposmgr.KeyEpochBlockNumber))
 	if err != nil {
 		impl.log.Errorf("load epochBlockNum from db failed, reason: %s", err)
-		return nil, err
+		return nil, nil, err
 	}
 	epochBlockNum := binary.BigEndian.Uint64(epochBlockNumBz)
 	impl.log.Debugf("epoch blockNum: %d", epochBlockNum)
@@ -116,17 +140,17 @@ func (impl *DPoSImpl) createNewEpoch(
 	// 2. get all candidates
 	candidates, err := impl.getAllCandidateInfo()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if len(candidates) == 0 {
 		impl.log.Errorf("not found candidates from contract")
-		return nil, fmt.Errorf("not found candidates from contract")
+		return nil, nil, fmt.Errorf("not found candidates from contract")
 	}
 
 	// 3. select validators from candidates
-	validators, err := impl.selectValidators(candidates, seed)
+	validators, electionWrites, err := impl.epochElection(oldEpoch.EpochId+1, candidates, block, blockTxRwSet)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	proposer := make([]string, 0, len(validators))
 	for _, val := range validators {
@@ -140,10 +164,11 @@ func (impl *DPoSImpl) createNewEpoch(
 		ProposerVector:        proposer,
 	}
 	impl.log.Debugf("new epoch: %s", newEpoch.String())
-	return newEpoch, nil
+	return newEpoch, electionWrites, nil
 }
 
-func (impl *DPoSImpl) selectValidators(candidates []*dpos.CandidateInfo, seed []byte) ([]*dpos.CandidateInfo, error) {
+func (impl *DPoSImpl) selectValidators(candidates []*dpos.CandidateInfo, seed []byte,
+	outputs map[string][]byte) ([]*dpos.CandidateInfo, error) {
 	valNumBz, err := impl.stateDB.ReadObject(
 		syscontract.SystemContract_DPOS_STAKE.String(), []byte(dposmgr.KeyEpochValidatorNumber))
 	if err != nil {
@@ -151,12 +176,12 @@ func (impl *DPoSImpl) selectValidators(candidates []*dpos.CandidateInfo, seed []
 		return nil, err
 	}
 	valNum := binary.BigEndian.Uint64(valNumBz)
-	vals, err := ValidatorsElection(candidates, int(valNum), seed, true)
+	vals, err := VRFValidatorsElection(candidates, int(valNum), seed, outputs, true)
 	if err != nil {
 		impl.log.Errorf("select validators from candidates failed, reason: %s", err)
 		return nil, err
 	}
-	impl.log.Debugf("select validators: %v from candidates: %v by seed: %x", vals, candidates, seed)
+	impl.log.Debugf("select validators: %v from candidates: %v by randomness: %x", vals, candidates, seed)
 	return vals, nil
 }
 
@@ -187,8 +212,7 @@ func (impl *DPoSImpl) VerifyConsensusArgs(block *common.Block, blockTxRwSet map[
 		return nil
 	}
 
-	localConsensus, err := impl.createDPoSRWSet(
-		block.Header.PreBlockHash, &consensus.ProposalBlock{Block: block, TxsRwSet: blockTxRwSet})
+	localConsensus, err := impl.createDPoSRWSet(&consensus.ProposalBlock{Block: block, TxsRwSet: blockTxRwSet})
 	if err != nil {
 		impl.log.Errorf("get DPoS txRwSets failed, reason: %s", err)
 		return err
@@ -214,7 +238,7 @@ func (impl *DPoSImpl) VerifyConsensusArgs(block *common.Block, blockTxRwSet map[
 		return fmt.Errorf("unmarshal dpos consensusArgs from blockHeader failed,reason: %s ", err)
 	}
 	return fmt.Errorf("consensus args verify mismatch, blockConsensus: %v, "+
-		"localConsensus: %v by seed: %x", consensusArgs, localConsensus, block.Header.PreBlockHash)
+		"localConsensus: %v", consensusArgs, localConsensus)
 }
 
 func (impl *DPoSImpl) GetValidators() ([]string, error) {
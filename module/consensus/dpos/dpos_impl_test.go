@@ -30,12 +30,12 @@ func TestDPoSImpl_CreateDPoSRWSet(t *testing.T) {
 	defer fn()
 
 	proposedBlk := &consensuspb.ProposalBlock{Block: &commonpb.Block{Header: &commonpb.BlockHeader{BlockHeight: 99}}}
-	rwSet, err := impl.createDPoSRWSet(nil, proposedBlk)
+	rwSet, err := impl.createDPoSRWSet(proposedBlk)
 	require.NoError(t, err)
 	require.Nil(t, rwSet)
 
 	proposedBlk.Block.Header.BlockHeight = 100
-	rwSet, err = impl.createDPoSRWSet(nil, proposedBlk)
+	rwSet, err = impl.createDPoSRWSet(proposedBlk)
 	require.EqualError(t, err, "not found candidates from contract")
 	require.Nil(t, rwSet)
 }
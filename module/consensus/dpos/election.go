@@ -0,0 +1,341 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dpos
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	"chainmaker.org/chainmaker/common/v2/helper"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/pb-go/v2/consensus/dpos"
+	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/vm-native/v2/dposmgr"
+	"github.com/gogo/protobuf/proto"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// KeyEpochRandomness stores the randomness of the current epoch in the stake contract
+	KeyEpochRandomness = "EPOCH_RANDOMNESS"
+
+	// VRFContractName is the name of the contract recording the VRF proofs of the candidates, which is executed
+	// by the node instead of the vm
+	VRFContractName = "DPOS_VRF"
+	// MethodProveEpoch records the VRF output of a candidate over the seed of the next epoch
+	MethodProveEpoch = "PROVE_EPOCH"
+
+	// the parameters of MethodProveEpoch
+	ParamEpochId   = "epoch_id"
+	ParamAddress   = "address"
+	ParamPublicKey = "public_key"
+	ParamProof     = "proof"
+
+	keyVRFNodeIdPrefix = "VRF_NODE_ID/"
+	keyVRFOutputPrefix = "VRF_OUTPUT/"
+	epochSeedDomain    = "chainmaker-dpos-epoch-seed"
+
+	// vrfTicketPrec is the precision in bits of the election tickets, vrfLogTerms is the number of the terms
+	// of the series computing them, both are fixed so all the nodes get the same tickets
+	vrfTicketPrec = 256
+	vrfLogTerms   = 90
+)
+
+// EpochSeed computes the VRF input of the new epoch. It is chained on the randomness of the last epoch instead of
+// the block hash, so the proposer can not grind it by choosing the contents of the block. The first election has
+// no randomness yet, its seed only depends on the epoch id.
+func EpochSeed(newEpochId uint64, lastRandomness []byte) []byte {
+	id := make([]byte, 8)
+	binary.BigEndian.PutUint64(id, newEpochId)
+	hash := sha256.New()
+	hash.Write([]byte(epochSeedDomain))
+	hash.Write(id)
+	hash.Write(lastRandomness)
+	return hash.Sum(nil)
+}
+
+// VRFNodeIdKey stores the node id of the candidate whose net key proves the VRF of the epoch
+func VRFNodeIdKey(epochId uint64, addr string) []byte {
+	return []byte(fmt.Sprintf("%s%d/%s", keyVRFNodeIdPrefix, epochId, addr))
+}
+
+// VRFOutputKey stores the VRF output of the candidate for the epoch
+func VRFOutputKey(epochId uint64, addr string) []byte {
+	return []byte(fmt.Sprintf("%s%d/%s", keyVRFOutputPrefix, epochId, addr))
+}
+
+// ExecuteVRFProof records the VRF output of a candidate for the next epoch. The proof is made over the seed of the
+// next epoch with the net key of the candidate's node, which was pinned at the epoch switch revealing the seed,
+// so the candidate could choose neither the seed nor the key to bias its output.
+func ExecuteVRFProof(txSimContext protocol.TxSimContext, method string, params map[string][]byte) (
+	*common.ContractResult, protocol.ExecOrderTxType, common.TxStatusCode) {
+	if method != MethodProveEpoch {
+		return vrfFailed(fmt.Errorf("unknown method %s of %s", method, VRFContractName))
+	}
+	epochId, err := strconv.ParseUint(string(params[ParamEpochId]), 10, 64)
+	if err != nil {
+		return vrfFailed(fmt.Errorf("invalid epoch id, %s", err))
+	}
+	addr := string(params[ParamAddress])
+	output, err := verifyVRFProof(txSimContext, epochId, addr, params[ParamPublicKey], params[ParamProof])
+	if err != nil {
+		return vrfFailed(err)
+	}
+	if err = txSimContext.Put(syscontract.SystemContract_DPOS_STAKE.String(), VRFOutputKey(epochId, addr),
+		output); err != nil {
+		return vrfFailed(err)
+	}
+	return &common.ContractResult{Result: output}, protocol.ExecOrderTxTypeNormal, common.TxStatusCode_SUCCESS
+}
+
+// verifyVRFProof verifies the proof of the candidate addr for the epoch and returns its VRF output
+func verifyVRFProof(txSimContext protocol.TxSimContext, epochId uint64, addr string, pkPEM, proof []byte) (
+	[]byte, error) {
+	stake := syscontract.SystemContract_DPOS_STAKE.String()
+	epochBz, err := txSimContext.Get(stake, []byte(dposmgr.KeyCurrentEpoch))
+	if err != nil {
+		return nil, err
+	}
+	var epoch syscontract.Epoch
+	if err = proto.Unmarshal(epochBz, &epoch); err != nil {
+		return nil, fmt.Errorf("unmarshal current epoch failed, %s", err)
+	}
+	if epochId != epoch.EpochId+1 {
+		return nil, fmt.Errorf("only the next epoch %d could be proved, got %d", epoch.EpochId+1, epochId)
+	}
+	nodeId, err := txSimContext.Get(stake, VRFNodeIdKey(epochId, addr))
+	if err != nil {
+		return nil, err
+	}
+	if len(nodeId) == 0 {
+		return nil, fmt.Errorf("candidate %s has no node pinned for epoch %d", addr, epochId)
+	}
+	if output, err := txSimContext.Get(stake, VRFOutputKey(epochId, addr)); err != nil {
+		return nil, err
+	} else if len(output) > 0 {
+		return nil, fmt.Errorf("candidate %s has proved epoch %d already", addr, epochId)
+	}
+	pk, err := asym.PublicKeyFromPEM(pkPEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key failed, %s", err)
+	}
+	if id, err := helper.CreateLibp2pPeerIdWithPublicKey(pk); err != nil || id != string(nodeId) {
+		return nil, fmt.Errorf("public key is not the key of node %s of candidate %s", nodeId, addr)
+	}
+	ecKey, ok := pk.ToStandardKey().(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errInvalidVRFKey
+	}
+	randomness, err := txSimContext.Get(stake, []byte(KeyEpochRandomness))
+	if err != nil {
+		return nil, err
+	}
+	return VRFVerify(ecKey, EpochSeed(epochId, randomness), proof)
+}
+
+func vrfFailed(err error) (*common.ContractResult, protocol.ExecOrderTxType, common.TxStatusCode) {
+	return &common.ContractResult{Code: 1, Message: err.Error()}, protocol.ExecOrderTxTypeNormal,
+		common.TxStatusCode_CONTRACT_FAIL
+}
+
+// PendingVRFProofs returns the next epoch, its seed and the candidates whose VRF should be proved by the node of
+// nodeId for it, the candidates proved already are skipped.
+func (impl *DPoSImpl) PendingVRFProofs(nodeId string) (epochId uint64, seed []byte, addrs []string, err error) {
+	if !impl.isDPoSConsensus() {
+		return 0, nil, nil, nil
+	}
+	epoch, err := impl.getEpochInfo()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	epochId = epoch.EpochId + 1
+	stake := syscontract.SystemContract_DPOS_STAKE.String()
+	randomness, err := impl.stateDB.ReadObject(stake, []byte(KeyEpochRandomness))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	prefix := VRFNodeIdKey(epochId, "")
+	iterRange := util.BytesPrefix(prefix)
+	iter, err := impl.stateDB.SelectObject(stake, iterRange.Start, iterRange.Limit)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer iter.Release()
+	for iter.Next() {
+		kv, err := iter.Value()
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if string(kv.Value) != nodeId {
+			continue
+		}
+		addr := strings.TrimPrefix(string(kv.Key), string(prefix))
+		output, err := impl.stateDB.ReadObject(stake, VRFOutputKey(epochId, addr))
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if len(output) == 0 {
+			addrs = append(addrs, addr)
+		}
+	}
+	return epochId, EpochSeed(epochId, randomness), addrs, nil
+}
+
+// epochElection reads the seed and the VRF outputs of the candidates for the new epoch, elects its validators and
+// returns them with the writes of the epoch randomness and of the node ids pinned for the epoch after it.
+func (impl *DPoSImpl) epochElection(newEpochId uint64, candidates []*dpos.CandidateInfo, block *common.Block,
+	blockTxRwSet map[string]*common.TxRWSet) ([]*dpos.CandidateInfo, []*common.TxWrite, error) {
+	stake := syscontract.SystemContract_DPOS_STAKE.String()
+	lastRandomness, err := impl.stateDB.ReadObject(stake, []byte(KeyEpochRandomness))
+	if err != nil {
+		impl.log.Errorf("load epoch randomness from db failed, reason: %s", err)
+		return nil, nil, err
+	}
+	seed := EpochSeed(newEpochId, lastRandomness)
+	outputs := make(map[string][]byte, len(candidates))
+	nodeIdWrites := make([]*common.TxWrite, 0, len(candidates))
+	for _, candidate := range candidates {
+		output, err := impl.getState(stake, VRFOutputKey(newEpochId, candidate.PeerId), block, blockTxRwSet)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(output) > 0 {
+			outputs[candidate.PeerId] = output
+		}
+		// the key proving the epoch after the new one is pinned before its seed is known
+		nodeId, err := impl.getState(stake, dposmgr.ToNodeIDKey(candidate.PeerId), block, blockTxRwSet)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(nodeId) > 0 {
+			nodeIdWrites = append(nodeIdWrites, &common.TxWrite{ContractName: stake,
+				Key: VRFNodeIdKey(newEpochId+1, candidate.PeerId), Value: nodeId})
+		}
+	}
+	validators, err := impl.selectValidators(candidates, seed, outputs)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(nodeIdWrites, func(i, j int) bool {
+		return string(nodeIdWrites[i].Key) < string(nodeIdWrites[j].Key)
+	})
+	writes := append([]*common.TxWrite{{ContractName: stake, Key: []byte(KeyEpochRandomness),
+		Value: epochRandomness(seed, outputs)}}, nodeIdWrites...)
+	return validators, writes, nil
+}
+
+// epochRandomness derives the randomness of the new epoch from its seed and the VRF outputs of the candidates
+func epochRandomness(seed []byte, outputs map[string][]byte) []byte {
+	addrs := make([]string, 0, len(outputs))
+	for addr := range outputs {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	hash := sha256.New()
+	hash.Write(seed)
+	for _, addr := range addrs {
+		hash.Write([]byte(addr))
+		hash.Write(outputs[addr])
+	}
+	return hash.Sum(nil)
+}
+
+// VRFValidatorsElection selects n validators from the candidates by the VRF outputs they proved for the epoch.
+// The candidates with outputs are sampled by stake without replacement: each one gets the ticket ln(u)/weight of
+// u in (0, 1] derived from its output, the highest tickets win, which draws them one by one with the probability
+// proportional to their stakes. The seats left are filled from the other candidates by ValidatorsElection over
+// seed, so the candidates not proving could not take the seats of the ones proving.
+func VRFValidatorsElection(infos []*dpos.CandidateInfo, n int, seed []byte, outputs map[string][]byte,
+	outSort bool) ([]*dpos.CandidateInfo, error) {
+	if n == 0 {
+		return nil, fmt.Errorf("can not select zero validators")
+	}
+	if n > len(infos) {
+		return nil, fmt.Errorf("the number of candidate is not enough, candidate[%v] validator[%v]", len(infos), n)
+	}
+	var (
+		provers = make([]*dpos.CandidateInfo, 0, len(outputs))
+		tickets = make(map[string]*big.Float, len(outputs))
+		others  = make([]*dpos.CandidateInfo, 0, len(infos))
+	)
+	for _, info := range infos {
+		weight, ok := big.NewInt(0).SetString(info.Weight, 10)
+		if !ok || weight.Sign() < 0 {
+			return nil, fmt.Errorf("invalid weight[%s] of candidate[%s]", info.Weight, info.PeerId)
+		}
+		output := outputs[info.PeerId]
+		if len(output) == 0 || weight.Sign() == 0 {
+			others = append(others, info)
+			continue
+		}
+		provers = append(provers, info)
+		tickets[info.PeerId] = vrfTicket(output, weight)
+	}
+	sort.Slice(provers, func(i, j int) bool {
+		if cmp := tickets[provers[i].PeerId].Cmp(tickets[provers[j].PeerId]); cmp != 0 {
+			return cmp > 0
+		}
+		return provers[i].PeerId < provers[j].PeerId
+	})
+	validators := make([]*dpos.CandidateInfo, 0, n)
+	if len(provers) >= n {
+		validators = append(validators, provers[:n]...)
+	} else {
+		validators = append(validators, provers...)
+		rest, err := ValidatorsElection(others, n-len(provers), seed, false)
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, rest...)
+	}
+	if outSort {
+		sort.Sort(CandidateInfos(validators))
+	}
+	return validators, nil
+}
+
+// vrfTicket returns ln(u)/weight of the candidate, u = (output+1)/2^len(output) in (0, 1]
+func vrfTicket(output []byte, weight *big.Int) *big.Float {
+	x := new(big.Int).Add(new(big.Int).SetBytes(output), big.NewInt(1))
+	u := new(big.Float).SetPrec(vrfTicketPrec).SetInt(x)
+	u.SetMantExp(u, -8*len(output))
+	ln := bigLog(u)
+	return ln.Quo(ln, new(big.Float).SetPrec(vrfTicketPrec).SetInt(weight))
+}
+
+// bigLog returns ln(x) of x > 0 by ln(x) = e*ln(2) + ln(m), x = m*2^e and m in [0.5, 1)
+func bigLog(x *big.Float) *big.Float {
+	m := new(big.Float).SetPrec(vrfTicketPrec)
+	exp := x.MantExp(m)
+	// ln(m) = 2*atanh((m-1)/(m+1)) and ln(2) = 2*atanh(1/3)
+	z := new(big.Float).SetPrec(vrfTicketPrec).Sub(m, big.NewFloat(1))
+	z.Quo(z, new(big.Float).SetPrec(vrfTicketPrec).Add(m, big.NewFloat(1)))
+	ln := bigAtanh2(z)
+	third := new(big.Float).SetPrec(vrfTicketPrec).Quo(big.NewFloat(1), big.NewFloat(3))
+	ln2 := bigAtanh2(third)
+	return ln.Add(ln, ln2.Mul(ln2, new(big.Float).SetPrec(vrfTicketPrec).SetInt64(int64(exp))))
+}
+
+// bigAtanh2 returns 2*atanh(z) of |z| <= 1/3 by the series 2*sum(z^(2k+1)/(2k+1))
+func bigAtanh2(z *big.Float) *big.Float {
+	sum := new(big.Float).SetPrec(vrfTicketPrec)
+	term := new(big.Float).SetPrec(vrfTicketPrec).Set(z)
+	z2 := new(big.Float).SetPrec(vrfTicketPrec).Mul(z, z)
+	for k := 0; k < vrfLogTerms; k++ {
+		sum.Add(sum, new(big.Float).SetPrec(vrfTicketPrec).Quo(term, big.NewFloat(float64(2*k+1))))
+		term.Mul(term, z2)
+	}
+	return sum.Mul(sum, big.NewFloat(2))
+}
@@ -0,0 +1,165 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dpos
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"testing"
+
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	"chainmaker.org/chainmaker/common/v2/helper"
+	commonpb "chainmaker.org/chainmaker/pb-go/v2/common"
+	pbdpos "chainmaker.org/chainmaker/pb-go/v2/consensus/dpos"
+	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"chainmaker.org/chainmaker/vm-native/v2/dposmgr"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+// newVRFTestKey returns a P-256 key with the PEM of its public key and the node id of it
+func newVRFTestKey(t *testing.T) (*ecdsa.PrivateKey, []byte, string) {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&sk.PublicKey)
+	require.NoError(t, err)
+	pkPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	pk, err := asym.PublicKeyFromPEM(pkPEM)
+	require.NoError(t, err)
+	nodeId, err := helper.CreateLibp2pPeerIdWithPublicKey(pk)
+	require.NoError(t, err)
+	return sk, pkPEM, nodeId
+}
+
+// newVRFTestContext returns a tx context over the state
+func newVRFTestContext(ctrl *gomock.Controller, state map[string][]byte) *mock.MockTxSimContext {
+	txSimContext := mock.NewMockTxSimContext(ctrl)
+	txSimContext.EXPECT().Get(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(contractName string, key []byte) ([]byte, error) {
+			return state[contractName+"/"+string(key)], nil
+		})
+	txSimContext.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(contractName string, key, value []byte) error {
+			state[contractName+"/"+string(key)] = value
+			return nil
+		})
+	return txSimContext
+}
+
+func TestExecuteVRFProof(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	stake := syscontract.SystemContract_DPOS_STAKE.String()
+	epochBz, err := proto.Marshal(&syscontract.Epoch{EpochId: 4})
+	require.NoError(t, err)
+	sk, pkPEM, nodeId := newVRFTestKey(t)
+	_, otherPEM, _ := newVRFTestKey(t)
+	state := map[string][]byte{
+		stake + "/" + dposmgr.KeyCurrentEpoch:          epochBz,
+		stake + "/" + KeyEpochRandomness:               []byte("randomness"),
+		stake + "/" + string(VRFNodeIdKey(5, "addr1")): []byte(nodeId),
+	}
+	txSimContext := newVRFTestContext(ctrl, state)
+	output, proof, err := VRFProve(sk, EpochSeed(5, []byte("randomness")))
+	require.NoError(t, err)
+	params := func(epochId uint64, addr string, pkPEM, proof []byte) map[string][]byte {
+		return map[string][]byte{ParamEpochId: []byte(strconv.FormatUint(epochId, 10)),
+			ParamAddress: []byte(addr), ParamPublicKey: pkPEM, ParamProof: proof}
+	}
+
+	for i, c := range []struct {
+		method string
+		params map[string][]byte
+	}{
+		{method: "UNKNOWN", params: params(5, "addr1", pkPEM, proof)},
+		// only the next epoch is proved
+		{method: MethodProveEpoch, params: params(6, "addr1", pkPEM, proof)},
+		// the candidate has no node pinned
+		{method: MethodProveEpoch, params: params(5, "addr2", pkPEM, proof)},
+		// the key is not the key of the pinned node
+		{method: MethodProveEpoch, params: params(5, "addr1", otherPEM, proof)},
+		{method: MethodProveEpoch, params: params(5, "addr1", pkPEM, proof[1:])},
+	} {
+		result, _, code := ExecuteVRFProof(txSimContext, c.method, c.params)
+		require.Equal(t, commonpb.TxStatusCode_CONTRACT_FAIL, code, "case %d: %s", i, result.Message)
+	}
+	require.Empty(t, state[stake+"/"+string(VRFOutputKey(5, "addr1"))])
+
+	result, _, code := ExecuteVRFProof(txSimContext, MethodProveEpoch, params(5, "addr1", pkPEM, proof))
+	require.Equal(t, commonpb.TxStatusCode_SUCCESS, code, result.Message)
+	require.Equal(t, output, state[stake+"/"+string(VRFOutputKey(5, "addr1"))])
+	// the output is recorded once
+	_, _, code = ExecuteVRFProof(txSimContext, MethodProveEpoch, params(5, "addr1", pkPEM, proof))
+	require.Equal(t, commonpb.TxStatusCode_CONTRACT_FAIL, code)
+}
+
+func TestVRFValidatorsElection(t *testing.T) {
+	infos := make([]*pbdpos.CandidateInfo, 0, 6)
+	outputs := make(map[string][]byte)
+	for i := 0; i < 6; i++ {
+		addr := fmt.Sprintf("peer%d", i)
+		infos = append(infos, &pbdpos.CandidateInfo{PeerId: addr, Weight: strconv.Itoa(100 * (i + 1))})
+		if i%2 == 0 {
+			output := sha256.Sum256([]byte(addr))
+			outputs[addr] = output[:]
+		}
+	}
+	seed := []byte("seed")
+
+	// the candidates proving are elected before the others
+	validators, err := VRFValidatorsElection(infos, 3, seed, outputs, true)
+	require.NoError(t, err)
+	require.Len(t, validators, 3)
+	for _, val := range validators {
+		require.Contains(t, outputs, val.PeerId)
+	}
+	again, err := VRFValidatorsElection(infos, 3, seed, outputs, true)
+	require.NoError(t, err)
+	require.Equal(t, validators, again)
+
+	// the seats left are filled from the others
+	validators, err = VRFValidatorsElection(infos, 5, seed, outputs, true)
+	require.NoError(t, err)
+	require.Len(t, validators, 5)
+	elected := make(map[string]bool)
+	for _, val := range validators {
+		elected[val.PeerId] = true
+	}
+	require.Len(t, elected, 5)
+	for addr := range outputs {
+		require.True(t, elected[addr])
+	}
+
+	// no one proved
+	validators, err = VRFValidatorsElection(infos, 2, seed, nil, true)
+	require.NoError(t, err)
+	require.Len(t, validators, 2)
+	_, err = VRFValidatorsElection(infos, 7, seed, outputs, true)
+	require.Error(t, err)
+}
+
+func TestVRFTicket(t *testing.T) {
+	for _, x := range []float64{1, 0.75, 0.5, 0.1, 1e-9} {
+		ln, _ := bigLog(new(big.Float).SetPrec(vrfTicketPrec).SetFloat64(x)).Float64()
+		require.InDelta(t, math.Log(x), ln, 1e-12)
+	}
+	// the tickets of the higher outputs and the higher weights are higher
+	low, high := make([]byte, 32), make([]byte, 32)
+	low[0], high[0] = 0x10, 0xf0
+	require.True(t, vrfTicket(high, big.NewInt(100)).Cmp(vrfTicket(low, big.NewInt(100))) > 0)
+	require.True(t, vrfTicket(low, big.NewInt(1000)).Cmp(vrfTicket(low, big.NewInt(100))) > 0)
+}
@@ -0,0 +1,572 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dpos
+
+import (
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	bcx509 "chainmaker.org/chainmaker/common/v2/crypto/x509"
+	"chainmaker.org/chainmaker/common/v2/helper"
+	"chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	configPb "chainmaker.org/chainmaker/pb-go/v2/config"
+	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/vm-native/v2/dposmgr"
+	"github.com/gogo/protobuf/proto"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// keys of the reward config in chain config consensus.ext_config
+const (
+	// RewardModeKey selects how the epoch reward is issued, RewardModeFixed or RewardModeInflation,
+	// no reward is issued when it is not set
+	RewardModeKey = "dpos.reward_mode"
+	// EpochRewardKey is the amount issued every epoch in RewardModeFixed
+	EpochRewardKey = "dpos.epoch_reward"
+	// InflationRateKey is the yearly inflation of the bonded tokens in basis points in RewardModeInflation
+	InflationRateKey = "dpos.inflation_rate"
+	// EpochsPerYearKey is the number of epochs in a year in RewardModeInflation
+	EpochsPerYearKey = "dpos.epochs_per_year"
+	// CommissionRateKey is the default commission rate of the validators in basis points,
+	// the rate of a validator is overridden by CommissionRateKey + "." + validatorAddress
+	CommissionRateKey = "dpos.commission_rate"
+)
+
+const (
+	// RewardModeFixed issues EpochRewardKey every epoch
+	RewardModeFixed = "fixed"
+	// RewardModeInflation issues bondedTokens * InflationRateKey / EpochsPerYearKey every epoch
+	RewardModeInflation = "inflation"
+
+	// RewardContractName is the contract name of the reward txs and queries
+	RewardContractName = "DPOS_REWARD"
+	// RewardMethodWithdraw withdraws the accrued rewards of RewardParamAddress to its balance, the tx
+	// is executed by the node, which checks it is sent by the owner of RewardParamAddress, and the
+	// rewards are moved by the DPoS consensus of the block for the txs succeeded only
+	RewardMethodWithdraw = "WITHDRAW_REWARD"
+	// RewardMethodGetReward queries the accrued rewards of RewardParamAddress
+	RewardMethodGetReward = "GET_REWARD"
+	// RewardParamAddress is the address parameter of the reward methods
+	RewardParamAddress = "address"
+
+	// KeyRewardIssued stores the total rewards issued in the stake contract
+	KeyRewardIssued = "REWARD_ISSUED"
+
+	keyRewardPrefix = "REWARD/"
+	basisPoints     = 10000
+)
+
+// RewardConfig is the reward config of DPoS
+type RewardConfig struct {
+	Mode          string
+	EpochReward   *big.Int
+	InflationRate uint64
+	EpochsPerYear uint64
+	// Commission is the default commission rate, Commissions overrides it by validator address
+	Commission  uint64
+	Commissions map[string]uint64
+}
+
+// Enabled returns whether rewards are issued
+func (c *RewardConfig) Enabled() bool {
+	return c.Mode == RewardModeFixed || c.Mode == RewardModeInflation
+}
+
+// CommissionOf returns the commission rate of the validator in basis points
+func (c *RewardConfig) CommissionOf(validator string) uint64 {
+	if rate, ok := c.Commissions[validator]; ok {
+		return rate
+	}
+	return c.Commission
+}
+
+// ParseRewardConfig parses the reward config from consensus.ext_config
+func ParseRewardConfig(chainConfig *configPb.ChainConfig) (*RewardConfig, error) {
+	rewardConfig := &RewardConfig{
+		EpochReward: big.NewInt(0),
+		Commissions: make(map[string]uint64),
+	}
+	if chainConfig == nil || chainConfig.Consensus == nil {
+		return rewardConfig, nil
+	}
+	parseRate := func(key, value string) (uint64, error) {
+		rate, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || rate > basisPoints {
+			return 0, fmt.Errorf("invalid %s: %s, it should be in [0, %d] basis points", key, value, basisPoints)
+		}
+		return rate, nil
+	}
+	var err error
+	for _, kv := range chainConfig.Consensus.ExtConfig {
+		value := string(kv.Value)
+		switch {
+		case kv.Key == RewardModeKey:
+			rewardConfig.Mode = value
+			if !rewardConfig.Enabled() {
+				return nil, fmt.Errorf("invalid %s: %s", RewardModeKey, value)
+			}
+		case kv.Key == EpochRewardKey:
+			reward, ok := big.NewInt(0).SetString(value, 10)
+			if !ok || reward.Sign() < 0 {
+				return nil, fmt.Errorf("invalid %s: %s", EpochRewardKey, value)
+			}
+			rewardConfig.EpochReward = reward
+		case kv.Key == InflationRateKey:
+			if rewardConfig.InflationRate, err = parseRate(kv.Key, value); err != nil {
+				return nil, err
+			}
+		case kv.Key == EpochsPerYearKey:
+			epochs, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || epochs == 0 {
+				return nil, fmt.Errorf("invalid %s: %s", EpochsPerYearKey, value)
+			}
+			rewardConfig.EpochsPerYear = epochs
+		case kv.Key == CommissionRateKey:
+			if rewardConfig.Commission, err = parseRate(kv.Key, value); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(kv.Key, CommissionRateKey+"."):
+			validator := strings.TrimPrefix(kv.Key, CommissionRateKey+".")
+			if rewardConfig.Commissions[validator], err = parseRate(kv.Key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if rewardConfig.Mode == RewardModeInflation && rewardConfig.EpochsPerYear == 0 {
+		return nil, fmt.Errorf("%s is required by reward mode %s", EpochsPerYearKey, RewardModeInflation)
+	}
+	return rewardConfig, nil
+}
+
+// ToRewardKey returns the key of the accrued rewards of addr in the stake contract
+func ToRewardKey(addr string) []byte {
+	return []byte(keyRewardPrefix + addr)
+}
+
+// GetAccruedReward returns the accrued rewards of addr which are not withdrawn
+func GetAccruedReward(store protocol.BlockchainStore, addr string) (*big.Int, error) {
+	val, err := store.ReadObject(syscontract.SystemContract_DPOS_STAKE.String(), ToRewardKey(addr))
+	if err != nil {
+		return nil, fmt.Errorf("read accrued reward of %s failed, reason: %s", addr, err)
+	}
+	return parseAmount(val)
+}
+
+// rewardLedger caches the accrued rewards changed in a block, the writes are
+// generated in the order of address so that all the nodes get the same rwSet.
+type rewardLedger struct {
+	store   protocol.BlockchainStore
+	accrued map[string]*big.Int
+	changed map[string]bool
+	issued  *big.Int
+}
+
+func newRewardLedger(store protocol.BlockchainStore) *rewardLedger {
+	return &rewardLedger{store: store, accrued: make(map[string]*big.Int), changed: make(map[string]bool)}
+}
+
+func (l *rewardLedger) rewardOf(addr string) (*big.Int, error) {
+	if reward, ok := l.accrued[addr]; ok {
+		return reward, nil
+	}
+	reward, err := GetAccruedReward(l.store, addr)
+	if err != nil {
+		return nil, err
+	}
+	l.accrued[addr] = reward
+	return reward, nil
+}
+
+func (l *rewardLedger) add(addr string, amount *big.Int) error {
+	if amount.Sign() == 0 {
+		return nil
+	}
+	reward, err := l.rewardOf(addr)
+	if err != nil {
+		return err
+	}
+	reward.Add(reward, amount)
+	l.changed[addr] = true
+	return nil
+}
+
+// take clears the accrued rewards of addr and returns them
+func (l *rewardLedger) take(addr string) (*big.Int, error) {
+	reward, err := l.rewardOf(addr)
+	if err != nil {
+		return nil, err
+	}
+	amount := big.NewInt(0).Set(reward)
+	if amount.Sign() != 0 {
+		reward.SetInt64(0)
+		l.changed[addr] = true
+	}
+	return amount, nil
+}
+
+func (l *rewardLedger) addIssued(amount *big.Int) error {
+	if l.issued == nil {
+		val, err := l.store.ReadObject(syscontract.SystemContract_DPOS_STAKE.String(), []byte(KeyRewardIssued))
+		if err != nil {
+			return fmt.Errorf("read issued reward failed, reason: %s", err)
+		}
+		if l.issued, err = parseAmount(val); err != nil {
+			return err
+		}
+	}
+	l.issued.Add(l.issued, amount)
+	return nil
+}
+
+func (l *rewardLedger) writes() []*common.TxWrite {
+	addrs := make([]string, 0, len(l.changed))
+	for addr := range l.changed {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	writes := make([]*common.TxWrite, 0, len(addrs)+1)
+	for _, addr := range addrs {
+		writes = append(writes, &common.TxWrite{
+			ContractName: syscontract.SystemContract_DPOS_STAKE.String(),
+			Key:          ToRewardKey(addr),
+			Value:        []byte(l.accrued[addr].String()),
+		})
+	}
+	if l.issued != nil {
+		writes = append(writes, &common.TxWrite{
+			ContractName: syscontract.SystemContract_DPOS_STAKE.String(),
+			Key:          []byte(KeyRewardIssued),
+			Value:        []byte(l.issued.String()),
+		})
+	}
+	return writes
+}
+
+// distributeEpochReward issues the reward of the epoch to the validators of it, each
+// validator gets an equal share, keeps its commission and pays the rest to its
+// delegators pro rata to their shares, the validator itself included.
+func (impl *DPoSImpl) distributeEpochReward(ledger *rewardLedger, epoch *syscontract.Epoch) error {
+	rewardConfig, err := ParseRewardConfig(impl.chainConf.ChainConfig())
+	if err != nil {
+		impl.log.Errorf("parse reward config failed, reason: %s", err)
+		return err
+	}
+	if !rewardConfig.Enabled() || len(epoch.ProposerVector) == 0 {
+		return nil
+	}
+	validators := make([]*syscontract.Validator, 0, len(epoch.ProposerVector))
+	bonded := big.NewInt(0)
+	for _, addr := range epoch.ProposerVector {
+		val, err := impl.getValidator(addr)
+		if err != nil {
+			return err
+		}
+		tokens, err := parseAmount([]byte(val.Tokens))
+		if err != nil {
+			return err
+		}
+		bonded.Add(bonded, tokens)
+		validators = append(validators, val)
+	}
+
+	total := big.NewInt(0).Set(rewardConfig.EpochReward)
+	if rewardConfig.Mode == RewardModeInflation {
+		total.Mul(bonded, big.NewInt(0).SetUint64(rewardConfig.InflationRate))
+		total.Quo(total, big.NewInt(0).SetUint64(basisPoints*rewardConfig.EpochsPerYear))
+	}
+	if total.Sign() == 0 {
+		return nil
+	}
+	share := big.NewInt(0).Quo(total, big.NewInt(int64(len(validators))))
+	if share.Sign() == 0 {
+		return nil
+	}
+
+	delegations, err := impl.getDelegationsByValidator()
+	if err != nil {
+		return err
+	}
+	for _, val := range validators {
+		commission := big.NewInt(0).Mul(share, big.NewInt(0).SetUint64(rewardConfig.CommissionOf(val.ValidatorAddress)))
+		commission.Quo(commission, big.NewInt(basisPoints))
+		rest := big.NewInt(0).Sub(share, commission)
+
+		paid := big.NewInt(0)
+		totalShares, ok := big.NewInt(0).SetString(val.DelegatorShares, 10)
+		if ok && totalShares.Sign() > 0 {
+			for _, delegation := range delegations[val.ValidatorAddress] {
+				shares, ok := big.NewInt(0).SetString(delegation.Shares, 10)
+				if !ok {
+					return fmt.Errorf("invalid shares[%s] of delegation %s -> %s",
+						delegation.Shares, delegation.DelegatorAddress, delegation.ValidatorAddress)
+				}
+				amount := big.NewInt(0).Mul(rest, shares)
+				amount.Quo(amount, totalShares)
+				if err = ledger.add(delegation.DelegatorAddress, amount); err != nil {
+					return err
+				}
+				paid.Add(paid, amount)
+			}
+		}
+		// the validator gets its commission and the remainder of the rounding
+		if err = ledger.add(val.ValidatorAddress, big.NewInt(0).Sub(share, paid)); err != nil {
+			return err
+		}
+	}
+	return ledger.addIssued(big.NewInt(0).Mul(share, big.NewInt(int64(len(validators)))))
+}
+
+// withdrawRewards moves the accrued rewards of the senders of the withdraw txs of
+// the block to their balances and adds them to the total supply of the tokens.
+// writes are the consensus writes generated before, the balances changed by them
+// are taken into account.
+func (impl *DPoSImpl) withdrawRewards(ledger *rewardLedger, block *common.Block,
+	blockTxRwSet map[string]*common.TxRWSet, writes []*common.TxWrite) ([]*common.TxWrite, error) {
+	balanceWrites := make([]*common.TxWrite, 0)
+	minted := big.NewInt(0)
+	for _, addr := range impl.withdrawRequests(block) {
+		amount, err := ledger.take(addr)
+		if err != nil {
+			return nil, err
+		}
+		if amount.Sign() == 0 {
+			continue
+		}
+		balance, err := impl.latestBalanceOf(addr, append(writes, balanceWrites...), block, blockTxRwSet)
+		if err != nil {
+			return nil, err
+		}
+		wSet, _, err := impl.addBalanceRwSet(addr, balance, amount.String())
+		if err != nil {
+			return nil, err
+		}
+		impl.log.Debugf("withdraw reward %s of %s", amount.String(), addr)
+		balanceWrites = append(balanceWrites, wSet)
+		minted.Add(minted, amount)
+	}
+	if minted.Sign() == 0 {
+		return balanceWrites, nil
+	}
+	supplyWrite, err := impl.addTotalSupplyRwSet(minted, block, blockTxRwSet)
+	if err != nil {
+		return nil, err
+	}
+	return append(balanceWrites, supplyWrite), nil
+}
+
+// withdrawRequests returns the addresses requested to withdraw by the txs of block, in order.
+// Only the txs succeeded are honored, an address is only withdrawn by a tx sent by itself.
+func (impl *DPoSImpl) withdrawRequests(block *common.Block) []string {
+	var addrs []string
+	requested := make(map[string]bool)
+	for _, tx := range block.Txs {
+		if tx.Payload == nil || tx.Payload.ContractName != RewardContractName ||
+			tx.Payload.Method != RewardMethodWithdraw {
+			continue
+		}
+		if tx.Result == nil || tx.Result.Code != common.TxStatusCode_SUCCESS {
+			impl.log.Debugf("ignore the withdrawal of the failed tx %s", tx.Payload.TxId)
+			continue
+		}
+		for _, kv := range tx.Payload.Parameters {
+			addr := string(kv.Value)
+			if kv.Key != RewardParamAddress || addr == "" || requested[addr] {
+				continue
+			}
+			sender, err := impl.senderAddress(tx)
+			if err != nil || sender != addr {
+				impl.log.Warnf("ignore the withdrawal of %s by tx %s, the sender %s does not own the reward, %v",
+					addr, tx.Payload.TxId, sender, err)
+				continue
+			}
+			requested[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// ExecuteRewardWithdraw executes the RewardMethodWithdraw tx, it fails if the tx is not sent by the owner of the
+// address or there are no rewards to withdraw. The rewards are moved to the balance by the consensus of the block.
+func ExecuteRewardWithdraw(txSimContext protocol.TxSimContext, method string, params map[string][]byte) (
+	*common.ContractResult, protocol.ExecOrderTxType, common.TxStatusCode) {
+	if method != RewardMethodWithdraw {
+		return rewardFailed(fmt.Errorf("unknown method %s of %s", method, RewardContractName))
+	}
+	addr := string(params[RewardParamAddress])
+	if addr == "" {
+		return rewardFailed(fmt.Errorf("param %s is required", RewardParamAddress))
+	}
+	stake := syscontract.SystemContract_DPOS_STAKE.String()
+	sender, err := senderAddress(txSimContext.GetTx(), func(certHash string) ([]byte, error) {
+		return txSimContext.Get(syscontract.SystemContract_CERT_MANAGE.String(), []byte(certHash))
+	})
+	if err != nil {
+		return rewardFailed(err)
+	}
+	if sender != addr {
+		return rewardFailed(fmt.Errorf("the sender %s does not own the reward of %s", sender, addr))
+	}
+	val, err := txSimContext.Get(stake, ToRewardKey(addr))
+	if err != nil {
+		return rewardFailed(err)
+	}
+	reward, err := parseAmount(val)
+	if err != nil {
+		return rewardFailed(err)
+	}
+	if reward.Sign() == 0 {
+		return rewardFailed(fmt.Errorf("no reward of %s to withdraw", addr))
+	}
+	return &common.ContractResult{Result: []byte(reward.String())}, protocol.ExecOrderTxTypeNormal,
+		common.TxStatusCode_SUCCESS
+}
+
+func rewardFailed(err error) (*common.ContractResult, protocol.ExecOrderTxType, common.TxStatusCode) {
+	return &common.ContractResult{Code: 1, Message: err.Error()}, protocol.ExecOrderTxTypeNormal,
+		common.TxStatusCode_CONTRACT_FAIL
+}
+
+// senderAddress returns the DPoS address of the sender of tx, which is the libp2p
+// peer id of the public key of the sender, as the addresses of the stake contract.
+func (impl *DPoSImpl) senderAddress(tx *common.Transaction) (string, error) {
+	return senderAddress(tx, func(certHash string) ([]byte, error) {
+		return impl.stateDB.ReadObject(syscontract.SystemContract_CERT_MANAGE.String(), []byte(certHash))
+	})
+}
+
+// senderAddress returns the DPoS address of the sender of tx, the certs of the hashes are read by readCert
+func senderAddress(tx *common.Transaction, readCert func(certHash string) ([]byte, error)) (string, error) {
+	if tx == nil || tx.Sender == nil || tx.Sender.Signer == nil {
+		return "", fmt.Errorf("no sender")
+	}
+	signer := tx.Sender.Signer
+	var pk bccrypto.PublicKey
+	switch signer.MemberType {
+	case accesscontrol.MemberType_PUBLIC_KEY:
+		key, err := asym.PublicKeyFromPEM(signer.MemberInfo)
+		if err != nil {
+			return "", fmt.Errorf("parse public key of sender failed, %s", err)
+		}
+		pk = key
+	case accesscontrol.MemberType_CERT, accesscontrol.MemberType_CERT_HASH:
+		certPEM := signer.MemberInfo
+		if signer.MemberType == accesscontrol.MemberType_CERT_HASH {
+			var err error
+			certPEM, err = readCert(hex.EncodeToString(signer.MemberInfo))
+			if err != nil || len(certPEM) == 0 {
+				return "", fmt.Errorf("the cert %x of sender is not registered, %v", signer.MemberInfo, err)
+			}
+		}
+		certDER := certPEM
+		if block, _ := pem.Decode(certPEM); block != nil {
+			certDER = block.Bytes
+		}
+		cert, err := bcx509.ParseCertificate(certDER)
+		if err != nil {
+			return "", fmt.Errorf("parse cert of sender failed, %s", err)
+		}
+		pk = cert.PublicKey
+	default:
+		return "", fmt.Errorf("sender of member type %s is not supported", signer.MemberType)
+	}
+	return helper.CreateLibp2pPeerIdWithPublicKey(pk)
+}
+
+// addTotalSupplyRwSet adds the minted tokens to the total supply of the erc20 contract
+func (impl *DPoSImpl) addTotalSupplyRwSet(minted *big.Int, block *common.Block,
+	blockTxRwSet map[string]*common.TxRWSet) (*common.TxWrite, error) {
+	val, err := impl.getState(syscontract.SystemContract_DPOS_ERC20.String(), []byte(dposmgr.KeyTotalSupply),
+		block, blockTxRwSet)
+	if err != nil {
+		return nil, err
+	}
+	supply, err := parseAmount(val)
+	if err != nil {
+		return nil, err
+	}
+	return &common.TxWrite{
+		ContractName: syscontract.SystemContract_DPOS_ERC20.String(),
+		Key:          []byte(dposmgr.KeyTotalSupply),
+		Value:        []byte(supply.Add(supply, minted).String()),
+	}, nil
+}
+
+// latestBalanceOf returns the balance of addr after the consensus writes
+func (impl *DPoSImpl) latestBalanceOf(addr string, writes []*common.TxWrite,
+	block *common.Block, blockTxRwSet map[string]*common.TxRWSet) (*big.Int, error) {
+	key := []byte(dposmgr.BalanceKey(addr))
+	for i := len(writes) - 1; i >= 0; i-- {
+		if writes[i].ContractName == syscontract.SystemContract_DPOS_ERC20.String() &&
+			string(writes[i].Key) == string(key) {
+			return parseAmount(writes[i].Value)
+		}
+	}
+	return impl.balanceOf(addr, block, blockTxRwSet)
+}
+
+func (impl *DPoSImpl) getValidator(addr string) (*syscontract.Validator, error) {
+	bz, err := impl.stateDB.ReadObject(syscontract.SystemContract_DPOS_STAKE.String(), dposmgr.ToValidatorKey(addr))
+	if err != nil || len(bz) == 0 {
+		impl.log.Errorf("read validator[%s] failed, reason: %v", addr, err)
+		return nil, fmt.Errorf("read validator[%s] failed, reason: %v", addr, err)
+	}
+	val := &syscontract.Validator{}
+	if err = proto.Unmarshal(bz, val); err != nil {
+		impl.log.Errorf("unmarshal validator failed, reason: %s", err)
+		return nil, err
+	}
+	return val, nil
+}
+
+// getDelegationsByValidator returns all the delegations grouped by validator address
+func (impl *DPoSImpl) getDelegationsByValidator() (map[string][]*syscontract.Delegation, error) {
+	iterRange := util.BytesPrefix(dposmgr.ToDelegationPrefix(""))
+	iter, err := impl.stateDB.SelectObject(
+		syscontract.SystemContract_DPOS_STAKE.String(), iterRange.Start, iterRange.Limit)
+	if err != nil {
+		impl.log.Errorf("new select range failed, reason: %s", err)
+		return nil, err
+	}
+	defer iter.Release()
+
+	delegations := make(map[string][]*syscontract.Delegation)
+	for iter.Next() {
+		kv, err := iter.Value()
+		if err != nil {
+			impl.log.Errorf("get kv from iterator failed, reason: %s", err)
+			return nil, err
+		}
+		delegation := &syscontract.Delegation{}
+		if err = proto.Unmarshal(kv.Value, delegation); err != nil {
+			impl.log.Errorf("unmarshal value to Delegation failed, reason: %s", err)
+			return nil, err
+		}
+		delegations[delegation.ValidatorAddress] = append(delegations[delegation.ValidatorAddress], delegation)
+	}
+	return delegations, nil
+}
+
+func parseAmount(val []byte) (*big.Int, error) {
+	amount := big.NewInt(0)
+	if len(val) == 0 {
+		return amount, nil
+	}
+	if _, ok := amount.SetString(string(val), 10); !ok {
+		return nil, fmt.Errorf("invalid amount: %s", val)
+	}
+	return amount, nil
+}
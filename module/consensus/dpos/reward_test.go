@@ -0,0 +1,280 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dpos
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	"chainmaker.org/chainmaker/common/v2/helper"
+	"chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	configPb "chainmaker.org/chainmaker/pb-go/v2/config"
+	consensuspb "chainmaker.org/chainmaker/pb-go/v2/consensus"
+	storePb "chainmaker.org/chainmaker/pb-go/v2/store"
+	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"chainmaker.org/chainmaker/vm-native/v2/dposmgr"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func newRewardChainConfig(kvs map[string]string) *configPb.ChainConfig {
+	chainConfig := &configPb.ChainConfig{Consensus: &configPb.ConsensusConfig{}}
+	for k, v := range kvs {
+		chainConfig.Consensus.ExtConfig = append(chainConfig.Consensus.ExtConfig,
+			&common.KeyValuePair{Key: k, Value: []byte(v)})
+	}
+	return chainConfig
+}
+
+func TestParseRewardConfig(t *testing.T) {
+	rewardConfig, err := ParseRewardConfig(newRewardChainConfig(nil))
+	require.NoError(t, err)
+	require.False(t, rewardConfig.Enabled())
+
+	rewardConfig, err = ParseRewardConfig(newRewardChainConfig(map[string]string{
+		RewardModeKey:                     RewardModeFixed,
+		EpochRewardKey:                    "1000",
+		CommissionRateKey:                 "500",
+		CommissionRateKey + ".validator1": "1000",
+	}))
+	require.NoError(t, err)
+	require.True(t, rewardConfig.Enabled())
+	require.Equal(t, "1000", rewardConfig.EpochReward.String())
+	require.EqualValues(t, 500, rewardConfig.CommissionOf("validator0"))
+	require.EqualValues(t, 1000, rewardConfig.CommissionOf("validator1"))
+
+	_, err = ParseRewardConfig(newRewardChainConfig(map[string]string{RewardModeKey: "unknown"}))
+	require.Error(t, err)
+	_, err = ParseRewardConfig(newRewardChainConfig(map[string]string{CommissionRateKey: "10001"}))
+	require.Error(t, err)
+	_, err = ParseRewardConfig(newRewardChainConfig(map[string]string{RewardModeKey: RewardModeInflation}))
+	require.Error(t, err)
+}
+
+func newTestSender(t *testing.T) (*accesscontrol.Member, string) {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&sk.PublicKey)
+	require.NoError(t, err)
+	pkPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	pk, err := asym.PublicKeyFromPEM(pkPEM)
+	require.NoError(t, err)
+	addr, err := helper.CreateLibp2pPeerIdWithPublicKey(pk)
+	require.NoError(t, err)
+	return &accesscontrol.Member{MemberType: accesscontrol.MemberType_PUBLIC_KEY, MemberInfo: pkPEM}, addr
+}
+
+func newWithdrawTx(txId string, sender *accesscontrol.Member, addr string) *common.Transaction {
+	return &common.Transaction{
+		Payload: &common.Payload{TxId: txId, ContractName: RewardContractName, Method: RewardMethodWithdraw,
+			Parameters: []*common.KeyValuePair{{Key: RewardParamAddress, Value: []byte(addr)}}},
+		Sender: &common.EndorsementEntry{Signer: sender},
+		Result: &common.Result{Code: common.TxStatusCode_SUCCESS},
+	}
+}
+
+func TestDPoSImpl_withdrawRewards(t *testing.T) {
+	impl, fn := initTestImpl(t)
+	defer fn()
+
+	owner, ownerAddr := newTestSender(t)
+	other, _ := newTestSender(t)
+	rewards := newRewardLedger(impl.stateDB)
+	require.NoError(t, rewards.add(ownerAddr, big.NewInt(100)))
+	require.NoError(t, rewards.add(testAddr, big.NewInt(50)))
+	block := &common.Block{Txs: []*common.Transaction{
+		// the rewards are only withdrawn by their owners
+		newWithdrawTx("tx1", other, ownerAddr),
+		newWithdrawTx("tx2", other, testAddr),
+		newWithdrawTx("tx3", owner, ownerAddr),
+		newWithdrawTx("tx4", owner, ownerAddr),
+	}}
+	blockRwSet := map[string]*common.TxRWSet{"tx1": {}, "tx2": {}, "tx3": {}, "tx4": {}}
+
+	writes, err := impl.withdrawRewards(rewards, block, blockRwSet, nil)
+	require.NoError(t, err)
+	require.Len(t, writes, 2)
+	require.Equal(t, syscontract.SystemContract_DPOS_ERC20.String(), writes[0].ContractName)
+	require.Equal(t, []byte(dposmgr.BalanceKey(ownerAddr)), writes[0].Key)
+	require.Equal(t, "100", string(writes[0].Value))
+	// the withdrawn rewards are minted
+	require.Equal(t, syscontract.SystemContract_DPOS_ERC20.String(), writes[1].ContractName)
+	require.Equal(t, []byte(dposmgr.KeyTotalSupply), writes[1].Key)
+	require.Equal(t, "100", string(writes[1].Value))
+
+	rewardWrites := rewards.writes()
+	require.Len(t, rewardWrites, 2)
+	for _, write := range rewardWrites {
+		switch string(write.Key) {
+		case string(ToRewardKey(ownerAddr)):
+			require.Equal(t, "0", string(write.Value))
+		case string(ToRewardKey(testAddr)):
+			require.Equal(t, "50", string(write.Value))
+		default:
+			t.Fatalf("unexpected reward write %s", write.Key)
+		}
+	}
+}
+
+func TestDPoSImpl_withdrawRequests(t *testing.T) {
+	impl, fn := initTestImpl(t)
+	defer fn()
+
+	owner, ownerAddr := newTestSender(t)
+	failed := newWithdrawTx("tx1", owner, ownerAddr)
+	failed.Result.Code = common.TxStatusCode_CONTRACT_FAIL
+	notExecuted := newWithdrawTx("tx2", owner, ownerAddr)
+	notExecuted.Result = nil
+	// the withdrawals failed in the execution are not honored
+	require.Empty(t, impl.withdrawRequests(&common.Block{Txs: []*common.Transaction{failed, notExecuted}}))
+	require.Equal(t, []string{ownerAddr}, impl.withdrawRequests(&common.Block{Txs: []*common.Transaction{
+		failed, newWithdrawTx("tx3", owner, ownerAddr)}}))
+}
+
+func TestExecuteRewardWithdraw(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	owner, ownerAddr := newTestSender(t)
+	other, otherAddr := newTestSender(t)
+	stake := syscontract.SystemContract_DPOS_STAKE.String()
+	rewards := map[string][]byte{string(ToRewardKey(ownerAddr)): []byte("100")}
+
+	for i, c := range []struct {
+		tx     *common.Transaction
+		method string
+		code   common.TxStatusCode
+	}{
+		{tx: newWithdrawTx("tx1", owner, ownerAddr), method: RewardMethodWithdraw, code: common.TxStatusCode_SUCCESS},
+		{tx: newWithdrawTx("tx2", owner, ownerAddr), method: RewardMethodGetReward,
+			code: common.TxStatusCode_CONTRACT_FAIL},
+		// the reward is only withdrawn by its owner
+		{tx: newWithdrawTx("tx3", other, ownerAddr), method: RewardMethodWithdraw,
+			code: common.TxStatusCode_CONTRACT_FAIL},
+		// no reward to withdraw
+		{tx: newWithdrawTx("tx4", other, otherAddr), method: RewardMethodWithdraw,
+			code: common.TxStatusCode_CONTRACT_FAIL},
+	} {
+		txSimContext := mock.NewMockTxSimContext(ctrl)
+		txSimContext.EXPECT().GetTx().AnyTimes().Return(c.tx)
+		txSimContext.EXPECT().Get(stake, gomock.Any()).AnyTimes().DoAndReturn(
+			func(_ string, key []byte) ([]byte, error) {
+				return rewards[string(key)], nil
+			})
+		params := map[string][]byte{RewardParamAddress: c.tx.Payload.Parameters[0].Value}
+		result, _, code := ExecuteRewardWithdraw(txSimContext, c.method, params)
+		require.Equal(t, c.code, code, "case %d: %s", i, result.Message)
+		if code == common.TxStatusCode_SUCCESS {
+			require.Equal(t, "100", string(result.Result))
+		}
+	}
+}
+
+// newRewardTestImpl creates an impl over the state of the validators and the delegations
+func newRewardTestImpl(t *testing.T, kvs map[string]string, validators []*syscontract.Validator,
+	delegations []*syscontract.Delegation) (*DPoSImpl, func()) {
+	ctrl := gomock.NewController(t)
+	state := make(map[string][]byte)
+	for _, val := range validators {
+		bz, err := proto.Marshal(val)
+		require.NoError(t, err)
+		state[string(dposmgr.ToValidatorKey(val.ValidatorAddress))] = bz
+	}
+	store := mock.NewMockBlockchainStore(ctrl)
+	store.EXPECT().ReadObject(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(contractName string, key []byte) ([]byte, error) {
+			return state[string(key)], nil
+		}).AnyTimes()
+	store.EXPECT().SelectObject(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(contractName string, startKey []byte, limit []byte) (protocol.StateIterator, error) {
+			i := -1
+			iter := mock.NewMockStateIterator(ctrl)
+			iter.EXPECT().Next().DoAndReturn(func() bool {
+				i++
+				return i < len(delegations)
+			}).AnyTimes()
+			iter.EXPECT().Value().DoAndReturn(func() (*storePb.KV, error) {
+				bz, err := proto.Marshal(delegations[i])
+				return &storePb.KV{ContractName: contractName, Value: bz}, err
+			}).AnyTimes()
+			iter.EXPECT().Release().AnyTimes()
+			return iter, nil
+		}).AnyTimes()
+	chainConfig := newRewardChainConfig(kvs)
+	chainConfig.ChainId = "test_chain"
+	chainConfig.Consensus.Type = consensuspb.ConsensusType_DPOS
+	chainConf := mock.NewMockChainConf(ctrl)
+	chainConf.EXPECT().ChainConfig().Return(chainConfig).AnyTimes()
+	return NewDPoSImpl(chainConf, store), ctrl.Finish
+}
+
+func TestDPoSImpl_distributeEpochReward(t *testing.T) {
+	validators := []*syscontract.Validator{
+		{ValidatorAddress: "v1", Tokens: "100", DelegatorShares: "100"},
+		{ValidatorAddress: "v2", Tokens: "300", DelegatorShares: "300"},
+	}
+	delegations := []*syscontract.Delegation{
+		{DelegatorAddress: "v1", ValidatorAddress: "v1", Shares: "60"},
+		{DelegatorAddress: "d1", ValidatorAddress: "v1", Shares: "40"},
+	}
+	epoch := &syscontract.Epoch{ProposerVector: []string{"v1", "v2"}}
+	rewardsOf := func(ledger *rewardLedger) map[string]string {
+		rewards := make(map[string]string)
+		for _, write := range ledger.writes() {
+			rewards[string(write.Key)] = string(write.Value)
+		}
+		return rewards
+	}
+
+	// each validator gets 500, v1 keeps 10% commission and pays the rest pro rata to the shares
+	impl, fn := newRewardTestImpl(t, map[string]string{
+		RewardModeKey:     RewardModeFixed,
+		EpochRewardKey:    "1000",
+		CommissionRateKey: "1000",
+	}, validators, delegations)
+	defer fn()
+	ledger := newRewardLedger(impl.stateDB)
+	require.NoError(t, impl.distributeEpochReward(ledger, epoch))
+	require.Equal(t, map[string]string{
+		string(ToRewardKey("v1")): "320",
+		string(ToRewardKey("d1")): "180",
+		string(ToRewardKey("v2")): "500",
+		KeyRewardIssued:           "1000",
+	}, rewardsOf(ledger))
+
+	// the inflation of the bonded tokens: 400 * 10% / 2 epochs, no commission
+	impl, fn2 := newRewardTestImpl(t, map[string]string{
+		RewardModeKey:    RewardModeInflation,
+		InflationRateKey: "1000",
+		EpochsPerYearKey: "2",
+	}, validators, delegations)
+	defer fn2()
+	ledger = newRewardLedger(impl.stateDB)
+	require.NoError(t, impl.distributeEpochReward(ledger, epoch))
+	require.Equal(t, map[string]string{
+		string(ToRewardKey("v1")): "6",
+		string(ToRewardKey("d1")): "4",
+		string(ToRewardKey("v2")): "10",
+		KeyRewardIssued:           "20",
+	}, rewardsOf(ledger))
+
+	// nothing is issued without the reward config
+	impl, fn3 := newRewardTestImpl(t, nil, validators, delegations)
+	defer fn3()
+	ledger = newRewardLedger(impl.stateDB)
+	require.NoError(t, impl.distributeEpochReward(ledger, epoch))
+	require.Empty(t, ledger.writes())
+}
@@ -7,9 +7,10 @@ SPDX-License-Identifier: Apache-2.0
 package dpos
 
 import (
+	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
-	"math/rand"
+	"math/big"
 	"sort"
 	"strings"
 
@@ -24,7 +25,9 @@ import (
 	goproto "github.com/gogo/protobuf/proto"
 )
 
-// ValidatorsElection select validators from Candidates
+// ValidatorsElection select validators from Candidates by stake-weighted sampling
+// without replacement, the randomness is derived from seed of the epoch, so the
+// election is deterministic and safe to be called concurrently.
 func ValidatorsElection(
 	infos []*pbdpos.CandidateInfo, n int, seed []byte, outSort bool) ([]*pbdpos.CandidateInfo, error) {
 	if n == 0 {
@@ -40,37 +43,39 @@ func ValidatorsElection(
 		return validators, nil
 	}
 	// when m > n
-	// 首先对结果进行排序
+	// 首先对结果进行排序，保证各节点的遍历顺序一致
 	sort.Sort(CandidateInfos(infos))
-	// n、m 都分为 两部分，
 	var (
-		m0, _      = distributionM(m, n)
-		n0, n1     = distributionN(m, n)
-		validators = make([]*pbdpos.CandidateInfo, 0)
+		remain      = make([]*pbdpos.CandidateInfo, 0, m)
+		weights     = make([]*big.Int, 0, m)
+		totalWeight = big.NewInt(0)
+		validators  = make([]*pbdpos.CandidateInfo, 0, n)
 	)
-	// 首选从m0个对象中选择n0个结果
-	// |m0|after(m0) ~ m1|
-	// |n0|n1|
-	seedInt := binary.LittleEndian.Uint64(seed)
-	rand.Seed(int64(seedInt)) // 设置种子
-	selectM0IdxMap := sliceToMap(rand.Perm(m0)[:n0])
-	for k := range selectM0IdxMap {
-		validators = append(validators, infos[k])
-	}
-	// 构建新的数组
-	newSelectArray := make([]*pbdpos.CandidateInfo, 0)
-	for i := 0; i < len(infos); i++ {
-		if _, ok := selectM0IdxMap[i]; !ok {
-			newSelectArray = append(newSelectArray, infos[i])
+	for _, info := range infos {
+		weight, ok := big.NewInt(0).SetString(info.Weight, 10)
+		if !ok || weight.Sign() < 0 {
+			return nil, fmt.Errorf("invalid weight[%s] of candidate[%s]", info.Weight, info.PeerId)
 		}
+		remain = append(remain, info)
+		weights = append(weights, weight)
+		totalWeight.Add(totalWeight, weight)
 	}
-
-	// 从新的数组中选择n1个结果
-	// 理论上不需要再排序，因为最开始已经排序过
-	rand.Seed(int64(seedInt)) // 设置种子
-	selectM1IdxArray := rand.Perm(len(newSelectArray))[:n1]
-	for _, v := range selectM1IdxArray {
-		validators = append(validators, newSelectArray[v])
+	// 每轮按照剩余候选人的权重比例抽取一个，被抽中的概率与质押量成正比
+	for round := 0; round < n; round++ {
+		idx := 0
+		if totalWeight.Sign() > 0 {
+			r := new(big.Int).Mod(electionRandom(seed, round), totalWeight)
+			for acc := big.NewInt(0); idx < len(remain); idx++ {
+				acc.Add(acc, weights[idx])
+				if acc.Cmp(r) > 0 {
+					break
+				}
+			}
+		}
+		validators = append(validators, remain[idx])
+		totalWeight.Sub(totalWeight, weights[idx])
+		remain = append(remain[:idx], remain[idx+1:]...)
+		weights = append(weights[:idx], weights[idx+1:]...)
 	}
 	if outSort {
 		// 输出需要进行排序
@@ -79,21 +84,12 @@ func ValidatorsElection(
 	return validators, nil
 }
 
-func distributionM(m, n int) (int, int) {
-	return n, m - n
-}
-
-func distributionN(m, n int) (int, int) {
-	n0 := n / 2
-	return n0, n - n0
-}
-
-func sliceToMap(array []int) map[int]struct{} {
-	values := make(map[int]struct{})
-	for _, v := range array {
-		values[v] = struct{}{}
-	}
-	return values
+// electionRandom derives the random number of a sampling round from seed
+func electionRandom(seed []byte, round int) *big.Int {
+	roundBz := make([]byte, 8)
+	binary.BigEndian.PutUint64(roundBz, uint64(round))
+	hash := sha256.Sum256(append(append([]byte{}, seed...), roundBz...))
+	return new(big.Int).SetBytes(hash[:])
 }
 
 // CandidateInfos array for sort
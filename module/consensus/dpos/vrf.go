@@ -0,0 +1,184 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dpos
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// VRF on P-256 following the ECVRF construction of RFC 9381 with
+// try-and-increment hash to curve (ECVRF-P256-SHA256-TAI).
+const (
+	vrfSuite        = 0x01
+	vrfPointLen     = 33
+	vrfChallengeLen = 16
+	vrfScalarLen    = 32
+	// VRFProofLen is the length of a VRF proof: Gamma || c || s
+	VRFProofLen = vrfPointLen + vrfChallengeLen + vrfScalarLen
+)
+
+var (
+	vrfCurve = elliptic.P256()
+
+	errInvalidVRFKey   = errors.New("vrf key must be a P-256 key")
+	errInvalidVRFProof = errors.New("invalid vrf proof")
+)
+
+type point struct {
+	x, y *big.Int
+}
+
+// VRFProve computes the VRF output of alpha and the proof of it
+func VRFProve(sk *ecdsa.PrivateKey, alpha []byte) (beta, pi []byte, err error) {
+	if sk == nil || sk.Curve != vrfCurve {
+		return nil, nil, errInvalidVRFKey
+	}
+	pk := elliptic.MarshalCompressed(vrfCurve, sk.X, sk.Y)
+	h, err := vrfHashToCurve(pk, alpha)
+	if err != nil {
+		return nil, nil, err
+	}
+	gamma := vrfScalarMult(h, sk.D.Bytes())
+	k := vrfNonce(sk.D, h)
+	u := vrfScalarBaseMult(k.Bytes())
+	v := vrfScalarMult(h, k.Bytes())
+	c := vrfChallenge(pk, h, gamma, u, v)
+
+	// s = (k + c*x) mod q
+	n := vrfCurve.Params().N
+	s := new(big.Int).Mul(c, sk.D)
+	s.Add(s, k).Mod(s, n)
+
+	pi = make([]byte, 0, VRFProofLen)
+	pi = append(pi, vrfMarshal(gamma)...)
+	pi = append(pi, leftPad(c.Bytes(), vrfChallengeLen)...)
+	pi = append(pi, leftPad(s.Bytes(), vrfScalarLen)...)
+	return vrfProofToHash(gamma), pi, nil
+}
+
+// VRFVerify verifies the proof pi of alpha and returns the VRF output
+func VRFVerify(pk *ecdsa.PublicKey, alpha, pi []byte) (beta []byte, err error) {
+	if pk == nil || pk.Curve != vrfCurve {
+		return nil, errInvalidVRFKey
+	}
+	if len(pi) != VRFProofLen {
+		return nil, errInvalidVRFProof
+	}
+	gamma, err := vrfUnmarshal(pi[:vrfPointLen])
+	if err != nil {
+		return nil, err
+	}
+	c := new(big.Int).SetBytes(pi[vrfPointLen : vrfPointLen+vrfChallengeLen])
+	s := new(big.Int).SetBytes(pi[vrfPointLen+vrfChallengeLen:])
+	if s.Cmp(vrfCurve.Params().N) >= 0 {
+		return nil, errInvalidVRFProof
+	}
+
+	pkBytes := elliptic.MarshalCompressed(vrfCurve, pk.X, pk.Y)
+	h, err := vrfHashToCurve(pkBytes, alpha)
+	if err != nil {
+		return nil, err
+	}
+	// U = s*B - c*Y, V = s*H - c*Gamma
+	u := vrfSub(vrfScalarBaseMult(s.Bytes()), vrfScalarMult(point{pk.X, pk.Y}, c.Bytes()))
+	v := vrfSub(vrfScalarMult(h, s.Bytes()), vrfScalarMult(gamma, c.Bytes()))
+	if c.Cmp(vrfChallenge(pkBytes, h, gamma, u, v)) != 0 {
+		return nil, errInvalidVRFProof
+	}
+	return vrfProofToHash(gamma), nil
+}
+
+func vrfHashToCurve(pk, alpha []byte) (point, error) {
+	for ctr := 0; ctr < 256; ctr++ {
+		hash := sha256.New()
+		hash.Write([]byte{vrfSuite, 0x01})
+		hash.Write(pk)
+		hash.Write(alpha)
+		hash.Write([]byte{byte(ctr), 0x00})
+		if p, err := vrfUnmarshal(append([]byte{0x02}, hash.Sum(nil)...)); err == nil {
+			return p, nil
+		}
+	}
+	return point{}, fmt.Errorf("hash to curve failed")
+}
+
+// vrfNonce derives the nonce deterministically from the secret key and H
+func vrfNonce(d *big.Int, h point) *big.Int {
+	n := vrfCurve.Params().N
+	for ctr := byte(0); ; ctr++ {
+		hash := sha256.New()
+		hash.Write(leftPad(d.Bytes(), vrfScalarLen))
+		hash.Write(vrfMarshal(h))
+		hash.Write([]byte{ctr})
+		k := new(big.Int).SetBytes(hash.Sum(nil))
+		k.Mod(k, n)
+		if k.Sign() != 0 {
+			return k
+		}
+	}
+}
+
+func vrfChallenge(pk []byte, points ...point) *big.Int {
+	hash := sha256.New()
+	hash.Write([]byte{vrfSuite, 0x02})
+	hash.Write(pk)
+	for _, p := range points {
+		hash.Write(vrfMarshal(p))
+	}
+	hash.Write([]byte{0x00})
+	return new(big.Int).SetBytes(hash.Sum(nil)[:vrfChallengeLen])
+}
+
+func vrfProofToHash(gamma point) []byte {
+	hash := sha256.New()
+	hash.Write([]byte{vrfSuite, 0x03})
+	hash.Write(vrfMarshal(gamma))
+	hash.Write([]byte{0x00})
+	return hash.Sum(nil)
+}
+
+func vrfScalarMult(p point, k []byte) point {
+	x, y := vrfCurve.ScalarMult(p.x, p.y, k)
+	return point{x, y}
+}
+
+func vrfScalarBaseMult(k []byte) point {
+	x, y := vrfCurve.ScalarBaseMult(k)
+	return point{x, y}
+}
+
+func vrfSub(p1, p2 point) point {
+	negY := new(big.Int).Sub(vrfCurve.Params().P, p2.y)
+	negY.Mod(negY, vrfCurve.Params().P)
+	x, y := vrfCurve.Add(p1.x, p1.y, p2.x, negY)
+	return point{x, y}
+}
+
+func vrfMarshal(p point) []byte {
+	return elliptic.MarshalCompressed(vrfCurve, p.x, p.y)
+}
+
+func vrfUnmarshal(bz []byte) (point, error) {
+	x, y := elliptic.UnmarshalCompressed(vrfCurve, bz)
+	if x == nil {
+		return point{}, errInvalidVRFProof
+	}
+	return point{x, y}, nil
+}
+
+func leftPad(bz []byte, size int) []byte {
+	if len(bz) >= size {
+		return bz
+	}
+	return append(bytes.Repeat([]byte{0}, size-len(bz)), bz...)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dpos
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVRFProveAndVerify(t *testing.T) {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	alpha := []byte("epoch seed")
+
+	beta, pi, err := VRFProve(sk, alpha)
+	require.NoError(t, err)
+	require.Len(t, pi, VRFProofLen)
+
+	// the output is unique for the same key and input
+	beta2, pi2, err := VRFProve(sk, alpha)
+	require.NoError(t, err)
+	require.Equal(t, beta, beta2)
+	require.Equal(t, pi, pi2)
+
+	out, err := VRFVerify(&sk.PublicKey, alpha, pi)
+	require.NoError(t, err)
+	require.Equal(t, beta, out)
+
+	// wrong input
+	_, err = VRFVerify(&sk.PublicKey, []byte("other seed"), pi)
+	require.Error(t, err)
+
+	// wrong key
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = VRFVerify(&other.PublicKey, alpha, pi)
+	require.Error(t, err)
+
+	// tampered proof
+	for _, i := range []int{1, vrfPointLen, VRFProofLen - 1} {
+		tampered := append([]byte{}, pi...)
+		tampered[i] ^= 0x01
+		_, err = VRFVerify(&sk.PublicKey, alpha, tampered)
+		require.Error(t, err)
+	}
+	_, err = VRFVerify(&sk.PublicKey, alpha, pi[:VRFProofLen-1])
+	require.Error(t, err)
+}
+
+func TestVRFInvalidKey(t *testing.T) {
+	sk, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, _, err = VRFProve(sk, []byte("seed"))
+	require.Equal(t, errInvalidVRFKey, err)
+	_, err = VRFVerify(&sk.PublicKey, []byte("seed"), make([]byte, VRFProofLen))
+	require.Equal(t, errInvalidVRFKey, err)
+}
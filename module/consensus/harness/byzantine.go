@@ -0,0 +1,76 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package harness
+
+import (
+	netpb "chainmaker.org/chainmaker/pb-go/v2/net"
+)
+
+// Silence drops all the messages sent by the given nodes, it simulates crashed nodes
+func Silence(ids ...string) Interceptor {
+	silenced := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		silenced[id] = struct{}{}
+	}
+	return func(from, to string, msg *netpb.NetMsg) []*netpb.NetMsg {
+		if _, ok := silenced[from]; ok {
+			return nil
+		}
+		return []*netpb.NetMsg{msg}
+	}
+}
+
+// Byzantine applies mutate to the messages sent by node id, mutate returns
+// the messages to be sent instead of msg.
+func Byzantine(id string, mutate func(to string, msg *netpb.NetMsg) []*netpb.NetMsg) Interceptor {
+	return func(from, to string, msg *netpb.NetMsg) []*netpb.NetMsg {
+		if from != id {
+			return []*netpb.NetMsg{msg}
+		}
+		return mutate(to, msg)
+	}
+}
+
+// Equivocate makes node id send conflicting messages: the receivers in targets get the
+// payload built by conflict, the others get the original one. Feeding a vote or a proposal
+// with another block hash to conflict simulates double voting or double proposing.
+func Equivocate(id string, targets []string, conflict func(payload []byte) []byte) Interceptor {
+	targetSet := make(map[string]struct{}, len(targets))
+	for _, target := range targets {
+		targetSet[target] = struct{}{}
+	}
+	return Byzantine(id, func(to string, msg *netpb.NetMsg) []*netpb.NetMsg {
+		if _, ok := targetSet[to]; !ok {
+			return []*netpb.NetMsg{msg}
+		}
+		return []*netpb.NetMsg{{Payload: conflict(msg.Payload), Type: msg.Type, To: to}}
+	})
+}
+
+// Corrupt makes node id send messages with tampered payloads, which simulates invalid
+// proposals and votes with broken signatures.
+func Corrupt(id string) Interceptor {
+	return Byzantine(id, func(to string, msg *netpb.NetMsg) []*netpb.NetMsg {
+		payload := make([]byte, len(msg.Payload))
+		copy(payload, msg.Payload)
+		if len(payload) > 0 {
+			payload[len(payload)-1] ^= 0xff
+		}
+		return []*netpb.NetMsg{{Payload: payload, Type: msg.Type, To: to}}
+	})
+}
+
+// Duplicate delivers every message of node id n times
+func Duplicate(id string, n int) Interceptor {
+	return Byzantine(id, func(to string, msg *netpb.NetMsg) []*netpb.NetMsg {
+		msgs := make([]*netpb.NetMsg, 0, n)
+		for i := 0; i < n; i++ {
+			msgs = append(msgs, msg)
+		}
+		return msgs
+	})
+}
@@ -0,0 +1,346 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package harness
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	commonErrors "chainmaker.org/chainmaker/common/v2/errors"
+	"chainmaker.org/chainmaker/common/v2/msgbus"
+	commonpb "chainmaker.org/chainmaker/pb-go/v2/common"
+	consensuspb "chainmaker.org/chainmaker/pb-go/v2/consensus"
+	netpb "chainmaker.org/chainmaker/pb-go/v2/net"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+// EngineFactory creates the consensus engine of a node, the node provides the
+// msgbus, net service, ledger cache and block committer to wire it up.
+type EngineFactory func(node *Node) (protocol.ConsensusEngine, error)
+
+// Node is a consensus instance of the cluster
+type Node struct {
+	msgbus.DefaultSubscriber
+
+	Id         string
+	ChainId    string
+	MsgBus     msgbus.MessageBus
+	NetService *NetService
+	Engine     protocol.ConsensusEngine
+
+	net       *Network
+	mtx       sync.RWMutex
+	committed []*commonpb.Block
+	commitC   chan uint64
+	proposing bool
+	proposed  uint64
+}
+
+// Cluster runs several consensus instances over an in-memory network
+type Cluster struct {
+	ChainId string
+	Net     *Network
+	Nodes   []*Node
+
+	externalNet bool
+}
+
+// ClusterOption configures the cluster
+type ClusterOption func(c *Cluster)
+
+// WithExternalNet leaves the consensus msgs sent on the msgbus of the nodes to the net services
+// bound to the msgbus by the caller, such as those on a protocol.Net, instead of the in-memory network
+func WithExternalNet() ClusterOption {
+	return func(c *Cluster) {
+		c.externalNet = true
+	}
+}
+
+// NewCluster creates a cluster of the given nodes, the genesis block is
+// committed on every node. It does not start the engines.
+func NewCluster(chainId string, ids []string, genesis *commonpb.Block,
+	seed int64, factory EngineFactory, opts ...ClusterOption) (*Cluster, error) {
+	cluster := &Cluster{
+		ChainId: chainId,
+		Net:     NewNetwork(seed),
+	}
+	for _, opt := range opts {
+		opt(cluster)
+	}
+	peers := func() []string { return ids }
+	for _, id := range ids {
+		node := &Node{
+			Id:        id,
+			ChainId:   chainId,
+			MsgBus:    msgbus.NewMessageBus(),
+			net:       cluster.Net,
+			committed: []*commonpb.Block{genesis},
+			commitC:   make(chan uint64, 1024),
+		}
+		node.NetService = NewNetService(chainId, id, cluster.Net, peers)
+		cluster.Net.Join(id, node.receive)
+		if !cluster.externalNet {
+			node.MsgBus.Register(msgbus.SendConsensusMsg, node)
+		}
+		node.MsgBus.Register(msgbus.CommitBlock, node)
+		node.MsgBus.Register(msgbus.ProposeState, node)
+		node.MsgBus.Register(msgbus.VerifyBlock, node)
+
+		engine, err := factory(node)
+		if err != nil {
+			return nil, fmt.Errorf("create engine of %s failed, %s", id, err.Error())
+		}
+		node.Engine = engine
+		cluster.Nodes = append(cluster.Nodes, node)
+	}
+	return cluster, nil
+}
+
+// NewObserver creates a node following the chain without an engine, such as a sync node, the genesis
+// block is committed on it. The blocks are committed by the modules given its ledger services.
+func NewObserver(chainId, id string, genesis *commonpb.Block) *Node {
+	return &Node{
+		Id:        id,
+		ChainId:   chainId,
+		MsgBus:    msgbus.NewMessageBus(),
+		committed: []*commonpb.Block{genesis},
+		commitC:   make(chan uint64, 1024),
+	}
+}
+
+// Start starts all the engines
+func (c *Cluster) Start() error {
+	for _, node := range c.Nodes {
+		if err := node.Engine.Start(); err != nil {
+			return fmt.Errorf("start engine of %s failed, %s", node.Id, err.Error())
+		}
+	}
+	return nil
+}
+
+// Stop stops all the engines and the network
+func (c *Cluster) Stop() {
+	for _, node := range c.Nodes {
+		_ = node.Engine.Stop()
+		c.Net.Leave(node.Id)
+	}
+	c.Net.Close()
+}
+
+// Node returns the node of id
+func (c *Cluster) Node(id string) *Node {
+	for _, node := range c.Nodes {
+		if node.Id == id {
+			return node
+		}
+	}
+	return nil
+}
+
+// CheckSafety checks that no two nodes committed different blocks at the same height
+// and that the blocks of every node are chained.
+func (c *Cluster) CheckSafety() error {
+	committed := make(map[uint64]*commonpb.Block)
+	for _, node := range c.Nodes {
+		blocks := node.CommittedBlocks()
+		for i, block := range blocks {
+			if i > 0 && !bytes.Equal(block.Header.PreBlockHash, blocks[i-1].Header.BlockHash) {
+				return fmt.Errorf("node %s: block %d does not follow block %d",
+					node.Id, block.Header.BlockHeight, blocks[i-1].Header.BlockHeight)
+			}
+			height := block.Header.BlockHeight
+			if other, ok := committed[height]; ok && !bytes.Equal(other.Header.BlockHash, block.Header.BlockHash) {
+				return fmt.Errorf("node %s: conflicting block %x committed at height %d, others committed %x",
+					node.Id, block.Header.BlockHash, height, other.Header.BlockHash)
+			}
+			committed[height] = block
+		}
+	}
+	return nil
+}
+
+// WaitForHeight checks liveness, it waits until the given nodes, or all the nodes
+// when none is given, committed height or returns an error after timeout.
+func (c *Cluster) WaitForHeight(height uint64, timeout time.Duration, ids ...string) error {
+	if len(ids) == 0 {
+		for _, node := range c.Nodes {
+			ids = append(ids, node.Id)
+		}
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		var lagging []string
+		for _, id := range ids {
+			if current, _ := c.Node(id).CurrentHeight(); current < height {
+				lagging = append(lagging, fmt.Sprintf("%s(%d)", id, current))
+			}
+		}
+		if len(lagging) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			sort.Strings(lagging)
+			return fmt.Errorf("nodes %v did not reach height %d in %v", lagging, height, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// OnMessage sends the consensus msg to the network, records the committed blocks, proposes
+// the next block when the engine makes the node the proposer and verifies the blocks proposed
+// by the others
+func (n *Node) OnMessage(message *msgbus.Message) {
+	switch message.Topic {
+	case msgbus.SendConsensusMsg:
+		if msg, ok := message.Payload.(*netpb.NetMsg); ok {
+			n.net.Send(n.Id, msg)
+		}
+	case msgbus.CommitBlock:
+		if block, ok := message.Payload.(*commonpb.Block); ok {
+			_ = n.AddBlock(block)
+		}
+	case msgbus.ProposeState:
+		if isProposer, ok := message.Payload.(bool); ok {
+			n.propose(isProposer)
+		}
+	case msgbus.VerifyBlock:
+		if block, ok := message.Payload.(*commonpb.Block); ok {
+			n.verify(block)
+		}
+	}
+}
+
+// verify stands in for the core engine, it verifies the block of the proposal received and
+// publishes the result to the engine
+func (n *Node) verify(block *commonpb.Block) {
+	result := &consensuspb.VerifyResult{VerifiedBlock: block, Code: consensuspb.VerifyResult_SUCCESS}
+	if err := n.VerifyBlock(block); err != nil {
+		result.Code = consensuspb.VerifyResult_FAIL
+	}
+	n.MsgBus.Publish(msgbus.VerifyResult, result)
+}
+
+// propose stands in for the core engine, it proposes an empty block on top of the last
+// committed one once per height while the node is the proposer. The engine signs it.
+func (n *Node) propose(isProposer bool) {
+	n.mtx.Lock()
+	if !isProposer {
+		n.proposing = false
+		n.mtx.Unlock()
+		return
+	}
+	last := n.committed[len(n.committed)-1]
+	height := last.Header.BlockHeight + 1
+	if n.proposing && n.proposed >= height {
+		n.mtx.Unlock()
+		return
+	}
+	n.proposing, n.proposed = true, height
+	n.mtx.Unlock()
+
+	block := &commonpb.Block{
+		Header: &commonpb.BlockHeader{
+			ChainId:        n.ChainId,
+			BlockHeight:    height,
+			PreBlockHash:   last.Header.BlockHash,
+			BlockTimestamp: time.Now().Unix(),
+		},
+		Dag: &commonpb.DAG{},
+	}
+	n.MsgBus.Publish(msgbus.ProposedBlock, &consensuspb.ProposalBlock{Block: block})
+}
+
+// VerifyBlock checks that the block follows the last committed block, it is the block
+// verifier given to the engines.
+func (n *Node) VerifyBlock(block *commonpb.Block) error {
+	last := n.GetLastCommittedBlock()
+	if block.Header.BlockHeight <= last.Header.BlockHeight {
+		return commonErrors.ErrBlockHadBeenCommited
+	}
+	if block.Header.BlockHeight != last.Header.BlockHeight+1 ||
+		!bytes.Equal(block.Header.PreBlockHash, last.Header.BlockHash) {
+		return fmt.Errorf("node %s: block %d does not follow block %d",
+			n.Id, block.Header.BlockHeight, last.Header.BlockHeight)
+	}
+	return nil
+}
+
+// receive handles the msg delivered by the network, the msg sent on the msgbus is published
+// to the msgbus and the others are handed to the net service, so that each is handled once.
+// As the net module does, the msg published carries the sender in the To field.
+func (n *Node) receive(from string, route Route, msg *netpb.NetMsg) {
+	if route != RouteMsgBus {
+		n.NetService.handle(from, route, msg)
+		return
+	}
+	if msg.Type == netpb.NetMsg_CONSENSUS_MSG {
+		n.MsgBus.Publish(msgbus.RecvConsensusMsg, &netpb.NetMsg{Payload: msg.Payload, Type: msg.Type, To: from})
+	}
+}
+
+// AddBlock implements protocol.BlockCommitter
+func (n *Node) AddBlock(block *commonpb.Block) error {
+	n.mtx.Lock()
+	last := n.committed[len(n.committed)-1]
+	if block.Header.BlockHeight <= last.Header.BlockHeight {
+		n.mtx.Unlock()
+		return nil
+	}
+	if block.Header.BlockHeight != last.Header.BlockHeight+1 {
+		n.mtx.Unlock()
+		return fmt.Errorf("node %s: commit block %d after block %d",
+			n.Id, block.Header.BlockHeight, last.Header.BlockHeight)
+	}
+	n.committed = append(n.committed, block)
+	n.mtx.Unlock()
+
+	n.MsgBus.Publish(msgbus.BlockInfo, &commonpb.BlockInfo{Block: block})
+	select {
+	case n.commitC <- block.Header.BlockHeight:
+	default:
+	}
+	return nil
+}
+
+// peers returns the ids of the nodes of the cluster
+func (n *Node) peers() []string {
+	return n.NetService.peers()
+}
+
+// CommitC notifies the heights committed by the node
+func (n *Node) CommitC() <-chan uint64 {
+	return n.commitC
+}
+
+// CommittedBlocks returns the blocks committed by the node, genesis included
+func (n *Node) CommittedBlocks() []*commonpb.Block {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+	blocks := make([]*commonpb.Block, len(n.committed))
+	copy(blocks, n.committed)
+	return blocks
+}
+
+// GetLastCommittedBlock implements protocol.LedgerCache
+func (n *Node) GetLastCommittedBlock() *commonpb.Block {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+	return n.committed[len(n.committed)-1]
+}
+
+// SetLastCommittedBlock implements protocol.LedgerCache
+func (n *Node) SetLastCommittedBlock(block *commonpb.Block) {
+	_ = n.AddBlock(block)
+}
+
+// CurrentHeight implements protocol.LedgerCache
+func (n *Node) CurrentHeight() (uint64, error) {
+	return n.GetLastCommittedBlock().Header.BlockHeight, nil
+}
@@ -0,0 +1,221 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package harness
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker-go/consensus/raft"
+	"chainmaker.org/chainmaker-go/consensus/tbft"
+	"chainmaker.org/chainmaker/localconf/v2"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	commonpb "chainmaker.org/chainmaker/pb-go/v2/common"
+	configpb "chainmaker.org/chainmaker/pb-go/v2/config"
+	consensuspb "chainmaker.org/chainmaker/pb-go/v2/consensus"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"github.com/golang/mock/gomock"
+)
+
+// harnessOrgId is the org of all the nodes of the cluster
+const harnessOrgId = "harness-org"
+
+// ChainConfig returns the chain config of the cluster, all the nodes are consensus nodes of one org
+func ChainConfig(chainId string, consensusType consensuspb.ConsensusType, ids []string) *configpb.ChainConfig {
+	return &configpb.ChainConfig{
+		ChainId: chainId,
+		Crypto:  &configpb.CryptoConfig{Hash: "SHA256"},
+		Block:   &configpb.BlockConfig{BlockSize: 10},
+		Consensus: &configpb.ConsensusConfig{
+			Type:  consensusType,
+			Nodes: []*configpb.OrgConfig{{OrgId: harnessOrgId, NodeId: ids}},
+		},
+	}
+}
+
+// SkipRaftIfShort skips the test running a raft cluster in the short mode,
+// as raft elects a leader after 10 ticks of one second.
+func SkipRaftIfShort(t testing.TB) {
+	if testing.Short() {
+		t.Skip("raft elects a leader after 10 ticks of one second")
+	}
+}
+
+// RaftFactory creates the raft engines of the cluster, the wal and the snapshots of each node
+// are kept under storePath/<node id>. The signers, the chain config, the block verifier and
+// the block committer are mocks backed by the node.
+//
+// Raft instances are registered globally by node id and keep running after Stop,
+// so every cluster of a test process must use distinct node ids.
+func RaftFactory(ctrl *gomock.Controller, storePath string) EngineFactory {
+	return func(node *Node) (protocol.ConsensusEngine, error) {
+		nodePath := filepath.Join(storePath, node.Id)
+		if err := os.MkdirAll(filepath.Join(nodePath, node.ChainId), 0750); err != nil {
+			return nil, err
+		}
+		// raft reads the store path when created
+		restore := useStorePath(nodePath)
+		defer restore()
+
+		return raft.New(raft.ConsensusRaftImplConfig{
+			ChainID:        node.ChainId,
+			NodeId:         node.Id,
+			Singer:         newMockSigner(ctrl, node),
+			LedgerCache:    newMockLedgerCache(ctrl, node),
+			BlockVerifier:  newMockBlockVerifier(ctrl, node),
+			BlockCommitter: newMockBlockCommitter(ctrl, node),
+			ChainConf:      newMockChainConf(ctrl, ChainConfig(node.ChainId, consensuspb.ConsensusType_RAFT, node.peers())),
+			MsgBus:         node.MsgBus,
+		})
+	}
+}
+
+// TBFTFactory creates the tbft engines of the cluster, the wal of each node is kept under storePath/<node id>.
+// The proposals and the votes are signed by Sign and verified by the access control of the harness, so the
+// tampered msgs are rejected while a byzantine node could still sign conflicting ones. The node proposes
+// the blocks and verifies the ones of the others on the msgbus as the core engine does.
+func TBFTFactory(ctrl *gomock.Controller, storePath string, proposeTimeout time.Duration) EngineFactory {
+	return func(node *Node) (protocol.ConsensusEngine, error) {
+		nodePath := filepath.Join(storePath, node.Id)
+		if err := os.MkdirAll(filepath.Join(nodePath, node.ChainId), 0750); err != nil {
+			return nil, err
+		}
+		// tbft opens the wal when created
+		restore := useStorePath(nodePath)
+		defer restore()
+
+		chainConfig := ChainConfig(node.ChainId, consensuspb.ConsensusType_TBFT, node.peers())
+		chainConfig.Consensus.ExtConfig = []*commonpb.KeyValuePair{
+			{Key: protocol.TBFT_propose_timeout_key, Value: []byte(proposeTimeout.String())},
+			{Key: protocol.TBFT_propose_delta_timeout_key, Value: []byte((proposeTimeout / 5).String())},
+		}
+		return tbft.New(tbft.ConsensusTBFTImplConfig{
+			ChainID:     node.ChainId,
+			Id:          node.Id,
+			Signer:      newMockSigner(ctrl, node),
+			Ac:          newMockAccessControl(ctrl, node.peers()),
+			LedgerCache: newMockLedgerCache(ctrl, node),
+			ChainConf:   newMockChainConf(ctrl, chainConfig),
+			NetService:  node.NetService,
+			MsgBus:      node.MsgBus,
+		})
+	}
+}
+
+// useStorePath sets the store path of the node config, the engines read it when created,
+// the returned func restores the previous one
+func useStorePath(path string) func() {
+	if localconf.ChainMakerConfig.StorageConfig == nil {
+		localconf.ChainMakerConfig.StorageConfig = make(map[string]interface{})
+	}
+	prePath, hasPath := localconf.ChainMakerConfig.StorageConfig["store_path"]
+	localconf.ChainMakerConfig.StorageConfig["store_path"] = path
+	return func() {
+		if hasPath {
+			localconf.ChainMakerConfig.StorageConfig["store_path"] = prePath
+		} else {
+			delete(localconf.ChainMakerConfig.StorageConfig, "store_path")
+		}
+	}
+}
+
+// Sign returns the signature of node id on msg in the harness
+func Sign(id string, msg []byte) []byte {
+	digest := sha256.Sum256(append([]byte(id+"/"), msg...))
+	return digest[:]
+}
+
+// LedgerServices returns the ledger cache, the block verifier and the block committer backed by the node,
+// they are given to the modules running beside the engine, such as the sync service
+func (n *Node) LedgerServices(ctrl *gomock.Controller) (protocol.LedgerCache, protocol.BlockVerifier,
+	protocol.BlockCommitter) {
+	return newMockLedgerCache(ctrl, n), newMockBlockVerifier(ctrl, n), newMockBlockCommitter(ctrl, n)
+}
+
+func newMockSigner(ctrl *gomock.Controller, node *Node) protocol.SigningMember {
+	signer := mock.NewMockSigningMember(ctrl)
+	signer.EXPECT().Sign(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(hashType string, msg []byte) ([]byte, error) {
+			return Sign(node.Id, msg), nil
+		})
+	signer.EXPECT().GetMember().AnyTimes().Return(&pbac.Member{
+		OrgId:      harnessOrgId,
+		MemberType: pbac.MemberType_CERT_HASH,
+		MemberInfo: []byte(node.Id),
+	}, nil)
+	return signer
+}
+
+// newMockAccessControl verifies the signatures of the consensus nodes ids made by Sign, the principal is
+// checked when created so that the verification fails with the error of CreatePrincipal
+func newMockAccessControl(ctrl *gomock.Controller, ids []string) protocol.AccessControlProvider {
+	members := make(map[string]protocol.Member, len(ids))
+	for _, id := range ids {
+		member := mock.NewMockMember(ctrl)
+		member.EXPECT().GetMemberId().AnyTimes().Return(id)
+		members[id] = member
+	}
+	ac := mock.NewMockAccessControlProvider(ctrl)
+	ac.EXPECT().CreatePrincipal(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(resourceName string, endorsements []*commonpb.EndorsementEntry, msg []byte) (protocol.Principal, error) {
+			for _, endorsement := range endorsements {
+				if endorsement == nil || endorsement.Signer == nil {
+					return nil, fmt.Errorf("endorsement without signer")
+				}
+				id := string(endorsement.Signer.MemberInfo)
+				if _, ok := members[id]; !ok {
+					return nil, fmt.Errorf("%s is not a consensus node", id)
+				}
+				if !bytes.Equal(endorsement.Signature, Sign(id, msg)) {
+					return nil, fmt.Errorf("invalid signature of %s", id)
+				}
+			}
+			return nil, nil
+		})
+	ac.EXPECT().VerifyPrincipal(gomock.Any()).AnyTimes().Return(true, nil)
+	ac.EXPECT().NewMember(gomock.Any()).AnyTimes().DoAndReturn(func(member *pbac.Member) (protocol.Member, error) {
+		if m, ok := members[string(member.MemberInfo)]; ok {
+			return m, nil
+		}
+		return nil, fmt.Errorf("%s is not a consensus node", member.MemberInfo)
+	})
+	return ac
+}
+
+func newMockLedgerCache(ctrl *gomock.Controller, node *Node) protocol.LedgerCache {
+	ledgerCache := mock.NewMockLedgerCache(ctrl)
+	ledgerCache.EXPECT().CurrentHeight().AnyTimes().DoAndReturn(node.CurrentHeight)
+	ledgerCache.EXPECT().GetLastCommittedBlock().AnyTimes().DoAndReturn(node.GetLastCommittedBlock)
+	return ledgerCache
+}
+
+func newMockBlockVerifier(ctrl *gomock.Controller, node *Node) protocol.BlockVerifier {
+	verifier := mock.NewMockBlockVerifier(ctrl)
+	verifier.EXPECT().VerifyBlock(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(block *commonpb.Block, mode protocol.VerifyMode) error {
+			return node.VerifyBlock(block)
+		})
+	return verifier
+}
+
+func newMockBlockCommitter(ctrl *gomock.Controller, node *Node) protocol.BlockCommitter {
+	committer := mock.NewMockBlockCommitter(ctrl)
+	committer.EXPECT().AddBlock(gomock.Any()).AnyTimes().DoAndReturn(node.AddBlock)
+	return committer
+}
+
+func newMockChainConf(ctrl *gomock.Controller, chainConfig *configpb.ChainConfig) protocol.ChainConf {
+	chainConf := mock.NewMockChainConf(ctrl)
+	chainConf.EXPECT().ChainConfig().AnyTimes().Return(chainConfig)
+	return chainConf
+}
@@ -0,0 +1,357 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package harness
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker/common/v2/msgbus"
+	"chainmaker.org/chainmaker/localconf/v2"
+	commonpb "chainmaker.org/chainmaker/pb-go/v2/common"
+	tbftpb "chainmaker.org/chainmaker/pb-go/v2/consensus/tbft"
+	netpb "chainmaker.org/chainmaker/pb-go/v2/net"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mtx  sync.Mutex
+	msgs []*netpb.NetMsg
+}
+
+func (r *recorder) receive(from string, route Route, msg *netpb.NetMsg) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.msgs = append(r.msgs, msg)
+}
+
+func (r *recorder) count() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return len(r.msgs)
+}
+
+func newTestNetwork() (*Network, map[string]*recorder) {
+	net := NewNetwork(1)
+	recorders := make(map[string]*recorder)
+	for _, id := range []string{"node0", "node1", "node2"} {
+		recorders[id] = &recorder{}
+		net.Join(id, recorders[id].receive)
+	}
+	return net, recorders
+}
+
+func TestNetwork_Partition(t *testing.T) {
+	net, recorders := newTestNetwork()
+	net.Partition([]string{"node0"}, []string{"node1", "node2"})
+	net.Send("node0", &netpb.NetMsg{To: "node1"})
+	net.Send("node1", &netpb.NetMsg{To: "node2"})
+	net.Heal()
+	net.Send("node0", &netpb.NetMsg{To: "node2"})
+	net.Close()
+
+	require.Equal(t, 0, recorders["node1"].count())
+	require.Equal(t, 2, recorders["node2"].count())
+	sent, delivered, dropped := net.Stats()
+	require.Equal(t, uint64(3), sent)
+	require.Equal(t, uint64(2), delivered)
+	require.Equal(t, uint64(1), dropped)
+}
+
+func TestNetwork_Faults(t *testing.T) {
+	net, recorders := newTestNetwork()
+	net.SetFaults(Faults{DropRate: 1})
+	net.Send("node0", &netpb.NetMsg{To: "node1"})
+	net.SetFaults(Faults{MinDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+	net.Send("node0", &netpb.NetMsg{To: "node1"})
+	net.Close()
+	require.Equal(t, 1, recorders["node1"].count())
+}
+
+func TestNetwork_Interceptors(t *testing.T) {
+	net, recorders := newTestNetwork()
+	net.AddInterceptor(Duplicate("node0", 2))
+	net.AddInterceptor(Silence("node2"))
+	net.AddInterceptor(Equivocate("node1", []string{"node2"}, func(payload []byte) []byte {
+		return []byte("conflict")
+	}))
+	net.Send("node0", &netpb.NetMsg{To: "node1", Payload: []byte("vote")})
+	net.Send("node2", &netpb.NetMsg{To: "node1", Payload: []byte("vote")})
+	net.Send("node1", &netpb.NetMsg{To: "node0", Payload: []byte("vote")})
+	net.Send("node1", &netpb.NetMsg{To: "node2", Payload: []byte("vote")})
+	net.Close()
+
+	require.Equal(t, 2, recorders["node1"].count())
+	require.Equal(t, "vote", string(recorders["node0"].msgs[0].Payload))
+	require.Equal(t, "conflict", string(recorders["node2"].msgs[0].Payload))
+}
+
+func newTestBlock(height uint64, hash, preHash string) *commonpb.Block {
+	return &commonpb.Block{Header: &commonpb.BlockHeader{
+		BlockHeight:  height,
+		BlockHash:    []byte(hash),
+		PreBlockHash: []byte(preHash),
+	}}
+}
+
+func mockEngineFactory(ctrl *gomock.Controller) EngineFactory {
+	return func(node *Node) (protocol.ConsensusEngine, error) {
+		engine := mock.NewMockConsensusEngine(ctrl)
+		engine.EXPECT().Start().AnyTimes().Return(nil)
+		engine.EXPECT().Stop().AnyTimes().Return(nil)
+		return engine, nil
+	}
+}
+
+func TestCluster_CheckSafety(t *testing.T) {
+	genesis := newTestBlock(0, "h0", "")
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cluster, err := NewCluster("chain1", []string{"node0", "node1"}, genesis, 1, mockEngineFactory(ctrl))
+	require.Nil(t, err)
+	require.Nil(t, cluster.Start())
+	defer cluster.Stop()
+
+	require.Nil(t, cluster.Node("node0").AddBlock(newTestBlock(1, "h1", "h0")))
+	require.Nil(t, cluster.Node("node1").AddBlock(newTestBlock(1, "h1", "h0")))
+	require.Nil(t, cluster.CheckSafety())
+	require.Nil(t, cluster.WaitForHeight(1, time.Second))
+	require.NotNil(t, cluster.WaitForHeight(2, 50*time.Millisecond))
+
+	require.NotNil(t, cluster.Node("node0").AddBlock(newTestBlock(3, "h3", "h2")))
+	require.Nil(t, cluster.Node("node0").AddBlock(newTestBlock(2, "h2", "h1")))
+	require.Nil(t, cluster.Node("node1").AddBlock(newTestBlock(2, "h2'", "h1")))
+	require.NotNil(t, cluster.CheckSafety())
+}
+
+func TestNode_ReceiveOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	genesis := newTestBlock(0, "h0", "")
+	cluster, err := NewCluster("chain1", []string{"node0", "node1"}, genesis, 1, mockEngineFactory(ctrl))
+	require.Nil(t, err)
+
+	var mtx sync.Mutex
+	received := make(map[string]int)
+	record := func(route string) protocol.MsgHandler {
+		return func(from string, msg []byte, msgType netpb.NetMsg_MsgType) error {
+			mtx.Lock()
+			defer mtx.Unlock()
+			received[route]++
+			return nil
+		}
+	}
+	node1 := cluster.Node("node1")
+	require.Nil(t, node1.NetService.Subscribe(netpb.NetMsg_CONSENSUS_MSG, record("broadcast")))
+	require.Nil(t, node1.NetService.ConsensusSubscribe(netpb.NetMsg_CONSENSUS_MSG, record("consensus")))
+	require.Nil(t, node1.NetService.ReceiveMsg(netpb.NetMsg_CONSENSUS_MSG, record("direct")))
+	subscriber := &msgRecorder{}
+	node1.MsgBus.Register(msgbus.RecvConsensusMsg, subscriber)
+
+	node0 := cluster.Node("node0")
+	require.Nil(t, node0.NetService.BroadcastMsg([]byte("m"), netpb.NetMsg_CONSENSUS_MSG))
+	require.Nil(t, node0.NetService.ConsensusBroadcastMsg([]byte("m"), netpb.NetMsg_CONSENSUS_MSG))
+	require.Nil(t, node0.NetService.SendMsg([]byte("m"), netpb.NetMsg_CONSENSUS_MSG, "node1"))
+	node0.OnMessage(&msgbus.Message{Topic: msgbus.SendConsensusMsg,
+		Payload: &netpb.NetMsg{Payload: []byte("m"), Type: netpb.NetMsg_CONSENSUS_MSG, To: "node1"}})
+	cluster.Net.Close()
+
+	require.Eventually(t, func() bool { return subscriber.count() == 1 }, time.Second, 10*time.Millisecond)
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Equal(t, map[string]int{"broadcast": 1, "consensus": 1, "direct": 1}, received)
+}
+
+type msgRecorder struct {
+	msgbus.DefaultSubscriber
+	mtx  sync.Mutex
+	msgs int
+}
+
+func (r *msgRecorder) OnMessage(message *msgbus.Message) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.msgs++
+}
+
+func (r *msgRecorder) count() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.msgs
+}
+
+func TestCluster_Raft(t *testing.T) {
+	SkipRaftIfShort(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ids := []string{"raft-node0", "raft-node1", "raft-node2"}
+	genesis := newTestBlock(0, "h0", "")
+	cluster, err := NewCluster("chain1", ids, genesis, 1, RaftFactory(ctrl, t.TempDir()))
+	require.Nil(t, err)
+	cluster.Net.SetFaults(Faults{MaxDelay: 5 * time.Millisecond, ReorderRate: 0.1, ReorderDelay: 10 * time.Millisecond})
+	require.Nil(t, cluster.Start())
+	defer cluster.Stop()
+
+	require.Nil(t, cluster.WaitForHeight(3, time.Minute))
+	require.Nil(t, cluster.CheckSafety())
+}
+
+// proposers returns the nodes made the proposer by their engines
+func proposers(c *Cluster) []*Node {
+	var nodes []*Node
+	for _, node := range c.Nodes {
+		node.mtx.RLock()
+		if node.proposing {
+			nodes = append(nodes, node)
+		}
+		node.mtx.RUnlock()
+	}
+	return nodes
+}
+
+func TestCluster_RaftDrain(t *testing.T) {
+	SkipRaftIfShort(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	// the drain waits for the ack of the wal saved asynchronously
+	raftConfig := localconf.ChainMakerConfig.ConsensusConfig.RaftConfig
+	defer func() { localconf.ChainMakerConfig.ConsensusConfig.RaftConfig = raftConfig }()
+	localconf.ChainMakerConfig.ConsensusConfig.RaftConfig.AsyncWalSave = true
+
+	ids := []string{"raft-drain-node0", "raft-drain-node1", "raft-drain-node2"}
+	cluster, err := NewCluster("chain1", ids, newTestBlock(0, "h0", ""), 1, RaftFactory(ctrl, t.TempDir()))
+	require.Nil(t, err)
+	require.Nil(t, cluster.Start())
+	defer cluster.Stop()
+	require.Nil(t, cluster.WaitForHeight(2, time.Minute))
+
+	var leader *Node
+	require.Eventually(t, func() bool {
+		if nodes := proposers(cluster); len(nodes) == 1 {
+			leader = nodes[0]
+		}
+		return leader != nil
+	}, 10*time.Second, 10*time.Millisecond)
+	drainer, ok := leader.Engine.(interface {
+		Drain(ctx context.Context) error
+	})
+	require.True(t, ok)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.Nil(t, drainer.Drain(ctx))
+
+	// a follower takes over the leadership and keeps the chain growing
+	require.Eventually(t, func() bool {
+		nodes := proposers(cluster)
+		return len(nodes) == 1 && nodes[0] != leader
+	}, 30*time.Second, 10*time.Millisecond)
+	height, _ := leader.CurrentHeight()
+	require.Nil(t, cluster.WaitForHeight(height+2, time.Minute))
+	require.Nil(t, cluster.CheckSafety())
+}
+
+// conflictHash returns the hash of the block conflicting with the block of hash
+func conflictHash(hash []byte) []byte {
+	digest := sha256.Sum256(append([]byte("conflict/"), hash...))
+	return digest[:]
+}
+
+// tbftConflict switches the proposal and the votes of a tbft msg to the conflicting block and signs
+// them again by the voter, so the byzantine node double proposes and double votes with valid signatures.
+// conflicts counts the msgs switched.
+func tbftConflict(conflicts *int64) func(payload []byte) []byte {
+	return func(payload []byte) []byte {
+		msg := new(tbftpb.TBFTMsg)
+		if err := proto.Unmarshal(payload, msg); err != nil {
+			return payload
+		}
+		var err error
+		switch msg.Type {
+		case tbftpb.TBFTMsgType_MSG_PROPOSE:
+			proposal := new(tbftpb.Proposal)
+			if err = proto.Unmarshal(msg.Msg, proposal); err != nil || proposal.Endorsement == nil {
+				return payload
+			}
+			proposal.Block.Header.BlockHash = conflictHash(proposal.Block.Header.BlockHash)
+			endorsement, additionalData := proposal.Endorsement, proposal.Block.AdditionalData
+			proposal.Endorsement, proposal.Block.AdditionalData = nil, nil
+			signed, _ := proto.Marshal(proposal)
+			proposal.Endorsement = &commonpb.EndorsementEntry{Signer: endorsement.Signer,
+				Signature: Sign(proposal.Voter, signed)}
+			proposal.Block.AdditionalData = additionalData
+			msg.Msg, err = proto.Marshal(proposal)
+		case tbftpb.TBFTMsgType_MSG_PREVOTE, tbftpb.TBFTMsgType_MSG_PRECOMMIT:
+			vote := new(tbftpb.Vote)
+			if err = proto.Unmarshal(msg.Msg, vote); err != nil || vote.Endorsement == nil {
+				return payload
+			}
+			vote.Hash = conflictHash(vote.Hash)
+			endorsement := vote.Endorsement
+			vote.Endorsement = nil
+			signed, _ := proto.Marshal(vote)
+			vote.Endorsement = &commonpb.EndorsementEntry{Signer: endorsement.Signer,
+				Signature: Sign(vote.Voter, signed)}
+			msg.Msg, err = proto.Marshal(vote)
+		default:
+			return payload
+		}
+		conflicting, marshalErr := proto.Marshal(msg)
+		if err != nil || marshalErr != nil {
+			return payload
+		}
+		atomic.AddInt64(conflicts, 1)
+		return conflicting
+	}
+}
+
+// the byzantine node double proposes and double votes to a victim, the others keep committing
+// the same blocks and the victim never commits the conflicting ones
+func TestCluster_TBFTEquivocation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ids := []string{"tbft-eq-node0", "tbft-eq-node1", "tbft-eq-node2", "tbft-eq-node3"}
+	byzantine, victim := ids[0], ids[3]
+	cluster, err := NewCluster("chain1", ids, newTestBlock(0, "h0", ""), 1,
+		TBFTFactory(ctrl, t.TempDir(), time.Second))
+	require.Nil(t, err)
+	var conflicts int64
+	cluster.Net.AddInterceptor(Equivocate(byzantine, []string{victim}, tbftConflict(&conflicts)))
+	require.Nil(t, cluster.Start())
+	defer cluster.Stop()
+
+	require.Nil(t, cluster.WaitForHeight(3, time.Minute, ids[1], ids[2]))
+	require.NotZero(t, atomic.LoadInt64(&conflicts))
+	require.Nil(t, cluster.CheckSafety())
+}
+
+// the votes and the proposals of the corrupted node fail the verification, it is
+// taken as a crashed node and the others keep committing the same blocks
+func TestCluster_TBFTCorrupt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ids := []string{"tbft-corrupt-node0", "tbft-corrupt-node1", "tbft-corrupt-node2", "tbft-corrupt-node3"}
+	cluster, err := NewCluster("chain1", ids, newTestBlock(0, "h0", ""), 1,
+		TBFTFactory(ctrl, t.TempDir(), time.Second))
+	require.Nil(t, err)
+	cluster.Net.AddInterceptor(Corrupt(ids[3]))
+	require.Nil(t, cluster.Start())
+	defer cluster.Stop()
+
+	require.Nil(t, cluster.WaitForHeight(3, time.Minute, ids[:3]...))
+	require.Nil(t, cluster.CheckSafety())
+}
@@ -0,0 +1,164 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package harness
+
+import (
+	"fmt"
+	"sync"
+
+	netpb "chainmaker.org/chainmaker/pb-go/v2/net"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+var _ protocol.NetService = (*NetService)(nil)
+
+// NetService implements protocol.NetService on the in-memory network
+type NetService struct {
+	chainId string
+	id      string
+	net     *Network
+	peers   func() []string
+
+	mtx               sync.RWMutex
+	subscribers       map[netpb.NetMsg_MsgType]protocol.MsgHandler
+	consensusHandlers map[netpb.NetMsg_MsgType]protocol.MsgHandler
+	directHandlers    map[netpb.NetMsg_MsgType]protocol.MsgHandler
+}
+
+// NewNetService creates the net service of node id, peers lists the nodes of the chain
+func NewNetService(chainId, id string, net *Network, peers func() []string) *NetService {
+	return &NetService{
+		chainId:           chainId,
+		id:                id,
+		net:               net,
+		peers:             peers,
+		subscribers:       make(map[netpb.NetMsg_MsgType]protocol.MsgHandler),
+		consensusHandlers: make(map[netpb.NetMsg_MsgType]protocol.MsgHandler),
+		directHandlers:    make(map[netpb.NetMsg_MsgType]protocol.MsgHandler),
+	}
+}
+
+// ChainId returns the chain id
+func (ns *NetService) ChainId() string {
+	return ns.chainId
+}
+
+// BroadcastMsg sends msg to all the other nodes
+func (ns *NetService) BroadcastMsg(msg []byte, msgType netpb.NetMsg_MsgType) error {
+	return ns.send(RouteBroadcast, msg, msgType, ns.peers()...)
+}
+
+// Subscribe registers the handler of the broadcast msg
+func (ns *NetService) Subscribe(msgType netpb.NetMsg_MsgType, handler protocol.MsgHandler) error {
+	return ns.register(ns.subscribers, msgType, handler)
+}
+
+// CancelSubscribe removes the handler of the broadcast msg
+func (ns *NetService) CancelSubscribe(msgType netpb.NetMsg_MsgType) error {
+	return ns.unregister(ns.subscribers, msgType)
+}
+
+// ConsensusBroadcastMsg sends msg to all the other nodes
+func (ns *NetService) ConsensusBroadcastMsg(msg []byte, msgType netpb.NetMsg_MsgType) error {
+	return ns.send(RouteConsensus, msg, msgType, ns.peers()...)
+}
+
+// ConsensusSubscribe registers the handler of the consensus msg
+func (ns *NetService) ConsensusSubscribe(msgType netpb.NetMsg_MsgType, handler protocol.MsgHandler) error {
+	return ns.register(ns.consensusHandlers, msgType, handler)
+}
+
+// CancelConsensusSubscribe removes the handler of the consensus msg
+func (ns *NetService) CancelConsensusSubscribe(msgType netpb.NetMsg_MsgType) error {
+	return ns.unregister(ns.consensusHandlers, msgType)
+}
+
+// SendMsg sends msg to the given nodes
+func (ns *NetService) SendMsg(msg []byte, msgType netpb.NetMsg_MsgType, to ...string) error {
+	return ns.send(RouteDirect, msg, msgType, to...)
+}
+
+func (ns *NetService) send(route Route, msg []byte, msgType netpb.NetMsg_MsgType, to ...string) error {
+	for _, n := range to {
+		if n == ns.id {
+			continue
+		}
+		ns.net.SendOn(ns.id, route, &netpb.NetMsg{Payload: msg, Type: msgType, To: n})
+	}
+	return nil
+}
+
+// ReceiveMsg registers the handler of the msg sent directly
+func (ns *NetService) ReceiveMsg(msgType netpb.NetMsg_MsgType, handler protocol.MsgHandler) error {
+	return ns.register(ns.directHandlers, msgType, handler)
+}
+
+// Start does nothing, the node joins the network when created
+func (ns *NetService) Start() error {
+	return nil
+}
+
+// Stop does nothing, the node leaves the network when the cluster stops
+func (ns *NetService) Stop() error {
+	return nil
+}
+
+// GetNodeUidByCertId returns the cert id itself, node ids are used as cert ids in the harness
+func (ns *NetService) GetNodeUidByCertId(certId string) (string, error) {
+	return certId, nil
+}
+
+// GetChainNodesInfoProvider returns a provider listing the nodes of the chain
+func (ns *NetService) GetChainNodesInfoProvider() protocol.ChainNodesInfoProvider {
+	return ns
+}
+
+// GetChainNodesInfo returns the nodes of the chain
+func (ns *NetService) GetChainNodesInfo() ([]*protocol.ChainNodeInfo, error) {
+	var infos []*protocol.ChainNodeInfo
+	for _, id := range ns.peers() {
+		infos = append(infos, &protocol.ChainNodeInfo{NodeUid: id})
+	}
+	return infos, nil
+}
+
+// handle dispatches a msg received from the network to the handler of its route and type
+func (ns *NetService) handle(from string, route Route, msg *netpb.NetMsg) {
+	var handler protocol.MsgHandler
+	ns.mtx.RLock()
+	switch route {
+	case RouteBroadcast:
+		handler = ns.subscribers[msg.Type]
+	case RouteConsensus:
+		handler = ns.consensusHandlers[msg.Type]
+	case RouteDirect:
+		handler = ns.directHandlers[msg.Type]
+	}
+	ns.mtx.RUnlock()
+	if handler != nil {
+		_ = handler(from, msg.Payload, msg.Type)
+	}
+}
+
+func (ns *NetService) register(handlers map[netpb.NetMsg_MsgType]protocol.MsgHandler,
+	msgType netpb.NetMsg_MsgType, handler protocol.MsgHandler) error {
+	ns.mtx.Lock()
+	defer ns.mtx.Unlock()
+	if _, ok := handlers[msgType]; ok {
+		return fmt.Errorf("handler of %s exists", msgType)
+	}
+	handlers[msgType] = handler
+	return nil
+}
+
+func (ns *NetService) unregister(handlers map[netpb.NetMsg_MsgType]protocol.MsgHandler,
+	msgType netpb.NetMsg_MsgType) error {
+	ns.mtx.Lock()
+	defer ns.mtx.Unlock()
+	delete(handlers, msgType)
+	return nil
+}
@@ -0,0 +1,218 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package harness runs several consensus instances in one process over an
+// in-memory network, it injects network faults and byzantine behaviors and
+// checks the safety and liveness of the committed blocks.
+package harness
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	netpb "chainmaker.org/chainmaker/pb-go/v2/net"
+)
+
+// Interceptor inspects a message sent from one node to another and returns
+// the messages to be delivered instead: nil drops it, several duplicate it,
+// modified ones tamper it.
+type Interceptor func(from, to string, msg *netpb.NetMsg) []*netpb.NetMsg
+
+// Route tells how a message is sent, the receiver hands it to the handlers of the same route only
+type Route int
+
+const (
+	// RouteMsgBus is the msg sent by the engine on msgbus.SendConsensusMsg and
+	// published to msgbus.RecvConsensusMsg by the receiver
+	RouteMsgBus Route = iota
+	// RouteBroadcast is the msg sent by NetService.BroadcastMsg
+	RouteBroadcast
+	// RouteConsensus is the msg sent by NetService.ConsensusBroadcastMsg
+	RouteConsensus
+	// RouteDirect is the msg sent by NetService.SendMsg
+	RouteDirect
+)
+
+// Receiver receives a message delivered by the network
+type Receiver func(from string, route Route, msg *netpb.NetMsg)
+
+// Faults describes the faults injected into the network
+type Faults struct {
+	// DropRate is the probability in [0, 1] to drop a message
+	DropRate float64
+	// MinDelay and MaxDelay bound the random delay of each message
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// ReorderRate is the probability in [0, 1] to hold a message back
+	// by ReorderDelay so that the following messages overtake it
+	ReorderRate  float64
+	ReorderDelay time.Duration
+}
+
+// Network is an in-memory network between the nodes of a cluster
+type Network struct {
+	mtx          sync.RWMutex
+	rand         *rand.Rand
+	receivers    map[string]Receiver
+	faults       Faults
+	partitions   map[string]int // node id -> partition index, nodes in different partitions can not talk
+	interceptors []Interceptor
+
+	wg     sync.WaitGroup
+	closed bool
+
+	sent      uint64
+	delivered uint64
+	dropped   uint64
+}
+
+// NewNetwork creates a network, seed makes the injected faults reproducible
+func NewNetwork(seed int64) *Network {
+	return &Network{
+		rand:       rand.New(rand.NewSource(seed)),
+		receivers:  make(map[string]Receiver),
+		partitions: make(map[string]int),
+	}
+}
+
+// Join attaches a node to the network
+func (n *Network) Join(id string, receiver Receiver) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.receivers[id] = receiver
+}
+
+// Leave detaches a node from the network, messages to it are dropped
+func (n *Network) Leave(id string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	delete(n.receivers, id)
+}
+
+// SetFaults replaces the faults injected into the network
+func (n *Network) SetFaults(faults Faults) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.faults = faults
+}
+
+// Partition splits the network, nodes not listed in any group keep
+// talking to everyone. Heal restores the network.
+func (n *Network) Partition(groups ...[]string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.partitions = make(map[string]int)
+	for i, group := range groups {
+		for _, id := range group {
+			n.partitions[id] = i + 1
+		}
+	}
+}
+
+// Heal removes all the partitions
+func (n *Network) Heal() {
+	n.Partition()
+}
+
+// AddInterceptor appends an interceptor, interceptors are applied in order
+func (n *Network) AddInterceptor(interceptor Interceptor) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.interceptors = append(n.interceptors, interceptor)
+}
+
+// ClearInterceptors removes all the interceptors
+func (n *Network) ClearInterceptors() {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.interceptors = nil
+}
+
+// Stats returns the number of messages sent, delivered and dropped
+func (n *Network) Stats() (sent, delivered, dropped uint64) {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+	return n.sent, n.delivered, n.dropped
+}
+
+// Close stops delivering and waits for the in-flight messages
+func (n *Network) Close() {
+	n.mtx.Lock()
+	n.closed = true
+	n.mtx.Unlock()
+	n.wg.Wait()
+}
+
+// Send sends msg from one node to msg.To on the msgbus route
+func (n *Network) Send(from string, msg *netpb.NetMsg) {
+	n.SendOn(from, RouteMsgBus, msg)
+}
+
+// SendOn sends msg from one node to msg.To on the given route
+func (n *Network) SendOn(from string, route Route, msg *netpb.NetMsg) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	if n.closed {
+		return
+	}
+	n.sent++
+
+	msgs := []*netpb.NetMsg{msg}
+	for _, interceptor := range n.interceptors {
+		var intercepted []*netpb.NetMsg
+		for _, m := range msgs {
+			intercepted = append(intercepted, interceptor(from, m.To, m)...)
+		}
+		msgs = intercepted
+	}
+	if len(msgs) == 0 {
+		n.dropped++
+		return
+	}
+
+	for _, m := range msgs {
+		receiver, ok := n.receivers[m.To]
+		if !ok || !n.connected(from, m.To) || n.rand.Float64() < n.faults.DropRate {
+			n.dropped++
+			continue
+		}
+		n.deliver(from, route, m, receiver, n.delay())
+	}
+}
+
+// connected checks whether two nodes are in the same partition, it must be called with lock held
+func (n *Network) connected(from, to string) bool {
+	p1, ok1 := n.partitions[from]
+	p2, ok2 := n.partitions[to]
+	return !ok1 || !ok2 || p1 == p2
+}
+
+// delay computes the delay of a message, it must be called with lock held
+func (n *Network) delay() time.Duration {
+	delay := n.faults.MinDelay
+	if n.faults.MaxDelay > n.faults.MinDelay {
+		delay += time.Duration(n.rand.Int63n(int64(n.faults.MaxDelay - n.faults.MinDelay)))
+	}
+	if n.rand.Float64() < n.faults.ReorderRate {
+		delay += n.faults.ReorderDelay
+	}
+	return delay
+}
+
+// deliver hands the message to the receiver after delay, it must be called with lock held
+func (n *Network) deliver(from string, route Route, msg *netpb.NetMsg, receiver Receiver,
+	delay time.Duration) {
+	n.delivered++
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		receiver(from, route, msg)
+	}()
+}
@@ -232,6 +232,61 @@ func (consensus *ConsensusRaftImpl) Stop() error {
 	return nil
 }
 
+// Drain transfers the leadership to the most up-to-date follower if the node is the leader,
+// and waits for the wal entries saved asynchronously to be flushed.
+func (consensus *ConsensusRaftImpl) Drain(ctx context.Context) error {
+	status := consensus.node.Status()
+	if status.Lead == consensus.Id {
+		var transferee, match uint64
+		for id, progress := range status.Progress {
+			if id != consensus.Id && progress.Match >= match {
+				transferee, match = id, progress.Match
+			}
+		}
+		if transferee != 0 {
+			consensus.logger.Infof("[%x] transfer leadership to [%x] for draining", consensus.Id, transferee)
+			consensus.node.TransferLeadership(ctx, consensus.Id, transferee)
+		}
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		status = consensus.node.Status()
+		if status.Lead != consensus.Id || len(status.Progress) <= 1 {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("[%x] leadership transfer not finished, %v", consensus.Id, ctx.Err())
+		case <-consensus.closeC:
+			return nil
+		}
+	}
+	if !consensus.asyncWalSave {
+		return nil
+	}
+
+	// the wal items are saved in order, so the ones queued are flushed once the ack is closed
+	ack := make(walFlushAck)
+	select {
+	case consensus.walSaveC <- ack:
+	case <-ctx.Done():
+		return fmt.Errorf("[%x] wal flush not finished, %v", consensus.Id, ctx.Err())
+	case <-consensus.closeC:
+		return nil
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("[%x] wal flush not finished, %v", consensus.Id, ctx.Err())
+	case <-consensus.closeC:
+		return nil
+	}
+}
+
 // OnMessage receives messages from msgbus
 func (consensus *ConsensusRaftImpl) OnMessage(message *msgbus.Message) {
 	switch message.Topic {
@@ -479,6 +534,9 @@ func (consensus *ConsensusRaftImpl) processWalAndSnap(ready etcdraft.Ready) {
 	consensus.node.Advance()
 }
 
+// walFlushAck is closed by AsyncWalSave once the wal items queued before it are saved
+type walFlushAck chan struct{}
+
 func (consensus *ConsensusRaftImpl) AsyncWalSave() {
 	for {
 		select {
@@ -487,6 +545,8 @@ func (consensus *ConsensusRaftImpl) AsyncWalSave() {
 				consensus.processWalAndSnap(ready)
 			} else if configChanged, ok := item.(bool); ok {
 				consensus.maybeTriggerSnapshot(configChanged)
+			} else if ack, ok := item.(walFlushAck); ok {
+				close(ack)
 			} else {
 				consensus.logger.Panicf("[%x] AsyncWalSave got an invalid item: %v", item)
 			}
@@ -36,6 +36,15 @@ type ConsensusSoloImpl struct {
 
 	mtx       sync.Mutex
 	chainConf protocol.ChainConf
+
+	// dev mode
+	devConfig  *DevModeConfig
+	devMtx     sync.Mutex
+	mineMtx    sync.Mutex
+	mining     bool
+	timeOffset int64
+	committedC chan struct{}
+	closeC     chan struct{}
 }
 
 //New ...
@@ -51,6 +60,14 @@ func New(chainID string, uid string, singer protocol.SigningMember,
 	consensus.singer = singer
 	consensus.msgbus = msgBus
 	consensus.chainConf = chainConf
+	consensus.committedC = make(chan struct{}, 1)
+	consensus.closeC = make(chan struct{})
+
+	devConfig, err := ParseDevModeConfig(chainConf.ChainConfig())
+	if err != nil {
+		return nil, err
+	}
+	consensus.devConfig = devConfig
 
 	return consensus, nil
 }
@@ -60,6 +77,10 @@ func (consensus *ConsensusSoloImpl) Start() error {
 	consensus.msgbus.Register(msgbus.ProposedBlock, consensus)
 	consensus.msgbus.Register(msgbus.VerifyResult, consensus)
 	go consensus.procProposerStatus()
+	if consensus.devConfig.Enabled() {
+		devInstances.Store(consensus.chainID, consensus)
+		go consensus.procDevMode()
+	}
 
 	clog.Infof("ConsensusSoloImpl %s started", consensus.id)
 	return nil
@@ -68,6 +89,10 @@ func (consensus *ConsensusSoloImpl) Start() error {
 // Stop implements the Stop method of ConsensusEngine interface.
 // TODO: implement Stop method
 func (consensus *ConsensusSoloImpl) Stop() error {
+	if consensus.devConfig.Enabled() {
+		devInstances.Delete(consensus.chainID)
+		close(consensus.closeC)
+	}
 	clog.Infof("ConsensusSoloImpl %s stoped", consensus.id)
 	return nil
 }
@@ -177,6 +202,10 @@ func (consensus *ConsensusSoloImpl) handleVerifyResult(message *msgbus.Message)
 	clog.Infof("publish CommitBlock, height = %v", consensus.verifyingBlock.Header.BlockHeight)
 	consensus.msgbus.Publish(msgbus.CommitBlock, consensus.verifyingBlock)
 	consensus.verifyingBlock = nil
+	select {
+	case consensus.committedC <- struct{}{}:
+	default:
+	}
 }
 
 //OnQuit ...
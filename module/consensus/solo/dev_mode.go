@@ -0,0 +1,224 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package solo
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"chainmaker.org/chainmaker/common/v2/msgbus"
+	"chainmaker.org/chainmaker/pb-go/v2/config"
+	consensuspb "chainmaker.org/chainmaker/pb-go/v2/consensus"
+	txpoolpb "chainmaker.org/chainmaker/pb-go/v2/txpool"
+)
+
+// keys of solo dev mode in chain config consensus.ext_config
+const (
+	// DevModeKey selects the dev mode, DevModeInterval or DevModeInstamine
+	DevModeKey = "solo.dev_mode"
+	// DevBlockIntervalKey is the block interval of DevModeInterval, e.g. "1s"
+	DevBlockIntervalKey = "solo.dev_block_interval"
+)
+
+const (
+	// DevModeInterval produces a block every interval, empty or not
+	DevModeInterval = "interval"
+	// DevModeInstamine produces a block as soon as there are txs in txpool
+	DevModeInstamine = "instamine"
+
+	// DevContractName is the contract name of the dev control queries
+	DevContractName = "SOLO_DEV"
+	// DevMethodMine mines DevParamBlocks blocks
+	DevMethodMine = "MINE"
+	// DevMethodFastForward moves the block timestamp forward DevParamSeconds seconds
+	DevMethodFastForward = "FAST_FORWARD"
+	// DevParamBlocks is the parameter of DevMethodMine
+	DevParamBlocks = "blocks"
+	// DevParamSeconds is the parameter of DevMethodFastForward
+	DevParamSeconds = "seconds"
+
+	defaultDevBlockInterval = time.Second
+	instaminePollInterval   = 20 * time.Millisecond
+	mineBlockTimeout        = 10 * time.Second
+)
+
+// devInstances holds the solo instances running in dev mode, chainID -> *ConsensusSoloImpl
+var devInstances sync.Map
+
+// DevModeConfig is the dev mode config of solo
+type DevModeConfig struct {
+	Mode          string
+	BlockInterval time.Duration
+}
+
+// Enabled returns whether the dev mode is enabled
+func (c *DevModeConfig) Enabled() bool {
+	return c.Mode == DevModeInterval || c.Mode == DevModeInstamine
+}
+
+// ParseDevModeConfig parses the dev mode config from consensus.ext_config
+func ParseDevModeConfig(chainConfig *config.ChainConfig) (*DevModeConfig, error) {
+	devConfig := &DevModeConfig{BlockInterval: defaultDevBlockInterval}
+	if chainConfig == nil || chainConfig.Consensus == nil ||
+		chainConfig.Consensus.Type != consensuspb.ConsensusType_SOLO {
+		return devConfig, nil
+	}
+	for _, kv := range chainConfig.Consensus.ExtConfig {
+		switch kv.Key {
+		case DevModeKey:
+			devConfig.Mode = string(kv.Value)
+			if devConfig.Mode != DevModeInterval && devConfig.Mode != DevModeInstamine {
+				return nil, fmt.Errorf("invalid %s: %s", DevModeKey, devConfig.Mode)
+			}
+		case DevBlockIntervalKey:
+			interval, err := time.ParseDuration(string(kv.Value))
+			if err != nil || interval <= 0 {
+				return nil, fmt.Errorf("invalid %s: %s", DevBlockIntervalKey, kv.Value)
+			}
+			devConfig.BlockInterval = interval
+		}
+	}
+	return devConfig, nil
+}
+
+// CanProposeEmptyBlock returns whether solo is allowed to propose an empty block,
+// which is the case in DevModeInterval or when blocks are mined by the dev control.
+func CanProposeEmptyBlock(chainConfig *config.ChainConfig) bool {
+	if chainConfig == nil {
+		return false
+	}
+	ins, ok := devInstances.Load(chainConfig.ChainId)
+	if !ok {
+		return false
+	}
+	consensus, _ := ins.(*ConsensusSoloImpl)
+	return consensus.canProposeEmptyBlock()
+}
+
+// BlockTimeOffset returns the seconds the timestamps of the new blocks are moved forward by the dev control,
+// 0 if solo is not running in dev mode. It is applied when the block is generated, before the txs are executed.
+func BlockTimeOffset(chainConfig *config.ChainConfig) int64 {
+	if chainConfig == nil {
+		return 0
+	}
+	ins, ok := devInstances.Load(chainConfig.ChainId)
+	if !ok {
+		return 0
+	}
+	consensus, _ := ins.(*ConsensusSoloImpl)
+	return consensus.getTimeOffset()
+}
+
+// GetDevController returns the dev controller of the chain, it returns
+// an error when solo is not running in dev mode on the chain.
+func GetDevController(chainID string) (*ConsensusSoloImpl, error) {
+	ins, ok := devInstances.Load(chainID)
+	if !ok {
+		return nil, fmt.Errorf("solo dev mode is not enabled on chain %s", chainID)
+	}
+	return ins.(*ConsensusSoloImpl), nil
+}
+
+// Mine produces n blocks, empty or not, and returns after they are committed.
+func (consensus *ConsensusSoloImpl) Mine(n uint64) error {
+	consensus.mineMtx.Lock()
+	defer consensus.mineMtx.Unlock()
+
+	for i := uint64(0); i < n; i++ {
+		// drop the commit notified before this round
+		select {
+		case <-consensus.committedC:
+		default:
+		}
+		consensus.setMining(true)
+		consensus.signalPropose()
+		select {
+		case <-consensus.committedC:
+		case <-time.After(mineBlockTimeout):
+			consensus.setMining(false)
+			return fmt.Errorf("mine block timeout, mined %d of %d", i, n)
+		case <-consensus.closeC:
+			consensus.setMining(false)
+			return fmt.Errorf("solo stopped, mined %d of %d", i, n)
+		}
+	}
+	consensus.setMining(false)
+	return nil
+}
+
+// FastForward moves the timestamp of the following blocks forward and
+// returns the total offset in seconds.
+func (consensus *ConsensusSoloImpl) FastForward(seconds int64) (int64, error) {
+	if seconds < 0 {
+		return 0, fmt.Errorf("can not fast forward a negative duration: %d", seconds)
+	}
+	consensus.devMtx.Lock()
+	defer consensus.devMtx.Unlock()
+	consensus.timeOffset += seconds
+	clog.Infof("solo dev mode fast forward %ds, time offset: %ds", seconds, consensus.timeOffset)
+	return consensus.timeOffset, nil
+}
+
+// ParseDevParam parses an uint64 parameter of the dev control
+func ParseDevParam(params map[string][]byte, key string) (uint64, error) {
+	value, ok := params[key]
+	if !ok {
+		return 0, fmt.Errorf("param %s not found", key)
+	}
+	n, err := strconv.ParseUint(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid param %s: %s", key, value)
+	}
+	return n, nil
+}
+
+func (consensus *ConsensusSoloImpl) canProposeEmptyBlock() bool {
+	consensus.devMtx.Lock()
+	defer consensus.devMtx.Unlock()
+	return consensus.devConfig.Mode == DevModeInterval || consensus.mining
+}
+
+func (consensus *ConsensusSoloImpl) setMining(mining bool) {
+	consensus.devMtx.Lock()
+	defer consensus.devMtx.Unlock()
+	consensus.mining = mining
+}
+
+func (consensus *ConsensusSoloImpl) getTimeOffset() int64 {
+	consensus.devMtx.Lock()
+	defer consensus.devMtx.Unlock()
+	return consensus.timeOffset
+}
+
+// signalPropose asks core to propose a block
+func (consensus *ConsensusSoloImpl) signalPropose() {
+	consensus.msgbus.Publish(msgbus.TxPoolSignal, &txpoolpb.TxPoolSignal{
+		SignalType: txpoolpb.SignalType_BLOCK_PROPOSE,
+		ChainId:    consensus.chainID,
+	})
+}
+
+// procDevMode triggers proposing periodically in dev mode
+func (consensus *ConsensusSoloImpl) procDevMode() {
+	interval := consensus.devConfig.BlockInterval
+	if consensus.devConfig.Mode == DevModeInstamine {
+		interval = instaminePollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	clog.Infof("solo dev mode %s started, interval: %v", consensus.devConfig.Mode, interval)
+	for {
+		select {
+		case <-ticker.C:
+			consensus.signalPropose()
+		case <-consensus.closeC:
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package solo
+
+import (
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/pb-go/v2/config"
+	consensuspb "chainmaker.org/chainmaker/pb-go/v2/consensus"
+	"github.com/stretchr/testify/require"
+)
+
+func newSoloChainConfig(kvs ...*common.KeyValuePair) *config.ChainConfig {
+	return &config.ChainConfig{
+		ChainId: "chain1",
+		Consensus: &config.ConsensusConfig{
+			Type:      consensuspb.ConsensusType_SOLO,
+			ExtConfig: kvs,
+		},
+	}
+}
+
+func TestParseDevModeConfig(t *testing.T) {
+	devConfig, err := ParseDevModeConfig(newSoloChainConfig())
+	require.Nil(t, err)
+	require.False(t, devConfig.Enabled())
+
+	devConfig, err = ParseDevModeConfig(newSoloChainConfig(
+		&common.KeyValuePair{Key: DevModeKey, Value: []byte(DevModeInterval)},
+		&common.KeyValuePair{Key: DevBlockIntervalKey, Value: []byte("500ms")},
+	))
+	require.Nil(t, err)
+	require.True(t, devConfig.Enabled())
+	require.Equal(t, 500*time.Millisecond, devConfig.BlockInterval)
+
+	_, err = ParseDevModeConfig(newSoloChainConfig(
+		&common.KeyValuePair{Key: DevModeKey, Value: []byte("unknown")},
+	))
+	require.NotNil(t, err)
+
+	_, err = ParseDevModeConfig(newSoloChainConfig(
+		&common.KeyValuePair{Key: DevBlockIntervalKey, Value: []byte("-1s")},
+	))
+	require.NotNil(t, err)
+}
+
+func TestCanProposeEmptyBlock(t *testing.T) {
+	chainConfig := newSoloChainConfig()
+	require.False(t, CanProposeEmptyBlock(chainConfig))
+
+	consensus := &ConsensusSoloImpl{
+		chainID:   chainConfig.ChainId,
+		devConfig: &DevModeConfig{Mode: DevModeInstamine},
+	}
+	devInstances.Store(chainConfig.ChainId, consensus)
+	defer devInstances.Delete(chainConfig.ChainId)
+	require.False(t, CanProposeEmptyBlock(chainConfig))
+
+	consensus.setMining(true)
+	require.True(t, CanProposeEmptyBlock(chainConfig))
+}
+
+func TestBlockTimeOffset(t *testing.T) {
+	chainConfig := newSoloChainConfig()
+	require.Equal(t, int64(0), BlockTimeOffset(chainConfig))
+
+	consensus := &ConsensusSoloImpl{
+		chainID:   chainConfig.ChainId,
+		devConfig: &DevModeConfig{Mode: DevModeInterval},
+	}
+	devInstances.Store(chainConfig.ChainId, consensus)
+	defer devInstances.Delete(chainConfig.ChainId)
+
+	offset, err := consensus.FastForward(60)
+	require.Nil(t, err)
+	require.Equal(t, int64(60), offset)
+	offset, err = consensus.FastForward(30)
+	require.Nil(t, err)
+	require.Equal(t, int64(90), offset)
+	require.Equal(t, int64(90), BlockTimeOffset(chainConfig))
+
+	_, err = consensus.FastForward(-1)
+	require.NotNil(t, err)
+}
@@ -8,11 +8,13 @@ package tbft
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"path"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"chainmaker.org/chainmaker/chainconf/v2"
@@ -52,6 +54,8 @@ const (
 	TimeoutPrecommit           = 30 * time.Second // Timeout of waitting for >2/3 precommit
 	TimeoutPrecommitDelta      = 1 * time.Second  // Increased time delta of TimeoutPrecommit between round
 	TimeoutCommit              = 30 * time.Second
+
+	drainCheckInterval = 100 * time.Millisecond // Interval of checking the proposer turn when draining
 )
 
 // mustMarshal marshals protobuf message to byte slice or panic
@@ -70,6 +74,23 @@ func mustUnmarshal(b []byte, msg proto.Message) {
 	}
 }
 
+// misbehaviors of the peers reported to the net service, the same as the ones of the net module
+const (
+	misbehaviorInvalidSignature = "invalid_signature"
+	misbehaviorInvalidMsg       = "invalid_msg"
+)
+
+// peerReporter is implemented by the net service scoring the peers by their misbehaviors
+type peerReporter interface {
+	ReportPeer(peer string, misbehavior string)
+}
+
+// externalMsg is a consensus msg received from the peer
+type externalMsg struct {
+	from string
+	msg  *tbftpb.TBFTMsg
+}
+
 // ConsensusTBFTImpl is the implementation of TBFT algorithm
 // and it implements the ConsensusEngine interface.
 type ConsensusTBFTImpl struct {
@@ -102,8 +123,12 @@ type ConsensusTBFTImpl struct {
 	proposedBlockC chan *consensuspb.ProposalBlock
 	verifyResultC  chan *consensuspb.VerifyResult
 	blockHeightC   chan uint64
-	externalMsgC   chan *tbftpb.TBFTMsg
+	externalMsgC   chan *externalMsg
 	internalMsgC   chan *tbftpb.TBFTMsg
+	drainC         chan struct{}
+
+	// draining is set once the node starts to hand off its proposer turns
+	draining int32
 
 	TimeoutPropose      time.Duration
 	TimeoutProposeDelta time.Duration
@@ -157,8 +182,9 @@ func New(config ConsensusTBFTImplConfig) (*ConsensusTBFTImpl, error) {
 	consensus.proposedBlockC = make(chan *consensuspb.ProposalBlock, defaultChanCap)
 	consensus.verifyResultC = make(chan *consensuspb.VerifyResult, defaultChanCap)
 	consensus.blockHeightC = make(chan uint64, defaultChanCap)
-	consensus.externalMsgC = make(chan *tbftpb.TBFTMsg, defaultChanCap)
+	consensus.externalMsgC = make(chan *externalMsg, defaultChanCap)
 	consensus.internalMsgC = make(chan *tbftpb.TBFTMsg, defaultChanCap)
+	consensus.drainC = make(chan struct{}, 1)
 
 	validators, err := GetValidatorListFromConfig(consensus.chainConf.ChainConfig())
 	if err != nil {
@@ -218,6 +244,63 @@ func (consensus *ConsensusTBFTImpl) Stop() error {
 	return nil
 }
 
+// Drain makes the node skip its proposer turns by prevoting nil at once instead of proposing,
+// the other validators prevote nil on the nil prevote of the proposer and change the round
+// without waiting for the propose timeout. It returns once the turn of the current round, if any,
+// is skipped, so it does not depend on the chain to make progress. The node keeps voting while draining.
+func (consensus *ConsensusTBFTImpl) Drain(ctx context.Context) error {
+	atomic.StoreInt32(&consensus.draining, 1)
+	select {
+	case consensus.drainC <- struct{}{}:
+	default:
+	}
+
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for {
+		consensus.RLock()
+		height, round := consensus.Height, consensus.Round
+		proposing := consensus.validatorSet.Size() > 1 && consensus.Step <= tbftpb.Step_PROPOSE &&
+			consensus.isProposer(height, round)
+		consensus.RUnlock()
+		if !proposing {
+			consensus.logger.Infof("[%s](%d/%d) drained, no proposer turn pending", consensus.Id, height, round)
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("proposer turn at (%d/%d) not skipped, %v", height, round, ctx.Err())
+		case <-consensus.closeC:
+			return nil
+		}
+	}
+}
+
+// isDraining returns true if the node skips its proposer turns,
+// a single validator keeps proposing or the chain would halt
+func (consensus *ConsensusTBFTImpl) isDraining() bool {
+	return atomic.LoadInt32(&consensus.draining) == 1 && consensus.validatorSet.Size() > 1
+}
+
+// handleDrain skips the proposer turn of the current round if the node has not proposed yet
+func (consensus *ConsensusTBFTImpl) handleDrain() {
+	consensus.Lock()
+	defer consensus.Unlock()
+
+	if consensus.Step == tbftpb.Step_PROPOSE && consensus.isProposer(consensus.Height, consensus.Round) &&
+		consensus.isDraining() {
+		consensus.skipPropose(consensus.Height, consensus.Round)
+	}
+}
+
+// skipPropose prevotes nil at `height` and `round` instead of proposing
+func (consensus *ConsensusTBFTImpl) skipPropose(height uint64, round int32) {
+	consensus.logger.Infof("[%s](%v/%v/%v) draining, skip the proposer turn of (%v/%v)",
+		consensus.Id, consensus.Height, consensus.Round, consensus.Step, height, round)
+	consensus.enterPrevote(height, round)
+}
+
 // 1. when leadership transfer, change consensus state and send singal
 // atomic.StoreInt32()
 // proposable <- atomic.LoadInt32(consensus.isLeader)
@@ -243,9 +326,14 @@ func (consensus *ConsensusTBFTImpl) OnMessage(message *msgbus.Message) {
 		}
 	case msgbus.RecvConsensusMsg:
 		if msg, ok := message.Payload.(*netpb.NetMsg); ok {
+			// the sender of the msg received is in the To field
 			tbftMsg := new(tbftpb.TBFTMsg)
-			mustUnmarshal(msg.Payload, tbftMsg)
-			consensus.externalMsgC <- tbftMsg
+			if err := proto.Unmarshal(msg.Payload, tbftMsg); err != nil {
+				consensus.logger.Warnf("[%s] drop the invalid msg from %s, %v", consensus.Id, msg.To, err)
+				consensus.reportPeer(msg.To, misbehaviorInvalidMsg)
+				return
+			}
+			consensus.externalMsgC <- &externalMsg{from: msg.To, msg: tbftMsg}
 		} else {
 			panic(fmt.Errorf("receive message failed, error message type"))
 		}
@@ -396,15 +484,17 @@ func (consensus *ConsensusTBFTImpl) handle() {
 		case height := <-consensus.blockHeightC:
 			consensus.handleBlockHeight(height)
 		case msg := <-consensus.externalMsgC:
-			consensus.logger.Debugf("[%s] receive from externalMsgC %s, size: %d", consensus.Id, msg.Type,
-				proto.Size(msg))
-			consensus.handleConsensusMsg(msg)
+			consensus.logger.Debugf("[%s] receive from externalMsgC %s, size: %d", consensus.Id, msg.msg.Type,
+				proto.Size(msg.msg))
+			consensus.handleConsensusMsg(msg.msg, msg.from)
 		case msg := <-consensus.internalMsgC:
 			consensus.logger.Debugf("[%s] receive from internalMsgC %s, size: %d", consensus.Id, msg.Type,
 				proto.Size(msg))
-			consensus.handleConsensusMsg(msg)
+			consensus.handleConsensusMsg(msg, "")
 		case ti := <-consensus.timeScheduler.GetTimeoutC():
 			consensus.handleTimeout(ti, false)
+		case <-consensus.drainC:
+			consensus.handleDrain()
 		case <-consensus.closeC:
 			loop = false
 		}
@@ -581,7 +671,7 @@ func (consensus *ConsensusTBFTImpl) handleBlockHeight(height uint64) {
 	consensus.enterNewHeight(height+1, false)
 }
 
-func (consensus *ConsensusTBFTImpl) procPropose(msg *tbftpb.TBFTMsg) {
+func (consensus *ConsensusTBFTImpl) procPropose(msg *tbftpb.TBFTMsg, from string) {
 	proposalProto := new(tbftpb.Proposal)
 	mustUnmarshal(msg.Msg, proposalProto)
 
@@ -596,6 +686,7 @@ func (consensus *ConsensusTBFTImpl) procPropose(msg *tbftpb.TBFTMsg) {
 			consensus.Id, consensus.Height, consensus.Round, consensus.Step,
 			err,
 		)
+		consensus.reportPeer(from, misbehaviorInvalidSignature)
 		return
 	}
 
@@ -668,7 +759,7 @@ func (consensus *ConsensusTBFTImpl) canReceiveProposal(height uint64, round int3
 	return true
 }
 
-func (consensus *ConsensusTBFTImpl) procPrevote(msg *tbftpb.TBFTMsg) {
+func (consensus *ConsensusTBFTImpl) procPrevote(msg *tbftpb.TBFTMsg, from string) {
 	prevote := new(tbftpb.Vote)
 	mustUnmarshal(msg.Msg, prevote)
 
@@ -694,6 +785,7 @@ func (consensus *ConsensusTBFTImpl) procPrevote(msg *tbftpb.TBFTMsg) {
 				consensus.Id, consensus.Height, consensus.Round, consensus.Step,
 				prevote.Voter, prevote.Height, prevote.Round, prevote.Hash, err,
 			)
+			consensus.reportPeer(from, misbehaviorInvalidSignature)
 			return
 		}
 	}
@@ -709,7 +801,7 @@ func (consensus *ConsensusTBFTImpl) procPrevote(msg *tbftpb.TBFTMsg) {
 	}
 }
 
-func (consensus *ConsensusTBFTImpl) procPrecommit(msg *tbftpb.TBFTMsg) {
+func (consensus *ConsensusTBFTImpl) procPrecommit(msg *tbftpb.TBFTMsg, from string) {
 	precommit := new(tbftpb.Vote)
 	mustUnmarshal(msg.Msg, precommit)
 
@@ -735,6 +827,7 @@ func (consensus *ConsensusTBFTImpl) procPrecommit(msg *tbftpb.TBFTMsg) {
 				consensus.Id, consensus.Height, consensus.Round, consensus.Step,
 				precommit.Voter, precommit.Height, precommit.Round, precommit.Hash, err,
 			)
+			consensus.reportPeer(from, misbehaviorInvalidSignature)
 			return
 		}
 	}
@@ -750,17 +843,18 @@ func (consensus *ConsensusTBFTImpl) procPrecommit(msg *tbftpb.TBFTMsg) {
 	}
 }
 
-func (consensus *ConsensusTBFTImpl) handleConsensusMsg(msg *tbftpb.TBFTMsg) {
+// handleConsensusMsg handles the msg from the peer, from is empty for the msgs of the node itself
+func (consensus *ConsensusTBFTImpl) handleConsensusMsg(msg *tbftpb.TBFTMsg, from string) {
 	consensus.Lock()
 	defer consensus.Unlock()
 
 	switch msg.Type {
 	case tbftpb.TBFTMsgType_MSG_PROPOSE:
-		consensus.procPropose(msg)
+		consensus.procPropose(msg, from)
 	case tbftpb.TBFTMsgType_MSG_PREVOTE:
-		consensus.procPrevote(msg)
+		consensus.procPrevote(msg, from)
 	case tbftpb.TBFTMsgType_MSG_PRECOMMIT:
-		consensus.procPrecommit(msg)
+		consensus.procPrecommit(msg, from)
 	case tbftpb.TBFTMsgType_MSG_STATE:
 		// Async is ok
 		go consensus.gossip.onRecvState(msg)
@@ -868,6 +962,13 @@ func (consensus *ConsensusTBFTImpl) addVote(vote *Vote, replayMode bool) error {
 }
 
 func (consensus *ConsensusTBFTImpl) addPrevoteVote(vote *Vote) {
+	// The proposer prevoting nil skips its turn, such as a draining node, prevote nil without
+	// waiting for the propose timeout
+	if consensus.Step == tbftpb.Step_PROPOSE && consensus.isSkippedTurn(vote) {
+		consensus.logger.Infof("[%s](%d/%d/%s) proposer %s prevotes nil, skip the propose timeout",
+			consensus.Id, consensus.Height, consensus.Round, consensus.Step, vote.Voter)
+		consensus.enterPrevote(consensus.Height, consensus.Round)
+	}
 	if consensus.Step != tbftpb.Step_PREVOTE {
 		consensus.logger.Infof("[%s](%d/%d/%s) addVote prevote %v at inappropriate step",
 			consensus.Id, consensus.Height, consensus.Round, consensus.Step, vote)
@@ -911,6 +1012,17 @@ func (consensus *ConsensusTBFTImpl) addPrevoteVote(vote *Vote) {
 	}
 }
 
+// isSkippedTurn returns true if `vote` is the nil prevote of the proposer of the current round
+// while no proposal has been received
+func (consensus *ConsensusTBFTImpl) isSkippedTurn(vote *Vote) bool {
+	if vote.Height != consensus.Height || vote.Round != consensus.Round || !isNilHash(vote.Hash) ||
+		consensus.Proposal != nil {
+		return false
+	}
+	proposer, err := consensus.validatorSet.GetProposer(consensus.Height, consensus.Round)
+	return err == nil && proposer == vote.Voter
+}
+
 func (consensus *ConsensusTBFTImpl) addPrecommitVote(vote *Vote) {
 	if consensus.Step != tbftpb.Step_PRECOMMIT {
 		consensus.logger.Infof("[%s](%d/%d/%s) addVote precommit %v at inappropriate step",
@@ -1068,7 +1180,11 @@ func (consensus *ConsensusTBFTImpl) enterPropose(height uint64, round int32) {
 	}
 
 	if consensus.isProposer(height, round) {
-		consensus.sendProposeState(true)
+		if consensus.isDraining() {
+			consensus.skipPropose(height, round)
+		} else {
+			consensus.sendProposeState(true)
+		}
 	}
 
 	go consensus.gossip.triggerEvent()
@@ -1337,6 +1453,16 @@ func (consensus *ConsensusTBFTImpl) signVote(vote *Vote) error {
 	return nil
 }
 
+// reportPeer reports the misbehavior of the peer to the net service if it scores the peers
+func (consensus *ConsensusTBFTImpl) reportPeer(peer string, misbehavior string) {
+	if peer == "" {
+		return
+	}
+	if reporter, ok := consensus.netService.(peerReporter); ok {
+		reporter.ReportPeer(peer, misbehavior)
+	}
+}
+
 func (consensus *ConsensusTBFTImpl) verifyProposal(proposal *tbftpb.Proposal) error {
 	// Verified by idmgmt
 	proposalCopy := proto.Clone(proposal)
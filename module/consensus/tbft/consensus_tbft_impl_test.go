@@ -7,15 +7,24 @@ SPDX-License-Identifier: Apache-2.0
 package tbft
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
 	"chainmaker.org/chainmaker/common/v2/msgbus"
+	"chainmaker.org/chainmaker/localconf/v2"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
 	"chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/pb-go/v2/config"
 	consensuspb "chainmaker.org/chainmaker/pb-go/v2/consensus"
+	tbftpb "chainmaker.org/chainmaker/pb-go/v2/consensus/tbft"
 	netpb "chainmaker.org/chainmaker/pb-go/v2/net"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
 )
 
 type Blocker struct {
@@ -617,3 +626,124 @@ func TestConsensusTBFTImpl_FourNode_KeepGrowing_LeaderDown(t *testing.T) {
 	wg.Wait()
 }
 */
+
+type proposeStateRecorder struct {
+	msgbus.DefaultSubscriber
+	states chan bool
+}
+
+func (r *proposeStateRecorder) OnMessage(msg *msgbus.Message) {
+	r.states <- msg.Payload.(bool)
+}
+
+// newDrainTestNode creates the tbft instance of id at (1/0/NEW_ROUND) without starting it,
+// the wal is kept under storePath/<id>
+func newDrainTestNode(t *testing.T, ctrl *gomock.Controller, storePath, id string,
+	validators []string) (*ConsensusTBFTImpl, *proposeStateRecorder) {
+	chainConfig := &config.ChainConfig{
+		ChainId: "chain1",
+		Crypto:  &config.CryptoConfig{Hash: "SHA256"},
+		Consensus: &config.ConsensusConfig{
+			Type:  consensuspb.ConsensusType_TBFT,
+			Nodes: []*config.OrgConfig{{OrgId: "org1", NodeId: validators}},
+		},
+	}
+	chainConf := mock.NewMockChainConf(ctrl)
+	chainConf.EXPECT().ChainConfig().AnyTimes().Return(chainConfig)
+	signer := mock.NewMockSigningMember(ctrl)
+	signer.EXPECT().Sign(gomock.Any(), gomock.Any()).AnyTimes().Return([]byte(id), nil)
+	signer.EXPECT().GetMember().AnyTimes().Return(&pbac.Member{OrgId: "org1", MemberInfo: []byte(id)}, nil)
+
+	localconf.ChainMakerConfig.StorageConfig["store_path"] = filepath.Join(storePath, id)
+	mb := msgbus.NewMessageBus()
+	recorder := &proposeStateRecorder{states: make(chan bool, 16)}
+	mb.Register(msgbus.ProposeState, recorder)
+	consensus, err := New(ConsensusTBFTImplConfig{
+		ChainID:   "chain1",
+		Id:        id,
+		Signer:    signer,
+		ChainConf: chainConf,
+		MsgBus:    mb,
+	})
+	require.Nil(t, err)
+
+	consensus.Height, consensus.Round, consensus.Step = 1, 0, tbftpb.Step_NEW_ROUND
+	consensus.heightRoundVoteSet = newHeightRoundVoteSet(consensus.logger, 1, 0, consensus.validatorSet)
+	consensus.metrics = newHeightMetrics(1)
+	return consensus, recorder
+}
+
+func TestConsensusTBFTImpl_Drain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	if localconf.ChainMakerConfig.StorageConfig == nil {
+		localconf.ChainMakerConfig.StorageConfig = make(map[string]interface{})
+	}
+	prePath, hasPath := localconf.ChainMakerConfig.StorageConfig["store_path"]
+	defer func() {
+		if hasPath {
+			localconf.ChainMakerConfig.StorageConfig["store_path"] = prePath
+		} else {
+			delete(localconf.ChainMakerConfig.StorageConfig, "store_path")
+		}
+	}()
+
+	validators := []string{"node0", "node1", "node2", "node3"}
+	storePath := t.TempDir()
+	follower, _ := newDrainTestNode(t, ctrl, storePath, validators[0], validators)
+	proposerId, err := follower.validatorSet.GetProposer(1, 0)
+	require.Nil(t, err)
+	if proposerId == follower.Id {
+		follower, _ = newDrainTestNode(t, ctrl, storePath, validators[1], validators)
+	}
+	proposer, recorder := newDrainTestNode(t, ctrl, storePath, proposerId, validators)
+	go proposer.handle()
+	defer close(proposer.closeC)
+
+	proposer.Lock()
+	proposer.enterPropose(1, 0)
+	proposer.Unlock()
+	require.True(t, <-recorder.states)
+
+	// the chain is idle, no block is proposed and the height never advances
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.Nil(t, proposer.Drain(ctx))
+	require.False(t, <-recorder.states)
+	proposer.RLock()
+	require.Equal(t, tbftpb.Step_PREVOTE, proposer.Step)
+	proposer.RUnlock()
+
+	// a later turn of the draining node is skipped without proposing
+	round := int32(1)
+	for !proposer.isProposer(1, round) {
+		round++
+	}
+	proposer.Lock()
+	proposer.enterNewRound(1, round)
+	require.Equal(t, tbftpb.Step_PREVOTE, proposer.Step)
+	proposer.Unlock()
+	require.False(t, <-recorder.states)
+	select {
+	case state := <-recorder.states:
+		t.Fatalf("unexpected propose state %v", state)
+	default:
+	}
+
+	// the validators prevote nil on the nil prevote of the proposer only
+	var other string
+	for _, id := range validators {
+		if id != proposerId && id != follower.Id {
+			other = id
+			break
+		}
+	}
+	follower.Lock()
+	defer follower.Unlock()
+	follower.enterPropose(1, 0)
+	require.Equal(t, tbftpb.Step_PROPOSE, follower.Step)
+	require.Nil(t, follower.addVote(NewVote(tbftpb.VoteType_VOTE_PREVOTE, other, 1, 0, nilHash), true))
+	require.Equal(t, tbftpb.Step_PROPOSE, follower.Step)
+	require.Nil(t, follower.addVote(NewVote(tbftpb.VoteType_VOTE_PREVOTE, proposerId, 1, 0, nilHash), true))
+	require.Equal(t, tbftpb.Step_PREVOTE, follower.Step)
+}
@@ -14,7 +14,9 @@ import (
 	"sync"
 	"time"
 
+	"chainmaker.org/chainmaker-go/consensus/solo"
 	"chainmaker.org/chainmaker-go/core/common/scheduler"
+	"chainmaker.org/chainmaker-go/core/crosschain"
 	"chainmaker.org/chainmaker-go/core/provider/conf"
 	"chainmaker.org/chainmaker-go/subscriber"
 	"chainmaker.org/chainmaker/common/v2/crypto/hash"
@@ -23,7 +25,7 @@ import (
 	"chainmaker.org/chainmaker/common/v2/msgbus"
 	"chainmaker.org/chainmaker/localconf/v2"
 	commonpb "chainmaker.org/chainmaker/pb-go/v2/common"
-	"chainmaker.org/chainmaker/pb-go/v2/consensus"
+	"chainmaker.org/chainmaker/pb-go/v2/config"
 	"chainmaker.org/chainmaker/protocol/v2"
 	batch "chainmaker.org/chainmaker/txpool-batch/v2"
 	"chainmaker.org/chainmaker/utils/v2"
@@ -45,6 +47,8 @@ type BlockBuilderConf struct {
 	ChainConf       protocol.ChainConf // chain config
 	Log             protocol.Logger
 	StoreHelper     conf.StoreHelper
+	Ac              protocol.AccessControlProvider // access control, to validate the cross-chain txs
+	Store           protocol.BlockchainStore       // blockchain store, to validate the cross-chain txs
 }
 
 type BlockBuilder struct {
@@ -58,6 +62,8 @@ type BlockBuilder struct {
 	chainConf       protocol.ChainConf // chain config
 	log             protocol.Logger
 	storeHelper     conf.StoreHelper
+	ac              protocol.AccessControlProvider
+	store           protocol.BlockchainStore
 }
 
 func NewBlockBuilder(conf *BlockBuilderConf) *BlockBuilder {
@@ -72,11 +78,41 @@ func NewBlockBuilder(conf *BlockBuilderConf) *BlockBuilder {
 		chainConf:       conf.ChainConf,
 		log:             conf.Log,
 		storeHelper:     conf.StoreHelper,
+		ac:              conf.Ac,
+		store:           conf.Store,
 	}
 
 	return creatorBlock
 }
 
+// DropInvalidCrossChainTxs removes the cross-chain txs failing the validation from the tx batch and the tx pool,
+// they are dropped rather than committed failed, since their tx ids are fixed by the messages
+func (bb *BlockBuilder) DropInvalidCrossChainTxs(proposingHeight uint64, preHash []byte,
+	txBatch []*commonpb.Transaction) []*commonpb.Transaction {
+	hasCrossChainTx := false
+	for _, tx := range txBatch {
+		if crosschain.IsCrossChainTx(tx) {
+			hasCrossChainTx = true
+			break
+		}
+	}
+	if !hasCrossChainTx {
+		return txBatch
+	}
+	currentHeight, _ := bb.ledgerCache.CurrentHeight()
+	lastBlock := bb.findLastBlockFromCache(proposingHeight, preHash, currentHeight)
+	if lastBlock == nil {
+		// fails in generating the block
+		return txBatch
+	}
+	validTxs, invalidTxs := ValidateCrossChainTxs(txBatch, lastBlock, bb.chainConf.ChainConfig(), bb.store, bb.ac,
+		bb.log)
+	if len(invalidTxs) > 0 {
+		bb.txPool.RetryAndRemoveTxs(nil, invalidTxs)
+	}
+	return validTxs
+}
+
 func (bb *BlockBuilder) GenerateNewBlock(proposingHeight uint64, preHash []byte, txBatch []*commonpb.Transaction) (
 	*commonpb.Block, []int64, error) {
 	timeLasts := make([]int64, 0)
@@ -129,7 +165,7 @@ func (bb *BlockBuilder) GenerateNewBlock(proposingHeight uint64, preHash []byte,
 	// deal with the special situation：
 	// 1. only one tx and schedule time out
 	// 2. package the empty block
-	if !utils.CanProposeEmptyBlock(bb.chainConf.ChainConfig().Consensus.Type) && len(block.Txs) == 0 {
+	if !CanProposeEmptyBlock(bb.chainConf.ChainConfig()) && len(block.Txs) == 0 {
 		return nil, timeLasts, fmt.Errorf("no txs in scheduled block, proposing block ends")
 	}
 
@@ -207,7 +243,7 @@ func initNewBlock(
 			DagHash:        nil,
 			RwSetRoot:      nil,
 			TxRoot:         nil,
-			BlockTimestamp: utils.CurrentTimeSeconds(),
+			BlockTimestamp: utils.CurrentTimeSeconds() + solo.BlockTimeOffset(chainConf.ChainConfig()),
 			Proposer:       proposer,
 			ConsensusArgs:  nil,
 			TxCount:        0,
@@ -427,9 +463,15 @@ func CheckBlockDigests(block *commonpb.Block, txHashes [][]byte, hashType string
 	return nil
 }
 
-func CheckVacuumBlock(block *commonpb.Block, consensusType consensus.ConsensusType) error {
+// CanProposeEmptyBlock returns whether an empty block is allowed by the chain config,
+// solo only allows empty blocks in dev mode.
+func CanProposeEmptyBlock(chainConfig *config.ChainConfig) bool {
+	return utils.CanProposeEmptyBlock(chainConfig.Consensus.Type) || solo.CanProposeEmptyBlock(chainConfig)
+}
+
+func CheckVacuumBlock(block *commonpb.Block, chainConfig *config.ChainConfig) error {
 	if block.Header.TxCount == 0 {
-		if utils.CanProposeEmptyBlock(consensusType) {
+		if CanProposeEmptyBlock(chainConfig) {
 			// for consensus that allows empty block, skip txs verify
 			return nil
 		}
@@ -531,7 +573,7 @@ func (vb *VerifierBlock) ValidateBlock(
 	sigLasts := utils.CurrentTimeMillisSeconds() - startSigTick
 	timeLasts = append(timeLasts, sigLasts)
 
-	err := CheckVacuumBlock(block, vb.chainConf.ChainConfig().Consensus.Type)
+	err := CheckVacuumBlock(block, vb.chainConf.ChainConfig())
 	if err != nil {
 		return nil, nil, timeLasts, err
 	}
@@ -545,6 +587,10 @@ func (vb *VerifierBlock) ValidateBlock(
 	if IsTxDuplicate(block.Txs) {
 		return nil, nil, timeLasts, fmt.Errorf("tx duplicate")
 	}
+	if _, invalidTxs := ValidateCrossChainTxs(block.Txs, lastBlock, vb.chainConf.ChainConfig(),
+		vb.blockchainStore, vb.ac, vb.log); len(invalidTxs) > 0 {
+		return nil, nil, timeLasts, fmt.Errorf("invalid cross-chain tx %s", invalidTxs[0].Payload.TxId)
+	}
 
 	// simulate with DAG, and verify read write set
 	startVMTick := utils.CurrentTimeMillisSeconds()
@@ -612,6 +658,28 @@ func (vb *VerifierBlock) ValidateBlock(
 	return txRWSetMap, contractEventMap, timeLasts, nil
 }
 
+// ValidateCrossChainTxs splits the txs into the valid ones and the cross-chain txs failing crosschain.ValidateTx,
+// the certs in the proofs are verified at the time of the last block, which is the same to the proposer and the
+// verifiers of the block
+func ValidateCrossChainTxs(txs []*commonpb.Transaction, lastBlock *commonpb.Block, chainConfig *config.ChainConfig,
+	store protocol.BlockchainStore, ac protocol.AccessControlProvider, log protocol.Logger) (
+	[]*commonpb.Transaction, []*commonpb.Transaction) {
+	var invalidTxs []*commonpb.Transaction
+	validTxs := make([]*commonpb.Transaction, 0, len(txs))
+	trustedTime := time.Unix(lastBlock.Header.BlockTimestamp, 0)
+	for _, tx := range txs {
+		if crosschain.IsCrossChainTx(tx) {
+			if err := crosschain.ValidateTx(tx, chainConfig, store, ac, trustedTime); err != nil {
+				log.Warnf("invalid cross-chain tx %s, %s", tx.Payload.TxId, err)
+				invalidTxs = append(invalidTxs, tx)
+				continue
+			}
+		}
+		validTxs = append(validTxs, tx)
+	}
+	return validTxs, invalidTxs
+}
+
 //nolint: staticcheck
 func CheckPreBlock(block *commonpb.Block, lastBlock *commonpb.Block,
 	err error, lastBlockHash []byte, proposedHeight uint64) error {
@@ -0,0 +1,76 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"chainmaker.org/chainmaker-go/core/crosschain"
+	"chainmaker.org/chainmaker/logger/v2"
+	commonpb "chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func newDeliverTx(t *testing.T, msg *crosschain.Message) *commonpb.Transaction {
+	msgBytes, err := json.Marshal(msg)
+	require.NoError(t, err)
+	proofBytes, err := json.Marshal(&crosschain.Proof{})
+	require.NoError(t, err)
+	return &commonpb.Transaction{Payload: &commonpb.Payload{
+		ChainId:      msg.DstChainId,
+		TxType:       commonpb.TxType_INVOKE_CONTRACT,
+		TxId:         crosschain.DeliverTxId(msg.Id),
+		ContractName: crosschain.ContractName,
+		Method:       crosschain.MethodDeliver,
+		Parameters: []*commonpb.KeyValuePair{
+			{Key: crosschain.ParamMessage, Value: msgBytes},
+			{Key: crosschain.ParamProof, Value: proofBytes},
+		},
+	}}
+}
+
+// the cross-chain txs are executed by the node, which invokes the target contracts by the vm manager
+func TestRunVMCrossChain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	msg := &crosschain.Message{SrcChainId: "chain1", SrcTxId: "tx1", SrcContract: "src", DstChainId: "chain2",
+		DstContract: "dst", DstMethod: "receive", Payload: "hello"}
+	msg.Id = crosschain.MessageId(msg.SrcChainId, msg.SrcTxId, msg.EventIndex)
+
+	contract := &commonpb.Contract{Name: "dst", RuntimeType: commonpb.RuntimeType_WASMER}
+	txSimContext := mock.NewMockTxSimContext(ctrl)
+	txSimContext.EXPECT().GetContractByName("dst").AnyTimes().Return(contract, nil)
+	txSimContext.EXPECT().GetContractByName("missing").AnyTimes().Return(nil, errors.New("contract not found"))
+	txSimContext.EXPECT().GetContractBytecode("dst").AnyTimes().Return([]byte("bytecode"), nil)
+	vmManager := mock.NewMockVmManager(ctrl)
+	vmManager.EXPECT().RunContract(contract, "receive", []byte("bytecode"), map[string][]byte{
+		crosschain.ParamCallMessageId:   []byte(msg.Id),
+		crosschain.ParamCallSrcChainId:  []byte("chain1"),
+		crosschain.ParamCallSrcContract: []byte("src"),
+		crosschain.ParamCallPayload:     []byte("hello"),
+	}, txSimContext, uint64(0), commonpb.TxType_INVOKE_CONTRACT).Return(
+		&commonpb.ContractResult{Result: []byte("received")}, protocol.ExecOrderTxTypeNormal,
+		commonpb.TxStatusCode_SUCCESS)
+	ts := &TxScheduler{VmManager: vmManager, log: logger.GetLoggerByChain(logger.MODULE_CORE, "chain2")}
+
+	result, _, err := ts.runVM(newDeliverTx(t, msg), txSimContext)
+	require.NoError(t, err)
+	require.Equal(t, commonpb.TxStatusCode_SUCCESS, result.Code)
+	require.Equal(t, []byte("received"), result.ContractResult.Result)
+
+	// the delivery fails with the target contract, which is acknowledged to the source chain as well
+	msg.DstContract = "missing"
+	result, _, err = ts.runVM(newDeliverTx(t, msg), txSimContext)
+	require.Error(t, err)
+	require.Equal(t, commonpb.TxStatusCode_CONTRACT_FAIL, result.Code)
+	require.Equal(t, "contract not found", result.ContractResult.Message)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package scheduler
+
+import (
+	"sync"
+
+	"chainmaker.org/chainmaker-go/accesscontrol"
+	"chainmaker.org/chainmaker-go/consensus/dpos"
+	"chainmaker.org/chainmaker-go/core/crosschain"
+	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
+)
+
+// reservedContractNames are the names of the contracts served by the node rather than the vm, e.g. the admin
+// queries of the modules, map[string]struct{}
+var reservedContractNames sync.Map
+
+func init() {
+	ReserveContractName(crosschain.ContractName)
+	ReserveContractName(dpos.VRFContractName)
+	ReserveContractName(dpos.RewardContractName)
+	ReserveContractName(accesscontrol.DIDRegistryContractName)
+}
+
+// ReserveContractName reserves the name of a contract served by the node, the user contracts of the name could not
+// be deployed. The names should be reserved at init, so all the nodes running the same binary agree on them.
+func ReserveContractName(name string) {
+	reservedContractNames.Store(name, struct{}{})
+}
+
+// IsReservedContractName returns true if the name is reserved by the node
+func IsReservedContractName(name string) bool {
+	_, ok := reservedContractNames.Load(name)
+	return ok
+}
+
+// deployedContractName returns the name of the contract deployed by the tx of the contract manage, false if the
+// tx does not deploy a contract
+func deployedContractName(contractName, method string, parameters map[string][]byte) (string, bool) {
+	if contractName != syscontract.SystemContract_CONTRACT_MANAGE.String() ||
+		method != syscontract.ContractManageFunction_INIT_CONTRACT.String() {
+		return "", false
+	}
+	return string(parameters[syscontract.InitContract_CONTRACT_NAME.String()]), true
+}
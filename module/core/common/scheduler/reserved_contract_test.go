@@ -0,0 +1,50 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package scheduler
+
+import (
+	"testing"
+
+	"chainmaker.org/chainmaker-go/accesscontrol"
+	"chainmaker.org/chainmaker-go/consensus/dpos"
+	"chainmaker.org/chainmaker-go/core/crosschain"
+	"chainmaker.org/chainmaker/logger/v2"
+	commonpb "chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunVMReservedContractName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ReserveContractName("ADMIN_TEST")
+	require.True(t, IsReservedContractName("ADMIN_TEST"))
+	require.True(t, IsReservedContractName(crosschain.ContractName))
+	require.True(t, IsReservedContractName(dpos.VRFContractName))
+	require.True(t, IsReservedContractName(accesscontrol.DIDRegistryContractName))
+	require.False(t, IsReservedContractName("user_contract"))
+
+	// the vm is not run for the deployment of a reserved name
+	ts := &TxScheduler{VmManager: mock.NewMockVmManager(ctrl),
+		log: logger.GetLoggerByChain(logger.MODULE_CORE, "chain1")}
+	for _, name := range []string{"ADMIN_TEST", crosschain.ContractName} {
+		tx := &commonpb.Transaction{Payload: &commonpb.Payload{
+			TxType:       commonpb.TxType_INVOKE_CONTRACT,
+			ContractName: syscontract.SystemContract_CONTRACT_MANAGE.String(),
+			Method:       syscontract.ContractManageFunction_INIT_CONTRACT.String(),
+			Parameters: []*commonpb.KeyValuePair{
+				{Key: syscontract.InitContract_CONTRACT_NAME.String(), Value: []byte(name)},
+			},
+		}}
+		result, _, err := ts.runVM(tx, mock.NewMockTxSimContext(ctrl))
+		require.Error(t, err)
+		require.NotEqual(t, commonpb.TxStatusCode_SUCCESS, result.Code)
+		require.Contains(t, result.ContractResult.Message, "reserved")
+	}
+}
@@ -11,9 +11,13 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strings"
 	"sync"
 	"time"
 
+	"chainmaker.org/chainmaker-go/accesscontrol"
+	"chainmaker.org/chainmaker-go/consensus/dpos"
+	"chainmaker.org/chainmaker-go/core/crosschain"
 	"chainmaker.org/chainmaker-go/core/provider/conf"
 	"chainmaker.org/chainmaker/localconf/v2"
 	commonpb "chainmaker.org/chainmaker/pb-go/v2/common"
@@ -36,8 +40,9 @@ type TxScheduler struct {
 	log             protocol.Logger
 	chainConf       protocol.ChainConf // chain config
 
-	metricVMRunTime *prometheus.HistogramVec
-	StoreHelper     conf.StoreHelper
+	metricVMRunTime    *prometheus.HistogramVec
+	metricVMRunningTxs *prometheus.GaugeVec // the txs executing, to show the vm concurrency of the chain
+	StoreHelper        conf.StoreHelper
 }
 
 // Transaction dependency in adjacency table representation
@@ -283,6 +288,10 @@ func (ts *TxScheduler) SimulateWithDag(block *commonpb.Block, snapshot protocol.
 func (ts *TxScheduler) executeTx(tx *commonpb.Transaction, snapshot protocol.Snapshot, block *commonpb.Block) (
 	protocol.TxSimContext, protocol.ExecOrderTxType, bool) {
 	ts.log.Debugf("run vm start for tx:%s", tx.Payload.GetTxId())
+	if ts.metricVMRunningTxs != nil {
+		ts.metricVMRunningTxs.WithLabelValues(tx.Payload.ChainId).Inc()
+		defer ts.metricVMRunningTxs.WithLabelValues(tx.Payload.ChainId).Dec()
+	}
 	txSimContext := vm.NewTxSimContext(ts.VmManager, snapshot, tx, block.Header.BlockVersion)
 	ts.log.Debugf("new tx simulate context for tx:%s", tx.Payload.GetTxId())
 	runVmSuccess := true
@@ -384,7 +393,6 @@ func (ts *TxScheduler) runVM(tx *commonpb.Transaction, txSimContext protocol.TxS
 	*commonpb.Result, protocol.ExecOrderTxType, error) {
 	var contractName string
 	var method string
-	var byteCode []byte
 
 	result := &commonpb.Result{
 		Code: commonpb.TxStatusCode_SUCCESS,
@@ -413,20 +421,39 @@ func (ts *TxScheduler) runVM(tx *commonpb.Transaction, txSimContext protocol.TxS
 		)
 	}
 
-	contract, err := txSimContext.GetContractByName(contractName)
-	if err != nil {
-		ts.log.Errorf("Get contract info by name[%s] error:%s", contractName, err)
-		return errResult(result, err)
+	if name, ok := deployedContractName(contractName, method, parameters); ok && IsReservedContractName(name) {
+		return errResult(result, fmt.Errorf("contract name %s is reserved by the node", name))
 	}
-	if contract.RuntimeType != commonpb.RuntimeType_NATIVE {
-		byteCode, err = txSimContext.GetContractBytecode(contractName)
+
+	var (
+		contractResultPayload *commonpb.ContractResult
+		specialTxType         protocol.ExecOrderTxType
+		txStatusCode          commonpb.TxStatusCode
+	)
+	if contractName == crosschain.ContractName && payload.TxType == commonpb.TxType_INVOKE_CONTRACT {
+		// the cross-chain txs are executed by the node, which invoke the user contracts in turn
+		contractResultPayload, specialTxType, txStatusCode = crosschain.Execute(txSimContext, method, parameters,
+			ts.contractCaller(txSimContext, payload.TxType))
+	} else if contractName == dpos.VRFContractName && payload.TxType == commonpb.TxType_INVOKE_CONTRACT {
+		// the VRF proofs of the DPoS candidates are verified by the node
+		contractResultPayload, specialTxType, txStatusCode = dpos.ExecuteVRFProof(txSimContext, method, parameters)
+	} else if contractName == dpos.RewardContractName && payload.TxType == commonpb.TxType_INVOKE_CONTRACT {
+		// the reward withdrawals are checked by the node, the DPoS consensus moves the rewards of the txs succeeded
+		contractResultPayload, specialTxType, txStatusCode = dpos.ExecuteRewardWithdraw(txSimContext, method,
+			parameters)
+	} else if contractName == accesscontrol.DIDRegistryContractName &&
+		payload.TxType == commonpb.TxType_INVOKE_CONTRACT && ts.isDIDChain() {
+		// the DID documents and the revoked credentials of the chains identifying the members by the DIDs,
+		// the senders are authorized by the access control
+		contractResultPayload, specialTxType, txStatusCode = accesscontrol.ExecuteDIDRegistry(txSimContext, method,
+			parameters)
+	} else {
+		contractResultPayload, specialTxType, txStatusCode, err = ts.runContract(contractName, method, parameters,
+			txSimContext, payload.TxType)
 		if err != nil {
-			ts.log.Errorf("Get contract bytecode by name[%s] error:%s", contractName, err)
 			return errResult(result, err)
 		}
 	}
-	contractResultPayload, specialTxType, txStatusCode := ts.VmManager.RunContract(contract, method, byteCode,
-		parameters, txSimContext, 0, tx.Payload.TxType)
 
 	result.Code = txStatusCode
 	result.ContractResult = contractResultPayload
@@ -437,6 +464,48 @@ func (ts *TxScheduler) runVM(tx *commonpb.Transaction, txSimContext protocol.TxS
 	return result, specialTxType, errors.New(contractResultPayload.Message)
 }
 
+// runContract runs the method of the contract, the error is returned if the contract is not found
+// isDIDChain returns true if the chain identifies the members by the DIDs
+func (ts *TxScheduler) isDIDChain() bool {
+	return ts.chainConf != nil && strings.EqualFold(ts.chainConf.ChainConfig().AuthType, accesscontrol.AuthTypeDID)
+}
+
+func (ts *TxScheduler) runContract(contractName, method string, parameters map[string][]byte,
+	txSimContext protocol.TxSimContext, txType commonpb.TxType) (
+	*commonpb.ContractResult, protocol.ExecOrderTxType, commonpb.TxStatusCode, error) {
+	var byteCode []byte
+	contract, err := txSimContext.GetContractByName(contractName)
+	if err != nil {
+		ts.log.Errorf("Get contract info by name[%s] error:%s", contractName, err)
+		return nil, protocol.ExecOrderTxTypeNormal, commonpb.TxStatusCode_CONTRACT_FAIL, err
+	}
+	if contract.RuntimeType != commonpb.RuntimeType_NATIVE {
+		byteCode, err = txSimContext.GetContractBytecode(contractName)
+		if err != nil {
+			ts.log.Errorf("Get contract bytecode by name[%s] error:%s", contractName, err)
+			return nil, protocol.ExecOrderTxTypeNormal, commonpb.TxStatusCode_CONTRACT_FAIL, err
+		}
+	}
+	contractResult, specialTxType, txStatusCode := ts.VmManager.RunContract(contract, method, byteCode,
+		parameters, txSimContext, 0, txType)
+	return contractResult, specialTxType, txStatusCode, nil
+}
+
+// contractCaller returns the caller of the user contracts in the cross-chain txs
+func (ts *TxScheduler) contractCaller(txSimContext protocol.TxSimContext,
+	txType commonpb.TxType) crosschain.ContractCaller {
+	return func(contractName, method string, parameters map[string][]byte) (
+		*commonpb.ContractResult, protocol.ExecOrderTxType, commonpb.TxStatusCode) {
+		contractResult, specialTxType, txStatusCode, err := ts.runContract(contractName, method, parameters,
+			txSimContext, txType)
+		if err != nil {
+			return &commonpb.ContractResult{Code: 1, Message: err.Error()}, protocol.ExecOrderTxTypeNormal,
+				commonpb.TxStatusCode_CONTRACT_FAIL
+		}
+		return contractResult, specialTxType, txStatusCode
+	}
+}
+
 func errResult(result *commonpb.Result, err error) (*commonpb.Result, protocol.ExecOrderTxType, error) {
 	result.ContractResult.Message = err.Error()
 	result.Code = commonpb.TxStatusCode_INVALID_PARAMETER
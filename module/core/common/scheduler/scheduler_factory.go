@@ -14,6 +14,7 @@ import (
 	"chainmaker.org/chainmaker/localconf/v2"
 	"chainmaker.org/chainmaker/logger/v2"
 	"chainmaker.org/chainmaker/protocol/v2"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type TxSchedulerFactory struct {
@@ -43,6 +44,7 @@ func newTxScheduler(vmMgr protocol.VmManager, chainConf protocol.ChainConf, stor
 	if localconf.ChainMakerConfig.MonitorConfig.Enabled {
 		txScheduler.metricVMRunTime = monitor.NewHistogramVec(monitor.SUBSYSTEM_CORE_PROPOSER_SCHEDULER, "metric_vm_run_time",
 			"VM run time metric", []float64{0.005, 0.01, 0.015, 0.05, 0.1, 1, 10}, "chainId")
+		txScheduler.metricVMRunningTxs = newMetricVMRunningTxs()
 	}
 	return txScheduler
 }
@@ -71,6 +73,23 @@ func newTxSchedulerEvidence(vmMgr protocol.VmManager, chainConf protocol.ChainCo
 			[]float64{0.005, 0.01, 0.015, 0.05, 0.1, 1, 10},
 			"chainId",
 		)
+		txSchedulerEvidence.delegate.metricVMRunningTxs = newMetricVMRunningTxs()
 	}
 	return txSchedulerEvidence
 }
+
+var (
+	metricVMRunningTxsOnce sync.Once
+	metricVMRunningTxs     = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "chainmaker", Subsystem: monitor.SUBSYSTEM_CORE_PROPOSER_SCHEDULER, Name: "metric_vm_running_txs",
+		Help: "The number of txs executing in the vm",
+	}, []string{"chainId"})
+)
+
+// newMetricVMRunningTxs registers the gauge shared by the schedulers of all the chains once
+func newMetricVMRunningTxs() *prometheus.GaugeVec {
+	metricVMRunningTxsOnce.Do(func() {
+		prometheus.MustRegister(metricVMRunningTxs)
+	})
+	return metricVMRunningTxs
+}
@@ -9,6 +9,7 @@ package common
 import (
 	"runtime"
 
+	"chainmaker.org/chainmaker-go/core/provider/conf"
 	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
 
 	commonpb "chainmaker.org/chainmaker/pb-go/v2/common"
@@ -79,3 +80,21 @@ func (sql *SQLStoreHelper) BeginDbTransaction(blockchainStore protocol.Blockchai
 func (sql *SQLStoreHelper) GetPoolCapacity() int {
 	return 1
 }
+
+// PoolCapacityLimitedStoreHelper caps the capacity of the goroutine pool executing the txs,
+// so that the txs of a chain do not occupy all the CPUs shared with the other chains
+type PoolCapacityLimitedStoreHelper struct {
+	conf.StoreHelper
+	maxCapacity int
+}
+
+func NewPoolCapacityLimitedStoreHelper(helper conf.StoreHelper, maxCapacity int) *PoolCapacityLimitedStoreHelper {
+	return &PoolCapacityLimitedStoreHelper{StoreHelper: helper, maxCapacity: maxCapacity}
+}
+
+func (h *PoolCapacityLimitedStoreHelper) GetPoolCapacity() int {
+	if capacity := h.StoreHelper.GetPoolCapacity(); capacity < h.maxCapacity {
+		return capacity
+	}
+	return h.maxCapacity
+}
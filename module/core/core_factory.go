@@ -40,6 +40,9 @@ func (cf *coreEngineFactory) NewConsensusEngine(consensusType string,
 	} else {
 		storeHelper = common.NewKVStoreHelper(providerConf.ChainConf.ChainConfig().ChainId)
 	}
+	if providerConf.VMConcurrency > 0 {
+		storeHelper = common.NewPoolCapacityLimitedStoreHelper(storeHelper, providerConf.VMConcurrency)
+	}
 	providerConf.StoreHelper = storeHelper
 
 	return p.NewCoreEngine(providerConf)
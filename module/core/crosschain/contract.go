@@ -0,0 +1,238 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package crosschain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	configPb "chainmaker.org/chainmaker/pb-go/v2/config"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+// ContractCaller invokes the method of a contract in the tx executing
+type ContractCaller func(contractName, method string, params map[string][]byte) (
+	*commonPb.ContractResult, protocol.ExecOrderTxType, commonPb.TxStatusCode)
+
+// IsCrossChainTx returns true if the tx invokes ContractName, which should be validated by ValidateTx
+func IsCrossChainTx(tx *commonPb.Transaction) bool {
+	return tx.Payload != nil && tx.Payload.ContractName == ContractName &&
+		tx.Payload.TxType == commonPb.TxType_INVOKE_CONTRACT
+}
+
+// ValidateTx validates the DELIVER or ACK tx before it is packed into a block, the proposer drops the invalid txs
+// and the verifiers reject the blocks containing them. An invalid tx is never committed failed, since the tx id
+// is fixed by the message and a failed tx would take the id of the valid one forever. The trusted time is the time
+// of the last block of the chain, the certs in the proofs are verified at it.
+func ValidateTx(tx *commonPb.Transaction, chainConfig *configPb.ChainConfig, store protocol.BlockchainStore,
+	ac protocol.AccessControlProvider, trustedTime time.Time) error {
+	if err := checkSender(tx, chainConfig, ac); err != nil {
+		return err
+	}
+	params := make(map[string][]byte)
+	for _, param := range tx.Payload.Parameters {
+		params[param.Key] = param.Value
+	}
+	msg, err := ParseMessage(params[ParamMessage])
+	if err != nil {
+		return err
+	}
+	proof, err := ParseProof(params[ParamProof])
+	if err != nil {
+		return err
+	}
+	switch tx.Payload.Method {
+	case MethodDeliver:
+		return validateDeliver(tx, chainConfig, msg, proof, trustedTime)
+	case MethodAck:
+		return validateAck(tx, chainConfig, store, msg, proof, trustedTime)
+	default:
+		return fmt.Errorf("unknown method %s of %s", tx.Payload.Method, ContractName)
+	}
+}
+
+// validateDeliver verifies the message is sent by the source chain to the chain
+func validateDeliver(tx *commonPb.Transaction, chainConfig *configPb.ChainConfig, msg *Message, proof *Proof,
+	trustedTime time.Time) error {
+	if msg.DstChainId != chainConfig.ChainId {
+		return fmt.Errorf("the message is sent to chain %s", msg.DstChainId)
+	}
+	if tx.Payload.TxId != DeliverTxId(msg.Id) {
+		return fmt.Errorf("the tx id of the delivery of message %s should be %s", msg.Id, DeliverTxId(msg.Id))
+	}
+	remote, ok := LoadRemoteChain(chainConfig, msg.SrcChainId)
+	if !ok {
+		return fmt.Errorf("chain %s is not trusted", msg.SrcChainId)
+	}
+	_, srcTx, err := VerifyProof(proof, remote, trustedTime)
+	if err != nil {
+		return err
+	}
+	return checkMessage(msg, srcTx)
+}
+
+// validateAck verifies the message is sent by the chain and delivered by the destination chain. The message is
+// checked by the source tx in the ledger, as the failed deliveries are not verified by the destination chain.
+func validateAck(tx *commonPb.Transaction, chainConfig *configPb.ChainConfig, store protocol.BlockchainStore,
+	msg *Message, proof *Proof, trustedTime time.Time) error {
+	if msg.SrcChainId != chainConfig.ChainId {
+		return fmt.Errorf("the message is sent by chain %s", msg.SrcChainId)
+	}
+	if tx.Payload.TxId != AckTxId(msg.Id) {
+		return fmt.Errorf("the tx id of the ack of message %s should be %s", msg.Id, AckTxId(msg.Id))
+	}
+	srcTx, err := store.GetTx(msg.SrcTxId)
+	if err != nil {
+		return err
+	}
+	if srcTx == nil {
+		return fmt.Errorf("the source tx %s does not exist", msg.SrcTxId)
+	}
+	if err = checkMessage(msg, srcTx); err != nil {
+		return err
+	}
+	remote, ok := LoadRemoteChain(chainConfig, msg.DstChainId)
+	if !ok {
+		return fmt.Errorf("chain %s is not trusted", msg.DstChainId)
+	}
+	header, dstTx, err := VerifyProof(proof, remote, trustedTime)
+	if err != nil {
+		return err
+	}
+	if dstTx.Payload == nil || dstTx.Payload.ChainId != msg.DstChainId {
+		return fmt.Errorf("the tx proved is not of chain %s", msg.DstChainId)
+	}
+	_, err = ReceiptOf(msg.Id, header.BlockHeight, dstTx)
+	return err
+}
+
+// Execute executes the DELIVER and ACK txs of ContractName in the scheduler, which are validated by ValidateTx
+// before packed into the block, so the proofs are not verified again.
+func Execute(txSimContext protocol.TxSimContext, method string, params map[string][]byte, call ContractCaller) (
+	*commonPb.ContractResult, protocol.ExecOrderTxType, commonPb.TxStatusCode) {
+	msg, err := ParseMessage(params[ParamMessage])
+	if err != nil {
+		return failed(err)
+	}
+	proof, err := ParseProof(params[ParamProof])
+	if err != nil {
+		return failed(err)
+	}
+	switch method {
+	case MethodDeliver:
+		return deliver(msg, call)
+	case MethodAck:
+		return ack(txSimContext, msg, proof, call)
+	default:
+		return failed(fmt.Errorf("unknown method %s of %s", method, ContractName))
+	}
+}
+
+// deliver invokes the target contract with the message. The tx fails if the target contract fails, which is
+// acknowledged to the source chain as well.
+func deliver(msg *Message, call ContractCaller) (*commonPb.ContractResult, protocol.ExecOrderTxType,
+	commonPb.TxStatusCode) {
+	return call(msg.DstContract, msg.DstMethod, map[string][]byte{
+		ParamCallMessageId:   []byte(msg.Id),
+		ParamCallSrcChainId:  []byte(msg.SrcChainId),
+		ParamCallSrcContract: []byte(msg.SrcContract),
+		ParamCallPayload:     []byte(msg.Payload),
+	})
+}
+
+// ack records the receipt of the delivery and invokes the callback of the source contract
+func ack(txSimContext protocol.TxSimContext, msg *Message, proof *Proof, call ContractCaller) (
+	*commonPb.ContractResult, protocol.ExecOrderTxType, commonPb.TxStatusCode) {
+	if receipt, err := txSimContext.Get(ContractName, ReceiptKey(msg.Id)); err != nil {
+		return failed(err)
+	} else if len(receipt) > 0 {
+		return failed(fmt.Errorf("message %s is acknowledged already", msg.Id))
+	}
+	header, dstTx, err := proof.decode()
+	if err != nil {
+		return failed(err)
+	}
+	receipt, err := ReceiptOf(msg.Id, header.BlockHeight, dstTx)
+	if err != nil {
+		return failed(err)
+	}
+	receiptBytes, err := json.Marshal(receipt)
+	if err != nil {
+		return failed(err)
+	}
+	if err = txSimContext.Put(ContractName, ReceiptKey(msg.Id), receiptBytes); err != nil {
+		return failed(err)
+	}
+	if msg.CallbackMethod == "" {
+		return &commonPb.ContractResult{Result: receiptBytes}, protocol.ExecOrderTxTypeNormal,
+			commonPb.TxStatusCode_SUCCESS
+	}
+	return call(msg.SrcContract, msg.CallbackMethod, map[string][]byte{
+		ParamCallMessageId:  []byte(msg.Id),
+		ParamCallDstChainId: []byte(msg.DstChainId),
+		ParamCallSuccess:    []byte(strconv.FormatBool(receipt.Success)),
+		ParamCallResult:     receipt.Result,
+	})
+}
+
+// ReceiptOf returns the receipt of the message by its DELIVER tx committed in the block of the height
+func ReceiptOf(messageId string, blockHeight uint64, dstTx *commonPb.Transaction) (*Receipt, error) {
+	if dstTx.Payload == nil || dstTx.Payload.ContractName != ContractName ||
+		dstTx.Payload.Method != MethodDeliver || dstTx.Payload.TxId != DeliverTxId(messageId) {
+		return nil, fmt.Errorf("the tx is not the delivery of message %s", messageId)
+	}
+	if dstTx.Result == nil {
+		return nil, errors.New("the delivery has no result")
+	}
+	receipt := &Receipt{
+		MessageId:      messageId,
+		DstChainId:     dstTx.Payload.ChainId,
+		DstBlockHeight: blockHeight,
+		DstTxId:        dstTx.Payload.TxId,
+		Success:        dstTx.Result.Code == commonPb.TxStatusCode_SUCCESS,
+	}
+	if dstTx.Result.ContractResult != nil {
+		receipt.Result = dstTx.Result.ContractResult.Result
+		receipt.Message = dstTx.Result.ContractResult.Message
+	}
+	return receipt, nil
+}
+
+// checkSender checks the sender of the tx is a consensus node or a relayer of the orgs running the consensus
+func checkSender(tx *commonPb.Transaction, chainConfig *configPb.ChainConfig,
+	ac protocol.AccessControlProvider) error {
+	if tx.Sender == nil || tx.Sender.Signer == nil {
+		return errors.New("sender is required")
+	}
+	member, err := ac.NewMember(tx.Sender.Signer)
+	if err != nil {
+		return err
+	}
+	consensusOrg := false
+	for _, node := range chainConfig.Consensus.Nodes {
+		if node.OrgId == member.GetOrgId() {
+			consensusOrg = true
+			break
+		}
+	}
+	if !consensusOrg {
+		return fmt.Errorf("org %s of the sender does not run the consensus", member.GetOrgId())
+	}
+	if member.GetRole() != protocol.RoleConsensusNode && !relayers(chainConfig)[member.GetMemberId()] {
+		return fmt.Errorf("the sender %s is neither a consensus node nor a relayer", member.GetMemberId())
+	}
+	return nil
+}
+
+func failed(err error) (*commonPb.ContractResult, protocol.ExecOrderTxType, commonPb.TxStatusCode) {
+	return &commonPb.ContractResult{Code: 1, Message: err.Error()}, protocol.ExecOrderTxTypeNormal,
+		commonPb.TxStatusCode_CONTRACT_FAIL
+}
@@ -0,0 +1,263 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package crosschain relays the messages between the chains hosted by the same node. A contract on the source
+// chain emits an event of EventTopicMessage, the node relays it to the destination chain by a DELIVER tx carrying
+// the proof of the source block, which invokes the target contract. The result of the delivery is relayed back to
+// the source chain by an ACK tx carrying the proof of the destination block, which records the receipt and
+// invokes the callback of the source contract.
+package crosschain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	configPb "chainmaker.org/chainmaker/pb-go/v2/config"
+)
+
+const (
+	// ContractName is the contract name of the cross-chain txs and queries, the txs are executed by the node
+	ContractName = "CROSS_CHAIN"
+	// MethodDeliver delivers a message to the target contract on the destination chain
+	MethodDeliver = "DELIVER"
+	// MethodAck acknowledges the delivery of a message on the source chain
+	MethodAck = "ACK"
+	// MethodGetReceipt queries the receipt of a message on the source chain
+	MethodGetReceipt = "GET_RECEIPT"
+	// MethodGetDelivery queries the delivery of a message on the destination chain
+	MethodGetDelivery = "GET_DELIVERY"
+
+	// ParamMessage is the message param of the DELIVER and ACK txs
+	ParamMessage = "message"
+	// ParamProof is the proof param of the DELIVER and ACK txs
+	ParamProof = "proof"
+	// ParamMessageId is the message id param of the queries
+	ParamMessageId = "message_id"
+
+	// EventTopicMessage is the topic of the events emitted by the source contracts to send messages,
+	// the event data is [dst_chain_id, dst_contract, dst_method, payload] with an optional callback_method
+	EventTopicMessage = "CROSS_CHAIN_MESSAGE"
+
+	// the params of the target contracts invoked by the DELIVER txs
+	ParamCallMessageId   = "cross_chain_message_id"
+	ParamCallSrcChainId  = "cross_chain_src_chain_id"
+	ParamCallSrcContract = "cross_chain_src_contract"
+	ParamCallPayload     = "cross_chain_payload"
+	// the params of the callbacks of the source contracts invoked by the ACK txs
+	ParamCallDstChainId = "cross_chain_dst_chain_id"
+	ParamCallSuccess    = "cross_chain_success"
+	ParamCallResult     = "cross_chain_result"
+
+	keyReceiptPrefix = "RECEIPT_"
+)
+
+// keys of the chains trusted by the chain in chain config consensus.ext_config, the messages of a chain are
+// accepted only if its consensus orgs and nodes are set.
+const (
+	// ConsensusOrgsKeyTemplate is the key of the orgs running the consensus of the chain, separated by commas
+	ConsensusOrgsKeyTemplate = "cross_chain.%s.consensus_orgs"
+	// ConsensusNodeKeyTemplate is the key of the PEM of the sign cert of a consensus node of the chain by its node id,
+	// the blocks of the chain are trusted if more than 2/3 of the nodes set precommit them
+	ConsensusNodeKeyTemplate = "cross_chain.%s.consensus_node.%s"
+	// TrustRootKeyTemplate is the key of the PEM of the CA certs of an org running the consensus of the chain
+	TrustRootKeyTemplate = "cross_chain.%s.trust_root.%s"
+	// HashTypeKeyTemplate is the key of the hash algorithm of the chain, SHA256 by default
+	HashTypeKeyTemplate = "cross_chain.%s.hash_type"
+	// RelayersKey is the key of the member ids of the relayers sending the cross-chain txs besides the consensus
+	// nodes, separated by commas, the relayers should be members of the orgs running the consensus of the chain
+	RelayersKey = "cross_chain.relayers"
+
+	defaultHashType = "SHA256"
+)
+
+// Message is a message sent by a contract of the source chain to a contract of the destination chain.
+type Message struct {
+	// Id identifies the message by the source chain, tx and event
+	Id          string `json:"id"`
+	SrcChainId  string `json:"src_chain_id"`
+	SrcTxId     string `json:"src_tx_id"`
+	SrcContract string `json:"src_contract"`
+	// EventIndex is the index of the event in the contract events of the source tx
+	EventIndex     int    `json:"event_index"`
+	DstChainId     string `json:"dst_chain_id"`
+	DstContract    string `json:"dst_contract"`
+	DstMethod      string `json:"dst_method"`
+	Payload        string `json:"payload"`
+	CallbackMethod string `json:"callback_method,omitempty"`
+}
+
+// Receipt is the result of the delivery of a message, recorded on the source chain by the ACK tx.
+type Receipt struct {
+	MessageId      string `json:"message_id"`
+	DstChainId     string `json:"dst_chain_id"`
+	DstBlockHeight uint64 `json:"dst_block_height"`
+	DstTxId        string `json:"dst_tx_id"`
+	Success        bool   `json:"success"`
+	Result         []byte `json:"result,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+// MessageId returns the id of the message sent by the event of the source tx
+func MessageId(srcChainId, srcTxId string, eventIndex int) string {
+	h := sha256.Sum256([]byte(srcChainId + "/" + srcTxId + "/" + strconv.Itoa(eventIndex)))
+	return hex.EncodeToString(h[:])
+}
+
+// DeliverTxId returns the id of the DELIVER tx of the message. A message is delivered by one tx only,
+// which could not be replayed as its id exists in the destination chain then, whether it succeeds or fails.
+func DeliverTxId(messageId string) string {
+	h := sha256.Sum256([]byte(MethodDeliver + "/" + messageId))
+	return hex.EncodeToString(h[:])
+}
+
+// AckTxId returns the id of the ACK tx of the message, a message is acknowledged by one tx only
+func AckTxId(messageId string) string {
+	h := sha256.Sum256([]byte(MethodAck + "/" + messageId))
+	return hex.EncodeToString(h[:])
+}
+
+// ReceiptKey returns the key of the receipt of the message in the state of ContractName
+func ReceiptKey(messageId string) []byte {
+	return []byte(keyReceiptPrefix + messageId)
+}
+
+// MessagesOf returns the messages sent by the events of the tx, none if the tx failed
+func MessagesOf(chainId string, tx *commonPb.Transaction) []*Message {
+	if tx.Payload == nil || tx.Result == nil || tx.Result.Code != commonPb.TxStatusCode_SUCCESS ||
+		tx.Result.ContractResult == nil {
+		return nil
+	}
+	var messages []*Message
+	for i, event := range tx.Result.ContractResult.ContractEvent {
+		if msg, ok := messageOf(chainId, tx.Payload.TxId, i, event); ok {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// messageOf returns the message sent by the event, false if it is not a valid message event
+func messageOf(chainId, txId string, index int, event *commonPb.ContractEvent) (*Message, bool) {
+	if event == nil || event.Topic != EventTopicMessage || len(event.EventData) < 4 {
+		return nil, false
+	}
+	msg := &Message{
+		Id:          MessageId(chainId, txId, index),
+		SrcChainId:  chainId,
+		SrcTxId:     txId,
+		SrcContract: event.ContractName,
+		EventIndex:  index,
+		DstChainId:  event.EventData[0],
+		DstContract: event.EventData[1],
+		DstMethod:   event.EventData[2],
+		Payload:     event.EventData[3],
+	}
+	if len(event.EventData) > 4 {
+		msg.CallbackMethod = event.EventData[4]
+	}
+	if msg.DstChainId == "" || msg.DstContract == "" || msg.DstMethod == "" || msg.DstChainId == chainId {
+		return nil, false
+	}
+	return msg, true
+}
+
+// checkMessage checks the message is sent by the event of its index of the source tx
+func checkMessage(msg *Message, tx *commonPb.Transaction) error {
+	if tx.Payload == nil || tx.Payload.ChainId != msg.SrcChainId || tx.Payload.TxId != msg.SrcTxId {
+		return fmt.Errorf("the source tx is not %s of chain %s", msg.SrcTxId, msg.SrcChainId)
+	}
+	if tx.Result == nil || tx.Result.Code != commonPb.TxStatusCode_SUCCESS || tx.Result.ContractResult == nil {
+		return fmt.Errorf("the source tx %s failed", msg.SrcTxId)
+	}
+	events := tx.Result.ContractResult.ContractEvent
+	if msg.EventIndex < 0 || msg.EventIndex >= len(events) {
+		return fmt.Errorf("the event %d of the source tx %s does not exist", msg.EventIndex, msg.SrcTxId)
+	}
+	expected, ok := messageOf(msg.SrcChainId, msg.SrcTxId, msg.EventIndex, events[msg.EventIndex])
+	if !ok || *expected != *msg {
+		return fmt.Errorf("the message %s mismatches the event %d of the source tx %s",
+			msg.Id, msg.EventIndex, msg.SrcTxId)
+	}
+	return nil
+}
+
+// ParseMessage parses the message param of the txs
+func ParseMessage(bz []byte) (*Message, error) {
+	msg := &Message{}
+	if err := json.Unmarshal(bz, msg); err != nil {
+		return nil, fmt.Errorf("invalid %s, %v", ParamMessage, err)
+	}
+	if msg.Id != MessageId(msg.SrcChainId, msg.SrcTxId, msg.EventIndex) {
+		return nil, fmt.Errorf("invalid %s, the id mismatches the source tx", ParamMessage)
+	}
+	return msg, nil
+}
+
+// RemoteChain is the consensus set of another chain trusted by the chain, set in its consensus.ext_config.
+type RemoteChain struct {
+	ChainId  string
+	HashType string
+	// Orgs is the orgs running the consensus of the chain
+	Orgs map[string]bool
+	// Nodes is the PEM of the sign certs of the consensus nodes of the chain by the node ids
+	Nodes map[string][]byte
+	// TrustRoots is the PEM of the CA certs of the orgs
+	TrustRoots map[string][]byte
+}
+
+// LoadRemoteChain loads the consensus set of the remote chain from the chain config, false if it is not trusted
+func LoadRemoteChain(chainConfig *configPb.ChainConfig, chainId string) (*RemoteChain, bool) {
+	if chainConfig == nil || chainConfig.Consensus == nil {
+		return nil, false
+	}
+	remote := &RemoteChain{ChainId: chainId, HashType: defaultHashType, Orgs: make(map[string]bool),
+		Nodes: make(map[string][]byte), TrustRoots: make(map[string][]byte)}
+	orgsKey := fmt.Sprintf(ConsensusOrgsKeyTemplate, chainId)
+	nodePrefix := fmt.Sprintf(ConsensusNodeKeyTemplate, chainId, "")
+	trustRootPrefix := fmt.Sprintf(TrustRootKeyTemplate, chainId, "")
+	hashTypeKey := fmt.Sprintf(HashTypeKeyTemplate, chainId)
+	for _, kv := range chainConfig.Consensus.ExtConfig {
+		switch {
+		case kv.Key == orgsKey:
+			for _, org := range strings.Split(string(kv.Value), ",") {
+				if org = strings.TrimSpace(org); org != "" {
+					remote.Orgs[org] = true
+				}
+			}
+		case kv.Key == hashTypeKey:
+			remote.HashType = string(kv.Value)
+		case strings.HasPrefix(kv.Key, nodePrefix):
+			remote.Nodes[strings.TrimPrefix(kv.Key, nodePrefix)] = kv.Value
+		case strings.HasPrefix(kv.Key, trustRootPrefix):
+			remote.TrustRoots[strings.TrimPrefix(kv.Key, trustRootPrefix)] = kv.Value
+		}
+	}
+	return remote, len(remote.Orgs) > 0 && len(remote.Nodes) > 0
+}
+
+// relayers returns the member ids of the relayers set in the chain config
+func relayers(chainConfig *configPb.ChainConfig) map[string]bool {
+	relayers := make(map[string]bool)
+	if chainConfig.Consensus == nil {
+		return relayers
+	}
+	for _, kv := range chainConfig.Consensus.ExtConfig {
+		if kv.Key != RelayersKey {
+			continue
+		}
+		for _, relayer := range strings.Split(string(kv.Value), ",") {
+			if relayer = strings.TrimSpace(relayer); relayer != "" {
+				relayers[relayer] = true
+			}
+		}
+	}
+	return relayers
+}
@@ -0,0 +1,523 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package crosschain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker/common/v2/crypto/hash"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	configPb "chainmaker.org/chainmaker/pb-go/v2/config"
+	tbftpb "chainmaker.org/chainmaker/pb-go/v2/consensus/tbft"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/protocol/v2/mock"
+	"chainmaker.org/chainmaker/utils/v2"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func newMessageTx(chainId, txId string, events ...*commonPb.ContractEvent) *commonPb.Transaction {
+	return &commonPb.Transaction{
+		Payload: &commonPb.Payload{ChainId: chainId, TxId: txId, ContractName: "src"},
+		Result: &commonPb.Result{
+			Code:           commonPb.TxStatusCode_SUCCESS,
+			ContractResult: &commonPb.ContractResult{ContractEvent: events},
+		},
+	}
+}
+
+func TestMessagesOf(t *testing.T) {
+	tx := newMessageTx("chain1", "tx1",
+		&commonPb.ContractEvent{Topic: "other", ContractName: "src", EventData: []string{"chain2", "dst", "m", "p"}},
+		&commonPb.ContractEvent{Topic: EventTopicMessage, ContractName: "src",
+			EventData: []string{"chain2", "dst", "receive", "hello", "on_ack"}},
+		&commonPb.ContractEvent{Topic: EventTopicMessage, ContractName: "src",
+			EventData: []string{"chain1", "dst", "receive", "to itself"}},
+		&commonPb.ContractEvent{Topic: EventTopicMessage, ContractName: "src", EventData: []string{"chain2", "dst"}},
+	)
+	messages := MessagesOf("chain1", tx)
+	require.Len(t, messages, 1)
+	msg := messages[0]
+	require.Equal(t, MessageId("chain1", "tx1", 1), msg.Id)
+	require.Equal(t, 1, msg.EventIndex)
+	require.Equal(t, "chain2", msg.DstChainId)
+	require.Equal(t, "receive", msg.DstMethod)
+	require.Equal(t, "hello", msg.Payload)
+	require.Equal(t, "on_ack", msg.CallbackMethod)
+	require.NoError(t, checkMessage(msg, tx))
+
+	// the forged messages are rejected
+	forged := *msg
+	forged.Payload = "forged"
+	require.Error(t, checkMessage(&forged, tx))
+
+	// no messages are sent by the failed txs
+	tx.Result.Code = commonPb.TxStatusCode_CONTRACT_FAIL
+	require.Empty(t, MessagesOf("chain1", tx))
+	require.Error(t, checkMessage(msg, tx))
+}
+
+func TestParseMessage(t *testing.T) {
+	msg := &Message{SrcChainId: "chain1", SrcTxId: "tx1", EventIndex: 2, DstChainId: "chain2"}
+	msg.Id = MessageId(msg.SrcChainId, msg.SrcTxId, msg.EventIndex)
+	bz, err := json.Marshal(msg)
+	require.NoError(t, err)
+	parsed, err := ParseMessage(bz)
+	require.NoError(t, err)
+	require.Equal(t, msg, parsed)
+
+	msg.EventIndex = 3
+	bz, err = json.Marshal(msg)
+	require.NoError(t, err)
+	_, err = ParseMessage(bz)
+	require.Error(t, err)
+
+	require.NotEqual(t, DeliverTxId(msg.Id), AckTxId(msg.Id))
+}
+
+// testNode is a consensus node of a remote chain signing the precommits
+type testNode struct {
+	id      string
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+// testChain is a chain running TBFT by the consensus nodes of an org, whose blocks are proved to the others
+type testChain struct {
+	chainId string
+	org     string
+	caCert  *x509.Certificate
+	caKey   *ecdsa.PrivateKey
+	nodes   []*testNode
+}
+
+func newTestChain(t *testing.T, chainId, org string, size int) *testChain {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca." + org, Organization: []string{org}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	chain := &testChain{chainId: chainId, org: org, caCert: caCert, caKey: key}
+	for i := 0; i < size; i++ {
+		chain.nodes = append(chain.nodes, chain.issue(t, fmt.Sprintf("%s-node%d", chainId, i),
+			string(protocol.RoleConsensusNode), time.Now().Add(time.Hour)))
+	}
+	return chain
+}
+
+// issue issues a cert of the org to the node, which is not a consensus node of the chain unless appended
+func (c *testChain) issue(t *testing.T, id, ou string, notAfter time.Time) *testNode {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{CommonName: id, Organization: []string{c.org},
+			OrganizationalUnit: []string{ou}},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  notAfter,
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.caCert, &key.PublicKey, c.caKey)
+	require.NoError(t, err)
+	return &testNode{id: id, key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// trustConfig returns the ext config of the chains trusting the chain
+func (c *testChain) trustConfig() []*commonPb.KeyValuePair {
+	kvs := []*commonPb.KeyValuePair{
+		{Key: fmt.Sprintf(ConsensusOrgsKeyTemplate, c.chainId), Value: []byte(c.org)},
+		{Key: fmt.Sprintf(TrustRootKeyTemplate, c.chainId, c.org),
+			Value: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.caCert.Raw})},
+	}
+	for _, node := range c.nodes {
+		kvs = append(kvs, &commonPb.KeyValuePair{Key: fmt.Sprintf(ConsensusNodeKeyTemplate, c.chainId, node.id),
+			Value: node.certPEM})
+	}
+	return kvs
+}
+
+func (c *testChain) remote(t *testing.T) *RemoteChain {
+	remote, ok := LoadRemoteChain(&configPb.ChainConfig{Consensus: &configPb.ConsensusConfig{
+		ExtConfig: c.trustConfig()}}, c.chainId)
+	require.True(t, ok)
+	return remote
+}
+
+// commit builds the block of the txs at the height, precommitted by the voters
+func (c *testChain) commit(t *testing.T, height uint64, txs []*commonPb.Transaction,
+	voters ...*testNode) *commonPb.Block {
+	block := &commonPb.Block{
+		Header: &commonPb.BlockHeader{ChainId: c.chainId, BlockHeight: height, TxCount: uint32(len(txs))},
+		Txs:    txs,
+	}
+	txHashes := make([][]byte, len(txs))
+	for i, tx := range txs {
+		txHash, err := utils.CalcTxHash(defaultHashType, tx)
+		require.NoError(t, err)
+		txHashes[i] = txHash
+	}
+	txRoot, err := hash.GetMerkleRoot(defaultHashType, txHashes)
+	require.NoError(t, err)
+	block.Header.TxRoot = txRoot
+	block.Header.BlockHash, err = utils.CalcBlockHash(defaultHashType, block)
+	require.NoError(t, err)
+
+	voteSet := &tbftpb.VoteSet{Type: tbftpb.VoteType_VOTE_PRECOMMIT, Height: height,
+		Votes: make(map[string]*tbftpb.Vote)}
+	for _, voter := range voters {
+		vote := &tbftpb.Vote{Type: tbftpb.VoteType_VOTE_PRECOMMIT, Voter: voter.id, Height: height,
+			Hash: block.Header.BlockHash}
+		message, err := proto.Marshal(vote)
+		require.NoError(t, err)
+		digest := sha256.Sum256(message)
+		signature, err := ecdsa.SignASN1(rand.Reader, voter.key, digest[:])
+		require.NoError(t, err)
+		vote.Endorsement = &commonPb.EndorsementEntry{
+			Signer:    &pbac.Member{OrgId: c.org, MemberType: pbac.MemberType_CERT, MemberInfo: voter.certPEM},
+			Signature: signature,
+		}
+		voteSet.Votes[voter.id] = vote
+	}
+	quorumCert, err := proto.Marshal(voteSet)
+	require.NoError(t, err)
+	block.AdditionalData = &commonPb.AdditionalData{
+		ExtraData: map[string][]byte{protocol.TBFTAddtionalDataKey: quorumCert}}
+	return block
+}
+
+func TestLoadRemoteChain(t *testing.T) {
+	chainConfig := &configPb.ChainConfig{Consensus: &configPb.ConsensusConfig{ExtConfig: []*commonPb.KeyValuePair{
+		{Key: fmt.Sprintf(ConsensusOrgsKeyTemplate, "chain2"), Value: []byte("org1, org2")},
+		{Key: fmt.Sprintf(ConsensusNodeKeyTemplate, "chain2", "node1"), Value: []byte("cert1")},
+		{Key: fmt.Sprintf(TrustRootKeyTemplate, "chain2", "org1"), Value: []byte("root1")},
+		{Key: fmt.Sprintf(HashTypeKeyTemplate, "chain2"), Value: []byte("SM3")},
+		{Key: fmt.Sprintf(ConsensusOrgsKeyTemplate, "chain3"), Value: []byte("org3")},
+		{Key: fmt.Sprintf(ConsensusNodeKeyTemplate, "chain3", "node3"), Value: []byte("cert3")},
+		{Key: fmt.Sprintf(ConsensusOrgsKeyTemplate, "chain4"), Value: []byte("org4")},
+		{Key: RelayersKey, Value: []byte("relayer1, relayer2")},
+	}}}
+	remote, ok := LoadRemoteChain(chainConfig, "chain2")
+	require.True(t, ok)
+	require.Equal(t, "SM3", remote.HashType)
+	require.Equal(t, map[string]bool{"org1": true, "org2": true}, remote.Orgs)
+	require.Equal(t, map[string][]byte{"node1": []byte("cert1")}, remote.Nodes)
+	require.Equal(t, map[string][]byte{"org1": []byte("root1")}, remote.TrustRoots)
+
+	remote, ok = LoadRemoteChain(chainConfig, "chain3")
+	require.True(t, ok)
+	require.Equal(t, defaultHashType, remote.HashType)
+
+	// the chains without the consensus nodes are not trusted
+	_, ok = LoadRemoteChain(chainConfig, "chain4")
+	require.False(t, ok)
+	_, ok = LoadRemoteChain(chainConfig, "chain5")
+	require.False(t, ok)
+
+	require.Equal(t, map[string]bool{"relayer1": true, "relayer2": true}, relayers(chainConfig))
+}
+
+func TestVerifyProof(t *testing.T) {
+	chain := newTestChain(t, "chain1", "org1", 4)
+	remote := chain.remote(t)
+	txs := []*commonPb.Transaction{
+		newMessageTx("chain1", "tx0"),
+		newMessageTx("chain1", "tx1"),
+		newMessageTx("chain1", "tx2"),
+	}
+	now := time.Now()
+
+	// the block precommitted by 3 of 4 nodes is trusted
+	block := chain.commit(t, 10, txs, chain.nodes[:3]...)
+	proof, err := BuildProof(block, 1, defaultHashType)
+	require.NoError(t, err)
+	header, tx, err := VerifyProof(proof, remote, now)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), header.BlockHeight)
+	require.Equal(t, "tx1", tx.Payload.TxId)
+
+	// the certs are verified at the trusted time rather than the time of the block
+	_, _, err = VerifyProof(proof, remote, now.Add(2*time.Hour))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "quorum cert")
+
+	// 2 of 4 nodes are not the quorum
+	proof, err = BuildProof(chain.commit(t, 10, txs, chain.nodes[:2]...), 1, defaultHashType)
+	require.NoError(t, err)
+	_, _, err = VerifyProof(proof, remote, now)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "2 valid precommits of 4")
+
+	// the votes of the nodes not set, or by other certs of the nodes, are not counted
+	for _, node := range []*testNode{
+		chain.issue(t, "chain1-node9", string(protocol.RoleConsensusNode), now.Add(time.Hour)),
+		chain.issue(t, chain.nodes[2].id, string(protocol.RoleConsensusNode), now.Add(time.Hour)),
+	} {
+		block = chain.commit(t, 10, txs, chain.nodes[0], chain.nodes[1], node)
+		proof, err = BuildProof(block, 1, defaultHashType)
+		require.NoError(t, err)
+		_, _, err = VerifyProof(proof, remote, now)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "2 valid precommits of 4")
+	}
+
+	// the votes of the certs set but not of the consensus nodes are not counted
+	client := chain.issue(t, chain.nodes[2].id, string(protocol.RoleClient), now.Add(time.Hour))
+	proof, err = BuildProof(chain.commit(t, 10, txs, chain.nodes[0], chain.nodes[1], client), 1, defaultHashType)
+	require.NoError(t, err)
+	clientRemote := chain.remote(t)
+	clientRemote.Nodes[client.id] = client.certPEM
+	_, _, err = VerifyProof(proof, clientRemote, now)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not of a consensus node")
+
+	// the votes of another block are not counted
+	block = chain.commit(t, 10, txs, chain.nodes...)
+	other := chain.commit(t, 10, txs[:2], chain.nodes...)
+	proof, err = BuildProof(block, 1, defaultHashType)
+	require.NoError(t, err)
+	proof.QuorumCert = other.AdditionalData.ExtraData[protocol.TBFTAddtionalDataKey]
+	_, _, err = VerifyProof(proof, remote, now)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "0 valid precommits")
+
+	// the tx not in the block is rejected
+	forged, err := BuildProof(&commonPb.Block{Header: block.Header, AdditionalData: block.AdditionalData,
+		Txs: []*commonPb.Transaction{txs[0], newMessageTx("chain1", "forged"), txs[2]}}, 1, defaultHashType)
+	require.NoError(t, err)
+	_, _, err = VerifyProof(forged, remote, now)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "tx root")
+
+	// the block of another chain is rejected
+	other = newTestChain(t, "chain2", "org2", 4).commit(t, 10, txs, chain.nodes...)
+	proof, err = BuildProof(other, 1, defaultHashType)
+	require.NoError(t, err)
+	_, _, err = VerifyProof(proof, remote, now)
+	require.Error(t, err)
+
+	// the blocks without the quorum cert of TBFT could not be proved
+	_, err = BuildProof(&commonPb.Block{Header: block.Header, Txs: txs}, 1, defaultHashType)
+	require.Error(t, err)
+	_, err = BuildProof(block, 3, defaultHashType)
+	require.Error(t, err)
+}
+
+// newTestAC returns the access control creating the senders of the org, role and member id
+func newTestAC(ctrl *gomock.Controller, org string, role protocol.Role,
+	memberId string) protocol.AccessControlProvider {
+	member := mock.NewMockMember(ctrl)
+	member.EXPECT().GetOrgId().AnyTimes().Return(org)
+	member.EXPECT().GetRole().AnyTimes().Return(role)
+	member.EXPECT().GetMemberId().AnyTimes().Return(memberId)
+	ac := mock.NewMockAccessControlProvider(ctrl)
+	ac.EXPECT().NewMember(gomock.Any()).AnyTimes().Return(member, nil)
+	return ac
+}
+
+// newTestTxSimContext returns the tx sim context on the state
+func newTestTxSimContext(ctrl *gomock.Controller, state map[string][]byte) protocol.TxSimContext {
+	txSimContext := mock.NewMockTxSimContext(ctrl)
+	txSimContext.EXPECT().Get(ContractName, gomock.Any()).AnyTimes().DoAndReturn(
+		func(name string, key []byte) ([]byte, error) {
+			return state[string(key)], nil
+		})
+	txSimContext.EXPECT().Put(ContractName, gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(name string, key, value []byte) error {
+			state[string(key)] = value
+			return nil
+		})
+	return txSimContext
+}
+
+func newCrossChainTx(t *testing.T, chainId, txId, method string, msg *Message, proof *Proof) *commonPb.Transaction {
+	msgBytes, err := json.Marshal(msg)
+	require.NoError(t, err)
+	proofBytes, err := json.Marshal(proof)
+	require.NoError(t, err)
+	return &commonPb.Transaction{
+		Payload: &commonPb.Payload{ChainId: chainId, TxType: commonPb.TxType_INVOKE_CONTRACT, TxId: txId,
+			ContractName: ContractName, Method: method, Parameters: []*commonPb.KeyValuePair{
+				{Key: ParamMessage, Value: msgBytes},
+				{Key: ParamProof, Value: proofBytes},
+			}},
+		Sender: &commonPb.EndorsementEntry{Signer: &pbac.Member{OrgId: "org"}},
+	}
+}
+
+func paramsOf(tx *commonPb.Transaction) map[string][]byte {
+	params := make(map[string][]byte)
+	for _, param := range tx.Payload.Parameters {
+		params[param.Key] = param.Value
+	}
+	return params
+}
+
+// contractCall is a call of the contracts by the cross-chain txs
+type contractCall struct {
+	contractName, method string
+	params               map[string][]byte
+}
+
+func newTestCaller(calls *[]contractCall, code commonPb.TxStatusCode, result []byte) ContractCaller {
+	return func(contractName, method string, params map[string][]byte) (
+		*commonPb.ContractResult, protocol.ExecOrderTxType, commonPb.TxStatusCode) {
+		*calls = append(*calls, contractCall{contractName: contractName, method: method, params: params})
+		return &commonPb.ContractResult{Result: result}, protocol.ExecOrderTxTypeNormal, code
+	}
+}
+
+func TestDeliverAndAck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	now := time.Now()
+	chain1 := newTestChain(t, "chain1", "org1", 4)
+	chain2 := newTestChain(t, "chain2", "org2", 4)
+	chainConfig1 := &configPb.ChainConfig{ChainId: "chain1", Consensus: &configPb.ConsensusConfig{
+		Nodes: []*configPb.OrgConfig{{OrgId: "org1"}}, ExtConfig: chain2.trustConfig()}}
+	chainConfig2 := &configPb.ChainConfig{ChainId: "chain2", Consensus: &configPb.ConsensusConfig{
+		Nodes: []*configPb.OrgConfig{{OrgId: "org2"}}, ExtConfig: chain1.trustConfig()}}
+	ac1 := newTestAC(ctrl, "org1", protocol.RoleConsensusNode, "node1")
+	ac2 := newTestAC(ctrl, "org2", protocol.RoleConsensusNode, "node2")
+
+	// the source contract sends the message on chain1
+	srcTx := newMessageTx("chain1", "tx1", &commonPb.ContractEvent{Topic: EventTopicMessage, ContractName: "src",
+		EventData: []string{"chain2", "dst", "receive", "hello", "on_ack"}})
+	messages := MessagesOf("chain1", srcTx)
+	require.Len(t, messages, 1)
+	msg := messages[0]
+	srcProof, err := BuildProof(chain1.commit(t, 5, []*commonPb.Transaction{srcTx}, chain1.nodes...), 0,
+		defaultHashType)
+	require.NoError(t, err)
+
+	// the message is delivered to the target contract on chain2
+	deliverTx := newCrossChainTx(t, "chain2", DeliverTxId(msg.Id), MethodDeliver, msg, srcProof)
+	require.True(t, IsCrossChainTx(deliverTx))
+	require.NoError(t, ValidateTx(deliverTx, chainConfig2, nil, ac2, now))
+	var calls []contractCall
+	result, _, code := Execute(newTestTxSimContext(ctrl, map[string][]byte{}), MethodDeliver, paramsOf(deliverTx),
+		newTestCaller(&calls, commonPb.TxStatusCode_SUCCESS, []byte("received")))
+	require.Equal(t, commonPb.TxStatusCode_SUCCESS, code)
+	require.Equal(t, []byte("received"), result.Result)
+	require.Equal(t, []contractCall{{contractName: "dst", method: "receive", params: map[string][]byte{
+		ParamCallMessageId:   []byte(msg.Id),
+		ParamCallSrcChainId:  []byte("chain1"),
+		ParamCallSrcContract: []byte("src"),
+		ParamCallPayload:     []byte("hello"),
+	}}}, calls)
+	deliverTx.Result = &commonPb.Result{Code: code, ContractResult: result}
+	dstProof, err := BuildProof(chain2.commit(t, 8, []*commonPb.Transaction{newMessageTx("chain2", "tx0"),
+		deliverTx}, chain2.nodes...), 1, defaultHashType)
+	require.NoError(t, err)
+
+	// the delivery is acknowledged to the source contract on chain1
+	store := mock.NewMockBlockchainStore(ctrl)
+	store.EXPECT().GetTx("tx1").AnyTimes().Return(srcTx, nil)
+	ackTx := newCrossChainTx(t, "chain1", AckTxId(msg.Id), MethodAck, msg, dstProof)
+	require.NoError(t, ValidateTx(ackTx, chainConfig1, store, ac1, now))
+	state := make(map[string][]byte)
+	calls = nil
+	_, _, code = Execute(newTestTxSimContext(ctrl, state), MethodAck, paramsOf(ackTx),
+		newTestCaller(&calls, commonPb.TxStatusCode_SUCCESS, nil))
+	require.Equal(t, commonPb.TxStatusCode_SUCCESS, code)
+	require.Equal(t, []contractCall{{contractName: "src", method: "on_ack", params: map[string][]byte{
+		ParamCallMessageId:  []byte(msg.Id),
+		ParamCallDstChainId: []byte("chain2"),
+		ParamCallSuccess:    []byte("true"),
+		ParamCallResult:     []byte("received"),
+	}}}, calls)
+	receipt := &Receipt{}
+	require.NoError(t, json.Unmarshal(state[string(ReceiptKey(msg.Id))], receipt))
+	require.Equal(t, &Receipt{MessageId: msg.Id, DstChainId: "chain2", DstBlockHeight: 8,
+		DstTxId: DeliverTxId(msg.Id), Success: true, Result: []byte("received")}, receipt)
+
+	// a message is acknowledged once
+	_, _, code = Execute(newTestTxSimContext(ctrl, state), MethodAck, paramsOf(ackTx),
+		newTestCaller(&calls, commonPb.TxStatusCode_SUCCESS, nil))
+	require.Equal(t, commonPb.TxStatusCode_CONTRACT_FAIL, code)
+	require.Len(t, calls, 1)
+
+	// the delivery is not acknowledged by the proof of another tx
+	otherProof, err := BuildProof(chain2.commit(t, 8, []*commonPb.Transaction{newMessageTx("chain2", "tx0"),
+		deliverTx}, chain2.nodes...), 0, defaultHashType)
+	require.NoError(t, err)
+	require.Error(t, ValidateTx(newCrossChainTx(t, "chain1", AckTxId(msg.Id), MethodAck, msg, otherProof),
+		chainConfig1, store, ac1, now))
+}
+
+func TestValidateTx(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	now := time.Now()
+	chain1 := newTestChain(t, "chain1", "org1", 4)
+	chainConfig := &configPb.ChainConfig{ChainId: "chain2", Consensus: &configPb.ConsensusConfig{
+		Nodes: []*configPb.OrgConfig{{OrgId: "org2"}}, ExtConfig: chain1.trustConfig()}}
+	ac := newTestAC(ctrl, "org2", protocol.RoleConsensusNode, "node2")
+
+	srcTx := newMessageTx("chain1", "tx1", &commonPb.ContractEvent{Topic: EventTopicMessage, ContractName: "src",
+		EventData: []string{"chain2", "dst", "receive", "hello"}})
+	msg := MessagesOf("chain1", srcTx)[0]
+	proof, err := BuildProof(chain1.commit(t, 5, []*commonPb.Transaction{srcTx}, chain1.nodes...), 0,
+		defaultHashType)
+	require.NoError(t, err)
+	require.NoError(t, ValidateTx(newCrossChainTx(t, "chain2", DeliverTxId(msg.Id), MethodDeliver, msg, proof),
+		chainConfig, nil, ac, now))
+
+	// the senders should be the consensus nodes or the relayers of the consensus orgs
+	tx := newCrossChainTx(t, "chain2", DeliverTxId(msg.Id), MethodDeliver, msg, proof)
+	require.Error(t, ValidateTx(tx, chainConfig, nil, newTestAC(ctrl, "org3", protocol.RoleConsensusNode, "node3"),
+		now))
+	relayer := newTestAC(ctrl, "org2", protocol.RoleClient, "relayer1")
+	require.Error(t, ValidateTx(tx, chainConfig, nil, relayer, now))
+	chainConfig.Consensus.ExtConfig = append(chainConfig.Consensus.ExtConfig,
+		&commonPb.KeyValuePair{Key: RelayersKey, Value: []byte("relayer1")})
+	require.NoError(t, ValidateTx(tx, chainConfig, nil, relayer, now))
+
+	// the tx id is fixed by the message
+	require.Error(t, ValidateTx(newCrossChainTx(t, "chain2", "tx2", MethodDeliver, msg, proof),
+		chainConfig, nil, ac, now))
+
+	// the message should be sent to the chain by the trusted chain
+	chainConfig.ChainId = "chain3"
+	require.Error(t, ValidateTx(tx, chainConfig, nil, ac, now))
+	chainConfig.ChainId = "chain2"
+	require.Error(t, ValidateTx(tx, &configPb.ChainConfig{ChainId: "chain2", Consensus: &configPb.ConsensusConfig{
+		Nodes: []*configPb.OrgConfig{{OrgId: "org2"}}}}, nil, ac, now))
+
+	// the message should be sent by the source tx proved
+	forged := *msg
+	forged.Payload = "forged"
+	err = ValidateTx(newCrossChainTx(t, "chain2", DeliverTxId(msg.Id), MethodDeliver, &forged, proof),
+		chainConfig, nil, ac, now)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "mismatches")
+
+	// the proofs whose certs expired at the trusted time are rejected
+	require.Error(t, ValidateTx(tx, chainConfig, nil, ac, now.Add(2*time.Hour)))
+}
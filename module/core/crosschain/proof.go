@@ -0,0 +1,272 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package crosschain
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
+	"chainmaker.org/chainmaker/common/v2/crypto/hash"
+	bcx509 "chainmaker.org/chainmaker/common/v2/crypto/x509"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	tbftpb "chainmaker.org/chainmaker/pb-go/v2/consensus/tbft"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/utils/v2"
+	"github.com/gogo/protobuf/proto"
+)
+
+// Proof proves a tx is included in a block of a chain, which is committed by the quorum of the consensus nodes of
+// the chain. The block is trusted if more than 2/3 of the consensus nodes of the chain set in the chain config of
+// the verifier precommit it, the quorum cert is carried by the blocks of TBFT only.
+type Proof struct {
+	// Header is the marshaled block header
+	Header []byte `json:"header"`
+	// Tx is the marshaled tx with its result
+	Tx []byte `json:"tx"`
+	// TxHashes is the hashes of the txs of the block in order, the merkle root of which is the tx root
+	TxHashes [][]byte `json:"tx_hashes"`
+	TxIndex  int      `json:"tx_index"`
+	// QuorumCert is the marshaled precommit vote set of TBFT committing the block
+	QuorumCert []byte `json:"quorum_cert"`
+}
+
+// BuildProof builds the proof of the tx of the index in the committed block
+func BuildProof(block *commonPb.Block, txIndex int, hashType string) (*Proof, error) {
+	if block == nil || block.Header == nil || txIndex < 0 || txIndex >= len(block.Txs) {
+		return nil, fmt.Errorf("tx %d not found in block", txIndex)
+	}
+	var quorumCert []byte
+	if block.AdditionalData != nil {
+		quorumCert = block.AdditionalData.ExtraData[protocol.TBFTAddtionalDataKey]
+	}
+	if len(quorumCert) == 0 {
+		return nil, fmt.Errorf("block %d has no quorum cert of TBFT", block.Header.BlockHeight)
+	}
+	txHashes := make([][]byte, len(block.Txs))
+	for i, tx := range block.Txs {
+		txHash, err := utils.CalcTxHash(hashType, tx)
+		if err != nil {
+			return nil, err
+		}
+		txHashes[i] = txHash
+	}
+	header, err := proto.Marshal(block.Header)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := proto.Marshal(block.Txs[txIndex])
+	if err != nil {
+		return nil, err
+	}
+	return &Proof{Header: header, Tx: tx, TxHashes: txHashes, TxIndex: txIndex, QuorumCert: quorumCert}, nil
+}
+
+// ParseProof parses the proof param of the txs
+func ParseProof(bz []byte) (*Proof, error) {
+	proof := &Proof{}
+	if err := json.Unmarshal(bz, proof); err != nil {
+		return nil, fmt.Errorf("invalid %s, %v", ParamProof, err)
+	}
+	return proof, nil
+}
+
+// decode returns the header and the tx of the proof without verifying them
+func (proof *Proof) decode() (*commonPb.BlockHeader, *commonPb.Transaction, error) {
+	header := &commonPb.BlockHeader{}
+	if err := proto.Unmarshal(proof.Header, header); err != nil {
+		return nil, nil, fmt.Errorf("invalid block header, %v", err)
+	}
+	tx := &commonPb.Transaction{}
+	if err := proto.Unmarshal(proof.Tx, tx); err != nil {
+		return nil, nil, fmt.Errorf("invalid tx, %v", err)
+	}
+	return header, tx, nil
+}
+
+// VerifyProof verifies the proof by the consensus set of the remote chain, it returns the header and the tx proved.
+// The certs of the consensus nodes are verified at the trusted time of the verifier rather than the time of the
+// block, which is set by the remote proposer and could be backdated into the validity of an expired cert.
+func VerifyProof(proof *Proof, remote *RemoteChain, trustedTime time.Time) (
+	*commonPb.BlockHeader, *commonPb.Transaction, error) {
+	header, tx, err := proof.decode()
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.ChainId != remote.ChainId {
+		return nil, nil, fmt.Errorf("the block is of chain %s, expect %s", header.ChainId, remote.ChainId)
+	}
+
+	// the tx is in the block
+	if proof.TxIndex < 0 || proof.TxIndex >= len(proof.TxHashes) || len(proof.TxHashes) != int(header.TxCount) {
+		return nil, nil, fmt.Errorf("invalid tx index %d of %d txs", proof.TxIndex, header.TxCount)
+	}
+	txHash, err := utils.CalcTxHash(remote.HashType, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(txHash, proof.TxHashes[proof.TxIndex]) {
+		return nil, nil, fmt.Errorf("the hash of tx %d mismatches", proof.TxIndex)
+	}
+	txRoot, err := hash.GetMerkleRoot(remote.HashType, proof.TxHashes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(txRoot, header.TxRoot) {
+		return nil, nil, fmt.Errorf("tx root expect %x, got %x", header.TxRoot, txRoot)
+	}
+
+	// the block is committed by the quorum of the consensus nodes of the chain
+	blockHash, err := utils.CalcBlockHash(remote.HashType, &commonPb.Block{Header: header})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(blockHash, header.BlockHash) {
+		return nil, nil, fmt.Errorf("block hash expect %x, got %x", header.BlockHash, blockHash)
+	}
+	if err = verifyQuorumCert(header, proof.QuorumCert, remote, trustedTime); err != nil {
+		return nil, nil, fmt.Errorf("verify the quorum cert of block %d failed, %v", header.BlockHeight, err)
+	}
+	return header, tx, nil
+}
+
+// verifyQuorumCert verifies more than 2/3 of the consensus nodes of the remote chain precommit the block
+func verifyQuorumCert(header *commonPb.BlockHeader, quorumCert []byte, remote *RemoteChain,
+	trustedTime time.Time) error {
+	voteSet := &tbftpb.VoteSet{}
+	if err := proto.Unmarshal(quorumCert, voteSet); err != nil {
+		return fmt.Errorf("invalid quorum cert, %v", err)
+	}
+	hashAlgo, ok := bccrypto.HashAlgoMap[remote.HashType]
+	if !ok {
+		return fmt.Errorf("unsupported hash type %s", remote.HashType)
+	}
+	var (
+		votes   int
+		lastErr error
+	)
+	for voter, vote := range voteSet.Votes {
+		if vote == nil || vote.Voter != voter || vote.Type != tbftpb.VoteType_VOTE_PRECOMMIT ||
+			vote.Height != header.BlockHeight || !bytes.Equal(vote.Hash, header.BlockHash) {
+			continue
+		}
+		if err := verifyVote(vote, remote, hashAlgo, trustedTime); err != nil {
+			lastErr = fmt.Errorf("vote of %s, %v", voter, err)
+			continue
+		}
+		votes++
+	}
+	if votes*3 <= len(remote.Nodes)*2 {
+		return fmt.Errorf("%d valid precommits of %d consensus nodes, last error: %v", votes, len(remote.Nodes),
+			lastErr)
+	}
+	return nil
+}
+
+// verifyVote verifies the vote is signed by the cert of the voter set in the chain config of the verifier
+func verifyVote(vote *tbftpb.Vote, remote *RemoteChain, hashAlgo bccrypto.HashType, trustedTime time.Time) error {
+	certPEM, ok := remote.Nodes[vote.Voter]
+	if !ok {
+		return errors.New("not a consensus node")
+	}
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return errors.New("invalid cert of the consensus node")
+	}
+	cert, err := bcx509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return err
+	}
+	if vote.Endorsement == nil || vote.Endorsement.Signer == nil {
+		return errors.New("no endorsement")
+	}
+	signer := vote.Endorsement.Signer
+	switch signer.MemberType {
+	case pbac.MemberType_CERT:
+		signerBlock, _ := pem.Decode(signer.MemberInfo)
+		if signerBlock == nil || !bytes.Equal(signerBlock.Bytes, cert.Raw) {
+			return errors.New("the signer is not the cert of the consensus node")
+		}
+	case pbac.MemberType_CERT_HASH:
+		certId, err := utils.GetCertificateIdFromDER(cert.Raw, remote.HashType)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(certId, signer.MemberInfo) {
+			return errors.New("the signer is not the cert of the consensus node")
+		}
+	default:
+		return fmt.Errorf("unsupported member type %s of the signer", signer.MemberType)
+	}
+	if err = verifyNodeCert(cert, signer.OrgId, remote, trustedTime); err != nil {
+		return err
+	}
+
+	unsigned, ok := proto.Clone(vote).(*tbftpb.Vote)
+	if !ok {
+		return errors.New("clone vote failed")
+	}
+	unsigned.Endorsement = nil
+	message, err := proto.Marshal(unsigned)
+	if err != nil {
+		return err
+	}
+	ok, err = cert.PublicKey.VerifyWithOpts(message, vote.Endorsement.Signature, &bccrypto.SignOpts{
+		Hash: hashAlgo,
+		UID:  bccrypto.CRYPTO_DEFAULT_UID,
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// verifyNodeCert verifies the cert is a consensus node of the org issued by the trust roots of the org
+func verifyNodeCert(cert *bcx509.Certificate, orgId string, remote *RemoteChain, trustedTime time.Time) error {
+	if len(cert.Subject.Organization) == 0 || cert.Subject.Organization[0] != orgId {
+		return fmt.Errorf("the cert is not of org %s", orgId)
+	}
+	if !remote.Orgs[orgId] {
+		return fmt.Errorf("org %s does not run the consensus of chain %s", orgId, remote.ChainId)
+	}
+	if len(cert.Subject.OrganizationalUnit) == 0 ||
+		protocol.Role(strings.ToUpper(cert.Subject.OrganizationalUnit[0])) != protocol.RoleConsensusNode {
+		return errors.New("the cert is not of a consensus node")
+	}
+	opts := bcx509.VerifyOptions{
+		Roots:         bcx509.NewCertPool(),
+		Intermediates: bcx509.NewCertPool(),
+		CurrentTime:   trustedTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	rest := remote.TrustRoots[orgId]
+	for {
+		var rootBlock *pem.Block
+		if rootBlock, rest = pem.Decode(rest); rootBlock == nil {
+			break
+		}
+		root, err := bcx509.ParseCertificate(rootBlock.Bytes)
+		if err != nil {
+			return fmt.Errorf("invalid trust root of org %s, %v", orgId, err)
+		}
+		opts.Roots.AddCert(root)
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return fmt.Errorf("the cert is not issued by the trust roots of org %s, %v", orgId, err)
+	}
+	return nil
+}
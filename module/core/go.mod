@@ -3,6 +3,7 @@ module chainmaker.org/chainmaker-go/core
 go 1.15
 
 require (
+	chainmaker.org/chainmaker-go/accesscontrol v0.0.0
 	chainmaker.org/chainmaker-go/consensus v0.0.0
 	chainmaker.org/chainmaker-go/subscriber v0.0.0
 	chainmaker.org/chainmaker/chainconf/v2 v2.1.1
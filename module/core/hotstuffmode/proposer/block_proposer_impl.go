@@ -140,6 +140,8 @@ func NewBlockProposer(config BlockProposerConfig, log protocol.Logger) (protocol
 		ChainConf:       blockProposerImpl.chainConf,
 		Log:             blockProposerImpl.log,
 		StoreHelper:     blockProposerImpl.storeHelper,
+		Ac:              blockProposerImpl.ac,
+		Store:           blockProposerImpl.blockchainStore,
 	}
 
 	blockProposerImpl.blockBuilder = common.NewBlockBuilder(bbConf)
@@ -275,8 +277,10 @@ func (bp *BlockProposerImpl) proposing(height uint64, preHash []byte) *commonpb.
 
 	startDupTick := utils.CurrentTimeMillisSeconds()
 	checkedBatch := bp.txDuplicateCheck(fetchBatch)
+	// the invalid cross-chain txs are dropped rather than committed failed, whose tx ids are fixed by the messages
+	checkedBatch = bp.blockBuilder.DropInvalidCrossChainTxs(height, preHash, checkedBatch)
 	dupLasts := utils.CurrentTimeMillisSeconds() - startDupTick
-	if !utils.CanProposeEmptyBlock(bp.chainConf.ChainConfig().Consensus.Type) && len(checkedBatch) == 0 {
+	if !common.CanProposeEmptyBlock(bp.chainConf.ChainConfig()) && len(checkedBatch) == 0 {
 		// can not propose empty block and tx batch is empty, then yield proposing.
 		bp.log.Debugf("no txs in tx pool, proposing block stoped")
 		bp.txPool.RetryAndRemoveTxs(nil, fetchBatch)
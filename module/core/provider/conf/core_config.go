@@ -28,6 +28,7 @@ type CoreEngineConfig struct {
 	VmMgr           protocol.VmManager
 	Subscriber      *subscriber.EventSubscriber // block subsriber
 	StoreHelper     StoreHelper
+	VMConcurrency   int // caps the goroutines executing the txs of a block, 0 for no cap
 }
 
 type StoreHelper interface {
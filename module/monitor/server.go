@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 
 	"chainmaker.org/chainmaker/localconf/v2"
 	"chainmaker.org/chainmaker/logger/v2"
@@ -18,22 +20,26 @@ import (
 )
 
 type MonitorServer struct {
+	// lock protects the http server and the port, which are replaced when the local config is reloaded
+	lock       sync.Mutex
 	httpServer *http.Server
+	port       int
 	log        *logger.CMLogger
+	readiness  atomic.Value // func() bool
 }
 
 func NewMonitorServer() *MonitorServer {
 	var log = logger.GetLogger(logger.MODULE_MONITOR)
 
 	if localconf.ChainMakerConfig.MonitorConfig.Enabled {
+		s := &MonitorServer{log: log, port: int(localconf.ChainMakerConfig.MonitorConfig.Port)}
 		mux := http.NewServeMux()
 		mux.Handle("/metrics", promhttp.Handler())
-		return &MonitorServer{
-			httpServer: &http.Server{
-				Handler: mux,
-			},
-			log: log,
+		mux.HandleFunc("/ready", s.handleReady)
+		s.httpServer = &http.Server{
+			Handler: mux,
 		}
+		return s
 	} else {
 		return &MonitorServer{
 			log: log,
@@ -42,16 +48,22 @@ func NewMonitorServer() *MonitorServer {
 }
 
 func (s *MonitorServer) Start() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.start()
+}
+
+func (s *MonitorServer) start() error {
 	if s.httpServer != nil {
-		endPoint := fmt.Sprintf(":%d", localconf.ChainMakerConfig.MonitorConfig.Port)
+		endPoint := fmt.Sprintf(":%d", s.port)
 		conn, err := net.Listen("tcp", endPoint)
 		if err != nil {
 			return fmt.Errorf("TCP listen failed, %s", err.Error())
 		}
 
+		httpServer := s.httpServer
 		go func() {
-			err = s.httpServer.Serve(conn)
-			if err != nil {
+			if err := httpServer.Serve(conn); err != nil && err != http.ErrServerClosed {
 				s.log.Errorf("http Serve failed, %s", err.Error())
 			}
 		}()
@@ -61,3 +73,46 @@ func (s *MonitorServer) Start() error {
 
 	return nil
 }
+
+// Module returns the name of the module applying the local config reloaded
+func (s *MonitorServer) Module() string {
+	return "MonitorServer"
+}
+
+// WatchedLocalConfigs returns the port of the monitor server, enabling or disabling it requires a restart
+func (s *MonitorServer) WatchedLocalConfigs() []string {
+	return []string{"MonitorConfig.Port"}
+}
+
+// WatchLocalConfig restarts the monitor server listening on the port reloaded
+func (s *MonitorServer) WatchLocalConfig(config *localconf.CMConfig) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.httpServer == nil {
+		return nil
+	}
+	if err := s.httpServer.Close(); err != nil {
+		return fmt.Errorf("close http server failed, %s", err.Error())
+	}
+	s.httpServer = &http.Server{
+		Handler: s.httpServer.Handler,
+	}
+	s.port = int(config.MonitorConfig.Port)
+	return s.start()
+}
+
+// SetReadiness sets the readiness probe served on /ready, the node is reported not ready if it is not set
+func (s *MonitorServer) SetReadiness(ready func() bool) {
+	s.readiness.Store(ready)
+}
+
+func (s *MonitorServer) handleReady(w http.ResponseWriter, _ *http.Request) {
+	if ready, ok := s.readiness.Load().(func() bool); ok && ready() {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ready"))
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write([]byte("not ready"))
+}
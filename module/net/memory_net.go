@@ -0,0 +1,530 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package net
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	"chainmaker.org/chainmaker/common/v2/helper"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+// MemoryNetType is the net type of the in-process net, which connects the nodes in the same process over channels.
+// It is used to run several nodes in one test without ports, certs or containers.
+// The modules of a ChainMakerServer read the global localconf, so one process runs one ChainMakerServer,
+// the nodes of a test are assembled from the net services, the consensus engines and the sync services
+// on the memory net instead, such as TestMemoryNetHarness of the blockchain module.
+const MemoryNetType protocol.NetType = 100
+
+const (
+	// memoryAddrPrefix is the prefix of the listen address of the in-process net, followed by the network name,
+	// such as "/memory/net1". The nodes listening on the same network connect to each other.
+	memoryAddrPrefix   = "/memory/"
+	defaultMemoryNetID = "default"
+)
+
+var (
+	ErrorMemoryPeerNotConnected = errors.New("peer not connected")
+	ErrorMemoryNodeUidEmpty     = errors.New("node uid of the memory net is empty, set it by the crypto option")
+)
+
+var (
+	memoryNetworksLock sync.Mutex
+	memoryNetworks     = make(map[string]*MemoryNetwork)
+)
+
+// GetMemoryNetwork returns the in-process network of the name, it is created if not existed.
+func GetMemoryNetwork(name string) *MemoryNetwork {
+	memoryNetworksLock.Lock()
+	defer memoryNetworksLock.Unlock()
+	network, ok := memoryNetworks[name]
+	if !ok {
+		network = NewMemoryNetwork(time.Now().UnixNano())
+		memoryNetworks[name] = network
+	}
+	return network
+}
+
+// RemoveMemoryNetwork removes the in-process network of the name, so that the next test starts from a new one.
+func RemoveMemoryNetwork(name string) {
+	memoryNetworksLock.Lock()
+	defer memoryNetworksLock.Unlock()
+	delete(memoryNetworks, name)
+}
+
+// MemoryNetFaults describes the faults injected into the in-process network.
+type MemoryNetFaults struct {
+	// DropRate is the probability in [0, 1] to drop a msg
+	DropRate float64
+	// MinDelay and MaxDelay bound the random latency of each msg
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// MemoryNetwork is an in-process network between the MemoryNet instances.
+type MemoryNetwork struct {
+	mtx        sync.RWMutex
+	rand       *rand.Rand
+	nodes      map[string]*MemoryNet // node uid -> the running net
+	certIds    map[string]string     // cert id -> node uid
+	faults     MemoryNetFaults
+	links      map[string]MemoryNetFaults // "from/to" -> the faults of the link, overriding the network ones
+	partitions map[string]int             // node uid -> partition index, nodes in different partitions can not talk
+	closed     map[string]struct{}        // "from/to" -> the links closed by the nodes
+
+	sent      uint64
+	delivered uint64
+	dropped   uint64
+}
+
+// NewMemoryNetwork creates an in-process network, seed makes the injected faults reproducible.
+func NewMemoryNetwork(seed int64) *MemoryNetwork {
+	return &MemoryNetwork{
+		rand:       rand.New(rand.NewSource(seed)),
+		nodes:      make(map[string]*MemoryNet),
+		certIds:    make(map[string]string),
+		links:      make(map[string]MemoryNetFaults),
+		partitions: make(map[string]int),
+		closed:     make(map[string]struct{}),
+	}
+}
+
+// SetFaults replaces the faults injected into all the links.
+func (n *MemoryNetwork) SetFaults(faults MemoryNetFaults) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.faults = faults
+}
+
+// SetLinkFaults replaces the faults injected into the msgs sent from one node to another.
+func (n *MemoryNetwork) SetLinkFaults(from, to string, faults MemoryNetFaults) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.links[from+"/"+to] = faults
+}
+
+// Partition splits the network, nodes not listed in any group keep talking to everyone.
+// Heal restores the network.
+func (n *MemoryNetwork) Partition(groups ...[]string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.partitions = make(map[string]int)
+	for i, group := range groups {
+		for _, uid := range group {
+			n.partitions[uid] = i + 1
+		}
+	}
+}
+
+// Heal removes all the partitions, the faults of the links and the links closed.
+func (n *MemoryNetwork) Heal() {
+	n.Partition()
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.links = make(map[string]MemoryNetFaults)
+	n.closed = make(map[string]struct{})
+}
+
+// setLinkClosed closes or reopens the link between two nodes in both directions.
+func (n *MemoryNetwork) setLinkClosed(a, b string, closed bool) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	for _, link := range []string{a + "/" + b, b + "/" + a} {
+		if closed {
+			n.closed[link] = struct{}{}
+		} else {
+			delete(n.closed, link)
+		}
+	}
+}
+
+// BindCertId maps the tls cert id of a node to its uid, which is returned by GetNodeUidByCertId.
+func (n *MemoryNetwork) BindCertId(certId, nodeUid string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.certIds[certId] = nodeUid
+}
+
+// Stats returns the number of the msgs sent, delivered and dropped.
+func (n *MemoryNetwork) Stats() (sent, delivered, dropped uint64) {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+	return n.sent, n.delivered, n.dropped
+}
+
+func (n *MemoryNetwork) join(node *MemoryNet) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	if _, ok := n.nodes[node.uid]; ok {
+		return fmt.Errorf("node %s has joined the memory network", node.uid)
+	}
+	n.nodes[node.uid] = node
+	return nil
+}
+
+func (n *MemoryNetwork) leave(uid string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	delete(n.nodes, uid)
+}
+
+// peers returns the running nodes joined the chain and connected to the node given.
+func (n *MemoryNetwork) peers(chainId, self string) []*MemoryNet {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+	var peers []*MemoryNet
+	for uid, node := range n.nodes {
+		if uid != self && n.connected(self, uid) && node.hasChain(chainId) {
+			peers = append(peers, node)
+		}
+	}
+	return peers
+}
+
+// send delivers the msg to the node after the latency, the msg is dropped by the faults silently.
+// It returns ErrorMemoryPeerNotConnected if the node is not running or partitioned.
+func (n *MemoryNetwork) send(from, to string, deliver func(node *MemoryNet)) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.sent++
+	node, ok := n.nodes[to]
+	if !ok || !n.connected(from, to) || node.isBlack(from) {
+		n.dropped++
+		return ErrorMemoryPeerNotConnected
+	}
+	faults, ok := n.links[from+"/"+to]
+	if !ok {
+		faults = n.faults
+	}
+	if n.rand.Float64() < faults.DropRate {
+		n.dropped++
+		return nil
+	}
+	delay := faults.MinDelay
+	if faults.MaxDelay > faults.MinDelay {
+		delay += time.Duration(n.rand.Int63n(int64(faults.MaxDelay - faults.MinDelay)))
+	}
+	n.delivered++
+	go func() {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		deliver(node)
+	}()
+	return nil
+}
+
+// connected checks whether two nodes are in the same partition and the link between them is not closed,
+// it must be called with lock held
+func (n *MemoryNetwork) connected(from, to string) bool {
+	if _, closed := n.closed[from+"/"+to]; closed {
+		return false
+	}
+	p1, ok1 := n.partitions[from]
+	p2, ok2 := n.partitions[to]
+	return !ok1 || !ok2 || p1 == p2
+}
+
+func (n *MemoryNetwork) nodeUidByCertId(certId string) (string, bool) {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+	uid, ok := n.certIds[certId]
+	return uid, ok
+}
+
+var (
+	_ protocol.Net     = (*MemoryNet)(nil)
+	_ PeerDisconnecter = (*MemoryNet)(nil)
+)
+
+// MemoryNet is a protocol.Net connecting the nodes in the same process over an in-process network.
+// The peers are trusted, the access control of the chains is not applied to them.
+type MemoryNet struct {
+	network *MemoryNetwork
+	uid     string
+	certPem []byte
+
+	mtx            sync.RWMutex
+	running        bool
+	chains         map[string]struct{}                             // the chains with pub-sub inited
+	directHandlers map[string]map[string]protocol.DirectMsgHandler // chainId -> msg flag -> handler
+	subscribers    map[string]map[string]protocol.PubSubMsgHandler // chainId -> topic -> handler
+	blackNodeIds   map[string]struct{}                             // the nodes not allowed to talk to us
+}
+
+// NewMemoryNet creates a net on the in-process network named "default",
+// the network and the node uid are set by the listen address and crypto options.
+func NewMemoryNet() *MemoryNet {
+	return &MemoryNet{
+		network:        GetMemoryNetwork(defaultMemoryNetID),
+		chains:         make(map[string]struct{}),
+		directHandlers: make(map[string]map[string]protocol.DirectMsgHandler),
+		subscribers:    make(map[string]map[string]protocol.PubSubMsgHandler),
+		blackNodeIds:   make(map[string]struct{}),
+	}
+}
+
+// SetListenAddr selects the network by the address like "/memory/<network name>".
+func (m *MemoryNet) SetListenAddr(addr string) error {
+	if !strings.HasPrefix(addr, memoryAddrPrefix) || len(addr) == len(memoryAddrPrefix) {
+		return fmt.Errorf("invalid memory net listen address %s, it should be like %s<network name>",
+			addr, memoryAddrPrefix)
+	}
+	m.network = GetMemoryNetwork(strings.TrimPrefix(addr, memoryAddrPrefix))
+	return nil
+}
+
+// SetCrypto sets the node uid by the tls private key, the same as the other nets.
+func (m *MemoryNet) SetCrypto(keyPem []byte, certPem []byte) error {
+	m.certPem = certPem
+	privateKey, err := asym.PrivateKeyFromPEM(keyPem, nil)
+	if err != nil {
+		return err
+	}
+	m.uid, err = helper.CreateLibp2pPeerIdWithPrivateKey(privateKey)
+	return err
+}
+
+// SetNodeUid sets the node uid directly.
+func (m *MemoryNet) SetNodeUid(uid string) {
+	m.uid = uid
+}
+
+// Network returns the in-process network the net is on, which injects the faults.
+func (m *MemoryNet) Network() *MemoryNetwork {
+	return m.network
+}
+
+// GetNodeUid is the unique id of node.
+func (m *MemoryNet) GetNodeUid() string {
+	return m.uid
+}
+
+// InitPubSub joins the chain, the msgs of the chain are received from then on.
+func (m *MemoryNet) InitPubSub(chainId string, _ int) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.chains[chainId] = struct{}{}
+	return nil
+}
+
+// BroadcastWithChainId sends the msg to all the peers joined the chain, the unreachable ones are skipped.
+func (m *MemoryNet) BroadcastWithChainId(chainId string, topic string, netMsg []byte) error {
+	if !m.IsRunning() {
+		return ErrorNetNotRunning
+	}
+	for _, peer := range m.network.peers(chainId, m.uid) {
+		_ = m.network.send(m.uid, peer.uid, func(node *MemoryNet) {
+			node.handlePubSubMsg(chainId, topic, m.uid, netMsg)
+		})
+	}
+	return nil
+}
+
+// SubscribeWithChainId registers the handler of the topic of the chain.
+func (m *MemoryNet) SubscribeWithChainId(chainId string, topic string, handler protocol.PubSubMsgHandler) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.subscribers[chainId]; !ok {
+		m.subscribers[chainId] = make(map[string]protocol.PubSubMsgHandler)
+	}
+	if _, ok := m.subscribers[chainId][topic]; ok {
+		return fmt.Errorf("topic %s of chain %s has been subscribed", topic, chainId)
+	}
+	m.subscribers[chainId][topic] = handler
+	return nil
+}
+
+// CancelSubscribeWithChainId removes the handler of the topic of the chain.
+func (m *MemoryNet) CancelSubscribeWithChainId(chainId string, topic string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.subscribers[chainId], topic)
+	return nil
+}
+
+// SendMsg sends the msg to the node.
+func (m *MemoryNet) SendMsg(chainId string, node string, msgFlag string, netMsg []byte) error {
+	if !m.IsRunning() {
+		return ErrorNetNotRunning
+	}
+	return m.network.send(m.uid, node, func(peer *MemoryNet) {
+		peer.handleDirectMsg(chainId, msgFlag, m.uid, netMsg)
+	})
+}
+
+// DirectMsgHandle registers the handler of the msg flag of the chain.
+func (m *MemoryNet) DirectMsgHandle(chainId string, msgFlag string, handler protocol.DirectMsgHandler) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.directHandlers[chainId]; !ok {
+		m.directHandlers[chainId] = make(map[string]protocol.DirectMsgHandler)
+	}
+	if _, ok := m.directHandlers[chainId][msgFlag]; ok {
+		return fmt.Errorf("handler of msg flag %s of chain %s exists", msgFlag, chainId)
+	}
+	m.directHandlers[chainId][msgFlag] = handler
+	return nil
+}
+
+// CancelDirectMsgHandle removes the handler of the msg flag of the chain.
+func (m *MemoryNet) CancelDirectMsgHandle(chainId string, msgFlag string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.directHandlers[chainId], msgFlag)
+	return nil
+}
+
+// AddSeed reopens the link to the node of the seed closed by ClosePeer, such as "/memory/net1/p2p/<uid>".
+// All the nodes on the network are connected otherwise.
+func (m *MemoryNet) AddSeed(seed string) error {
+	if uid := SeedNodeId(seed); uid != "" {
+		m.network.setLinkClosed(m.uid, uid, false)
+	}
+	return nil
+}
+
+// ClosePeer closes the link to the node until it is added as a seed or the network is healed.
+func (m *MemoryNet) ClosePeer(uid string) error {
+	m.network.setLinkClosed(m.uid, uid, true)
+	return nil
+}
+
+// RefreshSeeds is no-op, all the nodes on the network are connected.
+func (m *MemoryNet) RefreshSeeds(_ []string) error {
+	return nil
+}
+
+// SetChainCustomTrustRoots is no-op, the peers are trusted.
+func (m *MemoryNet) SetChainCustomTrustRoots(_ string, _ [][]byte) {}
+
+// ReVerifyPeers is no-op, the peers are trusted.
+func (m *MemoryNet) ReVerifyPeers(_ string) {}
+
+// IsRunning returns whether the net has started.
+func (m *MemoryNet) IsRunning() bool {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.running
+}
+
+// ChainNodesInfo returns the running peers joined the chain.
+func (m *MemoryNet) ChainNodesInfo(chainId string) ([]*protocol.ChainNodeInfo, error) {
+	var infos []*protocol.ChainNodeInfo
+	for _, peer := range m.network.peers(chainId, m.uid) {
+		infos = append(infos, &protocol.ChainNodeInfo{
+			NodeUid:     peer.uid,
+			NodeAddress: []string{memoryAddrPrefix + peer.uid},
+			NodeTlsCert: peer.certPem,
+		})
+	}
+	return infos, nil
+}
+
+// GetNodeUidByCertId returns the uid of the node whose cert id is bound to the network.
+func (m *MemoryNet) GetNodeUidByCertId(certId string) (string, error) {
+	if uid, ok := m.network.nodeUidByCertId(certId); ok {
+		return uid, nil
+	}
+	return "", fmt.Errorf("node of cert id %s not found", certId)
+}
+
+// AddAC is no-op, the peers are trusted.
+func (m *MemoryNet) AddAC(_ string, _ protocol.AccessControlProvider) {}
+
+// SetMsgPriority is no-op, the msgs are not queued.
+func (m *MemoryNet) SetMsgPriority(_ string, _ uint8) {}
+
+// Start joins the network.
+func (m *MemoryNet) Start() error {
+	if m.uid == "" {
+		return ErrorMemoryNodeUidEmpty
+	}
+	// the lock of the net is not held when calling the network, which calls back the net with its lock held
+	m.mtx.Lock()
+	if m.running {
+		m.mtx.Unlock()
+		return nil
+	}
+	m.running = true
+	m.mtx.Unlock()
+	if err := m.network.join(m); err != nil {
+		m.mtx.Lock()
+		m.running = false
+		m.mtx.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Stop leaves the network, the msgs sent to the node are dropped from then on.
+func (m *MemoryNet) Stop() error {
+	m.mtx.Lock()
+	running := m.running
+	m.running = false
+	m.mtx.Unlock()
+	if running {
+		m.network.leave(m.uid)
+	}
+	return nil
+}
+
+func (m *MemoryNet) hasChain(chainId string) bool {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	_, ok := m.chains[chainId]
+	return ok
+}
+
+// AddBlackNodeIds refuses the msgs from the nodes.
+func (m *MemoryNet) AddBlackNodeIds(uids ...string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, uid := range uids {
+		m.blackNodeIds[uid] = struct{}{}
+	}
+}
+
+// isBlack is called with the lock of the network held, it does not call back the network
+func (m *MemoryNet) isBlack(uid string) bool {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	_, ok := m.blackNodeIds[uid]
+	return ok
+}
+
+func (m *MemoryNet) handleDirectMsg(chainId, msgFlag, from string, msg []byte) {
+	m.mtx.RLock()
+	handler := m.directHandlers[chainId][msgFlag]
+	m.mtx.RUnlock()
+	if handler == nil {
+		return
+	}
+	if err := handler(from, msg); err != nil {
+		GlobalNetLogger.Debugf("[MemoryNet] handle msg (chain:%s, flag:%s, from:%s) failed, %s",
+			chainId, msgFlag, from, err.Error())
+	}
+}
+
+func (m *MemoryNet) handlePubSubMsg(chainId, topic, from string, msg []byte) {
+	m.mtx.RLock()
+	handler := m.subscribers[chainId][topic]
+	m.mtx.RUnlock()
+	if handler == nil {
+		return
+	}
+	if err := handler(from, msg); err != nil {
+		GlobalNetLogger.Debugf("[MemoryNet] handle pub-sub msg (chain:%s, topic:%s, from:%s) failed, %s",
+			chainId, topic, from, err.Error())
+	}
+}
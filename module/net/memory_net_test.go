@@ -0,0 +1,93 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package net
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMemoryNet(t *testing.T, network, uid string) *MemoryNet {
+	var nf NetFactory
+	n, err := nf.NewNet(MemoryNetType, WithListenAddr(memoryAddrPrefix+network))
+	require.Nil(t, err)
+	m, _ := n.(*MemoryNet)
+	m.SetNodeUid(uid)
+	require.Nil(t, m.Start())
+	require.Nil(t, m.InitPubSub(chainId1, 0))
+	return m
+}
+
+func TestMemoryNet(t *testing.T) {
+	const network = "TestMemoryNet"
+	defer RemoveMemoryNetwork(network)
+	a := newTestMemoryNet(t, network, "a")
+	b := newTestMemoryNet(t, network, "b")
+	c := newTestMemoryNet(t, network, "c")
+	require.Equal(t, a.Network(), c.Network())
+
+	directC := make(chan string, 10)
+	pubSubC := make(chan string, 10)
+	for _, m := range []*MemoryNet{b, c} {
+		m := m
+		require.Nil(t, m.DirectMsgHandle(chainId1, msgFlag, func(from string, msg []byte) error {
+			directC <- m.GetNodeUid() + "<-" + from + ":" + string(msg)
+			return nil
+		}))
+		require.Nil(t, m.SubscribeWithChainId(chainId1, msgFlag, func(from string, msg []byte) error {
+			pubSubC <- m.GetNodeUid() + "<-" + from + ":" + string(msg)
+			return nil
+		}))
+	}
+	receive := func(c chan string) string {
+		select {
+		case msg := <-c:
+			return msg
+		case <-time.After(time.Second):
+			return ""
+		}
+	}
+
+	// send and broadcast
+	require.Nil(t, a.SendMsg(chainId1, "b", msgFlag, []byte("hi")))
+	require.Equal(t, "b<-a:hi", receive(directC))
+	require.Nil(t, a.BroadcastWithChainId(chainId1, msgFlag, []byte("all")))
+	require.ElementsMatch(t, []string{"b<-a:all", "c<-a:all"}, []string{receive(pubSubC), receive(pubSubC)})
+	infos, err := a.ChainNodesInfo(chainId1)
+	require.Nil(t, err)
+	require.Len(t, infos, 2)
+
+	// latency
+	a.Network().SetFaults(MemoryNetFaults{MinDelay: 100 * time.Millisecond, MaxDelay: 100 * time.Millisecond})
+	start := time.Now()
+	require.Nil(t, a.SendMsg(chainId1, "b", msgFlag, []byte("slow")))
+	require.Equal(t, "b<-a:slow", receive(directC))
+	require.True(t, time.Since(start) >= 100*time.Millisecond)
+
+	// loss
+	a.Network().SetFaults(MemoryNetFaults{DropRate: 1})
+	require.Nil(t, a.SendMsg(chainId1, "b", msgFlag, []byte("lost")))
+	require.Equal(t, "", receive(directC))
+	a.Network().SetFaults(MemoryNetFaults{})
+
+	// partitions
+	a.Network().Partition([]string{"a", "b"}, []string{"c"})
+	require.Nil(t, a.SendMsg(chainId1, "b", msgFlag, []byte("near")))
+	require.Equal(t, "b<-a:near", receive(directC))
+	require.Equal(t, ErrorMemoryPeerNotConnected, a.SendMsg(chainId1, "c", msgFlag, []byte("far")))
+	a.Network().Heal()
+	require.Nil(t, a.SendMsg(chainId1, "c", msgFlag, []byte("healed")))
+	require.Equal(t, "c<-a:healed", receive(directC))
+
+	// stopped nodes are not reachable
+	require.Nil(t, c.Stop())
+	require.Equal(t, ErrorMemoryPeerNotConnected, a.SendMsg(chainId1, "c", msgFlag, []byte("stopped")))
+	sent, delivered, dropped := a.Network().Stats()
+	require.Equal(t, sent, delivered+dropped)
+}
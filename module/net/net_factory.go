@@ -50,6 +50,9 @@ func WithListenAddr(addr string) NetOption {
 		case protocol.Liquid:
 			n, _ := nf.n.(*liquid.LiquidNet)
 			return liquid.SetListenAddrStr(n.HostConfig(), addr)
+		case MemoryNetType:
+			n, _ := nf.n.(*MemoryNet)
+			return n.SetListenAddr(addr)
 		}
 		return nil
 	}
@@ -72,6 +75,14 @@ func WithCrypto(pkMode bool, keyFile string, certFile string) NetOption {
 				return err
 			}
 		}
+		return WithCryptoPEM(pkMode, keyBytes, certBytes)(nf)
+	}
+}
+
+// WithCryptoPEM set the PEM of the private key and the tls cert for the net to create connection,
+// such as the ones decrypted from the encrypted key files.
+func WithCryptoPEM(pkMode bool, keyBytes []byte, certBytes []byte) NetOption {
+	return func(nf *NetFactory) error {
 		switch nf.netType {
 		case protocol.Libp2p:
 			n, _ := nf.n.(*libp2p.LibP2pNet)
@@ -87,6 +98,9 @@ func WithCrypto(pkMode bool, keyFile string, certFile string) NetOption {
 			if !pkMode {
 				n.CryptoConfig().CertBytes = certBytes
 			}
+		case MemoryNetType:
+			n, _ := nf.n.(*MemoryNet)
+			return n.SetCrypto(keyBytes, certBytes)
 		}
 		return nil
 	}
@@ -227,6 +241,9 @@ func WithBlackNodeIds(blackNodeIds ...string) NetOption {
 		case protocol.Liquid:
 			n, _ := nf.n.(*liquid.LiquidNet)
 			return n.HostConfig().AddBlackPeers(blackNodeIds...)
+		case MemoryNetType:
+			n, _ := nf.n.(*MemoryNet)
+			n.AddBlackNodeIds(blackNodeIds...)
 		}
 		return nil
 	}
@@ -306,6 +323,8 @@ func (nf *NetFactory) NewNet(netType protocol.NetType, opts ...NetOption) (proto
 			return nil, err
 		}
 		nf.n = liquidNet
+	case MemoryNetType:
+		nf.n = NewMemoryNet()
 	default:
 		return nil, ErrorNetType
 	}
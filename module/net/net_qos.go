@@ -0,0 +1,444 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package net
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"chainmaker.org/chainmaker/net-common/common/priorityblocker"
+	netPb "chainmaker.org/chainmaker/pb-go/v2/net"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the qos classes of the net msgs
+const (
+	QoSClassConsensus = "consensus"
+	QoSClassTx        = "tx"
+	QoSClassSync      = "sync"
+)
+
+var (
+	ErrorQoSQueueFull = errors.New("qos queue of the msg class is full")
+	ErrorQoSStopped   = errors.New("qos scheduler stopped")
+)
+
+// QoSClassConfig is the config of a qos class.
+type QoSClassConfig struct {
+	// Priority of the class, 0~9. The msgs of a higher class are sent first,
+	// and they do not wait for the bandwidth used by the lower classes.
+	Priority int `mapstructure:"priority"`
+	// BandwidthShare is the percent of the bandwidth of the chain the class could use at most.
+	BandwidthShare int `mapstructure:"bandwidth_share"`
+	// QueueSize is the max msgs of the class waiting for sending, the msgs beyond are dropped.
+	QueueSize int `mapstructure:"queue_size"`
+}
+
+// defaultMaxInflight is the msgs of a chain being sent at most by default
+const defaultMaxInflight = 64
+
+// QoSConfig is the qos config of the net msgs sent by a chain.
+type QoSConfig struct {
+	// BandwidthKB is the KB per second the chain sends at most, 0 means unlimited.
+	BandwidthKB int `mapstructure:"bandwidth_kb"`
+	// MaxInflight is the msgs of the chain being sent at most, the msgs waiting are sent by the priority
+	// of their classes once the msgs in flight are sent, so the priorities apply without bandwidth_kb.
+	MaxInflight int `mapstructure:"max_inflight"`
+	// Classes is the config of the classes, the classes not configured use the default config.
+	Classes map[string]*QoSClassConfig `mapstructure:"classes"`
+	// MsgTypes maps the name of the netPb.NetMsg_MsgType to a class, overriding the default class of the type.
+	MsgTypes map[string]string `mapstructure:"msg_types"`
+}
+
+// DefaultQoSConfig returns the default qos config, whose priorities are the same as the ones set to the net.
+func DefaultQoSConfig() *QoSConfig {
+	return &QoSConfig{
+		MaxInflight: defaultMaxInflight,
+		Classes: map[string]*QoSClassConfig{
+			QoSClassConsensus: {Priority: int(priorityblocker.PriorityLevel9), BandwidthShare: 100, QueueSize: 1024},
+			QoSClassTx:        {Priority: int(priorityblocker.PriorityLevel7), BandwidthShare: 100, QueueSize: 4096},
+			QoSClassSync:      {Priority: int(priorityblocker.PriorityLevel5), BandwidthShare: 100, QueueSize: 256},
+		},
+	}
+}
+
+// Verify the qos config and fill the classes not configured with the default config.
+func (c *QoSConfig) Verify() error {
+	if c.BandwidthKB < 0 {
+		return fmt.Errorf("invalid qos bandwidth_kb %d", c.BandwidthKB)
+	}
+	if c.MaxInflight < 0 {
+		return fmt.Errorf("invalid qos max_inflight %d", c.MaxInflight)
+	}
+	if c.MaxInflight == 0 {
+		c.MaxInflight = defaultMaxInflight
+	}
+	defaultClasses := DefaultQoSConfig().Classes
+	if c.Classes == nil {
+		c.Classes = make(map[string]*QoSClassConfig)
+	}
+	for name, class := range c.Classes {
+		if _, ok := defaultClasses[name]; !ok {
+			return fmt.Errorf("unknown qos class %s", name)
+		}
+		if class == nil {
+			c.Classes[name] = defaultClasses[name]
+			continue
+		}
+		if class.Priority < 0 || class.Priority > int(priorityblocker.PriorityLevel9) {
+			return fmt.Errorf("invalid priority %d of qos class %s", class.Priority, name)
+		}
+		if class.BandwidthShare < 0 || class.BandwidthShare > 100 || class.QueueSize < 0 {
+			return fmt.Errorf("invalid bandwidth_share or queue_size of qos class %s", name)
+		}
+		if class.BandwidthShare == 0 {
+			class.BandwidthShare = 100
+		}
+		if class.QueueSize == 0 {
+			class.QueueSize = defaultClasses[name].QueueSize
+		}
+	}
+	for name, class := range defaultClasses {
+		if _, ok := c.Classes[name]; !ok {
+			c.Classes[name] = class
+		}
+	}
+	for msgType, class := range c.MsgTypes {
+		if _, ok := netPb.NetMsg_MsgType_value[msgType]; !ok {
+			return fmt.Errorf("unknown net msg type %s", msgType)
+		}
+		if _, ok := c.Classes[class]; !ok {
+			return fmt.Errorf("unknown qos class %s of net msg type %s", class, msgType)
+		}
+	}
+	return nil
+}
+
+// classOf returns the qos class of the msg type.
+func (c *QoSConfig) classOf(msgType netPb.NetMsg_MsgType) string {
+	if class, ok := c.MsgTypes[msgType.String()]; ok {
+		return class
+	}
+	switch msgType {
+	case netPb.NetMsg_CONSENSUS_MSG:
+		return QoSClassConsensus
+	case netPb.NetMsg_SYNC_BLOCK_MSG, netPb.NetMsg_BLOCK, netPb.NetMsg_BLOCKS:
+		return QoSClassSync
+	default:
+		return QoSClassTx
+	}
+}
+
+// priorityOf returns the priority of the msg type set to the net.
+func (c *QoSConfig) priorityOf(msgType netPb.NetMsg_MsgType) priorityblocker.PriorityLevel {
+	return priorityblocker.PriorityLevel(c.Classes[c.classOf(msgType)].Priority)
+}
+
+// SetMsgPriorities sets the priorities of the msg flags of the net by the qos classes of the msg types.
+// The flags carry no chain id, so the priorities are shared by all the chains of the node and set once
+// by the node-wide config, the default config if nil. The order of the msgs of a chain is decided by its qos.
+func SetMsgPriorities(localNet protocol.Net, config *QoSConfig) {
+	if config == nil {
+		config = DefaultQoSConfig()
+	}
+	flags := []struct {
+		prefix  string
+		msgType netPb.NetMsg_MsgType
+	}{
+		{"", netPb.NetMsg_CONSENSUS_MSG},
+		{"", netPb.NetMsg_BLOCK},
+		{"", netPb.NetMsg_BLOCKS},
+		{"", netPb.NetMsg_TX},
+		{"", netPb.NetMsg_TXS},
+		{"", netPb.NetMsg_SYNC_BLOCK_MSG},
+		{msgBusMsgFlagPrefix, netPb.NetMsg_CONSENSUS_MSG},
+		{msgBusConsensusTopicPrefix, netPb.NetMsg_CONSENSUS_MSG},
+		{msgBusMsgFlagPrefix, netPb.NetMsg_TX},
+		{msgBusConsensusTopicPrefix, netPb.NetMsg_TX},
+		{topicNamePrefix, netPb.NetMsg_TX},
+		{msgBusMsgFlagPrefix, netPb.NetMsg_SYNC_BLOCK_MSG},
+		{msgBusTopicPrefix, netPb.NetMsg_SYNC_BLOCK_MSG},
+	}
+	for _, f := range flags {
+		flag := f.msgType.String()
+		if f.prefix != "" {
+			flag = CreateFlagWithPrefixAndMsgType(f.prefix, f.msgType)
+		}
+		localNet.SetMsgPriority(flag, uint8(config.priorityOf(f.msgType)))
+	}
+}
+
+var (
+	qosMetricsOnce sync.Once
+
+	metricQoSQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "chainmaker", Subsystem: "net_qos", Name: "queued_msgs",
+		Help: "The number of the msgs waiting for sending",
+	}, []string{"chainId", "class"})
+	metricQoSDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chainmaker", Subsystem: "net_qos", Name: "dropped_msgs_total",
+		Help: "The number of the msgs dropped for the queue of the class is full",
+	}, []string{"chainId", "class"})
+	metricQoSSentBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chainmaker", Subsystem: "net_qos", Name: "sent_bytes_total",
+		Help: "The bytes of the msgs sent",
+	}, []string{"chainId", "class"})
+)
+
+// qosScheduler orders the msgs sent by a chain by the priority of their classes, and paces them
+// within the bandwidth of the chain and the share of the class, and within the msgs in flight of the chain.
+// The msgs are still sent by the callers, the scheduler only decides when. All methods are no-op on nil.
+type qosScheduler struct {
+	chainId     string
+	metrics     bool
+	rate        float64 // bytes per second of the chain, 0 means unlimited
+	maxInflight int
+
+	mtx      sync.Mutex
+	inflight int
+	classes  []*qosClass // sorted by priority desc
+	byName   map[string]*qosClass
+
+	notifyC chan struct{}
+	stopC   chan struct{}
+	stopped bool
+}
+
+type qosClass struct {
+	name      string
+	priority  int
+	rate      float64 // bytes per second of the class
+	queueSize int
+	next      time.Time // the time the class could send at within its share
+	chainNext time.Time // the time the class could send at within the bandwidth left by the higher classes
+	waiters   []*qosWaiter
+}
+
+type qosWaiter struct {
+	size    int
+	granted chan struct{}
+}
+
+// newQoSScheduler returns nil if the qos of the chain is not configured, the msgs are sent at once then.
+func newQoSScheduler(chainId string, config *QoSConfig, metrics bool) *qosScheduler {
+	if config == nil {
+		return nil
+	}
+	s := &qosScheduler{
+		chainId:     chainId,
+		metrics:     metrics,
+		rate:        float64(config.BandwidthKB) * 1024,
+		maxInflight: config.MaxInflight,
+		byName:      make(map[string]*qosClass),
+		notifyC:     make(chan struct{}, 1),
+		stopC:       make(chan struct{}),
+	}
+	for name, classConfig := range config.Classes {
+		class := &qosClass{
+			name:      name,
+			priority:  classConfig.Priority,
+			rate:      s.rate * float64(classConfig.BandwidthShare) / 100,
+			queueSize: classConfig.QueueSize,
+		}
+		s.classes = append(s.classes, class)
+		s.byName[name] = class
+	}
+	sort.SliceStable(s.classes, func(i, j int) bool {
+		if s.classes[i].priority != s.classes[j].priority {
+			return s.classes[i].priority > s.classes[j].priority
+		}
+		return s.classes[i].name < s.classes[j].name
+	})
+	if metrics {
+		qosMetricsOnce.Do(func() {
+			prometheus.MustRegister(metricQoSQueued, metricQoSDropped, metricQoSSentBytes)
+		})
+	}
+	return s
+}
+
+func (s *qosScheduler) start() {
+	if s == nil {
+		return
+	}
+	go s.loop()
+}
+
+func (s *qosScheduler) stop() {
+	if s == nil {
+		return
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stopC)
+	}
+}
+
+// wait blocks until the msg of the class could be sent, or returns an error if the msg is dropped.
+// The msg granted is in flight until done is called.
+func (s *qosScheduler) wait(class string, size int) error {
+	if s == nil {
+		return nil
+	}
+	w, err := s.enqueue(class, size)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-w.granted:
+		return nil
+	case <-s.stopC:
+		return ErrorQoSStopped
+	}
+}
+
+// done ends the msg in flight, the msgs waiting for it are dispatched
+func (s *qosScheduler) done() {
+	if s == nil {
+		return
+	}
+	s.mtx.Lock()
+	if s.inflight > 0 {
+		s.inflight--
+	}
+	s.mtx.Unlock()
+	s.notify()
+}
+
+func (s *qosScheduler) notify() {
+	select {
+	case s.notifyC <- struct{}{}:
+	default:
+	}
+}
+
+func (s *qosScheduler) enqueue(className string, size int) (*qosWaiter, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.stopped {
+		return nil, ErrorQoSStopped
+	}
+	class := s.byName[className]
+	if len(class.waiters) >= class.queueSize {
+		if s.metrics {
+			metricQoSDropped.WithLabelValues(s.chainId, className).Inc()
+		}
+		return nil, ErrorQoSQueueFull
+	}
+	w := &qosWaiter{size: size, granted: make(chan struct{})}
+	class.waiters = append(class.waiters, w)
+	s.setQueued(class)
+	s.notify()
+	return w, nil
+}
+
+func (s *qosScheduler) loop() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		delay, pending := s.dispatch(time.Now())
+		var timeoutC <-chan time.Time
+		if pending {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(delay)
+			timeoutC = timer.C
+		}
+		select {
+		case <-s.notifyC:
+		case <-timeoutC:
+		case <-s.stopC:
+			return
+		}
+	}
+}
+
+// dispatch grants the msgs could be sent at now in the order of the priority of their classes,
+// and returns the delay before the next msg could be sent if any msg is still waiting for the bandwidth.
+// The msgs waiting for the msgs in flight are dispatched again once done.
+func (s *qosScheduler) dispatch(now time.Time) (delay time.Duration, pending bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for {
+		granted := false
+		for _, class := range s.classes {
+			if s.maxInflight > 0 && s.inflight >= s.maxInflight {
+				break
+			}
+			if len(class.waiters) == 0 {
+				continue
+			}
+			readyAt := class.next
+			if class.chainNext.After(readyAt) {
+				readyAt = class.chainNext
+			}
+			if readyAt.After(now) {
+				// the lower classes could use the bandwidth the class could not
+				if d := readyAt.Sub(now); !pending || d < delay {
+					delay = d
+				}
+				pending = true
+				continue
+			}
+			s.grant(class, now)
+			granted = true
+			break
+		}
+		if !granted {
+			return delay, pending
+		}
+		delay, pending = 0, false
+	}
+}
+
+// grant the first msg of the class, the caller holds the lock
+func (s *qosScheduler) grant(class *qosClass, now time.Time) {
+	w := class.waiters[0]
+	class.waiters[0] = nil
+	class.waiters = class.waiters[1:]
+	close(w.granted)
+	s.inflight++
+
+	class.next = advance(class.next, now, w.size, class.rate)
+	// the bandwidth used is not available to the classes of the same or lower priority,
+	// the higher classes preempt it
+	for _, c := range s.classes {
+		if c.priority <= class.priority {
+			c.chainNext = advance(c.chainNext, now, w.size, s.rate)
+		}
+	}
+	s.setQueued(class)
+	if s.metrics {
+		metricQoSSentBytes.WithLabelValues(s.chainId, class.name).Add(float64(w.size))
+	}
+}
+
+func advance(next, now time.Time, size int, rate float64) time.Time {
+	if next.Before(now) {
+		next = now
+	}
+	if rate <= 0 {
+		return next
+	}
+	return next.Add(time.Duration(float64(size) / rate * float64(time.Second)))
+}
+
+func (s *qosScheduler) setQueued(class *qosClass) {
+	if s.metrics {
+		metricQoSQueued.WithLabelValues(s.chainId, class.name).Set(float64(len(class.waiters)))
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package net
+
+import (
+	"testing"
+	"time"
+
+	netPb "chainmaker.org/chainmaker/pb-go/v2/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQoSConfigVerify(t *testing.T) {
+	config := &QoSConfig{
+		BandwidthKB: 1,
+		Classes:     map[string]*QoSClassConfig{QoSClassSync: {Priority: 3}},
+		MsgTypes:    map[string]string{netPb.NetMsg_TXS.String(): QoSClassSync},
+	}
+	require.Nil(t, config.Verify())
+	require.Equal(t, &QoSClassConfig{Priority: 3, BandwidthShare: 100, QueueSize: 256}, config.Classes[QoSClassSync])
+	require.Equal(t, DefaultQoSConfig().Classes[QoSClassConsensus], config.Classes[QoSClassConsensus])
+	require.Equal(t, QoSClassConsensus, config.classOf(netPb.NetMsg_CONSENSUS_MSG))
+	require.Equal(t, QoSClassTx, config.classOf(netPb.NetMsg_TX))
+	require.Equal(t, QoSClassSync, config.classOf(netPb.NetMsg_TXS))
+	require.Equal(t, QoSClassSync, config.classOf(netPb.NetMsg_BLOCKS))
+
+	require.NotNil(t, (&QoSConfig{Classes: map[string]*QoSClassConfig{"bulk": {}}}).Verify())
+	require.NotNil(t, (&QoSConfig{Classes: map[string]*QoSClassConfig{QoSClassTx: {Priority: 10}}}).Verify())
+	require.NotNil(t, (&QoSConfig{MsgTypes: map[string]string{"UNKNOWN": QoSClassTx}}).Verify())
+}
+
+func TestQoSScheduler(t *testing.T) {
+	// unlimited
+	var unlimited *qosScheduler
+	require.Nil(t, newQoSScheduler("chain1", nil, false))
+	require.Nil(t, unlimited.wait(QoSClassSync, 1<<20))
+
+	// 1 KB per second, sync could use half of it
+	config := &QoSConfig{
+		BandwidthKB: 1,
+		Classes:     map[string]*QoSClassConfig{QoSClassSync: {Priority: 5, BandwidthShare: 50, QueueSize: 1}},
+	}
+	require.Nil(t, config.Verify())
+	s := newQoSScheduler("chain1", config, false)
+	now := time.Now()
+
+	sync1, err := s.enqueue(QoSClassSync, 1024)
+	require.Nil(t, err)
+	_, pending := s.dispatch(now)
+	require.False(t, pending)
+	requireGranted(t, sync1)
+
+	// the consensus msgs preempt the bandwidth used by the sync msgs
+	consensus1, err := s.enqueue(QoSClassConsensus, 512)
+	require.Nil(t, err)
+	_, pending = s.dispatch(now)
+	require.False(t, pending)
+	requireGranted(t, consensus1)
+
+	// the sync msgs wait for the share of the class, the tx msgs wait for the bandwidth used by the consensus msgs
+	sync2, err := s.enqueue(QoSClassSync, 1)
+	require.Nil(t, err)
+	_, err = s.enqueue(QoSClassSync, 1)
+	require.Equal(t, ErrorQoSQueueFull, err)
+	tx1, err := s.enqueue(QoSClassTx, 1)
+	require.Nil(t, err)
+	delay, pending := s.dispatch(now)
+	require.True(t, pending)
+	require.Equal(t, 500*time.Millisecond, delay)
+
+	_, pending = s.dispatch(now.Add(delay))
+	require.True(t, pending)
+	requireGranted(t, tx1)
+	_, pending = s.dispatch(now.Add(2 * time.Second))
+	require.False(t, pending)
+	requireGranted(t, sync2)
+
+	s.stop()
+	_, err = s.enqueue(QoSClassTx, 1)
+	require.Equal(t, ErrorQoSStopped, err)
+}
+
+func TestQoSSchedulerInflight(t *testing.T) {
+	// the bandwidth is unlimited, the msgs waiting for the msgs in flight are sent by the priority
+	config := &QoSConfig{MaxInflight: 1}
+	require.Nil(t, config.Verify())
+	s := newQoSScheduler("chain1", config, false)
+	now := time.Now()
+
+	sync1, err := s.enqueue(QoSClassSync, 1<<20)
+	require.Nil(t, err)
+	_, pending := s.dispatch(now)
+	require.False(t, pending)
+	requireGranted(t, sync1)
+
+	sync2, err := s.enqueue(QoSClassSync, 1)
+	require.Nil(t, err)
+	consensus1, err := s.enqueue(QoSClassConsensus, 1)
+	require.Nil(t, err)
+	s.dispatch(now)
+	requireNotGranted(t, sync2)
+	requireNotGranted(t, consensus1)
+
+	s.done()
+	s.dispatch(now)
+	requireGranted(t, consensus1)
+	requireNotGranted(t, sync2)
+	s.done()
+	s.dispatch(now)
+	requireGranted(t, sync2)
+	s.stop()
+}
+
+func requireNotGranted(t *testing.T, w *qosWaiter) {
+	select {
+	case <-w.granted:
+		t.Fatal("msg granted")
+	default:
+	}
+}
+
+func requireGranted(t *testing.T, w *qosWaiter) {
+	select {
+	case <-w.granted:
+	default:
+		t.Fatal("msg not granted")
+	}
+}
@@ -13,7 +13,6 @@ import (
 
 	"chainmaker.org/chainmaker/common/v2/msgbus"
 	rootLog "chainmaker.org/chainmaker/logger/v2"
-	"chainmaker.org/chainmaker/net-common/common/priorityblocker"
 	configPb "chainmaker.org/chainmaker/pb-go/v2/config"
 	netPb "chainmaker.org/chainmaker/pb-go/v2/net"
 	"chainmaker.org/chainmaker/pb-go/v2/syscontract"
@@ -24,6 +23,9 @@ import (
 var (
 	ErrorChainMsgBusBeenBound = errors.New("chain msg bus has been bound")
 	ErrorNetNotRunning        = errors.New("net instance is not running")
+	// ErrorTxMsgSignature is wrapped by the tx msg validator refusing the signature of a tx,
+	// the peer is reported for PeerMisbehaviorInvalidSignature instead of PeerMisbehaviorInvalidTx
+	ErrorTxMsgSignature = errors.New("invalid signature of the tx msg")
 )
 
 const (
@@ -62,6 +64,30 @@ type NetService struct {
 	ac            protocol.AccessControlProvider
 	revokeNodeIds sync.Map // nolint: structcheck,unused // node id of node cert revoked , map[string]struct{}
 	vmWatcher     *VmWatcher
+
+	// the msg flags, topics and msg-bus subscribers bound on start, unbound on stop
+	msgBusFlags       []string
+	msgBusTopics      []string
+	msgBusSubscribers map[msgbus.Topic]msgbus.Subscriber
+
+	// qosConfig decides the class and the priority of the msgs, qos paces the msgs sent by the chain
+	qosConfig *QoSConfig
+	qos       *qosScheduler
+
+	// peerScores scores the peers by the misbehaviors reported, the msgs from the banned peers are dropped
+	peerScores *peerScorer
+	// txMsgValidator checks the payload of the tx msgs received, nil if the format is unknown
+	txMsgValidator func(payload []byte) error
+	// txMsgFilter drops the valid tx msgs the chain could not take, nil if all are taken
+	txMsgFilter func(payload []byte) bool
+
+	// traffic counts the bytes between the node and the peers, the latency is measured by the pings
+	traffic    *peerTraffic
+	pingStopC  chan struct{}
+	dialedLock sync.Mutex
+	dialed     []string
+	// disconnected are the peers refused by Disconnect until they are dialed again, guarded by dialedLock
+	disconnected map[string]struct{}
 }
 
 // NewNetService create a new net service instance.
@@ -73,21 +99,43 @@ func NewNetService(chainId string, localNet protocol.Net, ac protocol.AccessCont
 		consensusNodeIds: make(map[string]struct{}),
 		ac:               ac,
 		logger:           logger,
+		qosConfig:        DefaultQoSConfig(),
+		traffic:          newPeerTraffic(),
+		disconnected:     make(map[string]struct{}),
 	}
 	return ns
 }
 
 // BroadcastMsg broadcast a net msg to other nodes belongs to the same chain.
 func (ns *NetService) BroadcastMsg(msg []byte, msgType netPb.NetMsg_MsgType) error {
-	err := ns.broadcastMsg(msg, CreateFlagWithPrefixAndMsgType(topicNamePrefix, msgType))
+	err := ns.broadcastMsg(msg, msgType, CreateFlagWithPrefixAndMsgType(topicNamePrefix, msgType))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ns *NetService) broadcastMsg(msg []byte, topic string) error {
-	return ns.localNet.BroadcastWithChainId(ns.chainId, topic, msg)
+func (ns *NetService) broadcastMsg(msg []byte, msgType netPb.NetMsg_MsgType, topic string) error {
+	if err := ns.sendWithQoS(msgType, len(msg), func() error {
+		return ns.localNet.BroadcastWithChainId(ns.chainId, topic, msg)
+	}); err != nil {
+		return err
+	}
+	ns.traffic.sent(broadcastPeer, msgType, len(msg))
+	return nil
+}
+
+// sendWithQoS blocks until the msg of the type could be sent within the qos of the chain, then sends it.
+// The msg is in flight until send returns. A msg broadcast is counted once, though the pub-sub may send it
+// to several peers.
+func (ns *NetService) sendWithQoS(msgType netPb.NetMsg_MsgType, size int, send func() error) error {
+	class := ns.qosConfig.classOf(msgType)
+	if err := ns.qos.wait(class, size); err != nil {
+		ns.logger.Debugf("[NetService] msg (type:%s, class:%s) not sent, %s", msgType.String(), class, err.Error())
+		return err
+	}
+	defer ns.qos.done()
+	return send()
 }
 
 // Subscribe a pub-sub topic for receiving the msg that be broadcast by the other node.
@@ -101,6 +149,10 @@ func (ns *NetService) Subscribe(msgType netPb.NetMsg_MsgType, handler protocol.M
 
 func (ns *NetService) subscribe(handler protocol.MsgHandler, msgType netPb.NetMsg_MsgType, topic string) error {
 	h := func(publisher string, msg []byte) error {
+		if ns.refuses(publisher) {
+			return nil
+		}
+		ns.traffic.received(publisher, msgType, len(msg))
 		return handler(publisher, msg, msgType)
 	}
 	return ns.localNet.SubscribeWithChainId(ns.chainId, topic, h)
@@ -123,13 +175,20 @@ func (ns *NetService) getConsensusNodeIdList() []string {
 	return result
 }
 
+func (ns *NetService) isConsensusNode(nodeId string) bool {
+	ns.consensusNodeIdsLock.RLock()
+	defer ns.consensusNodeIdsLock.RUnlock()
+	_, ok := ns.consensusNodeIds[nodeId]
+	return ok
+}
+
 func (ns *NetService) isConsensusNodeIdListEmpty() bool {
 	ns.consensusNodeIdsLock.RLock()
 	defer ns.consensusNodeIdsLock.RUnlock()
 	return len(ns.consensusNodeIds) == 0
 }
 
-func (ns *NetService) consensusBroadcastMsg(msg []byte, topic string) error {
+func (ns *NetService) consensusBroadcastMsg(msg []byte, msgType netPb.NetMsg_MsgType, topic string) error {
 	consensusNodeIdList := ns.getConsensusNodeIdList()
 	if len(consensusNodeIdList) == 0 {
 		return nil
@@ -144,9 +203,15 @@ func (ns *NetService) consensusBroadcastMsg(msg []byte, topic string) error {
 		}
 		go func() {
 			defer wg.Done()
-			if err := ns.localNet.SendMsg(ns.chainId, to, topic, msg); err != nil {
-				ns.logger.Warnf("[NetService] send consensus broadcast msg failed, %s", err.Error())
+			if err := ns.sendWithQoS(msgType, len(msg), func() error {
+				return ns.localNet.SendMsg(ns.chainId, to, topic, msg)
+			}); err != nil {
+				if err != ErrorQoSQueueFull && err != ErrorQoSStopped {
+					ns.logger.Warnf("[NetService] send consensus broadcast msg failed, %s", err.Error())
+				}
+				return
 			}
+			ns.traffic.sent(to, msgType, len(msg))
 		}()
 	}
 	wg.Wait()
@@ -156,7 +221,7 @@ func (ns *NetService) consensusBroadcastMsg(msg []byte, topic string) error {
 // ConsensusBroadcastMsg only broadcast a net msg to other consensus nodes belongs to the same chain.
 func (ns *NetService) ConsensusBroadcastMsg(msg []byte, msgType netPb.NetMsg_MsgType) error {
 	pbMsg := NewNetMsg(msg, msgType, "")
-	return ns.consensusBroadcastMsg(msg, CreateFlagWithPrefixAndMsgType(consensusTopicNamePrefix, pbMsg.Type))
+	return ns.consensusBroadcastMsg(msg, pbMsg.Type, CreateFlagWithPrefixAndMsgType(consensusTopicNamePrefix, pbMsg.Type))
 }
 
 // ConsensusSubscribe create a listener for receiving the msg
@@ -181,14 +246,17 @@ func (ns *NetService) CancelConsensusSubscribe(msgType netPb.NetMsg_MsgType) err
 func (ns *NetService) SendMsg(msg []byte, msgType netPb.NetMsg_MsgType, to ...string) error {
 	msgFlag := msgType.String()
 	for _, n := range to {
-		if n == ns.localNet.GetNodeUid() {
+		if n == ns.localNet.GetNodeUid() || ns.isDisconnected(n) {
 			continue
 		}
-		err := ns.localNet.SendMsg(ns.chainId, n, msgFlag, msg)
+		err := ns.sendWithQoS(msgType, len(msg), func() error {
+			return ns.localNet.SendMsg(ns.chainId, n, msgFlag, msg)
+		})
 		if err != nil {
 			ns.logger.Debugf("[NetService] send msg failed(to:%s, flag:%s), %s", n, msgFlag, err.Error())
 			return err
 		}
+		ns.traffic.sent(n, msgType, len(msg))
 	}
 	return nil
 }
@@ -203,6 +271,10 @@ func (ns *NetService) ReceiveMsg(msgType netPb.NetMsg_MsgType, handler protocol.
 
 func (ns *NetService) receiveMsg(handler protocol.MsgHandler, flag string, msgType netPb.NetMsg_MsgType) error {
 	h := func(from string, data []byte) error {
+		if ns.refuses(from) {
+			return nil
+		}
+		ns.traffic.received(from, msgType, len(data))
 		err := handler(from, data, msgType)
 		if err != nil {
 			return err
@@ -222,7 +294,12 @@ func (ns *NetService) cancelReceiveMsg(flag string) error {
 type MsgForMsgBusHandler func(chainId string, from string, msg []byte) error
 
 func (ns *NetService) receiveMsgForMsgBus(handler MsgForMsgBusHandler, flag string) error {
+	msgType := msgTypeOfFlag(flag)
 	h := func(from string, data []byte) error {
+		if ns.refuses(from) {
+			return nil
+		}
+		ns.traffic.received(from, msgType, len(data))
 		err := handler(ns.chainId, from, data)
 		if err != nil {
 			return err
@@ -230,11 +307,20 @@ func (ns *NetService) receiveMsgForMsgBus(handler MsgForMsgBusHandler, flag stri
 		return nil
 	}
 
-	return ns.localNet.DirectMsgHandle(ns.chainId, flag, h)
+	if err := ns.localNet.DirectMsgHandle(ns.chainId, flag, h); err != nil {
+		return err
+	}
+	ns.msgBusFlags = append(ns.msgBusFlags, flag)
+	return nil
 }
 
 func (ns *NetService) subscribeTopicForMsgBus(handler MsgForMsgBusHandler, topic string) error {
+	msgType := msgTypeOfFlag(topic)
 	h := func(from string, data []byte) error {
+		if ns.refuses(from) {
+			return nil
+		}
+		ns.traffic.received(from, msgType, len(data))
 		err := handler(ns.chainId, from, data)
 		if err != nil {
 			return err
@@ -242,7 +328,47 @@ func (ns *NetService) subscribeTopicForMsgBus(handler MsgForMsgBusHandler, topic
 		return nil
 	}
 
-	return ns.localNet.SubscribeWithChainId(ns.chainId, topic, h)
+	if err := ns.localNet.SubscribeWithChainId(ns.chainId, topic, h); err != nil {
+		return err
+	}
+	ns.msgBusTopics = append(ns.msgBusTopics, topic)
+	return nil
+}
+
+func (ns *NetService) registerMsgBusSubscriber(topic msgbus.Topic, sub msgbus.Subscriber) {
+	if ns.msgBusSubscribers == nil {
+		ns.msgBusSubscribers = make(map[msgbus.Topic]msgbus.Subscriber)
+	}
+	ns.msgBus.Register(topic, sub)
+	ns.msgBusSubscribers[topic] = sub
+}
+
+// ReportPeer lowers the score of the peer for its misbehavior, such as the PeerMisbehaviorXxx.
+// The msgs from the peer are dropped for a while when its score drops to the threshold.
+func (ns *NetService) ReportPeer(peer string, misbehavior string) {
+	if peer == "" || peer == ns.localNet.GetNodeUid() {
+		return
+	}
+	if ns.peerScores.report(peer, misbehavior) {
+		ns.logger.Warnf("[NetService] ban peer %s for %ds, the last misbehavior: %s",
+			peer, ns.peerScores.config.BanSeconds, misbehavior)
+		return
+	}
+	ns.logger.Debugf("[NetService] peer %s misbehaved: %s", peer, misbehavior)
+}
+
+// PeerScores returns the scores of the peers misbehaved, the lowest first.
+func (ns *NetService) PeerScores() []*PeerScore {
+	return ns.peerScores.list()
+}
+
+// UnbanPeer lifts the ban of the peer.
+func (ns *NetService) UnbanPeer(peer string) error {
+	if err := ns.peerScores.unban(peer); err != nil {
+		return err
+	}
+	ns.logger.Infof("[NetService] unban peer %s", peer)
+	return nil
 }
 
 // GetNodeUidByCertId return the id of the node connected to us which mapped to tls cert id given.
@@ -267,8 +393,12 @@ func (ns *NetService) Start() error {
 		ns.logger.Errorf("[NetService] start the net first pls.")
 		return ErrorNetNotRunning
 	}
-	// add access control
-	ns.localNet.AddAC(ns.chainId, ns.ac)
+	// add access control, the peers disconnected are refused by it
+	ac := ns.ac
+	if ac != nil {
+		ac = &netAccessControl{AccessControlProvider: ns.ac, ns: ns}
+	}
+	ns.localNet.AddAC(ns.chainId, ac)
 
 	// init pub-sub
 	if err := ns.localNet.InitPubSub(ns.chainId, 0); err != nil {
@@ -283,15 +413,24 @@ func (ns *NetService) Start() error {
 		return err
 	}
 
-	ns.setFlagPriority()
+	if err := ns.startPing(); err != nil {
+		return err
+	}
+
+	ns.qos.start()
 
 	ns.logger.Infof("[NetService] net service started.")
 	return nil
 }
 
 // Stop the net-service.
+// The msg handlers bound to the msg-bus are unbound, so that the chain could be paused and started again.
 func (ns *NetService) Stop() error {
-	return nil
+	ns.qos.stop()
+	if err := ns.stopPing(); err != nil {
+		ns.logger.Warnf("[NetService] stop ping failed, %s", err.Error())
+	}
+	return ns.unbindMsgBus()
 }
 
 // ConfigWatcher return a implementation of protocol.Watcher. It is used for refreshing the config.
@@ -407,6 +546,7 @@ func handleMsgBusSubscriberOnMessageBroadcast(
 		!netService.isConsensusNodeIdListEmpty() {
 		if err := netService.consensusBroadcastMsg(
 			netMsg.GetPayload(),
+			msgType,
 			CreateFlagWithPrefixAndMsgType(msgBusConsensusTopicPrefix, msgType),
 		); err != nil {
 			netService.logger.Debugf(
@@ -419,6 +559,7 @@ func handleMsgBusSubscriberOnMessageBroadcast(
 	} else {
 		if err := netService.broadcastMsg(
 			netMsg.GetPayload(),
+			msgType,
 			CreateFlagWithPrefixAndMsgType(msgBusTopicPrefix, msgType),
 		); err != nil {
 			netService.logger.Debugf(
@@ -439,13 +580,15 @@ func handleMsgBusSubscriberOnMessageSend(
 	logMsgDescription string,
 	netMsg *netPb.NetMsg) error {
 	go func() {
-		if err := netService.localNet.SendMsg(
-			netService.chainId, netMsg.To, CreateFlagWithPrefixAndMsgType(
-				msgBusMsgFlagPrefix,
-				msgType,
-			),
-			netMsg.GetPayload(),
-		); err != nil {
+		if err := netService.sendWithQoS(msgType, len(netMsg.GetPayload()), func() error {
+			return netService.localNet.SendMsg(
+				netService.chainId, netMsg.To, CreateFlagWithPrefixAndMsgType(
+					msgBusMsgFlagPrefix,
+					msgType,
+				),
+				netMsg.GetPayload(),
+			)
+		}); err != nil {
 			netService.logger.Debugf(
 				"[NetService/msg-bus %s subscriber] send msg failed (size:%d) (reason:%s) (to:%s)",
 				logMsgDescription,
@@ -454,6 +597,7 @@ func handleMsgBusSubscriberOnMessageSend(
 				netMsg.To,
 			)
 		} else {
+			netService.traffic.sent(netMsg.To, msgType, len(netMsg.GetPayload()))
 			netService.logger.Debugf(
 				"[NetService/msg-bus %s subscriber] send msg ok (size:%d) (to:%s)",
 				logMsgDescription,
@@ -632,18 +776,18 @@ func (ns *NetService) initBindMsgBus() error {
 	cmSubscriber := &ConsensusMsgSubscriber{
 		netService: ns,
 	}
-	ns.msgBus.Register(msgbus.SendConsensusMsg, cmSubscriber)
+	ns.registerMsgBusSubscriber(msgbus.SendConsensusMsg, cmSubscriber)
 
 	// ===========================================
 
 	// for tx pool module
 	// receive tx msg from net then publish to msg-bus
-	txPoolMsgHandler := CreateMsgHandlerForMsgBus(
+	txPoolMsgHandler := ns.validateTxMsg(CreateMsgHandlerForMsgBus(
 		ns,
 		msgbus.RecvTxPoolMsg,
 		"tx_pool msg",
 		netPb.NetMsg_TX,
-	)
+	))
 	if err := ns.receiveMsgForMsgBus(
 		txPoolMsgHandler,
 		CreateFlagWithPrefixAndMsgType(
@@ -677,7 +821,7 @@ func (ns *NetService) initBindMsgBus() error {
 	txPoolSubscriber := &TxPoolMsgSubscriber{
 		netService: ns,
 	}
-	ns.msgBus.Register(msgbus.SendTxPoolMsg, txPoolSubscriber)
+	ns.registerMsgBusSubscriber(msgbus.SendTxPoolMsg, txPoolSubscriber)
 
 	// ===========================================
 
@@ -712,45 +856,57 @@ func (ns *NetService) initBindMsgBus() error {
 	sbmSubscriber := &SyncBlockMsgSubscriber{
 		netService: ns,
 	}
-	ns.msgBus.Register(msgbus.SendSyncBlockMsg, sbmSubscriber)
+	ns.registerMsgBusSubscriber(msgbus.SendSyncBlockMsg, sbmSubscriber)
 	ns.logger.Infof("[NetService] init bind msg-bus ok")
 	return nil
 }
 
-func (ns *NetService) setFlagPriority() {
-	ns.localNet.SetMsgPriority(netPb.NetMsg_CONSENSUS_MSG.String(), uint8(priorityblocker.PriorityLevel9))
-	ns.localNet.SetMsgPriority(netPb.NetMsg_BLOCK.String(), uint8(priorityblocker.PriorityLevel8))
-	ns.localNet.SetMsgPriority(netPb.NetMsg_BLOCKS.String(), uint8(priorityblocker.PriorityLevel8))
-	ns.localNet.SetMsgPriority(netPb.NetMsg_TX.String(), uint8(priorityblocker.PriorityLevel7))
-	ns.localNet.SetMsgPriority(netPb.NetMsg_TXS.String(), uint8(priorityblocker.PriorityLevel7))
-	ns.localNet.SetMsgPriority(netPb.NetMsg_SYNC_BLOCK_MSG.String(), uint8(priorityblocker.PriorityLevel5))
+// validateTxMsg drops the tx msgs refused by the validator and reports the peers sent them,
+// then drops those refused by the filter
+func (ns *NetService) validateTxMsg(handler MsgForMsgBusHandler) MsgForMsgBusHandler {
+	if ns.txMsgValidator == nil && ns.txMsgFilter == nil {
+		return handler
+	}
+	return func(chainId string, from string, msg []byte) error {
+		if ns.txMsgValidator != nil {
+			if err := ns.txMsgValidator(msg); err != nil {
+				ns.logger.Debugf("[NetService] drop invalid tx msg from %s, %s", from, err.Error())
+				misbehavior := PeerMisbehaviorInvalidTx
+				if errors.Is(err, ErrorTxMsgSignature) {
+					misbehavior = PeerMisbehaviorInvalidSignature
+				}
+				ns.ReportPeer(from, misbehavior)
+				return nil
+			}
+		}
+		if ns.txMsgFilter != nil && !ns.txMsgFilter(msg) {
+			ns.logger.Debugf("[NetService] drop tx msg from %s, refused by the filter", from)
+			return nil
+		}
+		return handler(chainId, from, msg)
+	}
+}
 
-	ns.localNet.SetMsgPriority(
-		CreateFlagWithPrefixAndMsgType(msgBusMsgFlagPrefix, netPb.NetMsg_CONSENSUS_MSG),
-		uint8(priorityblocker.PriorityLevel9),
-	)
-	ns.localNet.SetMsgPriority(
-		CreateFlagWithPrefixAndMsgType(msgBusConsensusTopicPrefix, netPb.NetMsg_CONSENSUS_MSG),
-		uint8(priorityblocker.PriorityLevel9),
-	)
-	ns.localNet.SetMsgPriority(
-		CreateFlagWithPrefixAndMsgType(msgBusMsgFlagPrefix, netPb.NetMsg_TX),
-		uint8(priorityblocker.PriorityLevel7),
-	)
-	ns.localNet.SetMsgPriority(
-		CreateFlagWithPrefixAndMsgType(msgBusConsensusTopicPrefix, netPb.NetMsg_TX),
-		uint8(priorityblocker.PriorityLevel7),
-	)
-	ns.localNet.SetMsgPriority(
-		CreateFlagWithPrefixAndMsgType(topicNamePrefix, netPb.NetMsg_TX),
-		uint8(priorityblocker.PriorityLevel7),
-	)
-	ns.localNet.SetMsgPriority(
-		CreateFlagWithPrefixAndMsgType(msgBusMsgFlagPrefix, netPb.NetMsg_SYNC_BLOCK_MSG),
-		uint8(priorityblocker.PriorityLevel5),
-	)
-	ns.localNet.SetMsgPriority(
-		CreateFlagWithPrefixAndMsgType(msgBusTopicPrefix, netPb.NetMsg_SYNC_BLOCK_MSG),
-		uint8(priorityblocker.PriorityLevel5),
-	)
+func (ns *NetService) unbindMsgBus() error {
+	var firstErr error
+	for _, flag := range ns.msgBusFlags {
+		if err := ns.localNet.CancelDirectMsgHandle(ns.chainId, flag); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, topic := range ns.msgBusTopics {
+		if err := ns.localNet.CancelSubscribeWithChainId(ns.chainId, topic); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for topic, sub := range ns.msgBusSubscribers {
+		ns.msgBus.UnRegister(topic, sub)
+	}
+	ns.msgBusFlags, ns.msgBusTopics, ns.msgBusSubscribers = nil, nil, nil
+	if firstErr != nil {
+		ns.logger.Warnf("[NetService] unbind msg-bus failed, %s", firstErr.Error())
+		return firstErr
+	}
+	ns.logger.Infof("[NetService] unbind msg-bus ok")
+	return nil
 }
@@ -43,3 +43,52 @@ func WithConsensusNodeUid(consensusNodeUid ...string) NetServiceOption {
 		return nil
 	}
 }
+
+// WithQoS set the qos config of the msgs sent by the chain, the metrics of the qos are exported if metrics is true.
+func WithQoS(config *QoSConfig, metrics bool) NetServiceOption {
+	return func(ns *NetService) error {
+		if config == nil {
+			return nil
+		}
+		if err := config.Verify(); err != nil {
+			return err
+		}
+		ns.qosConfig = config
+		ns.qos = newQoSScheduler(ns.chainId, config, metrics)
+		return nil
+	}
+}
+
+// WithPeerScore set the config of scoring the peers, the metrics of the scores are exported if metrics is true.
+// The peers are not scored if the config is nil or not enabled, the consensus nodes are never banned.
+func WithPeerScore(config *PeerScoreConfig, metrics bool) NetServiceOption {
+	return func(ns *NetService) error {
+		if config == nil || !config.Enabled {
+			ns.peerScores = nil
+			return nil
+		}
+		if err := config.Verify(); err != nil {
+			return err
+		}
+		ns.peerScores = newPeerScorer(ns.chainId, config, metrics)
+		ns.peerScores.exempt = ns.isConsensusNode
+		return nil
+	}
+}
+
+// WithTxMsgValidator set the validator of the tx msgs received, the peers sending the invalid ones are reported.
+func WithTxMsgValidator(validator func(payload []byte) error) NetServiceOption {
+	return func(ns *NetService) error {
+		ns.txMsgValidator = validator
+		return nil
+	}
+}
+
+// WithTxMsgFilter set the filter of the valid tx msgs received, the msgs it refuses are dropped
+// without reporting the peers, such as the txs exceeding the resource budget of the chain.
+func WithTxMsgFilter(filter func(payload []byte) bool) NetServiceOption {
+	return func(ns *NetService) error {
+		ns.txMsgFilter = filter
+		return nil
+	}
+}
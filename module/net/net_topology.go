@@ -0,0 +1,448 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package net
+
+import (
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bccrypto "chainmaker.org/chainmaker/common/v2/crypto"
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	bcx509 "chainmaker.org/chainmaker/common/v2/crypto/x509"
+	"chainmaker.org/chainmaker/common/v2/helper"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	netPb "chainmaker.org/chainmaker/pb-go/v2/net"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+// NetAdminContractName is the pseudo contract of the queries of the p2p layer, they are served
+// by the rpc server of the node and never go on chain.
+const NetAdminContractName = "NET_ADMIN"
+
+// The methods of NetAdminContractName, NetAdminMethodDial and NetAdminMethodDisconnect require an admin
+// of the org of the node.
+const (
+	NetAdminMethodPeers      = "GET_PEERS"
+	NetAdminMethodSeeds      = "GET_SEEDS"
+	NetAdminMethodDial       = "DIAL"
+	NetAdminMethodDisconnect = "DISCONNECT"
+	// NetAdminParamAddress is the address to dial, such as /ip4/127.0.0.1/tcp/11301/p2p/QmXXX
+	NetAdminParamAddress = "address"
+	// NetAdminParamNodeId is the node id to disconnect
+	NetAdminParamNodeId = "node_id"
+)
+
+const (
+	// netPingFlag is the flag of the direct msgs measuring the latency of the peers, the peers
+	// not handling it are shown without latency.
+	netPingFlag     = "NET_PING"
+	netPingInterval = 30 * time.Second
+	netPingSize     = 9
+	// peerTrafficRetention is how long the traffic of a peer gone is kept, so a peer reconnecting
+	// soon keeps its counters
+	peerTrafficRetention = 10 * netPingInterval
+
+	netPingKindPing byte = 0
+	netPingKindPong byte = 1
+
+	// broadcastPeer is the peer the bytes broadcast by the pub-sub are counted to,
+	// the peers the pub-sub sends them to are unknown.
+	broadcastPeer = ""
+)
+
+// PeerDisconnecter is implemented by the protocol.Net which could close the connections to a peer.
+type PeerDisconnecter interface {
+	// ClosePeer closes the connections to the peer until it is added as a seed again.
+	ClosePeer(uid string) error
+}
+
+// PeerInfo is a peer connected to a chain with the traffic between the node and it.
+type PeerInfo struct {
+	NodeId    string   `json:"node_id"`
+	OrgId     string   `json:"org_id,omitempty"`
+	Addresses []string `json:"addresses"`
+	// LatencyMs is the round trip time of the last ping, 0 if the peer has not answered
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	// BytesIn and BytesOut are the bytes of the msgs by the name of their netPb.NetMsg_MsgType
+	BytesIn  map[string]uint64 `json:"bytes_in"`
+	BytesOut map[string]uint64 `json:"bytes_out"`
+	LastSeen string            `json:"last_seen,omitempty"`
+	// Disconnected is true if the peer is refused by Disconnect, the net provider may keep the connection
+	// open until the peer is re-verified
+	Disconnected bool `json:"disconnected,omitempty"`
+	// Chains are the chains on the node the peer also serves
+	Chains []string `json:"chains,omitempty"`
+}
+
+// ChainPeers are the peers connected to a chain.
+type ChainPeers struct {
+	ChainId string      `json:"chain_id"`
+	NodeId  string      `json:"node_id"`
+	Peers   []*PeerInfo `json:"peers"`
+	// BroadcastBytesOut are the bytes broadcast by the pub-sub, which are not counted to any peer
+	BroadcastBytesOut map[string]uint64 `json:"broadcast_bytes_out"`
+}
+
+// SeedInfo is a seed of the node and its connection state.
+type SeedInfo struct {
+	Address   string `json:"address"`
+	NodeId    string `json:"node_id,omitempty"`
+	Connected bool   `json:"connected"`
+	// Dialed is true if the seed is added at runtime rather than configured
+	Dialed bool `json:"dialed,omitempty"`
+}
+
+// SeedNodeId returns the node id in the address of a seed, empty if the address does not contain one.
+func SeedNodeId(address string) string {
+	i := strings.LastIndex(address, "/p2p/")
+	if i < 0 {
+		return ""
+	}
+	return strings.SplitN(address[i+len("/p2p/"):], "/", 2)[0]
+}
+
+// orgIdOfCert returns the organization of the tls cert of a peer, empty if the peer has no cert.
+func orgIdOfCert(certPem []byte) string {
+	if len(certPem) == 0 {
+		return ""
+	}
+	der := certPem
+	if block, _ := pem.Decode(certPem); block != nil {
+		der = block.Bytes
+	}
+	cert, err := bcx509.ParseCertificate(der)
+	if err != nil || len(cert.Subject.Organization) == 0 {
+		return ""
+	}
+	return cert.Subject.Organization[0]
+}
+
+// peerTraffic counts the bytes between the node and the peers of a chain. All methods are no-op on nil.
+type peerTraffic struct {
+	mtx   sync.Mutex
+	peers map[string]*peerTrafficState
+	now   func() time.Time
+}
+
+type peerTrafficState struct {
+	bytesIn  map[string]uint64
+	bytesOut map[string]uint64
+	lastSeen time.Time
+	latency  time.Duration
+}
+
+func newPeerTraffic() *peerTraffic {
+	return &peerTraffic{peers: make(map[string]*peerTrafficState), now: time.Now}
+}
+
+func (t *peerTraffic) received(peer string, msgType netPb.NetMsg_MsgType, size int) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	state := t.state(peer)
+	state.bytesIn[msgType.String()] += uint64(size)
+	state.lastSeen = t.now()
+}
+
+func (t *peerTraffic) sent(peer string, msgType netPb.NetMsg_MsgType, size int) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.state(peer).bytesOut[msgType.String()] += uint64(size)
+}
+
+func (t *peerTraffic) setLatency(peer string, latency time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	state := t.state(peer)
+	state.latency = latency
+	state.lastSeen = t.now()
+}
+
+// fill sets the traffic of the peer to the info
+func (t *peerTraffic) fill(info *PeerInfo) {
+	info.BytesIn = make(map[string]uint64)
+	info.BytesOut = make(map[string]uint64)
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	state, ok := t.peers[info.NodeId]
+	if !ok {
+		return
+	}
+	copyBytes(info.BytesIn, state.bytesIn)
+	copyBytes(info.BytesOut, state.bytesOut)
+	info.LatencyMs = float64(state.latency) / float64(time.Millisecond)
+	if !state.lastSeen.IsZero() {
+		info.LastSeen = state.lastSeen.Format(time.RFC3339)
+	}
+}
+
+func (t *peerTraffic) broadcastBytes() map[string]uint64 {
+	bytes := make(map[string]uint64)
+	if t == nil {
+		return bytes
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if state, ok := t.peers[broadcastPeer]; ok {
+		copyBytes(bytes, state.bytesOut)
+	}
+	return bytes
+}
+
+// prune drops the traffic of the peers neither connected nor seen within peerTrafficRetention
+func (t *peerTraffic) prune(connected map[string]bool) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for peer, state := range t.peers {
+		if peer != broadcastPeer && !connected[peer] && t.now().Sub(state.lastSeen) > peerTrafficRetention {
+			delete(t.peers, peer)
+		}
+	}
+}
+
+// state returns the state of the peer, the caller holds the lock
+func (t *peerTraffic) state(peer string) *peerTrafficState {
+	state, ok := t.peers[peer]
+	if !ok {
+		state = &peerTrafficState{bytesIn: make(map[string]uint64), bytesOut: make(map[string]uint64)}
+		t.peers[peer] = state
+	}
+	return state
+}
+
+func copyBytes(dst, src map[string]uint64) {
+	for msgType, bytes := range src {
+		dst[msgType] = bytes
+	}
+}
+
+// msgTypeOfFlag returns the msg type of a flag created by CreateFlagWithPrefixAndMsgType
+func msgTypeOfFlag(flag string) netPb.NetMsg_MsgType {
+	name := flag
+	if i := strings.LastIndex(flag, topicSeparator); i >= 0 {
+		name = flag[i+len(topicSeparator):]
+	}
+	return netPb.NetMsg_MsgType(netPb.NetMsg_MsgType_value[name])
+}
+
+// Peers returns the peers connected to the chain, the chains they serve are not set.
+func (ns *NetService) Peers() (*ChainPeers, error) {
+	infos, err := ns.localNet.ChainNodesInfo(ns.chainId)
+	if err != nil {
+		return nil, err
+	}
+	chainPeers := &ChainPeers{
+		ChainId:           ns.chainId,
+		NodeId:            ns.localNet.GetNodeUid(),
+		Peers:             make([]*PeerInfo, 0, len(infos)),
+		BroadcastBytesOut: ns.traffic.broadcastBytes(),
+	}
+	for _, info := range infos {
+		peer := &PeerInfo{
+			NodeId:    info.NodeUid,
+			OrgId:     orgIdOfCert(info.NodeTlsCert),
+			Addresses: info.NodeAddress,
+		}
+		ns.traffic.fill(peer)
+		peer.Disconnected = ns.isDisconnected(peer.NodeId)
+		chainPeers.Peers = append(chainPeers.Peers, peer)
+	}
+	sort.Slice(chainPeers.Peers, func(i, j int) bool {
+		return chainPeers.Peers[i].NodeId < chainPeers.Peers[j].NodeId
+	})
+	return chainPeers, nil
+}
+
+// Dial connects to the address and keeps it as a seed, the address must contain the node id.
+func (ns *NetService) Dial(address string) error {
+	if SeedNodeId(address) == "" {
+		return fmt.Errorf("node id not found in address %s, such as /ip4/127.0.0.1/tcp/11301/p2p/QmXXX", address)
+	}
+	if err := ns.localNet.AddSeed(address); err != nil {
+		return err
+	}
+	ns.dialedLock.Lock()
+	defer ns.dialedLock.Unlock()
+	delete(ns.disconnected, SeedNodeId(address))
+	for _, dialed := range ns.dialed {
+		if dialed == address {
+			return nil
+		}
+	}
+	ns.dialed = append(ns.dialed, address)
+	ns.logger.Infof("[NetService] dial %s", address)
+	return nil
+}
+
+// DialedSeeds returns the seeds added by Dial.
+func (ns *NetService) DialedSeeds() []string {
+	ns.dialedLock.Lock()
+	defer ns.dialedLock.Unlock()
+	return append([]string(nil), ns.dialed...)
+}
+
+// Disconnect refuses the peer until it is dialed again: the msgs from and to it are dropped by the chain, the
+// connections to it are closed if the net could close a peer, and the net re-verifies the peers, the ones the
+// access control of the chain reports frozen are closed by the net, see netAccessControl.
+func (ns *NetService) Disconnect(peer string) error {
+	if peer == "" || peer == ns.localNet.GetNodeUid() {
+		return fmt.Errorf("invalid peer %s to disconnect", peer)
+	}
+	ns.dialedLock.Lock()
+	ns.disconnected[peer] = struct{}{}
+	ns.dialedLock.Unlock()
+	if disconnecter, ok := ns.localNet.(PeerDisconnecter); ok {
+		if err := disconnecter.ClosePeer(peer); err != nil {
+			return err
+		}
+	}
+	ns.localNet.ReVerifyPeers(ns.chainId)
+	ns.logger.Infof("[NetService] disconnect peer %s", peer)
+	return nil
+}
+
+func (ns *NetService) isDisconnected(peer string) bool {
+	ns.dialedLock.Lock()
+	defer ns.dialedLock.Unlock()
+	_, ok := ns.disconnected[peer]
+	return ok
+}
+
+// refuses returns whether the msgs from the peer are dropped, the peer is banned or disconnected
+func (ns *NetService) refuses(peer string) bool {
+	return ns.peerScores.isBanned(peer) || ns.isDisconnected(peer)
+}
+
+// netAccessControl is the access control of the chain added to the net, which reports the members of the
+// peers disconnected frozen, so that the net closes them when it re-verifies the peers.
+type netAccessControl struct {
+	protocol.AccessControlProvider
+	ns *NetService
+}
+
+// GetMemberStatus returns frozen for the peers disconnected, the status by the access control otherwise
+func (ac *netAccessControl) GetMemberStatus(member *pbac.Member) (pbac.MemberStatus, error) {
+	if nodeId := nodeIdOfMember(member); nodeId != "" && ac.ns.isDisconnected(nodeId) {
+		return pbac.MemberStatus_FROZEN, nil
+	}
+	return ac.AccessControlProvider.GetMemberStatus(member)
+}
+
+// nodeIdOfMember returns the node id of the tls cert or the public key of a member, empty if unknown
+func nodeIdOfMember(member *pbac.Member) string {
+	var (
+		nodeId string
+		err    error
+	)
+	switch member.MemberType {
+	case pbac.MemberType_CERT:
+		nodeId, err = helper.GetLibp2pPeerIdFromCert(member.MemberInfo)
+	case pbac.MemberType_PUBLIC_KEY:
+		var pk bccrypto.PublicKey
+		if pk, err = asym.PublicKeyFromPEM(member.MemberInfo); err == nil {
+			nodeId, err = helper.CreateLibp2pPeerIdWithPublicKey(pk)
+		}
+	}
+	if err != nil {
+		return ""
+	}
+	return nodeId
+}
+
+// startPing answers the pings of the peers and pings them periodically to measure the latency
+func (ns *NetService) startPing() error {
+	if err := ns.localNet.DirectMsgHandle(ns.chainId, netPingFlag, ns.handlePing); err != nil {
+		return err
+	}
+	ns.pingStopC = make(chan struct{})
+	go func(stopC chan struct{}) {
+		ticker := time.NewTicker(netPingInterval)
+		defer ticker.Stop()
+		for {
+			ns.pingPeers()
+			select {
+			case <-ticker.C:
+			case <-stopC:
+				return
+			}
+		}
+	}(ns.pingStopC)
+	return nil
+}
+
+func (ns *NetService) stopPing() error {
+	if ns.pingStopC == nil {
+		return nil
+	}
+	close(ns.pingStopC)
+	ns.pingStopC = nil
+	return ns.localNet.CancelDirectMsgHandle(ns.chainId, netPingFlag)
+}
+
+func (ns *NetService) pingPeers() {
+	infos, err := ns.localNet.ChainNodesInfo(ns.chainId)
+	if err != nil {
+		return
+	}
+	ping := newNetPing(netPingKindPing, time.Now())
+	connected := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		connected[info.NodeUid] = true
+		if ns.isDisconnected(info.NodeUid) {
+			continue
+		}
+		if err = ns.localNet.SendMsg(ns.chainId, info.NodeUid, netPingFlag, ping); err != nil {
+			ns.logger.Debugf("[NetService] ping %s failed, %s", info.NodeUid, err.Error())
+		}
+	}
+	ns.traffic.prune(connected)
+}
+
+func (ns *NetService) handlePing(from string, msg []byte) error {
+	if len(msg) != netPingSize || ns.refuses(from) {
+		return nil
+	}
+	switch msg[0] {
+	case netPingKindPing:
+		pong := append([]byte(nil), msg...)
+		pong[0] = netPingKindPong
+		return ns.localNet.SendMsg(ns.chainId, from, netPingFlag, pong)
+	case netPingKindPong:
+		sentAt := time.Unix(0, int64(binary.BigEndian.Uint64(msg[1:])))
+		if latency := time.Since(sentAt); latency >= 0 && latency < netPingInterval {
+			ns.traffic.setLatency(from, latency)
+		}
+	}
+	return nil
+}
+
+// newNetPing returns a ping or pong, which is the kind followed by the unix nano time the ping is sent at
+func newNetPing(kind byte, sentAt time.Time) []byte {
+	msg := make([]byte, netPingSize)
+	msg[0] = kind
+	binary.BigEndian.PutUint64(msg[1:], uint64(sentAt.UnixNano()))
+	return msg
+}
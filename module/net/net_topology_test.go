@@ -0,0 +1,154 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package net
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker/common/v2/crypto/asym"
+	"chainmaker.org/chainmaker/common/v2/helper"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	netPb "chainmaker.org/chainmaker/pb-go/v2/net"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedNodeId(t *testing.T) {
+	require.Equal(t, "QmA", SeedNodeId("/ip4/127.0.0.1/tcp/11301/p2p/QmA"))
+	require.Equal(t, "b", SeedNodeId(memoryAddrPrefix+"net1/p2p/b"))
+	require.Equal(t, "", SeedNodeId("/ip4/127.0.0.1/tcp/11301"))
+
+	require.Equal(t, netPb.NetMsg_TX, msgTypeOfFlag(CreateFlagWithPrefixAndMsgType(msgBusMsgFlagPrefix, netPb.NetMsg_TX)))
+	require.Equal(t, netPb.NetMsg_BLOCKS, msgTypeOfFlag(netPb.NetMsg_BLOCKS.String()))
+}
+
+func TestNetServicePeers(t *testing.T) {
+	const network = "TestNetServicePeers"
+	defer RemoveMemoryNetwork(network)
+	var nsf NetServiceFactory
+	newNetService := func(uid string) *NetService {
+		var nf NetFactory
+		n, err := nf.NewNet(MemoryNetType, WithListenAddr(memoryAddrPrefix+network))
+		require.Nil(t, err)
+		n.(*MemoryNet).SetNodeUid(uid)
+		require.Nil(t, n.Start())
+		ns, err := nsf.NewNetService(n, chainId1, nil, nil)
+		require.Nil(t, err)
+		require.Nil(t, ns.Start())
+		return ns.(*NetService)
+	}
+	a := newNetService("a")
+	b := newNetService("b")
+	defer func() {
+		require.Nil(t, a.Stop())
+		require.Nil(t, b.Stop())
+	}()
+
+	receivedC := make(chan struct{}, 1)
+	require.Nil(t, b.ReceiveMsg(netPb.NetMsg_TX, func(_ string, _ []byte, _ netPb.NetMsg_MsgType) error {
+		receivedC <- struct{}{}
+		return nil
+	}))
+	require.Nil(t, a.SendMsg([]byte("hello"), netPb.NetMsg_TX, "b"))
+	select {
+	case <-receivedC:
+	case <-time.After(time.Second):
+		t.Fatal("msg not received")
+	}
+	a.pingPeers()
+	require.Eventually(t, func() bool {
+		peers, err := a.Peers()
+		require.Nil(t, err)
+		return len(peers.Peers) == 1 && peers.Peers[0].LatencyMs > 0
+	}, time.Second, 10*time.Millisecond)
+
+	peers, err := a.Peers()
+	require.Nil(t, err)
+	require.Equal(t, "a", peers.NodeId)
+	require.Equal(t, "b", peers.Peers[0].NodeId)
+	require.Equal(t, uint64(5), peers.Peers[0].BytesOut[netPb.NetMsg_TX.String()])
+	peers, err = b.Peers()
+	require.Nil(t, err)
+	require.Equal(t, uint64(5), peers.Peers[0].BytesIn[netPb.NetMsg_TX.String()])
+	require.NotEmpty(t, peers.Peers[0].LastSeen)
+
+	// the peer disconnected is refused until it is dialed again
+	require.NotNil(t, a.Disconnect("a"))
+	require.Nil(t, a.Disconnect("b"))
+	require.True(t, a.refuses("b"))
+	peers, err = a.Peers()
+	require.Nil(t, err)
+	require.Len(t, peers.Peers, 0)
+	require.NotNil(t, a.Dial(memoryAddrPrefix+network))
+	require.Nil(t, a.Dial(memoryAddrPrefix+network+"/p2p/b"))
+	require.Equal(t, []string{memoryAddrPrefix + network + "/p2p/b"}, a.DialedSeeds())
+	require.False(t, a.refuses("b"))
+	peers, err = a.Peers()
+	require.Nil(t, err)
+	require.Len(t, peers.Peers, 1)
+	require.False(t, peers.Peers[0].Disconnected)
+}
+
+// normalAC reports all the members normal
+type normalAC struct {
+	protocol.AccessControlProvider
+}
+
+func (normalAC) GetMemberStatus(_ *pbac.Member) (pbac.MemberStatus, error) {
+	return pbac.MemberStatus_NORMAL, nil
+}
+
+func TestNetAccessControl(t *testing.T) {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&sk.PublicKey)
+	require.Nil(t, err)
+	member := &pbac.Member{MemberType: pbac.MemberType_PUBLIC_KEY,
+		MemberInfo: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})}
+	pk, err := asym.PublicKeyFromPEM(member.MemberInfo)
+	require.Nil(t, err)
+	nodeId, err := helper.CreateLibp2pPeerIdWithPublicKey(pk)
+	require.Nil(t, err)
+	require.Equal(t, nodeId, nodeIdOfMember(member))
+
+	ns := NewNetService(chainId1, NewMemoryNet(), normalAC{})
+	ac := &netAccessControl{AccessControlProvider: ns.ac, ns: ns}
+	status, err := ac.GetMemberStatus(member)
+	require.Nil(t, err)
+	require.Equal(t, pbac.MemberStatus_NORMAL, status)
+	// the net re-verifying the peers closes the one disconnected
+	require.Nil(t, ns.Disconnect(nodeId))
+	status, err = ac.GetMemberStatus(member)
+	require.Nil(t, err)
+	require.Equal(t, pbac.MemberStatus_FROZEN, status)
+}
+
+func TestPeerTrafficPrune(t *testing.T) {
+	traffic := newPeerTraffic()
+	now := time.Now()
+	traffic.now = func() time.Time { return now }
+	traffic.received("a", netPb.NetMsg_TX, 1)
+	traffic.received("b", netPb.NetMsg_TX, 1)
+	traffic.sent("c", netPb.NetMsg_TX, 1)
+	traffic.sent(broadcastPeer, netPb.NetMsg_TX, 1)
+
+	// the peers gone are kept within the retention, the ones never seen are dropped
+	traffic.prune(map[string]bool{"a": true})
+	require.Len(t, traffic.peers, 3)
+	require.NotContains(t, traffic.peers, "c")
+	now = now.Add(peerTrafficRetention + time.Second)
+	traffic.prune(map[string]bool{"a": true})
+	require.Len(t, traffic.peers, 2)
+	require.Contains(t, traffic.peers, "a")
+	require.Contains(t, traffic.peers, broadcastPeer)
+}
@@ -0,0 +1,308 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package net
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The misbehaviors of the peers reported by the modules, the modules not depending on the net module
+// report them by the same strings.
+const (
+	PeerMisbehaviorInvalidBlock     = "invalid_block"
+	PeerMisbehaviorTimeout          = "timeout"
+	PeerMisbehaviorInvalidSignature = "invalid_signature"
+	PeerMisbehaviorInvalidTx        = "invalid_tx"
+	PeerMisbehaviorInvalidMsg       = "invalid_msg"
+)
+
+// PeerScoreContractName is the pseudo contract of the peer score queries, they are served
+// by the rpc server of the node and never go on chain.
+const PeerScoreContractName = "PEER_SCORE"
+
+// The methods of PeerScoreContractName, PeerScoreMethodUnban requires an admin.
+const (
+	PeerScoreMethodList  = "LIST"
+	PeerScoreMethodUnban = "UNBAN"
+	// PeerScoreParamNodeId is the node id to unban
+	PeerScoreParamNodeId = "node_id"
+)
+
+const (
+	// defaultPeerPenalty is the penalty of the misbehaviors not configured
+	defaultPeerPenalty = 10
+	// maxScoredPeers bounds the peers scored by a chain, the least misbehaving one is dropped beyond it
+	maxScoredPeers = 1024
+)
+
+// PeerScoreConfig is the config of scoring the peers of a chain by their misbehaviors.
+type PeerScoreConfig struct {
+	// Enabled scores the peers, the peers are neither scored nor banned by default.
+	Enabled bool `mapstructure:"enabled"`
+	// BanThreshold bans a peer when its score drops to -BanThreshold, 0 disables the bans.
+	BanThreshold float64 `mapstructure:"ban_threshold"`
+	// BanSeconds is the duration of a ban.
+	BanSeconds int `mapstructure:"ban_seconds"`
+	// HalfLifeSeconds is the time the score of a peer takes to recover by half.
+	HalfLifeSeconds int `mapstructure:"half_life_seconds"`
+	// Penalties is the penalty of each misbehavior, overriding the default ones.
+	Penalties map[string]float64 `mapstructure:"penalties"`
+}
+
+// DefaultPeerScoreConfig returns the default config once enabled, a peer is banned for half an hour
+// by about 2 invalid blocks or signatures, or 20 invalid txs, in 10 minutes.
+func DefaultPeerScoreConfig() *PeerScoreConfig {
+	return &PeerScoreConfig{
+		BanThreshold:    100,
+		BanSeconds:      1800,
+		HalfLifeSeconds: 600,
+		Penalties: map[string]float64{
+			PeerMisbehaviorInvalidBlock:     50,
+			PeerMisbehaviorInvalidSignature: 50,
+			PeerMisbehaviorInvalidMsg:       20,
+			PeerMisbehaviorInvalidTx:        5,
+			PeerMisbehaviorTimeout:          5,
+		},
+	}
+}
+
+// Verify the config and fill the items not configured with the default config.
+func (c *PeerScoreConfig) Verify() error {
+	if c.BanThreshold < 0 || c.BanSeconds < 0 || c.HalfLifeSeconds < 0 {
+		return fmt.Errorf("invalid peer score config, negative ban_threshold, ban_seconds or half_life_seconds")
+	}
+	defaultConfig := DefaultPeerScoreConfig()
+	if c.BanSeconds == 0 {
+		c.BanSeconds = defaultConfig.BanSeconds
+	}
+	if c.HalfLifeSeconds == 0 {
+		c.HalfLifeSeconds = defaultConfig.HalfLifeSeconds
+	}
+	if c.Penalties == nil {
+		c.Penalties = make(map[string]float64)
+	}
+	for misbehavior, penalty := range c.Penalties {
+		if penalty < 0 {
+			return fmt.Errorf("invalid penalty %v of misbehavior %s", penalty, misbehavior)
+		}
+	}
+	for misbehavior, penalty := range defaultConfig.Penalties {
+		if _, ok := c.Penalties[misbehavior]; !ok {
+			c.Penalties[misbehavior] = penalty
+		}
+	}
+	return nil
+}
+
+// PeerScore is the score of a peer of a chain.
+type PeerScore struct {
+	NodeId       string            `json:"node_id"`
+	Score        float64           `json:"score"`
+	Misbehaviors map[string]uint64 `json:"misbehaviors"`
+	Bans         uint64            `json:"bans"`
+	BannedUntil  string            `json:"banned_until,omitempty"`
+	BanReason    string            `json:"ban_reason,omitempty"`
+}
+
+var (
+	peerScoreMetricsOnce sync.Once
+
+	metricPeerMisbehaviors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chainmaker", Subsystem: "net", Name: "peer_misbehaviors_total",
+		Help: "The number of the misbehaviors of the peers reported",
+	}, []string{"chainId", "reason"})
+	metricPeerBans = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chainmaker", Subsystem: "net", Name: "peer_bans_total",
+		Help: "The number of the peers banned for their misbehaviors",
+	}, []string{"chainId", "reason"})
+)
+
+// peerScorer scores the peers of a chain, the score drops by the misbehaviors and recovers to 0 by time.
+// A peer is banned for a while when its score drops to the threshold, except the peers exempted, such as
+// the consensus nodes whose misbehaviors are handled by the consensus. All methods are no-op on nil.
+type peerScorer struct {
+	chainId string
+	config  *PeerScoreConfig
+	metrics bool
+	exempt  func(peer string) bool
+
+	mtx   sync.Mutex
+	peers map[string]*peerScoreState
+	now   func() time.Time
+}
+
+type peerScoreState struct {
+	score        float64
+	updated      time.Time
+	misbehaviors map[string]uint64
+	bans         uint64
+	bannedUntil  time.Time
+	banReason    string
+}
+
+func newPeerScorer(chainId string, config *PeerScoreConfig, metrics bool) *peerScorer {
+	if config == nil || !config.Enabled {
+		return nil
+	}
+	if metrics {
+		peerScoreMetricsOnce.Do(func() {
+			prometheus.MustRegister(metricPeerMisbehaviors, metricPeerBans)
+		})
+	}
+	return &peerScorer{
+		chainId: chainId,
+		config:  config,
+		metrics: metrics,
+		peers:   make(map[string]*peerScoreState),
+		now:     time.Now,
+	}
+}
+
+// report the misbehavior of the peer, returns true if the peer is banned for it.
+func (s *peerScorer) report(peer, misbehavior string) bool {
+	if s == nil || peer == "" {
+		return false
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	now := s.now()
+	state := s.state(peer, now)
+	penalty, ok := s.config.Penalties[misbehavior]
+	if !ok {
+		penalty = defaultPeerPenalty
+	}
+	state.score -= penalty
+	state.misbehaviors[misbehavior]++
+	if s.metrics {
+		metricPeerMisbehaviors.WithLabelValues(s.chainId, misbehavior).Inc()
+	}
+	if s.config.BanThreshold <= 0 || state.score > -s.config.BanThreshold || state.bannedUntil.After(now) ||
+		(s.exempt != nil && s.exempt(peer)) {
+		return false
+	}
+	// the score is reset by the ban, the peer starts over when the ban expires
+	state.score = 0
+	state.bans++
+	state.bannedUntil = now.Add(time.Duration(s.config.BanSeconds) * time.Second)
+	state.banReason = misbehavior
+	if s.metrics {
+		metricPeerBans.WithLabelValues(s.chainId, misbehavior).Inc()
+	}
+	return true
+}
+
+func (s *peerScorer) isBanned(peer string) bool {
+	if s == nil {
+		return false
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	state, ok := s.peers[peer]
+	return ok && state.bannedUntil.After(s.now())
+}
+
+func (s *peerScorer) unban(peer string) error {
+	if s == nil {
+		return fmt.Errorf("peer scoring is disabled")
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	state, ok := s.peers[peer]
+	if !ok || !state.bannedUntil.After(s.now()) {
+		return fmt.Errorf("peer %s is not banned", peer)
+	}
+	state.bannedUntil = time.Time{}
+	return nil
+}
+
+// list the scores of the peers reported, the lowest first.
+func (s *peerScorer) list() []*PeerScore {
+	if s == nil {
+		return nil
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	now := s.now()
+	scores := make([]*PeerScore, 0, len(s.peers))
+	for peer := range s.peers {
+		state := s.state(peer, now)
+		score := &PeerScore{
+			NodeId:       peer,
+			Score:        math.Round(state.score*100) / 100,
+			Misbehaviors: make(map[string]uint64, len(state.misbehaviors)),
+			Bans:         state.bans,
+		}
+		for misbehavior, count := range state.misbehaviors {
+			score.Misbehaviors[misbehavior] = count
+		}
+		if state.bannedUntil.After(now) {
+			score.BannedUntil = state.bannedUntil.Format(time.RFC3339)
+			score.BanReason = state.banReason
+		}
+		scores = append(scores, score)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score < scores[j].Score
+		}
+		return scores[i].NodeId < scores[j].NodeId
+	})
+	return scores
+}
+
+// state returns the state of the peer with the score decayed to now, the caller holds the lock
+func (s *peerScorer) state(peer string, now time.Time) *peerScoreState {
+	state, ok := s.peers[peer]
+	if !ok {
+		if len(s.peers) >= maxScoredPeers {
+			s.evict(now)
+		}
+		state = &peerScoreState{updated: now, misbehaviors: make(map[string]uint64)}
+		s.peers[peer] = state
+		return state
+	}
+	if elapsed := now.Sub(state.updated); elapsed > 0 {
+		halfLife := time.Duration(s.config.HalfLifeSeconds) * time.Second
+		state.score *= math.Pow(0.5, float64(elapsed)/float64(halfLife))
+		state.updated = now
+	}
+	return state
+}
+
+// evict drops a peer to make room for a new one, the peer not banned with the highest score,
+// or the one whose ban expires first if all are banned. The caller holds the lock.
+func (s *peerScorer) evict(now time.Time) {
+	var (
+		victim      string
+		victimState *peerScoreState
+	)
+	for peer := range s.peers {
+		state := s.state(peer, now)
+		if victimState == nil {
+			victim, victimState = peer, state
+			continue
+		}
+		banned, victimBanned := state.bannedUntil.After(now), victimState.bannedUntil.After(now)
+		if banned != victimBanned {
+			if !banned {
+				victim, victimState = peer, state
+			}
+			continue
+		}
+		if (!banned && state.score > victimState.score) ||
+			(banned && state.bannedUntil.Before(victimState.bannedUntil)) {
+			victim, victimState = peer, state
+		}
+	}
+	delete(s.peers, victim)
+}
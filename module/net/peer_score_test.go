@@ -0,0 +1,110 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package net
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerScoreConfigVerify(t *testing.T) {
+	config := &PeerScoreConfig{Penalties: map[string]float64{PeerMisbehaviorInvalidTx: 1}}
+	require.Nil(t, config.Verify())
+	require.Equal(t, 1800, config.BanSeconds)
+	require.Equal(t, 600, config.HalfLifeSeconds)
+	require.Equal(t, float64(1), config.Penalties[PeerMisbehaviorInvalidTx])
+	require.Equal(t, float64(50), config.Penalties[PeerMisbehaviorInvalidBlock])
+
+	require.NotNil(t, (&PeerScoreConfig{BanThreshold: -1}).Verify())
+	require.NotNil(t, (&PeerScoreConfig{Penalties: map[string]float64{PeerMisbehaviorTimeout: -1}}).Verify())
+}
+
+func TestPeerScorer(t *testing.T) {
+	var disabled *peerScorer
+	require.False(t, disabled.report("a", PeerMisbehaviorInvalidBlock))
+	require.False(t, disabled.isBanned("a"))
+	require.NotNil(t, disabled.unban("a"))
+
+	// disabled by default
+	config := DefaultPeerScoreConfig()
+	require.Nil(t, newPeerScorer(chainId1, config, false))
+	config.Enabled = true
+	require.Nil(t, config.Verify())
+	s := newPeerScorer(chainId1, config, false)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	// the score recovers by half in a half life
+	require.False(t, s.report("a", PeerMisbehaviorInvalidBlock))
+	require.False(t, s.report("b", PeerMisbehaviorInvalidTx))
+	now = now.Add(10 * time.Minute)
+	scores := s.list()
+	require.Len(t, scores, 2)
+	require.Equal(t, "a", scores[0].NodeId)
+	require.Equal(t, float64(-25), scores[0].Score)
+	require.Equal(t, float64(-2.5), scores[1].Score)
+
+	// banned when the score drops to the threshold
+	require.False(t, s.report("a", PeerMisbehaviorInvalidSignature))
+	require.True(t, s.report("a", PeerMisbehaviorInvalidSignature))
+	require.True(t, s.isBanned("a"))
+	require.False(t, s.isBanned("b"))
+	require.False(t, s.report("a", "unknown"))
+	scores = s.list()
+	require.Equal(t, "a", scores[0].NodeId)
+	require.Equal(t, uint64(1), scores[0].Bans)
+	require.Equal(t, PeerMisbehaviorInvalidSignature, scores[0].BanReason)
+	require.Equal(t, uint64(1), scores[0].Misbehaviors["unknown"])
+
+	// the ban expires, or is lifted
+	now = now.Add(30 * time.Minute)
+	require.False(t, s.isBanned("a"))
+	require.NotNil(t, s.unban("a"))
+	require.False(t, s.report("a", PeerMisbehaviorInvalidBlock))
+	require.True(t, s.report("a", PeerMisbehaviorInvalidBlock))
+	require.True(t, s.isBanned("a"))
+	require.Nil(t, s.unban("a"))
+	require.False(t, s.isBanned("a"))
+}
+
+func TestPeerScorerExempt(t *testing.T) {
+	config := &PeerScoreConfig{Enabled: true, BanThreshold: 10}
+	require.Nil(t, config.Verify())
+	s := newPeerScorer(chainId1, config, false)
+	s.exempt = func(peer string) bool { return peer == "consensus" }
+
+	// the exempted peers are scored but never banned
+	require.False(t, s.report("consensus", PeerMisbehaviorInvalidBlock))
+	require.False(t, s.isBanned("consensus"))
+	require.True(t, s.report("other", PeerMisbehaviorInvalidBlock))
+	scores := s.list()
+	require.Len(t, scores, 2)
+	require.Equal(t, float64(-50), scores[0].Score)
+	require.Equal(t, "consensus", scores[0].NodeId)
+}
+
+func TestPeerScorerBounded(t *testing.T) {
+	config := &PeerScoreConfig{Enabled: true, BanThreshold: 100}
+	require.Nil(t, config.Verify())
+	s := newPeerScorer(chainId1, config, false)
+
+	// a banned peer and many peers misbehaving a little
+	require.False(t, s.report("banned", PeerMisbehaviorInvalidBlock))
+	require.True(t, s.report("banned", PeerMisbehaviorInvalidBlock))
+	require.False(t, s.report("worst", PeerMisbehaviorInvalidMsg))
+	for i := 0; i < 2*maxScoredPeers; i++ {
+		s.report(fmt.Sprintf("peer%d", i), PeerMisbehaviorInvalidTx)
+	}
+	require.Len(t, s.list(), maxScoredPeers)
+	// the least misbehaving peers are dropped first
+	require.True(t, s.isBanned("banned"))
+	scores := s.list()
+	require.Equal(t, "worst", scores[0].NodeId)
+}
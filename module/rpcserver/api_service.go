@@ -13,7 +13,9 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"chainmaker.org/chainmaker-go/accesscontrol"
 	"chainmaker.org/chainmaker-go/blockchain"
+	"chainmaker.org/chainmaker-go/core/common/scheduler"
 	commonErr "chainmaker.org/chainmaker/common/v2/errors"
 	"chainmaker.org/chainmaker/common/v2/monitor"
 	"chainmaker.org/chainmaker/localconf/v2"
@@ -26,7 +28,6 @@ import (
 	"chainmaker.org/chainmaker/utils/v2"
 	native "chainmaker.org/chainmaker/vm-native/v2"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/time/rate"
 )
 
 const (
@@ -36,44 +37,51 @@ const (
 
 var _ apiPb.RpcNodeServer = (*ApiService)(nil)
 
+// adminHandler serves the queries of a contract by the node rather than the vm, e.g. the admin queries of a module
+type adminHandler func(s *ApiService, tx *commonPb.Transaction) *commonPb.TxResponse
+
+// adminHandlers are the admin handlers by the contract names, registered at init
+var adminHandlers = make(map[string]adminHandler)
+
+// registerAdminHandler registers the handler of the queries of the contract, the name is reserved from the user
+// contracts, which could not be deployed by the name
+func registerAdminHandler(contractName string, handler adminHandler) {
+	adminHandlers[contractName] = handler
+	scheduler.ReserveContractName(contractName)
+}
+
 // ApiService struct define
 type ApiService struct {
-	chainMakerServer      *blockchain.ChainMakerServer
-	log                   *logger.CMLogger
-	logBrief              *logger.CMLogger
-	subscriberRateLimiter *rate.Limiter
-	metricQueryCounter    *prometheus.CounterVec
-	metricInvokeCounter   *prometheus.CounterVec
-	ctx                   context.Context
+	chainMakerServer    *blockchain.ChainMakerServer
+	log                 *logger.CMLogger
+	logBrief            *logger.CMLogger
+	access              *accessLimiter
+	metricQueryCounter  *prometheus.CounterVec
+	metricInvokeCounter *prometheus.CounterVec
+	ctx                 context.Context
+	chainResources      *chainResourceLimiter
+	adminReplay         *adminReplayGuard
 }
 
 // NewApiService - new ApiService object
 func NewApiService(ctx context.Context, chainMakerServer *blockchain.ChainMakerServer) *ApiService {
+	return newApiService(ctx, chainMakerServer, newAccessLimiter(localconf.ChainMakerConfig))
+}
+
+// newApiService - new ApiService object limiting the subscriptions by the access limiter of the rpc server
+func newApiService(ctx context.Context, chainMakerServer *blockchain.ChainMakerServer,
+	access *accessLimiter) *ApiService {
 	log := logger.GetLogger(logger.MODULE_RPC)
 	logBrief := logger.GetLogger(logger.MODULE_BRIEF)
 
-	tokenBucketSize := localconf.ChainMakerConfig.RpcConfig.SubscriberConfig.RateLimitConfig.TokenBucketSize
-	tokenPerSecond := localconf.ChainMakerConfig.RpcConfig.SubscriberConfig.RateLimitConfig.TokenPerSecond
-
-	var subscriberRateLimiter *rate.Limiter
-	if tokenBucketSize >= 0 && tokenPerSecond >= 0 {
-		if tokenBucketSize == 0 {
-			tokenBucketSize = subscriberRateLimitDefaultTokenBucketSize
-		}
-
-		if tokenPerSecond == 0 {
-			tokenPerSecond = subscriberRateLimitDefaultTokenPerSecond
-		}
-
-		subscriberRateLimiter = rate.NewLimiter(rate.Limit(tokenPerSecond), tokenBucketSize)
-	}
-
 	apiService := ApiService{
-		chainMakerServer:      chainMakerServer,
-		log:                   log,
-		logBrief:              logBrief,
-		subscriberRateLimiter: subscriberRateLimiter,
-		ctx:                   ctx,
+		chainMakerServer: chainMakerServer,
+		log:              log,
+		logBrief:         logBrief,
+		access:           access,
+		ctx:              ctx,
+		chainResources:   newChainResourceLimiter(chainMakerServer),
+		adminReplay:      newAdminReplayGuard(),
 	}
 
 	if localconf.ChainMakerConfig.MonitorConfig.Enabled {
@@ -138,9 +146,35 @@ func (s *ApiService) validate(tx *commonPb.Transaction) (errCode commonErr.ErrCo
 		return
 	}
 
+	// the status of the certificates is checked online on admission only, the blocks are verified without it
+	if err = checkOnlineStatus(tx, bc.GetAccessControl()); err != nil {
+		errCode = commonErr.ERR_CODE_TX_VERIFY_FAILED
+		errMsg = fmt.Sprintf("%s, %s, txId:%s, sender:%s", errCode.String(), err.Error(), tx.Payload.TxId,
+			hex.EncodeToString(tx.Sender.Signer.MemberInfo))
+		s.log.Error(errMsg)
+		return
+	}
+
 	return commonErr.ERR_CODE_OK, ""
 }
 
+// checkOnlineStatus checks the sender and the endorsers of the tx if the access control checks the status online
+func checkOnlineStatus(tx *commonPb.Transaction, ac protocol.AccessControlProvider) error {
+	checker, ok := ac.(accesscontrol.OnlineStatusChecker)
+	if !ok {
+		return nil
+	}
+	if err := checker.CheckOnlineStatus(tx.Sender.Signer); err != nil {
+		return fmt.Errorf("check status of the sender failed, %s", err.Error())
+	}
+	for _, endorser := range tx.Endorsers {
+		if err := checker.CheckOnlineStatus(endorser.Signer); err != nil {
+			return fmt.Errorf("check status of the endorser failed, %s", err.Error())
+		}
+	}
+	return nil
+}
+
 func (s *ApiService) getErrMsg(errCode commonErr.ErrCode, err error) string {
 	return fmt.Sprintf("%s, %s", errCode.String(), err.Error())
 }
@@ -154,6 +188,13 @@ func (s *ApiService) invoke(tx *commonPb.Transaction, source protocol.TxSource)
 	)
 
 	if tx.Payload.ChainId != SYSTEM_CHAIN {
+		if err := s.chainResources.allowRequest(tx.Payload.ChainId); err != nil {
+			s.log.Warn(err)
+			resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+			resp.Message = err.Error()
+			resp.TxId = tx.Payload.TxId
+			return resp
+		}
 		errCode, errMsg = s.validate(tx)
 		if errCode != commonErr.ERR_CODE_OK {
 			resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
@@ -165,6 +206,9 @@ func (s *ApiService) invoke(tx *commonPb.Transaction, source protocol.TxSource)
 
 	switch tx.Payload.TxType {
 	case commonPb.TxType_QUERY_CONTRACT:
+		if handler, ok := adminHandlers[tx.Payload.ContractName]; ok {
+			return handler(s, tx)
+		}
 		return s.dealQuery(tx, source)
 	case commonPb.TxType_INVOKE_CONTRACT:
 		return s.dealTransact(tx, source)
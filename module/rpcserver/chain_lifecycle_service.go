@@ -0,0 +1,165 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpcserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"chainmaker.org/chainmaker-go/blockchain"
+	"chainmaker.org/chainmaker/localconf/v2"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/protocol/v2"
+	"chainmaker.org/chainmaker/utils/v2"
+)
+
+// adminTxExpireDuration is how long an admin query is accepted after it is signed, the tx ids of the queries
+// accepted in the period are kept to reject them replayed
+const adminTxExpireDuration = 10 * time.Minute
+
+// adminReplayGuard rejects the admin queries expired or replayed, the queries are served by the node and not
+// kept on chain, so their tx ids are not checked by the tx pool
+type adminReplayGuard struct {
+	mtx  sync.Mutex
+	seen map[string]int64 // tx id -> the timestamp of the query
+}
+
+func newAdminReplayGuard() *adminReplayGuard {
+	return &adminReplayGuard{seen: make(map[string]int64)}
+}
+
+// check records the tx id of the query, the queries signed out of adminTxExpireDuration are rejected
+func (g *adminReplayGuard) check(tx *commonPb.Transaction) error {
+	now := time.Now().Unix()
+	expire := int64(adminTxExpireDuration / time.Second)
+	timestamp := tx.Payload.Timestamp
+	if timestamp > now+expire || timestamp < now-expire {
+		return fmt.Errorf("the timestamp %d of %s is out of %s", timestamp, tx.Payload.TxId, adminTxExpireDuration)
+	}
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	for txId, ts := range g.seen {
+		if ts < now-expire {
+			delete(g.seen, txId)
+		}
+	}
+	if _, ok := g.seen[tx.Payload.TxId]; ok {
+		return fmt.Errorf("%s has been served, it could not be replayed", tx.Payload.TxId)
+	}
+	g.seen[tx.Payload.TxId] = timestamp
+	return nil
+}
+
+func init() {
+	registerAdminHandler(blockchain.LifecycleContractName, (*ApiService).doChainLifecycle)
+}
+
+// doChainLifecycle - deal the admin queries to join, pause, resume and leave the chains on the node.
+// The queries are sent to the system chain, the chain managed is given by the chain_id param.
+func (s *ApiService) doChainLifecycle(tx *commonPb.Transaction) *commonPb.TxResponse {
+	var (
+		err    error
+		result []byte
+		resp   = &commonPb.TxResponse{TxId: tx.Payload.TxId}
+		params = s.kvPair2Map(tx.Payload.Parameters)
+	)
+
+	chainId := string(params[blockchain.LifecycleParamChainId])
+	if err = s.checkLifecycleAdmin(tx, chainId); err == nil {
+		switch tx.Payload.Method {
+		case blockchain.LifecycleMethodStatus:
+			result, err = json.Marshal(s.chainMakerServer.BlockchainStatuses())
+		case blockchain.LifecycleMethodJoin:
+			genesis := string(params[blockchain.LifecycleParamGenesis])
+			if genesis == "" {
+				err = fmt.Errorf("%s is required", blockchain.LifecycleParamGenesis)
+				break
+			}
+			err = s.chainMakerServer.JoinBlockchain(chainId, genesis)
+		case blockchain.LifecycleMethodPause:
+			err = s.chainMakerServer.PauseBlockchain(chainId)
+		case blockchain.LifecycleMethodResume:
+			err = s.chainMakerServer.ResumeBlockchain(chainId)
+		case blockchain.LifecycleMethodLeave:
+			var deleteData bool
+			if deleteData, err = blockchain.ParseLifecycleDeleteData(params); err == nil {
+				err = s.chainMakerServer.LeaveBlockchain(chainId, deleteData)
+			}
+		default:
+			err = fmt.Errorf("unknown method %s of %s", tx.Payload.Method, blockchain.LifecycleContractName)
+		}
+	}
+
+	if err != nil {
+		errMsg := fmt.Sprintf("chain lifecycle %s failed, %s", tx.Payload.Method, err.Error())
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+
+	resp.Code = commonPb.TxStatusCode_SUCCESS
+	resp.Message = commonPb.TxStatusCode_SUCCESS.String()
+	resp.ContractResult = &commonPb.ContractResult{
+		Code:   0,
+		Result: result,
+	}
+	return resp
+}
+
+// checkLifecycleAdmin checks the sender of the tx is an admin of the org of the node, and the tx is not replayed.
+// The sender is verified by the access control of the chain managed if the chain is on the node,
+// otherwise (i.e. joining a chain or getting the status) by that of any chain on the node.
+func (s *ApiService) checkLifecycleAdmin(tx *commonPb.Transaction, chainId string) error {
+	if tx.Sender == nil || tx.Sender.Signer == nil {
+		return fmt.Errorf("sender is required")
+	}
+	if tx.Payload.ChainId != SYSTEM_CHAIN {
+		return fmt.Errorf("%s should be sent to %s", blockchain.LifecycleContractName, SYSTEM_CHAIN)
+	}
+
+	var acs []protocol.AccessControlProvider
+	if bc, err := s.chainMakerServer.GetBlockchain(chainId); err == nil &&
+		tx.Payload.Method != blockchain.LifecycleMethodJoin && tx.Payload.Method != blockchain.LifecycleMethodStatus {
+		acs = []protocol.AccessControlProvider{bc.GetAccessControl()}
+	} else if acs, err = s.chainMakerServer.GetAllAC(); err != nil {
+		return err
+	}
+
+	var err error
+	for _, ac := range acs {
+		if err = s.verifyLocalAdmin(tx, ac); err == nil {
+			return s.adminReplay.check(tx)
+		}
+	}
+	return err
+}
+
+// verifyLocalAdmin verifies the tx is signed by an admin of the org of the node, by the access control given
+func (s *ApiService) verifyLocalAdmin(tx *commonPb.Transaction, ac protocol.AccessControlProvider) error {
+	if tx.Sender == nil || tx.Sender.Signer == nil {
+		return fmt.Errorf("sender is required")
+	}
+	if err := utils.VerifyTxWithoutPayload(tx, tx.Payload.ChainId, ac); err != nil {
+		return err
+	}
+	member, err := ac.NewMember(tx.Sender.Signer)
+	if err != nil {
+		return err
+	}
+	if member.GetRole() != protocol.RoleAdmin {
+		return fmt.Errorf("%s requires the admin role, sender role: %s", tx.Payload.Method, member.GetRole())
+	}
+	if localOrgId := localconf.ChainMakerConfig.NodeConfig.OrgId; member.GetOrgId() != localOrgId {
+		return fmt.Errorf("%s requires an admin of the org %s of the node, sender org: %s",
+			tx.Payload.Method, localOrgId, member.GetOrgId())
+	}
+	return nil
+}
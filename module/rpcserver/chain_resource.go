@@ -0,0 +1,124 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpcserver
+
+import (
+	"fmt"
+	"sync"
+
+	"chainmaker.org/chainmaker-go/blockchain"
+	"chainmaker.org/chainmaker/localconf/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+var (
+	chainResourceMetricsOnce sync.Once
+
+	metricChainSubscriptions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "chainmaker", Subsystem: "chain_resource", Name: "subscriptions",
+		Help: "The number of the subscription streams of the chain",
+	}, []string{"chainId"})
+	metricChainThrottled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chainmaker", Subsystem: "chain_resource", Name: "rpc_throttled_total",
+		Help: "The number of the requests rejected for exceeding the rpc budget of the chain",
+	}, []string{"chainId", "resource"})
+)
+
+// chainResourceLimiter enforces the rpc budgets of the chains in the chain_resources section
+type chainResourceLimiter struct {
+	chainMakerServer *blockchain.ChainMakerServer
+	metrics          bool
+
+	limiters      sync.Map // chainId -> *chainQPSLimiter
+	mtx           sync.Mutex
+	subscriptions map[string]int // chainId -> the subscription streams
+}
+
+type chainQPSLimiter struct {
+	qps     int
+	limiter *rate.Limiter
+}
+
+func newChainResourceLimiter(chainMakerServer *blockchain.ChainMakerServer) *chainResourceLimiter {
+	l := &chainResourceLimiter{
+		chainMakerServer: chainMakerServer,
+		subscriptions:    make(map[string]int),
+	}
+	if localconf.ChainMakerConfig.MonitorConfig.Enabled {
+		chainResourceMetricsOnce.Do(func() {
+			prometheus.MustRegister(metricChainSubscriptions, metricChainThrottled)
+		})
+		l.metrics = true
+	}
+	return l
+}
+
+func (l *chainResourceLimiter) budget(chainId string) *blockchain.ChainResourceBudget {
+	budget, err := l.chainMakerServer.GetResourceBudget(chainId)
+	if err != nil || budget == nil {
+		return &blockchain.ChainResourceBudget{}
+	}
+	return budget
+}
+
+// allowRequest takes a token of the rpc qps budget of the chain
+func (l *chainResourceLimiter) allowRequest(chainId string) error {
+	qps := l.budget(chainId).RpcQPS
+	if qps <= 0 {
+		return nil
+	}
+	var limiter *chainQPSLimiter
+	if v, ok := l.limiters.Load(chainId); ok && v.(*chainQPSLimiter).qps == qps {
+		limiter = v.(*chainQPSLimiter)
+	} else {
+		// the budget changes when the chain rejoined or resumed
+		limiter = &chainQPSLimiter{qps: qps, limiter: rate.NewLimiter(rate.Limit(qps), qps)}
+		l.limiters.Store(chainId, limiter)
+	}
+	if !limiter.limiter.Allow() {
+		l.incThrottled(chainId, "rpc_qps")
+		return fmt.Errorf("the requests of chain %s exceed the rpc qps budget %d", chainId, qps)
+	}
+	return nil
+}
+
+// acquireSubscription counts a subscription stream of the chain, release it when the stream ends
+func (l *chainResourceLimiter) acquireSubscription(chainId string) (release func(), err error) {
+	maxSubscriptions := l.budget(chainId).MaxSubscriptions
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if maxSubscriptions > 0 && l.subscriptions[chainId] >= maxSubscriptions {
+		l.incThrottled(chainId, "max_subscriptions")
+		return nil, fmt.Errorf("the subscriptions of chain %s exceed the budget %d", chainId, maxSubscriptions)
+	}
+	l.subscriptions[chainId]++
+	l.setSubscriptions(chainId)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mtx.Lock()
+			defer l.mtx.Unlock()
+			l.subscriptions[chainId]--
+			l.setSubscriptions(chainId)
+		})
+	}, nil
+}
+
+func (l *chainResourceLimiter) setSubscriptions(chainId string) {
+	if l.metrics {
+		metricChainSubscriptions.WithLabelValues(chainId).Set(float64(l.subscriptions[chainId]))
+	}
+}
+
+func (l *chainResourceLimiter) incThrottled(chainId, resource string) {
+	if l.metrics {
+		metricChainThrottled.WithLabelValues(chainId, resource).Inc()
+	}
+}
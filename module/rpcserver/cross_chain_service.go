@@ -0,0 +1,111 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpcserver
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"chainmaker.org/chainmaker-go/core/crosschain"
+	commonErr "chainmaker.org/chainmaker/common/v2/errors"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+func init() {
+	registerAdminHandler(crosschain.ContractName, (*ApiService).doCrossChain)
+}
+
+// doCrossChain - deal the queries of the cross-chain messages, the receipts are queried on the source chains
+// and the deliveries on the destination chains
+func (s *ApiService) doCrossChain(tx *commonPb.Transaction) *commonPb.TxResponse {
+	var (
+		err    error
+		store  protocol.BlockchainStore
+		result []byte
+		resp   = &commonPb.TxResponse{TxId: tx.Payload.TxId}
+	)
+
+	if store, err = s.chainMakerServer.GetStore(tx.Payload.ChainId); err != nil {
+		errMsg := s.getErrMsg(commonErr.ERR_CODE_GET_STORE, err)
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+
+	params := s.kvPair2Map(tx.Payload.Parameters)
+	messageId := string(params[crosschain.ParamMessageId])
+	switch {
+	case messageId == "":
+		err = fmt.Errorf("param %s is required", crosschain.ParamMessageId)
+	case tx.Payload.Method == crosschain.MethodGetReceipt:
+		result, err = getCrossChainReceipt(store, messageId)
+	case tx.Payload.Method == crosschain.MethodGetDelivery:
+		result, err = getCrossChainDelivery(store, messageId)
+	default:
+		err = fmt.Errorf("unknown query method %s of %s", tx.Payload.Method, crosschain.ContractName)
+	}
+
+	if err != nil {
+		errMsg := fmt.Sprintf("cross chain %s failed, %s", tx.Payload.Method, err.Error())
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+
+	resp.Code = commonPb.TxStatusCode_SUCCESS
+	resp.Message = commonPb.TxStatusCode_SUCCESS.String()
+	resp.ContractResult = &commonPb.ContractResult{
+		Code:   0,
+		Result: result,
+	}
+	return resp
+}
+
+// getCrossChainReceipt returns the receipt of the message recorded by the ACK tx on the source chain
+func getCrossChainReceipt(store protocol.BlockchainStore, messageId string) ([]byte, error) {
+	receipt, err := store.ReadObject(crosschain.ContractName, crosschain.ReceiptKey(messageId))
+	if err != nil {
+		return nil, err
+	}
+	if len(receipt) > 0 {
+		return receipt, nil
+	}
+	// the receipt is discarded if the ACK tx failed, such as the callback failed
+	ackTx, err := store.GetTx(crosschain.AckTxId(messageId))
+	if err != nil {
+		return nil, err
+	}
+	if ackTx != nil && ackTx.Result != nil {
+		return nil, fmt.Errorf("the ack tx %s of message %s failed, %s", ackTx.Payload.TxId, messageId,
+			ackTx.Result.ContractResult.GetMessage())
+	}
+	return nil, fmt.Errorf("message %s is not acknowledged yet", messageId)
+}
+
+// getCrossChainDelivery returns the receipt of the message by the DELIVER tx on the destination chain
+func getCrossChainDelivery(store protocol.BlockchainStore, messageId string) ([]byte, error) {
+	deliverTxId := crosschain.DeliverTxId(messageId)
+	deliverTx, err := store.GetTx(deliverTxId)
+	if err != nil {
+		return nil, err
+	}
+	if deliverTx == nil {
+		return nil, fmt.Errorf("message %s is not delivered yet", messageId)
+	}
+	height, err := store.GetTxHeight(deliverTxId)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := crosschain.ReceiptOf(messageId, height, deliverTx)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(receipt)
+}
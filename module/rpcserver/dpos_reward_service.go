@@ -0,0 +1,71 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpcserver
+
+import (
+	"fmt"
+	"math/big"
+
+	"chainmaker.org/chainmaker-go/consensus/dpos"
+	commonErr "chainmaker.org/chainmaker/common/v2/errors"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	"chainmaker.org/chainmaker/protocol/v2"
+)
+
+func init() {
+	registerAdminHandler(dpos.RewardContractName, (*ApiService).doDPoSReward)
+}
+
+// doDPoSReward - deal the reward queries of DPoS, the withdrawals are txs executed by the node and the consensus
+func (s *ApiService) doDPoSReward(tx *commonPb.Transaction) *commonPb.TxResponse {
+	var (
+		err    error
+		store  protocol.BlockchainStore
+		result []byte
+		resp   = &commonPb.TxResponse{TxId: tx.Payload.TxId}
+	)
+
+	if store, err = s.chainMakerServer.GetStore(tx.Payload.ChainId); err != nil {
+		errMsg := s.getErrMsg(commonErr.ERR_CODE_GET_STORE, err)
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+
+	params := s.kvPair2Map(tx.Payload.Parameters)
+	switch tx.Payload.Method {
+	case dpos.RewardMethodGetReward:
+		address := string(params[dpos.RewardParamAddress])
+		if address == "" {
+			err = fmt.Errorf("param %s is required", dpos.RewardParamAddress)
+			break
+		}
+		var reward *big.Int
+		if reward, err = dpos.GetAccruedReward(store, address); err == nil {
+			result = []byte(reward.String())
+		}
+	default:
+		err = fmt.Errorf("unknown query method %s of %s", tx.Payload.Method, dpos.RewardContractName)
+	}
+
+	if err != nil {
+		errMsg := fmt.Sprintf("dpos reward %s failed, %s", tx.Payload.Method, err.Error())
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+
+	resp.Code = commonPb.TxStatusCode_SUCCESS
+	resp.Message = commonPb.TxStatusCode_SUCCESS.String()
+	resp.ContractResult = &commonPb.ContractResult{
+		Code:   0,
+		Result: result,
+	}
+	return resp
+}
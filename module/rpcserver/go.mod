@@ -3,8 +3,13 @@ module chainmaker.org/chainmaker-go/rpcserver
 go 1.15
 
 require (
+	chainmaker.org/chainmaker-go/accesscontrol v0.0.0
 	chainmaker.org/chainmaker-go/blockchain v0.0.0
+	chainmaker.org/chainmaker-go/consensus v0.0.0
+	chainmaker.org/chainmaker-go/core v0.0.0
+	chainmaker.org/chainmaker-go/net v0.0.0
 	chainmaker.org/chainmaker-go/subscriber v0.0.0
+	chainmaker.org/chainmaker-go/sync v0.0.0
 	chainmaker.org/chainmaker/common/v2 v2.1.0
 	chainmaker.org/chainmaker/localconf/v2 v2.1.0
 	chainmaker.org/chainmaker/logger/v2 v2.1.0
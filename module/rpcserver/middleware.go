@@ -15,6 +15,7 @@ import (
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"chainmaker.org/chainmaker/localconf/v2"
@@ -108,25 +109,95 @@ func MonitorInterceptor(ctx context.Context, req interface{},
 	return resp, err
 }
 
-func getRateLimitBucket(bucketMap *sync.Map, tokenBucketSize, tokenPerSecond int, peerIpAddr string) *rate.Limiter {
-	rateLimitType := localconf.ChainMakerConfig.RpcConfig.RateLimitConfig.Type
+// accessLimits are the rate limits and the blacklist of the rpc service read from the local config
+type accessLimits struct {
+	rateLimitEnabled  bool
+	rateLimitType     int
+	tokenBucketSize   int
+	tokenPerSecond    int
+	buckets           *sync.Map
+	blackIps          []string
+	subscriberLimiter *rate.Limiter
+}
+
+func newAccessLimits(config *localconf.CMConfig) *accessLimits {
+	return &accessLimits{
+		rateLimitEnabled:  config.RpcConfig.RateLimitConfig.Enabled,
+		rateLimitType:     int(config.RpcConfig.RateLimitConfig.Type),
+		tokenBucketSize:   config.RpcConfig.RateLimitConfig.TokenBucketSize,
+		tokenPerSecond:    config.RpcConfig.RateLimitConfig.TokenPerSecond,
+		buckets:           &sync.Map{},
+		blackIps:          config.RpcConfig.BlackList.Addresses,
+		subscriberLimiter: newSubscriberRateLimiter(config),
+	}
+}
+
+func newSubscriberRateLimiter(config *localconf.CMConfig) *rate.Limiter {
+	tokenBucketSize := config.RpcConfig.SubscriberConfig.RateLimitConfig.TokenBucketSize
+	tokenPerSecond := config.RpcConfig.SubscriberConfig.RateLimitConfig.TokenPerSecond
+	if tokenBucketSize < 0 || tokenPerSecond < 0 {
+		return nil
+	}
+	if tokenBucketSize == 0 {
+		tokenBucketSize = subscriberRateLimitDefaultTokenBucketSize
+	}
+	if tokenPerSecond == 0 {
+		tokenPerSecond = subscriberRateLimitDefaultTokenPerSecond
+	}
+	return rate.NewLimiter(rate.Limit(tokenPerSecond), tokenBucketSize)
+}
+
+// accessLimiter holds the access limits applied by the interceptors and the subscriptions,
+// they are replaced as a whole when the local config is reloaded, without restarting the server
+type accessLimiter struct {
+	limits atomic.Value // *accessLimits
+}
+
+func newAccessLimiter(config *localconf.CMConfig) *accessLimiter {
+	limiter := &accessLimiter{}
+	limiter.reset(config)
+	return limiter
+}
+
+// load returns the access limits in use
+func (l *accessLimiter) load() *accessLimits {
+	return l.limits.Load().(*accessLimits)
+}
+
+// reset applies the access limits of the config, the rate limit buckets start full again
+func (l *accessLimiter) reset(config *localconf.CMConfig) {
+	l.limits.Store(newAccessLimits(config))
+}
+
+// isBlack returns true if the ip is in the blacklist
+func (limits *accessLimits) isBlack(ipAddr string) bool {
+	for _, blackIp := range limits.blackIps {
+		if ipAddr == blackIp {
+			return true
+		}
+	}
+	return false
+}
+
+func getRateLimitBucket(limits *accessLimits, peerIpAddr string) *rate.Limiter {
 	var (
 		bucket interface{}
 		ok     bool
 	)
 
-	if rateLimitType == rateLimitTypeGlobal {
-		if bucket, ok = bucketMap.Load(rateLimitTypeGlobal); ok {
+	if limits.rateLimitType == rateLimitTypeGlobal {
+		if bucket, ok = limits.buckets.Load(rateLimitTypeGlobal); ok {
 			log.Debug("get rateLimit bucket from global")
 			return bucket.(*rate.Limiter)
 		}
 	} else {
-		if bucket, ok = bucketMap.Load(peerIpAddr); ok {
+		if bucket, ok = limits.buckets.Load(peerIpAddr); ok {
 			log.Debugf("get rateLimit bucket from peerIpAddr [%s]", peerIpAddr)
 			return bucket.(*rate.Limiter)
 		}
 	}
 
+	tokenBucketSize, tokenPerSecond := limits.tokenBucketSize, limits.tokenPerSecond
 	if tokenBucketSize >= 0 && tokenPerSecond >= 0 {
 		if tokenBucketSize == 0 {
 			tokenBucketSize = rateLimitDefaultTokenBucketSize
@@ -141,12 +212,12 @@ func getRateLimitBucket(bucketMap *sync.Map, tokenBucketSize, tokenPerSecond int
 		return nil
 	}
 
-	if rateLimitType == rateLimitTypeGlobal {
-		if bucket, ok = bucketMap.LoadOrStore(rateLimitTypeGlobal, bucket); !ok {
+	if limits.rateLimitType == rateLimitTypeGlobal {
+		if bucket, ok = limits.buckets.LoadOrStore(rateLimitTypeGlobal, bucket); !ok {
 			log.Debug("create rateLimit bucket from global")
 		}
 	} else {
-		if bucket, ok = bucketMap.LoadOrStore(peerIpAddr, bucket); !ok {
+		if bucket, ok = limits.buckets.LoadOrStore(peerIpAddr, bucket); !ok {
 			log.Debugf("create rateLimit bucket from peerIpAddr [%s]", peerIpAddr)
 		}
 	}
@@ -155,20 +226,13 @@ func getRateLimitBucket(bucketMap *sync.Map, tokenBucketSize, tokenPerSecond int
 }
 
 // RateLimitInterceptor - set ratelimit interceptor
-func RateLimitInterceptor() grpc.UnaryServerInterceptor {
-
-	tokenBucketSize := localconf.ChainMakerConfig.RpcConfig.RateLimitConfig.TokenBucketSize
-	tokenPerSecond := localconf.ChainMakerConfig.RpcConfig.RateLimitConfig.TokenPerSecond
-	enabled := localconf.ChainMakerConfig.RpcConfig.RateLimitConfig.Enabled
-
-	bucketMap := sync.Map{}
-
+func RateLimitInterceptor(limiter *accessLimiter) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (
 		interface{}, error) {
 
-		if enabled {
+		if limits := limiter.load(); limits.rateLimitEnabled {
 			ipAddr := getClientIp(ctx)
-			bucket := getRateLimitBucket(&bucketMap, tokenBucketSize, tokenPerSecond, ipAddr)
+			bucket := getRateLimitBucket(limits, ipAddr)
 			if bucket != nil && !bucket.Allow() {
 				errMsg := fmt.Sprintf("%s is rejected by ratelimit, try later pls", info.FullMethod)
 				log.Warn(errMsg)
@@ -181,20 +245,15 @@ func RateLimitInterceptor() grpc.UnaryServerInterceptor {
 }
 
 // BlackListInterceptor - set ip blacklist interceptor
-func BlackListInterceptor() grpc.UnaryServerInterceptor {
-
-	blackIps := localconf.ChainMakerConfig.RpcConfig.BlackList.Addresses
-
+func BlackListInterceptor(limiter *accessLimiter) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (
 		interface{}, error) {
 
 		ipAddr := getClientIp(ctx)
-		for _, blackIp := range blackIps {
-			if ipAddr == blackIp {
-				errMsg := fmt.Sprintf("%s is rejected by black list [%s]", info.FullMethod, ipAddr)
-				log.Warn(errMsg)
-				return nil, status.Error(codes.ResourceExhausted, errMsg)
-			}
+		if limiter.load().isBlack(ipAddr) {
+			errMsg := fmt.Sprintf("%s is rejected by black list [%s]", info.FullMethod, ipAddr)
+			log.Warn(errMsg)
+			return nil, status.Error(codes.ResourceExhausted, errMsg)
 		}
 
 		return handler(ctx, req)
@@ -202,19 +261,14 @@ func BlackListInterceptor() grpc.UnaryServerInterceptor {
 }
 
 // BlackListStreamInterceptor - set ip blacklist interceptor
-func BlackListStreamInterceptor() grpc.StreamServerInterceptor {
-
-	blackIps := localconf.ChainMakerConfig.RpcConfig.BlackList.Addresses
-
+func BlackListStreamInterceptor(limiter *accessLimiter) grpc.StreamServerInterceptor {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 
 		ipAddr := getClientIp(ss.Context())
-		for _, blackIp := range blackIps {
-			if ipAddr == blackIp {
-				errMsg := fmt.Sprintf("%s is rejected by black list [%s]", info.FullMethod, ipAddr)
-				log.Warn(errMsg)
-				return status.Error(codes.ResourceExhausted, errMsg)
-			}
+		if limiter.load().isBlack(ipAddr) {
+			errMsg := fmt.Sprintf("%s is rejected by black list [%s]", info.FullMethod, ipAddr)
+			log.Warn(errMsg)
+			return status.Error(codes.ResourceExhausted, errMsg)
 		}
 
 		return handler(srv, ss)
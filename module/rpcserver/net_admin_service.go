@@ -0,0 +1,95 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpcserver
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"chainmaker.org/chainmaker-go/blockchain"
+	"chainmaker.org/chainmaker-go/net"
+	commonErr "chainmaker.org/chainmaker/common/v2/errors"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+)
+
+func init() {
+	registerAdminHandler(net.NetAdminContractName, (*ApiService).doNetAdmin)
+}
+
+// doNetAdmin - deal the queries of the p2p layer of the node, i.e. peers, seeds, dial and disconnect
+func (s *ApiService) doNetAdmin(tx *commonPb.Transaction) *commonPb.TxResponse {
+	var (
+		err    error
+		bc     *blockchain.Blockchain
+		result []byte
+		resp   = &commonPb.TxResponse{TxId: tx.Payload.TxId}
+	)
+
+	if bc, err = s.chainMakerServer.GetBlockchain(tx.Payload.ChainId); err != nil {
+		errMsg := s.getErrMsg(commonErr.ERR_CODE_GET_BLOCKCHAIN, err)
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+	netService, ok := bc.GetNetService().(*net.NetService)
+	if !ok {
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = fmt.Sprintf("net service of chain %s does not support the net admin", tx.Payload.ChainId)
+		return resp
+	}
+
+	if tx.Payload.Method == net.NetAdminMethodDial || tx.Payload.Method == net.NetAdminMethodDisconnect {
+		if err = s.verifyLocalAdmin(tx, bc.GetAccessControl()); err == nil {
+			err = s.adminReplay.check(tx)
+		}
+	}
+	params := s.kvPair2Map(tx.Payload.Parameters)
+	if err == nil {
+		switch tx.Payload.Method {
+		case net.NetAdminMethodPeers:
+			var peers *net.ChainPeers
+			if peers, err = s.chainMakerServer.NetPeers(tx.Payload.ChainId); err == nil {
+				result, err = json.Marshal(peers)
+			}
+		case net.NetAdminMethodSeeds:
+			result, err = json.Marshal(s.chainMakerServer.NetSeeds())
+		case net.NetAdminMethodDial:
+			address := string(params[net.NetAdminParamAddress])
+			if address == "" {
+				err = fmt.Errorf("%s is required", net.NetAdminParamAddress)
+				break
+			}
+			err = netService.Dial(address)
+		case net.NetAdminMethodDisconnect:
+			nodeId := string(params[net.NetAdminParamNodeId])
+			if nodeId == "" {
+				err = fmt.Errorf("%s is required", net.NetAdminParamNodeId)
+				break
+			}
+			err = netService.Disconnect(nodeId)
+		default:
+			err = fmt.Errorf("unknown method %s of %s", tx.Payload.Method, net.NetAdminContractName)
+		}
+	}
+
+	if err != nil {
+		errMsg := fmt.Sprintf("net admin %s failed, %s", tx.Payload.Method, err.Error())
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+
+	resp.Code = commonPb.TxStatusCode_SUCCESS
+	resp.Message = commonPb.TxStatusCode_SUCCESS.String()
+	resp.ContractResult = &commonPb.ContractResult{
+		Code:   0,
+		Result: result,
+	}
+	return resp
+}
@@ -0,0 +1,83 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpcserver
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"chainmaker.org/chainmaker-go/blockchain"
+	"chainmaker.org/chainmaker-go/net"
+	commonErr "chainmaker.org/chainmaker/common/v2/errors"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+)
+
+func init() {
+	registerAdminHandler(net.PeerScoreContractName, (*ApiService).doPeerScore)
+}
+
+// doPeerScore - deal the queries of the scores of the peers of the chain on the node, i.e. list and unban
+func (s *ApiService) doPeerScore(tx *commonPb.Transaction) *commonPb.TxResponse {
+	var (
+		err    error
+		bc     *blockchain.Blockchain
+		result []byte
+		resp   = &commonPb.TxResponse{TxId: tx.Payload.TxId}
+	)
+
+	if bc, err = s.chainMakerServer.GetBlockchain(tx.Payload.ChainId); err != nil {
+		errMsg := s.getErrMsg(commonErr.ERR_CODE_GET_BLOCKCHAIN, err)
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+	netService, ok := bc.GetNetService().(*net.NetService)
+	if !ok {
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = fmt.Sprintf("net service of chain %s does not support peer scores", tx.Payload.ChainId)
+		return resp
+	}
+
+	if tx.Payload.Method != net.PeerScoreMethodList {
+		if err = s.verifyLocalAdmin(tx, bc.GetAccessControl()); err == nil {
+			err = s.adminReplay.check(tx)
+		}
+	}
+	params := s.kvPair2Map(tx.Payload.Parameters)
+	if err == nil {
+		switch tx.Payload.Method {
+		case net.PeerScoreMethodList:
+			result, err = json.Marshal(netService.PeerScores())
+		case net.PeerScoreMethodUnban:
+			nodeId := string(params[net.PeerScoreParamNodeId])
+			if nodeId == "" {
+				err = fmt.Errorf("%s is required", net.PeerScoreParamNodeId)
+			} else {
+				err = netService.UnbanPeer(nodeId)
+			}
+		default:
+			err = fmt.Errorf("unknown method %s of %s", tx.Payload.Method, net.PeerScoreContractName)
+		}
+	}
+
+	if err != nil {
+		errMsg := fmt.Sprintf("peer score %s failed, %s", tx.Payload.Method, err.Error())
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+
+	resp.Code = commonPb.TxStatusCode_SUCCESS
+	resp.Message = commonPb.TxStatusCode_SUCCESS.String()
+	resp.ContractResult = &commonPb.ContractResult{
+		Code:   0,
+		Result: result,
+	}
+	return resp
+}
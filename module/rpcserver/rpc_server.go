@@ -15,8 +15,11 @@ import (
 	"net"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"chainmaker.org/chainmaker-go/accesscontrol"
 	"chainmaker.org/chainmaker-go/blockchain"
 	"chainmaker.org/chainmaker/common/v2/ca"
 	"chainmaker.org/chainmaker/common/v2/crypto"
@@ -33,6 +36,8 @@ import (
 
 // RPCServer struct define
 type RPCServer struct {
+	// restartLock serializes the restarts, such as on the trust roots changing
+	restartLock                sync.Mutex
 	grpcServer                 *grpc.Server
 	chainMakerServer           *blockchain.ChainMakerServer
 	log                        *logger.CMLogger
@@ -40,6 +45,9 @@ type RPCServer struct {
 	cancel                     context.CancelFunc
 	curChainConfTrustRootsHash string
 	isShutdown                 bool
+	access                     *accessLimiter
+	// trustRootsCheckInterval is the interval in seconds of checking the trust roots changing
+	trustRootsCheckInterval int64
 }
 
 // prom monitor define
@@ -71,7 +79,8 @@ const (
 // NewRPCServer - new RPCServer object
 func NewRPCServer(chainMakerServer *blockchain.ChainMakerServer) (*RPCServer, error) {
 
-	server, err := newGrpc(chainMakerServer)
+	access := newAccessLimiter(localconf.ChainMakerConfig)
+	server, err := newGrpc(chainMakerServer, access)
 	if err != nil {
 		return nil, fmt.Errorf("new grpc server failed, %s", err.Error())
 	}
@@ -87,9 +96,11 @@ func NewRPCServer(chainMakerServer *blockchain.ChainMakerServer) (*RPCServer, er
 	}
 
 	return &RPCServer{
-		grpcServer:       server,
-		chainMakerServer: chainMakerServer,
-		log:              logger.GetLogger(logger.MODULE_RPC),
+		grpcServer:              server,
+		chainMakerServer:        chainMakerServer,
+		log:                     logger.GetLogger(logger.MODULE_RPC),
+		access:                  access,
+		trustRootsCheckInterval: int64(localconf.ChainMakerConfig.RpcConfig.CheckChainConfTrustRootsChangeInterval),
 	}, nil
 }
 
@@ -141,7 +152,7 @@ func (s *RPCServer) Start() error {
 
 // RegisterHandler - register apiservice handler to rpcserver
 func (s *RPCServer) RegisterHandler() error {
-	apiService := NewApiService(s.ctx, s.chainMakerServer)
+	apiService := newApiService(s.ctx, s.chainMakerServer, s.access)
 	apiPb.RegisterRpcNodeServer(s.grpcServer, apiService)
 	return nil
 }
@@ -154,8 +165,33 @@ func (s *RPCServer) Stop() {
 	s.log.Info("RPCServer is stopped!")
 }
 
+// StopGracefully - stop RPCServer, the subscription streams are closed with an unavailable status at once,
+// the other requests in progress are waited for until the ctx is done, then the connections are closed forcibly
+func (s *RPCServer) StopGracefully(ctx context.Context) {
+	s.isShutdown = true
+	s.cancel()
+	stoppedC := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stoppedC)
+	}()
+	select {
+	case <-stoppedC:
+		s.log.Info("RPCServer is stopped gracefully!")
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		s.log.Warn("RPCServer is stopped forcibly, the requests in progress are not finished in time")
+	}
+}
+
 // Restart - Restart RPCServer
 func (s *RPCServer) Restart(reason string) error {
+	s.restartLock.Lock()
+	defer s.restartLock.Unlock()
+	return s.restart(reason)
+}
+
+func (s *RPCServer) restart(reason string) error {
 	var (
 		err error
 	)
@@ -165,7 +201,7 @@ func (s *RPCServer) Restart(reason string) error {
 	s.cancel()
 	s.grpcServer.GracefulStop()
 
-	s.grpcServer, err = newGrpc(s.chainMakerServer)
+	s.grpcServer, err = newGrpc(s.chainMakerServer, s.access)
 	if err != nil {
 		errMsg := fmt.Sprintf("RPCServer restart for reason [%s], new rpc server failed, %s", reason, err.Error())
 		s.log.Errorf(errMsg)
@@ -182,6 +218,25 @@ func (s *RPCServer) Restart(reason string) error {
 	return nil
 }
 
+// Module - the name of the module applying the local config reloaded
+func (s *RPCServer) Module() string {
+	return "RPCServer"
+}
+
+// WatchedLocalConfigs - the rpc configs applied at runtime
+func (s *RPCServer) WatchedLocalConfigs() []string {
+	return []string{"RpcConfig.RateLimitConfig", "RpcConfig.SubscriberConfig", "RpcConfig.BlackList",
+		"RpcConfig.CheckChainConfTrustRootsChangeInterval"}
+}
+
+// WatchLocalConfig - apply the rate limits and the blacklist reloaded in place, the connections and the
+// streams in progress are kept
+func (s *RPCServer) WatchLocalConfig(config *localconf.CMConfig) error {
+	s.access.reset(config)
+	atomic.StoreInt64(&s.trustRootsCheckInterval, int64(config.RpcConfig.CheckChainConfTrustRootsChangeInterval))
+	return nil
+}
+
 func (s *RPCServer) getCurChainConfTrustRootsHash() (string, error) {
 	chainConfs, err := s.chainMakerServer.GetAllChainConf()
 	if err != nil {
@@ -227,7 +282,7 @@ func (s *RPCServer) tryReloadChainConfTrustRootsChange() {
 }
 
 func (s *RPCServer) sleep() {
-	checkChainConfTrustRootsChangeInterval := localconf.ChainMakerConfig.RpcConfig.CheckChainConfTrustRootsChangeInterval
+	checkChainConfTrustRootsChangeInterval := atomic.LoadInt64(&s.trustRootsCheckInterval)
 	if checkChainConfTrustRootsChangeInterval < 10 {
 		checkChainConfTrustRootsChangeInterval = 10
 	}
@@ -235,6 +290,8 @@ func (s *RPCServer) sleep() {
 }
 
 func (s *RPCServer) checkAndRestart() error {
+	s.restartLock.Lock()
+	defer s.restartLock.Unlock()
 
 	rootsHash, err := s.getCurChainConfTrustRootsHash()
 	if err != nil {
@@ -245,7 +302,7 @@ func (s *RPCServer) checkAndRestart() error {
 		s.log.Debugf("different chain config trust roots cert hash: [old:%s]/[new:%s]",
 			s.curChainConfTrustRootsHash, rootsHash)
 
-		if err := s.Restart("TrustRoots certs change, reload it"); err != nil {
+		if err := s.restart("TrustRoots certs change, reload it"); err != nil {
 			return err
 		}
 
@@ -258,7 +315,7 @@ func (s *RPCServer) checkAndRestart() error {
 }
 
 // newGrpc - new GRPC object
-func newGrpc(chainMakerServer *blockchain.ChainMakerServer) (*grpc.Server, error) {
+func newGrpc(chainMakerServer *blockchain.ChainMakerServer, access *accessLimiter) (*grpc.Server, error) {
 	var opts []grpc.ServerOption
 	if localconf.ChainMakerConfig.MonitorConfig.Enabled {
 		opts = []grpc.ServerOption{
@@ -266,11 +323,11 @@ func newGrpc(chainMakerServer *blockchain.ChainMakerServer) (*grpc.Server, error
 				RecoveryInterceptor,
 				LoggingInterceptor,
 				MonitorInterceptor,
-				BlackListInterceptor(),
-				RateLimitInterceptor(),
+				BlackListInterceptor(access),
+				RateLimitInterceptor(access),
 			),
 			grpc_middleware.WithStreamServerChain(
-				BlackListStreamInterceptor(),
+				BlackListStreamInterceptor(access),
 			),
 		}
 	} else {
@@ -278,11 +335,11 @@ func newGrpc(chainMakerServer *blockchain.ChainMakerServer) (*grpc.Server, error
 			grpc_middleware.WithUnaryServerChain(
 				RecoveryInterceptor,
 				LoggingInterceptor,
-				BlackListInterceptor(),
-				RateLimitInterceptor(),
+				BlackListInterceptor(access),
+				RateLimitInterceptor(access),
 			),
 			grpc_middleware.WithStreamServerChain(
-				BlackListStreamInterceptor(),
+				BlackListStreamInterceptor(access),
 			),
 		}
 	}
@@ -311,11 +368,10 @@ func newGrpc(chainMakerServer *blockchain.ChainMakerServer) (*grpc.Server, error
 
 		}
 
-		tlsRPCServer := ca.CAServer{
-			CaCerts:  caCerts,
-			CertFile: localconf.ChainMakerConfig.RpcConfig.TLSConfig.CertFile,
-			KeyFile:  localconf.ChainMakerConfig.RpcConfig.TLSConfig.PrivKeyFile,
-			Logger:   log,
+		keyPEM, err := accesscontrol.ReadPrivateKeyFile(localconf.ChainMakerConfig.RpcConfig.TLSConfig.PrivKeyFile)
+		if err != nil {
+			log.Errorf("new gRPC failed, load tls key err: %v", err)
+			return nil, err
 		}
 
 		checkClientAuth := false
@@ -335,14 +391,14 @@ func newGrpc(chainMakerServer *blockchain.ChainMakerServer) (*grpc.Server, error
 			GMVerifyPeerCertificate: createGMVerifyPeerCertificateFunc(acs),
 		}
 
-		//c, err := tlsRPCServer.GetCredentialsByCA(checkClientAuth)
-		c, err := tlsRPCServer.GetCredentialsByCA(checkClientAuth, customVerify)
+		c, err := newTLSCredentials(caCerts, localconf.ChainMakerConfig.RpcConfig.TLSConfig.CertFile, keyPEM,
+			checkClientAuth, customVerify)
 		if err != nil {
-			log.Errorf("new gRPC failed, GetTLSCredentialsByCA err: %v", err)
+			log.Errorf("new gRPC failed, new tls credentials err: %v", err)
 			return nil, err
 		}
 
-		opts = append(opts, grpc.Creds(*c))
+		opts = append(opts, grpc.Creds(c))
 	}
 
 	opts = append(opts, grpc.MaxSendMsgSize(maxSendMessageSize))
@@ -0,0 +1,87 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpcserver
+
+import (
+	"fmt"
+	"strconv"
+
+	"chainmaker.org/chainmaker-go/consensus/solo"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+)
+
+func init() {
+	registerAdminHandler(solo.DevContractName, (*ApiService).doSoloDev)
+}
+
+// doSoloDev - deal the dev control queries of solo dev mode, i.e. mine blocks and fast forward time
+func (s *ApiService) doSoloDev(tx *commonPb.Transaction) *commonPb.TxResponse {
+	var (
+		err    error
+		result []byte
+		resp   = &commonPb.TxResponse{TxId: tx.Payload.TxId}
+	)
+
+	controller, err := solo.GetDevController(tx.Payload.ChainId)
+	if err == nil {
+		err = s.checkSoloDevAdmin(tx)
+	}
+	if err != nil {
+		s.log.Error(err)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = err.Error()
+		return resp
+	}
+
+	params := s.kvPair2Map(tx.Payload.Parameters)
+	switch tx.Payload.Method {
+	case solo.DevMethodMine:
+		var blocks uint64
+		if blocks, err = solo.ParseDevParam(params, solo.DevParamBlocks); err == nil {
+			err = controller.Mine(blocks)
+		}
+	case solo.DevMethodFastForward:
+		var seconds uint64
+		if seconds, err = solo.ParseDevParam(params, solo.DevParamSeconds); err == nil {
+			var offset int64
+			if offset, err = controller.FastForward(int64(seconds)); err == nil {
+				result = []byte(strconv.FormatInt(offset, 10))
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown method %s of %s", tx.Payload.Method, solo.DevContractName)
+	}
+
+	if err != nil {
+		errMsg := fmt.Sprintf("solo dev %s failed, %s", tx.Payload.Method, err.Error())
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+
+	resp.Code = commonPb.TxStatusCode_SUCCESS
+	resp.Message = commonPb.TxStatusCode_SUCCESS.String()
+	resp.ContractResult = &commonPb.ContractResult{
+		Code:   0,
+		Result: result,
+	}
+	return resp
+}
+
+// checkSoloDevAdmin checks the sender of the tx is an admin of the org of the node, since the dev control
+// changes the blocks of the chain
+func (s *ApiService) checkSoloDevAdmin(tx *commonPb.Transaction) error {
+	if tx.Sender == nil || tx.Sender.Signer == nil {
+		return fmt.Errorf("sender is required")
+	}
+	bc, err := s.chainMakerServer.GetBlockchain(tx.Payload.ChainId)
+	if err != nil {
+		return err
+	}
+	return s.verifyLocalAdmin(tx, bc.GetAccessControl())
+}
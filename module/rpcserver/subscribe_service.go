@@ -33,6 +33,11 @@ const (
 	TRUE = "true"
 )
 
+// errSubscriptionStopped is the final message of the subscription streams when the node is stopping or restarting,
+// the clients should subscribe again, to another node if this one is stopping
+var errSubscriptionStopped = status.Error(codes.Unavailable,
+	"chainmaker is stopping or restarting, please subscribe later")
+
 // Subscribe - deal block/tx subscribe request
 func (s *ApiService) Subscribe(req *commonPb.TxRequest, server apiPb.RpcNode_SubscribeServer) error {
 	var (
@@ -51,6 +56,12 @@ func (s *ApiService) Subscribe(req *commonPb.TxRequest, server apiPb.RpcNode_Sub
 		return status.Error(codes.Unauthenticated, errMsg)
 	}
 
+	release, err := s.chainResources.acquireSubscription(req.Payload.ChainId)
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	defer release()
+
 	switch req.Payload.Method {
 	case syscontract.SubscribeFunction_SUBSCRIBE_BLOCK.String():
 		return s.dealBlockSubscription(tx, server)
@@ -322,7 +333,7 @@ func (s *ApiService) doSendContractEvent(tx *commonPb.Transaction, server apiPb.
 		case <-server.Context().Done():
 			return nil
 		case <-s.ctx.Done():
-			return nil
+			return errSubscriptionStopped
 		}
 	}
 }
@@ -481,7 +492,7 @@ func (s *ApiService) sendNewBlock(store protocol.BlockchainStore, tx *commonPb.T
 		case <-server.Context().Done():
 			return nil
 		case <-s.ctx.Done():
-			return nil
+			return errSubscriptionStopped
 		}
 	}
 }
@@ -570,7 +581,7 @@ func (s *ApiService) sendNewTx(store protocol.BlockchainStore, tx *commonPb.Tran
 		case <-server.Context().Done():
 			return nil
 		case <-s.ctx.Done():
-			return nil
+			return errSubscriptionStopped
 		}
 	}
 }
@@ -590,8 +601,8 @@ func (s *ApiService) checkIsFinish(txIds []string, endBlock int64,
 }
 
 func (s *ApiService) getRateLimitToken() error {
-	if s.subscriberRateLimiter != nil {
-		if err := s.subscriberRateLimiter.Wait(s.ctx); err != nil {
+	if limiter := s.access.load().subscriberLimiter; limiter != nil {
+		if err := limiter.Wait(s.ctx); err != nil {
 			errMsg := fmt.Sprintf("subscriber rateLimiter wait token failed, %s", err.Error())
 			s.log.Error(errMsg)
 			return errors.New(errMsg)
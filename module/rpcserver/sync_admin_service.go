@@ -0,0 +1,90 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rpcserver
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"chainmaker.org/chainmaker-go/blockchain"
+	blockSync "chainmaker.org/chainmaker-go/sync"
+	commonErr "chainmaker.org/chainmaker/common/v2/errors"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+)
+
+func init() {
+	registerAdminHandler(blockSync.AdminContractName, (*ApiService).doSyncAdmin)
+}
+
+// doSyncAdmin - deal the admin queries of the block sync on the node, i.e. status, pause, resume, pin and resync
+func (s *ApiService) doSyncAdmin(tx *commonPb.Transaction) *commonPb.TxResponse {
+	var (
+		err    error
+		bc     *blockchain.Blockchain
+		result []byte
+		resp   = &commonPb.TxResponse{TxId: tx.Payload.TxId}
+	)
+
+	if bc, err = s.chainMakerServer.GetBlockchain(tx.Payload.ChainId); err != nil {
+		errMsg := s.getErrMsg(commonErr.ERR_CODE_GET_BLOCKCHAIN, err)
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+	syncServer, ok := bc.GetSyncService().(*blockSync.BlockChainSyncServer)
+	if !ok {
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = fmt.Sprintf("sync service of chain %s does not support admin", tx.Payload.ChainId)
+		return resp
+	}
+
+	if tx.Payload.Method != blockSync.AdminMethodStatus {
+		if err = s.verifyLocalAdmin(tx, bc.GetAccessControl()); err == nil {
+			err = s.adminReplay.check(tx)
+		}
+	}
+	params := s.kvPair2Map(tx.Payload.Parameters)
+	if err == nil {
+		switch tx.Payload.Method {
+		case blockSync.AdminMethodStatus:
+			var status *blockSync.SyncStatus
+			if status, err = syncServer.Status(); err == nil {
+				result, err = json.Marshal(status)
+			}
+		case blockSync.AdminMethodPause:
+			syncServer.Pause()
+		case blockSync.AdminMethodResume:
+			syncServer.Resume()
+		case blockSync.AdminMethodPinPeers:
+			err = syncServer.PinPeers(blockSync.ParseAdminPeers(params))
+		case blockSync.AdminMethodResync:
+			var height uint64
+			if height, err = blockSync.ParseAdminHeight(params); err == nil {
+				err = syncServer.Resync(height)
+			}
+		default:
+			err = fmt.Errorf("unknown method %s of %s", tx.Payload.Method, blockSync.AdminContractName)
+		}
+	}
+
+	if err != nil {
+		errMsg := fmt.Sprintf("sync admin %s failed, %s", tx.Payload.Method, err.Error())
+		s.log.Error(errMsg)
+		resp.Code = commonPb.TxStatusCode_INTERNAL_ERROR
+		resp.Message = errMsg
+		return resp
+	}
+
+	resp.Code = commonPb.TxStatusCode_SUCCESS
+	resp.Message = commonPb.TxStatusCode_SUCCESS.String()
+	resp.ContractResult = &commonPb.ContractResult{
+		Code:   0,
+		Result: result,
+	}
+	return resp
+}
@@ -6,14 +6,20 @@ SPDX-License-Identifier: Apache-2.0
 package rpcserver
 
 import (
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 
 	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
 
+	"chainmaker.org/chainmaker/common/v2/ca"
+	cmtls "chainmaker.org/chainmaker/common/v2/crypto/tls"
+	cmcredentials "chainmaker.org/chainmaker/common/v2/crypto/tls/credentials"
 	cmx509 "chainmaker.org/chainmaker/common/v2/crypto/x509"
 	"chainmaker.org/chainmaker/protocol/v2"
+	"google.golang.org/grpc/credentials"
 )
 
 func createVerifyPeerCertificateFunc(
@@ -142,3 +148,57 @@ func checkMemberStatusIsRevoked(accessControls []protocol.AccessControlProvider,
 
 	return false, nil
 }
+
+// newTLSCredentials builds the tls credentials of the rpc server from the key PEM in memory, the key of an
+// encrypted key file should never be written to a file. The GM tls is used if the cert is not a standard one.
+func newTLSCredentials(caCerts []string, certFile string, keyPEM []byte, checkClientAuth bool,
+	customVerify ca.CustomVerify) (credentials.TransportCredentials, error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("read cert file %s failed, %s", certFile, err)
+	}
+	var caPEMs [][]byte
+	for _, caCert := range caCerts {
+		caPEMs = append(caPEMs, []byte(caCert))
+	}
+
+	if cert, err := tls.X509KeyPair(certPEM, keyPEM); err == nil {
+		certPool := x509.NewCertPool()
+		for _, caPEM := range caPEMs {
+			if !certPool.AppendCertsFromPEM(caPEM) {
+				return nil, fmt.Errorf("append ca cert failed")
+			}
+		}
+		clientAuth := tls.NoClientCert
+		if checkClientAuth {
+			clientAuth = tls.RequireAndVerifyClientCert
+		}
+		return credentials.NewTLS(&tls.Config{
+			Certificates:          []tls.Certificate{cert},
+			ClientAuth:            clientAuth,
+			ClientCAs:             certPool,
+			VerifyPeerCertificate: customVerify.VerifyPeerCertificate,
+		}), nil
+	}
+
+	cert, err := cmtls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("load tls key pair failed, %s", err)
+	}
+	certPool := cmx509.NewCertPool()
+	for _, caPEM := range caPEMs {
+		if !certPool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("append ca cert failed")
+		}
+	}
+	clientAuth := cmtls.NoClientCert
+	if checkClientAuth {
+		clientAuth = cmtls.RequireAndVerifyClientCert
+	}
+	return cmcredentials.NewTLS(&cmtls.Config{
+		Certificates:          []cmtls.Certificate{cert},
+		ClientAuth:            clientAuth,
+		ClientCAs:             certPool,
+		VerifyPeerCertificate: customVerify.GMVerifyPeerCertificate,
+	}), nil
+}
@@ -0,0 +1,256 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// AdminContractName is the pseudo contract of the sync admin queries, they are served
+// by the rpc server of the node and never go on chain.
+const AdminContractName = "SYNC_ADMIN"
+
+// The methods of AdminContractName, all but AdminMethodStatus require an admin of the org of the node.
+const (
+	AdminMethodStatus   = "GET_STATUS"
+	AdminMethodPause    = "PAUSE"
+	AdminMethodResume   = "RESUME"
+	AdminMethodPinPeers = "PIN_PEERS"
+	AdminMethodResync   = "RESYNC"
+)
+
+// The parameters of the sync admin methods
+const (
+	// AdminParamPeers is the comma separated node ids, empty to unpin
+	AdminParamPeers = "peers"
+	// AdminParamHeight is the height to resync from
+	AdminParamHeight = "height"
+)
+
+// SyncStatus is the status of the block sync on a chain
+type SyncStatus struct {
+	ChainId         string       `json:"chain_id"`
+	Height          uint64       `json:"height"`
+	Paused          bool         `json:"paused"`
+	ArchiveSyncing  bool         `json:"archive_syncing"`
+	BlocksPerSecond float64      `json:"blocks_per_second"`
+	PendingBlocks   int          `json:"pending_blocks"`
+	PinnedPeers     []string     `json:"pinned_peers,omitempty"`
+	Peers           []PeerStatus `json:"peers"`
+}
+
+// PeerStatus is the status of a peer in the block sync
+type PeerStatus struct {
+	NodeId          string  `json:"node_id"`
+	Height          uint64  `json:"height"`
+	LatencyMs       int64   `json:"latency_ms"`
+	BlocksPerSecond float64 `json:"blocks_per_second"`
+	Penalty         int     `json:"penalty"`
+	PendingBlocks   int     `json:"pending_blocks"`
+	BannedUntil     string  `json:"banned_until,omitempty"`
+}
+
+// schedulerStatus is the part of SyncStatus owned by the scheduler
+type schedulerStatus struct {
+	pendingBlocks int
+	pinnedPeers   []string
+	peers         []PeerStatus
+}
+
+type statusQuery struct {
+	EqualLevel
+	resp chan schedulerStatus
+}
+
+type pinPeersMsg struct {
+	EqualLevel
+	peers []string
+}
+
+type resyncMsg struct {
+	EqualLevel
+	height uint64
+}
+
+// Status returns the status of the block sync and the peers
+func (sync *BlockChainSyncServer) Status() (*SyncStatus, error) {
+	if atomic.LoadInt32(&sync.start) != 1 {
+		return nil, fmt.Errorf("sync service is not started")
+	}
+	height, err := sync.ledgerCache.CurrentHeight()
+	if err != nil {
+		return nil, err
+	}
+	query := statusQuery{resp: make(chan schedulerStatus, 1)}
+	if err = sync.scheduler.addTask(query); err != nil {
+		return nil, err
+	}
+	var schStatus schedulerStatus
+	select {
+	case schStatus = <-query.resp:
+	case <-time.After(sync.conf.timeOut):
+		return nil, fmt.Errorf("query status of scheduler timeout")
+	}
+	return &SyncStatus{
+		ChainId:         sync.chainId,
+		Height:          height,
+		Paused:          sync.isPaused(),
+		ArchiveSyncing:  sync.isArchiveSyncing(),
+		BlocksPerSecond: math.Float64frombits(atomic.LoadUint64(&sync.blocksPerSecond)),
+		PendingBlocks:   schStatus.pendingBlocks,
+		PinnedPeers:     schStatus.pinnedPeers,
+		Peers:           schStatus.peers,
+	}, nil
+}
+
+// Pause stops requesting and committing blocks until Resume, the node keeps serving peers
+func (sync *BlockChainSyncServer) Pause() {
+	if atomic.CompareAndSwapInt32(&sync.paused, 0, 1) {
+		sync.log.Infof("block sync paused")
+	}
+}
+
+// Resume resumes the block sync paused
+func (sync *BlockChainSyncServer) Resume() {
+	if atomic.CompareAndSwapInt32(&sync.paused, 1, 0) {
+		sync.log.Infof("block sync resumed")
+	}
+}
+
+func (sync *BlockChainSyncServer) isPaused() bool {
+	return atomic.LoadInt32(&sync.paused) == 1
+}
+
+// PinPeers requests blocks from the peers only, an empty list unpins
+func (sync *BlockChainSyncServer) PinPeers(peers []string) error {
+	sync.log.Infof("pin block sync to peers %v", peers)
+	return sync.scheduler.addTask(pinPeersMsg{peers: peers})
+}
+
+// Resync discards the pending and received blocks from the height and requests them again
+func (sync *BlockChainSyncServer) Resync(height uint64) error {
+	current, err := sync.ledgerCache.CurrentHeight()
+	if err != nil {
+		return err
+	}
+	if height <= current {
+		return fmt.Errorf("block [%d] has been committed, the current height is %d", height, current)
+	}
+	sync.log.Infof("resync blocks from height %d", height)
+	if err = sync.processor.addTask(resyncMsg{height: height}); err != nil {
+		return err
+	}
+	return sync.scheduler.addTask(resyncMsg{height: height})
+}
+
+// ParseAdminPeers parses AdminParamPeers
+func ParseAdminPeers(params map[string][]byte) []string {
+	var peers []string
+	for _, peer := range strings.Split(string(params[AdminParamPeers]), ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// ParseAdminHeight parses AdminParamHeight
+func ParseAdminHeight(params map[string][]byte) (uint64, error) {
+	value, ok := params[AdminParamHeight]
+	if !ok {
+		return 0, fmt.Errorf("param %s is required", AdminParamHeight)
+	}
+	return strconv.ParseUint(string(value), 10, 64)
+}
+
+// updateBlocksPerSecond updates the rate of the blocks committed since the last update
+func (sync *BlockChainSyncServer) updateBlocksPerSecond(lastCommitted uint64, lastTime time.Time) (uint64, time.Time) {
+	committed, now := atomic.LoadUint64(&sync.committedBlocks), time.Now()
+	if elapsed := now.Sub(lastTime).Seconds(); elapsed > 0 {
+		bps := float64(committed-lastCommitted) / elapsed
+		atomic.StoreUint64(&sync.blocksPerSecond, math.Float64bits(bps))
+		sync.metrics.setBlocksPerSecond(bps)
+	}
+	return committed, now
+}
+
+func (sch *scheduler) handleStatusQuery(query statusQuery) {
+	status := schedulerStatus{pendingBlocks: len(sch.blockStates)}
+	for peer := range sch.pinnedPeers {
+		status.pinnedPeers = append(status.pinnedPeers, peer)
+	}
+	sort.Strings(status.pinnedPeers)
+	for peer, height := range sch.peers {
+		stat := sch.peerStat(peer)
+		status.peers = append(status.peers, PeerStatus{
+			NodeId:          peer,
+			Height:          height,
+			LatencyMs:       stat.latency.Milliseconds(),
+			BlocksPerSecond: stat.throughput,
+			Penalty:         stat.penalty,
+			PendingBlocks:   sch.getPendingReqInPeer(peer),
+		})
+	}
+	for peer, until := range sch.bannedPeers {
+		if time.Now().Before(until) {
+			status.peers = append(status.peers, PeerStatus{NodeId: peer, BannedUntil: until.Format(time.RFC3339)})
+		}
+	}
+	sort.Slice(status.peers, func(i, j int) bool {
+		return status.peers[i].NodeId < status.peers[j].NodeId
+	})
+	query.resp <- status
+}
+
+func (sch *scheduler) handlePinPeers(msg pinPeersMsg) {
+	sch.pinnedPeers = make(map[string]bool, len(msg.peers))
+	for _, peer := range msg.peers {
+		sch.pinnedPeers[peer] = true
+	}
+}
+
+func (sch *scheduler) handleResync(msg resyncMsg) {
+	for height := range sch.blockStates {
+		if height < msg.height {
+			continue
+		}
+		sch.blockStates[height] = newBlock
+		delete(sch.pendingBlocks, height)
+		delete(sch.pendingTime, height)
+		delete(sch.receivedBlocks, height)
+	}
+	if sch.pendingRecvHeight > msg.height {
+		sch.pendingRecvHeight = msg.height
+	}
+}
+
+func (pro *processor) handleResync(msg resyncMsg) {
+	for height := range pro.queue {
+		if height >= msg.height {
+			delete(pro.queue, height)
+		}
+	}
+}
+
+// reportMetrics reports the sync lag and the pending pool size
+func (sch *scheduler) reportMetrics() {
+	if sch.metrics == nil {
+		return
+	}
+	var lag uint64
+	if currHeight, err := sch.ledger.CurrentHeight(); err == nil && sch.maxHeight() > currHeight {
+		lag = sch.maxHeight() - currHeight
+	}
+	sch.metrics.setLag(lag)
+	sch.metrics.setPendingBlocks(len(sch.blockStates))
+}
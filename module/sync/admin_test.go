@@ -0,0 +1,49 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker/logger/v2"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	syncPb "chainmaker.org/chainmaker/pb-go/v2/sync"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerAdmin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockSender := NewMockSender()
+	mockLedger := newMockLedgerCache(ctrl, &commonPb.Block{Header: &commonPb.BlockHeader{BlockHeight: 10}})
+	sch := newScheduler(mockSender, mockLedger, 100, time.Second, time.Second*3, 2, logger.GetLogger(logger.MODULE_SYNC))
+	_, _ = sch.handler(NodeStatusMsg{from: "node1", msg: syncPb.BlockHeightBCM{BlockHeight: 100}})
+	_, _ = sch.handler(NodeStatusMsg{from: "node2", msg: syncPb.BlockHeightBCM{BlockHeight: 50}})
+
+	// 1. pin to node2
+	_, _ = sch.handler(pinPeersMsg{peers: []string{"node2"}})
+	_, _ = sch.handler(SchedulerMsg{})
+	require.Equal(t, []string{"msgType: 2, to: node2"}, mockSender.msgs)
+
+	// 2. query the status
+	query := statusQuery{resp: make(chan schedulerStatus, 1)}
+	_, _ = sch.handler(query)
+	status := <-query.resp
+	require.Equal(t, []string{"node2"}, status.pinnedPeers)
+	require.Len(t, status.peers, 2)
+	require.Equal(t, "node1", status.peers[0].NodeId)
+	require.EqualValues(t, 100, status.peers[0].Height)
+	require.Equal(t, 2, status.peers[1].PendingBlocks)
+
+	// 3. resync from 12 drops the pending block 12 only
+	_, _ = sch.handler(resyncMsg{height: 12})
+	require.EqualValues(t, pendingBlock, sch.blockStates[11])
+	require.EqualValues(t, newBlock, sch.blockStates[12])
+	require.Len(t, sch.pendingBlocks, 1)
+}
@@ -0,0 +1,292 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	storePb "chainmaker.org/chainmaker/pb-go/v2/store"
+	// register the mysql driver for the archive database
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// The types of the archive source
+const (
+	ArchiveSourceMysql = "mysql"
+	ArchiveSourceFile  = "file"
+	ArchiveSourceS3    = "s3"
+)
+
+// The layout of the file and s3 archive under the prefix, every block is stored as the
+// serialized storePb.BlockWithRWSet, the same as the rows written by `cmc archive dump`.
+const (
+	archivedHeightObject = "archived_height"
+	archivedBlockDir     = "blocks"
+)
+
+// The layout of the database written by `cmc archive dump`
+const (
+	archiveDbPrefix          = "cm_archived_chain"
+	archiveBlockTablePrefix  = "t_block_info"
+	archiveRowsPerBlockTable = uint64(100000)
+	archiveSysinfoTable      = "sysinfos"
+	archiveHeightSysinfoKey  = "archived_block_height"
+)
+
+const (
+	maxArchivedObjectSize      = 256 * 1024 * 1024
+	objectStoreRequestTimeout  = 30 * time.Second
+	objectStoreDefaultRegion   = "us-east-1"
+	objectStoreEmptyBodySha256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// ArchiveSourceConfig configures the archive source of the block sync
+type ArchiveSourceConfig struct {
+	// Type is one of mysql, file and s3
+	Type string `mapstructure:"type"`
+	// Dest is the database destination of mysql, same as `cmc archive --dest`, eg. user:password:localhost:port
+	Dest string `mapstructure:"dest"`
+	// Path is the directory of the file archive
+	Path string `mapstructure:"path"`
+	// Endpoint, Bucket, Region, AccessKey and SecretKey locate the s3 compatible bucket,
+	// the requests are unsigned when AccessKey is empty
+	Endpoint  string `mapstructure:"endpoint"`
+	Bucket    string `mapstructure:"bucket"`
+	Region    string `mapstructure:"region"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	// Prefix of the archive in the directory or bucket, the chain id by default
+	Prefix string `mapstructure:"prefix"`
+}
+
+// NewArchiveSource creates the archive source of the chain by the config
+func NewArchiveSource(chainId string, config *ArchiveSourceConfig) (ArchiveSource, error) {
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = chainId
+	}
+	switch strings.ToLower(config.Type) {
+	case ArchiveSourceMysql:
+		return newMysqlArchiveSource(chainId, config.Dest)
+	case ArchiveSourceFile:
+		if config.Path == "" {
+			return nil, fmt.Errorf("path of the file archive is required")
+		}
+		return &fileArchiveSource{dir: filepath.Join(config.Path, prefix)}, nil
+	case ArchiveSourceS3:
+		if config.Endpoint == "" || config.Bucket == "" {
+			return nil, fmt.Errorf("endpoint and bucket of the s3 archive are required")
+		}
+		region := config.Region
+		if region == "" {
+			region = objectStoreDefaultRegion
+		}
+		return &objectStoreArchiveSource{
+			endpoint:  strings.TrimSuffix(config.Endpoint, "/"),
+			bucket:    config.Bucket,
+			prefix:    prefix,
+			region:    region,
+			accessKey: config.AccessKey,
+			secretKey: config.SecretKey,
+			client:    &http.Client{Timeout: objectStoreRequestTimeout},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown archive source type: %s", config.Type)
+}
+
+func parseArchivedHeight(bz []byte) (uint64, error) {
+	return strconv.ParseUint(strings.TrimSpace(string(bz)), 10, 64)
+}
+
+func parseArchivedBlock(bz []byte) (*commonPb.Block, error) {
+	blkWithRWSet := &storePb.BlockWithRWSet{}
+	if err := blkWithRWSet.Unmarshal(bz); err != nil {
+		return nil, err
+	}
+	return blkWithRWSet.Block, nil
+}
+
+// fileArchiveSource reads the archive in a local directory
+type fileArchiveSource struct {
+	dir string
+}
+
+func (s *fileArchiveSource) ArchivedHeight() (uint64, error) {
+	bz, err := ioutil.ReadFile(filepath.Join(s.dir, archivedHeightObject))
+	if err != nil {
+		return 0, err
+	}
+	return parseArchivedHeight(bz)
+}
+
+func (s *fileArchiveSource) GetBlock(height uint64) (*commonPb.Block, error) {
+	file := filepath.Join(s.dir, archivedBlockDir, strconv.FormatUint(height, 10))
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxArchivedObjectSize {
+		return nil, fmt.Errorf("archived block [%d] exceeds %d bytes", height, maxArchivedObjectSize)
+	}
+	bz, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseArchivedBlock(bz)
+}
+
+func (s *fileArchiveSource) Close() error {
+	return nil
+}
+
+// objectStoreArchiveSource reads the archive in a s3 compatible bucket by path style
+// requests, signed by AWS signature version 4 when the access key is set.
+type objectStoreArchiveSource struct {
+	endpoint  string
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func (s *objectStoreArchiveSource) ArchivedHeight() (uint64, error) {
+	bz, err := s.getObject(path.Join(s.prefix, archivedHeightObject))
+	if err != nil {
+		return 0, err
+	}
+	return parseArchivedHeight(bz)
+}
+
+func (s *objectStoreArchiveSource) GetBlock(height uint64) (*commonPb.Block, error) {
+	bz, err := s.getObject(path.Join(s.prefix, archivedBlockDir, strconv.FormatUint(height, 10)))
+	if err != nil {
+		return nil, err
+	}
+	return parseArchivedBlock(bz)
+}
+
+func (s *objectStoreArchiveSource) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func (s *objectStoreArchiveSource) getObject(key string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, s.endpoint+"/"+path.Join(s.bucket, key), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.accessKey != "" {
+		s.sign(req, time.Now())
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get object %s failed, status: %s", key, resp.Status)
+	}
+	bz, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxArchivedObjectSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(bz) > maxArchivedObjectSize {
+		return nil, fmt.Errorf("object %s exceeds %d bytes", key, maxArchivedObjectSize)
+	}
+	return bz, nil
+}
+
+// sign signs the GET request by AWS signature version 4
+func (s *objectStoreArchiveSource) sign(req *http.Request, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + s.region + "/s3/aws4_request"
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", objectStoreEmptyBodySha256)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + objectStoreEmptyBodySha256 + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		objectStoreEmptyBodySha256,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{amzDate[:8], s.region, "s3", "aws4_request"} {
+		key = hmacSha256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, hex.EncodeToString(hmacSha256(key, stringToSign))))
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// mysqlArchiveSource reads the database written by `cmc archive dump`
+type mysqlArchiveSource struct {
+	db *sql.DB
+}
+
+func newMysqlArchiveSource(chainId, dest string) (*mysqlArchiveSource, error) {
+	destSlice := strings.Split(dest, ":")
+	if len(destSlice) != 4 {
+		return nil, fmt.Errorf("invalid database destination, eg. user:password:localhost:port")
+	}
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s_%s", destSlice[0], destSlice[1], destSlice[2], destSlice[3],
+		archiveDbPrefix, chainId)
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlArchiveSource{db: db}, nil
+}
+
+func (s *mysqlArchiveSource) ArchivedHeight() (uint64, error) {
+	var height string
+	if err := s.db.QueryRow("SELECT v FROM "+archiveSysinfoTable+" WHERE k = ?",
+		archiveHeightSysinfoKey).Scan(&height); err != nil {
+		return 0, err
+	}
+	return parseArchivedHeight([]byte(height))
+}
+
+func (s *mysqlArchiveSource) GetBlock(height uint64) (*commonPb.Block, error) {
+	table := fmt.Sprintf("%s_%d", archiveBlockTablePrefix, height/archiveRowsPerBlockTable+1)
+	var bz []byte
+	if err := s.db.QueryRow("SELECT Fblock_with_rwset FROM "+table+" WHERE Fblock_height = ? AND Fis_archived = 1",
+		height).Scan(&bz); err != nil {
+		return nil, err
+	}
+	return parseArchivedBlock(bz)
+}
+
+func (s *mysqlArchiveSource) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,209 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+)
+
+// ArchiveSource provides the blocks archived off the chain, e.g. by `cmc archive dump`.
+// A node syncs the history from it when all the peers have archived the blocks it lacks.
+type ArchiveSource interface {
+	// ArchivedHeight returns the height of the last block in the source
+	ArchivedHeight() (uint64, error)
+	// GetBlock returns the block at the height, the block is verified before commit
+	GetBlock(height uint64) (*commonPb.Block, error)
+	// Close releases the resources of the source
+	Close() error
+}
+
+// WithArchiveSource enables syncing the blocks archived by all peers from the source
+func WithArchiveSource(source ArchiveSource) Option {
+	return func(sync *BlockChainSyncServer) {
+		sync.archiveSyncer = &archiveSyncer{source: source}
+	}
+}
+
+// archiveSyncer tracks the archived heights of peers to decide syncing from the archive
+type archiveSyncer struct {
+	source ArchiveSource
+
+	mtx         sync.Mutex
+	peers       map[string]peerArchive
+	lastAttempt time.Time
+	stopped     bool           // No archive sync starts once the sync service stops
+	running     sync.WaitGroup // The archive sync running
+}
+
+// archiveBarrier is done when the processor handles it, the tasks handled before it are finished
+type archiveBarrier struct {
+	EqualLevel
+	done chan struct{}
+}
+
+type peerArchive struct {
+	archivedHeight uint64
+	updated        time.Time
+}
+
+func (s *archiveSyncer) updatePeer(peer string, archivedHeight uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.peers == nil {
+		s.peers = make(map[string]peerArchive)
+	}
+	s.peers[peer] = peerArchive{archivedHeight: archivedHeight, updated: time.Now()}
+}
+
+// allPeersArchived returns whether every peer reported in the ttl has archived too high to
+// serve the node at the local height, as the scheduler judges by isPeerArchivedTooHeight.
+func (s *archiveSyncer) allPeersArchived(localHeight uint64, ttl time.Duration) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	active := 0
+	for peer, status := range s.peers {
+		if time.Since(status.updated) > ttl {
+			delete(s.peers, peer)
+			continue
+		}
+		if status.archivedHeight == 0 || status.archivedHeight < localHeight {
+			return false
+		}
+		active++
+	}
+	return active > 0
+}
+
+// needArchiveSync checks whether no peer serves the next block and it is time to try the archive
+func (sync *BlockChainSyncServer) needArchiveSync() bool {
+	if sync.archiveSyncer == nil || sync.isArchiveSyncing() {
+		return false
+	}
+	height, err := sync.ledgerCache.CurrentHeight()
+	if err != nil || !sync.archiveSyncer.allPeersArchived(height, 3*sync.conf.nodeStatusTick) {
+		return false
+	}
+	sync.archiveSyncer.mtx.Lock()
+	defer sync.archiveSyncer.mtx.Unlock()
+	if time.Since(sync.archiveSyncer.lastAttempt) < sync.conf.archiveSyncInterval {
+		return false
+	}
+	sync.archiveSyncer.lastAttempt = time.Now()
+	return true
+}
+
+// isArchiveSyncing returns whether the node is syncing from the archive, the block sync pauses meanwhile
+func (sync *BlockChainSyncServer) isArchiveSyncing() bool {
+	return atomic.LoadInt32(&sync.archiveSyncing) == 1
+}
+
+// startArchiveSync starts the archive sync unless the sync service stopped
+func (sync *BlockChainSyncServer) startArchiveSync() {
+	sync.archiveSyncer.mtx.Lock()
+	defer sync.archiveSyncer.mtx.Unlock()
+	if sync.archiveSyncer.stopped {
+		return
+	}
+	atomic.StoreInt32(&sync.archiveSyncing, 1)
+	sync.archiveSyncer.running.Add(1)
+	go sync.archiveSync()
+}
+
+// stopArchiveSync waits for the archive sync running to stop, then closes the archive source
+func (sync *BlockChainSyncServer) stopArchiveSync() {
+	sync.archiveSyncer.mtx.Lock()
+	sync.archiveSyncer.stopped = true
+	sync.archiveSyncer.mtx.Unlock()
+	sync.archiveSyncer.running.Wait()
+	if err := sync.archiveSyncer.source.Close(); err != nil {
+		sync.log.Warnf("close archive source failed, reason: %s", err)
+	}
+}
+
+// archiveSync commits the blocks of the archive source in order through the same
+// verification as the block sync, then returns to the block sync from the peers.
+func (sync *BlockChainSyncServer) archiveSync() {
+	defer sync.archiveSyncer.running.Done()
+	defer atomic.StoreInt32(&sync.archiveSyncing, 0)
+
+	if err := sync.drainProcessor(); err != nil {
+		sync.log.Warnf("sync from archive not started, reason: %s", err)
+		return
+	}
+	height, err := sync.syncFromArchive()
+	if err != nil {
+		sync.log.Warnf("sync from archive stopped at height %d, reason: %s", height, err)
+	} else {
+		sync.log.Infof("sync from archive finished at height %d, switch to block sync", height)
+	}
+	sync.resetPendingHeights()
+}
+
+// drainProcessor waits for the block being processed by the processor, the processor skips the blocks
+// queued meanwhile as the archive sync is on, so no block is committed concurrently with the archive sync.
+func (sync *BlockChainSyncServer) drainProcessor() error {
+	barrier := archiveBarrier{done: make(chan struct{})}
+	if err := sync.processor.addTask(barrier); err != nil {
+		return err
+	}
+	select {
+	case <-barrier.done:
+		return nil
+	case <-sync.close:
+		return fmt.Errorf("sync service stopped")
+	}
+}
+
+// syncFromArchive returns the height reached and the reason it stopped before the archived height
+func (sync *BlockChainSyncServer) syncFromArchive() (uint64, error) {
+	height, err := sync.ledgerCache.CurrentHeight()
+	if err != nil {
+		return 0, err
+	}
+	archivedHeight, err := sync.archiveSyncer.source.ArchivedHeight()
+	if err != nil {
+		return height, fmt.Errorf("get archived height failed, %s", err)
+	}
+	if archivedHeight <= height {
+		return height, fmt.Errorf("the archive ends at height %d", archivedHeight)
+	}
+	sync.log.Infof("all peers archived block [%d], sync blocks [%d, %d] from archive",
+		height+1, height+1, archivedHeight)
+	for next := height + 1; next <= archivedHeight; next++ {
+		select {
+		case <-sync.close:
+			return next - 1, fmt.Errorf("sync service stopped")
+		default:
+		}
+		blk, err := sync.archiveSyncer.source.GetBlock(next)
+		if err != nil {
+			return next - 1, fmt.Errorf("get block [%d] from archive failed, %s", next, err)
+		}
+		if blk == nil || blk.Header == nil || blk.Header.BlockHeight != next {
+			return next - 1, fmt.Errorf("archive returns a mismatched block for height %d", next)
+		}
+		if status := sync.validateAndCommitBlock(blk); status != ok && status != hasProcessed {
+			return next - 1, fmt.Errorf("block [%d] from archive is invalid, status [%d]", next, status)
+		}
+	}
+	return archivedHeight, nil
+}
+
+// resetPendingHeights resets the pending heights of the block sync to the last committed block
+func (sync *BlockChainSyncServer) resetPendingHeights() {
+	if err := sync.scheduler.addTask(DataDetection{}); err != nil {
+		sync.log.Errorf("add data detection task to scheduler failed, reason: %s", err)
+	}
+	if err := sync.processor.addTask(DataDetection{}); err != nil {
+		sync.log.Errorf("add data detection task to processor failed, reason: %s", err)
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker/logger/v2"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	storePb "chainmaker.org/chainmaker/pb-go/v2/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFileArchive writes the blocks [1, height] in the layout of the file archive
+func writeFileArchive(t *testing.T, dir string, height uint64) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "chain1", archivedBlockDir), 0755))
+	for i := uint64(1); i <= height; i++ {
+		blkWithRWSet := &storePb.BlockWithRWSet{Block: &commonPb.Block{Header: &commonPb.BlockHeader{BlockHeight: i}}}
+		bz, err := blkWithRWSet.Marshal()
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "chain1", archivedBlockDir,
+			strconv.FormatUint(i, 10)), bz, 0600))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "chain1", archivedHeightObject),
+		[]byte(strconv.FormatUint(height, 10)), 0600))
+}
+
+func TestFileArchiveSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeFileArchive(t, dir, 3)
+
+	source, err := NewArchiveSource("chain1", &ArchiveSourceConfig{Type: ArchiveSourceFile, Path: dir})
+	require.NoError(t, err)
+	height, err := source.ArchivedHeight()
+	require.NoError(t, err)
+	require.EqualValues(t, 3, height)
+	blk, err := source.GetBlock(2)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, blk.Header.BlockHeight)
+	_, err = source.GetBlock(4)
+	require.Error(t, err)
+}
+
+func TestObjectStoreArchiveSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeFileArchive(t, dir, 3)
+
+	// a local stand-in of the s3 bucket serving the file archive
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ak/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.StripPrefix("/bucket", http.FileServer(http.Dir(dir))).ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	source, err := NewArchiveSource("chain1", &ArchiveSourceConfig{
+		Type: ArchiveSourceS3, Endpoint: server.URL, Bucket: "bucket", AccessKey: "ak", SecretKey: "sk",
+	})
+	require.NoError(t, err)
+	defer source.Close()
+	height, err := source.ArchivedHeight()
+	require.NoError(t, err)
+	require.EqualValues(t, 3, height)
+	blk, err := source.GetBlock(3)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, blk.Header.BlockHeight)
+
+	unsigned, err := NewArchiveSource("chain1", &ArchiveSourceConfig{
+		Type: ArchiveSourceS3, Endpoint: server.URL, Bucket: "bucket",
+	})
+	require.NoError(t, err)
+	_, err = unsigned.GetBlock(3)
+	require.Error(t, err)
+}
+
+func TestArchiveSyncer_allPeersArchived(t *testing.T) {
+	syncer := &archiveSyncer{}
+	require.False(t, syncer.allPeersArchived(10, time.Minute))
+
+	syncer.updatePeer("node1", 100)
+	require.True(t, syncer.allPeersArchived(10, time.Minute))
+	syncer.updatePeer("node2", 0)
+	require.False(t, syncer.allPeersArchived(10, time.Minute))
+	// the status of node2 expires
+	syncer.peers["node2"] = peerArchive{updated: time.Now().Add(-time.Hour)}
+	require.True(t, syncer.allPeersArchived(10, time.Minute))
+}
+
+func TestSyncFromArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeFileArchive(t, dir, 5)
+	source, err := NewArchiveSource("chain1", &ArchiveSourceConfig{Type: ArchiveSourceFile, Path: dir})
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLedger := newMockLedgerCache(ctrl, &commonPb.Block{Header: &commonPb.BlockHeader{BlockHeight: 2}})
+	sync := &BlockChainSyncServer{
+		chainId:        "chain1",
+		ledgerCache:    mockLedger,
+		blockVerifier:  newMockVerifier(ctrl),
+		blockCommitter: newMockCommitter(ctrl, mockLedger),
+		conf:           NewBlockSyncServerConf(),
+		close:          make(chan bool),
+		log:            logger.GetLoggerByChain(logger.MODULE_SYNC, "chain1"),
+	}
+	WithArchiveSource(source)(sync)
+
+	height, err := sync.syncFromArchive()
+	require.NoError(t, err)
+	require.EqualValues(t, 5, height)
+	require.EqualValues(t, 5, mockLedger.GetLastCommittedBlock().Header.BlockHeight)
+
+	// nothing more in the archive
+	_, err = sync.syncFromArchive()
+	require.Error(t, err)
+}
+
+func TestArchiveSync_drainAndStop(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLedger := newMockLedgerCache(ctrl, &commonPb.Block{Header: &commonPb.BlockHeader{BlockHeight: 100}})
+	sync := &BlockChainSyncServer{
+		chainId:     "chain1",
+		ledgerCache: mockLedger,
+		close:       make(chan bool),
+		log:         logger.GetLoggerByChain(logger.MODULE_SYNC, "chain1"),
+	}
+	processor := newProcessor(NewMockVerifyAndCommit(mockLedger), mockLedger, sync.log)
+	processor.paused = sync.isArchiveSyncing
+	sync.processor = NewRoutine("processor", processor.handler, processor.getServiceState, sync.log)
+	require.NoError(t, sync.processor.begin())
+	defer sync.processor.end()
+
+	// the blocks queued are not processed during the archive sync
+	_, _ = processor.handler(&ReceivedBlocks{blks: []*commonPb.Block{testBlock(101)}, from: "node1"})
+	atomic.StoreInt32(&sync.archiveSyncing, 1)
+	ret, err := processor.handler(ProcessBlockMsg{})
+	require.NoError(t, err)
+	require.Nil(t, ret)
+	require.Len(t, processor.queue, 1)
+	require.NoError(t, sync.drainProcessor())
+
+	// the source is closed after the archive sync running stops
+	source := &closeRecorder{}
+	WithArchiveSource(source)(sync)
+	sync.archiveSyncer.running.Add(1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		source.syncDone = true
+		sync.archiveSyncer.running.Done()
+	}()
+	sync.stopArchiveSync()
+	require.True(t, source.closedAfterSync)
+	// no archive sync starts once stopped
+	atomic.StoreInt32(&sync.archiveSyncing, 0)
+	sync.startArchiveSync()
+	require.False(t, sync.isArchiveSyncing())
+}
+
+// closeRecorder records whether it is closed after the archive sync done
+type closeRecorder struct {
+	syncDone        bool
+	closedAfterSync bool
+}
+
+func (s *closeRecorder) ArchivedHeight() (uint64, error) { return 0, nil }
+
+func (s *closeRecorder) GetBlock(height uint64) (*commonPb.Block, error) { return nil, nil }
+
+func (s *closeRecorder) Close() error {
+	s.closedAfterSync = s.syncDone
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	netPb "chainmaker.org/chainmaker/pb-go/v2/net"
+	syncPb "chainmaker.org/chainmaker/pb-go/v2/sync"
+)
+
+// servingQueueSize is the number of the msgs served waiting for the bandwidth, the msgs beyond are dropped,
+// and the peers request the blocks again once the requests timeout
+const servingQueueSize = 64
+
+// WithBandwidthLimit caps the bytes per second of the blocks and the state snapshots served to the peers,
+// so that the peers syncing a chain do not occupy all the bandwidth shared with the other chains.
+// The msgs served are paced in a queue, so the handler of the msgs received is never blocked by the limit.
+func WithBandwidthLimit(bytesPerSecond int) Option {
+	return func(sync *BlockChainSyncServer) {
+		sync.bandwidth = newBandwidthLimiter(bytesPerSecond)
+		if sync.bandwidth != nil {
+			sync.servingC = make(chan *servingMsg, servingQueueSize)
+		}
+	}
+}
+
+// servingMsg is a msg served to the peer waiting for the bandwidth
+type servingMsg struct {
+	bs []byte
+	to string
+}
+
+// queueServingMsg queues the msg served to the peer, or sends it directly if the bandwidth is unlimited
+func (sync *BlockChainSyncServer) queueServingMsg(bs []byte, to string) error {
+	if sync.servingC == nil {
+		sync.metrics.addServedBytes(len(bs))
+		return sync.net.SendMsg(bs, netPb.NetMsg_SYNC_BLOCK_MSG, to)
+	}
+	select {
+	case sync.servingC <- &servingMsg{bs: bs, to: to}:
+		return nil
+	default:
+		return fmt.Errorf("the queue of the msgs served is full, drop the msg to %s", to)
+	}
+}
+
+// serveLoop sends the msgs queued at the pace of the bandwidth limit until the server is closed
+func (sync *BlockChainSyncServer) serveLoop() {
+	for {
+		select {
+		case <-sync.close:
+			return
+		case msg := <-sync.servingC:
+			if !sync.bandwidth.wait(len(msg.bs), sync.close) {
+				return
+			}
+			sync.metrics.addServedBytes(len(msg.bs))
+			if err := sync.net.SendMsg(msg.bs, netPb.NetMsg_SYNC_BLOCK_MSG, msg.to); err != nil {
+				sync.log.Error(err)
+			}
+		}
+	}
+}
+
+// isServingMsg returns whether the msg carries the blocks served to a peer
+func isServingMsg(msgType syncPb.SyncMsg_MsgType) bool {
+	switch msgType {
+	case syncPb.SyncMsg_BLOCK_SYNC_RESP, syncMsgCompressedBlockSyncResp:
+		return true
+	default:
+		return false
+	}
+}
+
+// bandwidthLimiter paces the msgs sent, all methods are no-op on nil
+type bandwidthLimiter struct {
+	mtx            sync.Mutex
+	bytesPerSecond float64
+	next           time.Time // the time the next msg could be sent at
+}
+
+func newBandwidthLimiter(bytesPerSecond int) *bandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &bandwidthLimiter{bytesPerSecond: float64(bytesPerSecond)}
+}
+
+// reserve returns the delay before sending the msg of the size
+func (l *bandwidthLimiter) reserve(size int, now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(size) / l.bytesPerSecond * float64(time.Second)))
+	return delay
+}
+
+// wait blocks until the msg of the size could be sent, false if closed before
+func (l *bandwidthLimiter) wait(size int, closeC <-chan bool) bool {
+	delay := l.reserve(size, time.Now())
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-closeC:
+		return false
+	}
+}
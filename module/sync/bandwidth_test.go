@@ -0,0 +1,82 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker/logger/v2"
+	netPb "chainmaker.org/chainmaker/pb-go/v2/net"
+	syncPb "chainmaker.org/chainmaker/pb-go/v2/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthLimiter(t *testing.T) {
+	// unlimited
+	var unlimited *bandwidthLimiter
+	require.Nil(t, newBandwidthLimiter(0))
+	require.Equal(t, time.Duration(0), unlimited.reserve(1<<20, time.Now()))
+
+	// 1 KB per second
+	limiter := newBandwidthLimiter(1024)
+	now := time.Now()
+	require.Equal(t, time.Duration(0), limiter.reserve(512, now))
+	require.Equal(t, 500*time.Millisecond, limiter.reserve(1024, now))
+	require.Equal(t, 1500*time.Millisecond, limiter.reserve(1, now))
+
+	// the idle time is not accumulated
+	later := now.Add(time.Minute)
+	require.Equal(t, time.Duration(0), limiter.reserve(1024, later))
+	require.Equal(t, time.Second, limiter.reserve(1024, later))
+
+	require.True(t, isServingMsg(syncPb.SyncMsg_BLOCK_SYNC_RESP))
+	require.True(t, isServingMsg(syncMsgCompressedBlockSyncResp))
+	require.False(t, isServingMsg(syncPb.SyncMsg_BLOCK_SYNC_REQ))
+}
+
+// chanNet passes the msgs sent to the channel
+type chanNet struct {
+	MockNet
+	sent chan string
+}
+
+func (n *chanNet) SendMsg(msg []byte, msgType netPb.NetMsg_MsgType, to ...string) error {
+	n.sent <- to[0]
+	return nil
+}
+
+func TestServingQueue(t *testing.T) {
+	net := &chanNet{sent: make(chan string, servingQueueSize)}
+	sync := &BlockChainSyncServer{
+		chainId: "chain1",
+		net:     net,
+		close:   make(chan bool),
+		log:     logger.GetLoggerByChain(logger.MODULE_SYNC, "chain1"),
+	}
+	WithBandwidthLimit(1024)(sync)
+
+	// the msgs served are queued without waiting for the bandwidth, those beyond the queue are dropped
+	start := time.Now()
+	for i := 0; i < servingQueueSize; i++ {
+		require.Nil(t, sync.sendMsg(syncPb.SyncMsg_BLOCK_SYNC_RESP, make([]byte, 1024), "node2"))
+	}
+	require.NotNil(t, sync.sendMsg(syncPb.SyncMsg_BLOCK_SYNC_RESP, make([]byte, 1024), "node2"))
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+	// the other msgs are not queued
+	require.Nil(t, sync.sendMsg(syncPb.SyncMsg_NODE_STATUS_RESP, nil, "node3"))
+	require.Equal(t, "node3", <-net.sent)
+
+	go sync.serveLoop()
+	select {
+	case to := <-net.sent:
+		require.Equal(t, "node2", to)
+	case <-time.After(time.Second):
+		t.Fatal("the msg queued is not sent")
+	}
+	close(sync.close)
+}
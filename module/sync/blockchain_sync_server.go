@@ -27,6 +27,9 @@ import (
 var _ protocol.SyncService = (*BlockChainSyncServer)(nil)
 
 type BlockChainSyncServer struct {
+	committedBlocks uint64 // The number of blocks committed by the sync, accessed atomically
+	blocksPerSecond uint64 // The float64 bits of the committing rate, accessed atomically
+
 	chainId string
 
 	net             protocol.NetService      // receive/broadcast messages from net module
@@ -43,15 +46,32 @@ type BlockChainSyncServer struct {
 
 	scheduler *Routine // Service that get blocks from other nodes
 	processor *Routine // Service that processes block data, adding valid blocks to the chain
+
+	extConf *ExtConfig // The config of the sync features not in localconf.SyncConfig
+
+	archiveSyncer  *archiveSyncer // Syncs the blocks archived by all peers, disabled when nil
+	archiveSyncing int32          // Identification of syncing from the archive
+
+	paused  int32 // Identification of the sync paused by the admin
+	metrics *syncMetrics
+
+	bandwidth *bandwidthLimiter // Paces the blocks served to the peers, unlimited when nil
+	servingC  chan *servingMsg  // The msgs served waiting for the bandwidth, nil when unlimited
+
+	resetTickersC chan *BlockSyncServerConf // The tickers of the local config reloaded
 }
 
+// Option configures the BlockChainSyncServer
+type Option func(sync *BlockChainSyncServer)
+
 func NewBlockChainSyncServer(chainId string,
 	net protocol.NetService,
 	msgBus msgbus.MessageBus,
 	blockchainStore protocol.BlockchainStore,
 	ledgerCache protocol.LedgerCache,
 	blockVerifier protocol.BlockVerifier,
-	blockCommitter protocol.BlockCommitter) protocol.SyncService {
+	blockCommitter protocol.BlockCommitter,
+	opts ...Option) protocol.SyncService {
 
 	syncServer := &BlockChainSyncServer{
 		chainId:         chainId,
@@ -62,8 +82,12 @@ func NewBlockChainSyncServer(chainId string,
 		blockVerifier:   blockVerifier,
 		blockCommitter:  blockCommitter,
 		close:           make(chan bool),
+		resetTickersC:   make(chan *BlockSyncServerConf, 1),
 		log:             logger.GetLoggerByChain(logger.MODULE_SYNC, chainId),
 	}
+	for _, opt := range opts {
+		opt(syncServer)
+	}
 	return syncServer
 }
 
@@ -75,11 +99,18 @@ func (sync *BlockChainSyncServer) Start() error {
 	// 1. init conf
 	sync.initSyncConfIfRequire()
 	processor := newProcessor(sync, sync.ledgerCache, sync.log)
+	processor.paused = sync.isArchiveSyncing
 	scheduler := newScheduler(sync, sync.ledgerCache,
 		sync.conf.blockPoolSize, sync.conf.timeOut, sync.conf.reqTimeThreshold, sync.conf.batchSizeFromOneNode, sync.log)
 	if scheduler == nil {
 		return fmt.Errorf("init scheduler failed")
 	}
+	scheduler.compressBlocks = sync.conf.compressBlocks
+	scheduler.maxPeerPenalty = sync.conf.maxPeerPenalty
+	scheduler.peerBanTime = sync.conf.peerBanTime
+	sync.metrics = newSyncMetrics(sync.chainId)
+	scheduler.metrics = sync.metrics
+	scheduler.reporter, _ = sync.net.(peerReporter)
 	sync.scheduler = NewRoutine("scheduler", scheduler.handler, scheduler.getServiceState, sync.log)
 	sync.processor = NewRoutine("processor", processor.handler, processor.getServiceState, sync.log)
 
@@ -101,6 +132,9 @@ func (sync *BlockChainSyncServer) Start() error {
 	if err := sync.processor.begin(); err != nil {
 		return err
 	}
+	if sync.servingC != nil {
+		go sync.serveLoop()
+	}
 	go sync.loop()
 	return nil
 }
@@ -122,26 +156,26 @@ func (sync *BlockChainSyncServer) initSyncConfIfRequire() {
 	if localconf.ChainMakerConfig.SyncConfig.BatchSizeFromOneNode > 0 {
 		sync.conf.SetBatchSizeFromOneNode(uint64(localconf.ChainMakerConfig.SyncConfig.BatchSizeFromOneNode))
 	}
-	if localconf.ChainMakerConfig.SyncConfig.LivenessTick > 0 {
-		sync.conf.SetLivenessTicker(localconf.ChainMakerConfig.SyncConfig.LivenessTick)
-	}
-	if localconf.ChainMakerConfig.SyncConfig.NodeStatusTick > 0 {
-		sync.conf.SetNodeStatusTicker(localconf.ChainMakerConfig.SyncConfig.NodeStatusTick)
-	}
-	if localconf.ChainMakerConfig.SyncConfig.DataDetectionTick > 0 {
-		sync.conf.SetDataDetectionTicker(localconf.ChainMakerConfig.SyncConfig.DataDetectionTick)
-	}
-	if localconf.ChainMakerConfig.SyncConfig.ProcessBlockTick > 0 {
-		sync.conf.SetProcessBlockTicker(localconf.ChainMakerConfig.SyncConfig.ProcessBlockTick)
-	}
-	if localconf.ChainMakerConfig.SyncConfig.SchedulerTick > 0 {
-		sync.conf.SetSchedulerTicker(localconf.ChainMakerConfig.SyncConfig.SchedulerTick)
-	}
+	sync.conf.loadTickers(localconf.ChainMakerConfig).loadExtConfig(sync.extConf)
 	if localconf.ChainMakerConfig.SyncConfig.ReqTimeThreshold > 0 {
 		sync.conf.SetReqTimeThreshold(localconf.ChainMakerConfig.SyncConfig.ReqTimeThreshold)
 	}
 }
 
+// ResetTickers applies the tickers in the sync config of the local config reloaded,
+// the ones not configured are reset to the defaults.
+func (sync *BlockChainSyncServer) ResetTickers(config *localconf.CMConfig) {
+	conf := NewBlockSyncServerConf().loadTickers(config)
+	for {
+		select {
+		case sync.resetTickersC <- conf:
+			return
+		case <-sync.resetTickersC:
+			// drop the tickers not applied yet
+		}
+	}
+}
+
 func (sync *BlockChainSyncServer) blockSyncMsgHandler(from string, msg []byte, msgType netPb.NetMsg_MsgType) error {
 	if atomic.LoadInt32(&sync.start) != 1 {
 		return commonErrors.ErrSyncServiceHasStoped
@@ -164,10 +198,17 @@ func (sync *BlockChainSyncServer) blockSyncMsgHandler(from string, msg []byte, m
 		return sync.handleNodeStatusReq(from)
 	case syncPb.SyncMsg_NODE_STATUS_RESP:
 		return sync.handleNodeStatusResp(&syncMsg, from)
-	case syncPb.SyncMsg_BLOCK_SYNC_REQ:
+	case syncPb.SyncMsg_BLOCK_SYNC_REQ, syncMsgCompressedBlockSyncReq:
 		return sync.handleBlockReq(&syncMsg, from)
 	case syncPb.SyncMsg_BLOCK_SYNC_RESP:
 		return sync.scheduler.addTask(&SyncedBlockMsg{msg: syncMsg.Payload, from: from})
+	case syncMsgCompressedBlockSyncResp:
+		payload, err := decompress(syncMsg.Payload)
+		if err != nil {
+			sync.log.Warnf("decompress blocks from node [%s] failed, reason: %s", from, err)
+			return err
+		}
+		return sync.scheduler.addTask(&SyncedBlockMsg{msg: payload, from: from, compressed: true})
 	}
 	return fmt.Errorf("not support the syncPb.SyncMsg.Type as %d", syncMsg.Type)
 }
@@ -196,6 +237,9 @@ func (sync *BlockChainSyncServer) handleNodeStatusResp(syncMsg *syncPb.SyncMsg,
 	}
 	sync.log.Debugf("receive node[%s] status, height [%d], archived height [%d]", from, msg.BlockHeight,
 		msg.ArchivedHeight)
+	if sync.archiveSyncer != nil {
+		sync.archiveSyncer.updatePeer(from, msg.ArchivedHeight)
+	}
 	return sync.scheduler.addTask(NodeStatusMsg{msg: msg, from: from})
 }
 
@@ -208,60 +252,53 @@ func (sync *BlockChainSyncServer) handleBlockReq(syncMsg *syncPb.SyncMsg, from s
 		sync.log.Errorf("fail to proto.Unmarshal the syncPb.SyncMsg:%s", err.Error())
 		return err
 	}
-	sync.log.Debugf("receive request to get block [height: %d, batch_size: %d] from "+
-		"node [%s]", req.BlockHeight, req.BatchSize, from)
+	sync.log.Debugf("receive request to get block [height: %d, batch_size: %d, compressed: %v] from "+
+		"node [%s]", req.BlockHeight, req.BatchSize, syncMsg.Type == syncMsgCompressedBlockSyncReq, from)
+	batcher := newBlockBatcher(sync, from, sync.conf.maxBatchBytes, syncMsg.Type == syncMsgCompressedBlockSyncReq)
 	if req.WithRwset {
-		return sync.sendInfos(&req, from)
+		return sync.sendInfos(&req, batcher)
 	}
-	return sync.sendBlocks(&req, from)
+	return sync.sendBlocks(&req, batcher)
 }
 
-func (sync *BlockChainSyncServer) sendBlocks(req *syncPb.BlockSyncReq, from string) error {
+func (sync *BlockChainSyncServer) sendBlocks(req *syncPb.BlockSyncReq, batcher *blockBatcher) error {
 	var (
-		bz  []byte
 		err error
 		blk *commonPb.Block
 	)
 
 	for i := uint64(0); i < req.BatchSize; i++ {
 		if blk, err = sync.blockChainStore.GetBlock(req.BlockHeight + i); err != nil || blk == nil {
-			return err
+			break
 		}
-		if bz, err = proto.Marshal(&syncPb.SyncBlockBatch{
-			Data: &syncPb.SyncBlockBatch_BlockBatch{BlockBatch: &syncPb.BlockBatch{Batches: []*commonPb.Block{blk}}},
-		}); err != nil {
-			return err
-		}
-		if err := sync.sendMsg(syncPb.SyncMsg_BLOCK_SYNC_RESP, bz, from); err != nil {
+		if err = batcher.addBlock(blk); err != nil {
 			return err
 		}
 	}
-	return nil
+	if flushErr := batcher.flush(); flushErr != nil {
+		return flushErr
+	}
+	return err
 }
 
-func (sync *BlockChainSyncServer) sendInfos(req *syncPb.BlockSyncReq, from string) error {
+func (sync *BlockChainSyncServer) sendInfos(req *syncPb.BlockSyncReq, batcher *blockBatcher) error {
 	var (
-		bz        []byte
 		err       error
 		blkRwInfo *storePb.BlockWithRWSet
 	)
 
 	for i := uint64(0); i < req.BatchSize; i++ {
 		if blkRwInfo, err = sync.blockChainStore.GetBlockWithRWSets(req.BlockHeight + i); err != nil || blkRwInfo == nil {
-			return err
+			break
 		}
-		info := &commonPb.BlockInfo{Block: blkRwInfo.Block, RwsetList: blkRwInfo.TxRWSets}
-		if bz, err = proto.Marshal(&syncPb.SyncBlockBatch{
-			Data: &syncPb.SyncBlockBatch_BlockinfoBatch{BlockinfoBatch: &syncPb.BlockInfoBatch{
-				Batch: []*commonPb.BlockInfo{info}}},
-		}); err != nil {
-			return err
-		}
-		if err := sync.sendMsg(syncPb.SyncMsg_BLOCK_SYNC_RESP, bz, from); err != nil {
+		if err = batcher.addInfo(&commonPb.BlockInfo{Block: blkRwInfo.Block, RwsetList: blkRwInfo.TxRWSets}); err != nil {
 			return err
 		}
 	}
-	return nil
+	if flushErr := batcher.flush(); flushErr != nil {
+		return flushErr
+	}
+	return err
 }
 
 func (sync *BlockChainSyncServer) sendMsg(msgType syncPb.SyncMsg_MsgType, msg []byte, to string) error {
@@ -276,7 +313,12 @@ func (sync *BlockChainSyncServer) sendMsg(msgType syncPb.SyncMsg_MsgType, msg []
 		sync.log.Error(err)
 		return err
 	}
-	if err = sync.net.SendMsg(bs, netPb.NetMsg_SYNC_BLOCK_MSG, to); err != nil {
+	if isServingMsg(msgType) {
+		err = sync.queueServingMsg(bs, to)
+	} else {
+		err = sync.net.SendMsg(bs, netPb.NetMsg_SYNC_BLOCK_MSG, to)
+	}
+	if err != nil {
 		sync.log.Error(err)
 		return err
 	}
@@ -314,6 +356,9 @@ func (sync *BlockChainSyncServer) loop() {
 		doLivenessTk = time.NewTicker(sync.conf.livenessTick)
 		// task: trigger the check of the data in processor and scheduler
 		doDataDetect = time.NewTicker(sync.conf.dataDetectionTick)
+
+		lastCommitted = atomic.LoadUint64(&sync.committedBlocks)
+		lastTick      = time.Now()
 	)
 	defer func() {
 		doProcessBlockTk.Stop()
@@ -330,14 +375,27 @@ func (sync *BlockChainSyncServer) loop() {
 
 			// Timing task
 		case <-doProcessBlockTk.C:
+			if sync.isPaused() || sync.isArchiveSyncing() {
+				continue
+			}
 			if err := sync.processor.addTask(ProcessBlockMsg{}); err != nil {
 				sync.log.Errorf("add process block task to processor failed, reason: %s", err)
 			}
 		case <-doScheduleTk.C:
+			if sync.isPaused() {
+				continue
+			}
+			if sync.needArchiveSync() {
+				sync.startArchiveSync()
+			}
+			if sync.isArchiveSyncing() {
+				continue
+			}
 			if err := sync.scheduler.addTask(SchedulerMsg{}); err != nil {
 				sync.log.Errorf("add scheduler task to scheduler failed, reason: %s", err)
 			}
 		case <-doLivenessTk.C:
+			lastCommitted, lastTick = sync.updateBlocksPerSecond(lastCommitted, lastTick)
 			if err := sync.scheduler.addTask(LivenessMsg{}); err != nil {
 				sync.log.Errorf("add livenessMsg task to scheduler failed, reason: %s", err)
 			}
@@ -346,6 +404,15 @@ func (sync *BlockChainSyncServer) loop() {
 			if err := sync.broadcastMsg(syncPb.SyncMsg_NODE_STATUS_REQ, nil); err != nil {
 				sync.log.Errorf("request node status failed by broadcast", err)
 			}
+		case conf := <-sync.resetTickersC:
+			doProcessBlockTk.Reset(conf.processBlockTick)
+			doScheduleTk.Reset(conf.schedulerTick)
+			doNodeStatusTk.Reset(conf.nodeStatusTick)
+			doLivenessTk.Reset(conf.livenessTick)
+			doDataDetect.Reset(conf.dataDetectionTick)
+			sync.log.Infof("reset tickers, processBlockTick: %v, schedulerTick: %v, nodeStatusTick: %v, "+
+				"livenessTick: %v, dataDetectionTick: %v", conf.processBlockTick, conf.schedulerTick,
+				conf.nodeStatusTick, conf.livenessTick, conf.dataDetectionTick)
 		case <-doDataDetect.C:
 			if err := sync.processor.addTask(DataDetection{}); err != nil {
 				sync.log.Errorf("add data detection task to processor failed, reason: %s", err)
@@ -388,6 +455,8 @@ func (sync *BlockChainSyncServer) validateAndCommitBlock(block *commonPb.Block)
 		sync.log.Warnf("fail to commit the block whose height is %d, err: %s", block.Header.BlockHeight, err)
 		return addErr
 	}
+	atomic.AddUint64(&sync.committedBlocks, 1)
+	sync.metrics.incSyncedBlocks()
 	return ok
 }
 
@@ -398,6 +467,9 @@ func (sync *BlockChainSyncServer) Stop() {
 	sync.scheduler.end()
 	sync.processor.end()
 	close(sync.close)
+	if sync.archiveSyncer != nil {
+		sync.stopArchiveSync()
+	}
 }
 
 func (sync *BlockChainSyncServer) OnMessage(message *msgbus.Message) {
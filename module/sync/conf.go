@@ -10,6 +10,8 @@ package sync
 import (
 	"fmt"
 	"time"
+
+	"chainmaker.org/chainmaker/localconf/v2"
 )
 
 type BlockSyncServerConf struct {
@@ -25,6 +27,28 @@ type BlockSyncServerConf struct {
 	blockPoolSize        uint64 // Maximum number of blocks to be processed in scheduler
 	batchSizeFromOneNode uint64 // The number of blocks received from each node in a request
 
+	maxBatchBytes  int           // The maximum size of the blocks sent in one msg
+	compressBlocks bool          // Whether to request the blocks compressed
+	maxPeerPenalty int           // The peer is evicted when its penalty reaches it
+	peerBanTime    time.Duration // The time to ignore an evicted peer
+
+	archiveSyncInterval time.Duration // The minimum interval between the attempts to sync from the archive
+}
+
+// ExtConfig is the config of the sync features not in localconf.SyncConfig, it is read from the
+// sync section of the config file, the zero values keep the defaults.
+type ExtConfig struct {
+	CompressBlocks *bool   `mapstructure:"compress_blocks"`
+	MaxBatchBytes  int     `mapstructure:"max_batch_bytes"`
+	MaxPeerPenalty int     `mapstructure:"max_peer_penalty"`
+	PeerBanTime    float64 `mapstructure:"peer_ban_time"`
+}
+
+// WithExtConfig applies the config of the sync features not in localconf.SyncConfig
+func WithExtConfig(config *ExtConfig) Option {
+	return func(sync *BlockChainSyncServer) {
+		sync.extConf = config
+	}
 }
 
 func NewBlockSyncServerConf() *BlockSyncServerConf {
@@ -38,9 +62,54 @@ func NewBlockSyncServerConf() *BlockSyncServerConf {
 		schedulerTick:        20 * time.Millisecond,
 		dataDetectionTick:    time.Minute,
 		reqTimeThreshold:     3 * time.Second,
+		maxBatchBytes:        4 * 1024 * 1024,
+		maxPeerPenalty:       5,
+		peerBanTime:          time.Minute,
+		archiveSyncInterval:  30 * time.Second,
 	}
 }
 
+// loadExtConfig sets the configured features of the ext config, the others are unchanged
+func (c *BlockSyncServerConf) loadExtConfig(config *ExtConfig) *BlockSyncServerConf {
+	if config == nil {
+		return c
+	}
+	if config.CompressBlocks != nil {
+		c.SetCompressBlocks(*config.CompressBlocks)
+	}
+	if config.MaxBatchBytes > 0 {
+		c.SetMaxBatchBytes(config.MaxBatchBytes)
+	}
+	if config.MaxPeerPenalty > 0 {
+		c.SetMaxPeerPenalty(config.MaxPeerPenalty)
+	}
+	if config.PeerBanTime > 0 {
+		c.SetPeerBanTime(config.PeerBanTime)
+	}
+	return c
+}
+
+// loadTickers sets the tickers configured in the sync config of the local config, the others are unchanged
+func (c *BlockSyncServerConf) loadTickers(config *localconf.CMConfig) *BlockSyncServerConf {
+	syncConfig := config.SyncConfig
+	if syncConfig.LivenessTick > 0 {
+		c.SetLivenessTicker(syncConfig.LivenessTick)
+	}
+	if syncConfig.NodeStatusTick > 0 {
+		c.SetNodeStatusTicker(syncConfig.NodeStatusTick)
+	}
+	if syncConfig.DataDetectionTick > 0 {
+		c.SetDataDetectionTicker(syncConfig.DataDetectionTick)
+	}
+	if syncConfig.ProcessBlockTick > 0 {
+		c.SetProcessBlockTicker(syncConfig.ProcessBlockTick)
+	}
+	if syncConfig.SchedulerTick > 0 {
+		c.SetSchedulerTicker(syncConfig.SchedulerTick)
+	}
+	return c
+}
+
 func (c *BlockSyncServerConf) SetBlockPoolSize(n uint64) *BlockSyncServerConf {
 	c.blockPoolSize = n
 	return c
@@ -77,9 +146,30 @@ func (c *BlockSyncServerConf) SetReqTimeThreshold(n float64) *BlockSyncServerCon
 	c.reqTimeThreshold = time.Duration(n * float64(time.Second))
 	return c
 }
+func (c *BlockSyncServerConf) SetMaxBatchBytes(n int) *BlockSyncServerConf {
+	c.maxBatchBytes = n
+	return c
+}
+func (c *BlockSyncServerConf) SetCompressBlocks(enable bool) *BlockSyncServerConf {
+	c.compressBlocks = enable
+	return c
+}
+func (c *BlockSyncServerConf) SetMaxPeerPenalty(n int) *BlockSyncServerConf {
+	c.maxPeerPenalty = n
+	return c
+}
+func (c *BlockSyncServerConf) SetPeerBanTime(n float64) *BlockSyncServerConf {
+	c.peerBanTime = time.Duration(n * float64(time.Second))
+	return c
+}
+func (c *BlockSyncServerConf) SetArchiveSyncInterval(n float64) *BlockSyncServerConf {
+	c.archiveSyncInterval = time.Duration(n * float64(time.Second))
+	return c
+}
 func (c *BlockSyncServerConf) print() string {
 	return fmt.Sprintf("blockPoolSize: %d, request timeout: %d, batchSizeFromOneNode: %d"+
-		", processBlockTick: %v, schedulerTick: %v, livenessTick: %v, nodeStatusTick: %v\n",
+		", processBlockTick: %v, schedulerTick: %v, livenessTick: %v, nodeStatusTick: %v"+
+		", maxBatchBytes: %d, compressBlocks: %v\n",
 		c.blockPoolSize, c.timeOut, c.batchSizeFromOneNode, c.processBlockTick, c.schedulerTick, c.livenessTick,
-		c.nodeStatusTick)
+		c.nodeStatusTick, c.maxBatchBytes, c.compressBlocks)
 }
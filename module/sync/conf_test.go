@@ -0,0 +1,36 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockSyncServerConf_loadExtConfig(t *testing.T) {
+	conf := NewBlockSyncServerConf().loadExtConfig(nil)
+	require.Equal(t, NewBlockSyncServerConf(), conf)
+	require.EqualValues(t, 1, conf.batchSizeFromOneNode)
+
+	compress := true
+	conf.loadExtConfig(&ExtConfig{
+		CompressBlocks: &compress,
+		MaxBatchBytes:  1024,
+		PeerBanTime:    1.5,
+	})
+	require.True(t, conf.compressBlocks)
+	require.Equal(t, 1024, conf.maxBatchBytes)
+	require.Equal(t, 1500*time.Millisecond, conf.peerBanTime)
+	// the features not configured keep the defaults
+	require.Equal(t, 5, conf.maxPeerPenalty)
+
+	conf.loadExtConfig(&ExtConfig{MaxPeerPenalty: 3})
+	require.Equal(t, 3, conf.maxPeerPenalty)
+	require.Equal(t, 1024, conf.maxBatchBytes)
+}
@@ -21,8 +21,9 @@ func (e EqualLevel) Compare(other queue.Item) int {
 
 type SyncedBlockMsg struct {
 	EqualLevel
-	msg  []byte
-	from string
+	msg        []byte
+	from       string
+	compressed bool // Whether the blocks are served in a compressed response
 }
 
 type NodeStatusMsg struct {
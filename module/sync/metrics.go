@@ -0,0 +1,117 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import (
+	"sync"
+	"time"
+
+	"chainmaker.org/chainmaker/localconf/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricNamespace = "chainmaker"
+	metricSubsystem = "sync"
+
+	// the reasons of the peer failures
+	failureTimeout      = "timeout"
+	failureInvalidBlock = "invalid_block"
+)
+
+var (
+	metricsOnce sync.Once
+
+	metricSyncLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricNamespace, Subsystem: metricSubsystem, Name: "lag_blocks",
+		Help: "The number of blocks the node is behind the highest peer",
+	}, []string{"chainId"})
+	metricPendingBlocks = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricNamespace, Subsystem: metricSubsystem, Name: "pending_blocks",
+		Help: "The number of blocks in the pending pool of the scheduler",
+	}, []string{"chainId"})
+	metricBlocksPerSecond = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricNamespace, Subsystem: metricSubsystem, Name: "blocks_per_second",
+		Help: "The number of blocks committed by the sync per second",
+	}, []string{"chainId"})
+	metricSyncedBlocks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricNamespace, Subsystem: metricSubsystem, Name: "synced_blocks_total",
+		Help: "The number of blocks committed by the sync",
+	}, []string{"chainId"})
+	metricPeerLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricNamespace, Subsystem: metricSubsystem, Name: "peer_request_latency_seconds",
+		Help:    "The latency of the block requests to the peer",
+		Buckets: []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10},
+	}, []string{"chainId", "peer"})
+	metricPeerFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricNamespace, Subsystem: metricSubsystem, Name: "peer_failures_total",
+		Help: "The number of the failed block requests to the peer",
+	}, []string{"chainId", "peer", "reason"})
+	metricServedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricNamespace, Subsystem: metricSubsystem, Name: "served_bytes_total",
+		Help: "The bytes of the blocks and the state snapshots served to the peers",
+	}, []string{"chainId"})
+)
+
+// syncMetrics reports the metrics of the sync on a chain, all methods are no-op on nil
+type syncMetrics struct {
+	chainId string
+}
+
+// newSyncMetrics returns nil when the monitor is disabled
+func newSyncMetrics(chainId string) *syncMetrics {
+	if !localconf.ChainMakerConfig.MonitorConfig.Enabled {
+		return nil
+	}
+	metricsOnce.Do(func() {
+		prometheus.MustRegister(metricSyncLag, metricPendingBlocks, metricBlocksPerSecond, metricSyncedBlocks,
+			metricPeerLatency, metricPeerFailures, metricServedBytes)
+	})
+	return &syncMetrics{chainId: chainId}
+}
+
+func (m *syncMetrics) setLag(lag uint64) {
+	if m != nil {
+		metricSyncLag.WithLabelValues(m.chainId).Set(float64(lag))
+	}
+}
+
+func (m *syncMetrics) setPendingBlocks(n int) {
+	if m != nil {
+		metricPendingBlocks.WithLabelValues(m.chainId).Set(float64(n))
+	}
+}
+
+func (m *syncMetrics) setBlocksPerSecond(bps float64) {
+	if m != nil {
+		metricBlocksPerSecond.WithLabelValues(m.chainId).Set(bps)
+	}
+}
+
+func (m *syncMetrics) incSyncedBlocks() {
+	if m != nil {
+		metricSyncedBlocks.WithLabelValues(m.chainId).Inc()
+	}
+}
+
+func (m *syncMetrics) observePeerLatency(peer string, latency time.Duration) {
+	if m != nil {
+		metricPeerLatency.WithLabelValues(m.chainId, peer).Observe(latency.Seconds())
+	}
+}
+
+func (m *syncMetrics) incPeerFailure(peer, reason string) {
+	if m != nil {
+		metricPeerFailures.WithLabelValues(m.chainId, peer, reason).Inc()
+	}
+}
+
+func (m *syncMetrics) addServedBytes(n int) {
+	if m != nil {
+		metricServedBytes.WithLabelValues(m.chainId).Add(float64(n))
+	}
+}
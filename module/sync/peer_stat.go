@@ -0,0 +1,57 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import "time"
+
+const (
+	// statSmoothing is the weight of a new sample in the moving averages of peerStat
+	statSmoothing = 0.3
+	// timeoutPenalty is the penalty of a peer for a timed out request
+	timeoutPenalty = 1
+)
+
+// peerStat is the measured service quality of a peer in block sync
+type peerStat struct {
+	latency    time.Duration // Moving average of the latency of the responses
+	throughput float64       // Moving average of the blocks received per second
+	penalty    int           // Increased by the time outs and invalid blocks, decreased by the valid responses
+}
+
+// observe records a response of blocks received latency after the request
+func (s *peerStat) observe(blocks int, latency time.Duration) {
+	if latency <= 0 {
+		latency = time.Millisecond
+	}
+	throughput := float64(blocks) / latency.Seconds()
+	if s.latency == 0 {
+		s.latency, s.throughput = latency, throughput
+	} else {
+		s.latency = time.Duration(statSmoothing*float64(latency) + (1-statSmoothing)*float64(s.latency))
+		s.throughput = statSmoothing*throughput + (1-statSmoothing)*s.throughput
+	}
+	if s.penalty > 0 {
+		s.penalty--
+	}
+}
+
+// estimate returns the expected time for the peer to serve a request of batch
+// blocks after the pending ones, defaultLatency is used before any measurement.
+func (s *peerStat) estimate(pendingBlocks int, batch uint64, defaultLatency time.Duration) time.Duration {
+	latency, throughput := s.latency, s.throughput
+	if latency == 0 {
+		latency = defaultLatency
+	}
+	if latency <= 0 {
+		latency = time.Millisecond
+	}
+	if throughput <= 0 {
+		throughput = float64(batch) / latency.Seconds()
+	}
+	cost := float64(latency) + float64(pendingBlocks+int(batch))/throughput*float64(time.Second)
+	return time.Duration(cost * float64(1+s.penalty))
+}
@@ -34,6 +34,7 @@ type processor struct {
 
 	log         *logger.CMLogger
 	ledgerCache protocol.LedgerCache // Provides the latest chain state for the node
+	paused      func() bool          // The blocks are not processed while it returns true, nil if never paused
 	verifyAndAddBlock
 }
 
@@ -51,9 +52,16 @@ func (pro *processor) handler(event queue.Item) (queue.Item, error) {
 	case *ReceivedBlocks:
 		pro.handleReceivedBlocks(msg)
 	case ProcessBlockMsg:
+		if pro.paused != nil && pro.paused() {
+			return nil, nil
+		}
 		return pro.handleProcessBlockMsg()
 	case DataDetection:
 		pro.handleDataDetection()
+	case resyncMsg:
+		pro.handleResync(msg)
+	case archiveBarrier:
+		close(msg.done)
 	}
 	return nil, nil
 }
@@ -25,6 +25,11 @@ type syncSender interface {
 	sendMsg(msgType syncPb.SyncMsg_MsgType, msg []byte, to string) error
 }
 
+// peerReporter is implemented by the net service scoring the peers by their misbehaviors
+type peerReporter interface {
+	ReportPeer(peer string, misbehavior string)
+}
+
 // scheduler Retrieves block data of specified height from different nodes
 type scheduler struct {
 	peers             map[string]uint64     // The state of the connected nodes
@@ -42,6 +47,18 @@ type scheduler struct {
 	reqTimeThreshold    time.Duration // When the difference between the height of the node and
 	// the latest height of peers is 1, the time interval for requesting
 
+	peerStats      map[string]*peerStat // The measured service quality of the peers
+	bannedPeers    map[string]time.Time // The evicted peers are ignored until the time
+	compressBlocks bool                 // Whether to request the blocks compressed
+	// Whether the peers serve the compressed requests, unknown until a compressed batch is received from the
+	// peer or a compressed request to it times out, the peers not serving them are requested uncompressed
+	compressedPeers map[string]bool
+	maxPeerPenalty  int             // The peer is evicted when its penalty reaches it
+	peerBanTime     time.Duration   // The time to ignore an evicted peer
+	pinnedPeers     map[string]bool // Blocks are requested from the pinned peers only if any
+	metrics         *syncMetrics
+	reporter        peerReporter // Reports the misbehaving peers to the net, nil if not supported
+
 	log    *logger.CMLogger
 	sender syncSender
 	ledger protocol.LedgerCache
@@ -70,6 +87,12 @@ func newScheduler(sender syncSender, ledger protocol.LedgerCache,
 		pendingTime:       make(map[uint64]time.Time),
 		receivedBlocks:    make(map[uint64]string),
 		pendingRecvHeight: currHeight + 1,
+
+		peerStats:       make(map[string]*peerStat),
+		bannedPeers:     make(map[string]time.Time),
+		compressedPeers: make(map[string]bool),
+		maxPeerPenalty:  5,
+		peerBanTime:     time.Minute,
 	}
 }
 
@@ -87,11 +110,23 @@ func (sch *scheduler) handler(event queue.Item) (queue.Item, error) {
 		return sch.handleProcessedBlockResp(msg)
 	case DataDetection:
 		sch.handleDataDetection()
+	case statusQuery:
+		sch.handleStatusQuery(msg)
+	case pinPeersMsg:
+		sch.handlePinPeers(msg)
+	case resyncMsg:
+		sch.handleResync(msg)
 	}
 	return nil, nil
 }
 
 func (sch *scheduler) handleNodeStatus(msg NodeStatusMsg) {
+	if until, banned := sch.bannedPeers[msg.from]; banned {
+		if time.Now().Before(until) {
+			return
+		}
+		delete(sch.bannedPeers, msg.from)
+	}
 	localCurrBlk := sch.ledger.GetLastCommittedBlock()
 	if old, exist := sch.peers[msg.from]; exist {
 		if old > msg.msg.BlockHeight || sch.isPeerArchivedTooHeight(localCurrBlk.Header.BlockHeight,
@@ -142,24 +177,38 @@ func (sch *scheduler) handleDataDetection() {
 }
 
 func (sch *scheduler) handleLivinessMsg() {
-	reqTime, exist := sch.pendingTime[sch.pendingRecvHeight]
-	if exist && time.Since(reqTime) > sch.peerReqTimeout {
-		id := sch.pendingBlocks[sch.pendingRecvHeight]
-		sch.log.Debugf("block request [height: %d] time out from node[%s]", sch.pendingRecvHeight, id)
-		if currBlk := sch.ledger.GetLastCommittedBlock(); currBlk != nil &&
-			currBlk.Header.BlockHeight < sch.pendingRecvHeight {
-			sch.blockStates[sch.pendingRecvHeight] = newBlock
+	timeoutPeers := make(map[string]bool)
+	currBlk := sch.ledger.GetLastCommittedBlock()
+	for height, reqTime := range sch.pendingTime {
+		if time.Since(reqTime) <= sch.peerReqTimeout {
+			continue
+		}
+		id := sch.pendingBlocks[height]
+		sch.log.Debugf("block request [height: %d] time out from node[%s]", height, id)
+		if currBlk != nil && currBlk.Header.BlockHeight < height {
+			sch.blockStates[height] = newBlock
+		}
+		delete(sch.pendingTime, height)
+		delete(sch.pendingBlocks, height)
+		if id != "" {
+			timeoutPeers[id] = true
+		}
+	}
+	for id := range timeoutPeers {
+		if _, known := sch.compressedPeers[id]; sch.compressBlocks && !known {
+			// the peers of the versions before the compressed sync drop the compressed requests
+			sch.log.Infof("node [%s] does not serve the compressed block requests, request it uncompressed", id)
+			sch.compressedPeers[id] = false
+			continue
 		}
-		delete(sch.peers, id)
-		delete(sch.pendingTime, sch.pendingRecvHeight)
-		delete(sch.pendingBlocks, sch.pendingRecvHeight)
+		sch.penalize(id, timeoutPenalty, failureTimeout)
+		sch.reportPeer(id, failureTimeout)
 	}
+	sch.reportMetrics()
 }
 
 func (sch *scheduler) handleScheduleMsg() (queue.Item, error) {
 	var (
-		err           error
-		bz            []byte
 		peer          string
 		pendingHeight uint64
 	)
@@ -168,19 +217,31 @@ func (sch *scheduler) handleScheduleMsg() (queue.Item, error) {
 		//sch.log.Debugf("no need to sync block")
 		return nil, nil
 	}
-	if pendingHeight = sch.nextHeightToReq(); pendingHeight == math.MaxUint64 {
-		sch.log.Debugf("pendingHeight: %d, block status %v", pendingHeight, sch.blockStates)
-		return nil, nil
-	}
-	if bz, err = proto.Marshal(&syncPb.BlockSyncReq{
-		BlockHeight: pendingHeight, BatchSize: sch.BatchesizeInEachReq,
-	}); err != nil {
-		return nil, err
+	// spread the requests across the peers in parallel, each peer gets one request in a tick at most
+	requested := make(map[string]bool, len(sch.peers))
+	for i := 0; i < len(sch.peers); i++ {
+		if pendingHeight = sch.nextHeightToReq(); pendingHeight == math.MaxUint64 {
+			sch.log.Debugf("pendingHeight: %d, block status %v", pendingHeight, sch.blockStates)
+			return nil, nil
+		}
+		if peer = sch.selectPeer(pendingHeight, requested); len(peer) == 0 {
+			sch.log.Debugf("no peers have block [%d] ", pendingHeight)
+			return nil, nil
+		}
+		requested[peer] = true
+		if err := sch.requestBlocks(pendingHeight, peer); err != nil {
+			return nil, err
+		}
 	}
+	return nil, nil
+}
 
-	if peer = sch.selectPeer(pendingHeight); len(peer) == 0 {
-		sch.log.Debugf("no peers have block [%d] ", pendingHeight)
-		return nil, nil
+func (sch *scheduler) requestBlocks(pendingHeight uint64, peer string) error {
+	bz, err := proto.Marshal(&syncPb.BlockSyncReq{
+		BlockHeight: pendingHeight, BatchSize: sch.BatchesizeInEachReq,
+	})
+	if err != nil {
+		return err
 	}
 	sch.lastRequest = time.Now()
 	for i := pendingHeight; i <= sch.peers[peer] && i < sch.BatchesizeInEachReq+pendingHeight; i++ {
@@ -190,10 +251,11 @@ func (sch *scheduler) handleScheduleMsg() (queue.Item, error) {
 	}
 	sch.log.Debugf("request block[height: %d] from node [%s], BatchesSizeInReq: %d", pendingHeight, peer,
 		sch.BatchesizeInEachReq)
-	if err := sch.sender.sendMsg(syncPb.SyncMsg_BLOCK_SYNC_REQ, bz, peer); err != nil {
-		return nil, err
+	reqType := syncPb.SyncMsg_BLOCK_SYNC_REQ
+	if supported, known := sch.compressedPeers[peer]; sch.compressBlocks && (!known || supported) {
+		reqType = syncMsgCompressedBlockSyncReq
 	}
-	return nil, nil
+	return sch.sender.sendMsg(reqType, bz, peer)
 }
 
 func (sch *scheduler) nextHeightToReq() uint64 {
@@ -231,26 +293,58 @@ func (sch *scheduler) isNeedSync() bool {
 	return currHeight+1 < max || (currHeight+1 == max && time.Since(sch.lastRequest) > sch.reqTimeThreshold)
 }
 
-func (sch *scheduler) selectPeer(pendingHeight uint64) string {
+// selectPeer selects the peer expected to serve the request soonest by its measured
+// latency and throughput, its pending requests and its penalty.
+func (sch *scheduler) selectPeer(pendingHeight uint64, exclude map[string]bool) string {
 	peers := sch.getHeight(pendingHeight)
-	if len(peers) == 0 {
-		return ""
+	sort.Strings(peers)
+	var (
+		selected string
+		minCost  time.Duration
+	)
+	for _, peer := range peers {
+		if exclude[peer] || (len(sch.pinnedPeers) > 0 && !sch.pinnedPeers[peer]) {
+			continue
+		}
+		cost := sch.peerStat(peer).estimate(sch.getPendingReqInPeer(peer), sch.BatchesizeInEachReq,
+			sch.peerReqTimeout/10)
+		if selected == "" || cost < minCost {
+			selected, minCost = peer, cost
+		}
 	}
+	return selected
+}
 
-	pendingReqInPeers := make(map[int][]string)
-	for i := 0; i < len(peers); i++ {
-		reqNum := sch.getPendingReqInPeer(peers[i])
-		pendingReqInPeers[reqNum] = append(pendingReqInPeers[reqNum], peers[i])
+func (sch *scheduler) peerStat(peer string) *peerStat {
+	stat, exist := sch.peerStats[peer]
+	if !exist {
+		stat = &peerStat{}
+		sch.peerStats[peer] = stat
 	}
-	min := math.MaxInt64
-	for num := range pendingReqInPeers {
-		if min > num {
-			min = num
-		}
+	return stat
+}
+
+// penalize increases the penalty of the peer, the peer is evicted and banned
+// for a while when its penalty reaches maxPeerPenalty.
+func (sch *scheduler) penalize(peer string, penalty int, reason string) {
+	sch.metrics.incPeerFailure(peer, reason)
+	stat := sch.peerStat(peer)
+	stat.penalty += penalty
+	if stat.penalty < sch.maxPeerPenalty {
+		sch.log.Debugf("penalize node [%s], penalty: %d", peer, stat.penalty)
+		return
+	}
+	sch.log.Warnf("evict node [%s] from block sync for %v, penalty: %d", peer, sch.peerBanTime, stat.penalty)
+	delete(sch.peers, peer)
+	delete(sch.peerStats, peer)
+	sch.bannedPeers[peer] = time.Now().Add(sch.peerBanTime)
+}
+
+// reportPeer reports the misbehavior of the peer to the net, the reasons are the same as the net ones
+func (sch *scheduler) reportPeer(peer string, reason string) {
+	if sch.reporter != nil {
+		sch.reporter.ReportPeer(peer, reason)
 	}
-	peers = pendingReqInPeers[min]
-	sort.Strings(peers)
-	return peers[0]
 }
 
 func (sch *scheduler) getHeight(pendingHeight uint64) []string {
@@ -278,11 +372,24 @@ func (sch *scheduler) handleSyncedBlockMsg(msg *SyncedBlockMsg) (queue.Item, err
 	if err := proto.Unmarshal(msg.msg, &blkBatch); err != nil {
 		return nil, err
 	}
+	if msg.compressed {
+		sch.compressedPeers[msg.from] = true
+	}
 	if len(blkBatch.GetBlockBatch().Batches) == 0 {
 		return nil, nil
 	}
-	needToProcess := false
+	var (
+		needToProcess = false
+		requested     int
+		reqTime       time.Time
+	)
 	for _, blk := range blkBatch.GetBlockBatch().Batches {
+		if sch.pendingBlocks[blk.Header.BlockHeight] == msg.from {
+			requested++
+			if t := sch.pendingTime[blk.Header.BlockHeight]; reqTime.IsZero() || t.Before(reqTime) {
+				reqTime = t
+			}
+		}
 		delete(sch.pendingBlocks, blk.Header.BlockHeight)
 		delete(sch.pendingTime, blk.Header.BlockHeight)
 		if _, exist := sch.blockStates[blk.Header.BlockHeight]; exist {
@@ -293,6 +400,10 @@ func (sch *scheduler) handleSyncedBlockMsg(msg *SyncedBlockMsg) (queue.Item, err
 		sch.log.Debugf("received block [height:%d:%x] needToProcess: %v from "+
 			"node [%s]", blk.Header.BlockHeight, blk.Header.BlockHash, needToProcess, msg.from)
 	}
+	if requested > 0 {
+		sch.peerStat(msg.from).observe(requested, time.Since(reqTime))
+		sch.metrics.observePeerLatency(msg.from, time.Since(reqTime))
+	}
 	if needToProcess {
 		return &ReceivedBlocks{
 			blks: blkBatch.GetBlockBatch().Batches,
@@ -314,14 +425,15 @@ func (sch *scheduler) handleProcessedBlockResp(msg ProcessedBlockResp) (queue.It
 	}
 	if msg.status == validateFailed {
 		sch.blockStates[msg.height] = newBlock
-		delete(sch.peers, msg.from)
+		sch.penalize(msg.from, sch.maxPeerPenalty, failureInvalidBlock)
+		sch.reportPeer(msg.from, failureInvalidBlock)
 	}
 	if msg.status == dbErr {
 		return nil, fmt.Errorf("query db failed in processor")
 	}
 	if msg.status == addErr {
+		// the block is valid but the node failed to add it, the peer is not to blame
 		sch.blockStates[msg.height] = newBlock
-		delete(sch.peers, msg.from)
 		return nil, fmt.Errorf("failed add block to chain")
 	}
 	return nil, nil
@@ -181,6 +181,13 @@ func TestProcessedBlockResp(t *testing.T) {
 	require.NoError(t, err)
 	require.EqualValues(t, 1, len(sch.blockStates))
 	require.EqualValues(t, 8, sch.pendingRecvHeight)
+	require.Contains(t, sch.bannedPeers, "node1")
+
+	// 4. the node failing to add a block does not penalize the peer
+	_, err = sch.handler(ProcessedBlockResp{height: 8, status: addErr, from: "node2"})
+	require.Error(t, err)
+	require.EqualValues(t, 0, sch.peerStat("node2").penalty)
+	require.NotContains(t, sch.bannedPeers, "node2")
 }
 
 func TestLivenessMsg(t *testing.T) {
@@ -0,0 +1,130 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	syncPb "chainmaker.org/chainmaker/pb-go/v2/sync"
+	"github.com/gogo/protobuf/proto"
+)
+
+// The msg types of the compressed block sync, the payload of the response is the
+// gzip of syncPb.SyncBlockBatch, the request is the same as BLOCK_SYNC_REQ.
+const (
+	syncMsgCompressedBlockSyncReq syncPb.SyncMsg_MsgType = iota + 110
+	syncMsgCompressedBlockSyncResp
+)
+
+// maxDecompressedSize bounds the size of a decompressed batch
+const maxDecompressedSize = 256 * 1024 * 1024
+
+type msgSender interface {
+	sendMsg(msgType syncPb.SyncMsg_MsgType, msg []byte, to string) error
+}
+
+// blockBatcher packs the blocks of a request into batches no larger than
+// maxBytes, a block larger than maxBytes is sent in a batch alone.
+type blockBatcher struct {
+	sender   msgSender
+	to       string
+	maxBytes int
+	compress bool
+
+	size   int
+	blocks []*commonPb.Block
+	infos  []*commonPb.BlockInfo
+}
+
+func newBlockBatcher(sender msgSender, to string, maxBytes int, compress bool) *blockBatcher {
+	return &blockBatcher{sender: sender, to: to, maxBytes: maxBytes, compress: compress}
+}
+
+func (b *blockBatcher) addBlock(blk *commonPb.Block) error {
+	if err := b.flushIfFull(blk.Size()); err != nil {
+		return err
+	}
+	b.blocks = append(b.blocks, blk)
+	return nil
+}
+
+func (b *blockBatcher) addInfo(info *commonPb.BlockInfo) error {
+	if err := b.flushIfFull(info.Size()); err != nil {
+		return err
+	}
+	b.infos = append(b.infos, info)
+	return nil
+}
+
+func (b *blockBatcher) flushIfFull(size int) error {
+	if b.size > 0 && b.size+size > b.maxBytes {
+		if err := b.flush(); err != nil {
+			return err
+		}
+	}
+	b.size += size
+	return nil
+}
+
+// flush sends the pending blocks in one msg
+func (b *blockBatcher) flush() error {
+	if len(b.blocks) == 0 && len(b.infos) == 0 {
+		return nil
+	}
+	batch := &syncPb.SyncBlockBatch{}
+	if len(b.infos) > 0 {
+		batch.Data = &syncPb.SyncBlockBatch_BlockinfoBatch{BlockinfoBatch: &syncPb.BlockInfoBatch{Batch: b.infos}}
+	} else {
+		batch.Data = &syncPb.SyncBlockBatch_BlockBatch{BlockBatch: &syncPb.BlockBatch{Batches: b.blocks}}
+	}
+	b.size, b.blocks, b.infos = 0, nil, nil
+
+	bz, err := proto.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	if !b.compress {
+		return b.sender.sendMsg(syncPb.SyncMsg_BLOCK_SYNC_RESP, bz, b.to)
+	}
+	if bz, err = compress(bz); err != nil {
+		return err
+	}
+	return b.sender.sendMsg(syncMsgCompressedBlockSyncResp, bz, b.to)
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	bz, err := ioutil.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(bz) > maxDecompressedSize {
+		return nil, fmt.Errorf("decompressed batch exceeds %d bytes", maxDecompressedSize)
+	}
+	return bz, nil
+}
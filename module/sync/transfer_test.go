@@ -0,0 +1,147 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sync
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"chainmaker.org/chainmaker/logger/v2"
+	commonPb "chainmaker.org/chainmaker/pb-go/v2/common"
+	syncPb "chainmaker.org/chainmaker/pb-go/v2/sync"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+type batchSender struct {
+	types    []syncPb.SyncMsg_MsgType
+	payloads [][]byte
+}
+
+func (s *batchSender) sendMsg(msgType syncPb.SyncMsg_MsgType, msg []byte, to string) error {
+	s.types = append(s.types, msgType)
+	s.payloads = append(s.payloads, msg)
+	return nil
+}
+
+func testBlock(height uint64) *commonPb.Block {
+	return &commonPb.Block{Header: &commonPb.BlockHeader{BlockHeight: height, BlockHash: make([]byte, 32)}}
+}
+
+func TestBlockBatcher(t *testing.T) {
+	sender := &batchSender{}
+	batcher := newBlockBatcher(sender, "node1", 2*testBlock(1).Size(), false)
+	for i := uint64(1); i <= 5; i++ {
+		require.NoError(t, batcher.addBlock(testBlock(i)))
+	}
+	require.NoError(t, batcher.flush())
+	require.Len(t, sender.payloads, 3)
+
+	var heights []uint64
+	for i, payload := range sender.payloads {
+		require.Equal(t, syncPb.SyncMsg_BLOCK_SYNC_RESP, sender.types[i])
+		batch := syncPb.SyncBlockBatch{}
+		require.NoError(t, proto.Unmarshal(payload, &batch))
+		for _, blk := range batch.GetBlockBatch().Batches {
+			heights = append(heights, blk.Header.BlockHeight)
+		}
+	}
+	require.Equal(t, []uint64{1, 2, 3, 4, 5}, heights)
+}
+
+func TestBlockBatcher_compress(t *testing.T) {
+	sender := &batchSender{}
+	batcher := newBlockBatcher(sender, "node1", 1024*1024, true)
+	for i := uint64(1); i <= 3; i++ {
+		require.NoError(t, batcher.addBlock(testBlock(i)))
+	}
+	require.NoError(t, batcher.flush())
+	require.Equal(t, []syncPb.SyncMsg_MsgType{syncMsgCompressedBlockSyncResp}, sender.types)
+
+	bz, err := decompress(sender.payloads[0])
+	require.NoError(t, err)
+	batch := syncPb.SyncBlockBatch{}
+	require.NoError(t, proto.Unmarshal(bz, &batch))
+	require.Len(t, batch.GetBlockBatch().Batches, 3)
+
+	_, err = decompress([]byte("not gzip"))
+	require.Error(t, err)
+}
+
+func TestSchedulerParallelRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockSender := NewMockSender()
+	mockLedger := newMockLedgerCache(ctrl, &commonPb.Block{Header: &commonPb.BlockHeader{BlockHeight: 10}})
+	sch := newScheduler(mockSender, mockLedger, 100, time.Second, time.Second*3, 2, logger.GetLogger(logger.MODULE_SYNC))
+	sch.compressBlocks = true
+
+	_, _ = sch.handler(NodeStatusMsg{from: "node1", msg: syncPb.BlockHeightBCM{BlockHeight: 100}})
+	_, _ = sch.handler(NodeStatusMsg{from: "node2", msg: syncPb.BlockHeightBCM{BlockHeight: 100}})
+	// node2 is measured much faster than node1
+	sch.peerStat("node1").observe(2, time.Second)
+	sch.peerStat("node2").observe(2, 10*time.Millisecond)
+
+	_, _ = sch.handler(SchedulerMsg{})
+	require.Equal(t, []string{"msgType: 110, to: node2", "msgType: 110, to: node1"}, mockSender.msgs)
+	require.EqualValues(t, "node2", sch.pendingBlocks[11])
+	require.EqualValues(t, "node1", sch.pendingBlocks[13])
+}
+
+func TestSchedulerEvictPeer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLedger := newMockLedgerCache(ctrl, &commonPb.Block{Header: &commonPb.BlockHeader{BlockHeight: 10}})
+	sch := newScheduler(NewMockSender(), mockLedger, 100, time.Second, time.Second*3, 2, logger.GetLogger(logger.MODULE_SYNC))
+	sch.maxPeerPenalty = 2
+
+	_, _ = sch.handler(NodeStatusMsg{from: "node1", msg: syncPb.BlockHeightBCM{BlockHeight: 100}})
+	sch.penalize("node1", timeoutPenalty, failureTimeout)
+	require.EqualValues(t, 100, sch.peers["node1"])
+	sch.penalize("node1", timeoutPenalty, failureTimeout)
+	require.EqualValues(t, 0, len(sch.peers))
+
+	// the status of the banned peer is ignored until the ban expires
+	_, _ = sch.handler(NodeStatusMsg{from: "node1", msg: syncPb.BlockHeightBCM{BlockHeight: 100}})
+	require.EqualValues(t, 0, len(sch.peers))
+	sch.bannedPeers["node1"] = time.Now()
+	_, _ = sch.handler(NodeStatusMsg{from: "node1", msg: syncPb.BlockHeightBCM{BlockHeight: 100}})
+	require.EqualValues(t, 100, sch.peers["node1"])
+}
+
+func TestSchedulerCompressFallback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockSender := NewMockSender()
+	mockLedger := newMockLedgerCache(ctrl, &commonPb.Block{Header: &commonPb.BlockHeader{BlockHeight: 10}})
+	sch := newScheduler(mockSender, mockLedger, 100, time.Millisecond, time.Second*3, 2,
+		logger.GetLogger(logger.MODULE_SYNC))
+	sch.compressBlocks = true
+
+	_, _ = sch.handler(NodeStatusMsg{from: "node1", msg: syncPb.BlockHeightBCM{BlockHeight: 100}})
+	_, _ = sch.handler(SchedulerMsg{})
+	require.Equal(t, []string{"msgType: 110, to: node1"}, mockSender.msgs)
+
+	// the peer drops the compressed request, it is requested uncompressed without a penalty
+	time.Sleep(2 * time.Millisecond)
+	_, _ = sch.handler(LivenessMsg{})
+	require.Equal(t, 0, sch.peerStat("node1").penalty)
+	_, _ = sch.handler(SchedulerMsg{})
+	require.Equal(t, fmt.Sprintf("msgType: %d, to: node1", syncPb.SyncMsg_BLOCK_SYNC_REQ), mockSender.msgs[1])
+
+	// the peer serving a compressed batch is requested compressed
+	bz, err := proto.Marshal(&syncPb.SyncBlockBatch{Data: &syncPb.SyncBlockBatch_BlockBatch{
+		BlockBatch: &syncPb.BlockBatch{Batches: []*commonPb.Block{testBlock(11)}}}})
+	require.NoError(t, err)
+	_, _ = sch.handler(NodeStatusMsg{from: "node2", msg: syncPb.BlockHeightBCM{BlockHeight: 100}})
+	_, _ = sch.handler(&SyncedBlockMsg{msg: bz, from: "node2", compressed: true})
+	require.True(t, sch.compressedPeers["node2"])
+	require.NoError(t, sch.requestBlocks(20, "node2"))
+	require.Equal(t, "msgType: 110, to: node2", mockSender.msgs[len(mockSender.msgs)-1])
+}
@@ -48,6 +48,7 @@ func CertCMD() *cobra.Command {
 	certCmd.AddCommand(caCMD())
 	certCmd.AddCommand(csrCMD())
 	certCmd.AddCommand(issueCMD())
+	certCmd.AddCommand(renewCMD())
 	certCmd.AddCommand(createCertCrlCMD())
 	certCmd.AddCommand(nodeIdCMD())
 	certCmd.AddCommand(addrCMD())
@@ -0,0 +1,206 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cert
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"chainmaker.org/chainmaker/common/v2/cert"
+	"chainmaker.org/chainmaker/common/v2/crypto/x509"
+)
+
+// the extensions generated by x509.CreateCertificate from the template fields, the others are copied
+var generatedExtensions = map[string]bool{
+	"2.5.29.14":         true, // subject key id
+	"2.5.29.35":         true, // authority key id
+	"2.5.29.15":         true, // key usage
+	"2.5.29.37":         true, // extended key usage
+	"2.5.29.19":         true, // basic constraints
+	"2.5.29.17":         true, // subject alternative name
+	"2.5.29.30":         true, // name constraints
+	"2.5.29.31":         true, // CRL distribution points
+	"2.5.29.32":         true, // certificate policies
+	"1.3.6.1.5.5.7.1.1": true, // authority info access
+}
+
+const renewLong = `Renew certificate, issue a replacement of the certificate by the CA with a new validity.
+The subject, SANs, key usages and extensions of the certificate are kept, and so is the
+validity period. The public key is kept too unless a CSR of a new key is given.
+
+Roll the renewed certificate in without downtime:
+  * node and user certificates issued by a trusted CA are accepted at once, replace the files
+    and reload or restart the nodes one by one, a node id derived from the TLS certificate
+    changes with the key only, update it by 'cmc client chainconfig consensusnodeid update'
+  * trust members, add the new one by 'cmc client chainconfig trustmember add' before removing the
+    old one by 'cmc client chainconfig trustmember remove'
+  * renewed trust roots, add them besides the old ones by 'cmc client chainconfig trustroot roll',
+    and remove the old ones by 'cmc client chainconfig trustroot update' once all certificates
+    issued by them are renewed`
+
+func renewCMD() *cobra.Command {
+	renewCmd := &cobra.Command{
+		Use:   "renew",
+		Short: "Renew certificate",
+		Long:  renewLong,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return renewCertificate()
+		},
+	}
+
+	attachFlags(renewCmd, []string{
+		flagCrtPath, flagCaKeyPath, flagCaCertPath, "csr-path", "path", "name",
+	})
+
+	renewCmd.MarkFlagRequired(flagCrtPath)
+	renewCmd.MarkFlagRequired(flagCaKeyPath)
+	renewCmd.MarkFlagRequired(flagCaCertPath)
+
+	return renewCmd
+}
+
+func renewCertificate() error {
+	oldCert, err := cert.ParseCertificate(crtPath)
+	if err != nil {
+		return fmt.Errorf("parse cert failed, %s", err.Error())
+	}
+	issuerCert, err := cert.ParseCertificate(caCertPath)
+	if err != nil {
+		return fmt.Errorf("parse ca cert file failed, %s", err.Error())
+	}
+	issuerPrivKey, err := loadPrivateKey(caKeyPath)
+	if err != nil {
+		return fmt.Errorf("load ca key failed, %s", err.Error())
+	}
+	// a self-signed root is renewed by itself
+	if !bytes.Equal(oldCert.Raw, issuerCert.Raw) {
+		if err = oldCert.CheckSignatureFrom(issuerCert); err != nil {
+			return fmt.Errorf("cert is not issued by the ca cert, %s", err.Error())
+		}
+	}
+
+	pubKey, keyChanged := oldCert.PublicKey, false
+	if csrPath != "" {
+		var csr *x509.CertificateRequest
+		if csr, err = parseCSR(csrPath); err != nil {
+			return err
+		}
+		pubKey = csr.PublicKey
+		var oldPubKey, newPubKey []byte
+		if oldPubKey, err = x509.MarshalPKIXPublicKey(oldCert.PublicKey); err != nil {
+			return err
+		}
+		if newPubKey, err = x509.MarshalPKIXPublicKey(pubKey); err != nil {
+			return err
+		}
+		keyChanged = !bytes.Equal(oldPubKey, newPubKey)
+	}
+
+	template, err := renewTemplate(oldCert, pubKey, keyChanged)
+	if err != nil {
+		return err
+	}
+	parent := issuerCert
+	if bytes.Equal(oldCert.Raw, issuerCert.Raw) {
+		parent = template
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, pubKey, issuerPrivKey.ToStandardKey())
+	if err != nil {
+		return fmt.Errorf("issue cert failed, %s", err.Error())
+	}
+
+	if err = os.MkdirAll(path, os.ModePerm); err != nil {
+		return fmt.Errorf("mk cert dir failed, %s", err.Error())
+	}
+	certFile := filepath.Join(path, name)
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0644)
+	if err != nil {
+		return fmt.Errorf("write cert file failed, %s", err.Error())
+	}
+
+	fmt.Printf("renewed cert %s, serial: %s, valid from %s to %s\n", certFile, template.SerialNumber.Text(16),
+		template.NotBefore.Format(time.RFC3339), template.NotAfter.Format(time.RFC3339))
+	if keyChanged {
+		fmt.Println("the public key is changed, the node id of a node TLS cert changes with it")
+	}
+	return nil
+}
+
+func renewTemplate(oldCert *x509.Certificate, pubKey interface{}, keyChanged bool) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               oldCert.Subject,
+		NotBefore:             now,
+		NotAfter:              now.Add(oldCert.NotAfter.Sub(oldCert.NotBefore)),
+		SignatureAlgorithm:    oldCert.SignatureAlgorithm,
+		KeyUsage:              oldCert.KeyUsage,
+		ExtKeyUsage:           oldCert.ExtKeyUsage,
+		UnknownExtKeyUsage:    oldCert.UnknownExtKeyUsage,
+		BasicConstraintsValid: oldCert.BasicConstraintsValid,
+		IsCA:                  oldCert.IsCA,
+		MaxPathLen:            oldCert.MaxPathLen,
+		MaxPathLenZero:        oldCert.MaxPathLenZero,
+		SubjectKeyId:          oldCert.SubjectKeyId,
+		DNSNames:              oldCert.DNSNames,
+		EmailAddresses:        oldCert.EmailAddresses,
+		IPAddresses:           oldCert.IPAddresses,
+		URIs:                  oldCert.URIs,
+		OCSPServer:            oldCert.OCSPServer,
+		IssuingCertificateURL: oldCert.IssuingCertificateURL,
+		CRLDistributionPoints: oldCert.CRLDistributionPoints,
+		PolicyIdentifiers:     oldCert.PolicyIdentifiers,
+	}
+	for _, ext := range oldCert.Extensions {
+		if !generatedExtensions[ext.Id.String()] {
+			template.ExtraExtensions = append(template.ExtraExtensions, ext)
+		}
+	}
+	if keyChanged {
+		pubKeyDER, err := x509.MarshalPKIXPublicKey(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		ski := sha256.Sum256(pubKeyDER)
+		template.SubjectKeyId = ski[:]
+	}
+	return template, nil
+}
+
+func parseCSR(csrFile string) (*x509.CertificateRequest, error) {
+	raw, err := ioutil.ReadFile(csrFile)
+	if err != nil {
+		return nil, fmt.Errorf("read csr file failed, %s", err.Error())
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("pem.Decode failed, invalid csr")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse csr failed, %s", err.Error())
+	}
+	if err = csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid csr signature, %s", err.Error())
+	}
+	return csr, nil
+}
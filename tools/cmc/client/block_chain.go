@@ -22,6 +22,7 @@ func blockChainsCMD() *cobra.Command {
 		Long:  "blockchains command",
 	}
 	chainConfigCmd.AddCommand(checkNewBlockchainsCMD())
+	chainConfigCmd.AddCommand(chainLifecycleCMDs()...)
 	return chainConfigCmd
 }
 
@@ -0,0 +1,189 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"chainmaker.org/chainmaker-go/tools/cmc/util"
+	bcx509 "chainmaker.org/chainmaker/common/v2/crypto/x509"
+	pbac "chainmaker.org/chainmaker/pb-go/v2/accesscontrol"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	sdk "chainmaker.org/chainmaker/sdk-go/v2"
+	sdkutils "chainmaker.org/chainmaker/sdk-go/v2/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	grantDelegation = iota
+	revokeDelegation
+)
+
+// delegationResourcePrefix is the prefix of the resource names of the delegations in the chain config
+const delegationResourcePrefix = "DELEGATION:"
+
+func configDelegationCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delegation",
+		Short: "delegated admin command",
+		Long:  "delegated admin command",
+	}
+	cmd.AddCommand(grantDelegationCMD())
+	cmd.AddCommand(revokeDelegationCMD())
+
+	return cmd
+}
+
+func grantDelegationCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "grant",
+		Short: "grant the admin role of the org to a member for some resources and contracts",
+		Long: "grant the admin role of the org to a member for some resources and contracts, " +
+			"until the expiration if it's specified",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return configDelegation(grantDelegation)
+		},
+	}
+
+	attachFlags(cmd, []string{
+		flagUserSignKeyFilePath, flagUserSignCrtFilePath,
+		flagSdkConfPath, flagOrgId, flagEnableCertHash, flagAdminCrtFilePaths, flagAdminKeyFilePaths,
+		flagUserTlsCrtFilePath, flagUserTlsKeyFilePath, flagDelegationName, flagDelegationOrgId,
+		flagDelegateCrtPath, flagDelegationResources, flagDelegationContracts, flagDelegationExpireAt,
+	})
+
+	cmd.MarkFlagRequired(flagSdkConfPath)
+	cmd.MarkFlagRequired(flagDelegationName)
+	cmd.MarkFlagRequired(flagDelegationOrgId)
+	cmd.MarkFlagRequired(flagDelegateCrtPath)
+
+	return cmd
+}
+
+func revokeDelegationCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "revoke",
+		Short: "revoke the delegated admin role",
+		Long:  "revoke the delegated admin role",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return configDelegation(revokeDelegation)
+		},
+	}
+
+	attachFlags(cmd, []string{
+		flagUserSignKeyFilePath, flagUserSignCrtFilePath,
+		flagSdkConfPath, flagOrgId, flagEnableCertHash, flagAdminCrtFilePaths, flagAdminKeyFilePaths,
+		flagUserTlsCrtFilePath, flagUserTlsKeyFilePath, flagDelegationName,
+	})
+
+	cmd.MarkFlagRequired(flagSdkConfPath)
+	cmd.MarkFlagRequired(flagDelegationName)
+
+	return cmd
+}
+
+func configDelegation(op int) error {
+	client, err := util.CreateChainClient(sdkConfPath, chainId, orgId, userTlsCrtFilePath, userTlsKeyFilePath,
+		userSignCrtFilePath, userSignKeyFilePath)
+	if err != nil {
+		return err
+	}
+	defer client.Stop()
+
+	if client.GetAuthType() != sdk.PermissionedWithCert {
+		return errors.New("the delegated admins are only supported by the chains with certs")
+	}
+	var adminKeys, adminCrts []string
+	if adminKeyFilePaths != "" {
+		adminKeys = strings.Split(adminKeyFilePaths, ",")
+	}
+	if adminCrtFilePaths != "" {
+		adminCrts = strings.Split(adminCrtFilePaths, ",")
+	}
+	if len(adminKeys) != len(adminCrts) {
+		return fmt.Errorf(ADMIN_ORGID_KEY_CERT_LENGTH_NOT_EQUAL_FORMAT, len(adminKeys), len(adminCrts))
+	}
+
+	var payload *common.Payload
+	switch op {
+	case grantDelegation:
+		var rule string
+		if rule, err = delegationRule(); err == nil {
+			payload, err = client.CreateChainConfigPermissionAddPayload(delegationResourcePrefix+delegationName,
+				&pbac.Policy{Rule: rule, OrgList: []string{delegationOrgId}})
+		}
+	case revokeDelegation:
+		payload, err = client.CreateChainConfigPermissionDeletePayload(delegationResourcePrefix + delegationName)
+	default:
+		err = errors.New("invalid delegation operation")
+	}
+	if err != nil {
+		return err
+	}
+
+	endorsementEntrys := make([]*common.EndorsementEntry, len(adminKeys))
+	for i := range adminKeys {
+		e, err := sdkutils.MakeEndorserWithPath(adminKeys[i], adminCrts[i], payload)
+		if err != nil {
+			return err
+		}
+		endorsementEntrys[i] = e
+	}
+
+	resp, err := client.SendChainConfigUpdateRequest(payload, endorsementEntrys, -1, syncResult)
+	if err != nil {
+		return err
+	}
+	err = util.CheckProposalRequestResp(resp, false)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("delegation response %+v\n", resp)
+	return nil
+}
+
+// delegationRule returns the rule of the delegation, the delegate is identified by the SKI of its cert
+func delegationRule() (string, error) {
+	certBytes, err := ioutil.ReadFile(delegateCrtPath)
+	if err != nil {
+		return "", fmt.Errorf("read cert file [%s] failed, %s", delegateCrtPath, err)
+	}
+	block, _ := pem.Decode(certBytes)
+	if block == nil {
+		return "", errors.New("pem.Decode failed, invalid cert")
+	}
+	cert, err := bcx509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("parseCertificate cert failed, %s", err)
+	}
+	if len(delegationResources) == 0 && len(delegationContracts) == 0 {
+		return "", errors.New("please specify the resources or the contracts delegated")
+	}
+
+	rule := "DELEGATE " + hex.EncodeToString(cert.SubjectKeyId)
+	if len(delegationResources) > 0 {
+		rule += " ON (" + strings.Join(delegationResources, ", ") + ")"
+	}
+	if len(delegationContracts) > 0 {
+		rule += " CONTRACTS (" + strings.Join(delegationContracts, ", ") + ")"
+	}
+	if delegationExpireAt != "" {
+		expireAt, err := time.Parse(time.RFC3339, delegationExpireAt)
+		if err != nil {
+			return "", fmt.Errorf("invalid expiration [%s], should be in RFC3339, %s", delegationExpireAt, err)
+		}
+		rule += " UNTIL " + expireAt.UTC().Format(time.RFC3339)
+	}
+	return rule, nil
+}
@@ -26,6 +26,7 @@ const (
 	addTrustRoot = iota
 	removeTrustRoot
 	updateTrustRoot
+	rollTrustRoot
 )
 
 func configTrustRootCMD() *cobra.Command {
@@ -37,6 +38,7 @@ func configTrustRootCMD() *cobra.Command {
 	cmd.AddCommand(addTrustRootCMD())
 	cmd.AddCommand(removeTrustRootCMD())
 	cmd.AddCommand(updateTrustRootCMD())
+	cmd.AddCommand(rollTrustRootCMD())
 
 	return cmd
 }
@@ -110,6 +112,30 @@ func updateTrustRootCMD() *cobra.Command {
 	return cmd
 }
 
+func rollTrustRootCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "roll",
+		Short: "add renewed trust root ca certs besides the current ones",
+		Long: "add renewed trust root ca certs besides the current ones of the org, so the certs issued by both " +
+			"are trusted while rolling, remove the old ones by 'trustroot update' after all certs are renewed",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return configTrustRoot(rollTrustRoot)
+		},
+	}
+
+	attachFlags(cmd, []string{
+		flagUserSignKeyFilePath, flagUserSignCrtFilePath,
+		flagSdkConfPath, flagOrgId, flagEnableCertHash, flagTrustRootCrtPath, flagTrustRootOrgId,
+		flagAdminCrtFilePaths, flagAdminKeyFilePaths, flagAdminOrgIds, flagUserTlsCrtFilePath, flagUserTlsKeyFilePath,
+	})
+
+	cmd.MarkFlagRequired(flagSdkConfPath)
+	cmd.MarkFlagRequired(flagTrustRootOrgId)
+	cmd.MarkFlagRequired(flagTrustRootCrtPath)
+
+	return cmd
+}
+
 // nolint: gocyclo
 func configTrustRoot(op int) error {
 	var adminKeys []string
@@ -151,7 +177,7 @@ func configTrustRoot(op int) error {
 	}
 
 	var trustRootBytes []string
-	if op == addTrustRoot || op == updateTrustRoot {
+	if op == addTrustRoot || op == updateTrustRoot || op == rollTrustRoot {
 
 		if len(trustRootPaths) == 0 {
 			return fmt.Errorf("please specify trust root path")
@@ -173,6 +199,11 @@ func configTrustRoot(op int) error {
 		payload, err = client.CreateChainConfigTrustRootDeletePayload(trustRootOrgId)
 	case updateTrustRoot:
 		payload, err = client.CreateChainConfigTrustRootUpdatePayload(trustRootOrgId, trustRootBytes)
+	case rollTrustRoot:
+		var rolledRoots []string
+		if rolledRoots, err = rollTrustRoots(client, trustRootBytes); err == nil {
+			payload, err = client.CreateChainConfigTrustRootUpdatePayload(trustRootOrgId, rolledRoots)
+		}
 	default:
 		err = errors.New("invalid trust root operation")
 	}
@@ -227,3 +258,30 @@ func configTrustRoot(op int) error {
 	fmt.Printf("trustroot response %+v\n", resp)
 	return nil
 }
+
+// rollTrustRoots returns the current trust roots of the org with the new ones appended
+func rollTrustRoots(client *sdk.ChainClient, newRoots []string) ([]string, error) {
+	chainConfig, err := client.GetChainConfig()
+	if err != nil {
+		return nil, err
+	}
+	var roots []string
+	for _, trustRoot := range chainConfig.TrustRoots {
+		if trustRoot.OrgId == trustRootOrgId {
+			roots = append(roots, trustRoot.Root...)
+		}
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("trust root of org %s not found, please add it", trustRootOrgId)
+	}
+	current := make(map[string]bool, len(roots))
+	for _, root := range roots {
+		current[strings.TrimSpace(root)] = true
+	}
+	for _, root := range newRoots {
+		if !current[strings.TrimSpace(root)] {
+			roots = append(roots, root)
+		}
+	}
+	return roots, nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"chainmaker.org/chainmaker-go/tools/cmc/util"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	sdk "chainmaker.org/chainmaker/sdk-go/v2"
+)
+
+// the lifecycle admin queries are served by the node connected and sent to the system chain, the contract
+// name, methods and params are the same as the ones of module/blockchain
+const (
+	systemChainId                 = "system_chain"
+	lifecycleContractName         = "CHAIN_LIFECYCLE"
+	lifecycleMethodJoin           = "JOIN"
+	lifecycleMethodPause          = "PAUSE"
+	lifecycleMethodResume         = "RESUME"
+	lifecycleMethodLeave          = "LEAVE"
+	lifecycleMethodStatus         = "GET_STATUS"
+	lifecycleParamChainId         = "chain_id"
+	lifecycleParamGenesis         = "genesis"
+	lifecycleParamDeleteData      = "delete_data"
+	lifecycleAdminCommonComment   = ", the command is served by the node connected in the sdk config"
+	lifecycleAdminRequiredComment = ", requires an admin of the org of the node"
+)
+
+func chainLifecycleCMDs() []*cobra.Command {
+	return []*cobra.Command{
+		chainLifecycleCMD("join", "join the chain from the genesis file on the node without restarting"+
+			lifecycleAdminRequiredComment, lifecycleMethodJoin, []string{flagGenesisPath}),
+		chainLifecycleCMD("pause", "stop the modules of the chain, the chain could still be queried"+
+			lifecycleAdminRequiredComment, lifecycleMethodPause, nil),
+		chainLifecycleCMD("resume", "restart the modules of the paused chain"+lifecycleAdminRequiredComment,
+			lifecycleMethodResume, nil),
+		chainLifecycleCMD("leave", "stop the chain and close its store, delete its data if --delete-data"+
+			lifecycleAdminRequiredComment, lifecycleMethodLeave, []string{flagDeleteData}),
+		chainLifecycleCMD("status", "show the state and height of the chains on the node"+
+			lifecycleAdminRequiredComment, lifecycleMethodStatus, nil),
+	}
+}
+
+func chainLifecycleCMD(use, short, method string, paramFlags []string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  short + lifecycleAdminCommonComment,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			params := map[string]string{lifecycleParamChainId: chainId}
+			switch method {
+			case lifecycleMethodJoin:
+				params[lifecycleParamGenesis] = genesisPath
+			case lifecycleMethodLeave:
+				params[lifecycleParamDeleteData] = strconv.FormatBool(deleteData)
+			}
+			return chainLifecycle(method, params)
+		},
+	}
+
+	attachFlags(cmd, append([]string{
+		flagSdkConfPath, flagOrgId, flagChainId,
+		flagUserTlsCrtFilePath, flagUserTlsKeyFilePath, flagUserSignCrtFilePath, flagUserSignKeyFilePath,
+	}, paramFlags...))
+
+	cmd.MarkFlagRequired(flagSdkConfPath)
+	if method != lifecycleMethodStatus {
+		cmd.MarkFlagRequired(flagChainId)
+	}
+	if method == lifecycleMethodJoin {
+		cmd.MarkFlagRequired(flagGenesisPath)
+	}
+
+	return cmd
+}
+
+func chainLifecycle(method string, params map[string]string) error {
+	// the cert hash is not enabled, the system chain has no cert registered
+	client, err := sdk.NewChainClient(
+		sdk.WithConfPath(sdkConfPath),
+		sdk.WithChainClientChainId(systemChainId),
+		sdk.WithChainClientOrgId(orgId),
+		sdk.WithUserCrtFilePath(userTlsCrtFilePath),
+		sdk.WithUserKeyFilePath(userTlsKeyFilePath),
+		sdk.WithUserSignCrtFilePath(userSignCrtFilePath),
+		sdk.WithUserSignKeyFilePath(userSignKeyFilePath),
+	)
+	if err != nil {
+		return fmt.Errorf("create user client failed, %s", err.Error())
+	}
+	defer client.Stop()
+
+	resp, err := client.QuerySystemContract(lifecycleContractName, method, util.ConvertParameters(params),
+		DEFAULT_TIMEOUT)
+	if err != nil {
+		return fmt.Errorf("%s failed, %s", common.TxType_QUERY_CONTRACT.String(), err.Error())
+	}
+	if resp.Code != common.TxStatusCode_SUCCESS {
+		return fmt.Errorf("chain lifecycle %s failed, %s", method, resp.Message)
+	}
+	if method == lifecycleMethodStatus {
+		fmt.Println(string(resp.ContractResult.Result))
+		return nil
+	}
+	fmt.Printf("chain lifecycle %s of %s succeed\n", method, params[lifecycleParamChainId])
+	return nil
+}
@@ -75,6 +75,18 @@ var (
 	trustMemberInfoPath string
 	trustMemberRole     string
 	trustMemberNodeId   string
+
+	delegationName      string
+	delegationOrgId     string
+	delegateCrtPath     string
+	delegationResources []string
+	delegationContracts []string
+	delegationExpireAt  string
+
+	genesisPath string
+	deleteData  bool
+
+	messageId string
 )
 
 const (
@@ -124,6 +136,15 @@ const (
 	flagEpochID                = "epoch-id"
 	flagGrantContractList      = "grant-contract-list"
 	flagRevokeContractList     = "revoke-contract-list"
+	flagDelegationName         = "delegation-name"
+	flagDelegationOrgId        = "delegation-org-id"
+	flagDelegateCrtPath        = "delegate-crt-path"
+	flagDelegationResources    = "delegation-resources"
+	flagDelegationContracts    = "delegation-contracts"
+	flagDelegationExpireAt     = "delegation-expire-at"
+	flagGenesisPath            = "genesis-path"
+	flagDeleteData             = "delete-data"
+	flagMessageId              = "message-id"
 )
 
 func ClientCMD() *cobra.Command {
@@ -138,6 +159,9 @@ func ClientCMD() *cobra.Command {
 	clientCmd.AddCommand(getChainMakerServerVersionCMD())
 	clientCmd.AddCommand(certManageCMD())
 	clientCmd.AddCommand(blockChainsCMD())
+	clientCmd.AddCommand(syncCMD())
+	clientCmd.AddCommand(peersCMD())
+	clientCmd.AddCommand(crossChainCMD())
 
 	return clientCmd
 }
@@ -205,6 +229,19 @@ func init() {
 	flags.StringVar(&trustMemberRole, flagTrustMemberRole, "", "specify trust member role")
 	flags.StringVar(&trustMemberNodeId, flagTrustMemberNodeId, "", "specify trust member node id")
 
+	flags.StringVar(&delegationName, flagDelegationName, "", "specify the name of the delegation")
+	flags.StringVar(&delegationOrgId, flagDelegationOrgId, "", "specify the org whose admin role is delegated")
+	flags.StringVar(&delegateCrtPath, flagDelegateCrtPath, "", "specify the cert file path of the delegate")
+	flags.StringSliceVar(&delegationResources, flagDelegationResources, nil, "specify the resources delegated")
+	flags.StringSliceVar(&delegationContracts, flagDelegationContracts, nil, "specify the contracts delegated")
+	flags.StringVar(&delegationExpireAt, flagDelegationExpireAt, "", "specify the expiration of the delegation "+
+		"in RFC3339, such as 2022-01-01T00:00:00+08:00")
+
+	flags.StringVar(&genesisPath, flagGenesisPath, "", "specify the genesis file path on the node to join the chain")
+	flags.BoolVar(&deleteData, flagDeleteData, false, "whether delete the data of the chain left, default false")
+
+	flags.StringVar(&messageId, flagMessageId, "", "specify the id of the cross-chain message")
+
 	// 证书管理
 	flags.StringVar(&certFilePaths, flagCertFilePaths, "", "specify cert file paths, use ',' to separate")
 	flags.StringVar(&certCrlPath, flagCertCrlPath, "", "specify cert crl path")
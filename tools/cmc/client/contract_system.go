@@ -58,6 +58,8 @@ func systemContractCMD() *cobra.Command {
 	systemContractCmd.AddCommand(stakeReadEpochBlockNumber())
 	systemContractCmd.AddCommand(stakeReadSystemContractAddr())
 	systemContractCmd.AddCommand(stakeReadCompleteUnBoundingEpochNumber())
+	systemContractCmd.AddCommand(stakeRewardOf())
+	systemContractCmd.AddCommand(stakeWithdrawReward())
 
 	// system contract manage
 	systemContractCmd.AddCommand(systemContractManageCMD())
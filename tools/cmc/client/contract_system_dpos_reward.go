@@ -0,0 +1,139 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"chainmaker.org/chainmaker-go/tools/cmc/util"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+	sdk "chainmaker.org/chainmaker/sdk-go/v2"
+	sdkutils "chainmaker.org/chainmaker/sdk-go/v2/utils"
+)
+
+// the rewards of DPoS are issued and withdrawn by the consensus, the contract
+// name and methods are the same as the ones of consensus/dpos
+const (
+	dposRewardContractName = "DPOS_REWARD"
+	dposRewardMethodGet    = "GET_REWARD"
+	dposRewardMethodDraw   = "WITHDRAW_REWARD"
+	dposRewardParamAddress = "address"
+)
+
+func stakeRewardOf() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reward-of",
+		Short: "query the accrued rewards of the address in DPoS",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			client, err := util.CreateChainClient(sdkConfPath, chainId, orgId, userTlsCrtFilePath, userTlsKeyFilePath,
+				userSignCrtFilePath, userSignKeyFilePath)
+			if err != nil {
+				return err
+			}
+			defer client.Stop()
+
+			resp, err := rewardOf(client, address, DEFAULT_TIMEOUT)
+			if err != nil {
+				return fmt.Errorf("reward-of failed, %s", err.Error())
+			}
+			fmt.Printf("resp: %+v\n", resp)
+			return nil
+		},
+	}
+
+	attachFlags(cmd, []string{
+		flagAddress,
+		flagSdkConfPath,
+		flagOrgId, flagChainId,
+		flagUserTlsCrtFilePath, flagUserTlsKeyFilePath, flagUserSignCrtFilePath, flagUserSignKeyFilePath,
+	})
+
+	cmd.MarkFlagRequired(flagAddress)
+
+	return cmd
+}
+
+func stakeWithdrawReward() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "withdraw-reward",
+		Short: "withdraw the accrued rewards of the address to its balance in DPoS",
+		Long: "withdraw the accrued rewards of the address to its balance in DPoS, the address must be the one " +
+			"of the sign key of the user. The tx fails if the user does not own the address or there are no rewards, " +
+			"otherwise the rewards are moved by the consensus of the block containing it",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			client, err := util.CreateChainClient(sdkConfPath, chainId, orgId, userTlsCrtFilePath, userTlsKeyFilePath,
+				userSignCrtFilePath, userSignKeyFilePath)
+			if err != nil {
+				return err
+			}
+			defer client.Stop()
+
+			txId = sdkutils.GetRandTxId()
+			resp, err := withdrawReward(client, address, txId, DEFAULT_TIMEOUT, syncResult)
+			if err != nil {
+				return fmt.Errorf("withdraw-reward failed, %s", err.Error())
+			}
+			fmt.Printf("resp: %+v\n", resp)
+			return nil
+		},
+	}
+
+	attachFlags(cmd, []string{
+		flagAddress,
+		flagSdkConfPath,
+		flagOrgId, flagChainId,
+		flagUserTlsCrtFilePath, flagUserTlsKeyFilePath,
+		flagUserSignCrtFilePath, flagUserSignKeyFilePath,
+		flagSyncResult,
+	})
+
+	cmd.MarkFlagRequired(flagAddress)
+
+	return cmd
+}
+
+func rewardOf(cc *sdk.ChainClient, address string, timeout int64) (*common.TxResponse, error) {
+	params := map[string]string{
+		dposRewardParamAddress: address,
+	}
+	resp, err := cc.QuerySystemContract(
+		dposRewardContractName,
+		dposRewardMethodGet,
+		util.ConvertParameters(params),
+		timeout,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed, %s", common.TxType_QUERY_CONTRACT.String(), err.Error())
+	}
+
+	return resp, nil
+}
+
+func withdrawReward(cc *sdk.ChainClient, address string, txId string, timeout int64,
+	withSyncResult bool) (*common.TxResponse, error) {
+	params := map[string]string{
+		dposRewardParamAddress: address,
+	}
+	if txId == "" {
+		txId = sdkutils.GetRandTxId()
+	}
+	resp, err := cc.InvokeSystemContract(
+		dposRewardContractName,
+		dposRewardMethodDraw,
+		txId,
+		util.ConvertParameters(params),
+		timeout,
+		withSyncResult,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed, %s", common.TxType_INVOKE_CONTRACT.String(), err.Error())
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"chainmaker.org/chainmaker-go/tools/cmc/util"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+)
+
+// the queries of the cross-chain messages relayed between the chains of a node, the contract name, methods
+// and params are the same as the ones of module/core/crosschain
+const (
+	crossChainContractName     = "CROSS_CHAIN"
+	crossChainMethodReceipt    = "GET_RECEIPT"
+	crossChainMethodDelivery   = "GET_DELIVERY"
+	crossChainParamMessageId   = "message_id"
+	crossChainMessageIdComment = ", the message id is the sha256 hex of \"{src_chain_id}/{src_tx_id}/{event_index}\""
+)
+
+// crossChainCMD the commands to query the cross-chain messages
+func crossChainCMD() *cobra.Command {
+	crossChainCmd := &cobra.Command{
+		Use:   "cross-chain",
+		Short: "cross-chain message query command",
+		Long:  "cross-chain message query command" + crossChainMessageIdComment,
+	}
+	crossChainCmd.AddCommand(crossChainQueryCMD("receipt",
+		"show the receipt of the message acknowledged on the source chain", crossChainMethodReceipt))
+	crossChainCmd.AddCommand(crossChainQueryCMD("delivery",
+		"show the result of the delivery of the message on the destination chain", crossChainMethodDelivery))
+	return crossChainCmd
+}
+
+func crossChainQueryCMD(use, short, method string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  short + crossChainMessageIdComment,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return queryCrossChain(method)
+		},
+	}
+
+	attachFlags(cmd, []string{
+		flagSdkConfPath, flagOrgId, flagChainId, flagMessageId,
+		flagUserTlsCrtFilePath, flagUserTlsKeyFilePath, flagUserSignCrtFilePath, flagUserSignKeyFilePath,
+	})
+
+	cmd.MarkFlagRequired(flagSdkConfPath)
+	cmd.MarkFlagRequired(flagMessageId)
+
+	return cmd
+}
+
+func queryCrossChain(method string) error {
+	client, err := util.CreateChainClient(sdkConfPath, chainId, orgId, userTlsCrtFilePath, userTlsKeyFilePath,
+		userSignCrtFilePath, userSignKeyFilePath)
+	if err != nil {
+		return fmt.Errorf("create user client failed, %s", err.Error())
+	}
+	defer client.Stop()
+
+	params := map[string]string{crossChainParamMessageId: messageId}
+	resp, err := client.QuerySystemContract(crossChainContractName, method, util.ConvertParameters(params),
+		DEFAULT_TIMEOUT)
+	if err != nil {
+		return fmt.Errorf("%s failed, %s", common.TxType_QUERY_CONTRACT.String(), err.Error())
+	}
+	if resp.Code != common.TxStatusCode_SUCCESS {
+		return fmt.Errorf("cross chain %s failed, %s", method, resp.Message)
+	}
+	fmt.Println(string(resp.ContractResult.Result))
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"chainmaker.org/chainmaker-go/tools/cmc/util"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+)
+
+// the queries of the p2p layer are served by the node connected, the contract name, methods
+// and params are the same as the ones of module/net
+const (
+	netAdminContractName     = "NET_ADMIN"
+	netAdminMethodPeers      = "GET_PEERS"
+	netAdminMethodSeeds      = "GET_SEEDS"
+	netAdminMethodDial       = "DIAL"
+	netAdminMethodDisconnect = "DISCONNECT"
+	netAdminParamAddress     = "address"
+	netAdminParamNodeId      = "node_id"
+	netAdminCommonComment    = ", the command is served by the node connected in the sdk config"
+)
+
+// NetCMD the commands to inspect the p2p layer of the node
+func NetCMD() *cobra.Command {
+	netCmd := &cobra.Command{
+		Use:   "net",
+		Short: "p2p network inspection command",
+		Long:  "p2p network inspection command" + netAdminCommonComment,
+	}
+	netCmd.AddCommand(netAdminCMD("peers",
+		"show the peers of the chain with their orgs, addresses, latency, traffic and chains",
+		netAdminMethodPeers, nil))
+	netCmd.AddCommand(netAdminCMD("seeds", "show the seeds of the node and whether they are connected",
+		netAdminMethodSeeds, nil))
+	netCmd.AddCommand(netAdminCMD("dial",
+		"connect to the address and keep it as a seed, requires an admin of the org of the node",
+		netAdminMethodDial, []string{flagAddress}))
+	netCmd.AddCommand(netAdminCMD("disconnect",
+		"refuse the peer on the chain until it is dialed again, requires an admin of the org of the node",
+		netAdminMethodDisconnect, []string{flagNodeId}))
+	return netCmd
+}
+
+func netAdminCMD(use, short, method string, paramFlags []string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  short + netAdminCommonComment,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			params := map[string]string{}
+			switch method {
+			case netAdminMethodDial:
+				params[netAdminParamAddress] = address
+			case netAdminMethodDisconnect:
+				params[netAdminParamNodeId] = nodeId
+			}
+			return netAdmin(method, params)
+		},
+	}
+
+	attachFlags(cmd, append([]string{
+		flagSdkConfPath, flagOrgId, flagChainId,
+		flagUserTlsCrtFilePath, flagUserTlsKeyFilePath, flagUserSignCrtFilePath, flagUserSignKeyFilePath,
+	}, paramFlags...))
+
+	cmd.MarkFlagRequired(flagSdkConfPath)
+	for _, flag := range paramFlags {
+		cmd.MarkFlagRequired(flag)
+	}
+
+	return cmd
+}
+
+func netAdmin(method string, params map[string]string) error {
+	client, err := util.CreateChainClient(sdkConfPath, chainId, orgId, userTlsCrtFilePath, userTlsKeyFilePath,
+		userSignCrtFilePath, userSignKeyFilePath)
+	if err != nil {
+		return fmt.Errorf("create user client failed, %s", err.Error())
+	}
+	defer client.Stop()
+
+	resp, err := client.QuerySystemContract(netAdminContractName, method, util.ConvertParameters(params),
+		DEFAULT_TIMEOUT)
+	if err != nil {
+		return fmt.Errorf("%s failed, %s", common.TxType_QUERY_CONTRACT.String(), err.Error())
+	}
+	if resp.Code != common.TxStatusCode_SUCCESS {
+		return fmt.Errorf("net %s failed, %s", method, resp.Message)
+	}
+	if method == netAdminMethodPeers || method == netAdminMethodSeeds {
+		fmt.Println(string(resp.ContractResult.Result))
+		return nil
+	}
+	fmt.Printf("net %s succeed\n", method)
+	return nil
+}
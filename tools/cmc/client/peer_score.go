@@ -0,0 +1,90 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"chainmaker.org/chainmaker-go/tools/cmc/util"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+)
+
+// the peer score queries are served by the node connected, the contract name, methods
+// and params are the same as the ones of module/net
+const (
+	peerScoreContractName  = "PEER_SCORE"
+	peerScoreMethodList    = "LIST"
+	peerScoreMethodUnban   = "UNBAN"
+	peerScoreParamNodeId   = "node_id"
+	peerScoreCommonComment = ", the command is served by the node connected in the sdk config"
+)
+
+func peersCMD() *cobra.Command {
+	peersCmd := &cobra.Command{
+		Use:   "peers",
+		Short: "peer score command",
+		Long:  "peer score command" + peerScoreCommonComment,
+	}
+	peersCmd.AddCommand(peerScoreCMD("list", "show the scores and the bans of the peers of the chain",
+		peerScoreMethodList, nil))
+	peersCmd.AddCommand(peerScoreCMD("unban", "lift the ban of the peer at once, requires an admin",
+		peerScoreMethodUnban, []string{flagNodeId}))
+	return peersCmd
+}
+
+func peerScoreCMD(use, short, method string, paramFlags []string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  short + peerScoreCommonComment,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			params := map[string]string{}
+			if method == peerScoreMethodUnban {
+				params[peerScoreParamNodeId] = nodeId
+			}
+			return peerScore(method, params)
+		},
+	}
+
+	attachFlags(cmd, append([]string{
+		flagSdkConfPath, flagOrgId, flagChainId,
+		flagUserTlsCrtFilePath, flagUserTlsKeyFilePath, flagUserSignCrtFilePath, flagUserSignKeyFilePath,
+	}, paramFlags...))
+
+	cmd.MarkFlagRequired(flagSdkConfPath)
+	if method == peerScoreMethodUnban {
+		cmd.MarkFlagRequired(flagNodeId)
+	}
+
+	return cmd
+}
+
+func peerScore(method string, params map[string]string) error {
+	client, err := util.CreateChainClient(sdkConfPath, chainId, orgId, userTlsCrtFilePath, userTlsKeyFilePath,
+		userSignCrtFilePath, userSignKeyFilePath)
+	if err != nil {
+		return fmt.Errorf("create user client failed, %s", err.Error())
+	}
+	defer client.Stop()
+
+	resp, err := client.QuerySystemContract(peerScoreContractName, method, util.ConvertParameters(params),
+		DEFAULT_TIMEOUT)
+	if err != nil {
+		return fmt.Errorf("%s failed, %s", common.TxType_QUERY_CONTRACT.String(), err.Error())
+	}
+	if resp.Code != common.TxStatusCode_SUCCESS {
+		return fmt.Errorf("peers %s failed, %s", method, resp.Message)
+	}
+	if method == peerScoreMethodList {
+		fmt.Println(string(resp.ContractResult.Result))
+		return nil
+	}
+	fmt.Printf("peers %s succeed\n", method)
+	return nil
+}
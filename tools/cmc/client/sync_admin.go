@@ -0,0 +1,105 @@
+/*
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package client
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"chainmaker.org/chainmaker-go/tools/cmc/util"
+	"chainmaker.org/chainmaker/pb-go/v2/common"
+)
+
+// the admin queries of the block sync are served by the node connected, the contract
+// name, methods and params are the same as the ones of module/sync
+const (
+	syncAdminContractName  = "SYNC_ADMIN"
+	syncAdminMethodStatus  = "GET_STATUS"
+	syncAdminMethodPause   = "PAUSE"
+	syncAdminMethodResume  = "RESUME"
+	syncAdminMethodPin     = "PIN_PEERS"
+	syncAdminMethodResync  = "RESYNC"
+	syncAdminParamPeers    = "peers"
+	syncAdminParamHeight   = "height"
+	syncAdminCommonComment = ", the command is served by the node connected in the sdk config" +
+		", all but status require an admin of the org of the node"
+)
+
+func syncCMD() *cobra.Command {
+	syncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "block sync admin command",
+		Long:  "block sync admin command" + syncAdminCommonComment,
+	}
+	syncCmd.AddCommand(syncAdminCMD("status", "show the sync status and the heights of the peers",
+		syncAdminMethodStatus, nil))
+	syncCmd.AddCommand(syncAdminCMD("pause", "pause requesting and committing blocks, requires an admin",
+		syncAdminMethodPause, nil))
+	syncCmd.AddCommand(syncAdminCMD("resume", "resume the paused sync, requires an admin",
+		syncAdminMethodResume, nil))
+	syncCmd.AddCommand(syncAdminCMD("pin", "request blocks from the node ids only, empty to unpin, requires an admin",
+		syncAdminMethodPin, []string{flagNodeIds}))
+	syncCmd.AddCommand(syncAdminCMD("resync", "request the blocks from the block height again, requires an admin",
+		syncAdminMethodResync, []string{flagBlockHeight}))
+	return syncCmd
+}
+
+func syncAdminCMD(use, short, method string, paramFlags []string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  short + syncAdminCommonComment,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			params := map[string]string{}
+			switch method {
+			case syncAdminMethodPin:
+				params[syncAdminParamPeers] = nodeIds
+			case syncAdminMethodResync:
+				params[syncAdminParamHeight] = strconv.FormatUint(blockHeight, 10)
+			}
+			return syncAdmin(method, params)
+		},
+	}
+
+	attachFlags(cmd, append([]string{
+		flagSdkConfPath, flagOrgId, flagChainId,
+		flagUserTlsCrtFilePath, flagUserTlsKeyFilePath, flagUserSignCrtFilePath, flagUserSignKeyFilePath,
+	}, paramFlags...))
+
+	cmd.MarkFlagRequired(flagSdkConfPath)
+	if method == syncAdminMethodResync {
+		cmd.MarkFlagRequired(flagBlockHeight)
+	}
+
+	return cmd
+}
+
+func syncAdmin(method string, params map[string]string) error {
+	client, err := util.CreateChainClient(sdkConfPath, chainId, orgId, userTlsCrtFilePath, userTlsKeyFilePath,
+		userSignCrtFilePath, userSignKeyFilePath)
+	if err != nil {
+		return fmt.Errorf("create user client failed, %s", err.Error())
+	}
+	defer client.Stop()
+
+	resp, err := client.QuerySystemContract(syncAdminContractName, method, util.ConvertParameters(params),
+		DEFAULT_TIMEOUT)
+	if err != nil {
+		return fmt.Errorf("%s failed, %s", common.TxType_QUERY_CONTRACT.String(), err.Error())
+	}
+	if resp.Code != common.TxStatusCode_SUCCESS {
+		return fmt.Errorf("sync %s failed, %s", method, resp.Message)
+	}
+	if method == syncAdminMethodStatus {
+		fmt.Println(string(resp.ContractResult.Result))
+		return nil
+	}
+	fmt.Printf("sync %s succeed\n", method)
+	return nil
+}
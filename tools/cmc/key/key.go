@@ -30,6 +30,8 @@ func KeyCMD() *cobra.Command {
 		Long:  "ChainMaker key command",
 	}
 	keyCmd.AddCommand(genCMD())
+	keyCmd.AddCommand(encryptCMD())
+	keyCmd.AddCommand(decryptCMD())
 	return keyCmd
 }
 
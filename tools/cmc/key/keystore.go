@@ -0,0 +1,271 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package key
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// the keystore format is the same as the encrypted key files of the node in module/accesscontrol, which is
+// pinned by the golden keystore decrypted by the tests of both
+const (
+	keyStorePassphraseEnv = "CHAINMAKER_KEY_PASSPHRASE"
+
+	keyStoreVersion = 1
+	keyStoreKDF     = "scrypt"
+	keyStoreCipher  = "aes-256-gcm"
+	keyStoreKeyLen  = 32
+	keyStoreSaltLen = 32
+	keyStoreScryptN = 1 << 16
+	keyStoreScryptR = 8
+	keyStoreScryptP = 1
+)
+
+var (
+	inFile  string
+	outFile string
+)
+
+type keyStoreJSON struct {
+	Version    int                `json:"version"`
+	KDF        string             `json:"kdf"`
+	KDFParams  keyStoreScryptJSON `json:"kdfparams"`
+	Cipher     string             `json:"cipher"`
+	Nonce      string             `json:"nonce"`
+	CipherText string             `json:"ciphertext"`
+}
+
+type keyStoreScryptJSON struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt string `json:"salt"`
+}
+
+func encryptCMD() *cobra.Command {
+	encryptCmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt a private key file",
+		Long: strings.TrimSpace(
+			`Encrypt the PEM private key file into the keystore loaded by chainmaker with the passphrase.
+The passphrase is read from the env CHAINMAKER_KEY_PASSPHRASE, or prompted in the terminal.
+Example:
+$ cmc key encrypt -i ./node1.sign.key -o ./node1.sign.key.enc
+`,
+		),
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return encryptKeyFile()
+		},
+	}
+
+	attachKeyStoreFlags(encryptCmd)
+	return encryptCmd
+}
+
+func decryptCMD() *cobra.Command {
+	decryptCmd := &cobra.Command{
+		Use:   "decrypt",
+		Short: "Decrypt an encrypted private key file",
+		Long: strings.TrimSpace(
+			`Decrypt the keystore encrypted by "cmc key encrypt" into the PEM private key file with the passphrase.
+The passphrase is read from the env CHAINMAKER_KEY_PASSPHRASE, or prompted in the terminal.
+Example:
+$ cmc key decrypt -i ./node1.sign.key.enc -o ./node1.sign.key
+`,
+		),
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return decryptKeyFile()
+		},
+	}
+
+	attachKeyStoreFlags(decryptCmd)
+	return decryptCmd
+}
+
+func attachKeyStoreFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVarP(&inFile, "in", "i", "", "specify the input key file")
+	flags.StringVarP(&outFile, "out", "o", "", "specify the output key file")
+	cmd.MarkFlagRequired("in")
+	cmd.MarkFlagRequired("out")
+}
+
+func encryptKeyFile() error {
+	keyPEM, err := ioutil.ReadFile(inFile)
+	if err != nil {
+		return err
+	}
+	if isKeyStore(keyPEM) {
+		return fmt.Errorf("%s is encrypted already", inFile)
+	}
+	passphrase, err := readPassphrase(true)
+	if err != nil {
+		return err
+	}
+	keyStore, err := encryptKeyStore(keyPEM, passphrase)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(outFile, keyStore, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("encrypt %s to %s succeed\n", inFile, outFile)
+	return nil
+}
+
+func decryptKeyFile() error {
+	keyStore, err := ioutil.ReadFile(inFile)
+	if err != nil {
+		return err
+	}
+	if !isKeyStore(keyStore) {
+		return fmt.Errorf("%s is not an encrypted key file", inFile)
+	}
+	passphrase, err := readPassphrase(false)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := decryptKeyStore(keyStore, passphrase)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(outFile, keyPEM, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("decrypt %s to %s succeed\n", inFile, outFile)
+	return nil
+}
+
+// readPassphrase reads the passphrase from the env, or prompts it in the terminal, twice if confirm
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase, ok := os.LookupEnv(keyStorePassphraseEnv); ok {
+		if passphrase == "" {
+			return "", errors.New("empty passphrase")
+		}
+		return passphrase, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", fmt.Errorf("set the passphrase by the env %s", keyStorePassphraseEnv)
+	}
+	fmt.Print("Enter passphrase: ")
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("empty passphrase")
+	}
+	if confirm {
+		fmt.Print("Confirm passphrase: ")
+		again, err := terminal.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(passphrase, again) {
+			return "", errors.New("the passphrases do not match")
+		}
+	}
+	return string(passphrase), nil
+}
+
+func encryptKeyStore(keyPEM []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, keyStoreSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	params := keyStoreScryptJSON{N: keyStoreScryptN, R: keyStoreScryptR, P: keyStoreScryptP,
+		Salt: hex.EncodeToString(salt)}
+	aead, err := keyStoreAEAD(passphrase, params, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&keyStoreJSON{
+		Version:    keyStoreVersion,
+		KDF:        keyStoreKDF,
+		KDFParams:  params,
+		Cipher:     keyStoreCipher,
+		Nonce:      hex.EncodeToString(nonce),
+		CipherText: hex.EncodeToString(aead.Seal(nil, nonce, keyPEM, nil)),
+	}, "", "  ")
+}
+
+func decryptKeyStore(keyStore []byte, passphrase string) ([]byte, error) {
+	ks := &keyStoreJSON{}
+	if err := json.Unmarshal(keyStore, ks); err != nil {
+		return nil, fmt.Errorf("invalid key store, %v", err)
+	}
+	if ks.Version != keyStoreVersion || ks.KDF != keyStoreKDF || ks.Cipher != keyStoreCipher {
+		return nil, fmt.Errorf("unsupported key store of version %d, kdf %s and cipher %s",
+			ks.Version, ks.KDF, ks.Cipher)
+	}
+	salt, err := hex.DecodeString(ks.KDFParams.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt of key store, %v", err)
+	}
+	nonce, err := hex.DecodeString(ks.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce of key store, %v", err)
+	}
+	cipherText, err := hex.DecodeString(ks.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cipher text of key store, %v", err)
+	}
+	aead, err := keyStoreAEAD(passphrase, ks.KDFParams, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce size %d of key store", len(nonce))
+	}
+	keyPEM, err := aead.Open(nil, nonce, cipherText, nil)
+	if err != nil {
+		return nil, errors.New("decrypt key store failed, wrong passphrase or corrupted key store")
+	}
+	return keyPEM, nil
+}
+
+func isKeyStore(content []byte) bool {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 || content[0] != '{' {
+		return false
+	}
+	ks := &keyStoreJSON{}
+	return json.Unmarshal(content, ks) == nil && ks.KDF != "" && ks.CipherText != ""
+}
+
+func keyStoreAEAD(passphrase string, params keyStoreScryptJSON, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, keyStoreKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("derive key of key store failed, %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}